@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// DomainHealth summarizes scrape outcomes for a single store domain.
+type DomainHealth struct {
+	Domain           string  `json:"domain"`
+	ItemCount        int     `json:"itemCount"`
+	Attempts         int     `json:"attempts"`
+	SuccessRate      float64 `json:"successRate"`
+	FailureRate      float64 `json:"failureRate"`
+	AvgDurationMs    float64 `json:"avgDurationMs"`
+	PlaywrightShare  float64 `json:"playwrightShare"`
+	TopFailureReason *string `json:"topFailureReason,omitempty"`
+}
+
+// adminUserIDs are the operators allowed to use /admin endpoints
+// (ADMIN_USER_IDS). It is set from the configuration in main.
+var adminUserIDs []string
+
+// isAdmin reports whether userID is an operator.
+func isAdmin(userID string) bool {
+	return slices.Contains(adminUserIDs, userID)
+}
+
+// AdminMiddleware restricts a route to operators. It must run after
+// AuthMiddleware so that the user ID is available in the context.
+func AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(userIDKey).(string)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isAdmin(userID) {
+			slog.Warn("Non-admin attempted admin access", "user_id", userID, "path", r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+var domainHealthSorts = map[string]string{
+	"":             "attempts DESC, domain",
+	"attempts":     "attempts DESC, domain",
+	"domain":       "domain",
+	"failure_rate": "success_rate ASC, attempts DESC, domain",
+}
+
+// adminDomainsHandler serves /admin/domains.
+var adminDomainsHandler = methods{"GET": listDomainHealthHandler}.ServeHTTP
+
+func listDomainHealthHandler(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 90 {
+			http.Error(w, "days must be an integer between 1 and 90", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	orderBy, ok := domainHealthSorts[r.URL.Query().Get("sort")]
+	if !ok {
+		http.Error(w, "Invalid sort", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT domain,
+			COUNT(DISTINCT item_id) AS item_count,
+			COUNT(*) AS attempts,
+			AVG(CASE WHEN status = 'success' THEN 1.0 ELSE 0.0 END) AS success_rate,
+			COALESCE(AVG(duration_ms), 0) AS avg_duration_ms,
+			AVG(CASE WHEN used_playwright THEN 1.0 ELSE 0.0 END) AS playwright_share,
+			mode() WITHIN GROUP (ORDER BY failure_reason) FILTER (WHERE status <> 'success') AS top_failure_reason
+		FROM scrape_log
+		WHERE created_at >= NOW() - make_interval(days => $1)
+		GROUP BY domain
+		ORDER BY `+orderBy, days)
+	if err != nil {
+		slog.Error("Failed to query domain health", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	domains := []DomainHealth{}
+	for rows.Next() {
+		var d DomainHealth
+		var topFailure *string
+		if err := rows.Scan(&d.Domain, &d.ItemCount, &d.Attempts, &d.SuccessRate, &d.AvgDurationMs, &d.PlaywrightShare, &topFailure); err != nil {
+			slog.Error("Failed to scan domain health", "error", err)
+			continue
+		}
+		d.FailureRate = 1 - d.SuccessRate
+		d.TopFailureReason = topFailure
+		domains = append(domains, d)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(domains)
+}
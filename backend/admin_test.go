@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/settings"
+)
+
+// setupMockDB swaps the global db for a sqlmock instance for the duration of a test.
+func setupMockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	prev, prevSettings := db, settingsLoader
+	db = mockDB
+	settingsLoader = settings.NewLoader(mockDB, 0)
+	t.Cleanup(func() {
+		db, settingsLoader = prev, prevSettings
+		mockDB.Close()
+	})
+	return mock
+}
+
+// setAdminUserIDs configures the operators for the duration of a test.
+func setAdminUserIDs(t *testing.T, ids ...string) {
+	prev := adminUserIDs
+	adminUserIDs = ids
+	t.Cleanup(func() { adminUserIDs = prev })
+}
+
+func TestAdminMiddleware_Forbidden(t *testing.T) {
+	setAdminUserIDs(t, "admin-1", "admin-2")
+
+	req := httptest.NewRequest("GET", "/admin/domains", nil)
+	req = req.WithContext(setupTestContext("regular-user"))
+	w := httptest.NewRecorder()
+
+	AdminMiddleware(adminDomainsHandler)(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestAdminDomainsHandler_InvalidDays(t *testing.T) {
+	req := httptest.NewRequest("GET", "/admin/domains?days=0", nil)
+	w := httptest.NewRecorder()
+
+	adminDomainsHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestAdminDomainsHandler_SortByFailureRate(t *testing.T) {
+	setAdminUserIDs(t, "admin-1")
+	mock := setupMockDB(t)
+
+	rows := sqlmock.NewRows([]string{"domain", "item_count", "attempts", "success_rate", "avg_duration_ms", "playwright_share", "top_failure_reason"}).
+		AddRow("amazon.com", 12, 40, 0.25, 5400.0, 0.75, "blocked").
+		AddRow("uniqlo.com", 3, 10, 1.0, 800.0, 0.0, nil)
+	mock.ExpectQuery(`FROM scrape_log .* GROUP BY domain\s+ORDER BY success_rate ASC`).
+		WithArgs(30).
+		WillReturnRows(rows)
+
+	req := httptest.NewRequest("GET", "/admin/domains?days=30&sort=failure_rate", nil)
+	req = req.WithContext(setupTestContext("admin-1"))
+	w := httptest.NewRecorder()
+
+	AdminMiddleware(adminDomainsHandler)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var domains []DomainHealth
+	if err := json.NewDecoder(w.Body).Decode(&domains); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(domains) != 2 {
+		t.Fatalf("Expected 2 domains, got %d", len(domains))
+	}
+	if domains[0].Domain != "amazon.com" || domains[0].FailureRate != 0.75 {
+		t.Errorf("Unexpected first domain: %+v", domains[0])
+	}
+	if domains[0].TopFailureReason == nil || *domains[0].TopFailureReason != "blocked" {
+		t.Errorf("Expected top failure reason 'blocked', got %v", domains[0].TopFailureReason)
+	}
+	if domains[1].TopFailureReason != nil {
+		t.Errorf("Expected no failure reason for healthy domain, got %v", *domains[1].TopFailureReason)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefix marks strings that are PriceTrack API keys, which makes leaked
+// keys easy to recognize in logs and secret scanners.
+const apiKeyPrefix = "pt_"
+
+// APIKey is the public view of a stored key. The raw key is only ever
+// returned once, in the response to its creation.
+type APIKey struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Prefix     string  `json:"prefix"`
+	Key        string  `json:"key,omitempty"`
+	CreatedAt  string  `json:"createdAt"`
+	LastUsedAt *string `json:"lastUsedAt,omitempty"`
+	RevokedAt  *string `json:"revokedAt,omitempty"`
+}
+
+// generateAPIKey returns a new random key. Only its hash is persisted.
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashAPIKey hashes a key for storage and lookup. Keys carry 256 bits of
+// entropy, so a plain SHA-256 is sufficient (no need for a slow KDF).
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateAPIKey resolves a raw API key to its owning user ID, recording
+// the key as used. It returns sql.ErrNoRows for unknown or revoked keys.
+func authenticateAPIKey(ctx context.Context, key string) (string, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", sql.ErrNoRows
+	}
+
+	var userID string
+	err := db.QueryRowContext(ctx, `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING user_id
+	`, hashAPIKey(key)).Scan(&userID)
+	return userID, err
+}
+
+// apiKeysHandler serves /api-keys.
+var apiKeysHandler = methods{
+	"GET":  listAPIKeysHandler,
+	"POST": createAPIKeyHandler,
+}.ServeHTTP
+
+func listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, name, key_prefix, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		slog.Error("Failed to query api keys", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		var createdAt time.Time
+		var lastUsedAt, revokedAt sql.NullTime
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &createdAt, &lastUsedAt, &revokedAt); err != nil {
+			slog.Error("Failed to scan api key", "error", err)
+			continue
+		}
+		k.CreatedAt = createdAt.Format(time.RFC3339)
+		if lastUsedAt.Valid {
+			formatted := lastUsedAt.Time.Format(time.RFC3339)
+			k.LastUsedAt = &formatted
+		}
+		if revokedAt.Valid {
+			formatted := revokedAt.Time.Format(time.RFC3339)
+			k.RevokedAt = &formatted
+		}
+		keys = append(keys, k)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+func createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		http.Error(w, "name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		slog.Error("Failed to generate api key", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	k := APIKey{Name: req.Name, Prefix: key[:len(apiKeyPrefix)+6], Key: key}
+	var createdAt time.Time
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO api_keys (user_id, name, key_prefix, key_hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, userID, k.Name, k.Prefix, hashAPIKey(key)).Scan(&k.ID, &createdAt)
+	if err != nil {
+		slog.Error("Failed to insert api key", "error", err)
+		queryError(ctx, w, err, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+	k.CreatedAt = createdAt.Format(time.RFC3339)
+
+	slog.Info("Created API key", "id", k.ID, "user_id", userID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(k)
+}
+
+// apiKeyHandler serves /api-keys/{id}.
+var apiKeyHandler = methods{"DELETE": revokeAPIKeyHandler}.ServeHTTP
+
+func revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+	result, err := db.ExecContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, id, userID)
+	if err != nil {
+		slog.Error("Failed to revoke api key", "id", id, "error", err)
+		queryError(ctx, w, err, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("Revoked API key", "id", id, "user_id", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
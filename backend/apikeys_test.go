@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// echoUserHandler writes the authenticated user ID so tests can assert on it.
+func echoUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(userIDKey).(string)
+	w.Write([]byte(userID))
+}
+
+func TestAuthMiddleware_APIKeySuccess(t *testing.T) {
+	mock := setupMockDB(t)
+	key := "pt_abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+
+	mock.ExpectQuery("UPDATE api_keys").
+		WithArgs(hashAPIKey(key)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("user-42"))
+
+	req := httptest.NewRequest("GET", "/items", nil)
+	req.Header.Set("Authorization", "ApiKey "+key)
+	w := httptest.NewRecorder()
+
+	AuthMiddleware(echoUserHandler)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if w.Body.String() != "user-42" {
+		t.Errorf("Expected user-42 in context, got %q", w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestAuthMiddleware_APIKeyUnknownOrRevoked(t *testing.T) {
+	mock := setupMockDB(t)
+	key := "pt_revokedkeyrevokedkeyrevokedkeyrevokedkey"
+
+	mock.ExpectQuery("UPDATE api_keys").
+		WithArgs(hashAPIKey(key)).
+		WillReturnError(sql.ErrNoRows)
+
+	req := httptest.NewRequest("GET", "/items", nil)
+	req.Header.Set("Authorization", "ApiKey "+key)
+	w := httptest.NewRecorder()
+
+	AuthMiddleware(echoUserHandler)(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestAuthMiddleware_APIKeyWrongPrefix(t *testing.T) {
+	setupMockDB(t)
+
+	req := httptest.NewRequest("GET", "/items", nil)
+	req.Header.Set("Authorization", "ApiKey not-a-price-track-key")
+	w := httptest.NewRecorder()
+
+	// No DB expectation is set: malformed keys must be rejected without a lookup.
+	AuthMiddleware(echoUserHandler)(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestAPIKeysHandler_Create(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("INSERT INTO api_keys").
+		WithArgs("user-1", "nightly cron", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("key-id", time.Now()))
+
+	req := httptest.NewRequest("POST", "/api-keys", strings.NewReader(`{"name":"nightly cron"}`))
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+
+	apiKeysHandler(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var k APIKey
+	if err := json.NewDecoder(w.Body).Decode(&k); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(k.Key, apiKeyPrefix) || !strings.HasPrefix(k.Key, k.Prefix) {
+		t.Errorf("Unexpected key %q with prefix %q", k.Key, k.Prefix)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestAPIKeyHandler_RevokeNotFound(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectExec("UPDATE api_keys").
+		WithArgs("missing", "user-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest("DELETE", "/api-keys/missing", nil)
+	req.SetPathValue("id", "missing")
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+
+	apiKeyHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
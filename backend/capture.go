@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/xpath"
+
+	"price-track-backend/internal/scheduler"
+	"price-track-backend/internal/urlnorm"
+)
+
+// captureSchemaVersion is the newest capture payload POST /capture reads.
+// Older versions stay accepted for as long as extensions sending them are
+// in use.
+const captureSchemaVersion = 1
+
+const (
+	// maxCaptureSelectors caps the candidate selectors of one capture.
+	maxCaptureSelectors = 10
+	// captureNameLength and captureSnippetLength are how much of the product
+	// name and of the price element's HTML are kept, as the extension's
+	// picker did before captures moved to the server.
+	captureNameLength    = 70
+	captureSnippetLength = 800
+	// captureVerifyTimeout bounds the preview scrapes that pick the selector.
+	captureVerifyTimeout = importRowTimeout
+)
+
+// CaptureSelector is one way of finding the price element: a CSS selector,
+// an XPath or both.
+type CaptureSelector struct {
+	CSSSelector string `json:"cssSelector,omitempty"`
+	XPath       string `json:"xPath,omitempty"`
+}
+
+// CaptureSelection describes the element the user picked: its text and the
+// selectors the extension built for it.
+type CaptureSelection struct {
+	Text string `json:"text"`
+	CaptureSelector
+}
+
+// CapturePayload is the body of POST /capture: what the extension saw when
+// the user picked a price, before any of it is turned into an item.
+type CapturePayload struct {
+	// SchemaVersion is the version of this payload; see captureSchemaVersion.
+	SchemaVersion int              `json:"schemaVersion"`
+	Selection     CaptureSelection `json:"selection"`
+	// Selectors are further candidates for the price element, best first.
+	Selectors     []CaptureSelector `json:"selectors"`
+	OuterHTML     string            `json:"outerHtml"`
+	PageURL       string            `json:"pageUrl"`
+	DocumentTitle string            `json:"documentTitle"`
+	// OG holds the page's Open Graph tags by property, such as "og:title".
+	OG            map[string]string `json:"og"`
+	CapturedAtISO string            `json:"capturedAtIso"`
+}
+
+// CaptureVerification reports the preview scrape of a captured item's page
+// with the selector it was saved with. Status is as for an import row:
+// ok, selector_not_found, no_price, timeout or unreachable.
+type CaptureVerification struct {
+	Status string `json:"status"`
+	Price  string `json:"price,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CaptureResponse is the item POST /capture created, with the selectors it
+// falls back on and how its page scraped.
+type CaptureResponse struct {
+	TrackedItem
+	FallbackSelectors []CaptureSelector   `json:"fallbackSelectors"`
+	Verification      CaptureVerification `json:"verification"`
+}
+
+// captureHandler serves /capture.
+var captureHandler = methods{"POST": postCaptureHandler}.ServeHTTP
+
+// decodeCapture reads a capture payload of any supported schema version.
+func decodeCapture(r *http.Request) (CapturePayload, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return CapturePayload{}, err
+	}
+	var version struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(raw, &version); err != nil {
+		return CapturePayload{}, err
+	}
+	switch version.SchemaVersion {
+	case 0:
+		return CapturePayload{}, fmt.Errorf("schemaVersion is required")
+	case 1:
+		var p CapturePayload
+		err := json.Unmarshal(raw, &p)
+		return p, err
+	default:
+		return CapturePayload{}, fmt.Errorf("schemaVersion %d is not supported; the newest is %d", version.SchemaVersion, captureSchemaVersion)
+	}
+}
+
+// validCaptureSelector checks that sel has a selector and that each of its
+// selectors compiles.
+func validCaptureSelector(sel CaptureSelector) error {
+	if sel.CSSSelector == "" && sel.XPath == "" {
+		return fmt.Errorf("cssSelector or xPath is required")
+	}
+	if len(sel.CSSSelector) > maxFrameLength || len(sel.XPath) > maxFrameLength {
+		return fmt.Errorf("selectors must be at most %d characters", maxFrameLength)
+	}
+	if sel.CSSSelector != "" {
+		if _, err := cascadia.Compile(sel.CSSSelector); err != nil {
+			return fmt.Errorf("invalid cssSelector %q: %w", sel.CSSSelector, err)
+		}
+	}
+	if sel.XPath != "" {
+		if _, err := xpath.Compile(sel.XPath); err != nil {
+			return fmt.Errorf("invalid xPath %q: %w", sel.XPath, err)
+		}
+	}
+	return nil
+}
+
+// captureCandidates returns the picked element's selectors followed by the
+// other candidates, trimmed, without duplicates and without ones that do not
+// compile.
+func captureCandidates(p CapturePayload) []CaptureSelector {
+	var candidates []CaptureSelector
+	seen := map[CaptureSelector]bool{}
+	for _, sel := range append([]CaptureSelector{p.Selection.CaptureSelector}, p.Selectors...) {
+		sel.CSSSelector = strings.TrimSpace(sel.CSSSelector)
+		sel.XPath = strings.TrimSpace(sel.XPath)
+		if seen[sel] {
+			continue
+		}
+		seen[sel] = true
+		if err := validCaptureSelector(sel); err != nil {
+			slog.Debug("Dropping capture selector", "error", err)
+			continue
+		}
+		candidates = append(candidates, sel)
+	}
+	return candidates
+}
+
+// capturePageURL checks the captured page's URL and normalizes it (see
+// urlnorm.Normalize).
+func capturePageURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("pageUrl must be an http(s) URL")
+	}
+	return urlnorm.Normalize(u.String()), nil
+}
+
+// captureProductName names the product after its og:title, falling back to
+// the document title.
+func captureProductName(p CapturePayload) string {
+	name := strings.TrimSpace(p.OG["og:title"])
+	if name == "" {
+		name = strings.TrimSpace(p.DocumentTitle)
+	}
+	return truncateRunes(strings.Join(strings.Fields(name), " "), captureNameLength)
+}
+
+// captureImageURL returns og:image resolved against the page, or "" when it
+// is missing or not an http(s) URL.
+func captureImageURL(p CapturePayload, pageURL string) string {
+	raw := strings.TrimSpace(p.OG["og:image"])
+	if raw == "" {
+		return ""
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	u, err := base.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+// capturePriceText returns the picked element's text or, without one, the
+// text of its outer HTML, with whitespace collapsed.
+func capturePriceText(p CapturePayload) string {
+	text := p.Selection.Text
+	if strings.TrimSpace(text) == "" && p.OuterHTML != "" {
+		if doc, err := goquery.NewDocumentFromReader(strings.NewReader(p.OuterHTML)); err == nil {
+			text = doc.Text()
+		}
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// captureSnippet returns the start of the price element's HTML, marking where
+// it was cut.
+func captureSnippet(html string) string {
+	if cut := truncateRunes(html, captureSnippetLength); cut != html {
+		return cut + "…(truncated)"
+	}
+	return html
+}
+
+// truncateRunes returns s cut to at most n runes.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// captureFromPayload derives the item a capture describes, with its
+// candidate selectors. The first candidate is the item's selector until
+// verification picks another.
+func captureFromPayload(p CapturePayload) (TrackedItem, []CaptureSelector, error) {
+	pageURL, err := capturePageURL(p.PageURL)
+	if err != nil {
+		return TrackedItem{}, nil, err
+	}
+	if len(p.Selectors) > maxCaptureSelectors {
+		return TrackedItem{}, nil, fmt.Errorf("at most %d selectors can be sent", maxCaptureSelectors)
+	}
+	candidates := captureCandidates(p)
+	if len(candidates) == 0 {
+		return TrackedItem{}, nil, fmt.Errorf("no valid selector among the candidates")
+	}
+
+	item := TrackedItem{
+		PageURL:          pageURL,
+		PriceText:        capturePriceText(p),
+		ProductName:      captureProductName(p),
+		ImageURL:         captureImageURL(p, pageURL),
+		Tags:             []string{},
+		CSSSelector:      candidates[0].CSSSelector,
+		XPath:            candidates[0].XPath,
+		OuterHTMLSnippet: captureSnippet(p.OuterHTML),
+	}
+	if err := validateParseStrategy(&item.ParseStrategy); err != nil {
+		return TrackedItem{}, nil, err
+	}
+	if capturedPrice(item) == nil {
+		return TrackedItem{}, nil, fmt.Errorf("selection %q is not a price", item.PriceText)
+	}
+	if err := validateAdapterOrder(&item.AdapterOrder); err != nil {
+		return TrackedItem{}, nil, err
+	}
+	if err := validateSelectorMatch(&item.SelectorMatch); err != nil {
+		return TrackedItem{}, nil, err
+	}
+	if err := validateAcceptLanguage(&item.AcceptLanguage); err != nil {
+		return TrackedItem{}, nil, err
+	}
+	normalizeImages(&item)
+	return item, candidates, nil
+}
+
+// verifyCapture preview-scrapes item's page with each candidate in turn until
+// one reads a price, and returns that candidate's index with the result.
+// When none does, it returns the first candidate's result. Candidates are
+// only tried further while the page loads but shows no price.
+func verifyCapture(ctx context.Context, item TrackedItem, candidates []CaptureSelector) (int, CaptureVerification) {
+	ctx, cancel := context.WithTimeout(ctx, captureVerifyTimeout)
+	defer cancel()
+
+	var first CaptureVerification
+	for i, sel := range candidates {
+		price, err := previewScraper.ScrapeHTTP(ctx, scheduler.ScrapeRequest{
+			URL:            item.PageURL,
+			CSSSelector:    sel.CSSSelector,
+			XPath:          sel.XPath,
+			Match:          scheduler.SelectorMatch(item.SelectorMatch),
+			Order:          scheduler.AdapterOrder(item.AdapterOrder),
+			AcceptLanguage: item.AcceptLanguage,
+		})
+		v := CaptureVerification{Status: previewStatus(ctx, err), Price: price}
+		if err != nil {
+			v.Error = err.Error()
+		}
+		if v.Status == "ok" {
+			return i, v
+		}
+		if i == 0 {
+			first = v
+		}
+		if !errors.Is(err, scheduler.ErrSelectorNotFound) && !errors.Is(err, scheduler.ErrNoPrice) {
+			break
+		}
+	}
+	return 0, first
+}
+
+// insertCapturedItem stores item and its fallback selectors in one
+// transaction.
+func insertCapturedItem(ctx context.Context, item TrackedItem, fallbacks []CaptureSelector, capturedAt, savedAt time.Time, userID string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertItemTx(ctx, tx, item, sql.Null[[]byte]{}, capturedAt, savedAt, userID); err != nil {
+		return err
+	}
+	for i, sel := range fallbacks {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO item_fallback_selectors (item_id, position, css_selector, xpath) VALUES ($1, $2, $3, $4)
+		`, item.ID, i+1, sel.CSSSelector, sel.XPath); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// postCaptureHandler tracks the item the extension captured. The server
+// derives the item from the raw capture, preview-scrapes the page to pick
+// the selector that reads its price, and keeps the other candidates as
+// fallback selectors.
+func postCaptureHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	payload, err := decodeCapture(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	item, candidates, err := captureFromPayload(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	capturedAt := time.Now().UTC()
+	if payload.CapturedAtISO != "" {
+		if capturedAt, err = time.Parse(time.RFC3339, payload.CapturedAtISO); err != nil {
+			http.Error(w, "Invalid capturedAtIso", http.StatusBadRequest)
+			return
+		}
+	}
+	if item.ID, err = newItemID(); err != nil {
+		slog.Error("Failed to generate item id", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	chosen, verification := verifyCapture(r.Context(), item, candidates)
+	item.CSSSelector, item.XPath = candidates[chosen].CSSSelector, candidates[chosen].XPath
+	fallbacks := append(append([]CaptureSelector{}, candidates[:chosen]...), candidates[chosen+1:]...)
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	if !checkItemsQuota(ctx, w, userID, 1) {
+		return
+	}
+	savedAt := time.Now().UTC()
+	if err := insertCapturedItem(ctx, item, fallbacks, capturedAt, savedAt, userID); err != nil {
+		slog.Error("Failed to insert captured item", "error", err)
+		queryError(ctx, w, err, "Failed to save item", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Captured item", "id", item.ID, "productName", item.ProductName, "verification", verification.Status, "user_id", userID)
+	invalidateUserCache(userID)
+	item.CapturedAtISO = capturedAt.Format(time.RFC3339)
+	item.SavedAtISO = savedAt.Format(time.RFC3339)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CaptureResponse{TrackedItem: item, FallbackSelectors: fallbacks, Verification: verification})
+}
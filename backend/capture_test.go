@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// postCapture performs a POST /capture request.
+func postCapture(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/capture", strings.NewReader(body))
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+	captureHandler(w, req)
+	return w
+}
+
+func TestCaptureHandler_CreatesItemWithFallbackSelectors(t *testing.T) {
+	mock := setupMockDB(t)
+	allowLocalPreviews(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$19.99</div></body></html>`))
+	}))
+	defer ts.Close()
+	pageURL := ts.URL + "/p/1"
+
+	expectItemsQuota(mock, "test-user-id", nil, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tracked_items").
+		WithArgs(sqlmock.AnyArg(), "$19.99", "Widget", pageURL+"/w.png", ".price", "", pageURL, sqlmock.AnyArg(), sqlmock.AnyArg(), "test-user-id", nil, nil, `{"`+pageURL+`/w.png"}`, pageURL, "auto", 19.99, "en-US", nil, nil, "", "", "", "", "", "{}", "", "", "19.99 USD", "first", nil, "first", "", "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO item_snippets").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// The selector that read a price is tracked; the others are kept in order.
+	mock.ExpectExec("INSERT INTO item_fallback_selectors").
+		WithArgs(sqlmock.AnyArg(), 1, "#buy .old-price", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO item_fallback_selectors").
+		WithArgs(sqlmock.AnyArg(), 2, "", "//div[@class='price']").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	body := `{"schemaVersion":1,
+		"selection":{"text":" $19.99\n","cssSelector":"#buy .old-price"},
+		"selectors":[{"cssSelector":"div["},{"cssSelector":".price"},{"cssSelector":"#buy .old-price"},{"xPath":"//div[@class='price']"}],
+		"outerHtml":"<div class=\"price\">$19.99</div>",
+		"pageUrl":"` + pageURL + `?gclid=abc#reviews",
+		"documentTitle":"Widget | Example Shop",
+		"og":{"og:title":" Widget ","og:image":"1/w.png"},
+		"capturedAtIso":"2025-01-01T00:00:00Z"}`
+	w := postCapture(t, body)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var resp CaptureResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.ID == "" || resp.CSSSelector != ".price" || resp.PageURL != pageURL || resp.CapturedAtISO != "2025-01-01T00:00:00Z" {
+		t.Errorf("Unexpected item %+v", resp.TrackedItem)
+	}
+	if resp.Verification.Status != "ok" || resp.Verification.Price != "$19.99" {
+		t.Errorf("Expected a verified price, got %+v", resp.Verification)
+	}
+	if len(resp.FallbackSelectors) != 2 {
+		t.Errorf("Expected 2 fallback selectors, got %+v", resp.FallbackSelectors)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCaptureHandler_RejectsBadPayloads(t *testing.T) {
+	setupMockDB(t)
+
+	tests := map[string]string{
+		"no version":     `{"selection":{"text":"$5","cssSelector":".price"},"pageUrl":"https://example.com/p"}`,
+		"newer version":  `{"schemaVersion":99,"selection":{"text":"$5","cssSelector":".price"},"pageUrl":"https://example.com/p"}`,
+		"no selector":    `{"schemaVersion":1,"selection":{"text":"$5","cssSelector":"div["},"pageUrl":"https://example.com/p"}`,
+		"not a price":    `{"schemaVersion":1,"selection":{"text":"Add to cart","cssSelector":".price"},"pageUrl":"https://example.com/p"}`,
+		"not a page":     `{"schemaVersion":1,"selection":{"text":"$5","cssSelector":".price"},"pageUrl":"chrome://newtab"}`,
+		"bad capture at": `{"schemaVersion":1,"selection":{"text":"$5","cssSelector":".price"},"pageUrl":"https://example.com/p","capturedAtIso":"yesterday"}`,
+	}
+	for name, body := range tests {
+		if w := postCapture(t, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", name, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestCaptureFromPayload_FallsBackToDocumentTitleAndHTML(t *testing.T) {
+	item, candidates, err := captureFromPayload(CapturePayload{
+		SchemaVersion: 1,
+		Selection:     CaptureSelection{CaptureSelector: CaptureSelector{XPath: "//span[@id='p']"}},
+		OuterHTML:     `<span id="p"><b>1.234,50</b> €</span>`,
+		PageURL:       "HTTPS://Shop.example.com:443/p/1/",
+		DocumentTitle: "  Lamp\n  – Shop ",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if item.ProductName != "Lamp – Shop" || item.PriceText != "1.234,50 €" || item.PageURL != "https://shop.example.com/p/1" {
+		t.Errorf("Unexpected item %+v", item)
+	}
+	if len(candidates) != 1 || item.XPath != "//span[@id='p']" || item.CSSSelector != "" {
+		t.Errorf("Expected the selection's XPath alone, got %+v", candidates)
+	}
+}
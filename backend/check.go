@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"price-track-backend/internal/scheduler"
+)
+
+// jobQueue queues price checks in scrape_jobs. main sets it to a scheduler
+// once the database is open.
+var jobQueue interface {
+	EnqueueJobs(ctx context.Context, reason scheduler.JobReason, itemIDs []string) ([]int64, error)
+}
+
+// ItemCheck is the response to asking for an item to be checked.
+type ItemCheck struct {
+	JobID int64 `json:"jobId"`
+}
+
+// itemCheckHandler serves /items/{id}/check.
+var itemCheckHandler = methods{"POST": checkItemHandler}.ServeHTTP
+
+// checkItemHandler queues a check of the caller's item ahead of the scheduled
+// ones and answers 202 Accepted. The next scraper run picks it up first; an
+// item that is already queued keeps its job, moved up the queue.
+func checkItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+	job, err := queueItemCheck(ctx, userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to queue check", "id", id, "error", err)
+		queryError(ctx, w, err, "Failed to queue check", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Queued item check", "id", id, "user_id", userID, "job_id", job)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(ItemCheck{JobID: job})
+}
+
+// queueItemCheck queues a check of the user's item id as a manual job and
+// returns the job. It returns sql.ErrNoRows if the user has no such item.
+func queueItemCheck(ctx context.Context, userID, id string) (int64, error) {
+	if err := ownItem(ctx, userID, id); err != nil {
+		return 0, err
+	}
+	jobs, err := jobQueue.EnqueueJobs(ctx, scheduler.JobManual, []string{id})
+	if err != nil {
+		return 0, err
+	}
+	if len(jobs) == 0 {
+		return 0, errors.New("no job queued")
+	}
+	return jobs[0], nil
+}
+
+// ownItem returns sql.ErrNoRows unless the user has an item id.
+func ownItem(ctx context.Context, userID, id string) error {
+	var exists bool
+	return db.QueryRowContext(ctx, `SELECT TRUE FROM tracked_items WHERE id = $1 AND user_id = $2`, id, userID).Scan(&exists)
+}
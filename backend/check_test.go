@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/scheduler"
+)
+
+// fakeJobQueue records the checks it was asked to queue.
+type fakeJobQueue struct {
+	reasons []scheduler.JobReason
+	items   []string
+}
+
+func (f *fakeJobQueue) EnqueueJobs(_ context.Context, reason scheduler.JobReason, itemIDs []string) ([]int64, error) {
+	f.reasons = append(f.reasons, reason)
+	f.items = append(f.items, itemIDs...)
+	return []int64{42}, nil
+}
+
+func setJobQueue(t *testing.T) *fakeJobQueue {
+	t.Helper()
+	f := &fakeJobQueue{}
+	prev := jobQueue
+	jobQueue = f
+	t.Cleanup(func() { jobQueue = prev })
+	return f
+}
+
+func postItemCheck(id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/items/"+id+"/check", nil)
+	req.SetPathValue("id", id)
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+	itemCheckHandler(w, req)
+	return w
+}
+
+func TestItemCheckHandler_QueuesManualJob(t *testing.T) {
+	mock := setupMockDB(t)
+	f := setJobQueue(t)
+	mock.ExpectQuery(`SELECT TRUE FROM tracked_items WHERE id = \$1 AND user_id = \$2`).
+		WithArgs("item-1", "test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"bool"}).AddRow(true))
+
+	w := postItemCheck("item-1")
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var check ItemCheck
+	if err := json.Unmarshal(w.Body.Bytes(), &check); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if check.JobID != 42 {
+		t.Errorf("Expected job 42, got %d", check.JobID)
+	}
+	if len(f.reasons) != 1 || f.reasons[0] != scheduler.JobManual || f.items[0] != "item-1" {
+		t.Errorf("Expected a manual check of item-1, got %v %q", f.reasons, f.items)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemCheckHandler_OtherUsersItem(t *testing.T) {
+	mock := setupMockDB(t)
+	f := setJobQueue(t)
+	mock.ExpectQuery(`SELECT TRUE FROM tracked_items`).
+		WithArgs("other", "test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"bool"}))
+
+	if w := postItemCheck("other"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if len(f.items) != 0 {
+		t.Errorf("Expected nothing queued, got %q", f.items)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"price-track-backend/internal/scheduler"
+)
+
+// checkHookRateLimit limits the checks each check hook token can queue. The
+// limiter is keyed by token rather than client IP: automation platforms
+// share their egress addresses between many users.
+var checkHookRateLimit = newIPRateLimiter("check-hook", 12, time.Hour)
+
+// CheckHook is the response to creating an item's check hook. URL carries
+// Token, and either lets whoever holds it queue checks of the item.
+type CheckHook struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// signCheckHook returns the check hook token of an item: an HMAC-SHA256,
+// under the server's token secret, of its ID and the nonce its owner
+// generated the hook with. Clearing the nonce revokes the token.
+func signCheckHook(itemID, nonce string) string {
+	mac := hmac.New(sha256.New, emailTokenSecret)
+	mac.Write([]byte("check-hook\n" + itemID + "\n" + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkHookToken returns the token a hook request carries, as a bearer token
+// or in ?token=.
+func checkHookToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("token")
+}
+
+// itemCheckHookHandler serves /items/{id}/check-hook.
+var itemCheckHookHandler = methods{
+	"POST":   createCheckHookHandler,
+	"DELETE": deleteCheckHookHandler,
+}.ServeHTTP
+
+// createCheckHookHandler returns the item's check hook, creating one if the
+// item has none yet.
+func createCheckHookHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if len(emailTokenSecret) == 0 {
+		http.Error(w, "Check hooks are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		slog.Error("Failed to generate check hook nonce", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	nonce := hex.EncodeToString(b)
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+	err := db.QueryRowContext(ctx, `
+		UPDATE tracked_items
+		SET check_hook_nonce = COALESCE(check_hook_nonce, $3)
+		WHERE id = $1 AND user_id = $2
+		RETURNING check_hook_nonce
+	`, id, userID, nonce).Scan(&nonce)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to create check hook", "id", id, "error", err)
+		queryError(ctx, w, err, "Failed to create check hook", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Created check hook", "id", id, "user_id", userID)
+	token := signCheckHook(id, nonce)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CheckHook{
+		Token: token,
+		URL:   strings.TrimSuffix(publicURL, "/") + "/hooks/check/" + id + "?token=" + token,
+	})
+}
+
+// deleteCheckHookHandler revokes the item's check hook. Revoking an item
+// without one succeeds.
+func deleteCheckHookHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+	result, err := db.ExecContext(ctx, `UPDATE tracked_items SET check_hook_nonce = NULL WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		slog.Error("Failed to revoke check hook", "id", id, "error", err)
+		queryError(ctx, w, err, "Failed to revoke check hook", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("Revoked check hook", "id", id, "user_id", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkHookHandler serves /hooks/check/{itemId}. The item's check hook token
+// stands in for authentication, so that automations need no user session.
+var checkHookHandler = methods{"POST": postCheckHookHandler}.ServeHTTP
+
+// postCheckHookHandler queues a check of the item ahead of the scheduled
+// ones, as POST /items/{id}/check does for its owner. Paused, broken and
+// discontinued items are refused: a check would not be run, or would not
+// find a price.
+func postCheckHookHandler(w http.ResponseWriter, r *http.Request) {
+	token := checkHookToken(r)
+	if token == "" || len(emailTokenSecret) == 0 {
+		http.Error(w, "Invalid check hook token", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("itemId")
+	var nonce, status sql.NullString
+	var paused bool
+	err := db.QueryRowContext(ctx, `
+		SELECT check_hook_nonce, paused_at IS NOT NULL, last_scrape_status FROM tracked_items WHERE id = $1
+	`, id).Scan(&nonce, &paused, &status)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("Failed to look up item", "id", id, "error", err)
+		queryError(ctx, w, err, "Failed to queue check", http.StatusInternalServerError)
+		return
+	}
+	// A missing item, a revoked hook and a wrong token look the same.
+	if !nonce.Valid || !hmac.Equal([]byte(token), []byte(signCheckHook(id, nonce.String))) {
+		http.Error(w, "Invalid check hook token", http.StatusUnauthorized)
+		return
+	}
+	if ok, retry := checkHookRateLimit.allow(token); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retry.Round(time.Second)/time.Second)))
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return
+	}
+
+	switch {
+	case paused:
+		http.Error(w, "Item is paused; resume it to check its price", http.StatusConflict)
+		return
+	case status.String == "selector_broken":
+		http.Error(w, "Item's price selector is broken; re-pick the price to check it", http.StatusConflict)
+		return
+	case status.String == "discontinued":
+		http.Error(w, "Item's page is gone; the product looks discontinued", http.StatusConflict)
+		return
+	}
+
+	jobs, err := jobQueue.EnqueueJobs(ctx, scheduler.JobManual, []string{id})
+	if err != nil || len(jobs) == 0 {
+		slog.Error("Failed to queue check", "id", id, "error", err)
+		queryError(ctx, w, err, "Failed to queue check", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Queued item check from hook", "id", id, "job_id", jobs[0])
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(ItemCheck{JobID: jobs[0]})
+}
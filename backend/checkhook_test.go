@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/scheduler"
+)
+
+// setCheckHookSecret signs check hooks with a test secret, and gives the test
+// a rate limiter of its own.
+func setCheckHookSecret(t *testing.T) {
+	t.Helper()
+	prevSecret, prevURL, prevLimit := emailTokenSecret, publicURL, checkHookRateLimit
+	emailTokenSecret, publicURL, checkHookRateLimit = []byte("test-secret"), "https://api.example.com", newIPRateLimiter("check-hook", 2, time.Hour)
+	t.Cleanup(func() { emailTokenSecret, publicURL, checkHookRateLimit = prevSecret, prevURL, prevLimit })
+}
+
+// postCheckHook performs POST /hooks/check/{itemId} with token in the query.
+func postCheckHook(id, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/hooks/check/"+id+"?token="+token, nil)
+	req.SetPathValue("itemId", id)
+	w := httptest.NewRecorder()
+	checkHookHandler(w, req)
+	return w
+}
+
+// expectHookedItem expects the item lookup of a hook request.
+func expectHookedItem(mock sqlmock.Sqlmock, id string, nonce any, paused bool, status any) {
+	mock.ExpectQuery(`SELECT check_hook_nonce, paused_at IS NOT NULL, last_scrape_status FROM tracked_items WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"check_hook_nonce", "paused", "last_scrape_status"}).AddRow(nonce, paused, status))
+}
+
+func TestItemCheckHookHandler_CreatesAndRevokes(t *testing.T) {
+	mock := setupMockDB(t)
+	setCheckHookSecret(t)
+
+	mock.ExpectQuery(`SET check_hook_nonce = COALESCE\(check_hook_nonce, \$3\)`).
+		WithArgs("item-1", "test-user-id", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"check_hook_nonce"}).AddRow("nonce-1"))
+	mock.ExpectExec(`SET check_hook_nonce = NULL WHERE id = \$1 AND user_id = \$2`).
+		WithArgs("item-1", "test-user-id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SET check_hook_nonce = NULL`).
+		WithArgs("item-2", "test-user-id").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	request := func(method, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/items/"+id+"/check-hook", nil)
+		req.SetPathValue("id", id)
+		req = req.WithContext(setupTestContext("test-user-id"))
+		w := httptest.NewRecorder()
+		itemCheckHookHandler(w, req)
+		return w
+	}
+
+	w := request("POST", "item-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var hook CheckHook
+	if err := json.Unmarshal(w.Body.Bytes(), &hook); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if hook.Token != signCheckHook("item-1", "nonce-1") || hook.URL != "https://api.example.com/hooks/check/item-1?token="+hook.Token {
+		t.Errorf("Unexpected hook %+v", hook)
+	}
+	if w := request("DELETE", "item-1"); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if w := request("DELETE", "item-2"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for another user's item, got %d", http.StatusNotFound, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCheckHookHandler_QueuesManualJob(t *testing.T) {
+	mock := setupMockDB(t)
+	setCheckHookSecret(t)
+	f := setJobQueue(t)
+	expectHookedItem(mock, "item-1", "nonce-1", false, "success")
+	expectHookedItem(mock, "item-1", "nonce-1", false, "success")
+
+	w := postCheckHook("item-1", signCheckHook("item-1", "nonce-1"))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	if len(f.items) != 1 || f.items[0] != "item-1" || f.reasons[0] != scheduler.JobManual {
+		t.Errorf("Expected a manual check of item-1, got %v %v", f.reasons, f.items)
+	}
+
+	// The token also works as a bearer token.
+	req := httptest.NewRequest("POST", "/hooks/check/item-1", nil)
+	req.SetPathValue("itemId", "item-1")
+	req.Header.Set("Authorization", "Bearer "+signCheckHook("item-1", "nonce-1"))
+	w = httptest.NewRecorder()
+	checkHookHandler(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status %d with a bearer token, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCheckHookHandler_RejectsBadTokens(t *testing.T) {
+	mock := setupMockDB(t)
+	setCheckHookSecret(t)
+	f := setJobQueue(t)
+
+	// Signed for another item, signed with a revoked nonce, and for an item
+	// whose hook was revoked or that does not exist.
+	expectHookedItem(mock, "item-1", "nonce-1", false, "success")
+	expectHookedItem(mock, "item-1", "nonce-2", false, "success")
+	expectHookedItem(mock, "item-1", nil, false, "success")
+	mock.ExpectQuery(`FROM tracked_items WHERE id = \$1`).
+		WithArgs("item-9").
+		WillReturnRows(sqlmock.NewRows([]string{"check_hook_nonce", "paused", "last_scrape_status"}))
+
+	tokens := []struct{ id, token string }{
+		{"item-1", signCheckHook("item-2", "nonce-1")},
+		{"item-1", signCheckHook("item-1", "nonce-1")},
+		{"item-1", signCheckHook("item-1", "nonce-1")},
+		{"item-9", signCheckHook("item-9", "nonce-1")},
+	}
+	for i, tt := range tokens {
+		if w := postCheckHook(tt.id, tt.token); w.Code != http.StatusUnauthorized {
+			t.Errorf("Request %d: expected status %d, got %d", i+1, http.StatusUnauthorized, w.Code)
+		}
+	}
+	if w := postCheckHook("item-1", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without a token, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if len(f.items) != 0 {
+		t.Errorf("Expected nothing queued, got %v", f.items)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCheckHookHandler_RejectsUncheckableItems(t *testing.T) {
+	mock := setupMockDB(t)
+	setCheckHookSecret(t)
+	setJobQueue(t)
+
+	tests := []struct {
+		id     string
+		paused bool
+		status any
+		want   string
+	}{
+		{"item-1", true, "success", "paused"},
+		{"item-2", false, "selector_broken", "selector is broken"},
+		{"item-3", false, "discontinued", "discontinued"},
+	}
+	for _, tt := range tests {
+		expectHookedItem(mock, tt.id, "nonce-1", tt.paused, tt.status)
+		w := postCheckHook(tt.id, signCheckHook(tt.id, "nonce-1"))
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: expected status %d mentioning %q, got %d: %s", tt.id, http.StatusConflict, tt.want, w.Code, w.Body.String())
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCheckHookHandler_RateLimitsPerToken(t *testing.T) {
+	mock := setupMockDB(t)
+	setCheckHookSecret(t)
+	setJobQueue(t)
+	for i := 0; i < 4; i++ {
+		expectHookedItem(mock, "item-1", "nonce-1", false, "success")
+	}
+	expectHookedItem(mock, "item-2", "nonce-1", false, "success")
+
+	token := signCheckHook("item-1", "nonce-1")
+	for i := 0; i < 2; i++ {
+		if w := postCheckHook("item-1", token); w.Code != http.StatusAccepted {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, http.StatusAccepted, w.Code)
+		}
+	}
+	w := postCheckHook("item-1", token)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected status %d with Retry-After, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	// A wrong token is refused before it is counted.
+	if w := postCheckHook("item-1", signCheckHook("item-1", "nonce-2")); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := postCheckHook("item-2", signCheckHook("item-2", "nonce-1")); w.Code != http.StatusAccepted {
+		t.Errorf("Expected another item's token to be allowed, got %d", w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+
+	"price-track-backend/internal/config"
+	"price-track-backend/internal/snippet"
+)
+
+// backfill-snippets moves outer_html_snippet values out of tracked_items into
+// the compressed item_snippets table (migration 008), then logs how much
+// storage the move saved.
+func main() {
+	batchSize := flag.Int("batch", 500, "number of items moved per transaction")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		slog.Warn("No .env file found, relying on system environment variables")
+	}
+
+	cfg, err := config.LoadScraper(os.Getenv)
+	if err != nil {
+		slog.Error("Refusing to start", "error", err)
+		os.Exit(1)
+	}
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		slog.Error("Failed to open database connection", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		slog.Error("Failed to ping database", "error", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	before := tableSizes(ctx, db)
+
+	var items, rawBytes, compressedBytes int64
+	lastID := ""
+	for {
+		n, raw, compressed, next, err := backfillBatch(ctx, db, lastID, *batchSize)
+		if err != nil {
+			slog.Error("Backfill failed", "after_id", lastID, "error", err)
+			os.Exit(1)
+		}
+		items += int64(n)
+		rawBytes += raw
+		compressedBytes += compressed
+		if n < *batchSize {
+			break
+		}
+		lastID = next
+		slog.Info("Backfilled batch", "items", items, "last_id", lastID)
+	}
+
+	after := tableSizes(ctx, db)
+	slog.Info("Snippet backfill finished",
+		"items", items,
+		"snippet_bytes", rawBytes,
+		"compressed_bytes", compressedBytes,
+		"saved_bytes", rawBytes-compressedBytes,
+	)
+	// Postgres only returns the freed space to the OS after VACUUM FULL; until
+	// then the on-disk sizes below mostly show the snippets table's growth.
+	slog.Info("Table sizes",
+		"tracked_items_before", before["tracked_items"],
+		"tracked_items_after", after["tracked_items"],
+		"item_snippets_before", before["item_snippets"],
+		"item_snippets_after", after["item_snippets"],
+	)
+}
+
+// backfillBatch moves up to limit snippets with ids greater than afterID in a
+// single transaction. It returns the number of items moved, their raw and
+// compressed sizes, and the last id seen.
+func backfillBatch(ctx context.Context, db *sql.DB, afterID string, limit int) (int, int64, int64, string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, 0, "", err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, outer_html_snippet
+		FROM tracked_items
+		WHERE outer_html_snippet <> '' AND id > $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE
+	`, afterID, limit)
+	if err != nil {
+		return 0, 0, 0, "", err
+	}
+	type pending struct {
+		id   string
+		html string
+	}
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.html); err != nil {
+			rows.Close()
+			return 0, 0, 0, "", err
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, 0, "", err
+	}
+
+	var raw, compressed int64
+	for _, p := range batch {
+		data, err := snippet.Compress(p.html)
+		if err != nil {
+			return 0, 0, 0, "", err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO item_snippets (item_id, snippet_gz) VALUES ($1, $2)
+			ON CONFLICT (item_id) DO UPDATE SET snippet_gz = EXCLUDED.snippet_gz
+		`, p.id, data); err != nil {
+			return 0, 0, 0, "", err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE tracked_items SET outer_html_snippet = '' WHERE id = $1`, p.id); err != nil {
+			return 0, 0, 0, "", err
+		}
+		raw += int64(len(p.html))
+		compressed += int64(len(data))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, 0, "", err
+	}
+	last := ""
+	if len(batch) > 0 {
+		last = batch[len(batch)-1].id
+	}
+	return len(batch), raw, compressed, last, nil
+}
+
+// tableSizes returns the total on-disk size (including TOAST and indexes) of
+// the tables touched by the backfill.
+func tableSizes(ctx context.Context, db *sql.DB) map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, table := range []string{"tracked_items", "item_snippets"} {
+		var size int64
+		if err := db.QueryRowContext(ctx, `SELECT pg_total_relation_size($1::regclass)`, table).Scan(&size); err != nil {
+			slog.Warn("Failed to read table size", "table", table, "error", err)
+			continue
+		}
+		sizes[table] = size
+	}
+	return sizes
+}
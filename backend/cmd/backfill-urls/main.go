@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+
+	"price-track-backend/internal/config"
+	"price-track-backend/internal/urlnorm"
+)
+
+// backfill-urls fills tracked_items.normalized_url (migration 011) for items
+// saved before the column existed. With -all it recomputes every row, which
+// is needed after the normalization rules change.
+func main() {
+	batchSize := flag.Int("batch", 500, "number of items updated per transaction")
+	all := flag.Bool("all", false, "recompute normalized_url for every item, not just missing ones")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		slog.Warn("No .env file found, relying on system environment variables")
+	}
+
+	cfg, err := config.LoadScraper(os.Getenv)
+	if err != nil {
+		slog.Error("Refusing to start", "error", err)
+		os.Exit(1)
+	}
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		slog.Error("Failed to open database connection", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		slog.Error("Failed to ping database", "error", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	var items, changed int64
+	lastID := ""
+	for {
+		n, c, next, err := backfillBatch(ctx, db, lastID, *batchSize, *all)
+		if err != nil {
+			slog.Error("Backfill failed", "after_id", lastID, "error", err)
+			os.Exit(1)
+		}
+		items += int64(n)
+		changed += c
+		if n < *batchSize {
+			break
+		}
+		lastID = next
+		slog.Info("Backfilled batch", "items", items, "last_id", lastID)
+	}
+
+	slog.Info("URL backfill finished", "items", items, "changed", changed)
+}
+
+// backfillBatch normalizes up to limit items with ids greater than afterID in
+// a single transaction. It returns the number of items read, how many of them
+// changed, and the last id seen.
+func backfillBatch(ctx context.Context, db *sql.DB, afterID string, limit int, all bool) (int, int64, string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	defer tx.Rollback()
+
+	cond := "normalized_url IS NULL AND "
+	if all {
+		cond = ""
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, page_url
+		FROM tracked_items
+		WHERE `+cond+`id > $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE
+	`, afterID, limit)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	type pending struct {
+		id      string
+		pageURL string
+	}
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.pageURL); err != nil {
+			rows.Close()
+			return 0, 0, "", err
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, "", err
+	}
+
+	var changed int64
+	for _, p := range batch {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE tracked_items SET normalized_url = $1
+			WHERE id = $2 AND normalized_url IS DISTINCT FROM $1
+		`, urlnorm.Normalize(p.pageURL), p.id)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		n, _ := result.RowsAffected()
+		changed += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, "", err
+	}
+	last := ""
+	if len(batch) > 0 {
+		last = batch[len(batch)-1].id
+	}
+	return len(batch), changed, last, nil
+}
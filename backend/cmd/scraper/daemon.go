@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"price-track-backend/internal/config"
+	"price-track-backend/internal/scheduler"
+)
+
+// runDaemon checks all prices every SCHEDULER_INTERVAL until ctx is done,
+// serving /health and /metrics on SCRAPER_LISTEN_ADDR meanwhile.
+func runDaemon(ctx context.Context, sch *scheduler.Scheduler, cfg config.Config) {
+	daemon := scheduler.NewDaemon(cfg.SchedulerInterval, sch.CheckAllPrices)
+	started := time.Now()
+
+	if cfg.ScraperListenAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+			serveHealth(w, daemon.Stats(), cfg.SchedulerInterval, started)
+		})
+		mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+			serveMetrics(w, daemon.Stats(), sch.NotificationsSilent())
+		})
+		srv := &http.Server{Addr: cfg.ScraperListenAddr, Handler: mux}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Scraper listener failed", "addr", cfg.ScraperListenAddr, "error", err)
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+		slog.Info("Serving scraper health", "addr", cfg.ScraperListenAddr)
+	}
+
+	slog.Info("Scraper daemon started", "interval", cfg.SchedulerInterval)
+	daemon.Run(ctx)
+}
+
+// serveHealth responds 200 while runs keep finishing and 503 once none has
+// finished within twice the interval, like the API's /health/scheduler.
+func serveHealth(w http.ResponseWriter, stats scheduler.DaemonStats, interval time.Duration, started time.Time) {
+	status := "ok"
+	last := stats.LastFinishedAt
+	if last.IsZero() {
+		last = started
+	}
+	if time.Since(last) > 2*interval {
+		status = "stale"
+	}
+
+	body := map[string]any{
+		"status":   status,
+		"running":  stats.Running,
+		"runs":     stats.Runs,
+		"skipped":  stats.Skipped,
+		"interval": interval.String(),
+	}
+	if !stats.LastFinishedAt.IsZero() {
+		body["lastRunAt"] = stats.LastFinishedAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// serveMetrics writes the daemon's stats in the Prometheus text format, with
+// whether price drop notifications have unexpectedly stopped.
+func serveMetrics(w http.ResponseWriter, stats scheduler.DaemonStats, notificationsSilent bool) {
+	running := 0
+	if stats.Running {
+		running = 1
+	}
+	silent := 0
+	if notificationsSilent {
+		silent = 1
+	}
+	var lastRun int64
+	if !stats.LastFinishedAt.IsZero() {
+		lastRun = stats.LastFinishedAt.Unix()
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP pricetrack_scraper_runs_total Scrape runs started.\n# TYPE pricetrack_scraper_runs_total counter\npricetrack_scraper_runs_total %d\n", stats.Runs)
+	fmt.Fprintf(w, "# HELP pricetrack_scraper_skipped_total Ticks skipped because the previous run was still going.\n# TYPE pricetrack_scraper_skipped_total counter\npricetrack_scraper_skipped_total %d\n", stats.Skipped)
+	fmt.Fprintf(w, "# HELP pricetrack_scraper_running Whether a scrape run is in progress.\n# TYPE pricetrack_scraper_running gauge\npricetrack_scraper_running %d\n", running)
+	fmt.Fprintf(w, "# HELP pricetrack_scraper_last_run_timestamp_seconds When the last scrape run finished.\n# TYPE pricetrack_scraper_last_run_timestamp_seconds gauge\npricetrack_scraper_last_run_timestamp_seconds %d\n", lastRun)
+	fmt.Fprintf(w, "# HELP pricetrack_notifications_silent Whether no price drop has been notified for unexpectedly long.\n# TYPE pricetrack_notifications_silent gauge\npricetrack_notifications_silent %d\n", silent)
+}
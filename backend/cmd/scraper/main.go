@@ -3,17 +3,42 @@ package main
 import (
 	"context"
 	"database/sql"
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
+	"price-track-backend/internal/adapters"
+	"price-track-backend/internal/config"
+	"price-track-backend/internal/email"
+	"price-track-backend/internal/realtime"
 	"price-track-backend/internal/scheduler"
+	"price-track-backend/internal/version"
 )
 
 func main() {
+	userID := flag.String("user", "", "only check items belonging to this user ID")
+	itemID := flag.String("item", "", "only check the item with this ID")
+	seed := flag.String("seed", "", "visit items in the order of this seed, as logged by an earlier run's order_seed, to reproduce it")
+	daemon := flag.Bool("daemon", false, "keep running and check all prices every SCHEDULER_INTERVAL (same as SCRAPER_MODE=daemon)")
+	backfill := flag.Bool("backfill", false, "scrape each item without price history once and record its first price, without notifying anyone")
+	flag.Parse()
+
+	if *userID != "" && *itemID != "" {
+		fmt.Fprintln(os.Stderr, "-user and -item are mutually exclusive")
+		os.Exit(2)
+	}
+	if *backfill && (*userID != "" || *itemID != "" || *seed != "" || *daemon) {
+		fmt.Fprintln(os.Stderr, "-backfill cannot be combined with -user, -item, -seed or -daemon")
+		os.Exit(2)
+	}
+
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
@@ -22,13 +47,16 @@ func main() {
 		slog.Warn("No .env file found, relying on system environment variables")
 	}
 
-	connStr := os.Getenv("DATABASE_URL")
-	if connStr == "" {
-		slog.Error("DATABASE_URL environment variable is not set")
+	info := version.Get()
+	slog.Info("Starting PriceTrack scraper", "version", info.Version, "commit", info.Commit, "build_date", info.BuildDate, "go_version", info.GoVersion)
+
+	cfg, err := config.LoadScraper(os.Getenv)
+	if err != nil {
+		slog.Error("Refusing to start", "error", err)
 		os.Exit(1)
 	}
 
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
 	if err != nil {
 		slog.Error("Failed to open database connection", "error", err)
 		os.Exit(1)
@@ -41,17 +69,48 @@ func main() {
 	slog.Info("Connected to database")
 
 	// Initialize Scheduler
-	sch := scheduler.New(db)
-	
+	cfg.Scheduler.Email = email.NewNotifier(db, email.NewSender(cfg.SMTP), []byte(cfg.EmailTokenSecret), cfg.PublicURL, cfg.EmailTemplates)
+	cfg.Scheduler.Realtime = realtime.NewBroadcaster(cfg.Realtime)
+	adapters.Configure(cfg.SiteSelectors)
+	sch := scheduler.New(db, cfg.Scheduler)
+
+	// SIGTERM (or Ctrl-C) cancels the run in progress; the scheduler stops
+	// at the next item and the job exits cleanly.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if *daemon || (cfg.ScraperDaemon && !*backfill) {
+		if *userID != "" || *itemID != "" || *seed != "" {
+			fmt.Fprintln(os.Stderr, "-user, -item and -seed cannot be used in daemon mode")
+			os.Exit(2)
+		}
+		runDaemon(ctx, sch, cfg)
+		sch.Stop()
+		slog.Info("Scraper daemon stopped")
+		return
+	}
+
 	// Create context with timeout for the entire scraping job
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Hour)
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Hour)
 	defer cancel()
+	if *seed != "" {
+		ctx = scheduler.WithOrderSeed(ctx, *seed)
+	}
 
 	// Run scraper once
-	sch.CheckAllPrices(ctx)
-	
+	switch {
+	case *backfill:
+		sch.Backfill(ctx)
+	case *itemID != "":
+		sch.CheckItem(ctx, *itemID)
+	case *userID != "":
+		sch.CheckPricesForUser(ctx, *userID)
+	default:
+		sch.CheckAllPrices(ctx)
+	}
+
 	// Explicitly stop to clean up Playwright resources if any
 	sch.Stop()
-	
+
 	slog.Info("Scraper job finished")
 }
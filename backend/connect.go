@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The typed API of proto/pricetrack/v1 is served over the Connect protocol
+// (https://connectrpc.com/docs/protocol) with its JSON codec. A unary call
+// POSTs its request message as application/json and gets the response
+// message back, or an error with the status its code maps to. A server
+// stream POSTs one enveloped message as application/connect+json and gets
+// enveloped messages back, ending with an end-of-stream envelope that
+// carries the error, if any.
+const (
+	connectUnaryContentType  = "application/json"
+	connectStreamContentType = "application/connect+json"
+	// connectEndStream flags the envelope that ends a stream.
+	connectEndStream = 0x02
+	// connectMaxMessageBytes bounds a request message.
+	connectMaxMessageBytes = 1 << 20
+)
+
+// rpcError is an error with a Connect error code, e.g. "not_found". Service
+// methods return one for anything the caller can act on; other errors are
+// internal.
+type rpcError struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func (e *rpcError) Error() string { return e.Code + ": " + e.Message }
+
+// rpcErrorStatus is the HTTP status of a unary error response, by code.
+var rpcErrorStatus = map[string]int{
+	"canceled":          499,
+	"invalid_argument":  http.StatusBadRequest,
+	"deadline_exceeded": http.StatusGatewayTimeout,
+	"not_found":         http.StatusNotFound,
+	"unimplemented":     http.StatusNotImplemented,
+	"internal":          http.StatusInternalServerError,
+	"unauthenticated":   http.StatusUnauthorized,
+}
+
+// toRPCError returns err as the caller sees it. Errors that are not an
+// rpcError are logged and reported as internal, or as deadline_exceeded
+// when ctx ran out of time.
+func toRPCError(ctx context.Context, procedure string, err error) *rpcError {
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	switch {
+	case isQueryTimeout(ctx, err):
+		return &rpcError{Code: "deadline_exceeded", Message: "Gateway Timeout"}
+	case errors.Is(err, context.Canceled):
+		return &rpcError{Code: "canceled", Message: "Canceled"}
+	}
+	slog.Error("RPC failed", "procedure", procedure, "error", err)
+	return &rpcError{Code: "internal", Message: "Internal Server Error"}
+}
+
+// rpcInterceptor runs before every RPC, as a connect.Interceptor would, and
+// returns the context the call runs with or the error it fails with.
+type rpcInterceptor func(ctx context.Context, header http.Header) (context.Context, error)
+
+// rpcInterceptors run in order before every RPC.
+var rpcInterceptors = []rpcInterceptor{rpcAuthInterceptor}
+
+// rpcAuthInterceptor authenticates a call the way AuthMiddleware does a REST
+// request, from the same Authorization header, and puts the user on its
+// context.
+func rpcAuthInterceptor(ctx context.Context, header http.Header) (context.Context, error) {
+	lookupCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	userID, err := authenticate(lookupCtx, header.Get("Authorization"))
+	var rejected authError
+	if errors.As(err, &rejected) {
+		return ctx, &rpcError{Code: "unauthenticated", Message: string(rejected)}
+	}
+	if err != nil {
+		return ctx, fmt.Errorf("look up API key: %w", err)
+	}
+	return context.WithValue(ctx, userIDKey, userID), nil
+}
+
+// intercept runs rpcInterceptors for r, within the timeout the client asked
+// for with Connect-Timeout-Ms, if any. The cancel function must be called
+// whatever the error.
+func intercept(r *http.Request) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := r.Context(), context.CancelFunc(func() {})
+	if v := r.Header.Get("Connect-Timeout-Ms"); v != "" {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ms <= 0 || len(v) > 10 {
+			return ctx, cancel, &rpcError{Code: "invalid_argument", Message: "Connect-Timeout-Ms must be a positive integer of at most 10 digits"}
+		}
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+	}
+	for _, interceptor := range rpcInterceptors {
+		var err error
+		if ctx, err = interceptor(ctx, r.Header); err != nil {
+			return ctx, cancel, err
+		}
+	}
+	return ctx, cancel, nil
+}
+
+// hasContentType reports whether r's body is of the media type want.
+func hasContentType(r *http.Request, want string) bool {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != want {
+		return false
+	}
+	charset, ok := params["charset"]
+	return !ok || charset == "utf-8" || charset == "UTF-8"
+}
+
+// decodeRPCMessage decodes a JSON request message. An empty body is the
+// empty message.
+func decodeRPCMessage(data []byte, msg any) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return &rpcError{Code: "invalid_argument", Message: "Invalid request message: " + err.Error()}
+	}
+	return nil
+}
+
+// unaryRPC returns the handler of a unary RPC served by fn.
+func unaryRPC[Req, Res any](fn func(ctx context.Context, req *Req) (*Res, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasContentType(r, connectUnaryContentType) {
+			w.Header().Set("Accept-Post", connectUnaryContentType)
+			http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+			return
+		}
+		ctx, cancel, err := intercept(r)
+		defer cancel()
+		if err != nil {
+			writeUnaryError(w, toRPCError(ctx, r.URL.Path, err))
+			return
+		}
+		if enc := r.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+			writeUnaryError(w, &rpcError{Code: "unimplemented", Message: "Content-Encoding " + enc + " is not supported"})
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, connectMaxMessageBytes))
+		if err != nil {
+			writeUnaryError(w, &rpcError{Code: "invalid_argument", Message: "Failed to read request message: " + err.Error()})
+			return
+		}
+		var req Req
+		if err := decodeRPCMessage(data, &req); err != nil {
+			writeUnaryError(w, toRPCError(ctx, r.URL.Path, err))
+			return
+		}
+
+		ctx, cancelQuery := context.WithTimeout(ctx, queryTimeout)
+		defer cancelQuery()
+		res, err := fn(ctx, &req)
+		if err != nil {
+			writeUnaryError(w, toRPCError(ctx, r.URL.Path, err))
+			return
+		}
+		w.Header().Set("Content-Type", connectUnaryContentType)
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// writeUnaryError writes the error response of a unary RPC.
+func writeUnaryError(w http.ResponseWriter, err *rpcError) {
+	status, ok := rpcErrorStatus[err.Code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(err)
+}
+
+// streamRPC returns the handler of a server-streaming RPC served by fn,
+// which passes each message to send and returns when the stream is done.
+// The response is 200 OK once the request message is read, so every error,
+// including a rejected credential, ends the stream instead.
+func streamRPC[Req, Res any](fn func(ctx context.Context, req *Req, send func(*Res) error) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasContentType(r, connectStreamContentType) {
+			w.Header().Set("Accept-Post", connectStreamContentType)
+			http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+			return
+		}
+		// The request message is read first: writing the response closes
+		// the request body.
+		ctx, cancel, err := intercept(r)
+		defer cancel()
+		var req Req
+		if err == nil {
+			err = readEnvelopedMessage(r.Body, &req)
+		}
+
+		w.Header().Set("Content-Type", connectStreamContentType)
+		// Keeps nginx from buffering the messages.
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		rc := http.NewResponseController(w)
+		rc.Flush()
+		if err == nil {
+			err = fn(ctx, &req, func(msg *Res) error {
+				data, err := json.Marshal(msg)
+				if err != nil {
+					return err
+				}
+				if err := writeEnvelope(w, 0, data); err != nil {
+					return err
+				}
+				return rc.Flush()
+			})
+		}
+
+		var end struct {
+			Error *rpcError `json:"error,omitempty"`
+		}
+		if err != nil {
+			end.Error = toRPCError(ctx, r.URL.Path, err)
+		}
+		data, _ := json.Marshal(end)
+		writeEnvelope(w, connectEndStream, data)
+		rc.Flush()
+	}
+}
+
+// readEnvelopedMessage reads the one request message of a server stream.
+func readEnvelopedMessage(body io.Reader, msg any) error {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return &rpcError{Code: "invalid_argument", Message: "Failed to read request envelope: " + err.Error()}
+	}
+	if prefix[0] != 0 {
+		return &rpcError{Code: "unimplemented", Message: "Compressed request messages are not supported"}
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > connectMaxMessageBytes {
+		return &rpcError{Code: "invalid_argument", Message: "Request message too large"}
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(body, data); err != nil {
+		return &rpcError{Code: "invalid_argument", Message: "Failed to read request message: " + err.Error()}
+	}
+	return decodeRPCMessage(data, msg)
+}
+
+// writeEnvelope writes one enveloped message: its flags, its length and the
+// message itself.
+func writeEnvelope(w io.Writer, flags byte, data []byte) error {
+	var prefix [5]byte
+	prefix[0] = flags
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// apiServer serves the REST routes and the Connect procedures together, as
+// main does.
+func apiServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	registerRoutes(mux.HandleFunc)
+	registerConnectRoutes(mux.HandleFunc)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// call makes a request as user token and returns the status and decoded
+// JSON body.
+func call(t *testing.T, ts *httptest.Server, method, path, token, contentType, body string) (int, any) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	var decoded any
+	if len(bytes.TrimSpace(data)) > 0 && json.Unmarshal(data, &decoded) != nil {
+		decoded = string(data)
+	}
+	return resp.StatusCode, decoded
+}
+
+// callRPC calls a unary procedure as user token.
+func callRPC(t *testing.T, ts *httptest.Server, procedure, token, request string) (int, map[string]any) {
+	t.Helper()
+	status, body := call(t, ts, "POST", "/pricetrack.v1."+procedure, token, "application/json", request)
+	msg, ok := body.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected a JSON message from %s, got %d: %v", procedure, status, body)
+	}
+	return status, msg
+}
+
+// protoFields returns the JSON names of the fields of message in
+// pricetrack.proto.
+func protoFields(t *testing.T, message string) []string {
+	t.Helper()
+	data, err := os.ReadFile("proto/pricetrack/v1/pricetrack.proto")
+	if err != nil {
+		t.Fatalf("Failed to read proto: %v", err)
+	}
+	m := regexp.MustCompile(`(?s)\nmessage ` + message + ` \{(.*?)\n\}`).FindSubmatch(data)
+	if m == nil {
+		t.Fatalf("No message %s in the proto", message)
+	}
+	var names []string
+	for _, field := range regexp.MustCompile(`(?m)^\s+(?:optional |repeated )?\w+ (\w+) = \d+;`).FindAllSubmatch(m[1], -1) {
+		parts := strings.Split(string(field[1]), "_")
+		for i := 1; i < len(parts); i++ {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+		names = append(names, strings.Join(parts, ""))
+	}
+	return names
+}
+
+// restKeys maps the proto fields whose REST JSON key differs.
+var restKeys = map[string]string{"xpath": "xPath"}
+
+// expectEquivalent checks that a message the Connect API returned carries
+// the same data as the REST JSON for it: each field of the proto message
+// equals the REST value, and is only left out where REST has an empty one.
+func expectEquivalent(t *testing.T, message string, rest, rpc map[string]any) {
+	t.Helper()
+	fields := protoFields(t, message)
+	for name := range rpc {
+		if !slices.Contains(fields, name) {
+			t.Errorf("%s: %s is not in the proto", message, name)
+		}
+	}
+	for _, name := range fields {
+		key := name
+		if k, ok := restKeys[name]; ok {
+			key = k
+		}
+		restValue, inREST := rest[key]
+		rpcValue, inRPC := rpc[name]
+		switch {
+		case !inRPC && inREST && !reflect.ValueOf(restValue).IsZero() && !reflect.DeepEqual(restValue, []any{}):
+			t.Errorf("%s: %s is %v over REST but left out over Connect", message, name, restValue)
+		case inRPC && !reflect.DeepEqual(restValue, rpcValue):
+			t.Errorf("%s: %s is %v over REST but %v over Connect", message, name, restValue, rpcValue)
+		}
+	}
+}
+
+// shippedItemRow is itemRow with shipping, availability and a notification
+// channel of its own.
+func shippedItemRow(id string) []driver.Value {
+	row := itemRow(id)
+	for column, value := range map[string]driver.Value{
+		"shipping_selector":     ".shipping",
+		"shipping_price":        4.99,
+		"total_price":           24.98,
+		"notification_channel":  "webhook",
+		"availability_selector": ".stock",
+		"availability":          "in_stock",
+		"min_expected":          5.0,
+		"tags":                  "{kitchen}",
+	} {
+		row[slices.Index(itemColumnNames, column)] = value
+	}
+	return row
+}
+
+func TestConnect_ItemsMatchREST(t *testing.T) {
+	mock := setupMockDB(t)
+	ts := apiServer(t)
+	token := liveSocketToken(t, "test-user-id")
+	expectItems := func() {
+		mock.ExpectQuery(`FROM tracked_items\s+WHERE user_id = \$1 AND tags @> \$2\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$3 OFFSET \$4`).
+			WithArgs("test-user-id", `{"kitchen"}`, 2, 1).
+			WillReturnRows(sqlmock.NewRows(itemColumnNames).AddRow(shippedItemRow("item-1")...).AddRow(itemRow("item-2")...))
+	}
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\), MAX\(updated_at\)`).
+		WithArgs("test-user-id", `{"kitchen"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(3, etagUpdatedAt))
+	expectItems()
+	status, restBody := call(t, ts, "GET", "/items?tag=kitchen&limit=2&offset=1", token, "", "")
+	rest, ok := restBody.([]any)
+	if status != http.StatusOK || !ok || len(rest) != 2 {
+		t.Fatalf("Expected two items over REST, got %d: %v", status, restBody)
+	}
+	expectItems()
+	status, res := callRPC(t, ts, "ItemService/ListItems", token, `{"tags":["kitchen"],"limit":2,"offset":1}`)
+	items, _ := res["items"].([]any)
+	if status != http.StatusOK || len(items) != 2 {
+		t.Fatalf("Expected two items over Connect, got %d: %v", status, res)
+	}
+	for i := range items {
+		expectEquivalent(t, "Item", rest[i].(map[string]any), items[i].(map[string]any))
+	}
+	if items[0].(map[string]any)["shippingPrice"] != 4.99 || items[0].(map[string]any)["availability"] != "in_stock" {
+		t.Errorf("Expected the shipping and availability over Connect, got %v", items[0])
+	}
+
+	// One item, by ID.
+	mock.ExpectQuery(`FROM tracked_items LEFT JOIN item_snippets .* WHERE id = \$1 AND user_id = \$2`).
+		WithArgs("item-1", "test-user-id").
+		WillReturnRows(sqlmock.NewRows(itemSnippetColumnNames).AddRow(append(shippedItemRow("item-1"), nil, "")...))
+	status, restBody = call(t, ts, "GET", "/items/item-1", token, "", "")
+	if status != http.StatusOK {
+		t.Fatalf("Expected the item over REST, got %d: %v", status, restBody)
+	}
+	mock.ExpectQuery(`FROM tracked_items\s+WHERE id = \$1 AND user_id = \$2`).
+		WithArgs("item-1", "test-user-id").
+		WillReturnRows(sqlmock.NewRows(itemColumnNames).AddRow(shippedItemRow("item-1")...))
+	status, item := callRPC(t, ts, "ItemService/GetItem", token, `{"id":"item-1"}`)
+	if status != http.StatusOK {
+		t.Fatalf("Expected the item over Connect, got %d: %v", status, item)
+	}
+	expectEquivalent(t, "Item", restBody.(map[string]any), item)
+
+	// Filters are checked as REST checks them.
+	status, restBody = call(t, ts, "GET", "/items?status=gone", token, "", "")
+	status2, res := callRPC(t, ts, "ItemService/ListItems", token, `{"status":"gone"}`)
+	if status != http.StatusBadRequest || status2 != http.StatusBadRequest || res["code"] != "invalid_argument" || res["message"] != strings.TrimSpace(restBody.(string)) {
+		t.Errorf("Expected the REST error as invalid_argument, got %d %v and %d %v", status, restBody, status2, res)
+	}
+
+	mock.ExpectQuery(`FROM tracked_items\s+WHERE id = \$1 AND user_id = \$2`).
+		WithArgs("item-9", "test-user-id").
+		WillReturnRows(sqlmock.NewRows(itemColumnNames))
+	if status, res := callRPC(t, ts, "ItemService/GetItem", token, `{"id":"item-9"}`); status != http.StatusNotFound || res["code"] != "not_found" {
+		t.Errorf("Expected not_found, got %d: %v", status, res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestConnect_CheckItem(t *testing.T) {
+	mock := setupMockDB(t)
+	f := setJobQueue(t)
+	ts := apiServer(t)
+	token := liveSocketToken(t, "test-user-id")
+	expectOwned := func(rows *sqlmock.Rows) {
+		mock.ExpectQuery(`SELECT TRUE FROM tracked_items WHERE id = \$1 AND user_id = \$2`).
+			WithArgs("item-1", "test-user-id").
+			WillReturnRows(rows)
+	}
+
+	expectOwned(sqlmock.NewRows([]string{"bool"}).AddRow(true))
+	status, rest := call(t, ts, "POST", "/items/item-1/check", token, "", "")
+	if status != http.StatusAccepted {
+		t.Fatalf("Expected the check queued over REST, got %d: %v", status, rest)
+	}
+	expectOwned(sqlmock.NewRows([]string{"bool"}).AddRow(true))
+	status, res := callRPC(t, ts, "ItemService/CheckItem", token, `{"id":"item-1"}`)
+	// jobId is an int64, a string in protobuf JSON.
+	if status != http.StatusOK || res["jobId"] != "42" || rest.(map[string]any)["jobId"] != 42.0 {
+		t.Errorf("Expected job 42 both ways, got %v and %d: %v", rest, status, res)
+	}
+	if len(f.items) != 2 || f.reasons[1] != f.reasons[0] {
+		t.Errorf("Expected the same manual check queued twice, got %v %v", f.reasons, f.items)
+	}
+
+	expectOwned(sqlmock.NewRows([]string{"bool"}))
+	if status, res := callRPC(t, ts, "ItemService/CheckItem", token, `{"id":"item-1"}`); status != http.StatusNotFound || res["code"] != "not_found" {
+		t.Errorf("Expected not_found, got %d: %v", status, res)
+	}
+	if status, res := callRPC(t, ts, "ItemService/CheckItem", token, `{}`); status != http.StatusBadRequest || res["code"] != "invalid_argument" {
+		t.Errorf("Expected invalid_argument without an ID, got %d: %v", status, res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestConnect_PriceHistoryMatchesSharedView(t *testing.T) {
+	mock := setupMockDB(t)
+	ts := apiServer(t)
+	token := liveSocketToken(t, "test-user-id")
+
+	expectSharedItem(mock, "tok")
+	status, shared := call(t, ts, "GET", "/shared/tok", "", "", "")
+	if status != http.StatusOK {
+		t.Fatalf("Expected the shared view, got %d: %v", status, shared)
+	}
+	mock.ExpectQuery(`SELECT TRUE FROM tracked_items WHERE id = \$1 AND user_id = \$2`).
+		WithArgs("item-1", "test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"bool"}).AddRow(true))
+	mock.ExpectQuery("FROM item_price_history").
+		WithArgs("item-1", sharedHistoryDays).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "min", "max", "close"}).
+			AddRow("2025-01-01", 19.99, 19.99, 19.99).
+			AddRow("2025-01-02", 15.0, 17.5, 15.0))
+	status, res := callRPC(t, ts, "HistoryService/GetPriceHistory", token, `{"itemId":"item-1"}`)
+	points, _ := res["points"].([]any)
+	history := shared.(map[string]any)["history"].([]any)
+	if status != http.StatusOK || len(points) != len(history) {
+		t.Fatalf("Expected %d points, got %d: %v", len(history), status, res)
+	}
+	for i := range points {
+		expectEquivalent(t, "PricePoint", history[i].(map[string]any), points[i].(map[string]any))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestConnect_NotificationsMatchREST(t *testing.T) {
+	mock := setupMockDB(t)
+	ts := apiServer(t)
+	token := liveSocketToken(t, "test-user-id")
+	expectNotifications := func() {
+		mock.ExpectQuery(`WHERE user_id = \$1 AND is_read = false AND severity = ANY\(\$2\)\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$3 OFFSET \$4`).
+			WithArgs("test-user-id", pq.Array([]string{"notice", "alert"}), 10, 0).
+			WillReturnRows(sqlmock.NewRows(strings.Split(strings.ReplaceAll(notificationColumns, " ", ""), ",")).
+				AddRow("n-1", "test-user-id", "Price Drop", "Kettle is now $19.99", "price_drop", "item-1", "$24.00", "$19.99", false, time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC), nil, "notice").
+				AddRow("n-2", "test-user-id", "Selector broken", "Kettle", "selector_broken", nil, nil, nil, false, time.Date(2025, 6, 7, 12, 0, 0, 0, time.UTC), nil, "alert"))
+	}
+
+	expectNotifications()
+	status, restBody := call(t, ts, "GET", "/notifications?severity=notice,alert&limit=10", token, "", "")
+	rest, ok := restBody.([]any)
+	if status != http.StatusOK || !ok || len(rest) != 2 {
+		t.Fatalf("Expected two notifications over REST, got %d: %v", status, restBody)
+	}
+	expectNotifications()
+	status, res := callRPC(t, ts, "NotificationService/ListNotifications", token, `{"severities":["notice","alert"],"limit":10}`)
+	notifications, _ := res["notifications"].([]any)
+	if status != http.StatusOK || len(notifications) != 2 {
+		t.Fatalf("Expected two notifications over Connect, got %d: %v", status, res)
+	}
+	for i := range notifications {
+		expectEquivalent(t, "Notification", rest[i].(map[string]any), notifications[i].(map[string]any))
+	}
+
+	if status, res := callRPC(t, ts, "NotificationService/ListNotifications", token, `{"offset":5}`); status != http.StatusBadRequest || res["message"] != "offset requires limit" {
+		t.Errorf("Expected the REST pagination error, got %d: %v", status, res)
+	}
+
+	for range 2 {
+		mock.ExpectExec("UPDATE notifications").
+			WithArgs("n-1", "test-user-id").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	if status, _ := call(t, ts, "PATCH", "/notifications/n-1/read", token, "", ""); status != http.StatusNoContent {
+		t.Errorf("Expected the notification marked read over REST, got %d", status)
+	}
+	if status, res := callRPC(t, ts, "NotificationService/MarkRead", token, `{"id":"n-1"}`); status != http.StatusOK || len(res) != 0 {
+		t.Errorf("Expected an empty response, got %d: %v", status, res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestConnect_Protocol(t *testing.T) {
+	setupMockDB(t)
+	ts := apiServer(t)
+	token := liveSocketToken(t, "test-user-id")
+
+	// The Authorization header is checked as REST checks it.
+	if status, res := callRPC(t, ts, "ItemService/ListItems", "", `{}`); status != http.StatusUnauthorized || res["code"] != "unauthenticated" || res["message"] != "Missing Authorization header" {
+		t.Errorf("Expected unauthenticated, got %d: %v", status, res)
+	}
+	if status, res := callRPC(t, ts, "ItemService/ListItems", "not-a-token", `{}`); status != http.StatusUnauthorized || res["message"] != "Invalid token" {
+		t.Errorf("Expected an invalid token, got %d: %v", status, res)
+	}
+	if status, res := callRPC(t, ts, "ItemService/GetItem", token, `{"id":`); status != http.StatusBadRequest || res["code"] != "invalid_argument" {
+		t.Errorf("Expected invalid_argument for a malformed message, got %d: %v", status, res)
+	}
+
+	// Only the JSON codec is served, and only over POST.
+	if status, _ := call(t, ts, "POST", "/pricetrack.v1.ItemService/GetItem", token, "application/proto", ""); status != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status %d for the binary codec, got %d", http.StatusUnsupportedMediaType, status)
+	}
+	if status, _ := call(t, ts, "GET", "/pricetrack.v1.ItemService/GetItem", token, "", ""); status != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for GET, got %d", http.StatusMethodNotAllowed, status)
+	}
+}
+
+// openRPCStream calls StreamNotifications as token and returns the response
+// body, positioned at the first message.
+func openRPCStream(t *testing.T, ts *httptest.Server, token string) io.Reader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	var body bytes.Buffer
+	writeEnvelope(&body, 0, []byte(`{}`))
+	req, err := http.NewRequestWithContext(ctx, "POST", ts.URL+"/pricetrack.v1.NotificationService/StreamNotifications", &body)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/connect+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/connect+json" {
+		t.Fatalf("Expected a Connect stream, got status %d and %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return resp.Body
+}
+
+// readRPCEnvelope reads the next enveloped message of a stream.
+func readRPCEnvelope(t *testing.T, r io.Reader) (byte, map[string]any) {
+	t.Helper()
+	type envelope struct {
+		flags byte
+		data  []byte
+		err   error
+	}
+	done := make(chan envelope, 1)
+	go func() {
+		var prefix [5]byte
+		if _, err := io.ReadFull(r, prefix[:]); err != nil {
+			done <- envelope{err: err}
+			return
+		}
+		data := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+		_, err := io.ReadFull(r, data)
+		done <- envelope{prefix[0], data, err}
+	}()
+
+	select {
+	case e := <-done:
+		if e.err != nil {
+			t.Fatalf("Failed to read envelope: %v", e.err)
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(e.data, &msg); err != nil {
+			t.Fatalf("Failed to decode %q: %v", e.data, err)
+		}
+		return e.flags, msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a message")
+		return 0, nil
+	}
+}
+
+func TestConnect_StreamNotifications(t *testing.T) {
+	mock := setupMockDB(t)
+	hub := newNotificationHub()
+	hub.setListening(true)
+	setNotificationStreams(t, hub)
+	ts := apiServer(t)
+	token := liveSocketToken(t, "user-1")
+
+	opened := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+	first := opened.Add(time.Second)
+	mock.ExpectQuery(`SELECT NOW\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"now"}).AddRow(opened))
+	mock.ExpectQuery("FROM notifications").
+		WithArgs("user-1", opened, notificationStreamBatch).
+		WillReturnRows(sqlmock.NewRows(notificationStreamColumns).AddRow(notificationStreamRow("n-1", first)...))
+
+	r := openRPCStream(t, ts, token)
+	// The stream subscribes before it answers, so wait for its query.
+	deadline := time.Now().Add(5 * time.Second)
+	for mock.ExpectationsWereMet() != nil && time.Now().Before(deadline) {
+		hub.dispatch(&pq.Notification{Channel: notificationInsertsChannel, Extra: "user-1"})
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	flags, msg := readRPCEnvelope(t, r)
+	if flags != 0 || msg["id"] != "n-1" || msg["newPrice"] != "$19.99" || msg["severity"] != "notice" {
+		t.Errorf("Expected n-1 to be streamed, got flags %d: %v", flags, msg)
+	}
+	if _, ok := msg["userId"]; ok {
+		t.Errorf("Expected only the proto's fields, got %v", msg)
+	}
+}
+
+func TestConnect_StreamRejectsCredentials(t *testing.T) {
+	setupMockDB(t)
+	ts := apiServer(t)
+
+	r := openRPCStream(t, ts, "not-a-token")
+	flags, end := readRPCEnvelope(t, r)
+	rpcErr, _ := end["error"].(map[string]any)
+	if flags != connectEndStream || rpcErr["code"] != "unauthenticated" {
+		t.Errorf("Expected the stream to end unauthenticated, got flags %d: %v", flags, end)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// registerConnectRoutes registers the procedures of
+// proto/pricetrack/v1/pricetrack.proto with handle, next to the REST routes
+// registerRoutes registers.
+func registerConnectRoutes(handle func(pattern string, handler func(http.ResponseWriter, *http.Request))) {
+	items, history, notifications := itemService{}, historyService{}, notificationService{}
+	procedures := map[string]http.HandlerFunc{
+		"/pricetrack.v1.ItemService/ListItems":                   unaryRPC(items.ListItems),
+		"/pricetrack.v1.ItemService/GetItem":                     unaryRPC(items.GetItem),
+		"/pricetrack.v1.ItemService/CheckItem":                   unaryRPC(items.CheckItem),
+		"/pricetrack.v1.HistoryService/GetPriceHistory":          unaryRPC(history.GetPriceHistory),
+		"/pricetrack.v1.NotificationService/ListNotifications":   unaryRPC(notifications.ListNotifications),
+		"/pricetrack.v1.NotificationService/MarkRead":            unaryRPC(notifications.MarkRead),
+		"/pricetrack.v1.NotificationService/StreamNotifications": streamRPC(notifications.StreamNotifications),
+	}
+	for path, procedure := range procedures {
+		handle(path, Chain(methods{"POST": procedure}.ServeHTTP, LoggingMiddleware))
+	}
+}
+
+// rpcItem is pricetrack.v1.Item, the fields of TrackedItem the typed API
+// carries. Like every message it is encoded as protobuf JSON, which leaves
+// out empty fields.
+type rpcItem struct {
+	ID                   string   `json:"id,omitempty"`
+	PriceText            string   `json:"priceText,omitempty"`
+	PriceNormalized      string   `json:"priceNormalized,omitempty"`
+	ProductName          string   `json:"productName,omitempty"`
+	ImageURL             string   `json:"imageUrl,omitempty"`
+	ImageURLs            []string `json:"imageUrls,omitempty"`
+	Tags                 []string `json:"tags,omitempty"`
+	CSSSelector          string   `json:"cssSelector,omitempty"`
+	XPath                string   `json:"xpath,omitempty"`
+	PageURL              string   `json:"pageUrl,omitempty"`
+	FinalURL             string   `json:"finalUrl,omitempty"`
+	CapturedAtISO        string   `json:"capturedAtIso,omitempty"`
+	SavedAtISO           string   `json:"savedAtIso,omitempty"`
+	LastScrapeStatus     string   `json:"lastScrapeStatus,omitempty"`
+	MinExpected          *float64 `json:"minExpected,omitempty"`
+	MaxExpected          *float64 `json:"maxExpected,omitempty"`
+	PausedAt             *string  `json:"pausedAt,omitempty"`
+	PauseReason          *string  `json:"pauseReason,omitempty"`
+	ParseStrategy        string   `json:"parseStrategy,omitempty"`
+	AdapterOrder         string   `json:"adapterOrder,omitempty"`
+	SelectorMatch        string   `json:"selectorMatch,omitempty"`
+	AcceptLanguage       string   `json:"acceptLanguage,omitempty"`
+	CountryCode          string   `json:"countryCode,omitempty"`
+	ObservedPrice        *float64 `json:"observedPrice,omitempty"`
+	PriceFirstSeenAt     *string  `json:"priceFirstSeenAt,omitempty"`
+	PriceLastChangedAt   *string  `json:"priceLastChangedAt,omitempty"`
+	ShippingSelector     string   `json:"shippingSelector,omitempty"`
+	ShippingPrice        *float64 `json:"shippingPrice,omitempty"`
+	TotalPrice           *float64 `json:"totalPrice,omitempty"`
+	NotificationChannel  string   `json:"notificationChannel,omitempty"`
+	AvailabilitySelector string   `json:"availabilitySelector,omitempty"`
+	Availability         string   `json:"availability,omitempty"`
+}
+
+func newRPCItem(i TrackedItem) *rpcItem {
+	return &rpcItem{
+		ID:                   i.ID,
+		PriceText:            i.PriceText,
+		PriceNormalized:      i.PriceNormalized,
+		ProductName:          i.ProductName,
+		ImageURL:             i.ImageURL,
+		ImageURLs:            i.ImageURLs,
+		Tags:                 i.Tags,
+		CSSSelector:          i.CSSSelector,
+		XPath:                i.XPath,
+		PageURL:              i.PageURL,
+		FinalURL:             i.FinalURL,
+		CapturedAtISO:        i.CapturedAtISO,
+		SavedAtISO:           i.SavedAtISO,
+		LastScrapeStatus:     i.LastScrapeStatus,
+		MinExpected:          i.MinExpected,
+		MaxExpected:          i.MaxExpected,
+		PausedAt:             i.PausedAt,
+		PauseReason:          i.PauseReason,
+		ParseStrategy:        i.ParseStrategy,
+		AdapterOrder:         i.AdapterOrder,
+		SelectorMatch:        i.SelectorMatch,
+		AcceptLanguage:       i.AcceptLanguage,
+		CountryCode:          i.CountryCode,
+		ObservedPrice:        i.ObservedPrice,
+		PriceFirstSeenAt:     i.PriceFirstSeenAt,
+		PriceLastChangedAt:   i.PriceLastChangedAt,
+		ShippingSelector:     i.ShippingSelector,
+		ShippingPrice:        i.ShippingPrice,
+		TotalPrice:           i.TotalPrice,
+		NotificationChannel:  i.NotificationChannel,
+		AvailabilitySelector: i.AvailabilitySelector,
+		Availability:         i.Availability,
+	}
+}
+
+// rpcNotification is pricetrack.v1.Notification.
+type rpcNotification struct {
+	ID        string  `json:"id,omitempty"`
+	Title     string  `json:"title,omitempty"`
+	Message   string  `json:"message,omitempty"`
+	Type      string  `json:"type,omitempty"`
+	Severity  string  `json:"severity,omitempty"`
+	ProductID *string `json:"productId,omitempty"`
+	OldPrice  *string `json:"oldPrice,omitempty"`
+	NewPrice  *string `json:"newPrice,omitempty"`
+	IsRead    bool    `json:"isRead,omitempty"`
+	CreatedAt string  `json:"createdAt,omitempty"`
+	ReadAt    *string `json:"readAt,omitempty"`
+}
+
+func newRPCNotification(n Notification) *rpcNotification {
+	return &rpcNotification{
+		ID:        n.ID,
+		Title:     n.Title,
+		Message:   n.Message,
+		Type:      n.Type,
+		Severity:  n.Severity,
+		ProductID: n.ProductID,
+		OldPrice:  n.OldPrice,
+		NewPrice:  n.NewPrice,
+		IsRead:    n.IsRead,
+		CreatedAt: n.CreatedAt,
+		ReadAt:    n.ReadAt,
+	}
+}
+
+// paginationQuery returns the limit and offset query parameters of a list
+// request, for parsePagination.
+func paginationQuery(limit, offset int32) url.Values {
+	q := url.Values{}
+	if limit != 0 {
+		q.Set("limit", strconv.Itoa(int(limit)))
+	}
+	if offset != 0 {
+		q.Set("offset", strconv.Itoa(int(offset)))
+	}
+	return q
+}
+
+// requireID returns an invalid_argument error if the request field name,
+// an ID, is empty.
+func requireID(name, id string) error {
+	if id == "" {
+		return &rpcError{Code: "invalid_argument", Message: name + " is required"}
+	}
+	return nil
+}
+
+// itemService serves pricetrack.v1.ItemService.
+type itemService struct{}
+
+type listItemsRequest struct {
+	Status  string   `json:"status"`
+	Tags    []string `json:"tags"`
+	PageURL string   `json:"pageUrl"`
+	Changed string   `json:"changed"`
+	Sort    string   `json:"sort"`
+	Limit   int32    `json:"limit"`
+	Offset  int32    `json:"offset"`
+}
+
+// query returns the GET /items query parameters the request stands for.
+func (m *listItemsRequest) query() url.Values {
+	q := paginationQuery(m.Limit, m.Offset)
+	for name, v := range map[string]string{"status": m.Status, "pageUrl": m.PageURL, "changed": m.Changed, "sort": m.Sort} {
+		if v != "" {
+			q.Set(name, v)
+		}
+	}
+	for _, tag := range m.Tags {
+		q.Add("tag", tag)
+	}
+	return q
+}
+
+type listItemsResponse struct {
+	Items []*rpcItem `json:"items,omitempty"`
+}
+
+// ListItems returns the caller's items as GET /items does for the same
+// filters.
+func (itemService) ListItems(ctx context.Context, req *listItemsRequest) (*listItemsResponse, error) {
+	q, err := parseItemQuery(req.query(), ctx.Value(userIDKey).(string))
+	if err != nil {
+		return nil, &rpcError{Code: "invalid_argument", Message: err.Error()}
+	}
+	rows, err := queryItems(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := &listItemsResponse{}
+	for rows.Next() {
+		item, err := q.fields.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan item: %w", err)
+		}
+		res.Items = append(res.Items, newRPCItem(item))
+	}
+	return res, rows.Err()
+}
+
+type getItemRequest struct {
+	ID string `json:"id"`
+}
+
+// GetItem returns one of the caller's items as GET /items/{id} does.
+func (itemService) GetItem(ctx context.Context, req *getItemRequest) (*rpcItem, error) {
+	if err := requireID("id", req.ID); err != nil {
+		return nil, err
+	}
+	item, err := loadItem(ctx, ctx.Value(userIDKey).(string), req.ID, defaultItemFields)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &rpcError{Code: "not_found", Message: "Item not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newRPCItem(item), nil
+}
+
+type checkItemRequest struct {
+	ID string `json:"id"`
+}
+
+type checkItemResponse struct {
+	// JobID is an int64, which protobuf JSON encodes as a string.
+	JobID int64 `json:"jobId,omitempty,string"`
+}
+
+// CheckItem queues a check of one of the caller's items as POST
+// /items/{id}/check does.
+func (itemService) CheckItem(ctx context.Context, req *checkItemRequest) (*checkItemResponse, error) {
+	if err := requireID("id", req.ID); err != nil {
+		return nil, err
+	}
+	job, err := queueItemCheck(ctx, ctx.Value(userIDKey).(string), req.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &rpcError{Code: "not_found", Message: "Item not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &checkItemResponse{JobID: job}, nil
+}
+
+// historyService serves pricetrack.v1.HistoryService.
+type historyService struct{}
+
+type getPriceHistoryRequest struct {
+	ItemID string `json:"itemId"`
+}
+
+type getPriceHistoryResponse struct {
+	Points []SharedPricePoint `json:"points,omitempty"`
+}
+
+// GetPriceHistory returns the daily prices of one of the caller's items, the
+// history a shared view of it shows.
+func (historyService) GetPriceHistory(ctx context.Context, req *getPriceHistoryRequest) (*getPriceHistoryResponse, error) {
+	if err := requireID("itemId", req.ItemID); err != nil {
+		return nil, err
+	}
+	err := ownItem(ctx, ctx.Value(userIDKey).(string), req.ItemID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &rpcError{Code: "not_found", Message: "Item not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	points, err := dailyPriceHistory(ctx, req.ItemID)
+	if err != nil {
+		return nil, err
+	}
+	return &getPriceHistoryResponse{Points: points}, nil
+}
+
+// notificationService serves pricetrack.v1.NotificationService.
+type notificationService struct{}
+
+type listNotificationsRequest struct {
+	Severities []string `json:"severities"`
+	Limit      int32    `json:"limit"`
+	Offset     int32    `json:"offset"`
+}
+
+type listNotificationsResponse struct {
+	Notifications []*rpcNotification `json:"notifications,omitempty"`
+}
+
+// ListNotifications returns the caller's unread notifications as GET
+// /notifications does for the same filters.
+func (notificationService) ListNotifications(ctx context.Context, req *listNotificationsRequest) (*listNotificationsResponse, error) {
+	limit, offset, err := parsePagination(paginationQuery(req.Limit, req.Offset))
+	if err != nil {
+		return nil, &rpcError{Code: "invalid_argument", Message: err.Error()}
+	}
+	severities, err := parseSeverities(strings.Join(req.Severities, ","))
+	if err != nil {
+		return nil, &rpcError{Code: "invalid_argument", Message: err.Error()}
+	}
+	where, args := unreadNotificationsWhere(ctx.Value(userIDKey).(string), severities)
+	notifications, err := queryNotifications(ctx, where, args, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	res := &listNotificationsResponse{}
+	for _, n := range notifications {
+		res.Notifications = append(res.Notifications, newRPCNotification(n))
+	}
+	return res, nil
+}
+
+type markReadRequest struct {
+	ID string `json:"id"`
+}
+
+type markReadResponse struct{}
+
+// MarkRead marks one of the caller's notifications read as PATCH
+// /notifications/{id}/read does.
+func (notificationService) MarkRead(ctx context.Context, req *markReadRequest) (*markReadResponse, error) {
+	if err := requireID("id", req.ID); err != nil {
+		return nil, err
+	}
+	if err := markNotificationRead(ctx, ctx.Value(userIDKey).(string), req.ID); err != nil {
+		return nil, err
+	}
+	return &markReadResponse{}, nil
+}
+
+type streamNotificationsRequest struct{}
+
+// StreamNotifications sends the caller's notifications as they are created,
+// as GET /notifications/stream does, until the caller goes away or its
+// timeout ends the stream. Only notifications created after the stream
+// opened are sent.
+func (notificationService) StreamNotifications(ctx context.Context, _ *streamNotificationsRequest, send func(*rpcNotification) error) error {
+	userID := ctx.Value(userIDKey).(string)
+
+	// Subscribe first so nothing created from here on is missed.
+	wake, unsubscribe := notificationStreams.subscribe(notificationInsertsChannel, userID)
+	defer unsubscribe()
+
+	stream := notificationStream{userID: userID, sent: map[string]bool{}}
+	openCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+	err := db.QueryRowContext(openCtx, `SELECT NOW()`).Scan(&stream.since)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	poll := time.NewTicker(notificationStreamPollInterval)
+	defer poll.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-poll.C:
+			if notificationStreams.isListening() {
+				continue
+			}
+		case <-wake:
+		}
+		err := stream.send(ctx, func(n Notification) error {
+			return send(newRPCNotification(n))
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// The next wake-up tries again.
+			slog.Error("Failed to send notifications", "user_id", userID, "error", err)
+		}
+	}
+}
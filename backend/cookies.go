@@ -0,0 +1,53 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"price-track-backend/internal/scheduler"
+	"price-track-backend/internal/secretbox"
+)
+
+// maxItemCookies bounds the cookies stored with one item.
+const maxItemCookies = 20
+
+// cookieBox encrypts item cookies (COOKIE_ENCRYPTION_KEY). It is set from the
+// configuration in main; nil means items cannot have cookies.
+var cookieBox *secretbox.Box
+
+// sealItemCookies validates the cookies for an item on pageURL and encrypts
+// them for storage; no cookies store NULL. Cookie values are never returned
+// by the API.
+func sealItemCookies(pageURL string, cookies []scheduler.Cookie) (sql.Null[[]byte], error) {
+	var sealed sql.Null[[]byte]
+	if len(cookies) == 0 {
+		return sealed, nil
+	}
+	if cookieBox == nil {
+		return sealed, fmt.Errorf("cookies are not enabled on this server")
+	}
+	if len(cookies) > maxItemCookies {
+		return sealed, fmt.Errorf("at most %d cookies are allowed", maxItemCookies)
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return sealed, fmt.Errorf("invalid pageUrl")
+	}
+	host := strings.ToLower(u.Hostname())
+	for i, c := range cookies {
+		c.Domain = strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+		if err := (&http.Cookie{Name: c.Name, Value: c.Value, Domain: c.Domain}).Valid(); err != nil {
+			return sealed, fmt.Errorf("cookies[%d]: %v", i, err)
+		}
+		if c.Domain != "" && host != c.Domain && !strings.HasSuffix(host, "."+c.Domain) {
+			return sealed, fmt.Errorf("cookies[%d]: domain %s does not match the page's host %s", i, c.Domain, host)
+		}
+		cookies[i] = c
+	}
+	sealed.V, err = scheduler.SealCookies(cookieBox, cookies)
+	sealed.Valid = err == nil
+	return sealed, err
+}
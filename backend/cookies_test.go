@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/scheduler"
+	"price-track-backend/internal/secretbox"
+)
+
+// setCookieBox enables item cookies for the duration of the test.
+func setCookieBox(t *testing.T) {
+	t.Helper()
+	box, err := secretbox.New(bytes.Repeat([]byte{7}, secretbox.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := cookieBox
+	cookieBox = box
+	t.Cleanup(func() { cookieBox = old })
+}
+
+// sealedCookies matches a cookies_encrypted argument that decrypts to want
+// and does not contain any cookie value in the clear.
+type sealedCookies []scheduler.Cookie
+
+func (want sealedCookies) Match(v driver.Value) bool {
+	b, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	for _, c := range want {
+		if bytes.Contains(b, []byte(c.Value)) {
+			return false
+		}
+	}
+	got, err := scheduler.OpenCookies(cookieBox, b)
+	if err != nil || len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestItemsHandler_PostSealsCookies(t *testing.T) {
+	mock := setupMockDB(t)
+	setCookieBox(t)
+
+	expectItemsQuota(mock, "test-user-id", nil, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tracked_items").
+		WithArgs("item-1", "$19.99", "Widget", "", ".price", "", "https://shop.example.com/p/1", sqlmock.AnyArg(), sqlmock.AnyArg(), "test-user-id", nil, nil, "{}", "https://shop.example.com/p/1", "auto", 19.99, "en-US",
+			sealedCookies{{Name: "session", Value: "s3cret-session", Domain: "example.com"}}, nil, "", "", "", "", "", "{}", "", "", "19.99 USD", "first", nil, "first", "", "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	body := `{"id":"item-1","priceText":"$19.99","productName":"Widget","cssSelector":".price","pageUrl":"https://shop.example.com/p/1",` +
+		`"capturedAtIso":"2025-01-01T00:00:00Z","savedAtIso":"2025-01-01T00:00:00Z",` +
+		`"cookies":[{"name":"session","value":"s3cret-session","domain":".Example.com"}]}`
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemsHandler(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "s3cret-session") {
+		t.Errorf("Expected the response not to echo cookie values, got %s", w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemsHandler_PostCookiesDisabled(t *testing.T) {
+	setupMockDB(t)
+
+	body := `{"id":"item-1","priceText":"$19.99","cssSelector":".price","pageUrl":"https://shop.example.com/p/1",` +
+		`"capturedAtIso":"2025-01-01T00:00:00Z","savedAtIso":"2025-01-01T00:00:00Z",` +
+		`"cookies":[{"name":"session","value":"abc"}]}`
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemsHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestItemHandler_PatchCookies(t *testing.T) {
+	mock := setupMockDB(t)
+	setCookieBox(t)
+
+	mock.ExpectQuery("SELECT page_url FROM tracked_items").
+		WithArgs("item-1", "test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"page_url"}).AddRow("https://shop.example.com/p/1"))
+	mock.ExpectExec(`SET cookies_encrypted = \$3, last_interacted_at = NOW\(\)`).
+		WithArgs("item-1", "test-user-id", sealedCookies{{Name: "consent", Value: "yes"}}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"cookies":[{"name":"consent","value":"yes"}]}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemHandler_PatchClearsCookies(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("SELECT page_url FROM tracked_items").
+		WithArgs("item-1", "test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"page_url"}).AddRow("https://shop.example.com/p/1"))
+	mock.ExpectExec(`SET cookies_encrypted = \$3`).
+		WithArgs("item-1", "test-user-id", nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"cookies":[]}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemHandler_PatchCookiesDomainMismatch(t *testing.T) {
+	mock := setupMockDB(t)
+	setCookieBox(t)
+
+	mock.ExpectQuery("SELECT page_url FROM tracked_items").
+		WithArgs("item-1", "test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"page_url"}).AddRow("https://shop.example.com/p/1"))
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"cookies":[{"name":"session","value":"abc","domain":"other.com"}]}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
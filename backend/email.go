@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"price-track-backend/internal/email"
+)
+
+var (
+	// emailSender sends verification messages. It is set from the
+	// configuration in main; nil means email is off.
+	emailSender email.Sender
+	// emailTokenSecret signs verification and unsubscribe links, and check
+	// hook tokens (EMAIL_TOKEN_SECRET).
+	emailTokenSecret []byte
+	// publicURL is where the API is reachable from a mail client
+	// (PUBLIC_URL).
+	publicURL string
+)
+
+// NotificationEmail is the address email notifications go to. VerifiedAt is
+// null until the user follows the link sent to it.
+type NotificationEmail struct {
+	Email      string  `json:"email"`
+	VerifiedAt *string `json:"verifiedAt"`
+}
+
+// settingsEmailHandler serves /settings/email.
+var settingsEmailHandler = methods{
+	"GET": getSettingsEmailHandler,
+	"PUT": putSettingsEmailHandler,
+}.ServeHTTP
+
+// settingsEmailVerificationHandler serves /settings/email/verification.
+var settingsEmailVerificationHandler = methods{"POST": postSettingsEmailVerificationHandler}.ServeHTTP
+
+// settingsEmailVerifyHandler serves /settings/email/verify. It is opened from
+// the verification email, so the token stands in for authentication.
+var settingsEmailVerifyHandler = methods{"GET": getSettingsEmailVerifyHandler}.ServeHTTP
+
+// loadNotificationEmail returns userID's notification email, or
+// email.ErrNoAddress.
+func loadNotificationEmail(ctx context.Context, userID string) (NotificationEmail, error) {
+	var addr sql.NullString
+	var verifiedAt sql.NullTime
+	err := db.QueryRowContext(ctx, `
+		SELECT notification_email, notification_email_verified_at FROM user_settings WHERE user_id = $1
+	`, userID).Scan(&addr, &verifiedAt)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !addr.Valid) {
+		return NotificationEmail{}, email.ErrNoAddress
+	}
+	if err != nil {
+		return NotificationEmail{}, err
+	}
+	return notificationEmail(addr.String, verifiedAt), nil
+}
+
+func notificationEmail(addr string, verifiedAt sql.NullTime) NotificationEmail {
+	e := NotificationEmail{Email: addr}
+	if verifiedAt.Valid {
+		v := verifiedAt.Time.Format(time.RFC3339)
+		e.VerifiedAt = &v
+	}
+	return e
+}
+
+// sendVerificationEmail mails addr a link that verifies it for userID.
+func sendVerificationEmail(ctx context.Context, userID, addr string) error {
+	token := email.SignToken(emailTokenSecret, email.Claims{
+		UserID:    userID,
+		Email:     addr,
+		ExpiresAt: time.Now().Add(email.TokenTTL),
+	})
+	link := strings.TrimSuffix(publicURL, "/") + "/settings/email/verify?" + url.Values{"token": {token}}.Encode()
+
+	return emailSender.Send(ctx, email.Message{
+		To:      addr,
+		Subject: "Verify your PriceTrack notification email",
+		Body:    "Confirm that PriceTrack may send notifications to this address by opening the link below. It expires in 24 hours.\n\n" + link,
+	})
+}
+
+func getSettingsEmailHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	e, err := loadNotificationEmail(ctx, userID)
+	if errors.Is(err, email.ErrNoAddress) {
+		http.Error(w, "No notification email set", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to load notification email", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+// putSettingsEmailHandler sets the notification email. A new address starts
+// out unverified and is sent a verification link; setting the current
+// address again keeps its verification.
+func putSettingsEmailHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body NotificationEmail
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if addr, err := mail.ParseAddress(body.Email); err != nil || addr.Address != body.Email {
+		http.Error(w, "email must be a plain email address", http.StatusBadRequest)
+		return
+	}
+	if emailSender == nil {
+		http.Error(w, "Email is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	var verifiedAt sql.NullTime
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO user_settings (user_id, notification_email)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET
+			notification_email_verified_at = CASE
+				WHEN user_settings.notification_email = EXCLUDED.notification_email
+				THEN user_settings.notification_email_verified_at
+			END,
+			notification_email = EXCLUDED.notification_email,
+			updated_at = NOW()
+		RETURNING notification_email_verified_at
+	`, userID, body.Email).Scan(&verifiedAt)
+	if err != nil {
+		slog.Error("Failed to save notification email", "error", err)
+		queryError(ctx, w, err, "Failed to save email", http.StatusInternalServerError)
+		return
+	}
+
+	if !verifiedAt.Valid {
+		if err := sendVerificationEmail(ctx, userID, body.Email); err != nil {
+			slog.Error("Failed to send verification email", "error", err)
+			http.Error(w, "Failed to send verification email", http.StatusBadGateway)
+			return
+		}
+	}
+
+	slog.Info("Saved notification email", "user_id", userID, "verified", verifiedAt.Valid)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notificationEmail(body.Email, verifiedAt))
+}
+
+// postSettingsEmailVerificationHandler sends another verification link for
+// the current, still unverified address.
+func postSettingsEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if emailSender == nil {
+		http.Error(w, "Email is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	e, err := loadNotificationEmail(ctx, userID)
+	if errors.Is(err, email.ErrNoAddress) {
+		http.Error(w, "No notification email set", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to load notification email", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if e.VerifiedAt != nil {
+		http.Error(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+
+	if err := sendVerificationEmail(ctx, userID, e.Email); err != nil {
+		slog.Error("Failed to send verification email", "error", err)
+		http.Error(w, "Failed to send verification email", http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// getSettingsEmailVerifyHandler marks the address in the token verified, as
+// long as it is still the user's notification email.
+func getSettingsEmailVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := email.VerifyToken(emailTokenSecret, r.URL.Query().Get("token"), time.Now())
+	if errors.Is(err, email.ErrTokenExpired) {
+		http.Error(w, "This verification link has expired; request a new one", http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid verification link", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE user_settings
+		SET notification_email_verified_at = COALESCE(notification_email_verified_at, NOW()), updated_at = NOW()
+		WHERE user_id = $1 AND notification_email = $2
+	`, claims.UserID, claims.Email)
+	if err != nil {
+		slog.Error("Failed to verify notification email", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "This address is no longer your notification email", http.StatusConflict)
+		return
+	}
+
+	slog.Info("Verified notification email", "user_id", claims.UserID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("Your notification email is verified.\n"))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/email"
+)
+
+type recordingSender struct {
+	sent []email.Message
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg email.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// setEmailSender turns email on for the duration of a test.
+func setEmailSender(t *testing.T) *recordingSender {
+	prevSender, prevSecret, prevURL := emailSender, emailTokenSecret, publicURL
+	sender := &recordingSender{}
+	emailSender, emailTokenSecret, publicURL = sender, []byte("test-secret"), "https://api.example.com"
+	t.Cleanup(func() { emailSender, emailTokenSecret, publicURL = prevSender, prevSecret, prevURL })
+	return sender
+}
+
+// verificationToken extracts the token from the link in a verification email.
+func verificationToken(t *testing.T, msg email.Message) string {
+	t.Helper()
+	i := strings.Index(msg.Body, "http")
+	if i < 0 {
+		t.Fatalf("No link in verification email: %q", msg.Body)
+	}
+	link, err := url.Parse(strings.TrimSpace(msg.Body[i:]))
+	if err != nil {
+		t.Fatalf("Failed to parse link: %v", err)
+	}
+	if link.Path != "/settings/email/verify" {
+		t.Errorf("Expected a link to /settings/email/verify, got %s", link)
+	}
+	return link.Query().Get("token")
+}
+
+func putSettingsEmail(userID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PUT", "/settings/email", strings.NewReader(body)).WithContext(setupTestContext(userID))
+	w := httptest.NewRecorder()
+	settingsEmailHandler(w, req)
+	return w
+}
+
+func verifyEmail(token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/settings/email/verify?token="+url.QueryEscape(token), nil)
+	w := httptest.NewRecorder()
+	settingsEmailVerifyHandler(w, req)
+	return w
+}
+
+func TestSettingsEmail_SetAndVerify(t *testing.T) {
+	mock := setupMockDB(t)
+	sender := setEmailSender(t)
+
+	mock.ExpectQuery("INSERT INTO user_settings").
+		WithArgs("test-user-id", "me@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"notification_email_verified_at"}).AddRow(nil))
+	mock.ExpectExec("SET notification_email_verified_at").
+		WithArgs("test-user-id", "me@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := putSettingsEmail("test-user-id", `{"email":"me@example.com"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"verifiedAt":null`) {
+		t.Errorf("Expected the new address to be unverified, got %s", w.Body.String())
+	}
+	if len(sender.sent) != 1 || sender.sent[0].To != "me@example.com" {
+		t.Fatalf("Expected one verification email to me@example.com, got %+v", sender.sent)
+	}
+
+	w = verifyEmail(verificationToken(t, sender.sent[0]))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestSettingsEmail_SameAddressKeepsVerification(t *testing.T) {
+	mock := setupMockDB(t)
+	sender := setEmailSender(t)
+
+	mock.ExpectQuery("INSERT INTO user_settings").
+		WithArgs("test-user-id", "me@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"notification_email_verified_at"}).AddRow(time.Now()))
+
+	w := putSettingsEmail("test-user-id", `{"email":"me@example.com"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if strings.Contains(w.Body.String(), `"verifiedAt":null`) || len(sender.sent) != 0 {
+		t.Errorf("Expected a verified address and no email, got %s and %d emails", w.Body.String(), len(sender.sent))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+// After the address changes, a link sent to the old address must not verify
+// anything: the update only matches the address named in the token.
+func TestSettingsEmail_ChangeAfterVerificationResets(t *testing.T) {
+	mock := setupMockDB(t)
+	sender := setEmailSender(t)
+
+	mock.ExpectQuery(`(?s)INSERT INTO user_settings.*WHEN user_settings.notification_email = EXCLUDED.notification_email`).
+		WithArgs("test-user-id", "old@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"notification_email_verified_at"}).AddRow(nil))
+	mock.ExpectQuery("INSERT INTO user_settings").
+		WithArgs("test-user-id", "new@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"notification_email_verified_at"}).AddRow(nil))
+	mock.ExpectExec("SET notification_email_verified_at").
+		WithArgs("test-user-id", "old@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	putSettingsEmail("test-user-id", `{"email":"old@example.com"}`)
+	w := putSettingsEmail("test-user-id", `{"email":"new@example.com"}`)
+	if !strings.Contains(w.Body.String(), `"verifiedAt":null`) {
+		t.Errorf("Expected the changed address to be unverified, got %s", w.Body.String())
+	}
+	if len(sender.sent) != 2 || sender.sent[1].To != "new@example.com" {
+		t.Fatalf("Expected a verification email to the new address, got %+v", sender.sent)
+	}
+
+	w = verifyEmail(verificationToken(t, sender.sent[0]))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestSettingsEmailVerify_RejectsBadTokens(t *testing.T) {
+	mock := setupMockDB(t)
+	setEmailSender(t)
+
+	expired := email.SignToken(emailTokenSecret, email.Claims{UserID: "test-user-id", Email: "me@example.com", ExpiresAt: time.Now().Add(-time.Minute)})
+	if w := verifyEmail(expired); w.Code != http.StatusGone {
+		t.Errorf("Expected status %d for an expired token, got %d", http.StatusGone, w.Code)
+	}
+
+	forged := email.SignToken([]byte("other-secret"), email.Claims{UserID: "test-user-id", Email: "me@example.com", ExpiresAt: time.Now().Add(time.Hour)})
+	if w := verifyEmail(forged); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a forged token, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expected no queries, got: %v", err)
+	}
+}
+
+func TestSettingsEmail_Validation(t *testing.T) {
+	setupMockDB(t)
+	setEmailSender(t)
+
+	for _, body := range []string{`{"email":"not-an-email"}`, `{"email":"Me <me@example.com>"}`, `{"email":""}`} {
+		if w := putSettingsEmail("test-user-id", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	emailSender = nil
+	if w := putSettingsEmail("test-user-id", `{"email":"me@example.com"}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d without email configured, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
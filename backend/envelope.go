@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// envelopeMediaType is the versioned Accept type that opts into the list
+// envelope, as an alternative to ?envelope=true.
+const envelopeMediaType = "application/vnd.pricetrack.v2+json"
+
+// listMeta is the pagination metadata sent alongside a list in the envelope.
+// NextCursor is empty on the last page and when the list is not paginated.
+type listMeta struct {
+	Total      int64  `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// newListMeta builds the metadata for the page at offset of a list with total
+// entries. A zero limit means the whole list was returned.
+func newListMeta(total int64, limit, offset int) listMeta {
+	meta := listMeta{Total: total, Limit: limit}
+	if limit > 0 && int64(offset+limit) < total {
+		meta.NextCursor = encodeCursor(offset + limit)
+	}
+	return meta
+}
+
+// encodeCursor and decodeCursor convert between an offset and the opaque
+// cursor handed to clients, so that keyset cursors can replace offsets later
+// without clients noticing.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(b), "o:") {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	offset, err := strconv.Atoi(string(b[2:]))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return offset, nil
+}
+
+// wantsEnvelope reports whether the client asked for the enveloped list shape
+// with ?envelope=true or an Accept header naming envelopeMediaType. The bare
+// array stays the default so existing clients keep working.
+func wantsEnvelope(r *http.Request) bool {
+	if r.URL.Query().Get("envelope") == "true" {
+		return true
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept)); err == nil && mediaType == envelopeMediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonList returns a data writer for respondList that encodes a slice whole.
+func jsonList(v any) func(io.Writer) error {
+	return func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v)
+	}
+}
+
+// listIncomplete is the envelope's error when its data was cut short. The
+// cause is only logged.
+const listIncomplete = "The list is incomplete: the server failed while sending it"
+
+// writeList writes a list body: the JSON array produced by writeData, wrapped
+// as {"data": [...], "meta": {...}} when envelope is set. The envelope is
+// closed even if writeData fails part way, so that its error only needs to be
+// logged by the caller; it then ends with an "error" field, as meta.total
+// counts entries data may lack.
+func writeList(w io.Writer, envelope bool, meta listMeta, writeData func(io.Writer) error) error {
+	if !envelope {
+		return writeData(w)
+	}
+	io.WriteString(w, `{"data":`)
+	dataErr := writeData(w)
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, `,"meta":%s`, metaJSON)
+	if dataErr != nil {
+		fmt.Fprintf(w, `,"error":%q`, listIncomplete)
+	}
+	io.WriteString(w, "}")
+	return dataErr
+}
+
+// listErrorTrailer is the HTTP trailer that marks a bare list cut short, as
+// the envelope's error field does.
+const listErrorTrailer = "X-List-Error"
+
+// respondList sends a list response in the shape the client asked for. meta
+// is only used for the envelope; callers that compute it with an extra query
+// can check wantsEnvelope first. A bare list whose data fails part way is
+// still closed, and ends with the listErrorTrailer trailer.
+func respondList(w http.ResponseWriter, r *http.Request, meta listMeta, writeData func(io.Writer) error) error {
+	envelope := wantsEnvelope(r)
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("Content-Type", "application/json")
+	if !envelope {
+		w.Header().Set("Trailer", listErrorTrailer)
+	}
+	err := writeList(w, envelope, meta, writeData)
+	if err != nil && !envelope {
+		w.Header().Set(listErrorTrailer, listIncomplete)
+	}
+	return err
+}
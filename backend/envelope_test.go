@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWantsEnvelope(t *testing.T) {
+	tests := []struct {
+		target   string
+		accept   string
+		expected bool
+	}{
+		{"/items", "", false},
+		{"/items", "application/json", false},
+		{"/items?envelope=true", "", true},
+		{"/items?envelope=false", "", false},
+		{"/items", envelopeMediaType, true},
+		{"/items", "text/html, " + envelopeMediaType + "; q=0.9", true},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.target, nil)
+		req.Header.Set("Accept", test.accept)
+		if got := wantsEnvelope(req); got != test.expected {
+			t.Errorf("wantsEnvelope(%q, Accept %q) = %v; expected %v", test.target, test.accept, got, test.expected)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	offset, err := decodeCursor(encodeCursor(40))
+	if err != nil || offset != 40 {
+		t.Errorf("Expected offset 40, got %d (error: %v)", offset, err)
+	}
+	for _, cursor := range []string{"", "not base64!", encodeCursor(-1)} {
+		if _, err := decodeCursor(cursor); err == nil {
+			t.Errorf("Expected an error for cursor %q", cursor)
+		}
+	}
+}
+
+func TestNewListMeta(t *testing.T) {
+	if meta := newListMeta(12, 5, 5); meta.NextCursor != encodeCursor(10) || meta.Total != 12 || meta.Limit != 5 {
+		t.Errorf("Unexpected meta for a middle page: %+v", meta)
+	}
+	if meta := newListMeta(12, 5, 10); meta.NextCursor != "" {
+		t.Errorf("Expected no cursor on the last page, got %q", meta.NextCursor)
+	}
+	if meta := newListMeta(12, 0, 0); meta.NextCursor != "" || meta.Limit != 0 {
+		t.Errorf("Expected no cursor or limit when unpaginated, got %+v", meta)
+	}
+}
+
+func TestWriteList_ClosesEnvelopeOnError(t *testing.T) {
+	var buf bytes.Buffer
+	errBoom := errors.New("boom")
+	err := writeList(&buf, true, listMeta{Total: 1}, func(w io.Writer) error {
+		io.WriteString(w, "[]")
+		return errBoom
+	})
+
+	if !errors.Is(err, errBoom) {
+		t.Errorf("Expected the data error, got %v", err)
+	}
+	if expected := `{"data":[],"meta":{"total":1},"error":"` + listIncomplete + `"}`; buf.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, buf.String())
+	}
+}
+
+func TestWriteList_NoErrorWhenComplete(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeList(&buf, true, listMeta{Total: 1}, jsonList([]int{1})); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := "{\"data\":[1]\n,\"meta\":{\"total\":1}}"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
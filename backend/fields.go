@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"price-track-backend/internal/scheduler"
+	"price-track-backend/internal/snippet"
+)
+
+// itemScan holds the scan destinations for one tracked_items row. Columns
+// that need conversion land in the temporaries and are copied into item by
+// finish.
+type itemScan struct {
+	item             TrackedItem
+	capturedAt       time.Time
+	savedAt          time.Time
+	lastScrapeStatus sql.NullString
+	finalURL         sql.NullString
+	priceNormalized  sql.NullString
+	minExpected      sql.NullFloat64
+	maxExpected      sql.NullFloat64
+	pausedAt         sql.NullTime
+	pauseReason      sql.NullString
+	maxScrapeSeconds sql.NullInt64
+	priceFirstSeenAt sql.NullTime
+	priceChangedAt   sql.NullTime
+	imageURLs        pq.StringArray
+	tags             pq.StringArray
+	lastPrice        sql.NullFloat64
+	shippingPrice    sql.NullFloat64
+	totalPrice       sql.NullFloat64
+	availability     sql.NullString
+	headers          []byte
+	snippetGz        []byte
+}
+
+func (s *itemScan) finish() (TrackedItem, error) {
+	i := s.item
+	if len(s.snippetGz) > 0 {
+		html, err := snippet.Decompress(s.snippetGz)
+		if err != nil {
+			return i, fmt.Errorf("decompress snippet for item %s: %w", i.ID, err)
+		}
+		i.OuterHTMLSnippet = html
+	}
+	i.FinalURL = s.finalURL.String
+	i.PriceNormalized = s.priceNormalized.String
+	i.CapturedAtISO = s.capturedAt.Format(time.RFC3339)
+	i.SavedAtISO = s.savedAt.Format(time.RFC3339)
+	if s.lastScrapeStatus.Valid {
+		i.LastScrapeStatus = s.lastScrapeStatus.String
+	} else {
+		i.LastScrapeStatus = "pending"
+	}
+	if s.minExpected.Valid {
+		i.MinExpected = &s.minExpected.Float64
+	}
+	if s.maxExpected.Valid {
+		i.MaxExpected = &s.maxExpected.Float64
+	}
+	if s.maxScrapeSeconds.Valid {
+		seconds := int(s.maxScrapeSeconds.Int64)
+		i.MaxScrapeSeconds = &seconds
+	}
+	if s.pausedAt.Valid {
+		formatted := s.pausedAt.Time.Format(time.RFC3339)
+		i.PausedAt = &formatted
+	}
+	if s.pauseReason.Valid {
+		i.PauseReason = &s.pauseReason.String
+	}
+	if s.priceFirstSeenAt.Valid {
+		formatted := s.priceFirstSeenAt.Time.Format(time.RFC3339)
+		i.PriceFirstSeenAt = &formatted
+	}
+	if s.priceChangedAt.Valid {
+		formatted := s.priceChangedAt.Time.Format(time.RFC3339)
+		i.PriceLastChangedAt = &formatted
+	}
+	i.ImageURLs = s.imageURLs
+	normalizeImages(&i)
+	i.Tags = s.tags
+	if i.Tags == nil {
+		i.Tags = []string{}
+	}
+	if s.lastPrice.Valid {
+		i.ObservedPrice = &s.lastPrice.Float64
+	}
+	if s.shippingPrice.Valid {
+		i.ShippingPrice = &s.shippingPrice.Float64
+	}
+	if s.totalPrice.Valid {
+		i.TotalPrice = &s.totalPrice.Float64
+	}
+	i.Availability = s.availability.String
+	i.ProxyURL = scheduler.RedactProxyURL(i.ProxyURL)
+	if len(s.headers) > 0 {
+		if err := json.Unmarshal(s.headers, &i.Headers); err != nil {
+			return i, fmt.Errorf("decode headers for item %s: %w", i.ID, err)
+		}
+	}
+	return i, nil
+}
+
+// itemField ties a TrackedItem JSON key to the SQL that produces it.
+type itemField struct {
+	name    string
+	columns string
+	dest    func(*itemScan) []any
+}
+
+// itemFields lists every selectable field in column order. The snippet comes
+// last and is the only one that needs snippetJoin.
+var itemFields = []*itemField{
+	{"id", "id", func(s *itemScan) []any { return []any{&s.item.ID} }},
+	{"priceText", "price_text", func(s *itemScan) []any { return []any{&s.item.PriceText} }},
+	{"priceNormalized", "price_normalized", func(s *itemScan) []any { return []any{&s.priceNormalized} }},
+	{"productName", "product_name", func(s *itemScan) []any { return []any{&s.item.ProductName} }},
+	{"imageUrl", "image_url", func(s *itemScan) []any { return []any{&s.item.ImageURL} }},
+	{"cssSelector", "css_selector", func(s *itemScan) []any { return []any{&s.item.CSSSelector} }},
+	{"xPath", "xpath", func(s *itemScan) []any { return []any{&s.item.XPath} }},
+	{"frameSelector", "frame_selector", func(s *itemScan) []any { return []any{&s.item.FrameSelector} }},
+	{"frameUrl", "frame_url", func(s *itemScan) []any { return []any{&s.item.FrameURL} }},
+	{"variantSelector", "variant_selector", func(s *itemScan) []any { return []any{&s.item.VariantSelector} }},
+	{"variantValue", "variant_value", func(s *itemScan) []any { return []any{&s.item.VariantValue} }},
+	{"proxyUrl", "proxy_url", func(s *itemScan) []any { return []any{&s.item.ProxyURL} }},
+	{"scrapeProfile", "scrape_profile", func(s *itemScan) []any { return []any{&s.item.ScrapeProfile} }},
+	{"maxScrapeSeconds", "max_scrape_seconds", func(s *itemScan) []any { return []any{&s.maxScrapeSeconds} }},
+	{"pageUrl", "page_url", func(s *itemScan) []any { return []any{&s.item.PageURL} }},
+	{"finalUrl", "final_url", func(s *itemScan) []any { return []any{&s.finalURL} }},
+	{"capturedAtIso", "captured_at", func(s *itemScan) []any { return []any{&s.capturedAt} }},
+	{"savedAtIso", "saved_at", func(s *itemScan) []any { return []any{&s.savedAt} }},
+	{"lastScrapeStatus", "last_scrape_status", func(s *itemScan) []any { return []any{&s.lastScrapeStatus} }},
+	{"minExpected", "min_expected", func(s *itemScan) []any { return []any{&s.minExpected} }},
+	{"maxExpected", "max_expected", func(s *itemScan) []any { return []any{&s.maxExpected} }},
+	{"pausedAt", "paused_at", func(s *itemScan) []any { return []any{&s.pausedAt} }},
+	{"pauseReason", "pause_reason", func(s *itemScan) []any { return []any{&s.pauseReason} }},
+	{"parseStrategy", "parse_strategy", func(s *itemScan) []any { return []any{&s.item.ParseStrategy} }},
+	{"adapterOrder", "adapter_order", func(s *itemScan) []any { return []any{&s.item.AdapterOrder} }},
+	{"selectorMatch", "selector_match", func(s *itemScan) []any { return []any{&s.item.SelectorMatch} }},
+	{"shippingSelector", "shipping_selector", func(s *itemScan) []any { return []any{&s.item.ShippingSelector} }},
+	{"shippingPrice", "shipping_price", func(s *itemScan) []any { return []any{&s.shippingPrice} }},
+	{"totalPrice", "total_price", func(s *itemScan) []any { return []any{&s.totalPrice} }},
+	{"notificationChannel", "notification_channel", func(s *itemScan) []any { return []any{&s.item.NotificationChannel} }},
+	{"availabilitySelector", "availability_selector", func(s *itemScan) []any { return []any{&s.item.AvailabilitySelector} }},
+	{"availability", "availability", func(s *itemScan) []any { return []any{&s.availability} }},
+	{"acceptLanguage", "accept_language", func(s *itemScan) []any { return []any{&s.item.AcceptLanguage} }},
+	{"countryCode", "country_code", func(s *itemScan) []any { return []any{&s.item.CountryCode} }},
+	{"priceFirstSeenAt", "price_first_seen_at", func(s *itemScan) []any { return []any{&s.priceFirstSeenAt} }},
+	{"priceLastChangedAt", "price_last_changed_at", func(s *itemScan) []any { return []any{&s.priceChangedAt} }},
+	{"tags", "tags", func(s *itemScan) []any { return []any{&s.tags} }},
+	{"headers", "headers", func(s *itemScan) []any { return []any{&s.headers} }},
+	{"imageUrls", "image_urls", func(s *itemScan) []any { return []any{&s.imageURLs} }},
+	{"outerHtmlSnippet", snippetColumns, func(s *itemScan) []any { return []any{&s.snippetGz, &s.item.OuterHTMLSnippet} }},
+}
+
+// observedPriceField is the latest price the scheduler observed. It is only
+// selected by list filters that compare against it.
+var observedPriceField = &itemField{"observedPrice", "last_price", func(s *itemScan) []any { return []any{&s.lastPrice} }}
+
+// itemFieldSet is the selection of fields a query reads and a response
+// carries. Unless sparse is set, items are encoded in full as before.
+type itemFieldSet struct {
+	fields []*itemField
+	sparse bool
+}
+
+var (
+	// defaultItemFields is every field except the snippet.
+	defaultItemFields = itemFieldSet{fields: itemFields[:len(itemFields)-1]}
+	// allItemFields adds the snippet.
+	allItemFields = itemFieldSet{fields: itemFields}
+)
+
+// parseFields reads ?fields= (a comma-separated list of JSON keys) and
+// ?include=snippet. Unknown names are an error. Without ?fields= the result
+// is defaultItemFields, or allItemFields when withSnippet is set.
+func parseFields(q url.Values, withSnippet bool) (itemFieldSet, error) {
+	v := q.Get("fields")
+	if v == "" {
+		if withSnippet || includesSnippet(q) {
+			return allItemFields, nil
+		}
+		return defaultItemFields, nil
+	}
+
+	want := make(map[string]bool)
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, f := range itemFields {
+			if f.name == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return itemFieldSet{}, fmt.Errorf("unknown field %q", name)
+		}
+		want[name] = true
+	}
+	if includesSnippet(q) {
+		want["outerHtmlSnippet"] = true
+	}
+	if len(want) == 0 {
+		return itemFieldSet{}, fmt.Errorf("fields must name at least one field")
+	}
+
+	set := itemFieldSet{sparse: true}
+	for _, f := range itemFields {
+		if want[f.name] {
+			set.fields = append(set.fields, f)
+		}
+	}
+	return set, nil
+}
+
+// with returns a copy of the set that also selects f.
+func (s itemFieldSet) with(f *itemField) itemFieldSet {
+	s.fields = append(s.fields[:len(s.fields):len(s.fields)], f)
+	return s
+}
+
+// columns returns the SQL select list for the set.
+func (s itemFieldSet) columns() string {
+	cols := make([]string, len(s.fields))
+	for i, f := range s.fields {
+		cols[i] = f.columns
+	}
+	return strings.Join(cols, ", ")
+}
+
+// from returns the FROM clause for the set, joining item_snippets only when
+// the snippet was selected.
+func (s itemFieldSet) from() string {
+	for _, f := range s.fields {
+		if f.columns == snippetColumns {
+			return "tracked_items" + snippetJoin
+		}
+	}
+	return "tracked_items"
+}
+
+// scan reads one row selected with s.columns().
+func (s itemFieldSet) scan(rows *sql.Rows) (TrackedItem, error) {
+	var scan itemScan
+	var dest []any
+	for _, f := range s.fields {
+		dest = append(dest, f.dest(&scan)...)
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return scan.item, err
+	}
+	return scan.finish()
+}
+
+// encode writes item as JSON. For a sparse set only the selected keys are
+// written; keys the full encoding would omit (nil pointers) stay omitted.
+func (s itemFieldSet) encode(w io.Writer, item TrackedItem) error {
+	if !s.sparse {
+		return json.NewEncoder(w).Encode(item)
+	}
+
+	full, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(full, &values); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, f := range s.fields {
+		value, ok := values[f.name]
+		if !ok {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteString("}\n")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestItemsHandler_SparseFields(t *testing.T) {
+	mock := setupMockDB(t)
+
+	expectItemsETag(mock, "test-user-id", 2)
+	mock.ExpectQuery(`SELECT id, price_text, product_name\s+FROM tracked_items\s+WHERE`).
+		WithArgs("test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "price_text", "product_name"}).
+			AddRow("a", "$19.99", "Widget a").
+			AddRow("b", "", "")) // zero values must still be present
+
+	w := getItems(t, "/items?fields=productName,id,priceText", "")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var items []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil {
+		t.Fatalf("Failed to decode response: %v\n%s", err, w.Body.String())
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(items))
+	}
+	for _, item := range items {
+		keys := make([]string, 0, len(item))
+		for k := range item {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if strings.Join(keys, ",") != "id,priceText,productName" {
+			t.Errorf("Expected only the requested keys, got %v", keys)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemsHandler_SparseFieldsOmitNulls(t *testing.T) {
+	mock := setupMockDB(t)
+
+	expectItemsETag(mock, "test-user-id", 1)
+	mock.ExpectQuery(`SELECT id, min_expected\s+FROM tracked_items\s+WHERE`).
+		WithArgs("test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "min_expected"}).AddRow("a", nil))
+
+	w := getItems(t, "/items?fields=id,minExpected", "")
+
+	var items []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if _, ok := items[0]["minExpected"]; ok || len(items[0]) != 1 {
+		t.Errorf("Expected a NULL bound to be omitted, got %v", items[0])
+	}
+}
+
+func TestItemsHandler_UnknownFieldRejected(t *testing.T) {
+	setupMockDB(t)
+
+	w := getItems(t, "/items?fields=id,user_id", "")
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "user_id") {
+		t.Errorf("Expected the unknown field to be named, got %q", w.Body.String())
+	}
+}
+
+func TestItemHandler_SparseFieldsWithSnippet(t *testing.T) {
+	mock := setupMockDB(t)
+
+	row := itemRowWithSnippet("item-1", "<span>$19.99</span>")
+	mock.ExpectQuery(`SELECT id, snippet_gz, outer_html_snippet\s+FROM tracked_items LEFT JOIN item_snippets`).
+		WithArgs("item-1", "test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "snippet_gz", "outer_html_snippet"}).AddRow(row[0], row[len(row)-2], row[len(row)-1]))
+
+	req := httptest.NewRequest("GET", "/items/item-1?fields=id,outerHtmlSnippet", nil)
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	var item map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &item); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(item) != 2 || item["id"] != "item-1" || item["outerHtmlSnippet"] != "<span>$19.99</span>" {
+		t.Errorf("Unexpected item %v", item)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemsHandler_DefaultFieldsUnchanged(t *testing.T) {
+	if itemColumns != "id, price_text, price_normalized, product_name, image_url, css_selector, xpath, frame_selector, frame_url, variant_selector, variant_value, proxy_url, scrape_profile, max_scrape_seconds, page_url, final_url, captured_at, saved_at, last_scrape_status, min_expected, max_expected, paused_at, pause_reason, parse_strategy, adapter_order, selector_match, shipping_selector, shipping_price, total_price, notification_channel, availability_selector, availability, accept_language, country_code, price_first_seen_at, price_last_changed_at, tags, headers, image_urls" {
+		t.Errorf("Unexpected default column list %q", itemColumns)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxItemHeaders bounds the custom headers stored with one item.
+	maxItemHeaders = 10
+	// maxItemHeaderValue caps the length of one header value.
+	maxItemHeaderValue = 1024
+)
+
+// itemHeaderName is the shape of header names items may set: an HTTP token
+// restricted to letters, digits and dashes.
+var itemHeaderName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,63}$`)
+
+// reservedItemHeaders cannot be set per item. The transport manages most of
+// them; Cookie and Accept-Language have their own item fields.
+var reservedItemHeaders = map[string]bool{
+	"Host":                true,
+	"Connection":          true,
+	"Content-Length":      true,
+	"Keep-Alive":          true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Cookie":              true,
+	"Accept-Language":     true,
+}
+
+// validateItemHeaders checks the custom headers for an item on pageURL and
+// canonicalizes their names in place. Credentials for this API are never
+// sent on: an item on our own host cannot carry an Authorization header.
+func validateItemHeaders(pageURL string, headers map[string]string) error {
+	if len(headers) > maxItemHeaders {
+		return fmt.Errorf("at most %d headers are allowed", maxItemHeaders)
+	}
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return fmt.Errorf("invalid pageUrl")
+	}
+	canonical := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if !itemHeaderName.MatchString(name) {
+			return fmt.Errorf("headers: invalid header name %q", name)
+		}
+		key := http.CanonicalHeaderKey(name)
+		if reservedItemHeaders[key] {
+			return fmt.Errorf("headers: %s cannot be set", key)
+		}
+		if key == "Authorization" && isOwnHost(page) {
+			return fmt.Errorf("headers: Authorization cannot be sent to this API")
+		}
+		if _, dup := canonical[key]; dup {
+			return fmt.Errorf("headers: %s is set twice", key)
+		}
+		if len(value) > maxItemHeaderValue {
+			return fmt.Errorf("headers: %s is longer than %d bytes", key, maxItemHeaderValue)
+		}
+		if strings.ContainsFunc(value, func(r rune) bool { return r < ' ' && r != '\t' || r == 0x7f }) {
+			return fmt.Errorf("headers: %s contains control characters", key)
+		}
+		canonical[key] = value
+	}
+	clear(headers)
+	for key, value := range canonical {
+		headers[key] = value
+	}
+	return nil
+}
+
+// isOwnHost reports whether u points at this API (PUBLIC_URL).
+func isOwnHost(u *url.URL) bool {
+	own, err := url.Parse(publicURL)
+	if err != nil || own.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Hostname(), own.Hostname())
+}
+
+// encodeItemHeaders returns the headers column for an item; no headers store
+// NULL.
+func encodeItemHeaders(headers map[string]string) (sql.NullString, error) {
+	if len(headers) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(headers)
+	return sql.NullString{String: string(data), Valid: err == nil}, err
+}
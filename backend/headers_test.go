@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// postItemWithHeaders performs a POST /items request for an item on pageURL
+// carrying headers (a JSON object).
+func postItemWithHeaders(pageURL, headers string) *httptest.ResponseRecorder {
+	body := `{"id":"item-1","priceText":"$19.99","productName":"Widget","cssSelector":".price","pageUrl":"` + pageURL + `",` +
+		`"capturedAtIso":"2025-01-01T00:00:00Z","savedAtIso":"2025-01-01T00:00:00Z","headers":` + headers + `}`
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+	itemsHandler(w, req)
+	return w
+}
+
+func TestItemsHandler_PostStoresHeaders(t *testing.T) {
+	mock := setupMockDB(t)
+
+	expectItemsQuota(mock, "test-user-id", nil, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tracked_items").
+		WithArgs("item-1", "$19.99", "Widget", "", ".price", "", "https://shop.example.com/p/1", sqlmock.AnyArg(), sqlmock.AnyArg(), "test-user-id", nil, nil, "{}", "https://shop.example.com/p/1", "auto", 19.99, "en-US", nil,
+			`{"X-Api-Key":"k3y","X-Region":"eu"}`, "", "", "", "", "", "{}", "", "", "19.99 USD", "first", nil, "first", "", "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := postItemWithHeaders("https://shop.example.com/p/1", `{"x-api-key":"k3y","X-Region":"eu"}`)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemsHandler_PostRejectsHeaders(t *testing.T) {
+	setupMockDB(t)
+	prev := publicURL
+	publicURL = "https://api.example.com"
+	t.Cleanup(func() { publicURL = prev })
+
+	tooMany := make(map[string]string)
+	for _, c := range "abcdefghijk" {
+		tooMany["X-"+string(c)] = "1"
+	}
+	tooManyJSON, _ := json.Marshal(tooMany)
+
+	tests := []struct {
+		name    string
+		pageURL string
+		headers string
+	}{
+		{"too many", "https://shop.example.com/p/1", string(tooManyJSON)},
+		{"bad name", "https://shop.example.com/p/1", `{"X Api Key":"k3y"}`},
+		{"host", "https://shop.example.com/p/1", `{"host":"evil.example.com"}`},
+		{"cookie", "https://shop.example.com/p/1", `{"Cookie":"session=abc"}`},
+		{"long value", "https://shop.example.com/p/1", `{"X-Api-Key":"` + strings.Repeat("k", maxItemHeaderValue+1) + `"}`},
+		{"control characters", "https://shop.example.com/p/1", `{"X-Api-Key":"k3y\r\nX-Other: 1"}`},
+		{"duplicate", "https://shop.example.com/p/1", `{"X-Api-Key":"a","x-api-key":"b"}`},
+		{"authorization to this API", "https://api.example.com/items", `{"Authorization":"Bearer token"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if w := postItemWithHeaders(test.pageURL, test.headers); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestItemHandler_PatchHeaders(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("SELECT page_url FROM tracked_items").
+		WithArgs("item-1", "test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"page_url"}).AddRow("https://shop.example.com/p/1"))
+	mock.ExpectExec(`SET headers = \$3, last_interacted_at = NOW\(\)`).
+		WithArgs("item-1", "test-user-id", `{"Authorization":"Bearer shop-token"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"headers":{"authorization":"Bearer shop-token"}}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemHandler_PatchClearsHeaders(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("SELECT page_url FROM tracked_items").
+		WithArgs("item-1", "test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"page_url"}).AddRow("https://shop.example.com/p/1"))
+	mock.ExpectExec(`SET headers = \$3`).
+		WithArgs("item-1", "test-user-id", nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"headers":{}}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemsHandler_GetReturnsHeadersToOwner(t *testing.T) {
+	mock := setupMockDB(t)
+
+	row := itemRow("item-1")
+	row[len(row)-2] = []byte(`{"X-Api-Key":"k3y"}`)
+	expectItemsETag(mock, "test-user-id", 1)
+	mock.ExpectQuery("FROM tracked_items").
+		WithArgs("test-user-id").
+		WillReturnRows(sqlmock.NewRows(itemColumnNames).AddRow(row...).AddRow(itemRow("item-2")...))
+
+	w := getItems(t, "/items", "")
+
+	var items []TrackedItem
+	if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(items) != 2 || items[0].Headers["X-Api-Key"] != "k3y" || items[1].Headers != nil {
+		t.Errorf("Unexpected headers in %+v", items)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
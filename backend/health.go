@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"price-track-backend/internal/config"
+	"price-track-backend/internal/scheduler"
+)
+
+// schedulerInterval is set from the configuration (SCHEDULER_INTERVAL) in
+// main.
+var schedulerInterval = config.DefaultSchedulerInterval
+
+// SchedulerHealth is returned by GET /health/scheduler.
+type SchedulerHealth struct {
+	// Status is "ok", "stale" (no full pass within twice the interval) or
+	// "never_run".
+	Status    string     `json:"status"`
+	LastRunAt *time.Time `json:"lastRunAt"`
+	Interval  string     `json:"interval"`
+}
+
+// schedulerHealthHandler serves /health/scheduler.
+var schedulerHealthHandler = methods{"GET": getSchedulerHealthHandler}.ServeHTTP
+
+// getSchedulerHealthHandler responds 200 while the scraper job keeps
+// finishing passes and 503 once its heartbeat is older than twice
+// schedulerInterval, so an uptime monitor can alert on a hung scraper.
+func getSchedulerHealthHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	lastRun, ok, err := scheduler.LastRun(ctx, db)
+	if err != nil {
+		slog.Error("Failed to load scheduler heartbeat", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	health := SchedulerHealth{Status: "ok", Interval: schedulerInterval.String()}
+	switch {
+	case !ok:
+		health.Status = "never_run"
+	case time.Since(lastRun) > 2*schedulerInterval:
+		health.Status = "stale"
+	}
+	if ok {
+		health.LastRunAt = &lastRun
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if health.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
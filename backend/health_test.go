@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func getSchedulerHealth(t *testing.T, mock sqlmock.Sqlmock, rows *sqlmock.Rows) (*httptest.ResponseRecorder, SchedulerHealth) {
+	t.Helper()
+	prev := schedulerInterval
+	schedulerInterval = time.Hour
+	t.Cleanup(func() { schedulerInterval = prev })
+
+	mock.ExpectQuery("SELECT last_run_at FROM scheduler_heartbeat").WillReturnRows(rows)
+
+	w := httptest.NewRecorder()
+	schedulerHealthHandler(w, httptest.NewRequest("GET", "/health/scheduler", nil))
+
+	var health SchedulerHealth
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+	return w, health
+}
+
+func TestSchedulerHealth_Fresh(t *testing.T) {
+	mock := setupMockDB(t)
+	lastRun := time.Now().Add(-90 * time.Minute)
+
+	w, health := getSchedulerHealth(t, mock, sqlmock.NewRows([]string{"last_run_at"}).AddRow(lastRun))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if health.Status != "ok" || health.LastRunAt == nil || !health.LastRunAt.Equal(lastRun) {
+		t.Errorf("Expected ok with the last run, got %+v", health)
+	}
+}
+
+func TestSchedulerHealth_StaleHeartbeat(t *testing.T) {
+	mock := setupMockDB(t)
+	lastRun := time.Now().Add(-150 * time.Minute)
+
+	w, health := getSchedulerHealth(t, mock, sqlmock.NewRows([]string{"last_run_at"}).AddRow(lastRun))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if health.Status != "stale" || health.Interval != "1h0m0s" {
+		t.Errorf("Expected stale with a 1h interval, got %+v", health)
+	}
+}
+
+func TestSchedulerHealth_NeverRun(t *testing.T) {
+	mock := setupMockDB(t)
+
+	w, health := getSchedulerHealth(t, mock, sqlmock.NewRows([]string{"last_run_at"}))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if health.Status != "never_run" || health.LastRunAt != nil {
+		t.Errorf("Expected never_run without a last run, got %+v", health)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"price-track-backend/internal/scheduler"
+)
+
+// maxImportRows caps the number of items accepted by one import request.
+const maxImportRows = 500
+
+const (
+	// importValidateConcurrency bounds the preview scrapes run at once.
+	importValidateConcurrency = 8
+	// importRowTimeout bounds a single preview scrape and importValidateTimeout
+	// the whole validation pass; rows still pending then report "timeout".
+	importRowTimeout      = 10 * time.Second
+	importValidateTimeout = 30 * time.Second
+)
+
+// previewScraper runs the HTTP-only preview scrapes for ?validate=true.
+var previewScraper = scheduler.NewScraper()
+
+// ImportResult reports what happened to one row of an import. Rows are
+// numbered from 1 in the order they were submitted (excluding a CSV header).
+type ImportResult struct {
+	Row    int    `json:"row"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Price  string `json:"price,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportResponse is the body returned by POST /items/import.
+type ImportResponse struct {
+	Validated bool           `json:"validated"`
+	Imported  int            `json:"imported"`
+	Results   []ImportResult `json:"results"`
+}
+
+// importCSVColumns maps accepted CSV header names to TrackedItem fields.
+var importCSVColumns = map[string]func(*TrackedItem, string){
+	"pageurl":              func(i *TrackedItem, v string) { i.PageURL = v },
+	"cssselector":          func(i *TrackedItem, v string) { i.CSSSelector = v },
+	"xpath":                func(i *TrackedItem, v string) { i.XPath = v },
+	"pricetext":            func(i *TrackedItem, v string) { i.PriceText = v },
+	"productname":          func(i *TrackedItem, v string) { i.ProductName = v },
+	"imageurl":             func(i *TrackedItem, v string) { i.ImageURL = v },
+	"parsestrategy":        func(i *TrackedItem, v string) { i.ParseStrategy = v },
+	"adapterorder":         func(i *TrackedItem, v string) { i.AdapterOrder = v },
+	"selectormatch":        func(i *TrackedItem, v string) { i.SelectorMatch = v },
+	"acceptlanguage":       func(i *TrackedItem, v string) { i.AcceptLanguage = v },
+	"countrycode":          func(i *TrackedItem, v string) { i.CountryCode = v },
+	"tags":                 func(i *TrackedItem, v string) { i.Tags = strings.Split(v, ",") },
+	"frameselector":        func(i *TrackedItem, v string) { i.FrameSelector = v },
+	"frameurl":             func(i *TrackedItem, v string) { i.FrameURL = v },
+	"variantselector":      func(i *TrackedItem, v string) { i.VariantSelector = v },
+	"variantvalue":         func(i *TrackedItem, v string) { i.VariantValue = v },
+	"scrapeprofile":        func(i *TrackedItem, v string) { i.ScrapeProfile = v },
+	"shippingselector":     func(i *TrackedItem, v string) { i.ShippingSelector = v },
+	"notificationchannel":  func(i *TrackedItem, v string) { i.NotificationChannel = v },
+	"availabilityselector": func(i *TrackedItem, v string) { i.AvailabilitySelector = v },
+}
+
+// parseImportCSV reads items from CSV with a header row naming the columns
+// (pageUrl, cssSelector, xPath, priceText, productName, imageUrl,
+// parseStrategy, adapterOrder, selectorMatch, acceptLanguage, countryCode,
+// shippingSelector, notificationChannel, availabilitySelector, and tags
+// separated by commas).
+// Unknown columns are ignored.
+func parseImportCSV(r io.Reader) ([]TrackedItem, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing CSV header: %w", err)
+	}
+	setters := make([]func(*TrackedItem, string), len(header))
+	for i, name := range header {
+		setters[i] = importCSVColumns[strings.ToLower(strings.TrimSpace(name))]
+	}
+
+	var items []TrackedItem
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var item TrackedItem
+		for i, value := range record {
+			if i < len(setters) && setters[i] != nil {
+				setters[i](&item, strings.TrimSpace(value))
+			}
+		}
+		items = append(items, item)
+		if len(items) > maxImportRows {
+			break
+		}
+	}
+	return items, nil
+}
+
+// parseImport decodes the request body as CSV (text/csv) or a JSON array.
+func parseImport(r *http.Request) ([]TrackedItem, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		return parseImportCSV(r.Body)
+	}
+	var items []TrackedItem
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// validateImportRow checks the fields an imported item needs before it can be
+// scraped or saved, filling in defaults.
+func validateImportRow(item *TrackedItem) error {
+	u, err := url.Parse(item.PageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("pageUrl must be an http(s) URL")
+	}
+	if item.CSSSelector == "" && item.XPath == "" {
+		return fmt.Errorf("cssSelector or xPath is required")
+	}
+	if err := validateParseStrategy(&item.ParseStrategy); err != nil {
+		return err
+	}
+	if err := validateAdapterOrder(&item.AdapterOrder); err != nil {
+		return err
+	}
+	if err := validateSelectorMatch(&item.SelectorMatch); err != nil {
+		return err
+	}
+	if err := validateAcceptLanguage(&item.AcceptLanguage); err != nil {
+		return err
+	}
+	if err := validateCountryCode(&item.CountryCode); err != nil {
+		return err
+	}
+	if item.Tags, err = normalizeTags(item.Tags); err != nil {
+		return err
+	}
+	if err := validateItemHeaders(item.PageURL, item.Headers); err != nil {
+		return err
+	}
+	if err := validateFrame(&item.FrameSelector, &item.FrameURL); err != nil {
+		return err
+	}
+	if err := validateShippingSelector(&item.ShippingSelector); err != nil {
+		return err
+	}
+	if err := validateAvailabilitySelector(&item.AvailabilitySelector); err != nil {
+		return err
+	}
+	if err := validateNotificationChannel(item.NotificationChannel); err != nil {
+		return err
+	}
+	if err := validateVariantSelection(&item.VariantSelector, &item.VariantValue, item.CSSSelector); err != nil {
+		return err
+	}
+	if _, err := scheduler.ParseProxyURL(item.ProxyURL); err != nil {
+		return err
+	}
+	if err := validateScrapeProfile(item.ScrapeProfile); err != nil {
+		return err
+	}
+	return validateExpectedBounds(item.MinExpected, item.MaxExpected)
+}
+
+// newItemID returns a random UUID (version 4), matching the IDs the extension
+// generates for items it saves.
+func newItemID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// previewStatus maps a preview scrape error to the per-row status reported to
+// the user.
+func previewStatus(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, scheduler.ErrSelectorNotFound):
+		return "selector_not_found"
+	case errors.Is(err, scheduler.ErrNoPrice):
+		return "no_price"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "unreachable"
+	}
+}
+
+// validateImport preview-scrapes every row that passed field validation, at
+// most importValidateConcurrency at a time, and fills in its result.
+func validateImport(ctx context.Context, items []TrackedItem, results []ImportResult) {
+	ctx, cancel := context.WithTimeout(ctx, importValidateTimeout)
+	defer cancel()
+
+	sem := make(chan struct{}, importValidateConcurrency)
+	var wg sync.WaitGroup
+	for i := range items {
+		if results[i].Status != "" {
+			continue
+		}
+		if items[i].VariantSelector != "" {
+			// A plain GET would only preview the default variant's price.
+			results[i].Status = "needs_browser"
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].Status = previewStatus(ctx, ctx.Err())
+				return
+			}
+
+			rowCtx, cancel := context.WithTimeout(ctx, importRowTimeout)
+			defer cancel()
+			proxy, _ := scheduler.ParseProxyURL(items[i].ProxyURL)
+			price, err := previewScraper.ScrapeHTTP(rowCtx, scheduler.ScrapeRequest{
+				URL:            items[i].PageURL,
+				CSSSelector:    items[i].CSSSelector,
+				XPath:          items[i].XPath,
+				Match:          scheduler.SelectorMatch(items[i].SelectorMatch),
+				Frame:          scheduler.Frame{Selector: items[i].FrameSelector, URL: items[i].FrameURL},
+				Order:          scheduler.AdapterOrder(items[i].AdapterOrder),
+				AcceptLanguage: items[i].AcceptLanguage,
+				Headers:        items[i].Headers,
+				Proxy:          proxy,
+			})
+			results[i].Status = previewStatus(rowCtx, err)
+			results[i].Price = price
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// importItemsHandler serves /items/import.
+var importItemsHandler = methods{"POST": importHandler}.ServeHTTP
+
+// importHandler bulk-imports items from a JSON array or CSV. With
+// ?validate=true nothing is saved: each row is preview-scraped instead, so
+// the user can fix selectors that currently match nothing before importing.
+func importHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	items, err := parseImport(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(items) == 0 {
+		http.Error(w, "No items to import", http.StatusBadRequest)
+		return
+	}
+	if len(items) > maxImportRows {
+		http.Error(w, fmt.Sprintf("At most %d items can be imported at once", maxImportRows), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	resp := ImportResponse{
+		Validated: r.URL.Query().Get("validate") == "true",
+		Results:   make([]ImportResult, len(items)),
+	}
+	for i := range items {
+		resp.Results[i].Row = i + 1
+		resp.Results[i].ID = items[i].ID
+		if _, err := validateItemProxy(userID, items[i].ProxyURL); err != nil {
+			resp.Results[i].Status = "invalid"
+			resp.Results[i].Error = err.Error()
+		} else if err := validateImportRow(&items[i]); err != nil {
+			resp.Results[i].Status = "invalid"
+			resp.Results[i].Error = err.Error()
+		}
+	}
+
+	if resp.Validated {
+		validateImport(r.Context(), items, resp.Results)
+	} else {
+		ctx, cancel := queryContext(r)
+		defer cancel()
+
+		// The whole import is refused if its valid rows would not all fit.
+		pending := 0
+		for _, result := range resp.Results {
+			if result.Status == "" {
+				pending++
+			}
+		}
+		if !checkItemsQuota(ctx, w, userID, pending) {
+			return
+		}
+
+		now := time.Now().UTC()
+		for i, item := range items {
+			if resp.Results[i].Status != "" {
+				continue
+			}
+			if item.ID == "" {
+				if item.ID, err = newItemID(); err != nil {
+					slog.Error("Failed to generate item id", "error", err)
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+			}
+			normalizeImages(&item)
+			if err := insertItem(ctx, item, sql.Null[[]byte]{}, now, now, userID); err != nil {
+				slog.Error("Failed to import item", "row", i+1, "error", err)
+				if isQueryTimeout(ctx, err) {
+					http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+					return
+				}
+				resp.Results[i].Status = "failed"
+				resp.Results[i].Error = "Failed to save item"
+				continue
+			}
+			resp.Results[i].ID = item.ID
+			resp.Results[i].Status = "imported"
+			resp.Imported++
+		}
+		if resp.Imported > 0 {
+			invalidateUserCache(userID)
+		}
+	}
+
+	slog.Info("Processed item import", "rows", len(items), "imported", resp.Imported, "validated", resp.Validated, "user_id", userID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// postImport performs a POST /items/import request and decodes the response.
+func postImport(t *testing.T, target, contentType, body string) (*httptest.ResponseRecorder, ImportResponse) {
+	t.Helper()
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	importItemsHandler(w, req)
+
+	var resp ImportResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v\n%s", err, w.Body.String())
+		}
+	}
+	return w, resp
+}
+
+// allowLocalPreviews lets preview scrapes reach the loopback addresses test
+// servers listen on, for the rest of t.
+func allowLocalPreviews(t *testing.T) {
+	previewScraper.AllowPrivateAddresses(true)
+	t.Cleanup(func() { previewScraper.AllowPrivateAddresses(false) })
+}
+
+func TestImportItemsHandler_ValidateReportsPerRowStatus(t *testing.T) {
+	// No database calls are expected: validation never saves anything.
+	mock := setupMockDB(t)
+	allowLocalPreviews(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$19.99</div><div class="stock">Sold out</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	body := `[
+		{"pageUrl":"` + ts.URL + `/a","cssSelector":".price"},
+		{"pageUrl":"` + ts.URL + `/b","cssSelector":".old-price"},
+		{"pageUrl":"` + ts.URL + `/c","xPath":"//div[@class='price']"},
+		{"pageUrl":"` + ts.URL + `/d","cssSelector":".stock"},
+		{"pageUrl":"` + ts.URL + `/gone","cssSelector":".price"},
+		{"pageUrl":"not a url","cssSelector":".price"},
+		{"pageUrl":"` + ts.URL + `/e"},
+		{"pageUrl":"` + ts.URL + `/a","cssSelector":".price","variantSelector":"button.size","variantValue":"11"}
+	]`
+	w, resp := postImport(t, "/items/import?validate=true", "application/json", body)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !resp.Validated || resp.Imported != 0 {
+		t.Errorf("Expected a dry run, got validated=%v imported=%d", resp.Validated, resp.Imported)
+	}
+	want := []string{"ok", "selector_not_found", "ok", "no_price", "unreachable", "invalid", "invalid", "needs_browser"}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("Expected %d results, got %d", len(want), len(resp.Results))
+	}
+	for i, status := range want {
+		if resp.Results[i].Row != i+1 || resp.Results[i].Status != status {
+			t.Errorf("Row %d: expected status %q, got %+v", i+1, status, resp.Results[i])
+		}
+	}
+	if resp.Results[0].Price != "$19.99" {
+		t.Errorf("Expected the previewed price, got %q", resp.Results[0].Price)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestImportItemsHandler_CSVImportsValidRows(t *testing.T) {
+	mock := setupMockDB(t)
+
+	expectItemsQuota(mock, "test-user-id", nil, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tracked_items").
+		WithArgs(sqlmock.AnyArg(), "$5.00", "Mug", "", ".price", "", "https://example.com/mug", sqlmock.AnyArg(), sqlmock.AnyArg(), "test-user-id", nil, nil, "{}", "https://example.com/mug", "auto", 5.0, "en-US", nil, nil, "", "", "", "", "", "{}", "", "", "5.00 USD", "first", nil, "first", "", "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	body := "pageUrl,cssSelector,priceText,productName,notes\n" +
+		"https://example.com/mug,.price,$5.00,Mug,ignored\n" +
+		"https://example.com/lamp,,$30.00,Lamp,\n"
+	w, resp := postImport(t, "/items/import", "text/csv; charset=utf-8", body)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if resp.Imported != 1 {
+		t.Errorf("Expected 1 imported item, got %d", resp.Imported)
+	}
+	if resp.Results[0].Status != "imported" || resp.Results[0].ID == "" {
+		t.Errorf("Expected row 1 to be imported with a generated id, got %+v", resp.Results[0])
+	}
+	if resp.Results[1].Status != "invalid" {
+		t.Errorf("Expected row 2 to be invalid, got %+v", resp.Results[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestImportItemsHandler_RejectsBadBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"empty array", "application/json", "[]", http.StatusBadRequest},
+		{"malformed json", "application/json", "{", http.StatusBadRequest},
+		{"csv without rows", "text/csv", "pageUrl,cssSelector\n", http.StatusBadRequest},
+		{"too many rows", "text/csv", "pageUrl\n" + strings.Repeat("https://example.com\n", maxImportRows+1), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w, _ := postImport(t, "/items/import", test.contentType, test.body)
+			if w.Code != test.want {
+				t.Errorf("Expected status %d, got %d", test.want, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/lib/pq"
+)
+
+// expectedIndex is an index the API's or the scheduler's queries rely on,
+// with the statement its migration creates it with.
+type expectedIndex struct {
+	name      string
+	migration string
+	create    string
+}
+
+// expectedIndexes are checked at startup. Without them, listing a user's
+// items, charting a price history or reading scrape logs scans whole tables.
+var expectedIndexes = []expectedIndex{
+	{"idx_scrape_log_domain_created_at", "003_scrape_log.sql", `CREATE INDEX IF NOT EXISTS idx_scrape_log_domain_created_at ON scrape_log (domain, created_at DESC)`},
+	{"idx_scrape_log_item_created_at", "003_scrape_log.sql", `CREATE INDEX IF NOT EXISTS idx_scrape_log_item_created_at ON scrape_log (item_id, created_at DESC)`},
+	{"idx_api_keys_user_id", "004_api_keys.sql", `CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id)`},
+	{"idx_tracked_items_user_updated_at", "006_updated_at.sql", `CREATE INDEX IF NOT EXISTS idx_tracked_items_user_updated_at ON tracked_items (user_id, updated_at DESC)`},
+	{"idx_variant_price_history_variant", "009_item_variants.sql", `CREATE INDEX IF NOT EXISTS idx_variant_price_history_variant ON variant_price_history (variant_id, recorded_at DESC)`},
+	{"idx_webhook_deliveries_pending", "010_webhooks.sql", `CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt_at) WHERE status = 'pending'`},
+	{"idx_webhook_deliveries_user_created_at", "010_webhooks.sql", `CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_user_created_at ON webhook_deliveries (user_id, created_at DESC)`},
+	{"idx_tracked_items_user_normalized_url", "011_normalized_url.sql", `CREATE INDEX IF NOT EXISTS idx_tracked_items_user_normalized_url ON tracked_items (user_id, normalized_url)`},
+	{"idx_item_price_history_item", "022_item_sharing.sql", `CREATE INDEX IF NOT EXISTS idx_item_price_history_item ON item_price_history (item_id, recorded_at)`},
+	{"idx_queued_emails_deliver_after", "023_queued_emails.sql", `CREATE INDEX IF NOT EXISTS idx_queued_emails_deliver_after ON queued_emails (deliver_after)`},
+	{"idx_tracked_items_user_created_at_id", "029_items_order_index.sql", `CREATE INDEX IF NOT EXISTS idx_tracked_items_user_created_at_id ON tracked_items (user_id, created_at DESC, id DESC)`},
+	{"idx_tracked_items_tags", "030_item_tags.sql", `CREATE INDEX IF NOT EXISTS idx_tracked_items_tags ON tracked_items USING GIN (tags)`},
+	{"idx_scrape_jobs_claim", "041_scrape_jobs.sql", `CREATE INDEX IF NOT EXISTS idx_scrape_jobs_claim ON scrape_jobs (priority DESC, run_after, id) WHERE state = 'queued'`},
+	{"idx_scrape_screenshots_item_created_at", "048_scrape_screenshots.sql", `CREATE INDEX IF NOT EXISTS idx_scrape_screenshots_item_created_at ON scrape_screenshots (item_id, created_at DESC)`},
+	{"idx_scrape_screenshots_created_at", "048_scrape_screenshots.sql", `CREATE INDEX IF NOT EXISTS idx_scrape_screenshots_created_at ON scrape_screenshots (created_at)`},
+}
+
+// missingIndexes returns the expected indexes the database does not have.
+func missingIndexes(ctx context.Context, db *sql.DB, expected []expectedIndex) ([]expectedIndex, error) {
+	names := make([]string, len(expected))
+	for i, index := range expected {
+		names[i] = index.name
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT indexname FROM pg_indexes
+		WHERE schemaname = current_schema() AND indexname = ANY($1)`, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	present := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		present[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []expectedIndex
+	for _, index := range expected {
+		if !present[index.name] {
+			missing = append(missing, index)
+		}
+	}
+	return missing, nil
+}
+
+// checkIndexes warns about every expected index the database lacks, or
+// creates it when create is set (AUTO_MIGRATE). A failed check is logged and
+// otherwise ignored; the API works without the indexes, only slower.
+func checkIndexes(ctx context.Context, db *sql.DB, create bool) {
+	missing, err := missingIndexes(ctx, db, expectedIndexes)
+	if err != nil {
+		slog.Warn("Could not check database indexes", "error", err)
+		return
+	}
+	for _, index := range missing {
+		if !create {
+			slog.Warn("Database index missing, queries will be slow until its migration is applied", "index", index.name, "migration", index.migration)
+			continue
+		}
+		if _, err := db.ExecContext(ctx, index.create); err != nil {
+			slog.Error("Failed to create missing index", "index", index.name, "error", err)
+			continue
+		}
+		slog.Info("Created missing index", "index", index.name)
+	}
+}
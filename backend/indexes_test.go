@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestMissingIndexes(t *testing.T) {
+	mock := setupMockDB(t)
+	expected := []expectedIndex{
+		{name: "idx_tracked_items_user_created_at_id"},
+		{name: "idx_item_price_history_item"},
+		{name: "idx_tracked_items_tags"},
+	}
+	mock.ExpectQuery(`SELECT indexname FROM pg_indexes\s+WHERE schemaname = current_schema\(\) AND indexname = ANY\(\$1\)`).
+		WithArgs(pq.Array([]string{"idx_tracked_items_user_created_at_id", "idx_item_price_history_item", "idx_tracked_items_tags"})).
+		WillReturnRows(sqlmock.NewRows([]string{"indexname"}).AddRow("idx_tracked_items_tags").AddRow("idx_tracked_items_user_created_at_id"))
+
+	missing, err := missingIndexes(context.Background(), db, expected)
+	if err != nil {
+		t.Fatalf("missingIndexes failed: %v", err)
+	}
+	if len(missing) != 1 || missing[0].name != "idx_item_price_history_item" {
+		t.Errorf("Expected only the price history index to be missing, got %+v", missing)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCheckIndexes_CreatesMissing(t *testing.T) {
+	mock := setupMockDB(t)
+	rows := sqlmock.NewRows([]string{"indexname"})
+	for _, index := range expectedIndexes {
+		if index.name != "idx_item_price_history_item" {
+			rows.AddRow(index.name)
+		}
+	}
+	mock.ExpectQuery("FROM pg_indexes").WillReturnRows(rows)
+	mock.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS idx_item_price_history_item ON item_price_history (item_id, recorded_at)")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	checkIndexes(context.Background(), db, true)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCheckIndexes_OnlyWarns(t *testing.T) {
+	mock := setupMockDB(t)
+	mock.ExpectQuery("FROM pg_indexes").WillReturnRows(sqlmock.NewRows([]string{"indexname"}))
+
+	checkIndexes(context.Background(), db, false)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package adapters knows how popular stores build their product pages, so
+// that their items are tracked without every user reverse-engineering a price
+// selector. The scraper looks up the adapter for a page's host and reads the
+// price, and whether the product is in stock, the way the adapter says.
+//
+// Adding a store takes a file that registers an Adapter for its hostname
+// pattern from init, and a test against a page saved in testdata/<store>.
+// Operators can give stores selectors without one, see Configure.
+package adapters
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Adapter describes one store's product pages.
+type Adapter interface {
+	// Name identifies the adapter in logs, errors and the scrape log.
+	Name() string
+	// PriceSelectors are CSS selectors for the price of the product on
+	// offer, most specific first. The first element whose text parses to a
+	// positive price wins.
+	PriceSelectors() []string
+	// Available reports whether doc offers the product for sale. Pages that
+	// do not say otherwise are available.
+	Available(doc *goquery.Document) bool
+	// Steps are performed in the headless browser, in order, once the page
+	// has loaded and before the price is read. Nil for most stores.
+	Steps() []Step
+}
+
+// Canonicalizer is implemented by adapters whose product links carry
+// tracking and navigation leftovers that do not change the product.
+type Canonicalizer interface {
+	// Canonicalize returns the URL that identifies the product at u, or nil
+	// if u is not a product page.
+	Canonicalize(u *url.URL) *url.URL
+}
+
+// Challenger is implemented by adapters for stores that answer suspected
+// bots with a challenge page instead of the product.
+type Challenger interface {
+	// Challenged reports whether doc is the store's bot challenge.
+	Challenged(doc *goquery.Document) bool
+}
+
+// PriceReader is implemented by adapters for stores that show a price no
+// single element holds, such as one split into whole and fraction parts.
+type PriceReader interface {
+	// ReadPrices returns price texts put together from doc, tried in order
+	// after PriceSelectors.
+	ReadPrices(doc *goquery.Document) []string
+}
+
+// Step is something the browser does on a store's page before the price can
+// be read. An element that does not show up within StepTimeout is skipped,
+// since banners and pickers only appear on some visits.
+type Step struct {
+	// Click is a CSS selector for an element to click, such as a consent
+	// banner's accept button.
+	Click string
+	// WaitFor is a CSS selector for an element to wait for, such as a price
+	// the page renders with a script.
+	WaitFor string
+}
+
+// StepTimeout bounds the wait for each Step's element.
+const StepTimeout = 5 * time.Second
+
+type entry struct {
+	pattern string
+	adapter Adapter
+}
+
+var (
+	mu       sync.RWMutex
+	registry []entry
+)
+
+// Register makes a the adapter for hosts matching pattern. A pattern is a
+// domain, which also matches its subdomains ("bestbuy.com" matches
+// "www.bestbuy.com"), or a name followed by ".*", which matches that name
+// under any public suffix ("amazon.*" matches "amazon.co.uk"). Adapters
+// registered earlier win when several patterns match.
+func Register(pattern string, a Adapter) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry = append(registry, entry{strings.ToLower(pattern), a})
+}
+
+// For returns the adapter for u's host, with the selectors configured for
+// it (see Configure) in front, nil if there is neither.
+func For(u *url.URL) Adapter {
+	if u == nil {
+		return nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	mu.RLock()
+	defer mu.RUnlock()
+	var builtin Adapter
+	for _, e := range registry {
+		if matchHost(e.pattern, host) {
+			builtin = e.adapter
+			break
+		}
+	}
+	return withConfigured(host, builtin)
+}
+
+func matchHost(pattern, host string) bool {
+	if name, ok := strings.CutSuffix(pattern, ".*"); ok {
+		site, err := publicsuffix.EffectiveTLDPlusOne(host)
+		// site is one label under the public suffix, so this only matches
+		// the name itself: not "notamazon.com" nor "amazon.example.com".
+		return err == nil && strings.HasPrefix(site, name+".")
+	}
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
+
+// Price returns the price a reads from doc: the text of the first element
+// its PriceSelectors match for which valid reports true, or failing that the
+// first such text from ReadPrices. It returns "" if there is none.
+func Price(a Adapter, doc *goquery.Document, valid func(string) bool) string {
+	for _, selector := range a.PriceSelectors() {
+		var price string
+		doc.Find(selector).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			if text := strings.TrimSpace(s.Text()); valid(text) {
+				price = text
+			}
+			return price == ""
+		})
+		if price != "" {
+			return price
+		}
+	}
+	if r, ok := a.(PriceReader); ok {
+		for _, text := range r.ReadPrices(doc) {
+			if valid(text) {
+				return text
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package adapters
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// fixture parses a page saved in testdata/<store>. The pages follow the
+// store's product page markup, cut down to the parts around the price.
+func fixture(t *testing.T, store, name string) *goquery.Document {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", store, name))
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatalf("Failed to parse fixture: %v", err)
+	}
+	return doc
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %v", raw, err)
+	}
+	return u
+}
+
+// hasPrice stands in for the scraper's check that text parses to a positive
+// price.
+func hasPrice(text string) bool {
+	return strings.ContainsAny(text, "123456789")
+}
+
+// fixtureCase is what an adapter should read from one of its fixtures.
+type fixtureCase struct {
+	fixture   string
+	price     string
+	available bool
+}
+
+// checkFixtures runs a against its store's fixtures.
+func checkFixtures(t *testing.T, a Adapter, tests []fixtureCase) {
+	t.Helper()
+	for _, test := range tests {
+		doc := fixture(t, a.Name(), test.fixture)
+		if got := Price(a, doc, hasPrice); got != test.price {
+			t.Errorf("%s/%s: Expected price %q, got %q", a.Name(), test.fixture, test.price, got)
+		}
+		if got := a.Available(doc); got != test.available {
+			t.Errorf("%s/%s: Expected available to be %v, got %v", a.Name(), test.fixture, test.available, got)
+		}
+	}
+}
+
+func TestFor(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://www.amazon.com/dp/B07XJ8C8F5":                      "amazon",
+		"https://amazon.co.uk/dp/B0BSHF7WHW":                        "amazon",
+		"https://smile.amazon.de/dp/B09G9FPHY6":                     "amazon",
+		"https://www.amazon.com.au/dp/B0C1234567":                   "amazon",
+		"https://www.bestbuy.com/site/sony/6505727.p?skuId=6505727": "bestbuy",
+		"https://WWW.Walmart.com/ip/5112413945":                     "walmart",
+		"https://www.target.com/p/stanley/-/A-88829460":             "target",
+		"https://www.uniqlo.com/us/en/products/E465185-000/00":      "uniqlo",
+		"https://s3.amazonaws.com/bucket/page":                      "",
+		"https://www.notamazon.com/dp/B07XJ8C8F5":                   "",
+		"https://amazon.example.com/dp/B07XJ8C8F5":                  "",
+		"https://shop.example.com/bestbuy.com":                      "",
+		"https://notbestbuy.com/site/6505727.p":                     "",
+	} {
+		got := ""
+		if a := For(mustParseURL(t, raw)); a != nil {
+			got = a.Name()
+		}
+		if got != want {
+			t.Errorf("%s: Expected %q, got %q", raw, want, got)
+		}
+	}
+}
+
+func TestPrice_Unmatched(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><span data-test="product-price">See price in cart</span></body></html>`))
+	if err != nil {
+		t.Fatalf("Failed to parse page: %v", err)
+	}
+	if got := Price(target{}, doc, hasPrice); got != "" {
+		t.Errorf("Expected no price, got %q", got)
+	}
+}
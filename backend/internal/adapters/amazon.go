@@ -0,0 +1,98 @@
+package adapters
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// amazon handles the Amazon storefronts (amazon.com, amazon.de, amazon.co.uk,
+// ...). Their product pages carry several prices (list price, per-unit price,
+// other sellers, related products), so a selector picked on one page often
+// reads the wrong one on the next.
+type amazon struct{}
+
+func init() { Register("amazon.*", amazon{}) }
+
+// amazonASIN finds the ASIN, Amazon's product ID, in the paths its product
+// links take.
+var amazonASIN = regexp.MustCompile(`(?i)/(?:dp|gp/product|gp/aw/d|exec/obidos/asin|o/asin)/([a-z0-9]{10})(?:[/?]|$)`)
+
+func (amazon) Name() string { return "amazon" }
+
+// PriceSelectors read the offer in the buy box. The visually hidden
+// .a-offscreen copy holds the whole price as one string; list prices
+// (.a-text-price) are skipped.
+func (amazon) PriceSelectors() []string {
+	return []string{
+		"#corePrice_feature_div .a-offscreen",
+		"#corePriceDisplay_desktop_feature_div .a-price:not(.a-text-price) .a-offscreen",
+		".a-price:not(.a-text-price) .a-offscreen",
+		"#priceblock_dealprice",
+		"#priceblock_saleprice",
+		"#priceblock_ourprice",
+	}
+}
+
+// Available is false on pages that carry the "Currently unavailable" box in
+// place of the buy box.
+func (amazon) Available(doc *goquery.Document) bool {
+	return doc.Find("#outOfStock").Length() == 0
+}
+
+func (amazon) Steps() []Step { return nil }
+
+// Canonicalize reduces a product link to https://<host>/dp/<ASIN>, dropping
+// the name slug, ref= path segments and query parameters Amazon adds for
+// tracking.
+func (amazon) Canonicalize(u *url.URL) *url.URL {
+	m := amazonASIN.FindStringSubmatch(u.EscapedPath())
+	if m == nil {
+		return nil
+	}
+	return &url.URL{Scheme: "https", Host: strings.ToLower(u.Host), Path: "/dp/" + strings.ToUpper(m[1])}
+}
+
+// ReadPrices covers pages that leave .a-offscreen empty and only show the
+// price split into its symbol, whole and fraction parts.
+func (amazon) ReadPrices(doc *goquery.Document) []string {
+	var prices []string
+	doc.Find(".a-price:not(.a-text-price)").Each(func(_ int, s *goquery.Selection) {
+		if text := amazonSplitPrice(s); text != "" {
+			prices = append(prices, text)
+		}
+	})
+	return prices
+}
+
+// amazonSplitPrice puts back together a price shown as
+// <span class="a-price-symbol">$</span><span class="a-price-whole">1,299<span
+// class="a-price-decimal">.</span></span><span class="a-price-fraction">99</span>.
+func amazonSplitPrice(price *goquery.Selection) string {
+	whole := strings.TrimSpace(price.Find(".a-price-whole").First().Text())
+	if whole == "" {
+		return ""
+	}
+	decimal := strings.TrimSpace(price.Find(".a-price-decimal").First().Text())
+	if decimal == "" {
+		decimal = "."
+	}
+	whole = strings.TrimSuffix(whole, decimal)
+	symbol := strings.TrimSpace(price.Find(".a-price-symbol").First().Text())
+	fraction := strings.TrimSpace(price.Find(".a-price-fraction").First().Text())
+	if fraction == "" {
+		return symbol + whole
+	}
+	return symbol + whole + decimal + fraction
+}
+
+// Challenged recognizes Amazon's robot check, which asks for the characters
+// in an image before showing the page.
+func (amazon) Challenged(doc *goquery.Document) bool {
+	if doc.Find(`form[action*="validateCaptcha"], input#captchacharacters`).Length() > 0 {
+		return true
+	}
+	return strings.Contains(doc.Find("title").First().Text(), "Robot Check")
+}
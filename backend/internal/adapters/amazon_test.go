@@ -0,0 +1,51 @@
+package adapters
+
+import "testing"
+
+func TestAmazon(t *testing.T) {
+	checkFixtures(t, amazon{}, []fixtureCase{
+		// The buy box price, not the list price before it or the carousel
+		// after it.
+		{"core_price.html", "$24.99", true},
+		// .a-offscreen is empty, so the split parts are put together.
+		{"split_price.html", "€1.299,00", true},
+		{"priceblock.html", "£64.99", true},
+		// Only a list price and related products remain.
+		{"out_of_stock.html", "$59.99", false},
+		{"robot_check.html", "", true},
+	})
+}
+
+func TestAmazon_Canonicalize(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.amazon.com/Stainless-Electric-Kettle/dp/B07XJ8C8F5/ref=sr_1_3?keywords=kettle&qid=1717&sr=8-3&th=1", "https://www.amazon.com/dp/B07XJ8C8F5"},
+		{"https://www.amazon.com/dp/b07xj8c8f5?tag=affiliate-20&linkCode=ll1", "https://www.amazon.com/dp/B07XJ8C8F5"},
+		{"http://Amazon.de/gp/product/B09G9FPHY6/ref=ppx_yo_dt_b_asin_title_o00_s00?ie=UTF8&psc=1", "https://amazon.de/dp/B09G9FPHY6"},
+		{"https://www.amazon.co.uk/gp/aw/d/B0BSHF7WHW?pd_rd_w=abc#customerReviews", "https://www.amazon.co.uk/dp/B0BSHF7WHW"},
+		{"https://www.amazon.com/s?k=kettle", ""},
+		{"https://www.amazon.com/stores/page/6A1F9C2B", ""},
+	}
+	for _, test := range tests {
+		got := amazon{}.Canonicalize(mustParseURL(t, test.url))
+		if (got == nil && test.want != "") || (got != nil && got.String() != test.want) {
+			t.Errorf("%s: Expected %q, got %v", test.url, test.want, got)
+		}
+	}
+}
+
+func TestAmazon_Challenged(t *testing.T) {
+	for name, want := range map[string]bool{
+		"robot_check.html":  true,
+		"core_price.html":   false,
+		"split_price.html":  false,
+		"priceblock.html":   false,
+		"out_of_stock.html": false,
+	} {
+		if got := (amazon{}).Challenged(fixture(t, "amazon", name)); got != want {
+			t.Errorf("%s: Expected %v, got %v", name, want, got)
+		}
+	}
+}
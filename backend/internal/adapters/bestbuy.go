@@ -0,0 +1,33 @@
+package adapters
+
+import "github.com/PuerkitoBio/goquery"
+
+// bestBuy handles bestbuy.com. Its price box also holds the regular price a
+// sale is measured against and a screen reader sentence repeating the price,
+// so a hand-picked selector easily lands on one of those.
+type bestBuy struct{}
+
+func init() { Register("bestbuy.com", bestBuy{}) }
+
+func (bestBuy) Name() string { return "bestbuy" }
+
+// PriceSelectors read the visible half of the customer price; the regular
+// price sits in .pricing-price__regular-price.
+func (bestBuy) PriceSelectors() []string {
+	return []string{
+		`[data-testid="customer-price"] span[aria-hidden="true"]`,
+		`.priceView-customer-price span[aria-hidden="true"]`,
+		`.priceView-hero-price span[aria-hidden="true"]`,
+	}
+}
+
+// Available is false when the add to cart button reads "Sold Out".
+func (bestBuy) Available(doc *goquery.Document) bool {
+	return doc.Find(`.fulfillment-add-to-cart-button [data-button-state="SOLD_OUT"]`).Length() == 0
+}
+
+// Steps pick the US store on the country picker that first-time visitors
+// from elsewhere get instead of the product.
+func (bestBuy) Steps() []Step {
+	return []Step{{Click: ".country-selection a.us-link"}}
+}
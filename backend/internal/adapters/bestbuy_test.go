@@ -0,0 +1,11 @@
+package adapters
+
+import "testing"
+
+func TestBestBuy(t *testing.T) {
+	checkFixtures(t, bestBuy{}, []fixtureCase{
+		// Not the regular price, the screen reader sentence or the carousel.
+		{"product.html", "$329.99", true},
+		{"sold_out.html", "$349.99", false},
+	})
+}
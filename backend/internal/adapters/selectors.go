@@ -0,0 +1,137 @@
+package adapters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// Selectors maps host patterns, as Register takes them, to price selectors
+// an operator configured for stores without an adapter, or to try before a
+// built-in adapter's own (SITE_SELECTORS).
+type Selectors map[string][]string
+
+// ParseSelectors reads a JSON object of host patterns to lists of CSS
+// selectors, most specific first, such as
+// {"shop.example.com": [".price-now", ".price"]}.
+func ParseSelectors(s string) (Selectors, error) {
+	var table Selectors
+	if err := json.Unmarshal([]byte(s), &table); err != nil {
+		return nil, err
+	}
+	for pattern, selectors := range table {
+		if pattern == "" || strings.ContainsAny(pattern, "/: ") {
+			return nil, fmt.Errorf("%q is not a host pattern", pattern)
+		}
+		if len(selectors) == 0 {
+			return nil, fmt.Errorf("%s has no selectors", pattern)
+		}
+		for _, selector := range selectors {
+			if _, err := cascadia.Compile(selector); err != nil {
+				return nil, fmt.Errorf("%s: invalid selector %q: %w", pattern, selector, err)
+			}
+		}
+	}
+	if len(table) == 0 {
+		return nil, errors.New("no host patterns")
+	}
+	return table, nil
+}
+
+type configuredEntry struct {
+	pattern   string
+	selectors []string
+}
+
+// configured holds the entries of Configure, the longest pattern first so
+// that "shop.example.com" wins over "example.com".
+var configured []configuredEntry
+
+// Configure makes For use table's selectors on hosts its patterns match,
+// replacing the table set before. On a host with a built-in adapter they are
+// tried before the adapter's own, which still decides whether the product is
+// in stock; elsewhere they make up an adapter named after the pattern.
+func Configure(table Selectors) {
+	entries := make([]configuredEntry, 0, len(table))
+	for pattern, selectors := range table {
+		entries = append(entries, configuredEntry{strings.ToLower(pattern), selectors})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if len(entries[i].pattern) != len(entries[j].pattern) {
+			return len(entries[i].pattern) > len(entries[j].pattern)
+		}
+		return entries[i].pattern < entries[j].pattern
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	configured = entries
+}
+
+// withConfigured returns builtin, the adapter registered for host, with the
+// configured selectors for host in front. Callers hold mu.
+func withConfigured(host string, builtin Adapter) Adapter {
+	for _, e := range configured {
+		if matchHost(e.pattern, host) {
+			return configuredAdapter{pattern: e.pattern, selectors: e.selectors, builtin: builtin}
+		}
+	}
+	return builtin
+}
+
+// configuredAdapter reads prices with configured selectors, then with those
+// of builtin, if the host has one, which it otherwise stands in for.
+type configuredAdapter struct {
+	pattern   string
+	selectors []string
+	builtin   Adapter
+}
+
+func (a configuredAdapter) Name() string {
+	if a.builtin != nil {
+		return a.builtin.Name()
+	}
+	return a.pattern
+}
+
+func (a configuredAdapter) PriceSelectors() []string {
+	if a.builtin == nil {
+		return a.selectors
+	}
+	return append(append([]string(nil), a.selectors...), a.builtin.PriceSelectors()...)
+}
+
+func (a configuredAdapter) Available(doc *goquery.Document) bool {
+	return a.builtin == nil || a.builtin.Available(doc)
+}
+
+func (a configuredAdapter) Steps() []Step {
+	if a.builtin == nil {
+		return nil
+	}
+	return a.builtin.Steps()
+}
+
+func (a configuredAdapter) Canonicalize(u *url.URL) *url.URL {
+	if c, ok := a.builtin.(Canonicalizer); ok {
+		return c.Canonicalize(u)
+	}
+	return nil
+}
+
+func (a configuredAdapter) Challenged(doc *goquery.Document) bool {
+	c, ok := a.builtin.(Challenger)
+	return ok && c.Challenged(doc)
+}
+
+func (a configuredAdapter) ReadPrices(doc *goquery.Document) []string {
+	if r, ok := a.builtin.(PriceReader); ok {
+		return r.ReadPrices(doc)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package adapters
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// configure sets table for the rest of the test.
+func configure(t *testing.T, table Selectors) {
+	t.Helper()
+	Configure(table)
+	t.Cleanup(func() { Configure(nil) })
+}
+
+func TestParseSelectors(t *testing.T) {
+	table, err := ParseSelectors(`{"shop.example.com": [".price-now", ".price"], "Kettles.*": ["#price"]}`)
+	if err != nil {
+		t.Fatalf("ParseSelectors failed: %v", err)
+	}
+	if len(table) != 2 || len(table["shop.example.com"]) != 2 || table["Kettles.*"][0] != "#price" {
+		t.Errorf("Expected both patterns with their selectors, got %v", table)
+	}
+
+	for _, bad := range []string{
+		``,
+		`[".price"]`,
+		`{}`,
+		`{"shop.example.com": []}`,
+		`{"https://shop.example.com": [".price"]}`,
+		`{"shop.example.com": [".price["]}`,
+	} {
+		if _, err := ParseSelectors(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	configure(t, Selectors{
+		"example.com":      {".price"},
+		"shop.example.com": {".price-now"},
+		"amazon.*":         {"#dealPrice"},
+	})
+
+	tests := []struct {
+		url       string
+		name      string
+		selectors []string
+	}{
+		{"https://www.example.com/kettle", "example.com", []string{".price"}},
+		// The more specific pattern wins.
+		{"https://shop.example.com/kettle", "shop.example.com", []string{".price-now"}},
+		// A built-in adapter keeps its name and tries its selectors after.
+		{"https://www.amazon.co.uk/dp/B0BSHF7WHW", "amazon", append([]string{"#dealPrice"}, amazon{}.PriceSelectors()...)},
+		{"https://www.bestbuy.com/site/6505727.p", "bestbuy", bestBuy{}.PriceSelectors()},
+	}
+	for _, test := range tests {
+		a := For(mustParseURL(t, test.url))
+		if a == nil {
+			t.Errorf("%s: Expected an adapter", test.url)
+			continue
+		}
+		if a.Name() != test.name || strings.Join(a.PriceSelectors(), "|") != strings.Join(test.selectors, "|") {
+			t.Errorf("%s: Expected %s with %q, got %s with %q", test.url, test.name, test.selectors, a.Name(), a.PriceSelectors())
+		}
+	}
+	if a := For(mustParseURL(t, "https://www.example.org/kettle")); a != nil {
+		t.Errorf("Expected no adapter for an unconfigured host, got %s", a.Name())
+	}
+
+	// The built-in adapter still recognizes Amazon's robot check.
+	robot, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><head><title>Robot Check</title></head><body><form action="/errors/validateCaptcha"></form></body></html>`))
+	if err != nil {
+		t.Fatalf("Failed to parse page: %v", err)
+	}
+	if c, ok := For(mustParseURL(t, "https://www.amazon.com/dp/B07XJ8C8F5")).(Challenger); !ok || !c.Challenged(robot) {
+		t.Error("Expected the robot check to be recognized with configured selectors")
+	}
+}
@@ -0,0 +1,28 @@
+package adapters
+
+import "github.com/PuerkitoBio/goquery"
+
+// target handles target.com. Its product pages render the price with a script
+// after the page loads, so over plain HTTP only the browser fallback finds it.
+type target struct{}
+
+func init() { Register("target.com", target{}) }
+
+func (target) Name() string { return "target" }
+
+// PriceSelectors read the current price; the price before a sale is
+// product-regular-price.
+func (target) PriceSelectors() []string {
+	return []string{`[data-test="product-price"]`}
+}
+
+// Available is false on pages that show the sold out block in place of the
+// fulfillment options.
+func (target) Available(doc *goquery.Document) bool {
+	return doc.Find(`[data-test="soldOutBlock"], [data-test="outOfStockMessage"]`).Length() == 0
+}
+
+// Steps wait for the price to be rendered.
+func (target) Steps() []Step {
+	return []Step{{WaitFor: `[data-test="product-price"]`}}
+}
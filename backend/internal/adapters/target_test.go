@@ -0,0 +1,11 @@
+package adapters
+
+import "testing"
+
+func TestTarget(t *testing.T) {
+	checkFixtures(t, target{}, []fixtureCase{
+		// The sale price, not the regular one or a recommendation.
+		{"product.html", "$35.00", true},
+		{"sold_out.html", "$49.99", false},
+	})
+}
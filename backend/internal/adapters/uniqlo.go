@@ -0,0 +1,36 @@
+package adapters
+
+import "github.com/PuerkitoBio/goquery"
+
+// uniqlo handles uniqlo.com, a single-page app whose price element shows the
+// promotional price and the original one struck through while on sale.
+type uniqlo struct{}
+
+func init() { Register("uniqlo.com", uniqlo{}) }
+
+func (uniqlo) Name() string { return "uniqlo" }
+
+// PriceSelectors read the promotional price first, then any price that is
+// not struck through.
+func (uniqlo) PriceSelectors() []string {
+	return []string{
+		"p.fr-ec-price-text--color-promotional",
+		".fr-ec-price p.fr-ec-price-text:not(.fr-ec-price-text--strike)",
+		"p.fr-ec-price-text",
+	}
+}
+
+// Available is false when the add to cart button is disabled, as it is for a
+// color and size that are sold out online.
+func (uniqlo) Available(doc *goquery.Document) bool {
+	return doc.Find("#add-to-cart-button[disabled]").Length() == 0
+}
+
+// Steps accept the cookie banner, which covers the page outside the US, and
+// wait for the price to be rendered.
+func (uniqlo) Steps() []Step {
+	return []Step{
+		{Click: "#onetrust-accept-btn-handler"},
+		{WaitFor: "p.fr-ec-price-text"},
+	}
+}
@@ -0,0 +1,11 @@
+package adapters
+
+import "testing"
+
+func TestUniqlo(t *testing.T) {
+	checkFixtures(t, uniqlo{}, []fixtureCase{
+		// The promotional price, not the struck through one before it.
+		{"sale.html", "$49.90", true},
+		{"sold_out.html", "$14.90", false},
+	})
+}
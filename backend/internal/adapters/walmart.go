@@ -0,0 +1,34 @@
+package adapters
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// walmart handles walmart.com, whose product pages list prices of other
+// sellers and of related products next to the one on offer.
+type walmart struct{}
+
+func init() { Register("walmart.com", walmart{}) }
+
+func (walmart) Name() string { return "walmart" }
+
+// PriceSelectors read the price in the buy box ("Now $24.88" on sale) before
+// any other element marked up as a price.
+func (walmart) PriceSelectors() []string {
+	return []string{
+		`[data-testid="price-wrap"] [itemprop="price"]`,
+		`span[data-seo-id="hero-price"]`,
+		`span[itemprop="price"]`,
+	}
+}
+
+// Available reads the availability the page's embedded product data gives,
+// since the sold-out banner is rendered by script.
+func (walmart) Available(doc *goquery.Document) bool {
+	data := doc.Find("script#__NEXT_DATA__").Text()
+	return !strings.Contains(data, `"availabilityStatus":"OUT_OF_STOCK"`)
+}
+
+func (walmart) Steps() []Step { return nil }
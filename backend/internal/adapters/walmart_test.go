@@ -0,0 +1,11 @@
+package adapters
+
+import "testing"
+
+func TestWalmart(t *testing.T) {
+	checkFixtures(t, walmart{}, []fixtureCase{
+		// The buy box, not another seller's offer.
+		{"product.html", "Now $24.88", true},
+		{"out_of_stock.html", "$199.00", false},
+	})
+}
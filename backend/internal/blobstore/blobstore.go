@@ -0,0 +1,44 @@
+// Package blobstore keeps files too large for the database, such as the
+// screenshots of failed scrapes, in a directory (Dir) or an S3-compatible
+// bucket (S3: AWS S3, MinIO, Cloudflare R2...). Keys are slash-separated
+// paths such as "screenshots/item-1/1718000000.png".
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by Get for a key that holds nothing.
+	ErrNotFound = errors.New("blobstore: not found")
+	// ErrNoURL is returned by URL when the store cannot hand out links, in
+	// which case the caller serves the bytes itself.
+	ErrNoURL = errors.New("blobstore: store has no URLs")
+)
+
+// Store keeps blobs under keys.
+type Store interface {
+	// Put stores data under key, replacing what was there.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns what is stored under key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes key. Deleting a key that holds nothing is no error.
+	Delete(ctx context.Context, key string) error
+	// URL returns a link that lets whoever has it download key for ttl,
+	// or ErrNoURL.
+	URL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// checkKey rejects keys that are not clean relative paths, which could
+// otherwise reach outside a Dir.
+func checkKey(key string) error {
+	if key == "" || path.Clean(key) != key || strings.HasPrefix(key, "/") || key == ".." || strings.HasPrefix(key, "../") || strings.Contains(key, `\`) {
+		return fmt.Errorf("blobstore: invalid key %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDir_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	var store Store = Dir(dir)
+
+	if err := store.Put(ctx, "screenshots/item-1/1.png", []byte("png-bytes"), "image/png"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "screenshots", "item-1", "1.png")); err != nil {
+		t.Errorf("Expected the blob in a file under its key: %v", err)
+	}
+	data, err := store.Get(ctx, "screenshots/item-1/1.png")
+	if err != nil || string(data) != "png-bytes" {
+		t.Errorf("Expected png-bytes, got %q (error: %v)", data, err)
+	}
+	if err := store.Put(ctx, "screenshots/item-1/1.png", []byte("newer"), "image/png"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if data, _ := store.Get(ctx, "screenshots/item-1/1.png"); string(data) != "newer" {
+		t.Errorf("Expected Put to replace the blob, got %q", data)
+	}
+
+	if err := store.Delete(ctx, "screenshots/item-1/1.png"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "screenshots/item-1/1.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+	}
+	if err := store.Delete(ctx, "screenshots/item-1/1.png"); err != nil {
+		t.Errorf("Expected deleting a missing key to succeed, got %v", err)
+	}
+	if _, err := store.URL(ctx, "screenshots/item-1/1.png", time.Minute); !errors.Is(err, ErrNoURL) {
+		t.Errorf("Expected ErrNoURL, got %v", err)
+	}
+}
+
+func TestDir_InvalidKeys(t *testing.T) {
+	store := Dir(t.TempDir())
+	for _, key := range []string{"", "/etc/passwd", "../outside", "a/../../b", "a//b", "a/", `a\b`} {
+		if err := store.Put(context.Background(), key, []byte("x"), ""); err == nil {
+			t.Errorf("Expected an error for key %q", key)
+		}
+	}
+}
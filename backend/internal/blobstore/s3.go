@@ -0,0 +1,304 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRegion is signed for when S3Config has none. MinIO accepts it
+	// unless configured otherwise; R2 wants "auto".
+	DefaultRegion = "us-east-1"
+	// requestTimeout bounds each request to the service.
+	requestTimeout = 30 * time.Second
+	// maxURLTTL is the longest a presigned URL may be valid for.
+	maxURLTTL = 7 * 24 * time.Hour
+
+	signAlgorithm   = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Config locates a bucket of an S3-compatible service.
+type S3Config struct {
+	// Endpoint is the service's base URL, such as
+	// https://s3.eu-west-1.amazonaws.com, http://minio:9000 or
+	// https://<account>.r2.cloudflarestorage.com. Buckets are addressed by
+	// path, which every such service supports.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3 is a Store keeping blobs as objects of a bucket, requested with
+// Signature Version 4. Its URLs are presigned GETs.
+type S3 struct {
+	endpoint *url.URL
+	bucket   string
+	signer   signer
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3 checks cfg and returns a Store for its bucket. It makes no request.
+func NewS3(cfg S3Config) (*S3, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("blobstore: endpoint %q is not an http(s) URL", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || strings.Contains(cfg.Bucket, "/") {
+		return nil, fmt.Errorf("blobstore: invalid bucket %q", cfg.Bucket)
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("blobstore: S3 needs an access key ID and secret access key")
+	}
+	if cfg.Region == "" {
+		cfg.Region = DefaultRegion
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/")
+	endpoint.RawPath, endpoint.RawQuery, endpoint.Fragment = "", "", ""
+	return &S3{
+		endpoint: endpoint,
+		bucket:   cfg.Bucket,
+		signer:   signer{accessKeyID: cfg.AccessKeyID, secretAccessKey: cfg.SecretAccessKey, region: cfg.Region},
+		client:   &http.Client{Timeout: requestTimeout},
+		now:      time.Now,
+	}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, data, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return responseError(resp, key)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode/100 != 2:
+		return nil, responseError(resp, key)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return responseError(resp, key)
+	}
+	return nil
+}
+
+// URL presigns a GET of key, valid for ttl up to the seven days S3 allows.
+func (s *S3) URL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	ttl = min(max(ttl, time.Second), maxURLTTL)
+	return s.signer.presign(http.MethodGet, s.objectURL(key), s.now(), ttl), nil
+}
+
+// objectURL is where key's object is, with its path escaped as signed.
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path += "/" + s.bucket + "/" + key
+	u.RawPath = awsEscape(u.Path, false)
+	return &u
+}
+
+// do sends a signed request for key's object.
+func (s *S3) do(ctx context.Context, method, key string, body []byte, header http.Header) (*http.Response, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL = s.objectURL(key)
+	req.Host = req.URL.Host
+	req.ContentLength = int64(len(body))
+	if len(body) == 0 {
+		req.Body = http.NoBody
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	sum := sha256.Sum256(body)
+	s.signer.sign(req, hex.EncodeToString(sum[:]), s.now())
+	return s.client.Do(req)
+}
+
+// responseError describes a failed request by its status and the start of
+// the service's error document.
+func responseError(resp *http.Response, key string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("blobstore: %s %s: %s: %s", resp.Request.Method, key, resp.Status, bytes.TrimSpace(body))
+}
+
+// signer signs requests with AWS Signature Version 4 for S3.
+type signer struct {
+	accessKeyID     string
+	secretAccessKey string
+	region          string
+}
+
+// sign adds the Authorization header to r, whose body hashes to
+// payloadHash, signing its host, Content-Type, Range and x-amz-* headers.
+func (s signer) sign(r *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	r.Header.Set("X-Amz-Date", t.Format(amzDateFormat))
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range r.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || name == "range" || strings.HasPrefix(name, "x-amz-") {
+			trimmed := make([]string, len(values))
+			for i, v := range values {
+				trimmed[i] = strings.Join(strings.Fields(v), " ")
+			}
+			headers[name] = strings.Join(trimmed, ",")
+		}
+	}
+	signedHeaders, canonicalHeaders := canonicalHeaders(headers)
+	scope := s.scope(t)
+	signature := s.signature(t, scope, strings.Join([]string{
+		r.Method, canonicalURI(r.URL), canonicalQuery(r.URL.Query()), canonicalHeaders, signedHeaders, payloadHash,
+	}, "\n"))
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", signAlgorithm, s.accessKeyID, scope, signedHeaders, signature))
+}
+
+// presign returns u with the query parameters that let anyone make a
+// method request of it until expires has passed from t.
+func (s signer) presign(method string, u *url.URL, t time.Time, expires time.Duration) string {
+	t = t.UTC()
+	scope := s.scope(t)
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", signAlgorithm)
+	query.Set("X-Amz-Credential", s.accessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", t.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+	signedHeaders, canonicalHeaders := canonicalHeaders(map[string]string{"host": u.Host})
+	signature := s.signature(t, scope, strings.Join([]string{
+		method, canonicalURI(u), canonicalQuery(query), canonicalHeaders, signedHeaders, unsignedPayload,
+	}, "\n"))
+
+	signed := *u
+	signed.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+	return signed.String()
+}
+
+// scope is the credential scope of requests signed at t.
+func (s signer) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// signature signs canonicalRequest with the key derived for t's day.
+func (s signer) signature(t time.Time, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := signAlgorithm + "\n" + t.Format(amzDateFormat) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalHeaders returns the signed header names, sorted and joined with
+// ";", and their "name:value" lines.
+func canonicalHeaders(headers map[string]string) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines strings.Builder
+	for _, name := range names {
+		lines.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	return strings.Join(names, ";"), lines.String()
+}
+
+func canonicalURI(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+	return awsEscape(u.Path, false)
+}
+
+// canonicalQuery sorts and escapes query the way it is signed.
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, awsEscape(name, true)+"="+awsEscape(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes every byte of s but the unreserved characters
+// and, unless encodeSlash, "/".
+func awsEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
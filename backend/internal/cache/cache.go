@@ -0,0 +1,131 @@
+// Package cache provides a small TTL cache for hot read endpoints, kept in
+// process memory or, for several replicas, in a shared Store.
+//
+// Concurrent misses for the same key are de-duplicated: only one caller runs
+// the loader while the others wait for its result.
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+type call[V any] struct {
+	wg    sync.WaitGroup
+	value V
+	err   error
+}
+
+// Cache is a map of values with per-entry expiry. A Cache with a non-positive
+// TTL is disabled and simply calls the loader every time.
+type Cache[V any] struct {
+	ttl time.Duration
+	now func() time.Time
+
+	// store, when set, holds the entries instead of entries, under
+	// namespace (see Share).
+	store     Store
+	namespace string
+
+	mu      sync.Mutex
+	entries map[string]entry[V]
+	calls   map[string]*call[V]
+}
+
+// New returns a cache whose entries live for ttl. Pass 0 to disable caching.
+func New[V any](ttl time.Duration) *Cache[V] {
+	return &Cache[V]{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]entry[V]),
+		calls:   make(map[string]*call[V]),
+	}
+}
+
+// Enabled reports whether the cache stores anything.
+func (c *Cache[V]) Enabled() bool {
+	return c != nil && c.ttl > 0
+}
+
+// GetOrLoad returns the cached value for key, calling load on a miss. Errors
+// from load are returned to every waiting caller but never cached.
+func (c *Cache[V]) GetOrLoad(key string, load func() (V, error)) (V, error) {
+	if !c.Enabled() {
+		return load()
+	}
+
+	if c.store != nil {
+		if v, ok := c.getShared(key); ok {
+			return v, nil
+		}
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		if c.now().Before(e.expiresAt) {
+			c.mu.Unlock()
+			return e.value, nil
+		}
+		delete(c.entries, key)
+	}
+	if inflight, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		inflight.wg.Wait()
+		return inflight.value, inflight.err
+	}
+
+	cl := &call[V]{}
+	cl.wg.Add(1)
+	c.calls[key] = cl
+	c.mu.Unlock()
+
+	cl.value, cl.err = load()
+
+	c.mu.Lock()
+	// An invalidation during the load removes the call, in which case the
+	// (possibly stale) result is handed to waiters but not stored.
+	keep := c.calls[key] == cl && cl.err == nil
+	if c.calls[key] == cl {
+		delete(c.calls, key)
+	}
+	if keep && c.store == nil {
+		c.entries[key] = entry[V]{value: cl.value, expiresAt: c.now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	if keep && c.store != nil {
+		c.setShared(key, cl.value)
+	}
+	cl.wg.Done()
+
+	return cl.value, cl.err
+}
+
+// InvalidatePrefix drops every entry whose key starts with prefix.
+func (c *Cache[V]) InvalidatePrefix(prefix string) {
+	if !c.Enabled() {
+		return
+	}
+
+	if c.store != nil {
+		c.deleteShared(prefix)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+	for key := range c.calls {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.calls, key)
+		}
+	}
+}
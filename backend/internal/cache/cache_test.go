@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad_HitAndExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New[int](10 * time.Second)
+	c.now = func() time.Time { return now }
+
+	loads := 0
+	load := func() (int, error) {
+		loads++
+		return loads, nil
+	}
+
+	if v, _ := c.GetOrLoad("k", load); v != 1 {
+		t.Fatalf("Expected 1, got %d", v)
+	}
+	now = now.Add(9 * time.Second)
+	if v, _ := c.GetOrLoad("k", load); v != 1 {
+		t.Errorf("Expected cached 1, got %d", v)
+	}
+	now = now.Add(2 * time.Second)
+	if v, _ := c.GetOrLoad("k", load); v != 2 {
+		t.Errorf("Expected reload after expiry, got %d", v)
+	}
+}
+
+func TestGetOrLoad_ErrorsNotCached(t *testing.T) {
+	c := New[int](time.Minute)
+
+	if _, err := c.GetOrLoad("k", func() (int, error) { return 0, errors.New("boom") }); err == nil {
+		t.Fatal("Expected error")
+	}
+	if v, err := c.GetOrLoad("k", func() (int, error) { return 7, nil }); err != nil || v != 7 {
+		t.Errorf("Expected 7 after failed load, got %d, %v", v, err)
+	}
+}
+
+func TestGetOrLoad_DeduplicatesConcurrentMisses(t *testing.T) {
+	c := New[int](time.Minute)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func() (int, error) {
+		loads.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	results := make([]int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = c.GetOrLoad("k", load)
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("Expected 1 load, got %d", got)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("Caller %d got %d", i, v)
+		}
+	}
+}
+
+func TestInvalidatePrefix(t *testing.T) {
+	c := New[string](time.Minute)
+	c.GetOrLoad("user-1|items", func() (string, error) { return "a", nil })
+	c.GetOrLoad("user-2|items", func() (string, error) { return "b", nil })
+
+	c.InvalidatePrefix("user-1|")
+
+	if v, _ := c.GetOrLoad("user-1|items", func() (string, error) { return "fresh", nil }); v != "fresh" {
+		t.Errorf("Expected invalidated entry to reload, got %q", v)
+	}
+	if v, _ := c.GetOrLoad("user-2|items", func() (string, error) { return "fresh", nil }); v != "b" {
+		t.Errorf("Expected other user's entry to survive, got %q", v)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	c := New[int](0)
+	loads := 0
+	for i := 0; i < 3; i++ {
+		c.GetOrLoad("k", func() (int, error) { loads++; return loads, nil })
+	}
+	if loads != 3 {
+		t.Errorf("Expected disabled cache to always load, got %d loads", loads)
+	}
+}
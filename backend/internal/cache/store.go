@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// Store keeps cache entries outside the process, such as in Redis, so that
+// every replica sees the same entries and invalidations.
+type Store interface {
+	// Get returns the value stored under key, and false if there is none.
+	Get(key string) ([]byte, bool, error)
+	// Set stores value under key for ttl.
+	Set(key string, value []byte, ttl time.Duration) error
+	// DeletePrefix deletes every key starting with prefix.
+	DeletePrefix(prefix string) error
+}
+
+// Share makes c keep its entries in store, JSON-encoded under namespace plus
+// their keys, instead of in process memory. Concurrent misses are still only
+// de-duplicated within the process. Entries are best effort: a store that
+// fails makes c call the loader. It returns c.
+func (c *Cache[V]) Share(store Store, namespace string) *Cache[V] {
+	c.store, c.namespace = store, namespace
+	return c
+}
+
+// getShared returns key's value from the store.
+func (c *Cache[V]) getShared(key string) (V, bool) {
+	var v V
+	data, ok, err := c.store.Get(c.namespace + key)
+	if err != nil {
+		slog.Warn("Failed to read shared cache", "key", c.namespace+key, "error", err)
+		return v, false
+	}
+	if !ok {
+		return v, false
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		slog.Warn("Failed to decode shared cache entry", "key", c.namespace+key, "error", err)
+		return v, false
+	}
+	return v, true
+}
+
+// setShared stores v under key for the cache's TTL.
+func (c *Cache[V]) setShared(key string, v V) {
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = c.store.Set(c.namespace+key, data, c.ttl)
+	}
+	if err != nil {
+		slog.Warn("Failed to write shared cache", "key", c.namespace+key, "error", err)
+	}
+}
+
+// deleteShared drops the stored entries whose keys start with prefix.
+func (c *Cache[V]) deleteShared(prefix string) {
+	if err := c.store.DeletePrefix(c.namespace + prefix); err != nil {
+		slog.Warn("Failed to invalidate shared cache", "prefix", c.namespace+prefix, "error", err)
+	}
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mapStore is a Store in a map, shared by the caches of a test the way Redis
+// is shared by replicas.
+type mapStore struct {
+	values map[string][]byte
+	err    error
+}
+
+func (m *mapStore) Get(key string) ([]byte, bool, error) {
+	v, ok := m.values[key]
+	return v, ok, m.err
+}
+
+func (m *mapStore) Set(key string, value []byte, ttl time.Duration) error {
+	if m.err == nil {
+		m.values[key] = value
+	}
+	return m.err
+}
+
+func (m *mapStore) DeletePrefix(prefix string) error {
+	for key := range m.values {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.values, key)
+		}
+	}
+	return m.err
+}
+
+type cachedValue struct {
+	Name string
+	Body []byte
+}
+
+func TestShare_EntriesAndInvalidationsAreShared(t *testing.T) {
+	store := &mapStore{values: map[string][]byte{}}
+	a := New[cachedValue](time.Minute).Share(store, "test:")
+	b := New[cachedValue](time.Minute).Share(store, "test:")
+
+	loads := 0
+	load := func() (cachedValue, error) {
+		loads++
+		return cachedValue{Name: "widget", Body: []byte{1, 2}}, nil
+	}
+	if _, err := a.GetOrLoad("user-1|items", load); err != nil {
+		t.Fatalf("GetOrLoad failed: %v", err)
+	}
+	if _, ok := store.values["test:user-1|items"]; !ok {
+		t.Fatalf("Expected the entry under the namespace, got %v", store.values)
+	}
+	v, err := b.GetOrLoad("user-1|items", load)
+	if err != nil || loads != 1 || v.Name != "widget" || len(v.Body) != 2 {
+		t.Errorf("Expected the other cache to read the stored entry, got %+v after %d loads (error: %v)", v, loads, err)
+	}
+
+	b.InvalidatePrefix("user-1|")
+	if _, err := a.GetOrLoad("user-1|items", load); err != nil || loads != 2 {
+		t.Errorf("Expected the invalidation to reach the other cache, got %d loads (error: %v)", loads, err)
+	}
+}
+
+func TestShare_StoreErrorsFallBackToLoading(t *testing.T) {
+	store := &mapStore{values: map[string][]byte{}, err: errors.New("connection refused")}
+	c := New[cachedValue](time.Minute).Share(store, "test:")
+
+	loads := 0
+	for i := 0; i < 2; i++ {
+		v, err := c.GetOrLoad("k", func() (cachedValue, error) {
+			loads++
+			return cachedValue{Name: "widget"}, nil
+		})
+		if err != nil || v.Name != "widget" {
+			t.Errorf("Expected the loaded value, got %+v (error: %v)", v, err)
+		}
+	}
+	if loads != 2 {
+		t.Errorf("Expected every call to load, got %d loads", loads)
+	}
+}
@@ -0,0 +1,618 @@
+// Package config reads the environment once at startup into a Config. Every
+// variable is checked up front and all problems are reported together, so a
+// misconfigured deployment fails immediately instead of on first use.
+package config
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"price-track-backend/internal/adapters"
+	"price-track-backend/internal/blobstore"
+	"price-track-backend/internal/email"
+	"price-track-backend/internal/realtime"
+	"price-track-backend/internal/scheduler"
+	"price-track-backend/internal/secretbox"
+)
+
+const (
+	// DefaultQueryTimeout bounds the database work done for a single request.
+	DefaultQueryTimeout = 5 * time.Second
+	// DefaultCacheTTL is how long hot read responses are served from memory.
+	DefaultCacheTTL = 15 * time.Second
+	// DefaultSchedulerInterval is how often the scraper job is expected to run.
+	DefaultSchedulerInterval = time.Hour
+)
+
+// Config is the validated configuration of the API server and the scraper job.
+type Config struct {
+	// DatabaseURL is the Postgres connection string (DATABASE_URL).
+	DatabaseURL string
+	// JWTSecret verifies Supabase session tokens (SUPABASE_JWT_SECRET). Only
+	// the API server needs it.
+	JWTSecret string
+	// AdminUserIDs may use the /admin endpoints (ADMIN_USER_IDS, comma
+	// separated).
+	AdminUserIDs []string
+	// TrustedProxies are the reverse proxies, such as the nginx in front of
+	// the API, whose X-Real-IP and X-Forwarded-For headers name the client
+	// for per-IP rate limits (TRUSTED_PROXIES, comma-separated addresses or
+	// CIDR ranges). Forwarding headers from anyone else are ignored.
+	TrustedProxies []netip.Prefix
+	// QueryTimeout bounds the database work of one request (DB_QUERY_TIMEOUT).
+	QueryTimeout time.Duration
+	// CacheTTL is the response cache lifetime (CACHE_TTL); zero disables the
+	// cache, as does CACHE_DISABLED.
+	CacheTTL time.Duration
+	// ItemsQuotaDefault is the per-user item limit (ITEMS_QUOTA_DEFAULT);
+	// zero means unlimited.
+	ItemsQuotaDefault int
+	// SchedulerInterval is how often the scraper job runs (SCHEDULER_INTERVAL).
+	// The API server reports the scheduler unhealthy after twice that.
+	SchedulerInterval time.Duration
+	// ScraperDaemon keeps the scraper job running, checking prices every
+	// SchedulerInterval, instead of checking once and exiting for cron
+	// (SCRAPER_MODE=daemon).
+	ScraperDaemon bool
+	// ScraperListenAddr is where the scraper daemon serves /health and
+	// /metrics (SCRAPER_LISTEN_ADDR, e.g. ":9090"). Empty serves nothing.
+	ScraperListenAddr string
+	// SMTP is the mail relay (SMTP_ADDR, SMTP_USERNAME, SMTP_PASSWORD,
+	// EMAIL_FROM). Email is off when SMTP_ADDR is unset.
+	SMTP email.SMTPConfig
+	// EmailTokenSecret signs email verification and unsubscribe links, and
+	// check hook tokens (EMAIL_TOKEN_SECRET, falling back to
+	// SUPABASE_JWT_SECRET).
+	EmailTokenSecret string
+	// EmailTemplates render the HTML part of notification emails: the
+	// built-in templates, with those in EMAIL_TEMPLATE_DIR in their place.
+	// Nil when EMAIL_TEMPLATE_DIR is unset, meaning the built-in ones.
+	EmailTemplates *email.Templates
+	// PublicURL is where the API is reachable from a mail client
+	// (PUBLIC_URL). Unsubscribe links point at it.
+	PublicURL string
+	// Realtime is where price drop notifications are broadcast
+	// (SUPABASE_URL, SUPABASE_SERVICE_ROLE_KEY). Broadcasting is off unless
+	// SUPABASE_REALTIME_ENABLED is set, and then both are required.
+	Realtime realtime.Config
+	// RedisURL is the Redis server the API server's replicas share their
+	// response and settings caches, rate limits and stream wake-ups through
+	// (REDIS_URL, redis:// or rediss://). Empty keeps them in process, for a
+	// single replica.
+	RedisURL string
+	// ListenAddr is where the API server listens (LISTEN_ADDR): ":8081", or
+	// ":443" with AUTOCERT_DOMAINS, unless set.
+	ListenAddr string
+	// TLS serves the API over HTTPS. Only the API server reads it.
+	TLS TLSConfig
+	// TrendingDisabled turns off the anonymous /trending endpoint
+	// (TRENDING_DISABLED), for operators who consider even aggregate data
+	// sensitive.
+	TrendingDisabled bool
+	// APIDocs serves a Swagger UI for /openapi.json at /docs (API_DOCS).
+	APIDocs bool
+	// AutoMigrate makes the API server create the indexes its queries rely
+	// on when they are missing, instead of only warning (AUTO_MIGRATE).
+	AutoMigrate bool
+	// Cookies encrypts the cookies users store with items
+	// (COOKIE_ENCRYPTION_KEY, 32 base64-encoded bytes). Nil when unset, in
+	// which case items cannot have cookies.
+	Cookies *secretbox.Box
+	// SiteSelectors are price selectors for stores, by host pattern, tried
+	// on items of those stores before the items' own (SITE_SELECTORS, a
+	// JSON object such as {"shop.example.com": [".price"]}). They extend the
+	// built-in site adapters.
+	SiteSelectors adapters.Selectors
+	// Blobs keeps failure screenshots: a directory (BLOB_STORE_DIR, or
+	// DEBUG_SCREENSHOT_DIR as before) or an S3-compatible bucket (S3_BUCKET,
+	// S3_ENDPOINT, S3_REGION, S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY). Nil
+	// when neither is set, in which case screenshots are dropped.
+	Blobs blobstore.Store
+	// Scheduler holds MAX_ITEM_AGE, SCRAPER_CONCURRENCY,
+	// PRICE_OUTLIER_FACTOR, ERROR_BUDGET, ERROR_BUDGET_WINDOW,
+	// NOTIFICATION_SILENCE_WINDOW, SCRAPE_QUOTA_MONTHLY, DISCONTINUE_AFTER,
+	// SCRAPER_PROXY_URL, SCRAPE_PROFILE, SCRAPE_BLOCK_RESOURCES,
+	// SCRAPE_ITEM_TIMEOUT, SCRAPE_HTTP_TIMEOUT, PLAYWRIGHT_DISABLED,
+	// JSONLD_FALLBACK_DISABLED, SCRAPE_ALLOW_PRIVATE_ADDRESSES,
+	// FAILURE_BACKOFF_MAX, SEVERITY_NOTICE_PERCENT, SEVERITY_ALERT_PERCENT,
+	// DROP_AVERAGE_WINDOW,
+	// DROP_AVERAGE_PERCENT, UNPARSEABLE_BASELINE, CURRENCY_CHANGE, the cookie
+	// key, the blob store and SchedulerInterval,
+	// which failing items back off from.
+	Scheduler scheduler.Config
+}
+
+// DefaultListenAddr is where the API server listens without LISTEN_ADDR,
+// unless it gets its certificates from Let's Encrypt.
+const DefaultListenAddr = ":8081"
+
+// TLSConfig is how the API server gets its certificate: from files
+// (TLS_CERT_FILE and TLS_KEY_FILE, PEM encoded) or from Let's Encrypt for
+// AUTOCERT_DOMAINS (comma separated), kept in AUTOCERT_CACHE_DIR (default
+// "autocert-cache"). With neither, the API is served over plain HTTP, e.g.
+// behind a proxy that terminates TLS.
+type TLSConfig struct {
+	CertFile         string
+	KeyFile          string
+	AutocertDomains  []string
+	AutocertCacheDir string
+}
+
+// Enabled reports whether the API is served over HTTPS.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || len(t.AutocertDomains) > 0
+}
+
+// LoadAPI reads the configuration of the API server, which needs a JWT secret.
+func LoadAPI(getenv func(string) string) (Config, error) {
+	return load(getenv, true)
+}
+
+// LoadScraper reads the configuration of the scraper job and other tools that
+// only talk to the database.
+func LoadScraper(getenv func(string) string) (Config, error) {
+	return load(getenv, false)
+}
+
+func load(getenv func(string) string, needJWT bool) (Config, error) {
+	var errs []error
+	invalid := func(name, value, want string) {
+		errs = append(errs, fmt.Errorf("%s=%q: must be %s", name, value, want))
+	}
+
+	c := Config{
+		DatabaseURL:       getenv("DATABASE_URL"),
+		JWTSecret:         getenv("SUPABASE_JWT_SECRET"),
+		QueryTimeout:      DefaultQueryTimeout,
+		CacheTTL:          DefaultCacheTTL,
+		SchedulerInterval: DefaultSchedulerInterval,
+		Scheduler:         scheduler.DefaultConfig(),
+	}
+	if c.DatabaseURL == "" {
+		errs = append(errs, errors.New("DATABASE_URL is not set"))
+	}
+	if needJWT && c.JWTSecret == "" {
+		errs = append(errs, errors.New("SUPABASE_JWT_SECRET is not set"))
+	}
+
+	c.SMTP = email.SMTPConfig{
+		Addr:     getenv("SMTP_ADDR"),
+		Username: getenv("SMTP_USERNAME"),
+		Password: getenv("SMTP_PASSWORD"),
+		From:     getenv("EMAIL_FROM"),
+	}
+	if c.SMTP.Addr != "" {
+		if _, err := mail.ParseAddress(c.SMTP.From); err != nil {
+			invalid("EMAIL_FROM", c.SMTP.From, "an email address when SMTP_ADDR is set")
+		}
+	}
+	if v := getenv("EMAIL_TEMPLATE_DIR"); v != "" {
+		templates, err := email.LoadTemplates(v)
+		if err != nil {
+			invalid("EMAIL_TEMPLATE_DIR", v, fmt.Sprintf("a directory of email templates (%v)", err))
+		} else {
+			c.EmailTemplates = templates
+		}
+	}
+	c.EmailTokenSecret = getenv("EMAIL_TOKEN_SECRET")
+	if c.EmailTokenSecret == "" {
+		c.EmailTokenSecret = c.JWTSecret
+	}
+	c.PublicURL = getenv("PUBLIC_URL")
+	if c.SMTP.Addr != "" {
+		// The scraper job has no JWT secret to fall back on, and every email
+		// it sends carries a signed unsubscribe link.
+		if c.EmailTokenSecret == "" {
+			errs = append(errs, errors.New("EMAIL_TOKEN_SECRET is not set (required when SMTP_ADDR is set)"))
+		}
+		if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("PUBLIC_URL", c.PublicURL, "an http(s) URL when SMTP_ADDR is set")
+		}
+	}
+
+	if v := getenv("SUPABASE_REALTIME_ENABLED"); v != "" {
+		on, err := strconv.ParseBool(v)
+		switch {
+		case err != nil:
+			invalid("SUPABASE_REALTIME_ENABLED", v, "true or false")
+		case on:
+			c.Realtime = realtime.Config{URL: getenv("SUPABASE_URL"), ServiceKey: getenv("SUPABASE_SERVICE_ROLE_KEY")}
+			if u, err := url.Parse(c.Realtime.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				invalid("SUPABASE_URL", c.Realtime.URL, "an http(s) URL when SUPABASE_REALTIME_ENABLED is set")
+			}
+			if c.Realtime.ServiceKey == "" {
+				errs = append(errs, errors.New("SUPABASE_SERVICE_ROLE_KEY is not set (required when SUPABASE_REALTIME_ENABLED is set)"))
+			}
+		}
+	}
+
+	c.RedisURL = getenv("REDIS_URL")
+	if c.RedisURL != "" {
+		u, err := url.Parse(c.RedisURL)
+		if err != nil {
+			invalid("REDIS_URL", "(hidden)", "a redis:// or rediss:// URL")
+		} else if (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			invalid("REDIS_URL", u.Redacted(), "a redis:// or rediss:// URL")
+		}
+	}
+
+	// Only the API server serves HTTPS, and the scraper job may well run
+	// where the certificates are not. The certificate is loaded once here so
+	// that a file the server cannot read stops it at startup rather than
+	// failing every handshake.
+	if needJWT {
+		c.TLS = TLSConfig{CertFile: getenv("TLS_CERT_FILE"), KeyFile: getenv("TLS_KEY_FILE"), AutocertCacheDir: getenv("AUTOCERT_CACHE_DIR")}
+		for _, domain := range strings.Split(getenv("AUTOCERT_DOMAINS"), ",") {
+			if domain = strings.ToLower(strings.TrimSpace(domain)); domain == "" {
+				continue
+			}
+			if strings.ContainsAny(domain, ":/*") || !strings.Contains(domain, ".") {
+				invalid("AUTOCERT_DOMAINS", domain, "a comma-separated list of domain names such as api.example.com")
+				continue
+			}
+			c.TLS.AutocertDomains = append(c.TLS.AutocertDomains, domain)
+		}
+		if c.TLS.AutocertCacheDir == "" {
+			c.TLS.AutocertCacheDir = "autocert-cache"
+		}
+		switch {
+		case c.TLS.CertFile == "" && c.TLS.KeyFile == "":
+		case c.TLS.CertFile == "" || c.TLS.KeyFile == "":
+			errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+		case len(c.TLS.AutocertDomains) > 0:
+			errs = append(errs, errors.New("TLS_CERT_FILE and AUTOCERT_DOMAINS are both set; choose one"))
+		default:
+			if _, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile); err != nil {
+				errs = append(errs, fmt.Errorf("TLS_CERT_FILE=%q, TLS_KEY_FILE=%q: must be a readable PEM certificate and its key (%v)", c.TLS.CertFile, c.TLS.KeyFile, err))
+			}
+		}
+	}
+	c.ListenAddr = getenv("LISTEN_ADDR")
+	if c.ListenAddr == "" {
+		c.ListenAddr = DefaultListenAddr
+		if len(c.TLS.AutocertDomains) > 0 {
+			c.ListenAddr = ":443"
+		}
+	}
+
+	for _, id := range strings.Split(getenv("ADMIN_USER_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			c.AdminUserIDs = append(c.AdminUserIDs, id)
+		}
+	}
+
+	for _, v := range strings.Split(getenv("TRUSTED_PROXIES"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			var addr netip.Addr
+			if addr, err = netip.ParseAddr(v); err == nil {
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+		}
+		if err != nil {
+			invalid("TRUSTED_PROXIES", v, "IP addresses or CIDR ranges separated by commas, e.g. 172.16.0.0/12")
+			continue
+		}
+		c.TrustedProxies = append(c.TrustedProxies, prefix.Masked())
+	}
+
+	if v := getenv("DB_QUERY_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			invalid("DB_QUERY_TIMEOUT", v, "a positive duration such as 5s")
+		} else {
+			c.QueryTimeout = d
+		}
+	}
+
+	if v := getenv("CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			invalid("CACHE_TTL", v, "a duration such as 15s, or 0 to disable")
+		} else {
+			c.CacheTTL = d
+		}
+	}
+	if getenv("CACHE_DISABLED") != "" {
+		c.CacheTTL = 0
+	}
+	c.TrendingDisabled = getenv("TRENDING_DISABLED") != ""
+	c.APIDocs = getenv("API_DOCS") != ""
+
+	if v := getenv("AUTO_MIGRATE"); v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			invalid("AUTO_MIGRATE", v, "true or false")
+		} else {
+			c.AutoMigrate = on
+		}
+	}
+
+	if v := getenv("ITEMS_QUOTA_DEFAULT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			invalid("ITEMS_QUOTA_DEFAULT", v, "a non-negative integer")
+		} else {
+			c.ItemsQuotaDefault = n
+		}
+	}
+
+	if v := getenv("SCRAPE_QUOTA_MONTHLY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			invalid("SCRAPE_QUOTA_MONTHLY", v, "a number of checks, or 0 for unlimited")
+		} else {
+			c.Scheduler.ScrapeQuota = n
+		}
+	}
+
+	if v := getenv("SCHEDULER_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			invalid("SCHEDULER_INTERVAL", v, "a positive duration such as 1h")
+		} else {
+			c.SchedulerInterval = d
+		}
+	}
+	c.Scheduler.CheckInterval = c.SchedulerInterval
+
+	switch v := getenv("SCRAPER_MODE"); v {
+	case "", "once":
+	case "daemon":
+		c.ScraperDaemon = true
+	default:
+		invalid("SCRAPER_MODE", v, "once or daemon")
+	}
+	c.ScraperListenAddr = getenv("SCRAPER_LISTEN_ADDR")
+
+	if v := getenv("MAX_ITEM_AGE"); v != "" {
+		d, err := ParseAge(v)
+		if err != nil {
+			invalid("MAX_ITEM_AGE", v, `a duration such as "720h" or "90d"`)
+		} else {
+			c.Scheduler.MaxItemAge = d
+		}
+	}
+
+	if v := getenv("SCRAPER_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			invalid("SCRAPER_CONCURRENCY", v, "a positive integer")
+		} else {
+			c.Scheduler.Concurrency = n
+		}
+	}
+
+	if v := getenv("PRICE_OUTLIER_FACTOR"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || (f != 0 && f <= 1) {
+			invalid("PRICE_OUTLIER_FACTOR", v, "a number above 1, or 0 to disable")
+		} else {
+			c.Scheduler.OutlierFactor = f
+		}
+	}
+
+	if v := getenv("ERROR_BUDGET"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f >= 1 {
+			invalid("ERROR_BUDGET", v, "a ratio below 1 such as 0.5, or 0 to disable")
+		} else {
+			c.Scheduler.ErrorBudget = f
+		}
+	}
+
+	if v := getenv("ERROR_BUDGET_WINDOW"); v != "" {
+		d, err := ParseAge(v)
+		if err != nil || d <= 0 {
+			invalid("ERROR_BUDGET_WINDOW", v, `a positive duration such as "72h" or "7d"`)
+		} else {
+			c.Scheduler.ErrorBudgetWindow = d
+		}
+	}
+
+	if v := getenv("NOTIFICATION_SILENCE_WINDOW"); v != "" {
+		d, err := ParseAge(v)
+		if err != nil {
+			invalid("NOTIFICATION_SILENCE_WINDOW", v, `a duration such as "48h" or "2d", or 0 to disable`)
+		} else {
+			c.Scheduler.SilenceWindow = d
+		}
+	}
+
+	if v := getenv("DISCONTINUE_AFTER"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			invalid("DISCONTINUE_AFTER", v, "a number of checks, or 0 to disable")
+		} else {
+			c.Scheduler.DiscontinueAfter = n
+		}
+	}
+
+	if v := getenv("SCRAPER_PROXY_URL"); v != "" {
+		proxy, err := scheduler.ParseProxyURL(v)
+		if err != nil {
+			invalid("SCRAPER_PROXY_URL", scheduler.RedactProxyURL(v), "an http, https or socks5 URL, with optional user:password credentials")
+		} else {
+			c.Scheduler.Proxy = proxy
+		}
+	}
+
+	if v := getenv("SITE_SELECTORS"); v != "" {
+		table, err := adapters.ParseSelectors(v)
+		if err != nil {
+			invalid("SITE_SELECTORS", v, fmt.Sprintf("a JSON object of host patterns to lists of CSS selectors (%v)", err))
+		} else {
+			c.SiteSelectors = table
+		}
+	}
+
+	dir := getenv("BLOB_STORE_DIR")
+	if dir == "" {
+		dir = getenv("DEBUG_SCREENSHOT_DIR")
+	}
+	if bucket := getenv("S3_BUCKET"); bucket != "" {
+		store, err := blobstore.NewS3(blobstore.S3Config{
+			Endpoint:        getenv("S3_ENDPOINT"),
+			Region:          getenv("S3_REGION"),
+			Bucket:          bucket,
+			AccessKeyID:     getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: getenv("S3_SECRET_ACCESS_KEY"),
+		})
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("S3_BUCKET=%q: needs S3_ENDPOINT (an http(s) URL), S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY (%v)", bucket, err))
+		case dir != "":
+			errs = append(errs, errors.New("BLOB_STORE_DIR and S3_BUCKET are both set; choose one blob store"))
+		default:
+			c.Blobs = store
+		}
+	} else if dir != "" {
+		c.Blobs = blobstore.Dir(dir)
+	}
+	c.Scheduler.Blobs = c.Blobs
+
+	if v := getenv("COOKIE_ENCRYPTION_KEY"); v != "" {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err == nil {
+			c.Cookies, err = secretbox.New(key)
+		}
+		if err != nil {
+			invalid("COOKIE_ENCRYPTION_KEY", "(hidden)", "32 base64-encoded bytes, e.g. from openssl rand -base64 32")
+		}
+		c.Scheduler.Cookies = c.Cookies
+	}
+
+	if v := getenv("SCRAPE_PROFILE"); v != "" {
+		if !scheduler.ValidScrapeProfile(v) {
+			invalid("SCRAPE_PROFILE", v, "fast or stealth")
+		} else {
+			c.Scheduler.Profile = scheduler.ScrapeProfile(v)
+		}
+	}
+
+	if v := getenv("SCRAPE_BLOCK_RESOURCES"); v != "" {
+		types, err := scheduler.ParseBlockedResources(v)
+		if err != nil {
+			invalid("SCRAPE_BLOCK_RESOURCES", v, "none or resource types separated by commas, e.g. image,font,media")
+		} else {
+			c.Scheduler.BlockResources = types
+		}
+	}
+
+	c.Scheduler.PlaywrightDisabled = getenv("PLAYWRIGHT_DISABLED") != ""
+	c.Scheduler.JSONLDFallbackDisabled = getenv("JSONLD_FALLBACK_DISABLED") != ""
+	c.Scheduler.PrivateAddressesAllowed = getenv("SCRAPE_ALLOW_PRIVATE_ADDRESSES") != ""
+
+	if v := getenv("SCRAPE_ITEM_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			invalid("SCRAPE_ITEM_TIMEOUT", v, "a positive duration such as 2m")
+		} else {
+			c.Scheduler.ItemTimeout = d
+		}
+	}
+	if v := getenv("SCRAPE_HTTP_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			invalid("SCRAPE_HTTP_TIMEOUT", v, "a positive duration such as 60s")
+		} else {
+			c.Scheduler.ItemHTTPTimeout = d
+		}
+	}
+	if v := getenv("FAILURE_BACKOFF_MAX"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			invalid("FAILURE_BACKOFF_MAX", v, "a duration such as 24h, or 0 to disable")
+		} else {
+			c.Scheduler.BackoffMax = d
+		}
+	}
+
+	if v := getenv("SEVERITY_NOTICE_PERCENT"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 100 {
+			invalid("SEVERITY_NOTICE_PERCENT", v, "a percentage above 0 and at most 100 such as 10")
+		} else {
+			c.Scheduler.Severity.NoticePercent = f
+		}
+	}
+	if v := getenv("SEVERITY_ALERT_PERCENT"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 100 {
+			invalid("SEVERITY_ALERT_PERCENT", v, "a percentage above 0 and at most 100 such as 25")
+		} else {
+			c.Scheduler.Severity.AlertPercent = f
+		}
+	}
+	if c.Scheduler.Severity.AlertPercent < c.Scheduler.Severity.NoticePercent {
+		errs = append(errs, fmt.Errorf("SEVERITY_ALERT_PERCENT (%g) must not be below SEVERITY_NOTICE_PERCENT (%g)", c.Scheduler.Severity.AlertPercent, c.Scheduler.Severity.NoticePercent))
+	}
+
+	if v := getenv("DROP_AVERAGE_WINDOW"); v != "" {
+		d, err := ParseAge(v)
+		if err != nil {
+			invalid("DROP_AVERAGE_WINDOW", v, `a duration such as "72h" or "7d", or 0 to compare with the last price`)
+		} else {
+			c.Scheduler.DropAverage.Window = d
+		}
+	}
+	if v := getenv("DROP_AVERAGE_PERCENT"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f >= 100 {
+			invalid("DROP_AVERAGE_PERCENT", v, "a percentage of at least 0 and below 100 such as 5")
+		} else {
+			c.Scheduler.DropAverage.Percent = f
+		}
+	}
+
+	switch v := getenv("UNPARSEABLE_BASELINE"); v {
+	case "", "adopt":
+	case "skip":
+		c.Scheduler.AdoptBaseline = false
+	default:
+		invalid("UNPARSEABLE_BASELINE", v, "adopt or skip")
+	}
+
+	switch v := getenv("CURRENCY_CHANGE"); v {
+	case "", "notify":
+	case "compare":
+		c.Scheduler.CompareAcrossCurrencies = true
+	default:
+		invalid("CURRENCY_CHANGE", v, "notify or compare")
+	}
+
+	if len(errs) > 0 {
+		return c, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return c, nil
+}
+
+// ParseAge parses a duration such as "720h" or "90d". Empty means disabled.
+func ParseAge(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days: %q", v)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration: %q", v)
+	}
+	return d, nil
+}
@@ -0,0 +1,87 @@
+package email
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// PricePoint is a price an item had from At until the next point.
+type PricePoint struct {
+	At    time.Time
+	Price float64
+}
+
+// The chart's size and the room around the plot for its labels, in pixels.
+const (
+	chartWidth  = 560
+	chartHeight = 160
+	chartLeft   = 64
+	chartRight  = 12
+	chartTop    = 12
+	chartBottom = 28
+)
+
+// PriceChart draws history, oldest first, from from to to as an inline SVG
+// step chart: each price holds until the next one, and the last until to. A
+// point before from is drawn as the price the chart starts at. It returns ""
+// when history is empty.
+func PriceChart(history []PricePoint, from, to time.Time) template.HTML {
+	if len(history) == 0 || !to.After(from) {
+		return ""
+	}
+	lo, hi := history[0].Price, history[0].Price
+	for _, p := range history[1:] {
+		lo, hi = min(lo, p.Price), max(hi, p.Price)
+	}
+	// Leave some room above and below, and give a flat line some height.
+	pad := (hi - lo) / 10
+	if pad == 0 {
+		pad = max(hi/10, 1)
+	}
+	cheapest, dearest := lo, hi
+	lo, hi = max(lo-pad, 0), hi+pad
+
+	plotWidth := float64(chartWidth - chartLeft - chartRight)
+	plotHeight := float64(chartHeight - chartTop - chartBottom)
+	x := func(t time.Time) float64 {
+		if t.Before(from) {
+			t = from
+		}
+		return chartLeft + plotWidth*float64(t.Sub(from))/float64(to.Sub(from))
+	}
+	y := func(price float64) float64 {
+		return chartTop + plotHeight*(hi-price)/(hi-lo)
+	}
+
+	var path strings.Builder
+	fmt.Fprintf(&path, "M%.1f %.1f", x(history[0].At), y(history[0].Price))
+	for _, p := range history[1:] {
+		fmt.Fprintf(&path, " H%.1f V%.1f", x(p.At), y(p.Price))
+	}
+	last := history[len(history)-1]
+	fmt.Fprintf(&path, " H%.1f", x(to))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="Price over the last %d days">`+"\n", chartWidth, chartHeight, chartWidth, chartHeight, int(to.Sub(from).Hours()/24+0.5))
+	// Grid lines mark the dearest and cheapest prices.
+	levels := []float64{dearest}
+	if cheapest != dearest {
+		levels = append(levels, cheapest)
+	}
+	for _, price := range levels {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e5e7eb"/>`+"\n", chartLeft, y(price), chartWidth-chartRight, y(price))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" font-family="sans-serif" font-size="11" fill="#6b7280" text-anchor="end">%.2f</text>`+"\n", chartLeft-6, y(price)+4, price)
+	}
+	for _, label := range []struct {
+		at     time.Time
+		anchor string
+	}{{from, "start"}, {to, "end"}} {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" font-family="sans-serif" font-size="11" fill="#6b7280" text-anchor="%s">%s</text>`+"\n", x(label.at), chartHeight-8, label.anchor, label.at.Format("Jan 2"))
+	}
+	fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="#16a34a" stroke-width="2"/>`+"\n", path.String())
+	fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3.5" fill="#16a34a"/>`+"\n", x(to), y(last.Price))
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
@@ -0,0 +1,216 @@
+// Package email sends notification emails to the address a user verified,
+// which need not be the one they log in with.
+package email
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrNoAddress means the user has not set a notification email.
+	ErrNoAddress = errors.New("no notification email set")
+	// ErrUnverified means the user's notification email is not verified yet.
+	ErrUnverified = errors.New("notification email is not verified")
+	// ErrUnsubscribed means the user turned the channel off.
+	ErrUnsubscribed = errors.New("unsubscribed from this channel")
+)
+
+// Message is a plain-text email, with an optional HTML alternative.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	// HTML, if set, is sent alongside Body for mail clients that show HTML.
+	HTML string
+	// Unsubscribe, if set, is sent as a one-click List-Unsubscribe header
+	// (RFC 8058).
+	Unsubscribe string
+}
+
+// Sender delivers a message.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPConfig is where outgoing mail is relayed. Email is off when Addr is
+// empty.
+type SMTPConfig struct {
+	// Addr is the relay's host:port (SMTP_ADDR).
+	Addr string
+	// Username and Password authenticate with PLAIN auth when set
+	// (SMTP_USERNAME, SMTP_PASSWORD).
+	Username string
+	Password string
+	// From is the sender address (EMAIL_FROM).
+	From string
+}
+
+// NewSender returns a Sender relaying through c, or nil if email is off.
+func NewSender(c SMTPConfig) Sender {
+	if c.Addr == "" {
+		return nil
+	}
+	return smtpSender{c}
+}
+
+type smtpSender struct {
+	SMTPConfig
+}
+
+func (s smtpSender) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := strings.Cut(s.Addr, ":")
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	data, err := s.message(msg)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, data)
+}
+
+// message formats msg for sending. With HTML it is a multipart/alternative
+// message, the plain text first, both parts quoted-printable so that no line
+// is too long for SMTP.
+func (s smtpSender) message(msg Message) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", s.From, msg.To, msg.Subject)
+	if msg.Unsubscribe != "" {
+		fmt.Fprintf(&b, "List-Unsubscribe: <%s>\r\nList-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n", msg.Unsubscribe)
+	}
+	if msg.HTML == "" {
+		fmt.Fprintf(&b, "Content-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n", msg.Body)
+		return b.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "MIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Body},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Notifier sends notification emails. Every one goes to the user's verified
+// address and carries an unsubscribe link for its channel.
+type Notifier struct {
+	db     *sql.DB
+	sender Sender
+	secret []byte
+	// baseURL is where the API is reachable from a mail client, for
+	// unsubscribe links.
+	baseURL   string
+	templates *Templates
+}
+
+// NewNotifier returns a Notifier that renders HTML emails with templates, or
+// the built-in templates when it is nil. It returns nil if sender is nil.
+func NewNotifier(db *sql.DB, sender Sender, secret []byte, baseURL string, templates *Templates) *Notifier {
+	if sender == nil {
+		return nil
+	}
+	if templates == nil {
+		templates = builtinTemplates
+	}
+	return &Notifier{db: db, sender: sender, secret: secret, baseURL: strings.TrimSuffix(baseURL, "/"), templates: templates}
+}
+
+// Templates returns the templates n renders emails with.
+func (n *Notifier) Templates() *Templates {
+	return n.templates
+}
+
+// VerifiedAddress returns userID's notification email, or ErrNoAddress or
+// ErrUnverified.
+func VerifiedAddress(ctx context.Context, db *sql.DB, userID string) (string, error) {
+	var addr sql.NullString
+	var verified bool
+	err := db.QueryRowContext(ctx, `
+		SELECT notification_email, notification_email_verified_at IS NOT NULL
+		FROM user_settings
+		WHERE user_id = $1
+	`, userID).Scan(&addr, &verified)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !addr.Valid) {
+		return "", ErrNoAddress
+	}
+	if err != nil {
+		return "", err
+	}
+	if !verified {
+		return "", ErrUnverified
+	}
+	return addr.String, nil
+}
+
+// Notify emails userID at their verified notification address, unless they
+// unsubscribed from channel. It refuses to send anywhere else. html, if not
+// empty, is the HTML version of body (such as from PriceDropHTML), which is
+// put in the layout template.
+func (n *Notifier) Notify(ctx context.Context, userID string, channel Channel, subject, body, html string) error {
+	to, err := VerifiedAddress(ctx, n.db, userID)
+	if err != nil {
+		return err
+	}
+
+	var enabled bool
+	var nonce string
+	err = n.db.QueryRowContext(ctx, fmt.Sprintf(`
+		UPDATE user_settings SET unsubscribe_nonce = COALESCE(unsubscribe_nonce, $2)
+		WHERE user_id = $1
+		RETURNING %s, unsubscribe_nonce
+	`, channelColumns[channel].enabled), userID, newNonce()).Scan(&enabled, &nonce)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrUnsubscribed
+	}
+
+	token := SignUnsubscribe(n.secret, UnsubscribeClaims{UserID: userID, Channel: channel, Nonce: nonce})
+	link := n.baseURL + "/unsubscribe?" + url.Values{"token": {token}}.Encode()
+	if html != "" {
+		if html, err = n.templates.page(subject, html, link, channel); err != nil {
+			return err
+		}
+	}
+	return n.sender.Send(ctx, Message{
+		To:          to,
+		Subject:     subject,
+		Body:        fmt.Sprintf("%s\n\n--\nTo stop receiving %s, unsubscribe: %s", body, channel.Description(), link),
+		HTML:        html,
+		Unsubscribe: link,
+	})
+}
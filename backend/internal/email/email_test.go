@@ -0,0 +1,115 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+type fakeSender struct {
+	sent []Message
+}
+
+func (f *fakeSender) Send(ctx context.Context, msg Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+var addressColumns = []string{"notification_email", "verified"}
+
+func TestNotify_RefusesUnverified(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    *sqlmock.Rows
+		wantErr error
+	}{
+		{"unverified", sqlmock.NewRows(addressColumns).AddRow("me@example.com", false), ErrUnverified},
+		{"no address", sqlmock.NewRows(addressColumns).AddRow(nil, false), ErrNoAddress},
+		{"no settings", sqlmock.NewRows(addressColumns), ErrNoAddress},
+	}
+
+	for _, test := range tests {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		mock.ExpectQuery("SELECT notification_email").WithArgs("user-1").WillReturnRows(test.rows)
+
+		sender := &fakeSender{}
+		err = NewNotifier(db, sender, testSecret, "https://api.example.com", nil).Notify(context.Background(), "user-1", ChannelPriceDrops, "Subject", "Body", "")
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.wantErr, err)
+		}
+		if len(sender.sent) != 0 {
+			t.Errorf("%s: expected nothing sent, got %+v", test.name, sender.sent)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: unmet expectations: %v", test.name, err)
+		}
+		db.Close()
+	}
+}
+
+func TestNotify_UnsubscribeLink(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT notification_email").WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows(addressColumns).AddRow("me@example.com", true))
+	mock.ExpectQuery(`SET unsubscribe_nonce = COALESCE\(unsubscribe_nonce, \$2\)`).WithArgs("user-1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"enabled", "unsubscribe_nonce"}).AddRow(true, "nonce-1"))
+
+	sender := &fakeSender{}
+	err = NewNotifier(db, sender, testSecret, "https://api.example.com/", nil).Notify(context.Background(), "user-1", ChannelPriceDrops, "Subject", "Body", "")
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(sender.sent))
+	}
+	msg := sender.sent[0]
+	if msg.To != "me@example.com" || !strings.HasSuffix(msg.Body, msg.Unsubscribe) {
+		t.Errorf("Expected the unsubscribe link in the footer, got %+v", msg)
+	}
+
+	link, err := url.Parse(msg.Unsubscribe)
+	if err != nil || link.Host != "api.example.com" || link.Path != "/unsubscribe" {
+		t.Fatalf("Unexpected unsubscribe link %q", msg.Unsubscribe)
+	}
+	c, err := VerifyUnsubscribe(testSecret, link.Query().Get("token"))
+	if err != nil || c != (UnsubscribeClaims{UserID: "user-1", Channel: ChannelPriceDrops, Nonce: "nonce-1"}) {
+		t.Errorf("Expected a token for user-1's price drops, got %+v, %v", c, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestNotify_Unsubscribed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT notification_email").WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows(addressColumns).AddRow("me@example.com", true))
+	mock.ExpectQuery("RETURNING COALESCE\\(email_price_drops, TRUE\\)").WithArgs("user-1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"enabled", "unsubscribe_nonce"}).AddRow(false, "nonce-1"))
+
+	sender := &fakeSender{}
+	err = NewNotifier(db, sender, testSecret, "https://api.example.com", nil).Notify(context.Background(), "user-1", ChannelPriceDrops, "Subject", "Body", "")
+	if !errors.Is(err, ErrUnsubscribed) || len(sender.sent) != 0 {
+		t.Errorf("Expected ErrUnsubscribed and nothing sent, got %v and %d messages", err, len(sender.sent))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
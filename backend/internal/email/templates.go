@@ -0,0 +1,147 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"time"
+)
+
+//go:embed templates
+var builtinFS embed.FS
+
+// Templates render the HTML part of notification emails: layout.html, the
+// page every email is put in, and one template per kind of email.
+type Templates struct {
+	layout    *template.Template
+	priceDrop *template.Template
+	digest    *template.Template
+}
+
+// templateNames are the files of a template directory.
+var templateNames = []string{"layout.html", "price_drop.html", "digest.html"}
+
+// builtinTemplates are the templates shipped in templates/.
+var builtinTemplates = mustLoadTemplates()
+
+func mustLoadTemplates() *Templates {
+	t, err := LoadTemplates("")
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// LoadTemplates parses the templates, taking each from dir when it has a
+// file of that name and from the built-in ones otherwise, so that a
+// directory need only hold the templates it changes (EMAIL_TEMPLATE_DIR).
+// Empty dir loads the built-in templates.
+func LoadTemplates(dir string) (*Templates, error) {
+	builtin, err := fs.Sub(builtinFS, "templates")
+	if err != nil {
+		return nil, err
+	}
+	var custom fs.FS
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", dir)
+		}
+		custom = os.DirFS(dir)
+	}
+
+	parsed := make(map[string]*template.Template, len(templateNames))
+	overridden := 0
+	for _, name := range templateNames {
+		fsys := builtin
+		if custom != nil {
+			if _, err := fs.Stat(custom, name); err == nil {
+				fsys = custom
+				overridden++
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+		t, err := template.ParseFS(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		parsed[name] = t
+	}
+	if custom != nil && overridden == 0 {
+		return nil, fmt.Errorf("%s has none of %v", dir, templateNames)
+	}
+	return &Templates{layout: parsed["layout.html"], priceDrop: parsed["price_drop.html"], digest: parsed["digest.html"]}, nil
+}
+
+// PriceDrop is what a price drop email shows.
+type PriceDrop struct {
+	ProductName string
+	// ImageURL and PageURL are left out when empty.
+	ImageURL string
+	PageURL  string
+	OldPrice string
+	NewPrice string
+	// Percent is how much cheaper the product got, 0 when unknown.
+	Percent float64
+	// History is charted from HistoryFrom to HistoryTo (see PriceChart);
+	// there is no chart without it.
+	History     []PricePoint
+	HistoryFrom time.Time
+	HistoryTo   time.Time
+}
+
+// PriceDropHTML renders the HTML version of a price drop email, which Notify
+// puts in the layout.
+func (t *Templates) PriceDropHTML(d PriceDrop) (string, error) {
+	var b bytes.Buffer
+	err := t.priceDrop.Execute(&b, struct {
+		PriceDrop
+		Chart template.HTML
+	}{d, PriceChart(d.History, d.HistoryFrom, d.HistoryTo)})
+	return b.String(), err
+}
+
+// Digest is what a daily or weekly digest email shows.
+type Digest struct {
+	// Frequency is "daily" or "weekly".
+	Frequency string
+	// Drops are the price drops since the previous digest, newest first.
+	Drops []DigestDrop
+}
+
+// DigestDrop is one price drop in a digest.
+type DigestDrop struct {
+	ProductName string
+	// PageURL is left out when empty.
+	PageURL  string
+	OldPrice string
+	NewPrice string
+}
+
+// DigestHTML renders the HTML version of a digest email, which Notify puts
+// in the layout.
+func (t *Templates) DigestHTML(d Digest) (string, error) {
+	var b bytes.Buffer
+	err := t.digest.Execute(&b, d)
+	return b.String(), err
+}
+
+// page puts content, the HTML version of an email, in the layout.
+func (t *Templates) page(subject, content, unsubscribe string, channel Channel) (string, error) {
+	var b bytes.Buffer
+	err := t.layout.Execute(&b, struct {
+		Subject     string
+		Content     template.HTML
+		Unsubscribe string
+		Channel     string
+	}{subject, template.HTML(content), unsubscribe, channel.Description()})
+	return b.String(), err
+}
@@ -0,0 +1,221 @@
+package email
+
+import (
+	"context"
+	"flag"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with testdata/name, or rewrites the file with -update.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s (run go test -update to create it): %v", path, err)
+	}
+	if got != string(want) {
+		t.Errorf("%s changed (run go test -update if that is intended); got:\n%s", path, got)
+	}
+}
+
+// testDrop is a price drop with a month of history: $129.99, a sale and the
+// drop to $99.99 the email is about.
+var testDrop = PriceDrop{
+	ProductName: "Stainless Kettle <1.7L>",
+	ImageURL:    "https://images.example.com/kettle.jpg",
+	PageURL:     "https://shop.example.com/kettle?ref=a&b=c",
+	OldPrice:    "$129.99",
+	NewPrice:    "$99.99",
+	Percent:     23.08,
+	History: []PricePoint{
+		{time.Date(2025, 5, 2, 9, 0, 0, 0, time.UTC), 129.99},
+		{time.Date(2025, 5, 12, 9, 0, 0, 0, time.UTC), 109.99},
+		{time.Date(2025, 5, 15, 9, 0, 0, 0, time.UTC), 129.99},
+		{time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC), 99.99},
+	},
+	HistoryFrom: time.Date(2025, 5, 2, 12, 0, 0, 0, time.UTC),
+	HistoryTo:   time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+}
+
+func TestPriceChart(t *testing.T) {
+	golden(t, "price_chart.svg", string(PriceChart(testDrop.History, testDrop.HistoryFrom, testDrop.HistoryTo)))
+
+	if got := PriceChart(nil, testDrop.HistoryFrom, testDrop.HistoryTo); got != "" {
+		t.Errorf("Expected no chart without history, got %q", got)
+	}
+	flat := PriceChart(testDrop.History[:1], testDrop.HistoryFrom, testDrop.HistoryTo)
+	if strings.Count(string(flat), "<line") != 1 || !strings.Contains(string(flat), ">129.99</text>") {
+		t.Errorf("Expected one grid line at the only price, got %s", flat)
+	}
+}
+
+func TestPriceDropHTML(t *testing.T) {
+	content, err := builtinTemplates.PriceDropHTML(testDrop)
+	if err != nil {
+		t.Fatalf("PriceDropHTML failed: %v", err)
+	}
+	page, err := builtinTemplates.page("Price drop: "+testDrop.ProductName, content, "https://api.example.com/unsubscribe?token=t", ChannelPriceDrops)
+	if err != nil {
+		t.Fatalf("page failed: %v", err)
+	}
+	golden(t, "price_drop.html", page)
+
+	// Without an image, page or history those parts are left out.
+	bare, err := builtinTemplates.PriceDropHTML(PriceDrop{ProductName: "Kettle", OldPrice: "$20", NewPrice: "$15"})
+	if err != nil {
+		t.Fatalf("PriceDropHTML failed: %v", err)
+	}
+	for _, part := range []string{"<img", "<svg", "View product", "% off"} {
+		if strings.Contains(bare, part) {
+			t.Errorf("Expected no %q, got:\n%s", part, bare)
+		}
+	}
+}
+
+func TestDigestHTML(t *testing.T) {
+	content, err := builtinTemplates.DigestHTML(Digest{Frequency: "weekly", Drops: []DigestDrop{
+		{ProductName: testDrop.ProductName, PageURL: testDrop.PageURL, OldPrice: testDrop.OldPrice, NewPrice: testDrop.NewPrice},
+		// Without a page the name is not a link.
+		{ProductName: "Toaster", OldPrice: "€45,00", NewPrice: "€39,00"},
+	}})
+	if err != nil {
+		t.Fatalf("DigestHTML failed: %v", err)
+	}
+	page, err := builtinTemplates.page("Your weekly price drops", content, "https://api.example.com/unsubscribe?token=t", ChannelDigest)
+	if err != nil {
+		t.Fatalf("page failed: %v", err)
+	}
+	golden(t, "digest.html", page)
+}
+
+func TestLoadTemplates_Overrides(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "layout.html"), []byte(`<main class="acme">{{.Content}}</main><a href="{{.Unsubscribe}}">Stop</a>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+	content, err := templates.PriceDropHTML(testDrop)
+	if err != nil {
+		t.Fatalf("PriceDropHTML failed: %v", err)
+	}
+	page, err := templates.page("Subject", content, "https://api.example.com/unsubscribe", ChannelPriceDrops)
+	if err != nil {
+		t.Fatalf("page failed: %v", err)
+	}
+	// The layout is the directory's, the price drop part still built in.
+	if !strings.HasPrefix(page, `<main class="acme"><h1`) || !strings.Contains(page, "$99.99") {
+		t.Errorf("Expected the custom layout around the built-in content, got:\n%s", page)
+	}
+
+	for _, dir := range []string{t.TempDir(), filepath.Join(dir, "missing"), filepath.Join(dir, "layout.html")} {
+		if _, err := LoadTemplates(dir); err == nil {
+			t.Errorf("Expected an error for %s", dir)
+		}
+	}
+	broken := t.TempDir()
+	os.WriteFile(filepath.Join(broken, "price_drop.html"), []byte(`{{.ProductName`), 0o644)
+	if _, err := LoadTemplates(broken); err == nil {
+		t.Error("Expected an error for a template that does not parse")
+	}
+}
+
+func TestNotify_HTML(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT notification_email").WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows(addressColumns).AddRow("me@example.com", true))
+	mock.ExpectQuery("SET unsubscribe_nonce").WithArgs("user-1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"enabled", "unsubscribe_nonce"}).AddRow(true, "nonce-1"))
+
+	sender := &fakeSender{}
+	err = NewNotifier(db, sender, testSecret, "https://api.example.com", nil).Notify(context.Background(), "user-1", ChannelPriceDrops, "Subject", "Body", "<p>Cheaper now</p>")
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(sender.sent))
+	}
+	msg := sender.sent[0]
+	if !strings.Contains(msg.HTML, "<p>Cheaper now</p>") || !strings.Contains(msg.HTML, `href="`+strings.ReplaceAll(msg.Unsubscribe, "&", "&amp;")+`"`) {
+		t.Errorf("Expected the content and unsubscribe link in the layout, got:\n%s", msg.HTML)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestSMTPMessage_Alternative(t *testing.T) {
+	s := smtpSender{SMTPConfig{From: "alerts@example.com"}}
+	long := strings.Repeat("<td>x</td>", 200)
+	data, err := s.message(Message{To: "me@example.com", Subject: "Price drop", Body: "Good news!", HTML: long, Unsubscribe: "https://api.example.com/unsubscribe"})
+	if err != nil {
+		t.Fatalf("message failed: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\r\n") {
+		if len(line) > 998 {
+			t.Fatalf("Expected no line over SMTP's limit, got one of %d", len(line))
+		}
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	if msg.Header.Get("List-Unsubscribe") == "" {
+		t.Error("Expected the List-Unsubscribe header")
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Expected multipart/alternative, got %q (error: %v)", mediaType, err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", "Good news!"},
+		{"text/html; charset=UTF-8", long},
+	} {
+		part, err := parts.NextRawPart()
+		if err != nil {
+			t.Fatalf("Expected a %s part: %v", want.contentType, err)
+		}
+		body, _ := io.ReadAll(quotedprintable.NewReader(part))
+		if part.Header.Get("Content-Type") != want.contentType || string(body) != want.body {
+			t.Errorf("Expected %s %q, got %s %q", want.contentType, want.body, part.Header.Get("Content-Type"), body)
+		}
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("Expected two parts, got error %v", err)
+	}
+
+	// Without HTML the message stays plain text.
+	plain, _ := s.message(Message{To: "me@example.com", Subject: "Price drop", Body: "Good news!"})
+	if !strings.Contains(string(plain), "Content-Type: text/plain; charset=UTF-8\r\n\r\nGood news!") {
+		t.Errorf("Expected a plain text message, got %q", plain)
+	}
+}
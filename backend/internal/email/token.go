@@ -0,0 +1,98 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTokenInvalid means a token is malformed or its signature does not
+	// match.
+	ErrTokenInvalid = errors.New("invalid token")
+	// ErrTokenExpired means a verification token was valid but is too old.
+	ErrTokenExpired = errors.New("verification token expired")
+)
+
+// TokenTTL is how long a verification link stays valid.
+const TokenTTL = 24 * time.Hour
+
+// Token purposes. Each is signed under its own prefix, so a token issued for
+// one purpose is never accepted for another.
+const (
+	purposeVerification = "email-verification"
+	purposeUnsubscribe  = "unsubscribe"
+)
+
+// Claims are what a verification token vouches for: that whoever holds it
+// received mail at Email, which UserID set as their address.
+type Claims struct {
+	UserID    string
+	Email     string
+	ExpiresAt time.Time
+}
+
+// SignToken returns a URL-safe token for c, signed with HMAC-SHA256 under
+// secret. The user ID and address are both signed, so a token cannot be used
+// for another account or another address.
+func SignToken(secret []byte, c Claims) string {
+	return signFields(secret, purposeVerification, c.UserID, c.Email, strconv.FormatInt(c.ExpiresAt.Unix(), 10))
+}
+
+// VerifyToken checks token's signature and expiry as of now and returns its
+// claims.
+func VerifyToken(secret []byte, token string, now time.Time) (Claims, error) {
+	fields, err := verifyFields(secret, purposeVerification, token, 3)
+	if err != nil {
+		return Claims{}, err
+	}
+	exp, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return Claims{}, ErrTokenInvalid
+	}
+	c := Claims{UserID: fields[0], Email: fields[1], ExpiresAt: time.Unix(exp, 0)}
+	if !now.Before(c.ExpiresAt) {
+		return c, ErrTokenExpired
+	}
+	return c, nil
+}
+
+// signFields joins fields into a payload and appends its signature. Fields
+// must not contain newlines.
+func signFields(secret []byte, purpose string, fields ...string) string {
+	payload := strings.Join(fields, "\n")
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(payload)) + "." + enc.EncodeToString(sign(secret, purpose, payload))
+}
+
+// verifyFields checks token's signature and returns its n fields.
+func verifyFields(secret []byte, purpose, token string, n int) ([]string, error) {
+	enc := base64.RawURLEncoding
+	payloadPart, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrTokenInvalid
+	}
+	payload, err := enc.DecodeString(payloadPart)
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+	sig, err := enc.DecodeString(sigPart)
+	if err != nil || !hmac.Equal(sig, sign(secret, purpose, string(payload))) {
+		return nil, ErrTokenInvalid
+	}
+	fields := strings.Split(string(payload), "\n")
+	if len(fields) != n {
+		return nil, ErrTokenInvalid
+	}
+	return fields, nil
+}
+
+func sign(secret []byte, purpose, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose + "\n" + payload))
+	return mac.Sum(nil)
+}
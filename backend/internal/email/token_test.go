@@ -0,0 +1,63 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("test-secret")
+
+func TestVerifyToken_RoundTrip(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	token := SignToken(testSecret, Claims{UserID: "user-1", Email: "me@example.com", ExpiresAt: now.Add(TokenTTL)})
+
+	c, err := VerifyToken(testSecret, token, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("VerifyToken failed: %v", err)
+	}
+	if c.UserID != "user-1" || c.Email != "me@example.com" || !c.ExpiresAt.Equal(now.Add(TokenTTL)) {
+		t.Errorf("Unexpected claims %+v", c)
+	}
+}
+
+func TestVerifyToken_Expired(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	token := SignToken(testSecret, Claims{UserID: "user-1", Email: "me@example.com", ExpiresAt: now.Add(TokenTTL)})
+
+	if _, err := VerifyToken(testSecret, token, now.Add(TokenTTL)); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
+
+func TestVerifyToken_Tampered(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	token := SignToken(testSecret, Claims{UserID: "user-1", Email: "me@example.com", ExpiresAt: exp})
+	payload, sig, _ := strings.Cut(token, ".")
+	flipped := "A" + sig[1:]
+	if sig[0] == 'A' {
+		flipped = "B" + sig[1:]
+	}
+
+	// A token minted for one account, re-addressed to another.
+	other := SignToken(testSecret, Claims{UserID: "user-2", Email: "me@example.com", ExpiresAt: exp})
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	tests := map[string]string{
+		"other account": otherPayload + "." + sig,
+		"flipped sig":   payload + "." + flipped,
+		"no signature":  payload,
+		"garbage":       "not a token",
+		"empty":         "",
+	}
+	for name, tampered := range tests {
+		if _, err := VerifyToken(testSecret, tampered, time.Now()); !errors.Is(err, ErrTokenInvalid) {
+			t.Errorf("%s: expected ErrTokenInvalid, got %v", name, err)
+		}
+	}
+
+	if _, err := VerifyToken([]byte("other-secret"), token, time.Now()); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("Expected a token signed with another secret to be invalid, got %v", err)
+	}
+}
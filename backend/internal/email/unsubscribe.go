@@ -0,0 +1,98 @@
+package email
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrTokenRevoked means an unsubscribe token was signed correctly but the
+// user has rotated their nonce since it was issued.
+var ErrTokenRevoked = errors.New("unsubscribe token revoked")
+
+// Channel is a kind of email a user can unsubscribe from on its own.
+type Channel string
+
+const (
+	ChannelPriceDrops Channel = "price_drops"
+	ChannelDigest     Channel = "digest"
+)
+
+// channelColumns says, for each channel, how user_settings records that it
+// is on and how to turn it off.
+var channelColumns = map[Channel]struct{ enabled, disable string }{
+	ChannelPriceDrops: {"COALESCE(email_price_drops, TRUE)", "email_price_drops = FALSE"},
+	ChannelDigest:     {"COALESCE(digest_frequency, 'off') <> 'off'", "digest_frequency = 'off'"},
+}
+
+// Description names the channel in confirmation pages.
+func (c Channel) Description() string {
+	if c == ChannelDigest {
+		return "digest emails"
+	}
+	return "price drop emails"
+}
+
+// UnsubscribeClaims are what an unsubscribe token vouches for.
+type UnsubscribeClaims struct {
+	UserID  string
+	Channel Channel
+	// Nonce must still match the user's unsubscribe_nonce.
+	Nonce string
+}
+
+// SignUnsubscribe returns a token that unsubscribes c.UserID from c.Channel.
+// It does not expire; rotating the nonce revokes it.
+func SignUnsubscribe(secret []byte, c UnsubscribeClaims) string {
+	return signFields(secret, purposeUnsubscribe, c.UserID, string(c.Channel), c.Nonce)
+}
+
+// VerifyUnsubscribe checks token's signature and returns its claims. Whether
+// the nonce is still current is checked by Unsubscribe.
+func VerifyUnsubscribe(secret []byte, token string) (UnsubscribeClaims, error) {
+	fields, err := verifyFields(secret, purposeUnsubscribe, token, 3)
+	if err != nil {
+		return UnsubscribeClaims{}, err
+	}
+	c := UnsubscribeClaims{UserID: fields[0], Channel: Channel(fields[1]), Nonce: fields[2]}
+	if _, ok := channelColumns[c.Channel]; !ok {
+		return UnsubscribeClaims{}, ErrTokenInvalid
+	}
+	return c, nil
+}
+
+// Unsubscribe turns off the channel in c, or returns ErrTokenRevoked if the
+// nonce has been rotated. Unsubscribing twice is not an error.
+func Unsubscribe(ctx context.Context, db *sql.DB, c UnsubscribeClaims) error {
+	result, err := db.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE user_settings SET %s, updated_at = NOW()
+		WHERE user_id = $1 AND unsubscribe_nonce = $2
+	`, channelColumns[c.Channel].disable), c.UserID, c.Nonce)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// RotateUnsubscribeNonce revokes every unsubscribe link sent to userID so
+// far. Emails sent afterwards carry links with the new nonce.
+func RotateUnsubscribeNonce(ctx context.Context, db *sql.DB, userID string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO user_settings (user_id, unsubscribe_nonce)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET unsubscribe_nonce = EXCLUDED.unsubscribe_nonce, updated_at = NOW()
+	`, userID, newNonce())
+	return err
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,60 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestVerifyUnsubscribe_Forged(t *testing.T) {
+	claims := UnsubscribeClaims{UserID: "user-1", Channel: ChannelDigest, Nonce: "nonce-1"}
+	if c, err := VerifyUnsubscribe(testSecret, SignUnsubscribe(testSecret, claims)); err != nil || c != claims {
+		t.Fatalf("Expected the token to round-trip, got %+v, %v", c, err)
+	}
+
+	tests := map[string]string{
+		"other secret":       SignUnsubscribe([]byte("other-secret"), claims),
+		"verification token": SignToken(testSecret, Claims{UserID: "user-1", Email: "digest", ExpiresAt: time.Now().Add(time.Hour)}),
+		"unknown channel":    SignUnsubscribe(testSecret, UnsubscribeClaims{UserID: "user-1", Channel: "sms", Nonce: "nonce-1"}),
+		"garbage":            "not a token",
+	}
+	for name, token := range tests {
+		if _, err := VerifyUnsubscribe(testSecret, token); !errors.Is(err, ErrTokenInvalid) {
+			t.Errorf("%s: expected ErrTokenInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestUnsubscribe_IdempotentAndRevocable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	claims := UnsubscribeClaims{UserID: "user-1", Channel: ChannelPriceDrops, Nonce: "nonce-1"}
+
+	// The row still matches on the second call, so repeating is harmless.
+	for range 2 {
+		mock.ExpectExec(`SET email_price_drops = FALSE.*WHERE user_id = \$1 AND unsubscribe_nonce = \$2`).
+			WithArgs("user-1", "nonce-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec("SET email_price_drops = FALSE").
+		WithArgs("user-1", "nonce-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	for i := range 2 {
+		if err := Unsubscribe(context.Background(), db, claims); err != nil {
+			t.Errorf("Unsubscribe #%d failed: %v", i+1, err)
+		}
+	}
+	if err := Unsubscribe(context.Background(), db, claims); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected ErrTokenRevoked once the nonce no longer matches, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package realtime publishes events to Supabase Realtime channels through
+// its REST broadcast endpoint, so that the frontend, which is already
+// subscribed to Supabase, hears about them without a socket of ours.
+package realtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxAttempts is how many times a broadcast is tried, the first
+	// included, when Supabase answers with a 5xx or cannot be reached.
+	maxAttempts = 3
+	// retryBackoff is the wait after the first failed attempt; it doubles
+	// with every further one.
+	retryBackoff = 500 * time.Millisecond
+	// timeout bounds each attempt.
+	timeout = 5 * time.Second
+)
+
+// Config is where broadcasts go. Broadcasting is off when URL is empty.
+type Config struct {
+	// URL is the Supabase project's URL (SUPABASE_URL), such as
+	// https://abcd.supabase.co.
+	URL string
+	// ServiceKey is the project's service role key
+	// (SUPABASE_SERVICE_ROLE_KEY), which may publish on any channel.
+	ServiceKey string
+}
+
+// Broadcaster publishes messages on Realtime channels.
+type Broadcaster struct {
+	endpoint   string
+	serviceKey string
+	client     *http.Client
+	// backoff is the wait after the first failed attempt.
+	backoff time.Duration
+}
+
+// NewBroadcaster returns a Broadcaster, or nil if c.URL is empty.
+func NewBroadcaster(c Config) *Broadcaster {
+	if c.URL == "" {
+		return nil
+	}
+	return &Broadcaster{
+		endpoint:   strings.TrimSuffix(c.URL, "/") + "/realtime/v1/api/broadcast",
+		serviceKey: c.ServiceKey,
+		client:     &http.Client{Timeout: timeout},
+		backoff:    retryBackoff,
+	}
+}
+
+// UserTopic is the channel of a user's events. Broadcasts on it are private,
+// so Realtime authorization (a policy on realtime.messages) must let each
+// user read only their own topic.
+func UserTopic(userID string) string {
+	return "user:" + userID
+}
+
+// message is one entry of the broadcast endpoint's request body.
+type message struct {
+	Topic   string `json:"topic"`
+	Event   string `json:"event"`
+	Payload any    `json:"payload"`
+	Private bool   `json:"private"`
+}
+
+// statusError is a response other than 2xx from the broadcast endpoint.
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("bad status code: %d", int(e))
+}
+
+// transient reports whether a failed attempt is worth retrying: Supabase
+// could not be reached or answered with a 5xx. Other responses would be
+// answered the same way again.
+func transient(err error) bool {
+	status, ok := err.(statusError)
+	return !ok || status >= 500
+}
+
+// Broadcast publishes event with payload on topic, retrying while Supabase
+// is unreachable or failing (5xx) up to maxAttempts times.
+func (b *Broadcaster) Broadcast(ctx context.Context, topic, event string, payload any) error {
+	body, err := json.Marshal(struct {
+		Messages []message `json:"messages"`
+	}{[]message{{Topic: topic, Event: event, Payload: payload, Private: true}}})
+	if err != nil {
+		return err
+	}
+
+	wait := b.backoff
+	for attempt := 1; ; attempt++ {
+		err = b.post(ctx, body)
+		if err == nil || attempt == maxAttempts || !transient(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// post makes one attempt at delivering body.
+func (b *Broadcaster) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", b.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apikey", b.serviceKey)
+	req.Header.Set("Authorization", "Bearer "+b.serviceKey)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return statusError(resp.StatusCode)
+	}
+	return nil
+}
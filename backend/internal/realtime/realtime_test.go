@@ -0,0 +1,113 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestBroadcaster returns a Broadcaster for url that does not wait
+// between attempts.
+func newTestBroadcaster(url string) *Broadcaster {
+	b := NewBroadcaster(Config{URL: url + "/", ServiceKey: "service-key"})
+	b.backoff = 0
+	return b
+}
+
+func TestBroadcast_RequestShape(t *testing.T) {
+	var got struct {
+		Messages []struct {
+			Topic   string          `json:"topic"`
+			Event   string          `json:"event"`
+			Payload json.RawMessage `json:"payload"`
+			Private bool            `json:"private"`
+		} `json:"messages"`
+	}
+	var path, apikey, auth, contentType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, apikey, auth, contentType = r.URL.Path, r.Header.Get("apikey"), r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("Failed to decode request %s: %v", body, err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	payload := map[string]string{"itemId": "item-1", "newPrice": "$15.00"}
+	if err := newTestBroadcaster(ts.URL).Broadcast(context.Background(), UserTopic("user-1"), "price_drop", payload); err != nil {
+		t.Fatalf("Broadcast failed: %v", err)
+	}
+
+	if path != "/realtime/v1/api/broadcast" || apikey != "service-key" || auth != "Bearer service-key" || contentType != "application/json" {
+		t.Errorf("Unexpected request to %s (apikey %q, Authorization %q, Content-Type %q)", path, apikey, auth, contentType)
+	}
+	if len(got.Messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(got.Messages))
+	}
+	m := got.Messages[0]
+	if m.Topic != "user:user-1" || m.Event != "price_drop" || !m.Private {
+		t.Errorf("Unexpected message %+v", m)
+	}
+	if string(m.Payload) != `{"itemId":"item-1","newPrice":"$15.00"}` {
+		t.Errorf("Unexpected payload %s", m.Payload)
+	}
+}
+
+func TestBroadcast_RetriesTransientFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"5xx then success", []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusAccepted}, 3, false},
+		{"5xx every time", []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}, maxAttempts, true},
+		{"4xx is not retried", []int{http.StatusUnauthorized}, 1, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.statuses[attempts])
+				attempts++
+			}))
+			defer ts.Close()
+
+			err := newTestBroadcaster(ts.URL).Broadcast(context.Background(), UserTopic("user-1"), "price_drop", nil)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Expected error %v, got %v", test.wantErr, err)
+			}
+			if attempts != test.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", test.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestBroadcast_StopsRetryingWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	b := newTestBroadcaster(ts.URL)
+	b.backoff = retryBackoff
+	err := b.Broadcast(ctx, UserTopic("user-1"), "price_drop", nil)
+	if err == nil || attempts != 1 {
+		t.Errorf("Expected to give up after 1 attempt, got %d attempts and %v", attempts, err)
+	}
+}
+
+func TestNewBroadcaster_OffWithoutURL(t *testing.T) {
+	if b := NewBroadcaster(Config{ServiceKey: "service-key"}); b != nil {
+		t.Errorf("Expected no broadcaster, got %+v", b)
+	}
+}
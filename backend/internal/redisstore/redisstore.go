@@ -0,0 +1,134 @@
+// Package redisstore keeps what the API server would otherwise keep in
+// process memory in Redis (REDIS_URL), so that several replicas share it:
+// cache entries (a cache.Store), rate limit counts and the wake-ups of open
+// notification streams. Every key and channel is prefixed with "pricetrack:".
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix = "pricetrack:"
+	// opTimeout bounds each operation made without a caller's context.
+	opTimeout = time.Second
+	// scanBatch is how many keys DeletePrefix asks SCAN for at a time.
+	scanBatch = 100
+)
+
+// Client is a connection pool to Redis.
+type Client struct {
+	rdb *redis.Client
+}
+
+// Open connects to the Redis server at rawURL (redis:// or rediss://, with
+// optional credentials and database number) and checks that it answers.
+func Open(ctx context.Context, rawURL string) (*Client, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	rdb := redis.NewClient(opts)
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
+		return nil, err
+	}
+	return &Client{rdb: rdb}, nil
+}
+
+// Close closes the connections.
+func (c *Client) Close() error {
+	return c.rdb.Close()
+}
+
+// cacheKey returns the Redis key of cache entry key.
+func cacheKey(key string) string {
+	return keyPrefix + "cache:" + key
+}
+
+// Get returns the cache entry stored under key.
+func (c *Client) Get(key string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	value, err := c.rdb.Get(ctx, cacheKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	return value, err == nil, err
+}
+
+// Set stores a cache entry under key for ttl.
+func (c *Client) Set(key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	return c.rdb.Set(ctx, cacheKey(key), value, ttl).Err()
+}
+
+// globEscaper escapes the characters SCAN's MATCH pattern treats specially.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// DeletePrefix deletes the cache entries whose keys start with prefix. It
+// walks the keyspace with SCAN rather than KEYS, so as not to block Redis.
+func (c *Client) DeletePrefix(prefix string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*opTimeout)
+	defer cancel()
+	iter := c.rdb.Scan(ctx, 0, globEscaper.Replace(cacheKey(prefix))+"*", scanBatch).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == scanBatch {
+			if err := c.rdb.Unlink(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return c.rdb.Unlink(ctx, keys...).Err()
+	}
+	return nil
+}
+
+// allowScript counts a request against KEYS[1], allowing ARGV[1] per window
+// of ARGV[2] milliseconds that starts with the key's first request. It
+// returns -1 when the request is allowed, else the milliseconds until the
+// window ends; refused requests are not counted.
+var allowScript = redis.NewScript(`
+local count = tonumber(redis.call("GET", KEYS[1]) or "0")
+if count >= tonumber(ARGV[1]) then
+	local ttl = redis.call("PTTL", KEYS[1])
+	if ttl < 0 then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		ttl = tonumber(ARGV[2])
+	end
+	return ttl
+end
+if redis.call("INCR", KEYS[1]) == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return -1
+`)
+
+// Allow counts a request against key, allowing limit of them per window,
+// and reports whether it is within the limit. When it is not, it also
+// returns how long until the key's window ends.
+func (c *Client) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, opTimeout)
+	defer cancel()
+	ms, err := allowScript.Run(ctx, c.rdb, []string{keyPrefix + "ratelimit:" + key}, limit, window.Milliseconds()).Int64()
+	if err != nil {
+		return false, 0, err
+	}
+	if ms < 0 {
+		return true, 0, nil
+	}
+	return false, time.Duration(ms) * time.Millisecond, nil
+}
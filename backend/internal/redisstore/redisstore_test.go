@@ -0,0 +1,139 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestClient returns a Client of a fresh miniredis server.
+func newTestClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	c, err := Open(context.Background(), "redis://"+mr.Addr())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, mr
+}
+
+func TestOpen_Unreachable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+	if _, err := Open(context.Background(), "redis://"+addr); err == nil {
+		t.Error("Expected an error for a server that is down")
+	}
+	if _, err := Open(context.Background(), "http://localhost"); err == nil {
+		t.Error("Expected an error for a URL that is not redis://")
+	}
+}
+
+func TestCacheStore(t *testing.T) {
+	c, mr := newTestClient(t)
+
+	if _, ok, err := c.Get("responses:user-1|items"); ok || err != nil {
+		t.Fatalf("Expected a miss, got %v (error: %v)", ok, err)
+	}
+	for _, key := range []string{"responses:user-1|items", "responses:user-1|count", "responses:user-10|items", "responses:user-*|items"} {
+		if err := c.Set(key, []byte(`{"ETag":"x"}`), time.Minute); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	if v, ok, err := c.Get("responses:user-1|items"); !ok || err != nil || string(v) != `{"ETag":"x"}` {
+		t.Errorf("Expected the stored entry, got %q, %v (error: %v)", v, ok, err)
+	}
+	if ttl := mr.TTL("pricetrack:cache:responses:user-1|items"); ttl != time.Minute {
+		t.Errorf("Expected a TTL of 1m, got %v", ttl)
+	}
+
+	// The prefix is matched literally, glob characters included.
+	if err := c.DeletePrefix("responses:user-*|"); err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if err := c.DeletePrefix("responses:user-1|"); err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	keys := mr.Keys()
+	if len(keys) != 1 || keys[0] != "pricetrack:cache:responses:user-10|items" {
+		t.Errorf("Expected only user-10's entry left, got %q", keys)
+	}
+}
+
+func TestAllow_FixedWindowPerKey(t *testing.T) {
+	c, mr := newTestClient(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if ok, _, err := c.Allow(ctx, "share:10.0.0.1", 2, time.Minute); !ok || err != nil {
+			t.Fatalf("Request %d: expected to be allowed (error: %v)", i+1, err)
+		}
+	}
+	ok, retry, err := c.Allow(ctx, "share:10.0.0.1", 2, time.Minute)
+	if ok || err != nil || retry <= 0 || retry > time.Minute {
+		t.Errorf("Expected the third request refused with a retry within a minute, got %v, %v (error: %v)", ok, retry, err)
+	}
+	if ok, _, _ := c.Allow(ctx, "share:10.0.0.2", 2, time.Minute); !ok {
+		t.Error("Expected another key to have its own count")
+	}
+
+	mr.FastForward(time.Minute)
+	if ok, _, _ := c.Allow(ctx, "share:10.0.0.1", 2, time.Minute); !ok {
+		t.Error("Expected a new window once the last one ended")
+	}
+}
+
+func TestRelay_DeliversSubscribedTopics(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub := c.Relay(ctx)
+	pub := c.Relay(ctx)
+	if err := sub.Subscribe(ctx, "notification_inserts:user-1"); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	// Subscriptions are confirmed asynchronously; publish until one lands.
+	received := func(want string) bool {
+		deadline := time.After(2 * time.Second)
+		for {
+			if err := pub.Publish(ctx, want); err != nil {
+				t.Fatalf("Publish failed: %v", err)
+			}
+			select {
+			case got := <-sub.Messages():
+				return got == want
+			case <-time.After(20 * time.Millisecond):
+			case <-deadline:
+				return false
+			}
+		}
+	}
+	if !received("notification_inserts:user-1") {
+		t.Fatal("Expected the subscribed topic's event")
+	}
+
+	if err := pub.Publish(ctx, "notification_inserts:user-2"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	select {
+	case got := <-sub.Messages():
+		if got != "notification_inserts:user-1" {
+			t.Errorf("Expected no event of an unsubscribed topic, got %q", got)
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case _, ok := <-sub.Messages():
+		for ok {
+			_, ok = <-sub.Messages()
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Expected Messages to be closed with the context")
+	}
+}
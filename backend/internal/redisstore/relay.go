@@ -0,0 +1,61 @@
+package redisstore
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// eventPrefix starts the names of the channels relays publish on.
+const eventPrefix = keyPrefix + "events:"
+
+// Relay passes events between processes over Redis pub/sub, one channel per
+// topic. A process only receives the topics it subscribed to.
+type Relay struct {
+	rdb      *redis.Client
+	pubsub   *redis.PubSub
+	messages chan string
+}
+
+// Relay opens a pub/sub connection. It is closed, and Messages with it, when
+// ctx is done.
+func (c *Client) Relay(ctx context.Context) *Relay {
+	r := &Relay{rdb: c.rdb, pubsub: c.rdb.Subscribe(ctx), messages: make(chan string)}
+	go func() {
+		<-ctx.Done()
+		r.pubsub.Close()
+	}()
+	go func() {
+		defer close(r.messages)
+		for msg := range r.pubsub.Channel() {
+			if topic, ok := strings.CutPrefix(msg.Channel, eventPrefix); ok {
+				r.messages <- topic
+			}
+		}
+	}()
+	return r
+}
+
+// Publish sends an event on topic to the processes subscribed to it.
+func (r *Relay) Publish(ctx context.Context, topic string) error {
+	ctx, cancel := context.WithTimeout(ctx, opTimeout)
+	defer cancel()
+	return r.rdb.Publish(ctx, eventPrefix+topic, "").Err()
+}
+
+// Subscribe starts receiving topic's events on Messages.
+func (r *Relay) Subscribe(ctx context.Context, topic string) error {
+	return r.pubsub.Subscribe(ctx, eventPrefix+topic)
+}
+
+// Unsubscribe stops receiving topic's events.
+func (r *Relay) Unsubscribe(ctx context.Context, topic string) error {
+	return r.pubsub.Unsubscribe(ctx, eventPrefix+topic)
+}
+
+// Messages receives the topic of each event published on a subscribed
+// topic, by this process or another.
+func (r *Relay) Messages() <-chan string {
+	return r.messages
+}
@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/playwright-community/playwright-go"
+
+	"price-track-backend/internal/adapters"
+	"price-track-backend/internal/urlnorm"
+)
+
+// ErrBotChallenge is returned when a site answered with a page asking to
+// prove the visitor is human, such as a CAPTCHA, instead of the product.
+var ErrBotChallenge = errors.New("bot challenge")
+
+// ErrOutOfStock is returned when the site's adapter finds the product is not
+// for sale. Whatever price the page still shows is not used.
+var ErrOutOfStock = errors.New("out of stock")
+
+// AdapterOrder is when the site's adapter (see internal/adapters) is asked
+// for the price relative to the item's own selector. Empty means
+// AdapterFirst.
+type AdapterOrder string
+
+const (
+	// AdapterFirst uses the adapter's price and only falls back to the
+	// item's selector when the adapter finds none. It is the default.
+	AdapterFirst AdapterOrder = "first"
+	// AdapterLast uses the item's selector and only asks the adapter when
+	// the selector finds no price, for items whose user picked a price the
+	// adapter does not read (a member or bulk price, say).
+	AdapterLast AdapterOrder = "last"
+)
+
+// ValidAdapterOrder reports whether s names a known order.
+func ValidAdapterOrder(s string) bool {
+	switch AdapterOrder(s) {
+	case AdapterFirst, AdapterLast:
+		return true
+	}
+	return false
+}
+
+// SourceSelector and SourceJSONLD are the ScrapeResult.Source of a price
+// read with the item's own selector and from the page's JSON-LD. A price
+// read by a site adapter has "adapter:" and the adapter's name.
+const (
+	SourceSelector = "selector"
+	SourceJSONLD   = "jsonld"
+)
+
+func adapterSource(a adapters.Adapter) string {
+	return "adapter:" + a.Name()
+}
+
+// canonicalURL returns the URL the scraper fetches for rawURL: its adapter's
+// canonical product URL, or rawURL itself.
+func canonicalURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if c, ok := adapters.For(u).(adapters.Canonicalizer); ok {
+		if canonical := c.Canonicalize(u); canonical != nil {
+			return canonical.String()
+		}
+	}
+	return rawURL
+}
+
+// canonicalPageURL normalizes rawURL (see urlnorm) after canonicalURL, so that
+// links to one product that only differ in what the site ignores compare
+// equal.
+func canonicalPageURL(rawURL string) string {
+	return urlnorm.Normalize(canonicalURL(rawURL))
+}
+
+// checkAdapterPage returns ErrBotChallenge if doc, a page loaded from u, is
+// the bot challenge of a's site, and ErrOutOfStock if a finds the product is
+// not for sale.
+func checkAdapterPage(a adapters.Adapter, doc *goquery.Document, u *url.URL) error {
+	if c, ok := a.(adapters.Challenger); ok && c.Challenged(doc) {
+		return fmt.Errorf("%w: %s served a robot check for %s", ErrBotChallenge, a.Name(), u.Redacted())
+	}
+	if !a.Available(doc) {
+		return fmt.Errorf("%w: %s lists %s as unavailable", ErrOutOfStock, a.Name(), u.Redacted())
+	}
+	return nil
+}
+
+// adapterPrice reads the price on doc with a, "" if it finds none.
+func adapterPrice(a adapters.Adapter, doc *goquery.Document) string {
+	return adapters.Price(a, doc, func(text string) bool { return validatePriceText(text) == nil })
+}
+
+// playwrightAdapter returns the adapter for the site page now shows and the
+// page's URL, a nil adapter if there is none.
+func playwrightAdapter(page playwright.Page) (adapters.Adapter, *url.URL) {
+	u, err := url.Parse(page.URL())
+	if err != nil {
+		return nil, nil
+	}
+	return adapters.For(u), u
+}
+
+// playwrightDocument parses the page as the browser now shows it.
+func playwrightDocument(page playwright.Page) (*goquery.Document, error) {
+	content, err := page.Content()
+	if err != nil {
+		return nil, err
+	}
+	return goquery.NewDocumentFromReader(strings.NewReader(content))
+}
+
+// runAdapterSteps performs a's steps on page. A step whose element does not
+// show up is skipped.
+func runAdapterSteps(page playwright.Page, a adapters.Adapter) {
+	timeout := playwright.Float(float64(adapters.StepTimeout.Milliseconds()))
+	visible := func(selector string) (playwright.Locator, bool) {
+		el := page.Locator(selector).First()
+		if err := el.WaitFor(playwright.LocatorWaitForOptions{State: playwright.WaitForSelectorStateVisible, Timeout: timeout}); err != nil {
+			slog.Debug("Adapter step skipped", "adapter", a.Name(), "selector", selector, "error", err)
+			return nil, false
+		}
+		return el, true
+	}
+	for _, step := range a.Steps() {
+		if step.Click != "" {
+			if el, ok := visible(step.Click); ok {
+				if err := el.Click(playwright.LocatorClickOptions{Timeout: timeout}); err != nil {
+					slog.Warn("Adapter step failed", "adapter", a.Name(), "selector", step.Click, "error", err)
+				}
+			}
+		}
+		if step.WaitFor != "" {
+			visible(step.WaitFor)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"price-track-backend/internal/adapters"
+)
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %v", raw, err)
+	}
+	return u
+}
+
+func parsePage(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse page: %v", err)
+	}
+	return doc
+}
+
+// amazonPage is cut down from an Amazon product page: the buy box price and,
+// in the same price markup, the list price and the member price.
+const amazonPage = `<html><body>
+	<div id="corePrice_feature_div"><span class="a-price"><span class="a-offscreen">$24.99</span></span></div>
+	<span class="a-price a-text-price"><span class="a-offscreen">$34.99</span></span>
+	<div id="memberPrice"><span class="member">$19.99</span></div>
+</body></html>`
+
+func TestExtractPrice_Adapter(t *testing.T) {
+	base := mustParseURL(t, "https://www.amazon.com/dp/B07XJ8C8F5")
+	tests := []struct {
+		name       string
+		page       string
+		css        string
+		order      AdapterOrder
+		wantPrice  string
+		wantSource string
+		wantErr    error
+	}{
+		{"selector loses to the adapter", amazonPage, ".a-text-price .a-offscreen", "", "$24.99", "adapter:amazon", nil},
+		{"adapter first", amazonPage, ".member", AdapterFirst, "$24.99", "adapter:amazon", nil},
+		{"adapter last", amazonPage, ".member", AdapterLast, "$19.99", SourceSelector, nil},
+		{"adapter last falls back", amazonPage, ".gone", AdapterLast, "$24.99", "adapter:amazon", nil},
+		{"selector after the adapter", `<html><body><span class="deal">$5.00</span></body></html>`, ".deal", "", "$5.00", SourceSelector, nil},
+		{"robot check", `<html><head><title>Robot Check</title></head><body><form action="/errors/validateCaptcha"></form></body></html>`, "h4", "", "", "", ErrBotChallenge},
+		{"out of stock", `<html><body><div id="outOfStock">Currently unavailable.</div><span class="a-price"><span class="a-offscreen">$24.99</span></span></body></html>`, ".price", AdapterLast, "", "", ErrOutOfStock},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			price, source, err := extractPrice(context.Background(), http.DefaultClient, parsePage(t, test.page), base, test.css, "", "", Frame{}, test.order, "", nil)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) || price != "" {
+					t.Errorf("Expected %v, got %q (error: %v)", test.wantErr, price, err)
+				}
+				return
+			}
+			if err != nil || price != test.wantPrice || source != test.wantSource {
+				t.Errorf("Expected %q from %s, got %q from %s (error: %v)", test.wantPrice, test.wantSource, price, source, err)
+			}
+		})
+	}
+
+	// Elsewhere the item's selector is all there is.
+	price, source, err := extractPrice(context.Background(), http.DefaultClient, parsePage(t, amazonPage), mustParseURL(t, "https://shop.example.com/kettle"), ".member", "", "", Frame{}, "", "", nil)
+	if err != nil || price != "$19.99" || source != SourceSelector {
+		t.Errorf("Expected the item's selector off Amazon, got %q from %s (error: %v)", price, source, err)
+	}
+}
+
+func TestExtractPrice_NoSelector(t *testing.T) {
+	// An Amazon item tracked by URL alone gets the built-in adapter's price.
+	price, source, err := extractPrice(context.Background(), http.DefaultClient, parsePage(t, amazonPage), mustParseURL(t, "https://www.amazon.com/dp/B07XJ8C8F5"), "", "", "", Frame{}, "", "", nil)
+	if err != nil || price != "$24.99" || source != "adapter:amazon" {
+		t.Errorf("Expected the adapter's price, got %q from %s (error: %v)", price, source, err)
+	}
+
+	page := parsePage(t, `<html><body><span class="was">$30.00</span><span class="now">$25.00</span></body></html>`)
+	kettles := mustParseURL(t, "https://shop.example.com/kettle")
+	if _, _, err := extractPrice(context.Background(), http.DefaultClient, page, kettles, "", "", "", Frame{}, "", "", nil); err == nil {
+		t.Error("Expected an error without a selector on a store with no adapter")
+	}
+
+	// Configured selectors stand in for an adapter.
+	adapters.Configure(adapters.Selectors{"example.com": {".now"}})
+	defer adapters.Configure(nil)
+	price, source, err = extractPrice(context.Background(), http.DefaultClient, page, kettles, "", "", "", Frame{}, "", "", nil)
+	if err != nil || price != "$25.00" || source != "adapter:example.com" {
+		t.Errorf("Expected the configured selector's price, got %q from %s (error: %v)", price, source, err)
+	}
+}
+
+func TestClassifyScrapeError_Adapter(t *testing.T) {
+	if reason := classifyScrapeError(ErrBotChallenge); reason != "bot_challenge" {
+		t.Errorf("Expected bot_challenge, got %q", reason)
+	}
+	if reason := classifyScrapeError(ErrOutOfStock); reason != "out_of_stock" {
+		t.Errorf("Expected out_of_stock, got %q", reason)
+	}
+}
+
+func TestGroupItems_SameASIN(t *testing.T) {
+	items := []Item{
+		{ID: "search", PageURL: "https://www.amazon.com/Stainless-Electric-Kettle/dp/B07XJ8C8F5/ref=sr_1_3?keywords=kettle", CSSSelector: ".price"},
+		{ID: "share", PageURL: "https://www.amazon.com/dp/B07XJ8C8F5?tag=affiliate-20", CSSSelector: ".price"},
+		{ID: "other", PageURL: "https://www.amazon.com/dp/B0BSHF7WHW", CSSSelector: ".price"},
+	}
+	if groups := groupItems(items); len(groups) != 2 {
+		t.Errorf("Expected links to one ASIN to share a page, got %d groups", len(groups))
+	}
+}
+
+func TestScrapeSignature_AdapterOrder(t *testing.T) {
+	for _, test := range []struct {
+		url  string
+		same bool
+	}{
+		{"https://www.amazon.com/dp/B07XJ8C8F5", false},
+		{"https://shop.example.com/kettle", true},
+	} {
+		first := Item{PageURL: test.url, CSSSelector: ".price"}
+		last := first
+		last.AdapterOrder = AdapterLast
+		if same := first.scrapeSignature() == last.scrapeSignature(); same != test.same {
+			t.Errorf("%s: Expected shared signature %v, got %v", test.url, test.same, same)
+		}
+	}
+}
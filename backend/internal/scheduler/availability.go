@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/playwright-community/playwright-go"
+)
+
+// Availability is what an item's last check found about its stock.
+type Availability string
+
+const (
+	InStock    Availability = "in_stock"
+	OutOfStock Availability = "out_of_stock"
+)
+
+// availabilitySelectorKey carries the CSS selector a scrape checks the stock
+// text with (see withAvailabilitySelector).
+type availabilitySelectorKey struct{}
+
+// availabilityReadKey carries the *availabilityRead of a scrape in progress.
+type availabilityReadKey struct{}
+
+// withAvailabilitySelector makes ScrapeDetailed read the text cssSelector
+// selects on the page before the price, into the result's Availability, and
+// fail with ErrOutOfStock instead of reading a price when it says the
+// product is sold out.
+func withAvailabilitySelector(ctx context.Context, cssSelector string) context.Context {
+	if cssSelector == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, availabilitySelectorKey{}, cssSelector)
+}
+
+// availabilityRead collects the stock text of one scrape.
+type availabilityRead struct {
+	selector string
+	text     string
+}
+
+// startAvailabilityRead returns ctx carrying a new availabilityRead for the
+// availability selector in ctx, and the read. Without a selector it returns
+// ctx and nil.
+func startAvailabilityRead(ctx context.Context) (context.Context, *availabilityRead) {
+	selector, _ := ctx.Value(availabilitySelectorKey{}).(string)
+	if selector == "" {
+		return ctx, nil
+	}
+	read := &availabilityRead{selector: selector}
+	return context.WithValue(ctx, availabilityReadKey{}, read), read
+}
+
+// String returns the stock text read, empty for a nil read.
+func (r *availabilityRead) String() string {
+	if r == nil {
+		return ""
+	}
+	return r.text
+}
+
+// check fails with ErrOutOfStock when the text read says the product on the
+// page at pageURL is sold out.
+func (r *availabilityRead) check(pageURL string) error {
+	if !SoldOut(r.text) {
+		return nil
+	}
+	if u, err := url.Parse(pageURL); err == nil {
+		pageURL = u.Redacted()
+	}
+	return fmt.Errorf("%w: %q on %s", ErrOutOfStock, r.text, pageURL)
+}
+
+// checkAvailabilityHTTP reads the text the scrape's availability selector
+// selects in doc, loaded from u, if the scrape has one, and fails with
+// ErrOutOfStock when it says the product is sold out.
+func checkAvailabilityHTTP(ctx context.Context, doc *goquery.Document, u *url.URL) error {
+	read, _ := ctx.Value(availabilityReadKey{}).(*availabilityRead)
+	if read == nil || doc == nil {
+		return nil
+	}
+	read.text = strings.TrimSpace(doc.Find(read.selector).First().Text())
+	return read.check(u.String())
+}
+
+// checkAvailabilityBrowser reads the text the scrape's availability selector
+// selects on page, if the scrape has one, and fails with ErrOutOfStock when
+// it says the product is sold out. The element is not waited for: a page
+// that has loaded without it is taken to be in stock.
+func checkAvailabilityBrowser(ctx context.Context, page playwright.Page) error {
+	read, _ := ctx.Value(availabilityReadKey{}).(*availabilityRead)
+	if read == nil {
+		return nil
+	}
+	read.text = ""
+	element := page.Locator(read.selector).First()
+	if n, err := element.Count(); err != nil || n == 0 {
+		return nil
+	}
+	if text, err := element.InnerText(playwright.LocatorInnerTextOptions{Timeout: playwright.Float(2000)}); err == nil {
+		read.text = strings.TrimSpace(text)
+	}
+	return read.check(page.URL())
+}
+
+// soldOutPhrases mark stock text that says a product cannot be bought, in the
+// languages of the supported regions. Text is compared in lower case.
+var soldOutPhrases = []string{
+	"out of stock", "sold out", "unavailable", "not available", "no longer available",
+	"ausverkauft", "nicht verfügbar", "nicht lieferbar", "vergriffen",
+	"épuisé", "rupture de stock", "indisponible",
+	"agotado", "sin stock", "no disponible",
+	"esaurito", "non disponibile",
+	"uitverkocht", "niet leverbaar",
+	"在庫切れ", "品切れ", "売り切れ",
+}
+
+// SoldOut reports whether text, as an availability selector selects it, says
+// the product is sold out. Any other text, "In stock" or "Only 2 left" say,
+// and none at all count as available, so a selector that only matches a
+// sold out badge works as well as one for the stock line.
+func SoldOut(text string) bool {
+	text = strings.ToLower(text)
+	for _, phrase := range soldOutPhrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAvailability stores what the check found about the item's stock
+// when it differs from what the item has recorded.
+func (s *Scheduler) recordAvailability(ctx context.Context, item Item, availability Availability) {
+	if item.Availability == availability {
+		return
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE tracked_items SET availability = $1, availability_changed_at = NOW()
+		WHERE id = $2
+	`, availability, item.ID)
+	if err != nil {
+		slog.Error("Failed to record availability", "id", item.ID, "error", err)
+		return
+	}
+	slog.Info("Availability changed", "id", item.ID, "availability", availability)
+}
@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSoldOut(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Out of Stock", true},
+		{"SOLD OUT", true},
+		{"Currently unavailable.", true},
+		{"Derzeit nicht verfügbar", true},
+		{"Rupture de stock", true},
+		{"In stock", false},
+		{"Only 2 left in stock - order soon.", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := SoldOut(test.text); got != test.want {
+			t.Errorf("SoldOut(%q) = %v, expected %v", test.text, got, test.want)
+		}
+	}
+}
+
+// availabilityPage serves a page with stock and price texts.
+func availabilityPage(t *testing.T, stock, price string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="stock">` + stock + `</div><div class="price">` + price + `</div></body></html>`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestScrapeDetailed_ReadsAvailability(t *testing.T) {
+	ts := availabilityPage(t, " In stock ", "$19.99")
+	ctx := withAvailabilitySelector(context.Background(), ".stock")
+
+	result, err := localScraper().ScrapeDetailed(ctx, ScrapeRequest{URL: ts.URL, CSSSelector: ".price"})
+	if err != nil {
+		t.Fatalf("Expected a price, got %v", err)
+	}
+	if result.Text != "$19.99" || result.Availability != "In stock" {
+		t.Errorf("Expected $19.99 in stock, got %q and %q", result.Text, result.Availability)
+	}
+}
+
+func TestScrapeDetailed_SoldOutReadsNoPrice(t *testing.T) {
+	// Sold out pages often show a placeholder price.
+	ts := availabilityPage(t, "Sold out", "$0.00")
+	ctx := withAvailabilitySelector(context.Background(), ".stock")
+
+	result, err := localScraper().ScrapeDetailed(ctx, ScrapeRequest{URL: ts.URL, CSSSelector: ".price"})
+	if !errors.Is(err, ErrOutOfStock) {
+		t.Fatalf("Expected ErrOutOfStock, got %v", err)
+	}
+	if result.Text != "" || result.Availability != "Sold out" {
+		t.Errorf("Expected no price and the stock text, got %q and %q", result.Text, result.Availability)
+	}
+}
+
+func TestProcessItem_RecordsInStock(t *testing.T) {
+	ts := availabilityPage(t, "In stock", "$19.99")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`SET availability = \$1, availability_changed_at = NOW\(\)`).
+		WithArgs(InStock, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("SET last_price = \\$1").
+		WithArgs(19.99, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	s := New(db, localConfig())
+	s.processItem(context.Background(), Item{
+		ID:                   "item-1",
+		UserID:               "user-1",
+		PriceText:            "$19.99",
+		ProductName:          "Widget",
+		PageURL:              ts.URL,
+		CSSSelector:          ".price",
+		AvailabilitySelector: ".stock",
+		Availability:         OutOfStock,
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestProcessItem_SoldOutKeepsPrice(t *testing.T) {
+	ts := availabilityPage(t, "Out of stock", "$0.00")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("out_of_stock", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`SET availability = \$1, availability_changed_at = NOW\(\)`).
+		WithArgs(OutOfStock, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s := New(db, localConfig())
+	s.processItem(context.Background(), Item{
+		ID:                   "item-1",
+		UserID:               "user-1",
+		PriceText:            "$19.99",
+		ProductName:          "Widget",
+		PageURL:              ts.URL,
+		CSSSelector:          ".price",
+		AvailabilitySelector: ".stock",
+		Availability:         InStock,
+	})
+
+	// Neither the price nor a drop to $0.00 is recorded.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
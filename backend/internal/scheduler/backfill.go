@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// withoutHistoryCond selects the items that have no price history yet, such as
+// those created before item_price_history existed.
+const withoutHistoryCond = `NOT EXISTS (SELECT 1 FROM item_price_history h WHERE h.item_id = tracked_items.id)`
+
+// BackfillSummary is the outcome of Backfill.
+type BackfillSummary struct {
+	Items    int
+	Recorded int
+	Failed   int
+}
+
+// Backfill gives every item without price history its first point: it scrapes
+// each item once and records the price it finds. Nothing else about the item
+// changes, except last_price where it was never set, and nothing is logged as
+// a scrape or sent to anyone. Items that gained history in the meantime are
+// left alone, so running it again only picks up what is still missing.
+func (s *Scheduler) Backfill(ctx context.Context) BackfillSummary {
+	if err := s.scraper.Start(); err != nil {
+		slog.Warn("Failed to start Playwright scraper, will use HTTP only", "error", err)
+	}
+	defer s.scraper.Stop()
+
+	slog.Info("Starting price history backfill")
+
+	groups := make(chan []Item)
+	memo := newScrapeMemo()
+	var recorded, failed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < s.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range groups {
+				for _, item := range group {
+					if s.backfillItem(ctx, item, memo) {
+						recorded.Add(1)
+					} else {
+						failed.Add(1)
+					}
+				}
+			}
+		}()
+	}
+
+	total := 0
+	lastID := ""
+	for ctx.Err() == nil {
+		batch, err := s.fetchBatch(ctx, withoutHistoryCond, nil, "", lastID)
+		if err != nil {
+			slog.Error("Failed to fetch items without price history", "error", err)
+			break
+		}
+		total += len(batch)
+		for _, group := range groupItems(batch) {
+			select {
+			case groups <- group:
+			case <-ctx.Done():
+			}
+		}
+		if len(batch) < s.batchSize {
+			break
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+
+	close(groups)
+	wg.Wait()
+	summary := BackfillSummary{Items: total, Recorded: int(recorded.Load()), Failed: int(failed.Load())}
+	slog.Info("Completed price history backfill", "items", summary.Items, "recorded", summary.Recorded, "failed", summary.Failed)
+	return summary
+}
+
+// backfillItem scrapes item, sharing the scrape with the other items on its
+// page through memo, and records the price as its first history point. It
+// reports whether it did.
+func (s *Scheduler) backfillItem(ctx context.Context, item Item, memo *scrapeMemo) bool {
+	entry := memo.get(item.scrapeSignature())
+	entry.once.Do(func() {
+		ctx, cancel := item.scrapeContext(ctx)
+		defer cancel()
+		entry.result, entry.err = s.scraper.ScrapeDetailed(ctx, item.scrapeRequest())
+	})
+	if entry.err != nil {
+		slog.Warn("Failed to scrape item for backfill", "id", item.ID, "url", item.PageURL, "error", entry.err)
+		return false
+	}
+	price, err := ParsePriceWith(entry.result.Text, item.ParseStrategy)
+	if err != nil || !item.Bounds.contains(price) {
+		slog.Warn("No usable price for backfill", "id", item.ID, "price", entry.result.Text, "error", err)
+		return false
+	}
+
+	// The item is checked again in the statement, in case a scheduled run
+	// recorded its first price while this one was scraping.
+	_, err = s.db.ExecContext(ctx, `
+		WITH updated AS (
+			UPDATE tracked_items
+			SET last_price = COALESCE(last_price, $2)
+			WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM item_price_history WHERE item_id = $1)
+			RETURNING id
+		)
+		INSERT INTO item_price_history (item_id, price)
+		SELECT id, $2 FROM updated
+	`, item.ID, price)
+	if err != nil {
+		slog.Error("Failed to record backfilled price", "id", item.ID, "error", err)
+		return false
+	}
+	return true
+}
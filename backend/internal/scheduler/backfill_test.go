@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestBackfill_RecordsFirstPriceQuietly(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$14.99</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	// Every statement is recorded, so that one the test did not expect (a
+	// notification, a status change) is noticed even though the scheduler
+	// only logs the error it gets back.
+	var mu sync.Mutex
+	var statements []string
+	matcher := sqlmock.QueryMatcherFunc(func(expected, actual string) error {
+		mu.Lock()
+		statements = append(statements, actual)
+		mu.Unlock()
+		return sqlmock.QueryMatcherRegexp.Match(expected, actual)
+	})
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher))
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// The stored price is $19.99, so a regular check would report a drop.
+	columns := []string{"id", "user_id", "price_text", "product_name", "page_url", "css_selector", "xpath", "frame_selector", "frame_url", "variant_selector", "variant_value", "adapter_order", "min_expected", "max_expected", "parse_strategy", "accept_language", "country_code", "cookies_encrypted", "headers", "proxy_url", "scrape_profile", "final_url", "not_found_count", "max_scrape_seconds", "failure_streak", "selector_match", "shipping_selector", "total_price", "notification_channel", "last_notified_price", "availability_selector", "availability", "variants"}
+	mock.ExpectQuery(`FROM tracked_items\s+WHERE NOT EXISTS \(SELECT 1 FROM item_price_history h WHERE h.item_id = tracked_items.id\) AND md5\(\$1 \|\| id\) \|\| id > \$2`).
+		WithArgs("", "").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("item-1", "user-1", "$19.99", "Switch", ts.URL+"/switch", ".price", "", "", "", "", "", "first", nil, nil, "auto", "en-US", "", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil))
+	mock.ExpectExec(`UPDATE tracked_items\s+SET last_price = COALESCE\(last_price, \$2\)\s+WHERE id = \$1 AND NOT EXISTS .*INSERT INTO item_price_history`).
+		WithArgs("item-1", 14.99).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	summary := New(db, noBrowserConfig()).Backfill(context.Background())
+
+	if summary != (BackfillSummary{Items: 1, Recorded: 1}) {
+		t.Errorf("Expected one price recorded, got %+v", summary)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(statements) != 2 {
+		t.Errorf("Expected only the fetch and the history insert, got %d statements", len(statements))
+	}
+	for _, statement := range statements {
+		if strings.Contains(statement, "notifications") || strings.Contains(statement, "scrape_log") {
+			t.Errorf("Expected no notification or scrape log, got %s", statement)
+		}
+	}
+}
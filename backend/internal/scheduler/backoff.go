@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+const (
+	// backoffFactor multiplies a failing item's check delay after each
+	// failure in a row.
+	backoffFactor = 2
+	// defaultBackoffMax caps the delay unless FAILURE_BACKOFF_MAX says
+	// otherwise.
+	defaultBackoffMax = 24 * time.Hour
+)
+
+// backoffDelay is how long an item waits for its next check after streak
+// failed checks in a row; zero means the next run, as usual. One failure may
+// be a fluke, so the delay starts at the second with twice the run interval
+// and doubles with each failure after it, up to max. A zero interval or max
+// turns backoff off.
+func backoffDelay(interval, max time.Duration, streak int) time.Duration {
+	if interval <= 0 || max <= 0 || streak < 2 {
+		return 0
+	}
+	delay := interval
+	for i := 1; i < streak && delay < max; i++ {
+		delay *= backoffFactor
+	}
+	return min(delay, max)
+}
+
+// backOff counts another failed check of item and puts its next check off
+// by backoffDelay, recording the delay on entry.
+func (s *Scheduler) backOff(ctx context.Context, item Item, entry *scrapeLogEntry) {
+	if s.checkInterval <= 0 || s.backoffMax <= 0 {
+		return
+	}
+	delay := backoffDelay(s.checkInterval, s.backoffMax, item.FailureStreak+1)
+	var next sql.NullTime
+	if delay > 0 {
+		// Due half an interval early, so that the run nearest the delay picks
+		// the item up even though runs drift.
+		next = sql.NullTime{Time: s.now().Add(delay - s.checkInterval/2), Valid: true}
+		entry.BackoffSeconds = int64(delay / time.Second)
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE tracked_items
+		SET failure_streak = failure_streak + 1, next_check_at = $2
+		WHERE id = $1
+	`, item.ID, next)
+	if err != nil {
+		slog.Error("Failed to back off item", "id", item.ID, "error", err)
+	}
+}
+
+// resetBackoff puts item back on every run after a check that did not fail.
+func (s *Scheduler) resetBackoff(ctx context.Context, item Item) {
+	if item.FailureStreak == 0 {
+		return
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE tracked_items
+		SET failure_streak = 0, next_check_at = NULL
+		WHERE id = $1
+	`, item.ID)
+	if err != nil {
+		slog.Error("Failed to reset item backoff", "id", item.ID, "error", err)
+	}
+}
@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		interval, max time.Duration
+		streak        int
+		expected      time.Duration
+	}{
+		// A single failure keeps the usual schedule.
+		{time.Hour, 24 * time.Hour, 1, 0},
+		{time.Hour, 24 * time.Hour, 2, 2 * time.Hour},
+		{time.Hour, 24 * time.Hour, 3, 4 * time.Hour},
+		{time.Hour, 24 * time.Hour, 5, 16 * time.Hour},
+		{time.Hour, 24 * time.Hour, 6, 24 * time.Hour},
+		{time.Hour, 24 * time.Hour, 40, 24 * time.Hour},
+		{15 * time.Minute, 24 * time.Hour, 4, 2 * time.Hour},
+		// Disabled.
+		{0, 24 * time.Hour, 5, 0},
+		{time.Hour, 0, 5, 0},
+	}
+	for _, test := range tests {
+		if got := backoffDelay(test.interval, test.max, test.streak); got != test.expected {
+			t.Errorf("backoffDelay(%v, %v, %d) = %v, expected %v", test.interval, test.max, test.streak, got, test.expected)
+		}
+	}
+}
+
+// backoffScheduler returns a scheduler running hourly at a fixed time.
+func backoffScheduler(t *testing.T) (*Scheduler, sqlmock.Sqlmock, time.Time) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	mock.MatchExpectationsInOrder(false)
+
+	cfg := noBrowserConfig()
+	cfg.JSONLDFallbackDisabled = true
+	cfg.CheckInterval = time.Hour
+	s := New(db, cfg)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, mock, now
+}
+
+func TestProcessItem_BacksOffFailingItem(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><span class="sale">$24.00</span></body></html>`))
+	}))
+	defer ts.Close()
+	s, mock, now := backoffScheduler(t)
+
+	// The third failure in a row waits four intervals, due half an interval
+	// early.
+	mock.ExpectExec(`SET failure_streak = failure_streak \+ 1, next_check_at = \$2`).
+		WithArgs("item-1", now.Add(4*time.Hour-30*time.Minute)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tracked_items").WithArgs("failed", "item-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WithArgs("item-1", "user-1", "127.0.0.1", "failed", "selector_not_found", sqlmock.AnyArg(), sqlmock.AnyArg(), false, nil, "en-US", "", ts.URL, "", "", int64(4*60*60)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	var results []ItemResult
+	ctx := WithItemResults(context.Background(), func(result ItemResult) { results = append(results, result) })
+	s.processItem(ctx, Item{ID: "item-1", UserID: "user-1", PriceText: "$24.00", PageURL: ts.URL, CSSSelector: ".price", FailureStreak: 2})
+
+	if len(results) != 1 || results[0].BackoffSeconds != 4*60*60 {
+		t.Errorf("Expected the result to show the backoff, got %+v", results)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestProcessItem_FirstFailureKeepsSchedule(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><span class="sale">$24.00</span></body></html>`))
+	}))
+	defer ts.Close()
+	s, mock, _ := backoffScheduler(t)
+
+	mock.ExpectExec(`SET failure_streak = failure_streak \+ 1, next_check_at = \$2`).
+		WithArgs("item-1", nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tracked_items").WithArgs("failed", "item-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WithArgs("item-1", "user-1", "127.0.0.1", "failed", "selector_not_found", sqlmock.AnyArg(), sqlmock.AnyArg(), false, nil, "en-US", "", ts.URL, "", "", int64(0)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	s.processItem(context.Background(), Item{ID: "item-1", UserID: "user-1", PriceText: "$24.00", PageURL: ts.URL, CSSSelector: ".price"})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestProcessItem_SuccessResetsBackoff(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><span class="price">$24.00</span></body></html>`))
+	}))
+	defer ts.Close()
+	s, mock, _ := backoffScheduler(t)
+
+	mock.ExpectExec(`SET failure_streak = 0, next_check_at = NULL`).
+		WithArgs("item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tracked_items").WithArgs("success", "item-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SET last_price").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	s.processItem(context.Background(), Item{ID: "item-1", UserID: "user-1", PriceText: "$24.00", PageURL: ts.URL, CSSSelector: ".price", FailureStreak: 5})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+)
+
+// dropBaseline is the price a new price must go below to be a drop: the
+// previous one, or the last price its owner was notified of when that is
+// lower. A price that recovers and falls back to where it was notified is
+// no news; one that falls further is. compareOld is the previous price, or
+// total with shipping, as the drop is judged on.
+func (i Item) dropBaseline(compareOld float64) float64 {
+	if i.LastNotifiedPrice.Valid && i.LastNotifiedPrice.Float64 < compareOld {
+		return i.LastNotifiedPrice.Float64
+	}
+	return compareOld
+}
+
+// clearsThreshold reports whether price is below baseline by at least
+// thresholdPercent of it.
+func clearsThreshold(baseline, price, thresholdPercent float64) bool {
+	return price < baseline && price <= baseline*(1-thresholdPercent/100)
+}
+
+// dropThreshold returns the user's dropThresholdPercent setting. When the
+// settings cannot be loaded every drop counts, as before it was applied.
+func (s *Scheduler) dropThreshold(ctx context.Context, userID string) float64 {
+	st, err := s.userSettings.Get(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to load settings", "user_id", userID, "error", err)
+		return 0
+	}
+	return st.DropThresholdPercent
+}
+
+// recordNotifiedPrice stores the price the item's owner was just notified of
+// as its last_notified_price.
+func (s *Scheduler) recordNotifiedPrice(ctx context.Context, itemID string, price float64) {
+	if _, err := s.db.ExecContext(ctx, `UPDATE tracked_items SET last_notified_price = $1 WHERE id = $2`, price, itemID); err != nil {
+		slog.Error("Failed to record notified price", "id", itemID, "error", err)
+	}
+}
+
+// clearNotifiedPrice forgets the item's last_notified_price once it can no
+// longer be compared with the item's prices, as after a currency change.
+func (s *Scheduler) clearNotifiedPrice(ctx context.Context, item Item) {
+	if !item.LastNotifiedPrice.Valid {
+		return
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE tracked_items SET last_notified_price = NULL WHERE id = $1`, item.ID); err != nil {
+		slog.Error("Failed to clear notified price", "id", item.ID, "error", err)
+	}
+}
@@ -0,0 +1,229 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectNotifiedPrice expects the item's last_notified_price to be set to
+// price after its owner was notified.
+func expectNotifiedPrice(mock sqlmock.Sqlmock, itemID string, price float64) {
+	mock.ExpectExec(`UPDATE tracked_items SET last_notified_price = \$1 WHERE id = \$2`).
+		WithArgs(price, itemID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestDropBaseline(t *testing.T) {
+	item := Item{}
+	if got := item.dropBaseline(19.99); got != 19.99 {
+		t.Errorf("Expected the previous price without a notified one, got %v", got)
+	}
+	item.LastNotifiedPrice = sql.NullFloat64{Float64: 15, Valid: true}
+	if got := item.dropBaseline(18); got != 15 {
+		t.Errorf("Expected the lower notified price, got %v", got)
+	}
+	if got := item.dropBaseline(12); got != 12 {
+		t.Errorf("Expected the lower previous price, got %v", got)
+	}
+}
+
+func TestClearsThreshold(t *testing.T) {
+	tests := []struct {
+		baseline, price, threshold float64
+		want                       bool
+	}{
+		{20, 19.99, 0, true},
+		{20, 20, 0, false},
+		{20, 18, 10, true},
+		{20, 17.99, 10, true},
+		{20, 18.01, 10, false},
+		{20, 21, 10, false},
+	}
+	for _, test := range tests {
+		if got := clearsThreshold(test.baseline, test.price, test.threshold); got != test.want {
+			t.Errorf("clearsThreshold(%v, %v, %v) = %v, expected %v", test.baseline, test.price, test.threshold, got, test.want)
+		}
+	}
+}
+
+func TestProcessItem_DropThreshold(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		scraped  string
+		price    float64
+		notified bool
+	}{
+		{"just under the threshold", "$18.01", 18.01, false},
+		{"just over the threshold", "$17.99", 17.99, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte(`<html><body><div class="price">` + test.scraped + `</div></body></html>`))
+			}))
+			defer ts.Close()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectExec("UPDATE tracked_items").
+				WithArgs("success", "item-1").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("INSERT INTO scrape_log").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			// The user only wants to hear about drops of 10% or more.
+			mock.ExpectQuery("FROM user_settings").
+				WithArgs("user-1").
+				WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow(nil, nil, nil, nil, nil, 10.0, nil, nil))
+			mock.ExpectExec("SET price_text = \\$1").
+				WithArgs(test.scraped, sqlmock.AnyArg(), test.price, "item-1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			if test.notified {
+				mock.ExpectExec("INSERT INTO notifications").
+					WithArgs("user-1", "Price Drop Alert!", sqlmock.AnyArg(), "item-1", "$20.00", test.scraped, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectNoWebhook(mock, "user-1")
+				expectNotifiedPrice(mock, "item-1", test.price)
+			}
+
+			New(db, localConfig()).processItem(context.Background(), Item{
+				ID:          "item-1",
+				UserID:      "user-1",
+				PriceText:   "$20.00",
+				ProductName: "Widget",
+				PageURL:     ts.URL,
+				CSSSelector: ".price",
+			})
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+// TestProcessItem_NotifiesEachDropOnce follows an item over several checks,
+// carrying the price and notified price each stores over to the next, as
+// fetchBatch would read them back.
+func TestProcessItem_NotifiesEachDropOnce(t *testing.T) {
+	var mu sync.Mutex
+	page := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">` + page + `</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := New(db, localConfig())
+
+	item := Item{ID: "item-1", UserID: "user-1", PriceText: "$19.99", ProductName: "Widget", PageURL: ts.URL, CSSSelector: ".price"}
+	settingsRead := false
+	for _, step := range []struct {
+		name     string
+		scraped  string
+		price    float64
+		notified bool
+	}{
+		{"first drop", "$15.00", 15, true},
+		{"same price next run", "$15.00", 15, false},
+		{"further drop", "$12.00", 12, true},
+		{"recovery", "$18.00", 18, false},
+		{"re-drop to the notified price", "$12.00", 12, false},
+		{"re-drop below it", "$11.00", 11, true},
+	} {
+		mu.Lock()
+		page = step.scraped
+		mu.Unlock()
+
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("success", "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO scrape_log").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		// The drop threshold is read at the first drop, and cached.
+		if step.notified && !settingsRead {
+			expectDefaultSettings(mock, "user-1")
+			settingsRead = true
+		}
+		if step.scraped == item.PriceText {
+			mock.ExpectExec("SET last_price = \\$1").
+				WithArgs(step.price, "item-1").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		} else {
+			mock.ExpectExec("SET price_text = \\$1").
+				WithArgs(step.scraped, sqlmock.AnyArg(), step.price, "item-1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		if step.notified {
+			mock.ExpectExec("INSERT INTO notifications").
+				WithArgs("user-1", "Price Drop Alert!", sqlmock.AnyArg(), "item-1", item.PriceText, step.scraped, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectNoWebhook(mock, "user-1")
+			expectNotifiedPrice(mock, "item-1", step.price)
+		}
+
+		s.processItem(context.Background(), item)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		item.PriceText = step.scraped
+		if step.notified {
+			item.LastNotifiedPrice = sql.NullFloat64{Float64: step.price, Valid: true}
+		}
+	}
+}
+
+func TestProcessItem_CurrencyChangeForgetsNotifiedPrice(t *testing.T) {
+	ts := euroPage(t)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("SET price_text = \\$1").
+		WithArgs("€15.00", "15.00 EUR", 15.0, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SET last_notified_price = NULL WHERE id = \$1`).
+		WithArgs("item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO notifications .*'currency_changed'").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	New(db, localConfig()).processItem(context.Background(), Item{
+		ID:                "item-1",
+		UserID:            "user-1",
+		PriceText:         "$19.99",
+		ProductName:       "Widget",
+		PageURL:           ts.URL,
+		CSSSelector:       ".price",
+		LastNotifiedPrice: sql.NullFloat64{Float64: 17.5, Valid: true},
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"golang.org/x/net/html/charset"
+)
+
+// maxPageSize bounds how much of a page the HTTP scrape reads. Anything past
+// it is cut off rather than failing the scrape.
+const maxPageSize = 5 << 20
+
+// readPage decompresses resp's body (see decodeBody), reads at most
+// maxPageSize bytes of it and transcodes them to UTF-8, so that Shift_JIS or
+// Windows-1251 pages yield readable prices. The charset comes from the
+// Content-Type header, a byte order mark or a <meta> tag, and a page declaring
+// none that is not valid UTF-8 is read as Windows-1252 (a superset of
+// Latin-1), so that its "£" or "é" survive. A body that cannot be decoded is
+// returned as is.
+func readPage(resp *http.Response) (*bytes.Reader, error) {
+	decoded, err := decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(io.LimitReader(decoded, maxPageSize))
+	if err != nil {
+		return nil, err
+	}
+	enc, name, _ := charset.DetermineEncoding(body, resp.Header.Get("Content-Type"))
+	if name == "utf-8" {
+		return bytes.NewReader(body), nil
+	}
+	transcoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		slog.Warn("Could not decode page, reading it as is", "url", resp.Request.URL.String(), "charset", name, "error", err)
+		return bytes.NewReader(body), nil
+	}
+	return bytes.NewReader(transcoded), nil
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// encodedPage serves html encoded with enc under contentType.
+func encodedPage(t *testing.T, enc encoding.Encoding, contentType, html string) *httptest.Server {
+	t.Helper()
+	body, err := enc.NewEncoder().String(html)
+	if err != nil {
+		t.Fatalf("Failed to encode fixture: %v", err)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestScrapeDetailed_Charsets(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		enc         encoding.Encoding
+		contentType string
+		html        string
+		want        string
+	}{
+		{
+			"Shift_JIS from the header", japanese.ShiftJIS, "text/html; charset=Shift_JIS",
+			`<html><body><h1>電気ケトル</h1><span class="price">税込 ￥1,980</span></body></html>`,
+			"税込 ￥1,980",
+		},
+		{
+			"Windows-1251 from a meta tag", charmap.Windows1251, "text/html",
+			`<html><head><meta charset="windows-1251"></head><body><span class="price">Цена: 1 299,00 руб.</span></body></html>`,
+			"Цена: 1 299,00 руб.",
+		},
+		{
+			"ISO-8859-1 from the header", charmap.ISO8859_1, "text/html; charset=ISO-8859-1",
+			`<html><body><span class="price">£12.99</span></body></html>`,
+			"£12.99",
+		},
+		{
+			"Latin-1 without a declared charset", charmap.ISO8859_1, "text/html",
+			`<html><body><h1>Théière</h1><span class="price">£21.00</span></body></html>`,
+			"£21.00",
+		},
+		{
+			"unknown charset label", charmap.Windows1251, "text/html; charset=x-made-up",
+			`<html><head><meta http-equiv="Content-Type" content="text/html; charset=windows-1251"></head><body><span class="price">Цена: 450 руб.</span></body></html>`,
+			"Цена: 450 руб.",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := encodedPage(t, test.enc, test.contentType, test.html)
+			defer ts.Close()
+
+			result, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price"})
+			if err != nil {
+				t.Fatalf("ScrapeDetailed failed: %v", err)
+			}
+			if result.Text != test.want {
+				t.Errorf("Expected %q, got %q", test.want, result.Text)
+			}
+		})
+	}
+}
+
+func TestScrapeDetailed_PageSizeLimit(t *testing.T) {
+	padding := strings.Repeat("<p>filler</p>", maxPageSize/len("<p>filler</p>"))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		w.Write([]byte(`<html><body><span class="early">$5.00</span>` + padding + `<span class="late">$9.00</span></body></html>`))
+	}))
+	defer ts.Close()
+
+	result, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".early"})
+	if err != nil || result.Text != "$5.00" {
+		t.Errorf("Expected the price before the limit, got %q (error: %v)", result.Text, err)
+	}
+	if _, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".late"}); !errors.Is(err, ErrSelectorNotFound) {
+		t.Errorf("Expected the price past the limit to be cut off, got %v", err)
+	}
+}
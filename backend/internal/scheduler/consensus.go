@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+const (
+	// consensusMinItems is how many items on one page must have a price
+	// before any of them can be outvoted.
+	consensusMinItems = 3
+	// defaultOutlierFactor flags prices more than double or less than half
+	// the page's median.
+	defaultOutlierFactor = 2.0
+)
+
+// outlier is a price that disagrees with the other items on its page.
+type outlier struct {
+	price, median float64
+}
+
+// priceOutliers compares the prices the items of one page group scraped. When
+// at least consensusMinItems parsed, those more than factor away from their
+// median, either way, are returned by index. Items on a page that span keyset
+// batches are only compared within their batch.
+func priceOutliers(group []Item, scrapes []*memoizedScrape, factor float64) map[int]outlier {
+	if factor <= 0 || len(group) < consensusMinItems {
+		return nil
+	}
+	prices := make(map[int]float64, len(group))
+	for i, item := range group {
+		if scrapes[i].err != nil {
+			continue
+		}
+		price, err := ParsePriceWith(scrapes[i].result.Text, item.ParseStrategy)
+		if err != nil || price <= 0 {
+			continue
+		}
+		prices[i] = price
+	}
+	if len(prices) < consensusMinItems {
+		return nil
+	}
+
+	sorted := make([]float64, 0, len(prices))
+	for _, p := range prices {
+		sorted = append(sorted, p)
+	}
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + median) / 2
+	}
+
+	var outliers map[int]outlier
+	for i, p := range prices {
+		if p > median*factor || p*factor < median {
+			if outliers == nil {
+				outliers = make(map[int]outlier)
+			}
+			outliers[i] = outlier{price: p, median: median}
+		}
+	}
+	return outliers
+}
+
+// flagOutlier records an item whose price was outvoted. Its price is left
+// alone, so no drop is recorded, and it is flagged like a broken selector:
+// the owner is told the first time.
+func (s *Scheduler) flagOutlier(ctx context.Context, item Item, result ScrapeResult, o outlier) {
+	slog.Warn("Scraped price disagrees with other items on the page", "id", item.ID, "url", item.PageURL, "price", o.price, "median", o.median)
+	if changed := s.setScrapeStatus(ctx, newScrapeLogEntry(item, result), "outlier", nil); changed {
+		if err := s.sendSelectorBrokenNotification(item.UserID, item.ProductName, item.ID); err != nil {
+			slog.Error("Failed to send notification", "error", err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestProcessGroup_FlagsOutlierSelector(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>
+			<div class="price">$19.99</div>
+			<div class="member-price">$19.49</div>
+			<span class="review-count">1,234</span>
+		</body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// Three users track the page; the third picked the review count.
+	items := []Item{
+		{ID: "item-1", UserID: "user-1", PriceText: "$19.99", ProductName: "Kettle", PageURL: ts.URL, CSSSelector: ".price"},
+		{ID: "item-2", UserID: "user-2", PriceText: "$19.49", ProductName: "Kettle", PageURL: ts.URL, CSSSelector: ".member-price"},
+		{ID: "item-3", UserID: "user-3", PriceText: "$19.99", ProductName: "Kettle", PageURL: ts.URL, CSSSelector: ".review-count"},
+	}
+	for _, item := range items[:2] {
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("success", item.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO scrape_log").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET last_price").
+			WithArgs(sqlmock.AnyArg(), item.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	// The outlier is flagged as a broken selector and logged as an outlier;
+	// its price is left alone.
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("selector_broken", "item-3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WithArgs("item-3", "user-3", "127.0.0.1", "outlier", "price_outlier", "", sqlmock.AnyArg(), false, nil, "en-US", "", ts.URL, "", "selector", int64(0)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO notifications .* 'selector_broken'").
+		WithArgs("user-3", sqlmock.AnyArg(), sqlmock.AnyArg(), "item-3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	groups := groupItems(items)
+	if len(groups) != 1 {
+		t.Fatalf("Expected one page group, got %d", len(groups))
+	}
+	New(db, noBrowserConfig()).processGroup(context.Background(), groups[0], newScrapeMemo())
+
+	if got := requests.Load(); got != 3 {
+		t.Errorf("Expected one fetch per selector, got %d", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestPriceOutliers(t *testing.T) {
+	item := Item{ParseStrategy: "auto"}
+	scrape := func(text string) *memoizedScrape {
+		return &memoizedScrape{result: ScrapeResult{Text: text}}
+	}
+	failed := &memoizedScrape{err: errors.New("timeout")}
+
+	tests := []struct {
+		name    string
+		scrapes []*memoizedScrape
+		factor  float64
+		want    []int
+	}{
+		{"high outlier", []*memoizedScrape{scrape("$20"), scrape("$21"), scrape("$95")}, 2, []int{2}},
+		{"low outlier", []*memoizedScrape{scrape("$20"), scrape("$4"), scrape("$21"), scrape("$19")}, 2, []int{1}},
+		{"within factor", []*memoizedScrape{scrape("$20"), scrape("$21"), scrape("$35")}, 2, nil},
+		{"too few items", []*memoizedScrape{scrape("$20"), scrape("$95")}, 2, nil},
+		{"failed scrapes do not vote", []*memoizedScrape{scrape("$20"), failed, scrape("$95")}, 2, nil},
+		{"disabled", []*memoizedScrape{scrape("$20"), scrape("$21"), scrape("$95")}, 0, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			group := make([]Item, len(test.scrapes))
+			for i := range group {
+				group[i] = item
+			}
+			got := priceOutliers(group, test.scrapes, test.factor)
+			if len(got) != len(test.want) {
+				t.Fatalf("Expected outliers %v, got %v", test.want, got)
+			}
+			for _, i := range test.want {
+				if _, ok := got[i]; !ok {
+					t.Errorf("Expected item %d to be an outlier, got %v", i, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package scheduler
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// decodeBody returns resp's body with its Content-Encoding undone. Go's
+// transport only decodes gzip, and only when it asked for it itself: once an
+// item's headers set Accept-Encoding, say to "gzip, deflate, br" like a
+// browser, the body arrives as the site compressed it.
+func decodeBody(resp *http.Response) (io.Reader, error) {
+	if resp.Uncompressed {
+		return resp.Body, nil
+	}
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return resp.Body, nil
+	case "br":
+		return brotli.NewReader(resp.Body), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %q", encoding)
+	}
+}
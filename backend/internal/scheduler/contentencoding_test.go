@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressedShop serves a price page compressed with whichever of br and gzip
+// the request accepts first.
+func compressedShop(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		var zw io.WriteCloser
+		switch accept := r.Header.Get("Accept-Encoding"); {
+		case strings.Contains(accept, "br"):
+			w.Header().Set("Content-Encoding", "br")
+			zw = brotli.NewWriter(w)
+		case strings.Contains(accept, "gzip"):
+			w.Header().Set("Content-Encoding", "gzip")
+			zw = gzip.NewWriter(w)
+		default:
+			t.Errorf("Expected a compressed response to be accepted, got Accept-Encoding %q", accept)
+			return
+		}
+		zw.Write([]byte(`<html><body><span class="price">€42,50</span></body></html>`))
+		zw.Close()
+	}))
+}
+
+func TestScrapeDetailed_DecodesContentEncoding(t *testing.T) {
+	ts := compressedShop(t)
+	defer ts.Close()
+
+	for _, accept := range []string{"gzip, deflate, br", "gzip"} {
+		headers := map[string]string{"Accept-Encoding": accept}
+		result, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price", Headers: headers})
+		if err != nil {
+			t.Errorf("%s: ScrapeDetailed failed: %v", accept, err)
+			continue
+		}
+		if result.Text != "€42,50" {
+			t.Errorf("%s: Expected €42,50, got %q", accept, result.Text)
+		}
+	}
+
+	// Without an Accept-Encoding of the item's own, Go's transport asks for
+	// gzip and decodes it.
+	result, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price"})
+	if err != nil || result.Text != "€42,50" {
+		t.Errorf("Expected the transport's own gzip to be decoded, got %q (error: %v)", result.Text, err)
+	}
+}
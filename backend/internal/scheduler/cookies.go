@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+
+	"github.com/playwright-community/playwright-go"
+
+	"price-track-backend/internal/secretbox"
+)
+
+// Cookie is a cookie sent with every request for an item's page, for prices
+// only shown to logged-in users. An empty Domain means the page's host only;
+// otherwise the cookie also applies to subdomains of Domain.
+type Cookie struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Domain string `json:"domain,omitempty"`
+}
+
+// SealCookies encrypts cookies for storage. No cookies seal to nil.
+func SealCookies(box *secretbox.Box, cookies []Cookie) ([]byte, error) {
+	if len(cookies) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(cookies)
+	if err != nil {
+		return nil, err
+	}
+	return box.Seal(data), nil
+}
+
+// OpenCookies decrypts cookies sealed by SealCookies.
+func OpenCookies(box *secretbox.Box, sealed []byte) ([]Cookie, error) {
+	if len(sealed) == 0 {
+		return nil, nil
+	}
+	data, err := box.Open(sealed)
+	if err != nil {
+		return nil, err
+	}
+	var cookies []Cookie
+	return cookies, json.Unmarshal(data, &cookies)
+}
+
+// openCookies decrypts an item's stored cookies with the scheduler's key.
+func (s *Scheduler) openCookies(sealed []byte) ([]Cookie, error) {
+	if len(sealed) == 0 {
+		return nil, nil
+	}
+	if s.cookies == nil {
+		return nil, errors.New("COOKIE_ENCRYPTION_KEY is not set")
+	}
+	return OpenCookies(s.cookies, sealed)
+}
+
+// cookiesSignature distinguishes scrapes of the same page made with different
+// cookies, which may see different prices.
+func cookiesSignature(cookies []Cookie) string {
+	var b strings.Builder
+	for _, c := range cookies {
+		b.WriteString(c.Name + "=" + c.Value + ";" + c.Domain + "\x00")
+	}
+	return b.String()
+}
+
+// cookieJar returns a jar holding cookies for pageURL, so that they are also
+// sent after redirects within the same site.
+func cookieJar(pageURL string, cookies []Cookie) (http.CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil || len(cookies) == 0 {
+		return jar, err
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	httpCookies := make([]*http.Cookie, len(cookies))
+	for i, c := range cookies {
+		httpCookies[i] = &http.Cookie{Name: c.Name, Value: c.Value, Domain: c.Domain, Path: "/"}
+	}
+	jar.SetCookies(u, httpCookies)
+	return jar, nil
+}
+
+// playwrightCookies converts cookies for BrowserContext.AddCookies with the
+// same scoping as cookieJar.
+func playwrightCookies(pageURL string, cookies []Cookie) []playwright.OptionalCookie {
+	out := make([]playwright.OptionalCookie, len(cookies))
+	for i, c := range cookies {
+		out[i] = playwright.OptionalCookie{Name: c.Name, Value: c.Value}
+		if c.Domain == "" {
+			out[i].URL = playwright.String(pageURL)
+		} else {
+			out[i].Domain = playwright.String("." + strings.TrimPrefix(c.Domain, "."))
+			out[i].Path = playwright.String("/")
+		}
+	}
+	return out
+}
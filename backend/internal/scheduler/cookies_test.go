@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/secretbox"
+)
+
+// loggedInShop only shows the price to requests carrying session=abc. The
+// product page redirects first, as shops often do, so the cookie must survive
+// the redirect.
+func loggedInShop() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/p" {
+			http.Redirect(w, r, "/p/1", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		if c, err := r.Cookie("session"); err == nil && c.Value == "abc" {
+			w.Write([]byte(`<html><body><div class="price">$42.00</div></body></html>`))
+			return
+		}
+		w.Write([]byte(`<html><body><a href="/login">Log in to see the price</a></body></html>`))
+	}))
+}
+
+func TestScrapeDetailed_Cookies(t *testing.T) {
+	ts := loggedInShop()
+	defer ts.Close()
+
+	if _, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL + "/p", CSSSelector: ".price"}); !errors.Is(err, ErrSelectorNotFound) {
+		t.Errorf("Expected no price without the cookie, got %v", err)
+	}
+
+	result, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL + "/p", CSSSelector: ".price", Cookies: []Cookie{{Name: "session", Value: "abc"}}})
+	if err != nil {
+		t.Fatalf("ScrapeDetailed failed: %v", err)
+	}
+	if result.Text != "$42.00" {
+		t.Errorf("Expected $42.00, got %q", result.Text)
+	}
+}
+
+func TestFetchBatch_DecryptsCookies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	box, _ := secretbox.New(bytes.Repeat([]byte{7}, secretbox.KeySize))
+	cookies := []Cookie{{Name: "session", Value: "abc", Domain: "example.com"}}
+	sealed, err := SealCookies(box, cookies)
+	if err != nil {
+		t.Fatalf("SealCookies failed: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("abc")) {
+		t.Fatal("Expected the stored cookies to be encrypted")
+	}
+
+	columns := []string{"id", "user_id", "price_text", "product_name", "page_url", "css_selector", "xpath", "frame_selector", "frame_url", "variant_selector", "variant_value", "adapter_order", "min_expected", "max_expected", "parse_strategy", "accept_language", "country_code", "cookies_encrypted", "headers", "proxy_url", "scrape_profile", "final_url", "not_found_count", "max_scrape_seconds", "failure_streak", "selector_match", "shipping_selector", "total_price", "notification_channel", "last_notified_price", "availability_selector", "availability", "variants"}
+	mock.ExpectQuery("cookies_encrypted").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("item-1", "user-1", "$42.00", "Shoes", "https://www.example.com/p", ".price", "", "", "", "", "", "first", nil, nil, "auto", "en-US", "", sealed, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil))
+
+	cfg := localConfig()
+	cfg.Cookies = box
+	batch, err := New(db, cfg).fetchBatch(context.Background(), "", nil, "", "")
+	if err != nil {
+		t.Fatalf("fetchBatch failed: %v", err)
+	}
+	if len(batch) != 1 || len(batch[0].Cookies) != 1 || batch[0].Cookies[0] != cookies[0] {
+		t.Errorf("Expected the decrypted cookies, got %+v", batch)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestScrapeSignature_Cookies(t *testing.T) {
+	plain := Item{PageURL: "https://example.com/p", CSSSelector: ".price"}
+	withCookies := plain
+	withCookies.Cookies = []Cookie{{Name: "session", Value: "abc"}}
+
+	if plain.scrapeSignature() == withCookies.scrapeSignature() {
+		t.Error("Expected items with different cookies not to share a scrape")
+	}
+}
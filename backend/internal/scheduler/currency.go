@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+)
+
+// currencyChange returns the currencies of an item's previous and new price
+// texts, and whether both are known and differ. Symbols several currencies
+// share are resolved with the item's country, as NormalizePrice does.
+func currencyChange(oldText, newText, countryCode string) (from, to string, changed bool) {
+	from, to = priceCurrency(oldText, countryCode), priceCurrency(newText, countryCode)
+	return from, to, from != "" && to != "" && from != to
+}
+
+// sendCurrencyChangedNotification tells the owner that item's price is now
+// in another currency, so it was not compared with the previous one.
+func (s *Scheduler) sendCurrencyChangedNotification(ctx context.Context, item Item, oldPriceText, newPriceText, from, to string) error {
+	title := "Price Currency Changed"
+	message := fmt.Sprintf("The store now shows '%s' in %s instead of %s (%s, was %s), perhaps for another region. We'll compare prices in %s from now on.", item.ProductName, to, from, newPriceText, oldPriceText, to)
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO notifications (user_id, title, message, type, product_id, old_price, new_price, is_read)
+		VALUES ($1, $2, $3, 'currency_changed', $4, $5, $6, false)
+	`, item.UserID, title, message, item.ID, oldPriceText, newPriceText)
+
+	return err
+}
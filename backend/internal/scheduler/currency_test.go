@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCurrencyChange(t *testing.T) {
+	tests := []struct {
+		old, new, country string
+		from, to          string
+		changed           bool
+	}{
+		{"$19.99", "$15.00", "", "USD", "USD", false},
+		{"$19.99", "€15.00", "", "USD", "EUR", true},
+		{"$19.99", "15.00", "", "USD", "", false},
+		{"19.99 EUR", "£15.00", "", "EUR", "GBP", true},
+	}
+	for _, tt := range tests {
+		from, to, changed := currencyChange(tt.old, tt.new, tt.country)
+		if from != tt.from || to != tt.to || changed != tt.changed {
+			t.Errorf("currencyChange(%q, %q) = %q, %q, %v; want %q, %q, %v", tt.old, tt.new, from, to, changed, tt.from, tt.to, tt.changed)
+		}
+	}
+}
+
+// euroPage serves a page whose price is now in euros.
+func euroPage(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">€15.00</div></body></html>`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestProcessItem_CurrencyChangeIsNotADrop(t *testing.T) {
+	ts := euroPage(t)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// The euro price becomes the baseline, and no price drop is reported.
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("€15.00", "15.00 EUR", 15.0, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO notifications .*'currency_changed'").
+		WithArgs("user-1", "Price Currency Changed", sqlmock.AnyArg(), "item-1", "$19.99", "€15.00").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	s := New(db, localConfig())
+	s.processItem(context.Background(), Item{
+		ID:          "item-1",
+		UserID:      "user-1",
+		PriceText:   "$19.99",
+		ProductName: "Widget",
+		PageURL:     ts.URL,
+		CSSSelector: ".price",
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestProcessItem_CompareAcrossCurrencies(t *testing.T) {
+	ts := euroPage(t)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectQuietHours(mock, "user-1", "UTC", "", "")
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("€15.00", "15.00 EUR", 15.0, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO notifications .*'price_drop'").
+		WithArgs("user-1", "Price Drop Alert!", sqlmock.AnyArg(), "item-1", "$19.99", "€15.00", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectNoWebhook(mock, "user-1")
+	expectNotifiedPrice(mock, "item-1", 15.0)
+
+	cfg := localConfig()
+	cfg.CompareAcrossCurrencies = true
+	s := New(db, cfg)
+	s.processItem(context.Background(), Item{
+		ID:          "item-1",
+		UserID:      "user-1",
+		PriceText:   "$19.99",
+		ProductName: "Widget",
+		PageURL:     ts.URL,
+		CSSSelector: ".price",
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
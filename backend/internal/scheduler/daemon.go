@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// daemonJitter is the share of the interval each wait is randomly lengthened
+// or shortened by, so that scrapers started together drift apart.
+const daemonJitter = 0.1
+
+// Daemon runs a job now and then every interval until its context is done,
+// for deployments without cron (cmd/scraper -daemon).
+type Daemon struct {
+	interval time.Duration
+	run      func(context.Context)
+	// after waits like time.After; tests replace it.
+	after func(time.Duration) <-chan time.Time
+	now   func() time.Time
+
+	mu      sync.Mutex
+	running bool
+	stats   DaemonStats
+}
+
+// DaemonStats describes a Daemon's runs so far.
+type DaemonStats struct {
+	// Running is true while a run is in progress.
+	Running bool
+	// Runs counts the runs started, Skipped the ticks that came while the
+	// previous run was still going.
+	Runs    int
+	Skipped int
+	// LastStartedAt and LastFinishedAt are zero until a run starts and
+	// finishes.
+	LastStartedAt  time.Time
+	LastFinishedAt time.Time
+}
+
+// NewDaemon returns a Daemon that calls run every interval.
+func NewDaemon(interval time.Duration, run func(context.Context)) *Daemon {
+	return &Daemon{interval: interval, run: run, after: time.After, now: time.Now}
+}
+
+// Stats returns the daemon's runs so far.
+func (d *Daemon) Stats() DaemonStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats := d.stats
+	stats.Running = d.running
+	return stats
+}
+
+// Run runs the job at once and then every interval, give or take 10%. A tick
+// that comes while the previous run is still going is skipped rather than
+// queued. Once ctx is done, Run waits for the current run, which sees ctx
+// cancelled, and returns.
+func (d *Daemon) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		d.tick(ctx, &wg)
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.after(d.nextWait()):
+		}
+	}
+}
+
+// tick starts a run unless one is still going.
+func (d *Daemon) tick(ctx context.Context, wg *sync.WaitGroup) {
+	d.mu.Lock()
+	if d.running {
+		d.stats.Skipped++
+		d.mu.Unlock()
+		slog.Warn("Previous scrape run still going, skipping this one", "started_at", d.stats.LastStartedAt)
+		return
+	}
+	d.running = true
+	d.stats.Runs++
+	d.stats.LastStartedAt = d.now()
+	d.mu.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.run(ctx)
+		d.mu.Lock()
+		d.running = false
+		d.stats.LastFinishedAt = d.now()
+		d.mu.Unlock()
+	}()
+}
+
+// nextWait is the interval with jitter applied.
+func (d *Daemon) nextWait() time.Duration {
+	spread := float64(d.interval) * daemonJitter
+	return d.interval + time.Duration((rand.Float64()*2-1)*spread)
+}
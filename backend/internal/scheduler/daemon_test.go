@@ -0,0 +1,129 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeTicks makes d wait on ticks instead of the clock, and reports each wait
+// it asks for on waits.
+func fakeTicks(d *Daemon) (ticks chan time.Time, waits chan time.Duration) {
+	ticks = make(chan time.Time)
+	waits = make(chan time.Duration, 16)
+	d.after = func(wait time.Duration) <-chan time.Time {
+		waits <- wait
+		return ticks
+	}
+	return ticks, waits
+}
+
+func TestDaemon_RunsEveryTickWithJitter(t *testing.T) {
+	var runs atomic.Int32
+	d := NewDaemon(time.Hour, func(context.Context) { runs.Add(1) })
+	ticks, waits := fakeTicks(d)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 3; i++ {
+		wait := <-waits
+		if wait < 54*time.Minute || wait > 66*time.Minute {
+			t.Errorf("Expected a wait within 10%% of the interval, got %v", wait)
+		}
+		waitFor(t, func() bool { return !d.Stats().Running })
+		ticks <- time.Now()
+	}
+	<-waits
+	waitFor(t, func() bool { return !d.Stats().Running })
+	cancel()
+	<-done
+
+	if got := runs.Load(); got != 4 {
+		t.Errorf("Expected a run at start and one per tick, got %d", got)
+	}
+	if stats := d.Stats(); stats.Runs != 4 || stats.Skipped != 0 || stats.LastFinishedAt.IsZero() {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestDaemon_SkipsTickWhileRunning(t *testing.T) {
+	release := make(chan struct{})
+	d := NewDaemon(time.Minute, func(ctx context.Context) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+	})
+	ticks, waits := fakeTicks(d)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	// The first run is still going on the next two ticks.
+	<-waits
+	ticks <- time.Now()
+	<-waits
+	ticks <- time.Now()
+	<-waits
+	if stats := d.Stats(); stats.Runs != 1 || stats.Skipped != 2 || !stats.Running {
+		t.Errorf("Expected one run and two skipped ticks, got %+v", stats)
+	}
+
+	close(release)
+	waitFor(t, func() bool { return !d.Stats().Running })
+	ticks <- time.Now()
+	<-waits
+	if stats := d.Stats(); stats.Runs != 2 {
+		t.Errorf("Expected a run once the first finished, got %+v", stats)
+	}
+	cancel()
+	<-done
+}
+
+func TestDaemon_CancelStopsRun(t *testing.T) {
+	var cancelled atomic.Bool
+	d := NewDaemon(time.Millisecond, func(ctx context.Context) {
+		<-ctx.Done()
+		cancelled.Store(true)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	waitFor(t, func() bool { return d.Stats().Runs > 0 })
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Run to return once its context was cancelled")
+	}
+	if !cancelled.Load() {
+		t.Error("Expected Run to wait for the cancelled run to return")
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
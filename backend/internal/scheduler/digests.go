@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"price-track-backend/internal/email"
+)
+
+// digestDropLimit bounds the price drops one digest lists, newest first.
+const digestDropLimit = 50
+
+// digestCandidate is a user who gets daily or weekly digests.
+type digestCandidate struct {
+	userID   string
+	lastSent time.Time
+}
+
+// sendDigests emails the daily and weekly digests that are due: the price
+// drops each user was notified of since their last digest. A digest is due
+// once settings.DigestHour has passed in the user's time zone (on Mondays
+// for weekly ones) and waits out quiet hours. Sent or empty digests, and
+// users who can no longer be emailed, are recorded in last_digest_at; any
+// other failure is retried on the next tick.
+func (s *Scheduler) sendDigests(ctx context.Context) {
+	if s.email == nil {
+		return
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, last_digest_at FROM user_settings
+		WHERE digest_frequency IN ('daily', 'weekly')
+		  AND notification_email_verified_at IS NOT NULL
+	`)
+	if err != nil {
+		slog.Error("Failed to query digest users", "error", err)
+		return
+	}
+	var candidates []digestCandidate
+	for rows.Next() {
+		var c digestCandidate
+		var lastSent sql.NullTime
+		if err := rows.Scan(&c.userID, &lastSent); err != nil {
+			slog.Error("Failed to scan digest user", "error", err)
+			continue
+		}
+		c.lastSent = lastSent.Time
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+
+	sent := 0
+	for _, c := range candidates {
+		if s.sendDigest(ctx, c) {
+			sent++
+		}
+	}
+	if sent > 0 {
+		slog.Info("Sent digests", "count", sent)
+	}
+}
+
+// sendDigest sends c's digest if it is due, reporting whether an email went
+// out.
+func (s *Scheduler) sendDigest(ctx context.Context, c digestCandidate) bool {
+	st, err := s.userSettings.Get(ctx, c.userID)
+	if err != nil {
+		slog.Error("Failed to load settings", "user_id", c.userID, "error", err)
+		return false
+	}
+	now := s.now()
+	if !st.DigestDue(now, c.lastSent) {
+		return false
+	}
+	if _, quiet := st.QuietUntil(now); quiet {
+		return false
+	}
+
+	// A first digest covers one period.
+	since := c.lastSent
+	if since.IsZero() {
+		since = now.AddDate(0, 0, -1)
+		if st.DigestFrequency == "weekly" {
+			since = now.AddDate(0, 0, -7)
+		}
+	}
+	drops, err := s.digestDrops(ctx, c.userID, since)
+	if err != nil {
+		slog.Error("Failed to load digest drops", "user_id", c.userID, "error", err)
+		return false
+	}
+
+	sent := false
+	if len(drops) > 0 {
+		digest := email.Digest{Frequency: st.DigestFrequency, Drops: drops}
+		subject := fmt.Sprintf("Your %s price drops", st.DigestFrequency)
+		html, err := s.email.Templates().DigestHTML(digest)
+		if err != nil {
+			// The plain text still goes out.
+			slog.Error("Failed to render digest email", "user_id", c.userID, "error", err)
+			html = ""
+		}
+		err = s.email.Notify(ctx, c.userID, email.ChannelDigest, subject, digestBody(digest), html)
+		switch {
+		case errors.Is(err, email.ErrNoAddress), errors.Is(err, email.ErrUnverified), errors.Is(err, email.ErrUnsubscribed):
+		case err != nil:
+			slog.Error("Failed to send digest", "user_id", c.userID, "error", err)
+			return false
+		default:
+			sent = true
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE user_settings SET last_digest_at = $2 WHERE user_id = $1`, c.userID, now); err != nil {
+		slog.Error("Failed to record digest", "user_id", c.userID, "error", err)
+	}
+	return sent
+}
+
+// digestDrops returns the price drops userID was notified of after since,
+// newest first.
+func (s *Scheduler) digestDrops(ctx context.Context, userID string, since time.Time) ([]email.DigestDrop, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT COALESCE(t.product_name, ''), COALESCE(t.page_url, ''), n.old_price, n.new_price
+		FROM notifications n
+		LEFT JOIN tracked_items t ON t.id = n.product_id
+		WHERE n.user_id = $1 AND n.type = 'price_drop' AND n.created_at > $2
+		ORDER BY n.created_at DESC
+		LIMIT $3
+	`, userID, since, digestDropLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var drops []email.DigestDrop
+	for rows.Next() {
+		var d email.DigestDrop
+		if err := rows.Scan(&d.ProductName, &d.PageURL, &d.OldPrice, &d.NewPrice); err != nil {
+			return nil, err
+		}
+		drops = append(drops, d)
+	}
+	return drops, rows.Err()
+}
+
+// digestBody is the plain text of a digest email.
+func digestBody(d email.Digest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Your %s price drops:\n", d.Frequency)
+	for _, drop := range d.Drops {
+		fmt.Fprintf(&b, "\n- %s: %s -> %s", drop.ProductName, drop.OldPrice, drop.NewPrice)
+		if drop.PageURL != "" {
+			fmt.Fprintf(&b, " (%s)", drop.PageURL)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/email"
+)
+
+func TestSendDigests(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	sender := &recordingSender{}
+	cfg := DefaultConfig()
+	cfg.Email = email.NewNotifier(db, sender, []byte("secret"), "https://api.example.com", nil)
+	s := New(db, cfg)
+	// A Monday, an hour after digests are due.
+	now := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	lastWeek := now.AddDate(0, 0, -7)
+
+	mock.ExpectQuery("SELECT user_id, last_digest_at FROM user_settings").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "last_digest_at"}).
+			AddRow("weekly", lastWeek).
+			AddRow("sent-today", now.Add(-30*time.Minute)).
+			AddRow("quiet", nil).
+			AddRow("nothing-new", nil))
+
+	// A week of drops goes out and is recorded.
+	mock.ExpectQuery("FROM user_settings").WithArgs("weekly").
+		WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow(nil, "UTC", nil, nil, "weekly", nil, nil, nil))
+	mock.ExpectQuery("FROM notifications n").WithArgs("weekly", lastWeek, digestDropLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"product_name", "page_url", "old_price", "new_price"}).
+			AddRow("Kettle", "https://shop.example.com/kettle", "$129.99", "$99.99").
+			AddRow("Toaster", "", "$45.00", "$39.00"))
+	mock.ExpectQuery("SELECT notification_email").WithArgs("weekly").
+		WillReturnRows(sqlmock.NewRows([]string{"notification_email", "verified"}).AddRow("me@example.com", true))
+	mock.ExpectQuery("UPDATE user_settings SET unsubscribe_nonce").
+		WillReturnRows(sqlmock.NewRows([]string{"enabled", "unsubscribe_nonce"}).AddRow(true, "nonce"))
+	mock.ExpectExec("UPDATE user_settings SET last_digest_at").WithArgs("weekly", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Today's daily digest already went out.
+	mock.ExpectQuery("FROM user_settings").WithArgs("sent-today").
+		WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow(nil, "UTC", nil, nil, "daily", nil, nil, nil))
+
+	// Quiet hours hold the digest back until they end.
+	mock.ExpectQuery("FROM user_settings").WithArgs("quiet").
+		WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow(nil, "UTC", "08:00", "10:00", "daily", nil, nil, nil))
+
+	// Without drops nothing is sent, but the digest is still recorded.
+	mock.ExpectQuery("FROM user_settings").WithArgs("nothing-new").
+		WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow(nil, "UTC", nil, nil, "daily", nil, nil, nil))
+	mock.ExpectQuery("FROM notifications n").WithArgs("nothing-new", now.AddDate(0, 0, -1), digestDropLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"product_name", "page_url", "old_price", "new_price"}))
+	mock.ExpectExec("UPDATE user_settings SET last_digest_at").WithArgs("nothing-new", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s.sendDigests(context.Background())
+
+	if len(sender.sent) != 1 {
+		t.Fatalf("Expected 1 digest, got %d", len(sender.sent))
+	}
+	msg := sender.sent[0]
+	if msg.To != "me@example.com" || msg.Subject != "Your weekly price drops" {
+		t.Errorf("Expected the weekly digest to me@example.com, got %q to %q", msg.Subject, msg.To)
+	}
+	if !strings.Contains(msg.Body, "- Kettle: $129.99 -> $99.99 (https://shop.example.com/kettle)") || !strings.Contains(msg.Body, "- Toaster: $45.00 -> $39.00") {
+		t.Errorf("Expected both drops in the text, got:\n%s", msg.Body)
+	}
+	if !strings.Contains(msg.HTML, "Toaster") || !strings.Contains(msg.HTML, "<html") {
+		t.Errorf("Expected the drops in the HTML layout, got:\n%s", msg.HTML)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
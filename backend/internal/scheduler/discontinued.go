@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultDiscontinueAfter marks an item discontinued once its page has
+	// been gone for this many checks in a row, a day at the default interval.
+	defaultDiscontinueAfter = 24
+	// discontinuedRecheckInterval is how often scheduled runs still look at a
+	// discontinued item, so that a restored page revives it.
+	discontinuedRecheckInterval = 24 * time.Hour
+)
+
+// scheduledCond restricts a scheduled run to active items: not paused, not
+// backed off after failing (see backOff), and not discontinued unless the
+// item is due its daily recheck. The cutoff is bound to placeholder $n.
+func scheduledCond(n int) string {
+	return fmt.Sprintf(`paused_at IS NULL AND (next_check_at IS NULL OR next_check_at <= NOW()) AND (discontinued_at IS NULL OR NOT EXISTS (
+		SELECT 1 FROM scrape_log l WHERE l.item_id = tracked_items.id AND l.created_at >= $%d
+	))`, n)
+}
+
+// recordPageGone counts another check that found item's page gone. Once it
+// has been gone discontinueAfter checks in a row the item is discontinued and
+// its owner told, once; until then the scrape is an ordinary failure.
+func (s *Scheduler) recordPageGone(ctx context.Context, item Item, entry scrapeLogEntry, scrapeErr error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE tracked_items
+		SET not_found_count = not_found_count + 1
+		WHERE id = $1
+		RETURNING not_found_count
+	`, item.ID).Scan(&count)
+	if err != nil {
+		slog.Error("Failed to count missing page", "id", item.ID, "error", err)
+	}
+	if count < s.discontinueAfter {
+		s.setScrapeStatus(ctx, entry, "failed", scrapeErr)
+		return
+	}
+
+	if changed := s.setScrapeStatus(ctx, entry, "discontinued", scrapeErr); !changed {
+		return
+	}
+	slog.Warn("Item discontinued", "id", item.ID, "url", item.PageURL, "checks", count)
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE tracked_items SET discontinued_at = $1 WHERE id = $2
+	`, s.now(), item.ID); err != nil {
+		slog.Error("Failed to mark item discontinued", "id", item.ID, "error", err)
+	}
+	if err := s.sendDiscontinuedNotification(ctx, item); err != nil {
+		slog.Error("Failed to send notification", "error", err)
+	}
+}
+
+// reviveItem resets item's count of missing pages once its page loads again,
+// whether or not it has the price, which also puts a discontinued item back
+// on the schedule. The scrape's own
+// status replaces "discontinued".
+func (s *Scheduler) reviveItem(ctx context.Context, item Item, result ScrapeResult, scrapeErr error) {
+	if item.NotFoundCount == 0 || PageGone(scrapeErr) || (scrapeErr != nil && result.FinalURL == "") {
+		return
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE tracked_items
+		SET not_found_count = 0, discontinued_at = NULL
+		WHERE id = $1
+	`, item.ID)
+	if err != nil {
+		slog.Error("Failed to revive item", "id", item.ID, "error", err)
+	}
+}
+
+// lastKnownPrice returns the price item last changed to and when, from its
+// price history.
+func (s *Scheduler) lastKnownPrice(ctx context.Context, itemID string) (float64, time.Time, error) {
+	var price float64
+	var at time.Time
+	err := s.db.QueryRowContext(ctx, `
+		SELECT price, recorded_at FROM item_price_history
+		WHERE item_id = $1
+		ORDER BY recorded_at DESC, id DESC
+		LIMIT 1
+	`, itemID).Scan(&price, &at)
+	return price, at, err
+}
+
+func (s *Scheduler) sendDiscontinuedNotification(ctx context.Context, item Item) error {
+	title := "Product Discontinued"
+	message := fmt.Sprintf("The page for '%s' no longer exists, so we've stopped checking its price. We'll look again once a day in case it comes back.", item.ProductName)
+	lastPrice := ""
+	price, at, err := s.lastKnownPrice(ctx, item.ID)
+	switch {
+	case err == nil:
+		// History keeps the number; the item's text, written alongside it,
+		// has the currency.
+		lastPrice = strconv.FormatFloat(price, 'f', 2, 64)
+		if p, err := ParsePriceWith(item.PriceText, item.ParseStrategy); err == nil && p == price {
+			lastPrice = item.PriceText
+		}
+		message = fmt.Sprintf("The page for '%s' no longer exists, so we've stopped checking its price. Its last known price was %s on %s. We'll look again once a day in case it comes back.", item.ProductName, lastPrice, at.Format("Jan 2, 2006"))
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO notifications (user_id, title, message, type, product_id, old_price, is_read)
+		VALUES ($1, $2, $3, 'discontinued', $4, NULLIF($5, ''), false)
+	`, item.UserID, title, message, item.ID, lastPrice)
+
+	return err
+}
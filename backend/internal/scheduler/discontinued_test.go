@@ -0,0 +1,186 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestScrapeDetailed_PageGone(t *testing.T) {
+	for _, code := range []int{http.StatusNotFound, http.StatusGone} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}))
+		_, err := httpScraper(true).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price"})
+		if !PageGone(err) {
+			t.Errorf("%d: Expected the page to be reported gone, got %v", code, err)
+		}
+		ts.Close()
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	if _, err := httpScraper(true).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price"}); err == nil || PageGone(err) {
+		t.Errorf("Expected a 503 to be an ordinary failure, got %v", err)
+	}
+}
+
+func TestProcessItem_PageGone(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer ts.Close()
+
+	item := Item{ID: "item-1", UserID: "user-1", PriceText: "$24.00", ProductName: "Kettle", PageURL: ts.URL, CSSSelector: ".price", NotFoundCount: 22}
+
+	t.Run("below the threshold fails", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(`SET not_found_count = not_found_count \+ 1`).
+			WithArgs("item-1").
+			WillReturnRows(sqlmock.NewRows([]string{"not_found_count"}).AddRow(23))
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("failed", "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO scrape_log").
+			WithArgs("item-1", "user-1", "127.0.0.1", "failed", "not_found", "bad status code: 410", sqlmock.AnyArg(), false, nil, "en-US", "", "", "", "", int64(0)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		New(db, noBrowserConfig()).processItem(context.Background(), item)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("at the threshold discontinues and notifies", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		now := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+		s := New(db, noBrowserConfig())
+		s.now = func() time.Time { return now }
+
+		mock.ExpectQuery(`SET not_found_count = not_found_count \+ 1`).
+			WithArgs("item-1").
+			WillReturnRows(sqlmock.NewRows([]string{"not_found_count"}).AddRow(24))
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("discontinued", "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO scrape_log").
+			WithArgs("item-1", "user-1", "127.0.0.1", "discontinued", "not_found", "bad status code: 410", sqlmock.AnyArg(), false, nil, "en-US", "", "", "", "", int64(0)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`SET discontinued_at = \$1`).
+			WithArgs(now, "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("FROM item_price_history").
+			WithArgs("item-1").
+			WillReturnRows(sqlmock.NewRows([]string{"price", "recorded_at"}).AddRow(24.0, time.Date(2025, 5, 30, 9, 0, 0, 0, time.UTC)))
+		mock.ExpectExec("INSERT INTO notifications .*'discontinued'").
+			WithArgs("user-1", "Product Discontinued", sqlmock.AnyArg(), "item-1", "$24.00").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		s.processItem(context.Background(), item)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("already discontinued does not notify again", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(`SET not_found_count = not_found_count \+ 1`).
+			WithArgs("item-1").
+			WillReturnRows(sqlmock.NewRows([]string{"not_found_count"}).AddRow(31))
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("discontinued", "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO scrape_log").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		New(db, noBrowserConfig()).processItem(context.Background(), item)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestProcessItem_RevivesRestoredPage(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><span class="price">$24.00</span></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`SET not_found_count = 0, discontinued_at = NULL`).
+		WithArgs("item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("SET last_price").
+		WithArgs(24.0, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	item := Item{ID: "item-1", UserID: "user-1", PriceText: "$24.00", ProductName: "Kettle", PageURL: ts.URL, CSSSelector: ".price", NotFoundCount: 30}
+	New(db, noBrowserConfig()).processItem(context.Background(), item)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestProcessItem_PageGoneDisabled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("failed", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	cfg := noBrowserConfig()
+	cfg.DiscontinueAfter = 0
+	item := Item{ID: "item-1", UserID: "user-1", PriceText: "$24.00", ProductName: "Kettle", PageURL: ts.URL, CSSSelector: ".price"}
+	New(db, cfg).processItem(context.Background(), item)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"price-track-backend/internal/email"
+)
+
+// DropAverage steadies drop detection for prices that bounce around: a
+// drop below the last price is only notified when it also takes the price
+// Percent below its average over the last Window. The average weighs each
+// price by how long it held, as the history records changes, not checks.
+type DropAverage struct {
+	// Window is how far back the average reaches. Zero compares with the
+	// last price alone.
+	Window time.Duration
+	// Percent is how far below the average, in percent, the price must go.
+	Percent float64
+}
+
+// DefaultDropAveragePercent is how far below the average a price must drop
+// when DropAverage.Percent is not set.
+const DefaultDropAveragePercent = 5
+
+// belowRecentAverage reports whether price is far enough below the item's
+// average over the last DropAverage.Window to be a drop. Without a window,
+// or without history to average, every drop counts.
+func (s *Scheduler) belowRecentAverage(ctx context.Context, itemID string, price float64) bool {
+	if s.dropAverage.Window <= 0 {
+		return true
+	}
+	to := s.now()
+	from := to.Add(-s.dropAverage.Window)
+	history, err := s.priceHistory(ctx, itemID, from)
+	if err != nil {
+		slog.Warn("Failed to load price history, comparing with the last price", "id", itemID, "error", err)
+		return true
+	}
+	average, ok := timeWeightedAverage(history, from, to)
+	if !ok {
+		return true
+	}
+	threshold := average * (1 - s.dropAverage.Percent/100)
+	if price > threshold {
+		slog.Info("Price drop within the recent average", "id", itemID, "price", price, "average", average, "threshold", threshold)
+		return false
+	}
+	return true
+}
+
+// timeWeightedAverage averages the prices of history, ordered by time, over
+// from to to, each weighted by how long it held. A price recorded before
+// from holds from from; without one, the average starts at the first
+// price. It reports false when no price held for any time.
+func timeWeightedAverage(history []email.PricePoint, from, to time.Time) (float64, bool) {
+	var sum, total float64
+	for i, p := range history {
+		start, end := p.At, to
+		if start.Before(from) {
+			start = from
+		}
+		if i+1 < len(history) && history[i+1].At.Before(to) {
+			end = history[i+1].At
+		}
+		if held := end.Sub(start).Seconds(); held > 0 {
+			sum += p.Price * held
+			total += held
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return sum / total, true
+}
+
+// priceHistory returns the item's prices recorded since from, oldest first,
+// starting with the last one recorded before from, which still held then.
+func (s *Scheduler) priceHistory(ctx context.Context, itemID string, from time.Time) ([]email.PricePoint, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT price, recorded_at FROM (
+			(SELECT price, recorded_at FROM item_price_history
+			WHERE item_id = $1 AND recorded_at < $2
+			ORDER BY recorded_at DESC LIMIT 1)
+			UNION ALL
+			SELECT price, recorded_at FROM item_price_history
+			WHERE item_id = $1 AND recorded_at >= $2
+		) h
+		ORDER BY recorded_at
+	`, itemID, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var history []email.PricePoint
+	for rows.Next() {
+		var p email.PricePoint
+		if err := rows.Scan(&p.Price, &p.At); err != nil {
+			return nil, err
+		}
+		history = append(history, p)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/email"
+)
+
+// noisySeries is a week of a price bouncing between $100 and $95 every 12
+// hours, as some stores' prices do, starting a day before the week.
+func noisySeries(to time.Time) []email.PricePoint {
+	var history []email.PricePoint
+	for i := 0; i <= 16; i++ {
+		price := 100.0
+		if i%2 == 1 {
+			price = 95
+		}
+		history = append(history, email.PricePoint{At: to.Add(-8 * 24 * time.Hour).Add(time.Duration(i) * 12 * time.Hour), Price: price})
+	}
+	return history
+}
+
+func TestTimeWeightedAverage(t *testing.T) {
+	to := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	from := to.Add(-7 * 24 * time.Hour)
+
+	// The points before the window are cut off at its start, so each price
+	// holds for half of it.
+	if avg, ok := timeWeightedAverage(noisySeries(to), from, to); !ok || math.Abs(avg-97.5) > 1e-9 {
+		t.Errorf("Expected 97.5, got %v (%v)", avg, ok)
+	}
+
+	// $100 for six days, then $70 for the last one.
+	history := []email.PricePoint{{At: from.Add(-time.Hour), Price: 100}, {At: to.Add(-24 * time.Hour), Price: 70}}
+	if avg, _ := timeWeightedAverage(history, from, to); math.Abs(avg-(100*6+70)/7.0) > 1e-9 {
+		t.Errorf("Expected the price weighted by how long it held, got %v", avg)
+	}
+
+	// History that starts inside the window is averaged from its start.
+	if avg, _ := timeWeightedAverage(history[1:], from, to); avg != 70 {
+		t.Errorf("Expected 70, got %v", avg)
+	}
+	for _, history := range [][]email.PricePoint{nil, {{At: to, Price: 80}}} {
+		if _, ok := timeWeightedAverage(history, from, to); ok {
+			t.Errorf("Expected no average of %v", history)
+		}
+	}
+}
+
+func TestBelowRecentAverage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	cfg := DefaultConfig()
+	cfg.DropAverage.Window = 7 * 24 * time.Hour
+	s := New(db, cfg)
+	s.now = func() time.Time { return now }
+
+	// The noise, $100 falling to $95 once more, is no drop; $90 is more than
+	// 5% below the $97.50 average.
+	for _, tc := range []struct {
+		price float64
+		want  bool
+	}{{95, false}, {92.7, false}, {92.6, true}, {90, true}} {
+		rows := sqlmock.NewRows([]string{"price", "recorded_at"})
+		for _, p := range noisySeries(now)[1:] {
+			rows.AddRow(p.Price, p.At)
+		}
+		mock.ExpectQuery("FROM item_price_history").WithArgs("item-1", now.Add(-7*24*time.Hour)).WillReturnRows(rows)
+		if got := s.belowRecentAverage(context.Background(), "item-1", tc.price); got != tc.want {
+			t.Errorf("Expected %v for $%v, got %v", tc.want, tc.price, got)
+		}
+	}
+
+	// Without history every drop counts, as it does without a window.
+	mock.ExpectQuery("FROM item_price_history").WillReturnRows(sqlmock.NewRows([]string{"price", "recorded_at"}))
+	if !s.belowRecentAverage(context.Background(), "item-1", 99) {
+		t.Error("Expected a drop without history")
+	}
+	s.dropAverage.Window = 0
+	if !s.belowRecentAverage(context.Background(), "item-1", 99) {
+		t.Error("Expected a drop without a window")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
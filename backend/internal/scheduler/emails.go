@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"price-track-backend/internal/email"
+)
+
+// queuedEmailBatch bounds the queued emails sent on one tick.
+const queuedEmailBatch = 100
+
+// emailHistoryDays is how many days of price history drop emails chart.
+const emailHistoryDays = 30
+
+// sendEmail emails a price drop to the user's verified notification address.
+// Users without one, whose address is still unverified or who unsubscribed
+// from price drop emails get nothing. If n.DeliverAfter is in the future (the
+// user's quiet hours), the email is queued for flushQueuedEmails instead.
+func (s *Scheduler) sendEmail(ctx context.Context, n Notification) {
+	if s.email == nil {
+		return
+	}
+	subject := fmt.Sprintf("Price drop: %s", n.ProductName)
+	body := fmt.Sprintf("Good news! The price for '%s' dropped from %s to %s.", n.ProductName, n.OldPrice, n.NewPrice)
+	html, err := s.email.Templates().PriceDropHTML(s.priceDropEmail(ctx, n))
+	if err != nil {
+		// The plain text still goes out.
+		slog.Error("Failed to render price drop email", "item_id", n.ItemID, "error", err)
+		html = ""
+	}
+	if n.DeliverAfter.After(s.now()) {
+		slog.Info("Queueing email until quiet hours end", "user_id", n.UserID, "until", n.DeliverAfter)
+		if _, err := s.db.ExecContext(ctx, `
+			INSERT INTO queued_emails (user_id, channel, subject, body, html, deliver_after)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		`, n.UserID, string(email.ChannelPriceDrops), subject, body, html, n.DeliverAfter); err != nil {
+			slog.Error("Failed to queue email", "user_id", n.UserID, "error", err)
+		}
+		return
+	}
+	s.notifyEmail(ctx, n.UserID, email.ChannelPriceDrops, subject, body, html)
+}
+
+// priceDropEmail returns what n's email shows: the item's image and page
+// and, unless a variant dropped, its prices over the last emailHistoryDays.
+// What cannot be loaded is left out.
+func (s *Scheduler) priceDropEmail(ctx context.Context, n Notification) email.PriceDrop {
+	d := email.PriceDrop{ProductName: n.ProductName, OldPrice: n.OldPrice, NewPrice: n.NewPrice, Percent: n.DropPercent}
+	var imageURL, pageURL sql.NullString
+	if err := s.db.QueryRowContext(ctx, `SELECT image_url, page_url FROM tracked_items WHERE id = $1`, n.ItemID).Scan(&imageURL, &pageURL); err != nil {
+		slog.Warn("Failed to load item for email", "item_id", n.ItemID, "error", err)
+		return d
+	}
+	d.ImageURL, d.PageURL = imageURL.String, pageURL.String
+	if n.VariantID != 0 {
+		return d
+	}
+
+	d.HistoryTo = s.now()
+	d.HistoryFrom = d.HistoryTo.AddDate(0, 0, -emailHistoryDays)
+	// The last price before the window is the one the chart starts at.
+	history, err := s.priceHistory(ctx, n.ItemID, d.HistoryFrom)
+	if err != nil {
+		slog.Warn("Failed to load price history for email", "item_id", n.ItemID, "error", err)
+		return d
+	}
+	d.History = history
+	return d
+}
+
+// notifyEmail sends one email through the notifier, logging the outcome.
+func (s *Scheduler) notifyEmail(ctx context.Context, userID string, channel email.Channel, subject, body, html string) {
+	err := s.email.Notify(ctx, userID, channel, subject, body, html)
+	switch {
+	case errors.Is(err, email.ErrNoAddress), errors.Is(err, email.ErrUnsubscribed):
+	case errors.Is(err, email.ErrUnverified):
+		slog.Info("Not emailing unverified address", "user_id", userID)
+	case err != nil:
+		slog.Error("Failed to send email", "user_id", userID, "error", err)
+	}
+}
+
+// queuedEmail is an email held back by quiet hours.
+type queuedEmail struct {
+	id      int64
+	userID  string
+	channel email.Channel
+	subject string
+	body    string
+	html    string
+}
+
+// flushQueuedEmails sends the queued emails whose quiet hours have ended.
+// Each is deleted once tried; whether the user still has a verified address
+// and is still subscribed is checked at sending time, not when queued.
+func (s *Scheduler) flushQueuedEmails(ctx context.Context) {
+	if s.email == nil {
+		return
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, channel, subject, body, COALESCE(html, '')
+		FROM queued_emails
+		WHERE deliver_after <= $1
+		ORDER BY deliver_after, id
+		LIMIT $2
+	`, s.now(), queuedEmailBatch)
+	if err != nil {
+		slog.Error("Failed to query queued emails", "error", err)
+		return
+	}
+	var due []queuedEmail
+	for rows.Next() {
+		var q queuedEmail
+		if err := rows.Scan(&q.id, &q.userID, &q.channel, &q.subject, &q.body, &q.html); err != nil {
+			slog.Error("Failed to scan queued email", "error", err)
+			continue
+		}
+		due = append(due, q)
+	}
+	rows.Close()
+
+	for _, q := range due {
+		s.notifyEmail(ctx, q.userID, q.channel, q.subject, q.body, q.html)
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM queued_emails WHERE id = $1`, q.id); err != nil {
+			slog.Error("Failed to delete queued email", "id", q.id, "error", err)
+		}
+	}
+	if len(due) > 0 {
+		slog.Info("Sent queued emails", "count", len(due))
+	}
+}
@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPriceDropEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	from := now.AddDate(0, 0, -emailHistoryDays)
+	itemColumns := []string{"image_url", "page_url"}
+	mock.ExpectQuery("SELECT image_url, page_url FROM tracked_items").
+		WithArgs("item-1").
+		WillReturnRows(sqlmock.NewRows(itemColumns).AddRow("https://images.example.com/kettle.jpg", "https://shop.example.com/kettle"))
+	mock.ExpectQuery(`FROM item_price_history\s+WHERE item_id = \$1 AND recorded_at < \$2`).
+		WithArgs("item-1", from).
+		WillReturnRows(sqlmock.NewRows([]string{"price", "recorded_at"}).
+			AddRow(129.99, time.Date(2025, 4, 20, 9, 0, 0, 0, time.UTC)).
+			AddRow(99.99, now))
+
+	cfg := DefaultConfig()
+	s := New(db, cfg)
+	s.now = func() time.Time { return now }
+	n := Notification{UserID: "user-1", ItemID: "item-1", ProductName: "Kettle", OldPrice: "$129.99", NewPrice: "$99.99", DropPercent: 23.08}
+	d := s.priceDropEmail(context.Background(), n)
+	if d.ImageURL != "https://images.example.com/kettle.jpg" || d.PageURL != "https://shop.example.com/kettle" || d.Percent != 23.08 {
+		t.Errorf("Expected the item's image, page and drop, got %+v", d)
+	}
+	if len(d.History) != 2 || d.History[0].Price != 129.99 || !d.HistoryFrom.Equal(from) || !d.HistoryTo.Equal(now) {
+		t.Errorf("Expected 30 days of history starting at the price before, got %+v from %v to %v", d.History, d.HistoryFrom, d.HistoryTo)
+	}
+
+	// A variant's drop is not charted with the item's history.
+	mock.ExpectQuery("SELECT image_url, page_url FROM tracked_items").
+		WithArgs("item-1").
+		WillReturnRows(sqlmock.NewRows(itemColumns).AddRow(nil, "https://shop.example.com/kettle"))
+	n.VariantID = 3
+	if d := s.priceDropEmail(context.Background(), n); d.History != nil || d.ImageURL != "" {
+		t.Errorf("Expected no history or image, got %+v", d)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
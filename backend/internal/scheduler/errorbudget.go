@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// defaultErrorBudget tells users about items that fail more than half
+	// their scrapes.
+	defaultErrorBudget = 0.5
+	// defaultErrorBudgetWindow is the span of scrape log the failure ratio
+	// is computed over, and how often an item can be reported.
+	defaultErrorBudgetWindow = 7 * 24 * time.Hour
+	// errorBudgetMinScrapes keeps a new item's first failure or two from
+	// counting as a 100% failure ratio.
+	errorBudgetMinScrapes = 5
+)
+
+// unreliableItem is an item that used up its error budget.
+type unreliableItem struct {
+	id, userID, productName string
+	scrapes, failures       int
+}
+
+// checkErrorBudgets tells the owners of active items whose scrapes failed more
+// often than errorBudget over the last errorBudgetWindow that tracking is
+// unreliable. A scrape fails when it yields no price that could be used:
+// failed, out of bounds or outvoted by other items on the page. Each item is
+// reported at most once per window.
+func (s *Scheduler) checkErrorBudgets(ctx context.Context) {
+	if s.errorBudget <= 0 || s.errorBudgetWindow <= 0 {
+		return
+	}
+	items, err := s.unreliableItems(ctx, s.now().Add(-s.errorBudgetWindow))
+	if err != nil {
+		slog.Error("Failed to check error budgets", "error", err)
+		return
+	}
+	for _, item := range items {
+		slog.Warn("Item exceeded its error budget", "id", item.id, "scrapes", item.scrapes, "failures", item.failures)
+		if err := s.sendTrackingUnreliableNotification(ctx, item); err != nil {
+			slog.Error("Failed to send notification", "error", err)
+		}
+	}
+}
+
+// unreliableItems returns the active items with more than errorBudget of their
+// scrapes since since failed, leaving out those already reported since then.
+func (s *Scheduler) unreliableItems(ctx context.Context, since time.Time) ([]unreliableItem, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.id, t.user_id, t.product_name, l.scrapes, l.failures
+		FROM (
+			SELECT item_id, COUNT(*) AS scrapes,
+				COUNT(*) FILTER (WHERE status IN ('failed', 'suspicious', 'outlier')) AS failures
+			FROM scrape_log
+			WHERE created_at >= $1
+			GROUP BY item_id
+		) l
+		JOIN tracked_items t ON t.id = l.item_id
+		WHERE t.paused_at IS NULL AND t.discontinued_at IS NULL AND l.scrapes >= $2 AND l.failures > l.scrapes * $3::float8
+			AND NOT EXISTS (
+				SELECT 1 FROM notifications n
+				WHERE n.product_id = t.id AND n.type = 'tracking_unreliable' AND n.created_at >= $1
+			)
+		ORDER BY t.id
+	`, since, errorBudgetMinScrapes, s.errorBudget)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []unreliableItem
+	for rows.Next() {
+		var item unreliableItem
+		if err := rows.Scan(&item.id, &item.userID, &item.productName, &item.scrapes, &item.failures); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *Scheduler) sendTrackingUnreliableNotification(ctx context.Context, item unreliableItem) error {
+	title := "Price Tracking Unreliable"
+	message := fmt.Sprintf("We couldn't get a usable price for '%s' in %d of its last %d checks, so price drops may be missed. Check that the product page still works and re-pick the price if it moved.", item.productName, item.failures, item.scrapes)
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO notifications (user_id, title, message, type, product_id, is_read)
+		VALUES ($1, $2, $3, 'tracking_unreliable', $4, false)
+	`, item.userID, title, message, item.id)
+
+	return err
+}
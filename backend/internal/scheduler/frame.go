@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/playwright-community/playwright-go"
+)
+
+// Frame picks the iframe an item's price lives in, by a CSS selector for the
+// iframe element or by part of its src URL. The zero Frame means the price
+// is on the page itself.
+type Frame struct {
+	Selector string
+	URL      string
+}
+
+// IsZero reports whether the price is outside any iframe.
+func (f Frame) IsZero() bool {
+	return f.Selector == "" && f.URL == ""
+}
+
+// cssEscaper escapes a value for a double-quoted CSS string.
+var cssEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// iframeSelector returns the CSS selector for the iframe element.
+func (f Frame) iframeSelector() string {
+	if f.Selector != "" {
+		return f.Selector
+	}
+	return `iframe[src*="` + cssEscaper.Replace(f.URL) + `"]`
+}
+
+func (f Frame) String() string {
+	if f.Selector != "" {
+		return "iframe " + f.Selector
+	}
+	return "iframe with src containing " + f.URL
+}
+
+// frameSource finds the iframe in doc, a page fetched from base, and returns
+// the absolute URL of its document.
+func frameSource(doc *goquery.Document, base *url.URL, frame Frame) (string, error) {
+	src, ok := doc.Find(frame.iframeSelector()).First().Attr("src")
+	if !ok || strings.TrimSpace(src) == "" {
+		return "", selectorNotFound(doc, "element not found: %s", frame)
+	}
+	u, err := base.Parse(strings.TrimSpace(src))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("unsupported iframe src: %q", src)
+	}
+	return u.String(), nil
+}
+
+// priceLocator returns the locator for every match of cssSelector on page,
+// entering the item's iframe first when it has one.
+func priceLocator(page playwright.Page, cssSelector string, frame Frame) playwright.Locator {
+	if frame.IsZero() {
+		return page.Locator(cssSelector)
+	}
+	return page.Locator(frame.iframeSelector()).First().ContentFrame().Locator(cssSelector)
+}
@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// framedShop renders its price inside an iframe whose src is relative to the
+// product page, next to an unrelated reviews iframe.
+func framedShop() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/p/widgets/price":
+			w.Write([]byte(`<html><body><span class="price">$24.50</span></body></html>`))
+		case "/widgets/reviews":
+			w.Write([]byte(`<html><body><span class="price">4.5 stars</span></body></html>`))
+		default:
+			w.Write([]byte(`<html><body>
+				<h1>Kettle</h1>
+				<iframe id="reviews" src="/widgets/reviews"></iframe>
+				<iframe id="buy-box" src="widgets/price?sku=42"></iframe>
+			</body></html>`))
+		}
+	}))
+}
+
+func TestScrapeDetailed_Frame(t *testing.T) {
+	ts := framedShop()
+	defer ts.Close()
+
+	if _, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL + "/p/kettle", CSSSelector: ".price"}); !errors.Is(err, ErrSelectorNotFound) {
+		t.Errorf("Expected the framed price to be out of reach without a frame, got %v", err)
+	}
+
+	for _, frame := range []Frame{{Selector: "iframe#buy-box"}, {URL: "widgets/price"}} {
+		result, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL + "/p/kettle", CSSSelector: ".price", Frame: frame})
+		if err != nil {
+			t.Errorf("%s: ScrapeDetailed failed: %v", frame, err)
+			continue
+		}
+		if result.Text != "$24.50" {
+			t.Errorf("%s: Expected $24.50, got %q", frame, result.Text)
+		}
+	}
+
+	_, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL + "/p/kettle", CSSSelector: ".price", Frame: Frame{Selector: "iframe#checkout"}})
+	if !errors.Is(err, ErrSelectorNotFound) {
+		t.Errorf("Expected a missing iframe to count as a broken selector, got %v", err)
+	}
+}
+
+func TestFrame_IframeSelector(t *testing.T) {
+	if got := (Frame{URL: `pay.example.com/"widget"`}).iframeSelector(); got != `iframe[src*="pay.example.com/\"widget\""]` {
+		t.Errorf("Unexpected selector %s", got)
+	}
+	if got := (Frame{Selector: "#buy-box iframe"}).iframeSelector(); got != "#buy-box iframe" {
+		t.Errorf("Unexpected selector %s", got)
+	}
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// parseHeaders decodes an item's headers column. NULL means none.
+func parseHeaders(data []byte) (map[string]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var headers map[string]string
+	return headers, json.Unmarshal(data, &headers)
+}
+
+// headerNames returns the canonical names of headers, sorted. Values are
+// never logged.
+func headerNames(headers map[string]string) []string {
+	if len(headers) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// headersSignature distinguishes scrapes of the same page made with different
+// headers, which may see different prices.
+func headersSignature(headers map[string]string) string {
+	canonical := mergeHeaders(nil, headers)
+	var b strings.Builder
+	for _, name := range headerNames(canonical) {
+		b.WriteString(name + ":" + canonical[name] + "\x00")
+	}
+	return b.String()
+}
+
+// mergeHeaders returns defaults overridden by headers, matching names
+// case-insensitively. defaults is not modified.
+func mergeHeaders(defaults, headers map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(headers))
+	for name, value := range defaults {
+		merged[http.CanonicalHeaderKey(name)] = value
+	}
+	for name, value := range headers {
+		merged[http.CanonicalHeaderKey(name)] = value
+	}
+	return merged
+}
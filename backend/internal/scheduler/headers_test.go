@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// apiKeyShop only shows the price to requests carrying X-Api-Key: k3y.
+func apiKeyShop(seen *http.Header) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*seen = r.Header.Clone()
+		w.Header().Set("Content-Type", "text/html")
+		if r.Header.Get("X-Api-Key") == "k3y" {
+			w.Write([]byte(`<html><body><div class="price">$42.00</div></body></html>`))
+			return
+		}
+		w.Write([]byte(`<html><body>Members only</body></html>`))
+	}))
+}
+
+func TestScrapeDetailed_Headers(t *testing.T) {
+	var seen http.Header
+	ts := apiKeyShop(&seen)
+	defer ts.Close()
+
+	if _, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price"}); !errors.Is(err, ErrSelectorNotFound) {
+		t.Errorf("Expected no price without the header, got %v", err)
+	}
+
+	headers := map[string]string{"X-Api-Key": "k3y", "User-Agent": "PartnerBot/1.0"}
+	result, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price", Headers: headers})
+	if err != nil {
+		t.Fatalf("ScrapeDetailed failed: %v", err)
+	}
+	if result.Text != "$42.00" {
+		t.Errorf("Expected $42.00, got %q", result.Text)
+	}
+	if got := seen.Get("User-Agent"); got != "PartnerBot/1.0" {
+		t.Errorf("Expected the item's User-Agent to override ours, got %q", got)
+	}
+	if got := seen.Get("Accept-Language"); got != "en-US,en;q=0.9" {
+		t.Errorf("Expected the default Accept-Language to be kept, got %q", got)
+	}
+}
+
+func TestNewScrapeLogEntry_HeaderNamesOnly(t *testing.T) {
+	item := Item{ID: "item-1", PageURL: "https://example.com/p", Headers: map[string]string{"x-api-key": "k3y", "X-Region": "eu"}}
+
+	entry := newScrapeLogEntry(item, ScrapeResult{})
+
+	if want := []string{"X-Api-Key", "X-Region"}; !reflect.DeepEqual(entry.HeaderNames, want) {
+		t.Errorf("Expected header names %v, got %v", want, entry.HeaderNames)
+	}
+}
+
+func TestMergeHeaders(t *testing.T) {
+	defaults := map[string]string{"Accept": "text/html", "DNT": "1"}
+
+	merged := mergeHeaders(defaults, map[string]string{"accept": "application/json", "X-Api-Key": "k3y"})
+
+	want := map[string]string{"Accept": "application/json", "Dnt": "1", "X-Api-Key": "k3y"}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Expected %v, got %v", want, merged)
+	}
+	if defaults["Accept"] != "text/html" {
+		t.Error("Expected the defaults to be left alone")
+	}
+}
+
+func TestGroupItems_SeparatesHeaders(t *testing.T) {
+	items := []Item{
+		{ID: "a", PageURL: "https://example.com/p", CSSSelector: ".price"},
+		{ID: "b", PageURL: "https://example.com/p", CSSSelector: ".price", Headers: map[string]string{"X-Api-Key": "k3y"}},
+	}
+	if groups := groupItems(items); len(groups) != 2 {
+		t.Errorf("Expected items with different headers to be scraped apart, got %d groups", len(groups))
+	}
+}
@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// recordHeartbeat stores the time the last full pass finished, so that a
+// wedged or stopped scraper job can be detected from the API server.
+func (s *Scheduler) recordHeartbeat(ctx context.Context) {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO scheduler_heartbeat (id, last_run_at)
+		VALUES (TRUE, $1)
+		ON CONFLICT (id) DO UPDATE SET last_run_at = EXCLUDED.last_run_at
+	`, s.now())
+	if err != nil {
+		slog.Error("Failed to record scheduler heartbeat", "error", err)
+	}
+}
+
+// LastRun returns when the last full pass finished. ok is false if no pass
+// has ever finished.
+func LastRun(ctx context.Context, db *sql.DB) (t time.Time, ok bool, err error) {
+	err = db.QueryRowContext(ctx, `SELECT last_run_at FROM scheduler_heartbeat`).Scan(&t)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, false, nil
+	}
+	return t, err == nil, err
+}
@@ -0,0 +1,276 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// JobReason is why a price check was queued in scrape_jobs. It sets the
+// job's priority: workers claim the highest priority first, so a check a
+// user asked for jumps the scheduled ones.
+type JobReason string
+
+const (
+	JobScheduled  JobReason = "scheduled"
+	JobManual     JobReason = "manual"
+	JobRevalidate JobReason = "revalidate"
+)
+
+// priority is the priority jobs queued for r get.
+func (r JobReason) priority() int {
+	switch r {
+	case JobManual:
+		return 100
+	case JobRevalidate:
+		return 50
+	}
+	return 0
+}
+
+const (
+	// jobClaimTTL is how long a claimed job may run before another worker
+	// may claim it again, assuming its worker died. It matches the scraper
+	// job's own limit; a batch that outlasts it may be checked twice, which
+	// costs a second scrape and nothing else.
+	jobClaimTTL = time.Hour
+	// jobRetention is how long finished jobs are kept for run summaries
+	// before they are deleted.
+	jobRetention = 7 * 24 * time.Hour
+)
+
+// claimedJob is a job a worker claimed from scrape_jobs.
+type claimedJob struct {
+	id     int64
+	itemID string
+}
+
+// workerName identifies this process in scrape_jobs.claimed_by.
+func workerName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// EnqueueJobs queues a price check of each of itemIDs and returns the jobs'
+// IDs. An item that already has a job waiting or running keeps it, at the
+// higher of the two priorities and due by the sooner of the two times, and
+// its ID is returned instead.
+func (s *Scheduler) EnqueueJobs(ctx context.Context, reason JobReason, itemIDs []string) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		INSERT INTO scrape_jobs (item_id, reason, priority, run_after)
+		SELECT item_id, $2, $3, $4 FROM unnest($1::text[]) AS item_id
+		ON CONFLICT (item_id) WHERE state IN ('queued', 'running')
+		DO UPDATE SET priority = GREATEST(scrape_jobs.priority, EXCLUDED.priority),
+			run_after = LEAST(scrape_jobs.run_after, EXCLUDED.run_after)
+		RETURNING id
+	`, pq.Array(itemIDs), reason, reason.priority(), s.now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// runQueued queues checks of itemIDs for reason and works off those jobs
+// right away, ahead of the rest of the queue.
+func (s *Scheduler) runQueued(ctx context.Context, scope string, reason JobReason, itemIDs []string) {
+	if len(itemIDs) == 0 {
+		return
+	}
+	jobs, err := s.EnqueueJobs(ctx, reason, itemIDs)
+	if err != nil {
+		slog.Error("Failed to queue checks", "scope", scope, "error", err)
+		return
+	}
+	if _, err := s.runJobs(ctx, scope, jobs); err != nil {
+		slog.Error("Failed to claim jobs", "scope", scope, "error", err)
+	}
+}
+
+// enqueueDue queues a scheduled check of every item matching cond that has no
+// job waiting or running yet.
+func (s *Scheduler) enqueueDue(ctx context.Context, cond string, args ...any) {
+	n := len(args)
+	query := fmt.Sprintf(`
+		INSERT INTO scrape_jobs (item_id, reason, priority, run_after)
+		SELECT id, $%d, $%d, $%d FROM tracked_items
+		WHERE %s
+		ON CONFLICT (item_id) WHERE state IN ('queued', 'running') DO NOTHING
+	`, n+1, n+2, n+3, cond)
+	result, err := s.db.ExecContext(ctx, query, append(append([]any{}, args...), JobScheduled, JobScheduled.priority(), s.now())...)
+	if err != nil {
+		slog.Error("Failed to queue due items", "error", err)
+		return
+	}
+	if queued, _ := result.RowsAffected(); queued > 0 {
+		slog.Info("Queued due items", "count", queued)
+	}
+}
+
+// claimJobs claims up to batchSize jobs for this worker: queued ones whose
+// time has come and ones whose claim expired, highest priority first and
+// within a priority in the order seed gives their items (see orderKey), so
+// that the jobs a run has no time left for are not always the same ones.
+// Jobs other workers are claiming at the same moment are skipped rather than
+// waited for. When only is non-empty, only those jobs are claimed.
+func (s *Scheduler) claimJobs(ctx context.Context, seed string, only []int64) ([]claimedJob, error) {
+	now := s.now()
+	args := []any{s.worker, now, now.Add(-jobClaimTTL), seed}
+	filter := ""
+	if len(only) > 0 {
+		filter = " AND id = ANY($5)"
+		args = append(args, pq.Array(only))
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		UPDATE scrape_jobs
+		SET state = 'running', claimed_by = $1, claimed_at = $2, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM scrape_jobs
+			WHERE ((state = 'queued' AND run_after <= $2) OR (state = 'running' AND claimed_at < $3))%s
+			ORDER BY priority DESC, md5($4 || item_id), id
+			LIMIT %d
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, item_id
+	`, filter, s.batchSize), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []claimedJob
+	for rows.Next() {
+		var job claimedJob
+		if err := rows.Scan(&job.id, &job.itemID); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// runJobs claims jobs and checks their items until there are none left or
+// the run is out of time, and returns how many it claimed. Every batch it
+// claims is checked as part of one run, with one seed, scrape memo and
+// scraper start. When only is non-empty, only those jobs are run.
+func (s *Scheduler) runJobs(ctx context.Context, scope string, only []int64) (int, error) {
+	run := s.startRun(ctx, scope)
+	defer s.finishRun(run)
+	claimed := 0
+	for !s.outOfTime(ctx) {
+		jobs, err := s.claimJobs(ctx, run.seed, only)
+		if err != nil {
+			return claimed, err
+		}
+		if len(jobs) == 0 {
+			break
+		}
+		claimed += len(jobs)
+		s.runClaimed(ctx, run, jobs)
+	}
+	return claimed, nil
+}
+
+// runClaimed checks the items of jobs through checkPrices, as part of run,
+// and records each job's outcome: the status its item's check ended with.
+// Items skipped for lack of time go back to the queue for the next run;
+// items that were not checked at all (deleted meanwhile) fail their job.
+func (s *Scheduler) runClaimed(ctx context.Context, run *priceRun, jobs []claimedJob) {
+	itemIDs := make([]string, len(jobs))
+	for i, job := range jobs {
+		itemIDs[i] = job.itemID
+	}
+
+	var mu sync.Mutex
+	statuses := make(map[string]string, len(jobs))
+	report, _ := ctx.Value(itemResultsKey{}).(func(ItemResult))
+	runCtx := WithItemResults(ctx, func(result ItemResult) {
+		if report != nil {
+			report(result)
+		}
+		if result.Variant != "" {
+			return
+		}
+		mu.Lock()
+		statuses[result.ItemID] = result.Status
+		mu.Unlock()
+	})
+	s.checkPrices(runCtx, run, "id = ANY($1)", pq.Array(itemIDs))
+
+	ids := make([]int64, len(jobs))
+	results := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i], results[i] = job.id, statuses[job.itemID]
+	}
+	// The checks happened even if the run was cut short.
+	_, err := s.db.ExecContext(context.WithoutCancel(ctx), `
+		UPDATE scrape_jobs j
+		SET state = CASE r.status WHEN 'skipped' THEN 'queued' WHEN '' THEN 'failed' ELSE 'done' END,
+			result_status = NULLIF(r.status, ''),
+			finished_at = CASE r.status WHEN 'skipped' THEN NULL ELSE $3 END
+		FROM unnest($1::bigint[], $2::text[]) AS r (id, status)
+		WHERE j.id = r.id
+	`, pq.Array(ids), pq.Array(results), s.now())
+	if err != nil {
+		slog.Error("Failed to record job results", "scope", run.scope, "error", err)
+	}
+}
+
+// pruneJobs deletes jobs that finished more than jobRetention ago.
+func (s *Scheduler) pruneJobs(ctx context.Context) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM scrape_jobs
+		WHERE state IN ('done', 'failed') AND finished_at < $1
+	`, s.now().Add(-jobRetention))
+	if err != nil {
+		slog.Error("Failed to prune finished jobs", "error", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		slog.Info("Pruned finished jobs", "count", n)
+	}
+}
+
+// jobSummary counts the jobs with the given IDs by the status their item's
+// check ended with, and those without one by state ("queued", "running" or
+// "failed").
+func (s *Scheduler) jobSummary(ctx context.Context, ids []int64) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT COALESCE(result_status, state), COUNT(*)
+		FROM scrape_jobs
+		WHERE id = ANY($1)
+		GROUP BY 1
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] += n
+	}
+	return counts, rows.Err()
+}
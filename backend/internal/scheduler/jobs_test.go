@@ -0,0 +1,238 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// claimQuery matches claimJobs' query.
+const claimQuery = `UPDATE scrape_jobs\s+SET state = 'running'`
+
+// expectQueuedRun expects a full run to prune old jobs, queue the due items
+// and claim them as jobs 1, 2, ... in a single batch.
+func expectQueuedRun(mock sqlmock.Sqlmock, itemIDs ...string) {
+	mock.ExpectExec("DELETE FROM scrape_jobs").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO scrape_jobs").WillReturnResult(sqlmock.NewResult(0, int64(len(itemIDs))))
+	rows := sqlmock.NewRows([]string{"id", "item_id"})
+	for i, id := range itemIDs {
+		rows.AddRow(int64(i+1), id)
+	}
+	mock.ExpectQuery(claimQuery).WillReturnRows(rows)
+	mock.ExpectExec("UPDATE scrape_jobs j").WillReturnResult(sqlmock.NewResult(0, int64(len(itemIDs))))
+	mock.ExpectQuery(claimQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}))
+}
+
+func TestJobReason_Priority(t *testing.T) {
+	if !(JobManual.priority() > JobRevalidate.priority() && JobRevalidate.priority() > JobScheduled.priority()) {
+		t.Errorf("Expected manual checks ahead of revalidation ahead of scheduled checks, got %d, %d, %d",
+			JobManual.priority(), JobRevalidate.priority(), JobScheduled.priority())
+	}
+}
+
+func TestEnqueueJobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New(db, localConfig())
+	s.now = func() time.Time { return now }
+
+	// item-1 already had a scheduled job, which keeps its ID and is moved
+	// up to now.
+	mock.ExpectQuery(`INSERT INTO scrape_jobs .*ON CONFLICT \(item_id\) WHERE state IN \('queued', 'running'\)\s+DO UPDATE SET priority = GREATEST\(scrape_jobs.priority, EXCLUDED.priority\),\s+run_after = LEAST\(scrape_jobs.run_after, EXCLUDED.run_after\)`).
+		WithArgs(`{"item-1","item-2"}`, JobManual, 100, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(7)).AddRow(int64(12)))
+
+	ids, err := s.EnqueueJobs(context.Background(), JobManual, []string{"item-1", "item-2"})
+	if err != nil {
+		t.Fatalf("EnqueueJobs failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 7 || ids[1] != 12 {
+		t.Errorf("Expected jobs [7 12], got %v", ids)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestClaimJobs_PriorityOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New(db, localConfig())
+	s.now = func() time.Time { return now }
+	s.worker = "worker-1"
+	s.batchSize = 2
+
+	// The highest priority comes first, then the run's order; rows another
+	// worker is claiming are skipped, not waited for.
+	mock.ExpectQuery(claimQuery+`.*ORDER BY priority DESC, md5\(\$4 \|\| item_id\), id\s+LIMIT 2\s+FOR UPDATE SKIP LOCKED`).
+		WithArgs("worker-1", now, now.Add(-jobClaimTTL), "run-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}).AddRow(int64(9), "manual-item").AddRow(int64(3), "scheduled-item"))
+
+	jobs, err := s.claimJobs(context.Background(), "run-1", nil)
+	if err != nil {
+		t.Fatalf("claimJobs failed: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0] != (claimedJob{9, "manual-item"}) || jobs[1] != (claimedJob{3, "scheduled-item"}) {
+		t.Errorf("Expected both claimed jobs, got %+v", jobs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestClaimJobs_ExpiredClaim(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New(db, localConfig())
+	s.now = func() time.Time { return now }
+	s.worker = "worker-2"
+
+	// A job claimed more than jobClaimTTL ago belongs to a worker that died
+	// and is claimed again; only the jobs asked for are considered.
+	mock.ExpectQuery(claimQuery+`.*\(state = 'running' AND claimed_at < \$3\)\) AND id = ANY\(\$5\)`).
+		WithArgs("worker-2", now, now.Add(-time.Hour), "run-1", "{4}").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}).AddRow(int64(4), "item-1"))
+
+	jobs, err := s.claimJobs(context.Background(), "run-1", []int64{4})
+	if err != nil {
+		t.Fatalf("claimJobs failed: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].id != 4 {
+		t.Errorf("Expected the expired job to be claimed, got %+v", jobs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestRunJobs_RecordsResults(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$19.99</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New(db, noBrowserConfig())
+	s.now = func() time.Time { return now }
+
+	mock.ExpectQuery(claimQuery).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}).AddRow(int64(1), "item-1").AddRow(int64(2), "item-gone"))
+	// item-gone was deleted after it was queued.
+	columns := []string{"id", "user_id", "price_text", "product_name", "page_url", "css_selector", "xpath", "frame_selector", "frame_url", "variant_selector", "variant_value", "adapter_order", "min_expected", "max_expected", "parse_strategy", "accept_language", "country_code", "cookies_encrypted", "headers", "proxy_url", "scrape_profile", "final_url", "not_found_count", "max_scrape_seconds", "failure_streak", "selector_match", "shipping_selector", "total_price", "notification_channel", "last_notified_price", "availability_selector", "availability", "variants"}
+	mock.ExpectQuery(`FROM tracked_items\s+WHERE id = ANY\(\$1\)`).
+		WithArgs(`{"item-1","item-gone"}`, sqlmock.AnyArg(), "").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("item-1", "user-1", "$19.99", "Switch", ts.URL+"/switch", ".price", "", "", "", "", "", "first", nil, nil, "auto", "en-US", "", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil))
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SET last_price").
+		WithArgs(19.99, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectScrapeLogBatch(mock, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE scrape_jobs j\s+SET state = CASE r.status WHEN 'skipped' THEN 'queued' WHEN '' THEN 'failed' ELSE 'done' END`).
+		WithArgs("{1,2}", `{"success",""}`, now).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(claimQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}))
+
+	var reported []string
+	ctx := WithItemResults(context.Background(), func(result ItemResult) { reported = append(reported, result.ItemID) })
+	claimed, err := s.runJobs(ctx, "test", nil)
+	if err != nil {
+		t.Fatalf("runJobs failed: %v", err)
+	}
+	if claimed != 2 {
+		t.Errorf("Expected 2 jobs claimed, got %d", claimed)
+	}
+	// The caller's own results function still hears about every item.
+	if len(reported) != 1 || reported[0] != "item-1" {
+		t.Errorf("Expected item-1 reported to the caller, got %q", reported)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestRunJobs_OneRunAcrossBatches(t *testing.T) {
+	var mu sync.Mutex
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$19.99</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New(db, noBrowserConfig())
+	s.now = func() time.Time { return now }
+	s.batchSize = 1
+
+	// Two items on the same page, claimed one batch at a time: every claim
+	// uses the run's seed, and the page is fetched once for both.
+	columns := []string{"id", "user_id", "price_text", "product_name", "page_url", "css_selector", "xpath", "frame_selector", "frame_url", "variant_selector", "variant_value", "adapter_order", "min_expected", "max_expected", "parse_strategy", "accept_language", "country_code", "cookies_encrypted", "headers", "proxy_url", "scrape_profile", "final_url", "not_found_count", "max_scrape_seconds", "failure_streak", "selector_match", "shipping_selector", "total_price", "notification_channel", "last_notified_price", "availability_selector", "availability", "variants"}
+	for i, id := range []string{"item-1", "item-2"} {
+		mock.ExpectQuery(claimQuery+`.*ORDER BY priority DESC, md5\(\$4 \|\| item_id\), id`).
+			WithArgs(s.worker, now, now.Add(-jobClaimTTL), "run-1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}).AddRow(int64(i+1), id))
+		mock.ExpectQuery(`FROM tracked_items\s+WHERE id = ANY\(\$1\)`).
+			WithArgs(`{"`+id+`"}`, "run-1", "").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(id, "user-1", "$19.99", "Switch", ts.URL+"/switch", ".price", "", "", "", "", "", "first", nil, nil, "auto", "en-US", "", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil))
+		mock.ExpectExec("UPDATE tracked_items").WithArgs("success", id).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("SET last_price").WithArgs(19.99, id).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectScrapeLogBatch(mock, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE scrape_jobs j").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectQuery(claimQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}))
+
+	claimed, err := s.runJobs(WithOrderSeed(context.Background(), "run-1"), "test", nil)
+	if err != nil {
+		t.Fatalf("runJobs failed: %v", err)
+	}
+	if claimed != 2 {
+		t.Errorf("Expected 2 jobs claimed, got %d", claimed)
+	}
+	if hits != 1 {
+		t.Errorf("Expected the shared page to be fetched once, got %d", hits)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// extractJSONLDPrice looks for a schema.org Product (or bare Offer) in the
+// page's JSON-LD blocks and returns the first positive offer price it finds.
+// Most stores publish this for search engines, and it survives the markup
+// changes that tend to break user-picked selectors.
+func extractJSONLDPrice(doc *goquery.Document) (string, bool) {
+	var price string
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		price = findJSONLDPrice(data)
+		return price == ""
+	})
+	return price, price != ""
+}
+
+// findJSONLDPrice walks a decoded JSON-LD value: top-level arrays, @graph
+// containers, Products and their offers.
+func findJSONLDPrice(v any) string {
+	switch v := v.(type) {
+	case []any:
+		for _, elem := range v {
+			if price := findJSONLDPrice(elem); price != "" {
+				return price
+			}
+		}
+	case map[string]any:
+		if graph, ok := v["@graph"]; ok {
+			return findJSONLDPrice(graph)
+		}
+		switch {
+		case jsonLDHasType(v, "Product"):
+			return findJSONLDPrice(v["offers"])
+		case jsonLDHasType(v, "Offer"):
+			return jsonLDOfferPrice(v, "price")
+		case jsonLDHasType(v, "AggregateOffer"):
+			return jsonLDOfferPrice(v, "lowPrice")
+		}
+	}
+	return ""
+}
+
+// jsonLDHasType reports whether obj's @type is (or includes) typ.
+func jsonLDHasType(obj map[string]any, typ string) bool {
+	switch t := obj["@type"].(type) {
+	case string:
+		return t == typ
+	case []any:
+		for _, elem := range t {
+			if s, ok := elem.(string); ok && s == typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonLDOfferPrice returns the offer's price field as text, prefixed with its
+// currency code when present. Prices may be published as strings or numbers.
+func jsonLDOfferPrice(offer map[string]any, field string) string {
+	var text string
+	switch p := offer[field].(type) {
+	case string:
+		text = strings.TrimSpace(p)
+	case float64:
+		text = strconv.FormatFloat(p, 'f', 2, 64)
+	}
+	if validatePriceText(text) != nil {
+		return ""
+	}
+	if currency, ok := offer["priceCurrency"].(string); ok && currency != "" {
+		return currency + " " + text
+	}
+	return text
+}
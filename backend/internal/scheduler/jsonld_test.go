@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractJSONLDPrice(t *testing.T) {
+	tests := []struct {
+		name   string
+		jsonLD string
+		want   string
+	}{
+		{"product offer", `{"@type":"Product","offers":{"@type":"Offer","price":"19.99","priceCurrency":"USD"}}`, "USD 19.99"},
+		{"numeric price", `{"@type":"Product","offers":{"@type":"Offer","price":5}}`, "5.00"},
+		{"offer list", `{"@type":"Product","offers":[{"@type":"Offer","price":"0"},{"@type":"Offer","price":"7.50"}]}`, "7.50"},
+		{"aggregate offer", `{"@type":"Product","offers":{"@type":"AggregateOffer","lowPrice":"12.00"}}`, "12.00"},
+		{"graph", `{"@graph":[{"@type":"WebPage"},{"@type":["Product","Thing"],"offers":{"@type":"Offer","price":"3.25"}}]}`, "3.25"},
+		{"no product", `{"@type":"Organization","name":"Shop"}`, ""},
+		{"invalid json", `{"@type":`, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			html := `<html><head><script type="application/ld+json">` + test.jsonLD + `</script></head></html>`
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+			if err != nil {
+				t.Fatalf("Failed to parse HTML: %v", err)
+			}
+			got, ok := extractJSONLDPrice(doc)
+			if got != test.want || ok != (test.want != "") {
+				t.Errorf("extractJSONLDPrice() = %q, %v; want %q", got, ok, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"price-track-backend/internal/realtime"
+	"price-track-backend/internal/settings"
+)
+
+// Notification is a price drop to tell an item's owner about.
+type Notification struct {
+	UserID      string
+	ItemID      string
+	ProductName string
+	OldPrice    string
+	NewPrice    string
+	OccurredAt  time.Time
+	// Severity grades the drop for the in-app notification.
+	Severity Severity
+	// DropPercent is how much cheaper the item got, in percent.
+	DropPercent float64
+	// VariantID is set when one of the item's variants dropped rather than
+	// the item itself, whose price history is then not the one that dropped.
+	VariantID int64
+	// DeliverAfter holds back deliveries that could wake the user until
+	// their quiet hours end; zero delivers right away.
+	DeliverAfter time.Time
+	// Channel is the item's own notification channel, which overrides the
+	// user's default; empty uses the default.
+	Channel settings.NotificationChannel
+}
+
+// webhookEvent returns n as the body of a price_drop webhook.
+func (n Notification) webhookEvent() WebhookEvent {
+	return WebhookEvent{Event: "price_drop", ItemID: n.ItemID, ProductName: n.ProductName, OldPrice: n.OldPrice, NewPrice: n.NewPrice, OccurredAt: n.OccurredAt}
+}
+
+// RealtimeEvent is the payload of a price_drop Realtime broadcast.
+type RealtimeEvent struct {
+	WebhookEvent
+	Severity Severity `json:"severity"`
+}
+
+// realtimeEvent returns n as the payload of a price_drop broadcast.
+func (n Notification) realtimeEvent() RealtimeEvent {
+	return RealtimeEvent{WebhookEvent: n.webhookEvent(), Severity: n.Severity}
+}
+
+// Notifier delivers price drop notifications through one channel.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// MultiNotifier notifies each of its notifiers in turn. A failing one does
+// not stop the others; their errors are joined.
+type MultiNotifier []Notifier
+
+func (m MultiNotifier) Notify(ctx context.Context, n Notification) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NotifierFunc adapts a function to Notifier.
+type NotifierFunc func(ctx context.Context, n Notification) error
+
+func (f NotifierFunc) Notify(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+// DBNotifier creates the in-app notification users see in the dashboard.
+type DBNotifier struct {
+	DB *sql.DB
+}
+
+func (d DBNotifier) Notify(ctx context.Context, n Notification) error {
+	title := "Price Drop Alert!"
+	message := fmt.Sprintf("Good news! The price for '%s' dropped from %s to %s.", n.ProductName, n.OldPrice, n.NewPrice)
+
+	_, err := d.DB.ExecContext(ctx, `
+		INSERT INTO notifications (user_id, title, message, type, product_id, old_price, new_price, severity, is_read)
+		VALUES ($1, $2, $3, 'price_drop', $4, $5, $6, $7, false)
+	`, n.UserID, title, message, n.ItemID, n.OldPrice, n.NewPrice, n.Severity)
+
+	return err
+}
+
+// realtimeNotifier broadcasts the notification on its owner's Realtime
+// channel once stored has stored it, so that an open dashboard shows it
+// right away. A failed broadcast is only logged: the dashboard still finds
+// the notification the next time it loads them.
+type realtimeNotifier struct {
+	stored      Notifier
+	broadcaster *realtime.Broadcaster
+}
+
+func (r realtimeNotifier) Notify(ctx context.Context, n Notification) error {
+	if err := r.stored.Notify(ctx, n); err != nil {
+		return err
+	}
+	if err := r.broadcaster.Broadcast(ctx, realtime.UserTopic(n.UserID), "price_drop", n.realtimeEvent()); err != nil {
+		slog.Warn("Failed to broadcast notification", "user_id", n.UserID, "item_id", n.ItemID, "error", err)
+	}
+	return nil
+}
+
+// webhookNotifier posts the drop to the user's webhook, if they have one.
+// Failed deliveries are retried by the scheduler, so it never fails itself.
+type webhookNotifier struct {
+	s *Scheduler
+}
+
+func (w webhookNotifier) Notify(ctx context.Context, n Notification) error {
+	w.s.sendWebhook(ctx, n.UserID, n.webhookEvent(), n.DeliverAfter)
+	return nil
+}
+
+// emailNotifier emails the drop to the user's verified address, if they
+// have one and email is configured.
+type emailNotifier struct {
+	s *Scheduler
+}
+
+func (e emailNotifier) Notify(ctx context.Context, n Notification) error {
+	e.s.sendEmail(ctx, n)
+	return nil
+}
+
+// quietHoursNotifier fills in DeliverAfter from the user's quiet hours, when
+// the notification does not set it, before passing it on.
+type quietHoursNotifier struct {
+	s    *Scheduler
+	next Notifier
+}
+
+func (q quietHoursNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.DeliverAfter.IsZero() {
+		n.DeliverAfter = q.s.quietUntil(ctx, n.UserID)
+	}
+	return q.next.Notify(ctx, n)
+}
+
+// channelNotifier passes the notification on to the deliveries of its
+// channel: the item's own, or else the user's default. NotifyAll reaches
+// all of them, extra ones included; NotifyInApp none.
+type channelNotifier struct {
+	s       *Scheduler
+	webhook Notifier
+	email   Notifier
+	extra   []Notifier
+}
+
+func (c channelNotifier) Notify(ctx context.Context, n Notification) error {
+	switch c.s.notificationChannel(ctx, n) {
+	case settings.NotifyInApp:
+		return nil
+	case settings.NotifyWebhook:
+		return c.webhook.Notify(ctx, n)
+	case settings.NotifyEmail:
+		return c.email.Notify(ctx, n)
+	}
+	return append(MultiNotifier{c.webhook, c.email}, c.extra...).Notify(ctx, n)
+}
+
+// notificationChannel returns where n is delivered besides the in-app
+// notification. When the user's settings cannot be loaded it delivers
+// everywhere, as before channels could be picked.
+func (s *Scheduler) notificationChannel(ctx context.Context, n Notification) settings.NotificationChannel {
+	if n.Channel != "" {
+		return n.Channel
+	}
+	st, err := s.userSettings.Get(ctx, n.UserID)
+	if err != nil {
+		slog.Error("Failed to load settings", "user_id", n.UserID, "error", err)
+		return settings.NotifyAll
+	}
+	return st.NotificationChannel
+}
+
+// defaultNotifier is how s tells users about drops: the in-app notification
+// right away, broadcast once stored if Realtime is on, then the webhook,
+// email and extra deliveries the item's or user's notification channel
+// picks, which wait for the user's quiet hours to end.
+func (s *Scheduler) defaultNotifier(extra []Notifier) Notifier {
+	var inApp Notifier = DBNotifier{DB: s.db}
+	if s.realtime != nil {
+		inApp = realtimeNotifier{stored: inApp, broadcaster: s.realtime}
+	}
+	deferred := channelNotifier{s: s, webhook: webhookNotifier{s}, email: emailNotifier{s}, extra: extra}
+	return MultiNotifier{inApp, quietHoursNotifier{s: s, next: deferred}}
+}
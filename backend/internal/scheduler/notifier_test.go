@@ -0,0 +1,204 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/realtime"
+	"price-track-backend/internal/settings"
+)
+
+// recordingNotifier is a mock sink that keeps what it was told and fails
+// with err.
+type recordingNotifier struct {
+	got []Notification
+	err error
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, n Notification) error {
+	r.got = append(r.got, n)
+	return r.err
+}
+
+func TestMultiNotifier_NotifiesAllAndJoinsErrors(t *testing.T) {
+	failing := &recordingNotifier{err: errors.New("sink down")}
+	ok := &recordingNotifier{}
+	var order []string
+	m := MultiNotifier{
+		NotifierFunc(func(context.Context, Notification) error { order = append(order, "first"); return nil }),
+		failing,
+		ok,
+	}
+
+	n := Notification{UserID: "user-1", ItemID: "item-1", OldPrice: "$20.00", NewPrice: "$15.00"}
+	err := m.Notify(context.Background(), n)
+
+	if !errors.Is(err, failing.err) {
+		t.Errorf("Expected the failing sink's error, got %v", err)
+	}
+	if len(order) != 1 || len(failing.got) != 1 || len(ok.got) != 1 {
+		t.Fatalf("Expected every sink notified once, got %d, %d and %d", len(order), len(failing.got), len(ok.got))
+	}
+	if ok.got[0] != n {
+		t.Errorf("Expected %+v, got %+v", n, ok.got[0])
+	}
+	if err := (MultiNotifier{}).Notify(context.Background(), n); err != nil {
+		t.Errorf("Expected no error from no sinks, got %v", err)
+	}
+}
+
+func TestProcessItem_FansOutToConfiguredNotifiers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$15.00</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	ny, _ := time.LoadLocation("America/New_York")
+	now := time.Date(2025, 6, 1, 3, 0, 0, 0, ny)
+	windowEnd := time.Date(2025, 6, 1, 7, 0, 0, 0, ny)
+
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectQuietHours(mock, "user-1", "America/New_York", "22:00", "07:00")
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("$15.00", "15.00 USD", 15.0, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// A failing sink does not keep the others from hearing about the drop.
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-1", "Price Drop Alert!", sqlmock.AnyArg(), "item-1", "$19.99", "$15.00", sqlmock.AnyArg()).
+		WillReturnError(errors.New("db down"))
+	expectNoWebhook(mock, "user-1")
+
+	sink := &recordingNotifier{}
+	cfg := localConfig()
+	cfg.Notifiers = []Notifier{sink}
+	s := New(db, cfg)
+	s.now = func() time.Time { return now }
+	s.processItem(context.Background(), Item{
+		ID:          "item-1",
+		UserID:      "user-1",
+		PriceText:   "$19.99",
+		ProductName: "Widget",
+		PageURL:     ts.URL,
+		CSSSelector: ".price",
+	})
+
+	if len(sink.got) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(sink.got))
+	}
+	got := sink.got[0]
+	if got.UserID != "user-1" || got.ItemID != "item-1" || got.ProductName != "Widget" || got.OldPrice != "$19.99" || got.NewPrice != "$15.00" || !got.OccurredAt.Equal(now) {
+		t.Errorf("Unexpected notification %+v", got)
+	}
+	// Extra sinks wait for quiet hours like the webhook does.
+	if !got.DeliverAfter.Equal(windowEnd) {
+		t.Errorf("Expected delivery after %v, got %v", windowEnd, got.DeliverAfter)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestRealtimeNotifier_BroadcastsStoredNotifications(t *testing.T) {
+	var bodies []string
+	status := http.StatusAccepted
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	stored := &recordingNotifier{}
+	notifier := realtimeNotifier{stored: stored, broadcaster: realtime.NewBroadcaster(realtime.Config{URL: ts.URL, ServiceKey: "service-key"})}
+	n := Notification{UserID: "user-1", ItemID: "item-1", ProductName: "Widget", OldPrice: "$20.00", NewPrice: "$15.00", OccurredAt: time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC), Severity: SeverityAlert}
+
+	if err := notifier.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	want := `{"messages":[{"topic":"user:user-1","event":"price_drop","payload":{"event":"price_drop","itemId":"item-1","productName":"Widget","oldPrice":"$20.00","newPrice":"$15.00","occurredAt":"2025-06-01T03:00:00Z","severity":"alert"},"private":true}]}`
+	if len(stored.got) != 1 || len(bodies) != 1 || bodies[0] != want {
+		t.Fatalf("Expected the notification stored and broadcast as %s, got %d stored and %v", want, len(stored.got), bodies)
+	}
+
+	// A failed broadcast does not fail the notification.
+	status = http.StatusUnauthorized
+	if err := notifier.Notify(context.Background(), n); err != nil {
+		t.Errorf("Expected a failed broadcast to be ignored, got %v", err)
+	}
+
+	// Nothing is broadcast that was not stored.
+	stored.err = errors.New("db down")
+	if err := notifier.Notify(context.Background(), n); !errors.Is(err, stored.err) {
+		t.Errorf("Expected the store's error, got %v", err)
+	}
+	if len(bodies) != 2 {
+		t.Errorf("Expected 2 broadcasts, got %d", len(bodies))
+	}
+}
+
+func TestChannelNotifier_ItemOverridesUserDefault(t *testing.T) {
+	tests := []struct {
+		name        string
+		userDefault any // nil: never set
+		item        settings.NotificationChannel
+		want        []string
+	}{
+		{"user default in_app", "in_app", "", nil},
+		{"item webhook over in_app", "in_app", settings.NotifyWebhook, []string{"webhook"}},
+		{"item email over webhook", "webhook", settings.NotifyEmail, []string{"email"}},
+		{"item in_app over all", nil, settings.NotifyInApp, nil},
+		{"item all over email", "email", settings.NotifyAll, []string{"webhook", "email", "extra"}},
+		{"user default webhook", "webhook", "", []string{"webhook"}},
+		{"no default", nil, "", []string{"webhook", "email", "extra"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+			// The user's settings are only read when the item has no channel.
+			if test.item == "" {
+				mock.ExpectQuery("FROM user_settings").
+					WithArgs("user-1").
+					WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow(nil, nil, nil, nil, nil, nil, nil, test.userDefault))
+			}
+
+			var got []string
+			sink := func(name string) Notifier {
+				return NotifierFunc(func(context.Context, Notification) error { got = append(got, name); return nil })
+			}
+			c := channelNotifier{s: New(db, localConfig()), webhook: sink("webhook"), email: sink("email"), extra: []Notifier{sink("extra")}}
+			if err := c.Notify(context.Background(), Notification{UserID: "user-1", ItemID: "item-1", Channel: test.item}); err != nil {
+				t.Fatalf("Notify failed: %v", err)
+			}
+
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Expected deliveries %v, got %v", test.want, got)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"math/rand"
+	"strconv"
+)
+
+// A run visits its items in an order of its own, so that the same shops are
+// not hit in the same burst every hour and the items a run has no time left
+// for are not always the same ones. The order is that of each item's
+// orderKey under a seed picked per run and logged, so a run can be replayed
+// with cmd/scraper -seed.
+
+type orderSeedKey struct{}
+
+// WithOrderSeed returns a context under which a price check visits items in
+// the order seed gives rather than in a random one.
+func WithOrderSeed(ctx context.Context, seed string) context.Context {
+	return context.WithValue(ctx, orderSeedKey{}, seed)
+}
+
+// runSeed returns the seed ctx carries, or a new random one.
+func runSeed(ctx context.Context) string {
+	if seed, ok := ctx.Value(orderSeedKey{}).(string); ok && seed != "" {
+		return seed
+	}
+	return strconv.FormatUint(rand.Uint64(), 36)
+}
+
+// orderKeyExpr is orderKey in SQL, for the seed in parameter $n.
+const orderKeyExpr = "md5($%d || id) || id"
+
+// orderKey is the item's place in the run seeded with seed: the hex MD5 of
+// seed and id, which shuffles the items, followed by id, which keeps keys
+// unique.
+func orderKey(seed, id string) string {
+	sum := md5.Sum([]byte(seed + id))
+	return hex.EncodeToString(sum[:]) + id
+}
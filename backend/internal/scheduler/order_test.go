@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// runOrder returns ids in the order a run seeded with seed visits them.
+func runOrder(seed string, ids []string) []string {
+	order := slices.Clone(ids)
+	sort.Slice(order, func(i, j int) bool { return orderKey(seed, order[i]) < orderKey(seed, order[j]) })
+	return order
+}
+
+func TestRunOrder_DiffersBetweenRuns(t *testing.T) {
+	ids := make([]string, 50)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("item-%02d", i)
+	}
+
+	seen := map[string]bool{}
+	for run := 0; run < 5; run++ {
+		order := runOrder(runSeed(context.Background()), ids)
+		sorted := slices.Clone(order)
+		slices.Sort(sorted)
+		if !slices.Equal(sorted, ids) {
+			t.Fatalf("Expected run %d to visit every item once, got %q", run, order)
+		}
+		seen[fmt.Sprint(order)] = true
+	}
+	if len(seen) < 2 {
+		t.Error("Expected runs to visit items in differing orders")
+	}
+}
+
+func TestRunOrder_ReproducibleFromSeed(t *testing.T) {
+	ids := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	ctx := WithOrderSeed(context.Background(), "k3x9")
+
+	if seed := runSeed(ctx); seed != "k3x9" {
+		t.Fatalf("Expected the seed from the context, got %q", seed)
+	}
+	if first, again := runOrder(runSeed(ctx), ids), runOrder(runSeed(ctx), ids); !slices.Equal(first, again) {
+		t.Errorf("Expected the same seed to give the same order, got %q and %q", first, again)
+	}
+}
+
+func TestOrderKey_MatchesSQL(t *testing.T) {
+	// md5(seed || id) || id in Postgres.
+	sum := md5.Sum([]byte("k3x9item-1"))
+	if got, want := orderKey("k3x9", "item-1"), hex.EncodeToString(sum[:])+"item-1"; got != want {
+		t.Errorf("orderKey = %q, want %q", got, want)
+	}
+}
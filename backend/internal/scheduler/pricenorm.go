@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// currencyCodes are the ISO 4217 codes recognized when a price spells out its
+// currency ("EUR 19,99", "19.99 CHF").
+var currencyCodes = map[string]bool{
+	"AED": true, "ARS": true, "AUD": true, "BRL": true, "CAD": true, "CHF": true,
+	"CLP": true, "CNY": true, "COP": true, "CZK": true, "DKK": true, "EUR": true,
+	"GBP": true, "HKD": true, "HUF": true, "ILS": true, "INR": true, "JPY": true,
+	"KRW": true, "MXN": true, "NOK": true, "NZD": true, "PLN": true, "RUB": true,
+	"SAR": true, "SEK": true, "SGD": true, "TRY": true, "USD": true, "ZAR": true,
+}
+
+var currencyCode = regexp.MustCompile(`\b[A-Z]{3}\b`)
+
+// currencySymbols map symbols to currencies, longest first so that "US$" is
+// not read as a bare "$". A currency of "" depends on the country (see
+// countryCurrencies).
+var currencySymbols = []struct {
+	symbol   string
+	currency string
+}{
+	{"US$", "USD"}, {"CA$", "CAD"}, {"AU$", "AUD"}, {"NZ$", "NZD"}, {"HK$", "HKD"}, {"MX$", "MXN"},
+	{"C$", "CAD"}, {"A$", "AUD"}, {"S$", "SGD"}, {"R$", "BRL"}, {"CN¥", "CNY"},
+	{"Rs.", "INR"}, {"Rs", "INR"}, {"₹", "INR"}, {"€", "EUR"}, {"£", "GBP"}, {"₩", "KRW"},
+	{"₽", "RUB"}, {"₺", "TRY"}, {"₪", "ILS"}, {"zł", "PLN"}, {"Kč", "CZK"}, {"Ft", "HUF"},
+	{"$", ""}, {"¥", ""}, {"kr", ""},
+}
+
+// countryCurrencies resolves the symbols several currencies share for the
+// country a page was requested from (Item.CountryCode).
+var countryCurrencies = map[string]map[string]string{
+	"$": {
+		"US": "USD", "CA": "CAD", "AU": "AUD", "NZ": "NZD", "MX": "MXN",
+		"SG": "SGD", "HK": "HKD", "AR": "ARS", "CL": "CLP", "CO": "COP",
+	},
+	"¥":  {"JP": "JPY", "CN": "CNY"},
+	"kr": {"SE": "SEK", "NO": "NOK", "DK": "DKK"},
+}
+
+// defaultCurrencies is what a shared symbol means when the country does not
+// say: a "$" is most likely US dollars and a "¥" yen. "kr" has no default.
+var defaultCurrencies = map[string]string{"$": "USD", "¥": "JPY"}
+
+// priceCurrency returns the ISO 4217 code of the currency text is in, or ""
+// if it cannot tell.
+func priceCurrency(text, countryCode string) string {
+	currency, _ := PriceCurrency(text, countryCode)
+	return currency
+}
+
+// PriceCurrency returns the ISO 4217 code of the currency text is in and the
+// code or symbol in text it was read from, such as "EUR" and "€" for
+// "1.234,50 €". Symbols several currencies share are resolved as in
+// NormalizePrice. The currency is "" if it cannot tell, as is the symbol when
+// text has none.
+func PriceCurrency(text, countryCode string) (currency, symbol string) {
+	for _, code := range currencyCode.FindAllString(text, -1) {
+		if currencyCodes[code] {
+			return code, code
+		}
+	}
+	for _, s := range currencySymbols {
+		if !strings.Contains(text, s.symbol) {
+			continue
+		}
+		if s.currency != "" {
+			return s.currency, s.symbol
+		}
+		if currency, ok := countryCurrencies[s.symbol][strings.ToUpper(countryCode)]; ok {
+			return currency, s.symbol
+		}
+		return defaultCurrencies[s.symbol], s.symbol
+	}
+	return "", ""
+}
+
+// NormalizePrice writes price text the same way whatever the store's format:
+// the amount with two decimals and a dot, then the ISO 4217 code of its
+// currency, as in "1234.50 EUR" for "1.234,50 €". The currency is read from a
+// code or symbol in text; symbols several currencies share, such as "$", are
+// resolved with countryCode, the country the page was requested from. When
+// the currency is unknown the amount is returned alone, and when text does
+// not parse to a positive price with strategy, "".
+func NormalizePrice(text string, strategy ParseStrategy, countryCode string) string {
+	price, err := ParsePriceWith(text, strategy)
+	if err != nil || price <= 0 {
+		return ""
+	}
+	amount := strconv.FormatFloat(price, 'f', 2, 64)
+	if currency := priceCurrency(text, countryCode); currency != "" {
+		return amount + " " + currency
+	}
+	return amount
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import "testing"
+
+func TestNormalizePrice(t *testing.T) {
+	tests := []struct {
+		input    string
+		strategy ParseStrategy
+		country  string
+		expected string
+	}{
+		// One price as different stores write it.
+		{"$1,234.50", ParseAuto, "", "1234.50 USD"},
+		{"US$ 1,234.5", ParseAuto, "", "1234.50 USD"},
+		{"USD 1234.50", ParseAuto, "", "1234.50 USD"},
+		{"1234.50 USD", ParseAuto, "", "1234.50 USD"},
+		{"€1.234,50", ParseAuto, "", "1234.50 EUR"},
+		{"1.234,50 €", ParseAuto, "", "1234.50 EUR"},
+		{"EUR 1 234,50", ParseEU, "", "1234.50 EUR"},
+		// Whole amounts still get two decimals.
+		{"£64", ParseAuto, "", "64.00 GBP"},
+		{"Rs. 1,299", ParseAuto, "", "1299.00 INR"},
+		{"₹1,23,456.78", ParseLakh, "", "123456.78 INR"},
+		{"R$ 1.299,90", ParseAuto, "", "1299.90 BRL"},
+		{"CHF 49.90", ParseAuto, "", "49.90 CHF"},
+		{"299,00 zł", ParseAuto, "", "299.00 PLN"},
+		// A shared symbol depends on where the page was requested from.
+		{"$19.99", ParseAuto, "CA", "19.99 CAD"},
+		{"$19.99", ParseAuto, "au", "19.99 AUD"},
+		{"C$19.99", ParseAuto, "US", "19.99 CAD"},
+		{"¥1,980", ParseAuto, "", "1980.00 JPY"},
+		{"¥1,980", ParseAuto, "CN", "1980.00 CNY"},
+		{"1.299 kr", ParseEU, "SE", "1299.00 SEK"},
+		// Unknown currencies keep the amount.
+		{"1.299 kr", ParseEU, "", "1299.00"},
+		{"19.99", ParseAuto, "", "19.99"},
+		// Nothing to normalize.
+		{"", ParseAuto, "", ""},
+		{"Out of stock", ParseAuto, "", ""},
+		{"$0.00", ParseAuto, "", ""},
+	}
+
+	for _, test := range tests {
+		if got := NormalizePrice(test.input, test.strategy, test.country); got != test.expected {
+			t.Errorf("NormalizePrice(%q, %q, %q) = %q, expected %q", test.input, test.strategy, test.country, got, test.expected)
+		}
+	}
+}
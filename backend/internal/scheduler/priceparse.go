@@ -0,0 +1,163 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseStrategy selects how separators in a price are interpreted. Stores
+// disagree on whether "1.234" means one thousand two hundred and thirty-four
+// or one and a bit, so users can pin the interpretation per item.
+type ParseStrategy string
+
+const (
+	// ParseAuto guesses from the separators present (the default).
+	ParseAuto ParseStrategy = "auto"
+	// ParseUS reads "1,234.56": commas group, the dot is decimal.
+	ParseUS ParseStrategy = "us"
+	// ParseEU reads "1.234,56": dots group, the comma is decimal.
+	ParseEU ParseStrategy = "eu"
+	// ParsePlain drops commas and reads a dot as decimal, the original
+	// behavior before strategies existed.
+	ParsePlain ParseStrategy = "plain"
+	// ParseLakh reads Indian grouping such as "1,23,456.78".
+	ParseLakh ParseStrategy = "lakh"
+)
+
+// ValidParseStrategy reports whether s names a known strategy.
+func ValidParseStrategy(s string) bool {
+	switch ParseStrategy(s) {
+	case ParseAuto, ParseUS, ParseEU, ParsePlain, ParseLakh:
+		return true
+	}
+	return false
+}
+
+var nonNumeric = regexp.MustCompile(`[^\d.,]`)
+
+// parsePrice parses price text with the auto strategy.
+func parsePrice(priceStr string) (float64, error) {
+	return ParsePriceWith(priceStr, ParseAuto)
+}
+
+// ErrMalformedPrice is returned for price text whose separators do not make a
+// number under the item's strategy, such as "1.2.3" or "1,234.56.78".
+var ErrMalformedPrice = errors.New("malformed price")
+
+// ParsePriceWith extracts the number from price text such as "$1,234.56" or
+// "1.234,56 €" using strategy. An empty strategy means auto. The API uses it
+// to record an item's captured price the same way the scheduler reads it.
+//
+// The decimal separator may appear once, after every grouping separator, and
+// groups must be thousands ("1,234,567") or lakhs ("12,34,567"). Text that
+// breaks either rule is ErrMalformedPrice, except under ParsePlain, which
+// keeps ignoring how commas group.
+func ParsePriceWith(priceStr string, strategy ParseStrategy) (float64, error) {
+	// Currency symbols, codes and spaces go; stray separators at either end
+	// (as in "Rs. 1,299") cannot be part of the number.
+	cleaned := strings.Trim(nonNumeric.ReplaceAllString(priceStr, ""), ".,")
+
+	var decimal, group byte
+	switch strategy {
+	case ParseAuto, "":
+		decimal, group = autoSeparators(cleaned)
+	case ParseUS, ParsePlain, ParseLakh:
+		decimal, group = '.', ','
+	case ParseEU:
+		decimal, group = ',', '.'
+	default:
+		return 0, fmt.Errorf("unknown parse strategy %q", strategy)
+	}
+	number, ok := joinNumber(cleaned, decimal, group, strategy != ParsePlain)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrMalformedPrice, priceStr)
+	}
+	return strconv.ParseFloat(number, 64)
+}
+
+// autoSeparators picks the decimal and grouping separators of cleaned
+// (digits, dots and commas only):
+//   - with both separators, the last one is the decimal separator;
+//   - a separator repeated is grouping ("1.234.567", "1,23,456");
+//   - a single comma is decimal unless exactly three digits follow it
+//     ("19,99" but "1,234");
+//   - a single dot is decimal.
+func autoSeparators(cleaned string) (decimal, group byte) {
+	dot, comma := strings.LastIndex(cleaned, "."), strings.LastIndex(cleaned, ",")
+	switch {
+	case dot >= 0 && comma >= 0:
+		if comma > dot {
+			return ',', '.'
+		}
+	case comma >= 0:
+		if strings.Count(cleaned, ",") == 1 && len(cleaned)-comma-1 != 3 {
+			return ',', '.'
+		}
+	case strings.Count(cleaned, ".") > 1:
+		return ',', '.'
+	}
+	return '.', ','
+}
+
+// joinNumber rewrites cleaned as a number strconv can parse: grouping
+// separators dropped and the decimal separator, if any, made a dot. ok is
+// false when the decimal separator appears more than once or before a
+// grouping separator, when a group is empty, or, if strict, when the groups
+// are neither thousands nor lakhs.
+func joinNumber(cleaned string, decimal, group byte, strict bool) (number string, ok bool) {
+	if cleaned == "" {
+		return "", false
+	}
+	whole, frac := cleaned, ""
+	if i := strings.LastIndexByte(cleaned, decimal); i >= 0 {
+		whole, frac = cleaned[:i], cleaned[i+1:]
+		if strings.IndexByte(whole, decimal) >= 0 || strings.IndexByte(frac, group) >= 0 {
+			return "", false
+		}
+	}
+	groups := strings.Split(whole, string(group))
+	for _, g := range groups {
+		if g == "" {
+			return "", false
+		}
+	}
+	if strict && len(groups) > 1 && !thousands(groups) && !lakhs(groups) {
+		return "", false
+	}
+	number = strings.Join(groups, "")
+	if frac != "" {
+		number += "." + frac
+	}
+	return number, true
+}
+
+// thousands reports whether groups split a number every three digits.
+func thousands(groups []string) bool {
+	if len(groups[0]) > 3 {
+		return false
+	}
+	for _, g := range groups[1:] {
+		if len(g) != 3 {
+			return false
+		}
+	}
+	return true
+}
+
+// lakhs reports whether groups split a number the Indian way: the last three
+// digits, then every two.
+func lakhs(groups []string) bool {
+	last := len(groups) - 1
+	if len(groups[last]) != 3 || len(groups[0]) > 2 {
+		return false
+	}
+	for _, g := range groups[1:last] {
+		if len(g) != 2 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePriceWith_AmbiguousInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		strategy ParseStrategy
+		expected float64
+	}{
+		// "1.234" is one thousand two hundred and thirty-four only in EU style.
+		{"1.234", ParseAuto, 1.234},
+		{"1.234", ParseUS, 1.234},
+		{"1.234", ParseEU, 1234},
+		{"1.234", ParsePlain, 1.234},
+		{"1.234", ParseLakh, 1.234},
+		// "1,234" is the reverse.
+		{"1,234", ParseAuto, 1234},
+		{"1,234", ParseUS, 1234},
+		{"1,234", ParseEU, 1.234},
+		{"1,234", ParsePlain, 1234},
+		{"1,234", ParseLakh, 1234},
+		// Unambiguous formats.
+		{"€1.234,56", ParseAuto, 1234.56},
+		{"€1.234,56", ParseEU, 1234.56},
+		{"$1,234.56", ParseUS, 1234.56},
+		{"₹1,23,456.78", ParseLakh, 123456.78},
+		{"₹1,23,456.78", ParseAuto, 123456.78},
+		{"19,99 €", ParseAuto, 19.99},
+		{"1.234.567 kr", ParseAuto, 1234567},
+		{"Rs. 1,299", ParseAuto, 1299},
+		{"$19.99", "", 19.99},
+	}
+
+	for _, test := range tests {
+		got, err := ParsePriceWith(test.input, test.strategy)
+		if err != nil {
+			t.Errorf("ParsePriceWith(%q, %q) error: %v", test.input, test.strategy, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("ParsePriceWith(%q, %q) = %f, expected %f", test.input, test.strategy, got, test.expected)
+		}
+	}
+}
+
+func TestParsePriceWith_MultipleSeparators(t *testing.T) {
+	tests := []struct {
+		input    string
+		strategy ParseStrategy
+		expected float64
+	}{
+		// The last separator is decimal, the ones before it group.
+		{"1.234.567,89", ParseAuto, 1234567.89},
+		{"1,234,567.89", ParseAuto, 1234567.89},
+		{"1.234.567,89", ParseEU, 1234567.89},
+		{"1,234,567.89", ParseUS, 1234567.89},
+		{"12,34,567.89", ParseLakh, 1234567.89},
+		{"1,234,567", ParseAuto, 1234567},
+		// Plain keeps ignoring how commas group.
+		{"1,2,3.45", ParsePlain, 123.45},
+	}
+	for _, test := range tests {
+		got, err := ParsePriceWith(test.input, test.strategy)
+		if err != nil {
+			t.Errorf("ParsePriceWith(%q, %q) error: %v", test.input, test.strategy, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("ParsePriceWith(%q, %q) = %f, expected %f", test.input, test.strategy, got, test.expected)
+		}
+	}
+}
+
+func TestParsePriceWith_Malformed(t *testing.T) {
+	tests := []struct {
+		input    string
+		strategy ParseStrategy
+	}{
+		{"1.2.3", ParseAuto},
+		{"1,2,3", ParseAuto},
+		{"1.2.3,4", ParseAuto},
+		{"1,234.56.78", ParseAuto},
+		{"1.2.3", ParseUS},
+		{"1.2.3", ParsePlain},
+		{"1.234,5", ParseUS},
+		{"12,3456.78", ParseUS},
+		{"1,,234", ParsePlain},
+		{"Sold out", ParseAuto},
+	}
+	for _, test := range tests {
+		if got, err := ParsePriceWith(test.input, test.strategy); !errors.Is(err, ErrMalformedPrice) {
+			t.Errorf("ParsePriceWith(%q, %q) = %f, %v; expected ErrMalformedPrice", test.input, test.strategy, got, err)
+		}
+	}
+}
+
+func TestParsePriceWith_UnknownStrategy(t *testing.T) {
+	if _, err := ParsePriceWith("$1.00", "roman"); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
+
+func TestValidParseStrategy(t *testing.T) {
+	for _, s := range []string{"auto", "us", "eu", "plain", "lakh"} {
+		if !ValidParseStrategy(s) {
+			t.Errorf("Expected %q to be valid", s)
+		}
+	}
+	for _, s := range []string{"", "AUTO", "fr"} {
+		if ValidParseStrategy(s) {
+			t.Errorf("Expected %q to be invalid", s)
+		}
+	}
+}
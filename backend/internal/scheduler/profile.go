@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// ScrapeProfile trades the Playwright fallback's bot-detection evasion
+// against its speed. Empty means the scraper's default (SCRAPE_PROFILE).
+type ScrapeProfile string
+
+const (
+	// ProfileStealth hides the headless browser and pauses like a reader
+	// before looking for the price. It is the default.
+	ProfileStealth ScrapeProfile = "stealth"
+	// ProfileFast skips both and looks for the price as soon as the page
+	// starts loading, for sites that do not block bots.
+	ProfileFast ScrapeProfile = "fast"
+)
+
+// ValidScrapeProfile reports whether s names a known profile.
+func ValidScrapeProfile(s string) bool {
+	switch ScrapeProfile(s) {
+	case ProfileStealth, ProfileFast:
+		return true
+	}
+	return false
+}
+
+// stealth reports whether the browser should hide that it is automated.
+func (p ScrapeProfile) stealth() bool {
+	return p != ProfileFast
+}
+
+// delay is how long to wait after navigating before looking for the price.
+func (p ScrapeProfile) delay() time.Duration {
+	if p == ProfileFast {
+		return 0
+	}
+	return time.Duration(1000+rand.Intn(2000)) * time.Millisecond
+}
+
+// waitUntil is the navigation event to wait for. Fast pages rely on the
+// locator's own wait for the price instead of the DOM being ready.
+func (p ScrapeProfile) waitUntil() *playwright.WaitUntilState {
+	if p == ProfileFast {
+		return playwright.WaitUntilStateCommit
+	}
+	return playwright.WaitUntilStateDomcontentloaded
+}
+
+// profileFor resolves an item's profile against the scraper's default.
+func (s *Scraper) profileFor(profile ScrapeProfile) ScrapeProfile {
+	if profile == "" {
+		profile = s.profile
+	}
+	if profile == "" {
+		profile = ProfileStealth
+	}
+	return profile
+}
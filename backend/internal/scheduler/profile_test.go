@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+func TestScrapeProfile_FastSkipsDelay(t *testing.T) {
+	if d := ProfileFast.delay(); d != 0 {
+		t.Errorf("Expected the fast profile not to wait, got %v", d)
+	}
+	if ProfileFast.stealth() {
+		t.Error("Expected the fast profile to skip the stealth script")
+	}
+	if *ProfileFast.waitUntil() != *playwright.WaitUntilStateCommit {
+		t.Errorf("Expected the fast profile to wait for the commit, got %v", *ProfileFast.waitUntil())
+	}
+
+	for i := 0; i < 20; i++ {
+		if d := ProfileStealth.delay(); d < time.Second || d >= 3*time.Second {
+			t.Fatalf("Expected a stealth delay between 1s and 3s, got %v", d)
+		}
+	}
+	if !ProfileStealth.stealth() {
+		t.Error("Expected the stealth profile to add the stealth script")
+	}
+}
+
+func TestScraper_ProfileFor(t *testing.T) {
+	s := NewScraper()
+	if got := s.profileFor(""); got != ProfileStealth {
+		t.Errorf("Expected stealth by default, got %q", got)
+	}
+	s.profile = ProfileFast
+	if got := s.profileFor(""); got != ProfileFast {
+		t.Errorf("Expected SCRAPE_PROFILE to apply, got %q", got)
+	}
+	if got := s.profileFor(ProfileStealth); got != ProfileStealth {
+		t.Errorf("Expected the item's profile to win, got %q", got)
+	}
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// ParseProxyURL validates a proxy for scraping: an http, https or socks5 URL
+// with a host, optional user:password credentials and nothing else. Empty
+// means no proxy.
+func ParseProxyURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		// url.Error quotes the input, credentials included.
+		return nil, errors.New("proxy URL is malformed")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy URL scheme must be http, https or socks5")
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL must have a host")
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("proxy URL must not have a path, query or fragment")
+	}
+	if u.User != nil {
+		password, ok := u.User.Password()
+		if u.User.Username() == "" || !ok || password == "" {
+			return nil, fmt.Errorf("proxy credentials must be user:password")
+		}
+	}
+	u.Path = ""
+	return u, nil
+}
+
+// RedactProxyURL returns raw with its password masked, for logs and API
+// responses.
+func RedactProxyURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Redacted()
+}
+
+type proxyKey struct{}
+
+// withProxy returns a context under which the scraper's requests go through
+// proxy instead of its default. A nil proxy keeps the default.
+func withProxy(ctx context.Context, proxy *url.URL) context.Context {
+	if proxy == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, proxyKey{}, proxy)
+}
+
+// proxyFor picks the proxy for req: the item's, then SCRAPER_PROXY_URL, then
+// the usual HTTP_PROXY environment variables. A request that goes through one
+// must be for a public host (see checkProxiedRequest).
+func (s *Scraper) proxyFor(req *http.Request) (*url.URL, error) {
+	proxy, ok := req.Context().Value(proxyKey{}).(*url.URL)
+	if !ok {
+		proxy = s.proxy
+	}
+	if proxy == nil {
+		var err error
+		if proxy, err = http.ProxyFromEnvironment(req); err != nil || proxy == nil {
+			return proxy, err
+		}
+	}
+	if err := s.checkProxiedRequest(req); err != nil {
+		return nil, err
+	}
+	return proxy, nil
+}
+
+// playwrightProxy converts proxy for a browser context, preferring it over
+// SCRAPER_PROXY_URL. It returns nil when neither is set.
+func (s *Scraper) playwrightProxy(proxy *url.URL) *playwright.Proxy {
+	if proxy == nil {
+		proxy = s.proxy
+	}
+	if proxy == nil {
+		return nil
+	}
+	p := &playwright.Proxy{Server: proxy.Scheme + "://" + proxy.Host}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		p.Username = playwright.String(proxy.User.Username())
+		p.Password = playwright.String(password)
+	}
+	return p
+}
+
+// proxyKeyOf identifies the proxy an item is scraped through, so that items
+// behind different proxies are not scraped together.
+func proxyKeyOf(proxy *url.URL) string {
+	if proxy == nil {
+		return ""
+	}
+	return proxy.String()
+}
@@ -0,0 +1,129 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ErrPrivateAddress is returned for a page, or any hop on the way to one, on
+// a loopback, private, link-local or unspecified address. The scraper fetches
+// URLs users give it, and those must not reach the host's own services, the
+// network it runs in or a cloud metadata endpoint.
+var ErrPrivateAddress = errors.New("address is not public")
+
+// publicAddr reports whether ip may be scraped.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsUnspecified()
+}
+
+// checkDialAddress is the Control of the scraper's dialer. It runs once
+// host names are resolved, right before each connection, so that every hop
+// is checked, HTTP and page redirects alike, and a DNS answer that changes
+// after an earlier check cannot slip a private address in.
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+	}
+	return nil
+}
+
+// CheckPublicHost resolves host and fails with ErrPrivateAddress if any of
+// its addresses is not public.
+func CheckPublicHost(ctx context.Context, host string) error {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !publicAddr(ip) {
+			return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+		}
+		return nil
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if !publicAddr(ip) {
+			return fmt.Errorf("%w: %s is %s", ErrPrivateAddress, host, ip)
+		}
+	}
+	return nil
+}
+
+// CheckPublicURL checks that raw is an http or https URL whose host
+// resolves to public addresses only, as the scraper will check every hop
+// on the way to it.
+func CheckPublicURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("not an http or https URL: %q", raw)
+	}
+	return CheckPublicHost(ctx, u.Hostname())
+}
+
+// AllowPrivateAddresses sets whether s may scrape pages on loopback, private
+// and link-local addresses, as New does from Config.PrivateAddressesAllowed.
+func (s *Scraper) AllowPrivateAddresses(allowed bool) {
+	s.allowPrivate = allowed
+}
+
+// dialContext dials for the scraper's transport. Connections to the proxies
+// the operator configured may go anywhere; all others, to pages and to the
+// proxies of items, must be to public addresses (see checkDialAddress).
+func (s *Scraper) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !s.allowPrivate && !s.operatorProxy(address) {
+		dialer.Control = checkDialAddress
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// operatorProxy reports whether address, a host and port being dialed, is
+// that of SCRAPER_PROXY_URL or of HTTP_PROXY and friends.
+func (s *Scraper) operatorProxy(address string) bool {
+	env := httpproxy.FromEnvironment()
+	for _, raw := range []string{proxyKeyOf(s.proxy), env.HTTPProxy, env.HTTPSProxy} {
+		if raw == "" {
+			continue
+		}
+		if u, err := url.Parse(raw); err == nil && proxyAddress(u) == address {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyAddress is the host and port a transport dials to reach proxy.
+func proxyAddress(proxy *url.URL) string {
+	if port := proxy.Port(); port != "" {
+		return net.JoinHostPort(proxy.Hostname(), port)
+	}
+	port := map[string]string{"http": "80", "https": "443", "socks5": "1080"}[proxy.Scheme]
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
+
+// checkProxiedRequest checks the host of req, which goes through a proxy:
+// the proxy connects to it, not the scraper's dialer, so its addresses are
+// checked up front.
+func (s *Scraper) checkProxiedRequest(req *http.Request) error {
+	if s.allowPrivate {
+		return nil
+	}
+	return CheckPublicHost(req.Context(), req.URL.Hostname())
+}
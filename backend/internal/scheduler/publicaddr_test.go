@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestPublicAddr(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "fe80::1", "fd00::1", "0.0.0.0", "::", "::ffff:127.0.0.1", "::ffff:10.0.0.1"} {
+		if publicAddr(netip.MustParseAddr(addr)) {
+			t.Errorf("Expected %s to be refused", addr)
+		}
+	}
+	for _, addr := range []string{"93.184.216.34", "8.8.8.8", "2606:4700:4700::1111"} {
+		if !publicAddr(netip.MustParseAddr(addr)) {
+			t.Errorf("Expected %s to be allowed", addr)
+		}
+	}
+}
+
+func TestCheckPublicURL(t *testing.T) {
+	ctx := context.Background()
+	for _, raw := range []string{"http://127.0.0.1:8080/", "http://169.254.169.254/latest/meta-data/", "https://[::1]/", "http://10.0.0.5/admin"} {
+		if err := CheckPublicURL(ctx, raw); !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("%s: Expected ErrPrivateAddress, got %v", raw, err)
+		}
+	}
+	if err := CheckPublicURL(ctx, "http://93.184.216.34/p/1"); err != nil {
+		t.Errorf("Expected a public address to pass, got %v", err)
+	}
+	if err := CheckPublicURL(ctx, "file:///etc/passwd"); err == nil || errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("Expected a file URL to be rejected as unsupported, got %v", err)
+	}
+}
+
+func TestScrapeDetailed_RefusesPrivateAddress(t *testing.T) {
+	ts := redirectShop()
+	defer ts.Close()
+
+	s := NewScraper()
+	s.noBrowser = true
+	_, err := s.ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL + "/p/1", CSSSelector: ".price"})
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("Expected the loopback page to be refused, got %v", err)
+	}
+}
+
+// TestScrapeDetailed_RefusesPrivateHop scrapes through an operator proxy,
+// whose own address is trusted, so that the page can be on a public address
+// while the hops it leads to are not.
+func TestScrapeDetailed_RefusesPrivateHop(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.URL.String())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/meta":
+			w.Write([]byte(`<html><head><meta http-equiv="refresh" content="0; url=http://169.254.169.254/latest/meta-data/"></head></html>`))
+		case "/script":
+			w.Write([]byte(`<html><body><script>location.href = "http://localhost:6379/";</script></body></html>`))
+		case "/http":
+			http.Redirect(w, r, "http://10.0.0.1/admin", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer proxy.Close()
+
+	s := NewScraper()
+	s.noBrowser = true
+	s.proxy, _ = url.Parse(proxy.URL)
+	for _, path := range []string{"/meta", "/script", "/http"} {
+		_, err := s.ScrapeDetailed(context.Background(), ScrapeRequest{URL: "http://93.184.216.34" + path, CSSSelector: ".price"})
+		if !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("%s: Expected the private hop to be refused, got %v", path, err)
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected only the public pages to be fetched, got %q", seen)
+	}
+}
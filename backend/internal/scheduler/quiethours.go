@@ -0,0 +1,20 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// quietUntil returns when userID's quiet hours end if they are in them now,
+// and the zero time otherwise. Settings that cannot be loaded hold nothing
+// back.
+func (s *Scheduler) quietUntil(ctx context.Context, userID string) time.Time {
+	st, err := s.userSettings.Get(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to load settings", "user_id", userID, "error", err)
+		return time.Time{}
+	}
+	until, _ := st.QuietUntil(s.now())
+	return until
+}
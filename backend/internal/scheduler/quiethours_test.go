@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/email"
+)
+
+var settingsColumns = []string{"currency", "timezone", "quiet_hours_start", "quiet_hours_end", "digest_frequency", "drop_threshold_percent", "email_price_drops", "notification_channel"}
+
+// expectDefaultSettings expects the settings lookup for a user who never
+// changed any, so has no quiet hours.
+func expectDefaultSettings(mock sqlmock.Sqlmock, userID string) {
+	mock.ExpectQuery("FROM user_settings").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(settingsColumns))
+}
+
+// expectQuietHours expects the settings lookup for a user with quiet hours
+// from start to end in tz.
+func expectQuietHours(mock sqlmock.Sqlmock, userID, tz, start, end string) {
+	mock.ExpectQuery("FROM user_settings").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow(nil, tz, start, end, nil, nil, nil, nil))
+}
+
+type recordingSender struct{ sent []email.Message }
+
+func (r *recordingSender) Send(_ context.Context, m email.Message) error {
+	r.sent = append(r.sent, m)
+	return nil
+}
+
+func TestQuietHours_DefersWebhookUntilWindowEnds(t *testing.T) {
+	ts, calls := webhookEndpoint(t, 204)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	ny, _ := time.LoadLocation("America/New_York")
+	now := time.Date(2025, 6, 1, 3, 0, 0, 0, ny)
+	windowEnd := time.Date(2025, 6, 1, 7, 0, 0, 0, ny)
+	s := New(db, DefaultConfig())
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// At 3am the delivery is only recorded, due when quiet hours end.
+	expectQuietHours(mock, "user-1", "America/New_York", "22:00", "07:00")
+	mock.ExpectQuery("SELECT url FROM user_webhooks").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"url"}).AddRow(ts.URL))
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WithArgs("user-1", ts.URL, sqlmock.AnyArg(), "pending", 0, "", windowEnd, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.sendWebhook(ctx, "user-1", WebhookEvent{Event: "price_drop", ItemID: "item-1"}, s.quietUntil(ctx, "user-1"))
+	if calls.Load() != 0 {
+		t.Fatalf("Expected no webhook call during quiet hours, got %d", calls.Load())
+	}
+
+	// The first tick after the window delivers it.
+	now = windowEnd.Add(5 * time.Minute)
+	mock.ExpectQuery("FROM webhook_deliveries").
+		WithArgs(now, webhookRetryBatch).
+		WillReturnRows(sqlmock.NewRows(webhookDeliveryColumns).AddRow(1, ts.URL, []byte(`{}`), 0))
+	mock.ExpectExec("SET status = 'delivered'").
+		WithArgs(1, now, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.retryWebhooks(ctx)
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 webhook call after quiet hours, got %d", calls.Load())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestQuietHours_OutsideWindowDeliversImmediately(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	ny, _ := time.LoadLocation("America/New_York")
+	s := New(db, DefaultConfig())
+	s.now = func() time.Time { return time.Date(2025, 6, 1, 12, 0, 0, 0, ny) }
+
+	expectQuietHours(mock, "user-1", "America/New_York", "22:00", "07:00")
+	if until := s.quietUntil(context.Background(), "user-1"); !until.IsZero() {
+		t.Errorf("Expected no deferral at noon, got %v", until)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestQuietHours_QueuesEmailAndFlushesAfterWindow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	sender := &recordingSender{}
+	cfg := DefaultConfig()
+	cfg.Email = email.NewNotifier(db, sender, []byte("secret"), "https://api.example.com", nil)
+	s := New(db, cfg)
+	ny, _ := time.LoadLocation("America/New_York")
+	now := time.Date(2025, 6, 1, 23, 30, 0, 0, ny)
+	windowEnd := time.Date(2025, 6, 2, 7, 0, 0, 0, ny)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	n := Notification{UserID: "user-1", ItemID: "item-1", ProductName: "Widget", OldPrice: "$20", NewPrice: "$15", DeliverAfter: windowEnd}
+
+	html := &capturedArg{}
+	mock.ExpectQuery("SELECT image_url, page_url FROM tracked_items").
+		WithArgs("item-1").
+		WillReturnRows(sqlmock.NewRows([]string{"image_url", "page_url"}).AddRow(nil, "https://shop.example.com/widget"))
+	mock.ExpectQuery("FROM item_price_history").
+		WillReturnRows(sqlmock.NewRows([]string{"price", "recorded_at"}))
+	mock.ExpectExec("INSERT INTO queued_emails").
+		WithArgs("user-1", "price_drops", "Price drop: Widget", sqlmock.AnyArg(), html, windowEnd).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.sendEmail(ctx, n)
+	queuedHTML, _ := html.value.(string)
+	if !strings.Contains(queuedHTML, "https://shop.example.com/widget") || strings.Contains(queuedHTML, "<html") {
+		t.Errorf("Expected the email's own HTML queued without the layout, got:\n%s", queuedHTML)
+	}
+
+	// Still quiet: nothing is due.
+	now = windowEnd.Add(-time.Minute)
+	mock.ExpectQuery("FROM queued_emails").
+		WithArgs(now, queuedEmailBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "channel", "subject", "body", "html"}))
+	s.flushQueuedEmails(ctx)
+	if len(sender.sent) != 0 {
+		t.Fatalf("Expected no email during quiet hours, got %d", len(sender.sent))
+	}
+
+	// Once the window ends the queued email goes out and is removed.
+	now = windowEnd
+	mock.ExpectQuery("FROM queued_emails").
+		WithArgs(now, queuedEmailBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "channel", "subject", "body", "html"}).
+			AddRow(7, "user-1", "price_drops", "Price drop: Widget", "Good news!", queuedHTML))
+	mock.ExpectQuery("SELECT notification_email").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"notification_email", "verified"}).AddRow("me@example.com", true))
+	mock.ExpectQuery("UPDATE user_settings SET unsubscribe_nonce").
+		WillReturnRows(sqlmock.NewRows([]string{"email_price_drops", "unsubscribe_nonce"}).AddRow(true, "nonce"))
+	mock.ExpectExec("DELETE FROM queued_emails").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.flushQueuedEmails(ctx)
+
+	if len(sender.sent) != 1 || sender.sent[0].To != "me@example.com" || sender.sent[0].Subject != "Price drop: Widget" {
+		t.Fatalf("Expected the queued email to be sent after quiet hours, got %+v", sender.sent)
+	}
+	if !strings.Contains(sender.sent[0].HTML, queuedHTML) || !strings.Contains(sender.sent[0].HTML, "<html") {
+		t.Errorf("Expected the queued HTML sent in the layout, got:\n%s", sender.sent[0].HTML)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
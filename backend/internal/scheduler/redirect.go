@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/publicsuffix"
+)
+
+// maxPageRedirects bounds the meta refresh and script redirects the HTTP
+// scrape follows from one page.
+const maxPageRedirects = 2
+
+// ErrOffDomainRedirect is returned when a page leads to a second site, after
+// the one cross-domain hop a short link needs. Such an item is flagged rather
+// than tracked wherever it ends up.
+var ErrOffDomainRedirect = errors.New("page redirects off its domain")
+
+// scriptRedirect matches an inline script sending the browser elsewhere, as
+// interstitial pages do with location.href = "..." or location.replace("...").
+var scriptRedirect = regexp.MustCompile(`location(?:\.href)?\s*=\s*["']([^"']+)["']|location\.(?:replace|assign)\(\s*["']([^"']+)["']\s*\)`)
+
+// pageRedirect returns where doc, a page fetched from base, sends the browser
+// with a <meta http-equiv="refresh"> or an inline script, or nil if it does
+// not. Targets get the same checks as an iframe src.
+func pageRedirect(doc *goquery.Document, base *url.URL) (*url.URL, error) {
+	var target string
+	doc.Find("meta[http-equiv]").EachWithBreak(func(_ int, meta *goquery.Selection) bool {
+		equiv, _ := meta.Attr("http-equiv")
+		if !strings.EqualFold(strings.TrimSpace(equiv), "refresh") {
+			return true
+		}
+		content, _ := meta.Attr("content")
+		target = refreshURL(content)
+		return target == ""
+	})
+	if target == "" {
+		doc.Find("script:not([src])").EachWithBreak(func(_ int, script *goquery.Selection) bool {
+			if m := scriptRedirect.FindStringSubmatch(script.Text()); m != nil {
+				target = m[1] + m[2]
+			}
+			return target == ""
+		})
+	}
+	if target == "" {
+		return nil, nil
+	}
+	u, err := base.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("unsupported redirect target: %q", target)
+	}
+	return u, nil
+}
+
+// refreshURL extracts the URL from a meta refresh content attribute such as
+// `0; url=https://example.com/p/1`. A bare delay reloads the page and yields
+// "".
+func refreshURL(content string) string {
+	_, rest, ok := strings.Cut(content, ";")
+	if !ok {
+		_, rest, ok = strings.Cut(content, ",")
+	}
+	if !ok {
+		return ""
+	}
+	rest = strings.TrimSpace(rest)
+	if len(rest) >= 4 && strings.EqualFold(rest[:3], "url") {
+		if after, ok := strings.CutPrefix(strings.TrimSpace(rest[3:]), "="); ok {
+			rest = strings.TrimSpace(after)
+		}
+	}
+	return strings.Trim(rest, `"'`)
+}
+
+// siteOf returns the registrable domain of u, such as example.co.uk for
+// www.shop.example.co.uk, or its host if it has none.
+func siteOf(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	if site, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return site
+	}
+	return host
+}
+
+// redirectChain counts the sites a scrape passes through on its way to the
+// price, whether by HTTP or page redirects.
+type redirectChain struct {
+	site      string
+	crossings int
+}
+
+func newRedirectChain(start *url.URL) *redirectChain {
+	return &redirectChain{site: siteOf(start)}
+}
+
+// visit records a hop to u. It fails on the second change of site: a short
+// link may lead to the shop, but the shop may not lead elsewhere.
+func (c *redirectChain) visit(u *url.URL) error {
+	if site := siteOf(u); site != c.site {
+		c.site = site
+		c.crossings++
+	}
+	if c.crossings > 1 {
+		return fmt.Errorf("%w: %s", ErrOffDomainRedirect, u.Redacted())
+	}
+	return nil
+}
+
+// recordFinalURL stores where a redirect took item's page, so that its owner
+// can see what is actually being tracked, and clears it once the page stops
+// redirecting. Nothing is written if that did not change or the page never
+// loaded.
+func (s *Scheduler) recordFinalURL(ctx context.Context, item Item, result ScrapeResult) {
+	if result.FinalURL == "" {
+		return
+	}
+	finalURL := result.FinalURL
+	if canonicalPageURL(finalURL) == canonicalPageURL(item.PageURL) {
+		finalURL = ""
+	}
+	if finalURL == item.FinalURL {
+		return
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE tracked_items
+		SET final_url = NULLIF($1, ''), updated_at = NOW()
+		WHERE id = $2
+	`, finalURL, item.ID)
+	if err != nil {
+		slog.Error("Failed to record final URL", "id", item.ID, "error", err)
+	}
+}
@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// redirectShop serves the price at /p/1 and reaches it from other paths
+// through interstitial pages.
+func redirectShop() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/p/1":
+			w.Write([]byte(`<html><body><span class="price">$24.00</span></body></html>`))
+		case "/meta":
+			w.Write([]byte(`<html><head><meta http-equiv="Refresh" content="0; URL='/p/1'"></head><body>Redirecting…</body></html>`))
+		case "/script":
+			w.Write([]byte(`<html><body><script>window.location.replace("/p/1");</script></body></html>`))
+		case "/http":
+			http.Redirect(w, r, "/meta", http.StatusFound)
+		case "/loop/1", "/loop/2", "/loop/3":
+			next := map[string]string{"/loop/1": "/loop/2", "/loop/2": "/loop/3", "/loop/3": "/p/1"}[r.URL.Path]
+			w.Write([]byte(`<html><head><meta http-equiv="refresh" content="0;url=` + next + `"></head></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestScrapeDetailed_FollowsPageRedirects(t *testing.T) {
+	ts := redirectShop()
+	defer ts.Close()
+
+	for _, path := range []string{"/meta", "/script", "/http"} {
+		result, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL + path, CSSSelector: ".price"})
+		if err != nil {
+			t.Errorf("%s: ScrapeDetailed failed: %v", path, err)
+			continue
+		}
+		if result.Text != "$24.00" || result.FinalURL != ts.URL+"/p/1" {
+			t.Errorf("%s: Expected $24.00 from %s, got %q from %s", path, ts.URL+"/p/1", result.Text, result.FinalURL)
+		}
+	}
+}
+
+func TestScrapeDetailed_PageRedirectsBounded(t *testing.T) {
+	ts := redirectShop()
+	defer ts.Close()
+
+	result, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL + "/loop/1", CSSSelector: ".price"})
+	if !errors.Is(err, ErrSelectorNotFound) {
+		t.Errorf("Expected the third redirect not to be followed, got %q (error: %v)", result.Text, err)
+	}
+	if result.FinalURL != ts.URL+"/loop/3" {
+		t.Errorf("Expected the last page reached to be recorded, got %q", result.FinalURL)
+	}
+}
+
+func TestScrapeDetailed_FlagsOffDomainRedirect(t *testing.T) {
+	// One server plays every host: the short links lead to the shop, where
+	// /moved leads on to a second site.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch {
+		case r.Host == "shrt.example":
+			target := map[string]string{"/abc": "/p/1", "/xyz": "/moved"}[r.URL.Path]
+			w.Write([]byte(`<html><head><meta http-equiv="refresh" content="0; url=http://www.shop.example` + target + `"></head></html>`))
+		case r.Host == "www.shop.example" && r.URL.Path == "/moved":
+			w.Write([]byte(`<html><head><meta http-equiv="refresh" content="0; url=http://other.example/p/1"></head></html>`))
+		default:
+			w.Write([]byte(`<html><body><span class="price">$24.00</span></body></html>`))
+		}
+	}))
+	defer ts.Close()
+
+	scraper := httpScraper(false)
+	scraper.transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, ts.Listener.Addr().String())
+	}
+
+	result, err := scraper.ScrapeDetailed(context.Background(), ScrapeRequest{URL: "http://shrt.example/abc", CSSSelector: ".price"})
+	if err != nil || result.Text != "$24.00" || result.FinalURL != "http://www.shop.example/p/1" {
+		t.Errorf("Expected one cross-domain hop to be followed, got %q from %s (error: %v)", result.Text, result.FinalURL, err)
+	}
+
+	result, err = scraper.ScrapeDetailed(context.Background(), ScrapeRequest{URL: "http://shrt.example/xyz", CSSSelector: ".price"})
+	if !errors.Is(err, ErrOffDomainRedirect) {
+		t.Errorf("Expected a second cross-domain hop to be flagged, got %q (error: %v)", result.Text, err)
+	}
+	if result.FinalURL != "http://www.shop.example/moved" {
+		t.Errorf("Expected the last page on the shop to be recorded, got %q", result.FinalURL)
+	}
+}
+
+func TestRefreshURL(t *testing.T) {
+	tests := map[string]string{
+		"0; url=https://example.com/p/1": "https://example.com/p/1",
+		"0;URL='/p/1'":                   "/p/1",
+		`5; url="/p/2"`:                  "/p/2",
+		"0, url=/p/3":                    "/p/3",
+		"0; /p/4":                        "/p/4",
+		"30":                             "",
+		"0; url=":                        "",
+	}
+	for content, want := range tests {
+		if got := refreshURL(content); got != want {
+			t.Errorf("refreshURL(%q) = %q, expected %q", content, got, want)
+		}
+	}
+}
+
+func TestRecordFinalURL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := New(db, localConfig())
+	item := Item{ID: "item-1", PageURL: "https://shrt.example/abc"}
+
+	mock.ExpectExec(`SET final_url = NULLIF\(\$1, ''\)`).
+		WithArgs("https://shop.example/p/1", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.recordFinalURL(context.Background(), item, ScrapeResult{FinalURL: "https://shop.example/p/1"})
+
+	// Unchanged, unloaded or not redirected: nothing to write.
+	item.FinalURL = "https://shop.example/p/1"
+	s.recordFinalURL(context.Background(), item, ScrapeResult{FinalURL: "https://shop.example/p/1"})
+	s.recordFinalURL(context.Background(), item, ScrapeResult{})
+	s.recordFinalURL(context.Background(), Item{ID: "item-2", PageURL: "https://shop.example/p/2"}, ScrapeResult{FinalURL: "https://shop.example/p/2/"})
+
+	// A page that stopped redirecting clears it.
+	mock.ExpectExec(`SET final_url = NULLIF\(\$1, ''\)`).
+		WithArgs("", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.recordFinalURL(context.Background(), item, ScrapeResult{FinalURL: "https://shrt.example/abc"})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import "github.com/playwright-community/playwright-go"
+
+// defaultTimezone is the browser timezone of items without a country.
+const defaultTimezone = "America/Los_Angeles"
+
+// region is where the headless browser pretends to be for an item's country:
+// a timezone and coordinates near its largest city.
+type region struct {
+	timezone  string
+	latitude  float64
+	longitude float64
+}
+
+// regions maps the ISO 3166-1 alpha-2 codes items may set as countryCode.
+var regions = map[string]region{
+	"AU": {"Australia/Sydney", -33.87, 151.21},
+	"AT": {"Europe/Vienna", 48.21, 16.37},
+	"BE": {"Europe/Brussels", 50.85, 4.35},
+	"BR": {"America/Sao_Paulo", -23.55, -46.63},
+	"CA": {"America/Toronto", 43.65, -79.38},
+	"CH": {"Europe/Zurich", 47.38, 8.54},
+	"CN": {"Asia/Shanghai", 31.23, 121.47},
+	"DE": {"Europe/Berlin", 52.52, 13.40},
+	"DK": {"Europe/Copenhagen", 55.68, 12.57},
+	"ES": {"Europe/Madrid", 40.42, -3.70},
+	"FI": {"Europe/Helsinki", 60.17, 24.94},
+	"FR": {"Europe/Paris", 48.86, 2.35},
+	"GB": {"Europe/London", 51.51, -0.13},
+	"IE": {"Europe/Dublin", 53.35, -6.26},
+	"IN": {"Asia/Kolkata", 19.08, 72.88},
+	"IT": {"Europe/Rome", 41.90, 12.50},
+	"JP": {"Asia/Tokyo", 35.68, 139.69},
+	"KR": {"Asia/Seoul", 37.57, 126.98},
+	"MX": {"America/Mexico_City", 19.43, -99.13},
+	"NL": {"Europe/Amsterdam", 52.37, 4.90},
+	"NO": {"Europe/Oslo", 59.91, 10.75},
+	"NZ": {"Pacific/Auckland", -36.85, 174.76},
+	"PL": {"Europe/Warsaw", 52.23, 21.01},
+	"PT": {"Europe/Lisbon", 38.72, -9.14},
+	"SE": {"Europe/Stockholm", 59.33, 18.07},
+	"SG": {"Asia/Singapore", 1.35, 103.82},
+	"TR": {"Europe/Istanbul", 41.01, 28.98},
+	"US": {"America/New_York", 40.71, -74.01},
+	"ZA": {"Africa/Johannesburg", -26.20, 28.05},
+}
+
+// ValidCountryCode reports whether code is a country items can be scraped
+// from. Empty means none.
+func ValidCountryCode(code string) bool {
+	if code == "" {
+		return true
+	}
+	_, ok := regions[code]
+	return ok
+}
+
+// timezoneFor is the browser timezone for country.
+func timezoneFor(country string) string {
+	if r, ok := regions[country]; ok {
+		return r.timezone
+	}
+	return defaultTimezone
+}
+
+// geolocationFor is the browser position for country, nil when it has none.
+func geolocationFor(country string) *playwright.Geolocation {
+	r, ok := regions[country]
+	if !ok {
+		return nil
+	}
+	return &playwright.Geolocation{Latitude: r.latitude, Longitude: r.longitude}
+}
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCheckAllPrices_AcceptLanguagePerItem(t *testing.T) {
+
+	var mu sync.Mutex
+	var seen []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("Accept-Language"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">€19,99</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+	expectNoPendingWebhooks(mock)
+	expectQueuedRun(mock, "item-1", "item-2")
+
+	// The same page, asked for in German from Germany and in French from France.
+	columns := []string{"id", "user_id", "price_text", "product_name", "page_url", "css_selector", "xpath", "frame_selector", "frame_url", "variant_selector", "variant_value", "adapter_order", "min_expected", "max_expected", "parse_strategy", "accept_language", "country_code", "cookies_encrypted", "headers", "proxy_url", "scrape_profile", "final_url", "not_found_count", "max_scrape_seconds", "failure_streak", "selector_match", "shipping_selector", "total_price", "notification_channel", "last_notified_price", "availability_selector", "availability", "variants"}
+	mock.ExpectQuery("FROM tracked_items").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("item-1", "user-1", "€19,99", "Jacke", ts.URL+"/p", ".price", "", "", "", "", "", "first", nil, nil, "eu", "de-DE", "DE", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil).
+		AddRow("item-2", "user-2", "€19,99", "Veste", ts.URL+"/p", ".price", "", "", "", "", "", "first", nil, nil, "eu", "fr-FR", "FR", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil))
+	for _, id := range []string{"item-1", "item-2"} {
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("success", id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("SET last_price").
+			WithArgs(19.99, id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	expectScrapeLogBatch(mock, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO scheduler_heartbeat").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	New(db, noBrowserConfig()).CheckAllPrices(context.Background())
+
+	sort.Strings(seen)
+	if len(seen) != 2 || seen[0] != "de-DE,de;q=0.9" || seen[1] != "fr-FR,fr;q=0.9" {
+		t.Errorf("Expected one request per item's locale, got %q", seen)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestNewScrapeLogEntry_Locale(t *testing.T) {
+	entry := newScrapeLogEntry(Item{ID: "item-1", PageURL: "https://example.com/p", AcceptLanguage: "de-CH", CountryCode: "CH"}, ScrapeResult{})
+	if entry.Locale != "de-CH" || entry.CountryCode != "CH" {
+		t.Errorf("Expected de-CH from CH, got %q from %q", entry.Locale, entry.CountryCode)
+	}
+
+	entry = newScrapeLogEntry(Item{ID: "item-2", PageURL: "https://example.com/p"}, ScrapeResult{})
+	if entry.Locale != DefaultAcceptLanguage || entry.CountryCode != "" {
+		t.Errorf("Expected the default locale and no country, got %q from %q", entry.Locale, entry.CountryCode)
+	}
+}
+
+func TestRegions(t *testing.T) {
+	if !ValidCountryCode("") || !ValidCountryCode("JP") || ValidCountryCode("jp") || ValidCountryCode("XX") {
+		t.Error("Expected only empty and known upper-case codes to be valid")
+	}
+	if got := timezoneFor("JP"); got != "Asia/Tokyo" {
+		t.Errorf("Expected Asia/Tokyo, got %s", got)
+	}
+	if got := timezoneFor(""); got != defaultTimezone {
+		t.Errorf("Expected the default timezone without a country, got %s", got)
+	}
+	if geolocationFor("") != nil {
+		t.Error("Expected no geolocation without a country")
+	}
+	if geo := geolocationFor("DE"); geo == nil || geo.Latitude != 52.52 || geo.Longitude != 13.40 {
+		t.Errorf("Expected Berlin, got %+v", geo)
+	}
+}
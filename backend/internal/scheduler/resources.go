@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// DefaultBlockedResources are the resource types the Playwright fallback does
+// not load unless SCRAPE_BLOCK_RESOURCES says otherwise. Prices are text, so
+// only the document, its scripts and their requests are needed to read one.
+var DefaultBlockedResources = []string{"image", "font", "media"}
+
+// blockableResources are the Playwright resource types that may be blocked.
+// Blocking the document itself would leave nothing to scrape.
+var blockableResources = map[string]bool{
+	"stylesheet":  true,
+	"image":       true,
+	"media":       true,
+	"font":        true,
+	"script":      true,
+	"texttrack":   true,
+	"xhr":         true,
+	"fetch":       true,
+	"eventsource": true,
+	"websocket":   true,
+	"manifest":    true,
+	"other":       true,
+}
+
+// ParseBlockedResources parses a comma-separated list of resource types to
+// block, such as "image,font,media". "none" blocks nothing and returns nil.
+func ParseBlockedResources(v string) ([]string, error) {
+	if strings.TrimSpace(v) == "none" {
+		return nil, nil
+	}
+	var types []string
+	for _, t := range strings.Split(v, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if !blockableResources[t] {
+			return nil, fmt.Errorf("cannot block resource type %q", t)
+		}
+		types = append(types, t)
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no resource types given")
+	}
+	return types, nil
+}
+
+// resourceBlocked reports whether a request for a resource of type
+// resourceType is aborted when blocked lists the types to block.
+func resourceBlocked(blocked []string, resourceType string) bool {
+	for _, t := range blocked {
+		if t == resourceType {
+			return true
+		}
+	}
+	return false
+}
+
+// routeRequests aborts the browser context's requests for hosts that are not
+// public (see CheckPublicHost), such as a page sending the browser to an
+// internal service, and those for the scraper's blocked resource types, so
+// that pages heavy with images and video load faster. The returned function
+// reports the first private address refused, if any. The browser follows
+// HTTP redirects without routing them, so where a page ended up is checked
+// separately.
+func (s *Scraper) routeRequests(ctx context.Context, browserContext playwright.BrowserContext) func() error {
+	var mu sync.Mutex
+	var refused error
+	checked := map[string]error{}
+	checkHost := func(raw string) error {
+		u, err := url.Parse(raw)
+		if err != nil || s.allowPrivate || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		err, ok := checked[u.Hostname()]
+		if !ok {
+			err = CheckPublicHost(ctx, u.Hostname())
+			checked[u.Hostname()] = err
+		}
+		if err != nil && refused == nil {
+			refused = err
+		}
+		return err
+	}
+
+	blocked := s.blocked
+	err := browserContext.Route("**/*", func(route playwright.Route) {
+		var err error
+		switch {
+		case checkHost(route.Request().URL()) != nil:
+			err = route.Abort("addressunreachable")
+		case resourceBlocked(blocked, route.Request().ResourceType()):
+			err = route.Abort("blockedbyclient")
+		default:
+			err = route.Continue()
+		}
+		if err != nil {
+			slog.Debug("Could not route request", "url", route.Request().URL(), "error", err)
+		}
+	})
+	if err != nil {
+		slog.Warn("Could not route requests", "error", err)
+	}
+	return func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return refused
+	}
+}
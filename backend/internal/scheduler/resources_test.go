@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseBlockedResources(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"image,font,media", []string{"image", "font", "media"}, false},
+		{" Image , stylesheet,", []string{"image", "stylesheet"}, false},
+		{"none", nil, false},
+		{"document", nil, true},
+		{"image,pictures", nil, true},
+		{",", nil, true},
+	}
+	for _, test := range tests {
+		got, err := ParseBlockedResources(test.in)
+		if (err != nil) != test.wantErr || !slices.Equal(got, test.want) {
+			t.Errorf("ParseBlockedResources(%q) = %q, %v; want %q (error: %v)", test.in, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestResourceBlocked_Defaults(t *testing.T) {
+	for _, blocked := range []string{"image", "font", "media"} {
+		if !resourceBlocked(DefaultBlockedResources, blocked) {
+			t.Errorf("Expected %s to be blocked by default", blocked)
+		}
+	}
+	for _, loaded := range []string{"document", "script", "xhr", "fetch", "stylesheet"} {
+		if resourceBlocked(DefaultBlockedResources, loaded) {
+			t.Errorf("Expected %s to load by default", loaded)
+		}
+	}
+	if resourceBlocked(nil, "image") {
+		t.Error("Expected nothing to be blocked when blocking is off")
+	}
+	if s := New(nil, DefaultConfig()); !slices.Equal(s.scraper.blocked, DefaultBlockedResources) {
+		t.Errorf("Expected the scraper to block the defaults, got %q", s.scraper.blocked)
+	}
+}
@@ -0,0 +1,49 @@
+package scheduler
+
+import "context"
+
+// ItemResult is the outcome of checking one item, as recorded in its scrape
+// log entry.
+type ItemResult struct {
+	ItemID         string `json:"itemId"`
+	UserID         string `json:"userId"`
+	Domain         string `json:"domain"`
+	Status         string `json:"status"`
+	FailureReason  string `json:"failureReason,omitempty"`
+	Error          string `json:"error,omitempty"`
+	DurationMs     int64  `json:"durationMs"`
+	UsedPlaywright bool   `json:"usedPlaywright"`
+	FinalURL       string `json:"finalUrl,omitempty"`
+	Variant        string `json:"variant,omitempty"`
+	BackoffSeconds int64  `json:"backoffSeconds,omitempty"`
+}
+
+type itemResultsKey struct{}
+
+// WithItemResults returns a context under which every item a price check
+// processes is reported to fn once its outcome is recorded. fn is called from
+// the run's workers, so it must be safe for concurrent use.
+func WithItemResults(ctx context.Context, fn func(ItemResult)) context.Context {
+	return context.WithValue(ctx, itemResultsKey{}, fn)
+}
+
+// reportItemResult passes entry to the function ctx carries, if any.
+func reportItemResult(ctx context.Context, entry scrapeLogEntry) {
+	fn, ok := ctx.Value(itemResultsKey{}).(func(ItemResult))
+	if !ok {
+		return
+	}
+	fn(ItemResult{
+		ItemID:         entry.ItemID,
+		UserID:         entry.UserID,
+		Domain:         entry.Domain,
+		Status:         entry.Status,
+		FailureReason:  entry.FailureReason,
+		Error:          entry.Error,
+		DurationMs:     entry.DurationMs,
+		UsedPlaywright: entry.UsedPlaywright,
+		FinalURL:       entry.FinalURL,
+		Variant:        entry.Variant,
+		BackoffSeconds: entry.BackoffSeconds,
+	})
+}
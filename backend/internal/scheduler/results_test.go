@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestWithItemResults_ReportsEachItem(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><span class="price">$24.00</span></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExec("UPDATE tracked_items").WithArgs("success", "item-1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET last_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE tracked_items").WithArgs("failed", "item-2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO scrape_log").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	var results []ItemResult
+	ctx := WithItemResults(context.Background(), func(result ItemResult) { results = append(results, result) })
+	cfg := noBrowserConfig()
+	cfg.JSONLDFallbackDisabled = true
+	s := New(db, cfg)
+	s.processItem(ctx, Item{ID: "item-1", UserID: "user-1", PriceText: "$24.00", PageURL: ts.URL, CSSSelector: ".price"})
+	s.processItem(ctx, Item{ID: "item-2", UserID: "user-1", PriceText: "$24.00", PageURL: ts.URL, CSSSelector: ".gone"})
+
+	if len(results) != 2 {
+		t.Fatalf("Expected two results, got %+v", results)
+	}
+	if r := results[0]; r.ItemID != "item-1" || r.Status != "success" || r.FinalURL != ts.URL {
+		t.Errorf("Expected item-1 to succeed, got %+v", r)
+	}
+	if r := results[1]; r.ItemID != "item-2" || r.Status != "failed" || r.FailureReason != "selector_not_found" || r.Error == "" {
+		t.Errorf("Expected item-2 to fail with its reason, got %+v", r)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import "context"
+
+// RevalidateSummary is the outcome of RevalidateBroken, counted by the status
+// each item ended up with.
+type RevalidateSummary struct {
+	Checked     int `json:"checked"`
+	Fixed       int `json:"fixed"`
+	StillBroken int `json:"stillBroken"`
+	Failed      int `json:"failed"`
+}
+
+// RevalidateBroken re-scrapes every item flagged selector_broken, paused or
+// not, and only userID's if it is non-empty. The items are queued as jobs
+// ahead of scheduled checks and run right away, and the summary is read back
+// from the jobs' results. Items whose selector matches again go back to
+// success through the usual price check, which also applies any price change
+// found on the way.
+func (s *Scheduler) RevalidateBroken(ctx context.Context, userID string) (RevalidateSummary, error) {
+	var summary RevalidateSummary
+	query, args, scope := `SELECT id FROM tracked_items WHERE last_scrape_status = 'selector_broken'`, []any{}, "broken selectors"
+	if userID != "" {
+		query += ` AND user_id = $1`
+		args = append(args, userID)
+		scope += " of user " + userID
+	}
+	var ids []string
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return summary, err
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return summary, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return summary, err
+	}
+	if len(ids) == 0 {
+		return summary, nil
+	}
+
+	jobs, err := s.EnqueueJobs(ctx, JobRevalidate, ids)
+	if err != nil {
+		return summary, err
+	}
+	if _, err := s.runJobs(ctx, scope, jobs); err != nil {
+		return summary, err
+	}
+
+	counts, err := s.jobSummary(ctx, jobs)
+	if err != nil {
+		return summary, err
+	}
+	for status, n := range counts {
+		summary.Checked += n
+		switch status {
+		case "success":
+			summary.Fixed += n
+		case "selector_broken":
+			summary.StillBroken += n
+		default:
+			summary.Failed += n
+		}
+	}
+	return summary, nil
+}
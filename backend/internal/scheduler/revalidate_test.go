@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRevalidateBroken_MixedResults(t *testing.T) {
+	// /fixed has its price element back; /broken still only carries the
+	// price in JSON-LD.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/fixed" {
+			w.Write([]byte(`<html><body><div class="price">$19.99</div></body></html>`))
+			return
+		}
+		w.Write([]byte(`<html><head><script type="application/ld+json">
+			{"@context":"https://schema.org","@type":"Product","offers":{"@type":"Offer","price":"19.99","priceCurrency":"USD"}}
+		</script></head><body></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	mock.ExpectQuery("SELECT id FROM tracked_items WHERE last_scrape_status = 'selector_broken' AND user_id = \\$1").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("item-1").AddRow("item-2"))
+	// The items are queued ahead of scheduled checks and run right away;
+	// only their own jobs are claimed.
+	mock.ExpectQuery("INSERT INTO scrape_jobs").
+		WithArgs(`{"item-1","item-2"}`, JobRevalidate, 50, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)).AddRow(int64(2)))
+	mock.ExpectQuery(claimQuery+`.*AND id = ANY\(\$5\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "{1,2}").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}).AddRow(int64(1), "item-1").AddRow(int64(2), "item-2"))
+	mock.ExpectQuery(claimQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}))
+	columns := []string{"id", "user_id", "price_text", "product_name", "page_url", "css_selector", "xpath", "frame_selector", "frame_url", "variant_selector", "variant_value", "adapter_order", "min_expected", "max_expected", "parse_strategy", "accept_language", "country_code", "cookies_encrypted", "headers", "proxy_url", "scrape_profile", "final_url", "not_found_count", "max_scrape_seconds", "failure_streak", "selector_match", "shipping_selector", "total_price", "notification_channel", "last_notified_price", "availability_selector", "availability", "variants"}
+	mock.ExpectQuery(`FROM tracked_items\s+WHERE id = ANY\(\$1\) AND md5\(\$2 \|\| id\) \|\| id > \$3`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("item-1", "user-1", "$19.99", "Fixed", ts.URL+"/fixed", ".price", "", "", "", "", "", "first", nil, nil, "auto", "en-US", "", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil).
+			AddRow("item-2", "user-1", "$19.99", "Broken", ts.URL+"/broken", ".price", "", "", "", "", "", "first", nil, nil, "auto", "en-US", "", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil))
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SET last_price").
+		WithArgs(19.99, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// Still flagged, so the status does not change and nobody is notified
+	// again.
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("selector_broken", "item-2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET last_price").
+		WithArgs(19.99, "item-2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectScrapeLogBatch(mock, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE scrape_jobs j").
+		WithArgs("{1,2}", `{"success","selector_broken"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("SELECT COALESCE\\(result_status, state\\), COUNT").
+		WithArgs("{1,2}").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).
+			AddRow("success", 1).
+			AddRow("selector_broken", 1))
+
+	summary, err := New(db, noBrowserConfig()).RevalidateBroken(context.Background(), "user-1")
+	if err != nil {
+		t.Fatalf("RevalidateBroken failed: %v", err)
+	}
+	want := RevalidateSummary{Checked: 2, Fixed: 1, StillBroken: 1}
+	if summary != want {
+		t.Errorf("Expected %+v, got %+v", want, summary)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestRevalidateBroken_NothingFlagged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM tracked_items WHERE last_scrape_status = 'selector_broken'$").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	summary, err := New(db, localConfig()).RevalidateBroken(context.Background(), "")
+	if err != nil {
+		t.Fatalf("RevalidateBroken failed: %v", err)
+	}
+	if summary != (RevalidateSummary{}) {
+		t.Errorf("Expected an empty summary, got %+v", summary)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -3,64 +3,638 @@ package scheduler
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
-	"regexp"
-	"strconv"
+	"net/http"
+	"net/url"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"price-track-backend/internal/adapters"
+	"price-track-backend/internal/blobstore"
+	"price-track-backend/internal/email"
+	"price-track-backend/internal/realtime"
+	"price-track-backend/internal/secretbox"
+	"price-track-backend/internal/settings"
 )
 
+// Item is a tracked item as loaded by the scheduler for a price check.
+type Item struct {
+	ID             string
+	UserID         string
+	PriceText      string
+	ProductName    string
+	PageURL        string
+	CSSSelector    string
+	XPath          string
+	Frame          Frame
+	Selection      VariantSelection
+	AdapterOrder   AdapterOrder
+	SelectorMatch  SelectorMatch
+	Bounds         priceBounds
+	ParseStrategy  ParseStrategy
+	AcceptLanguage string
+	CountryCode    string
+	Cookies        []Cookie
+	Headers        map[string]string
+	Proxy          *url.URL
+	Profile        ScrapeProfile
+	// MaxDuration is the item's own limit on one scrape (max_scrape_seconds),
+	// zero to leave it to the scraper's item timeout.
+	MaxDuration time.Duration
+	// FinalURL is where a redirect took the page on an earlier scrape, empty
+	// if it did not.
+	FinalURL string
+	// NotFoundCount is the number of checks in a row that found the page
+	// gone.
+	NotFoundCount int
+	// FailureStreak is the number of checks in a row that failed (see
+	// backOff).
+	FailureStreak int
+	Variants      []Variant
+	// ShippingSelector selects the item's shipping cost on its page, empty
+	// if the item is compared on its price alone.
+	ShippingSelector string
+	// TotalPrice is the price with shipping the last check found, invalid
+	// until one was read.
+	TotalPrice sql.NullFloat64
+	// NotificationChannel overrides where the owner's settings deliver the
+	// item's price drops; empty keeps their default.
+	NotificationChannel settings.NotificationChannel
+	// LastNotifiedPrice is the price, or total, of the last drop the owner
+	// was notified of; invalid until the first (see dropBaseline).
+	LastNotifiedPrice sql.NullFloat64
+	// AvailabilitySelector selects the item's stock text on its page, which
+	// is checked before the price (see withAvailabilitySelector); empty if
+	// the item has none.
+	AvailabilitySelector string
+	// Availability is what the last check found about the item's stock,
+	// empty until one did.
+	Availability Availability
+}
+
+// pageKey identifies the page an item is on as the scraper fetches it: items
+// sharing a key see the same content, whatever element they select. A page
+// shows each variant's own price, so the selection is part of it.
+func (i Item) pageKey() string {
+	return canonicalPageURL(i.PageURL) + "\x00" + i.Selection.Selector + "\x00" + i.Selection.Value + "\x00" + i.AcceptLanguage + "\x00" + i.CountryCode + "\x00" + cookiesSignature(i.Cookies) + "\x00" + headersSignature(i.Headers) + "\x00" + proxyKeyOf(i.Proxy)
+}
+
+// scrapeSignature identifies the page and element an item scrapes. Items that
+// share a signature produce the same scrape result, so it is fetched once.
+// The adapter order only counts on sites that have an adapter.
+func (i Item) scrapeSignature() string {
+	sig := i.pageKey() + "\x00" + i.Frame.Selector + "\x00" + i.Frame.URL + "\x00" + i.CSSSelector + "\x00" + i.XPath + "\x00" + string(i.SelectorMatch) + "\x00" + i.ShippingSelector + "\x00" + i.AvailabilitySelector + "\x00" + string(i.Profile) + "\x00" + i.MaxDuration.String()
+	if u, err := url.Parse(i.PageURL); err == nil && adapters.For(u) != nil {
+		order := i.AdapterOrder
+		if order == "" {
+			order = AdapterFirst
+		}
+		sig += "\x00" + string(order)
+	}
+	return sig
+}
+
+// scrapeRequest is what the scraper is asked for to scrape the item.
+func (i Item) scrapeRequest() ScrapeRequest {
+	return ScrapeRequest{
+		URL:            i.PageURL,
+		CSSSelector:    i.CSSSelector,
+		XPath:          i.XPath,
+		Match:          i.SelectorMatch,
+		Frame:          i.Frame,
+		Selection:      i.Selection,
+		Order:          i.AdapterOrder,
+		AcceptLanguage: i.AcceptLanguage,
+		CountryCode:    i.CountryCode,
+		Cookies:        i.Cookies,
+		Headers:        i.Headers,
+		Proxy:          i.Proxy,
+		Profile:        i.Profile,
+	}
+}
+
+// scrapeContext bounds a scrape of the item by its own MaxDuration, if it has
+// one, and has it read the item's shipping cost and check its stock.
+func (i Item) scrapeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = withShippingSelector(ctx, i.ShippingSelector)
+	ctx = withAvailabilitySelector(ctx, i.AvailabilitySelector)
+	if i.MaxDuration <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, i.MaxDuration)
+}
+
+// groupItems buckets items by page, preserving first-seen order, so that the
+// prices different selectors find on one page can be compared.
+func groupItems(items []Item) [][]Item {
+	index := make(map[string]int)
+	var groups [][]Item
+	for _, item := range items {
+		sig := item.pageKey()
+		if i, ok := index[sig]; ok {
+			groups[i] = append(groups[i], item)
+			continue
+		}
+		index[sig] = len(groups)
+		groups = append(groups, []Item{item})
+	}
+	return groups
+}
+
 type Scheduler struct {
 	db      *sql.DB
 	scraper *Scraper
+
+	// maxItemAge is how long an item may go without user interaction before
+	// it is auto-paused. Zero disables auto-pausing.
+	maxItemAge time.Duration
+	now        func() time.Time
+
+	// batchSize is the number of items fetched per keyset page and
+	// concurrency the number of pages scraped in parallel.
+	batchSize   int
+	concurrency int
+
+	// adoptBaseline controls what happens when the stored price cannot be
+	// parsed: adopt the freshly scraped price as the new baseline (default),
+	// or leave the item untouched.
+	adoptBaseline bool
+	// compareAcrossCurrencies compares prices whose currency changed as
+	// plain numbers instead of reporting the change.
+	compareAcrossCurrencies bool
+	// outlierFactor is how far, as a ratio either way, an item's price may
+	// be from the median of the other items on its page. Zero disables the
+	// consensus check.
+	outlierFactor float64
+	// errorBudget is the share of an item's scrapes over errorBudgetWindow
+	// that may fail before its owner is told. Zero disables the check.
+	errorBudget       float64
+	errorBudgetWindow time.Duration
+	// discontinueAfter is how many checks in a row an item's page may be gone
+	// before it is marked discontinued. Zero disables it.
+	discontinueAfter int
+	// silenceWindow is how long no price drop may be notified before the
+	// operator is alerted, and silent whether the last check alerted (see
+	// checkNotificationSilence). Zero disables the check.
+	silenceWindow time.Duration
+	silent        atomic.Bool
+	// scrapeQuota is how many checks each user's items get per calendar
+	// month (see withinScrapeQuota). Zero means unlimited.
+	scrapeQuota int
+	// checkInterval is how often runs happen and backoffMax the longest a
+	// failing item's check is put off for (see backOff). Zero in either
+	// disables backoff.
+	checkInterval time.Duration
+	backoffMax    time.Duration
+	// severity grades price drop notifications.
+	severity SeverityThresholds
+	// dropAverage holds drops of volatile prices back (see
+	// belowRecentAverage).
+	dropAverage DropAverage
+	// worker names this process on the scrape_jobs it claims.
+	worker string
+
+	webhookClient *http.Client
+	email         *email.Notifier
+	realtime      *realtime.Broadcaster
+	// notifier tells users about price drops (see defaultNotifier).
+	notifier Notifier
+	// userSettings provides quiet hours, which defer webhooks and emails, and
+	// the default notification channel.
+	userSettings *settings.Loader
+	// cookies decrypts items' stored cookies; nil if no key is configured.
+	cookies *secretbox.Box
+	// blobs keeps failure screenshots; nil drops them.
+	blobs blobstore.Store
+}
+
+const (
+	defaultBatchSize   = 500
+	defaultConcurrency = 8
+	// settingsCacheTTL bounds how long a run may use settings that changed
+	// after it read them.
+	settingsCacheTTL = 5 * time.Minute
+)
+
+// Config holds the scheduler's tunables. internal/config fills it in from
+// the environment.
+type Config struct {
+	// MaxItemAge auto-pauses items nobody interacted with for this long.
+	// Zero disables auto-pausing.
+	MaxItemAge time.Duration
+	// Concurrency is the number of keyset pages scraped in parallel.
+	Concurrency int
+	// AdoptBaseline makes a freshly scraped price the new baseline when the
+	// stored one cannot be parsed.
+	AdoptBaseline bool
+	// CompareAcrossCurrencies compares a price in a new currency with the
+	// old one as if they were the same, instead of sending a
+	// currency_changed notification and taking it as the new baseline.
+	CompareAcrossCurrencies bool
+	// OutlierFactor flags an item whose price is more than this factor above
+	// or below the median price of the items on the same page (with at least
+	// consensusMinItems of them). Zero disables the check.
+	OutlierFactor float64
+	// ErrorBudget is the share (0 to 1) of an item's scrapes over
+	// ErrorBudgetWindow that may fail before a tracking_unreliable
+	// notification is sent. Zero disables the check.
+	ErrorBudget       float64
+	ErrorBudgetWindow time.Duration
+	// DiscontinueAfter marks an item discontinued once its page answered 404
+	// or 410 this many checks in a row. Zero disables it.
+	DiscontinueAfter int
+	// SilenceWindow is how long full passes wait for any price drop to be
+	// notified before alerting the operator, when drops are usually
+	// frequent (NOTIFICATION_SILENCE_WINDOW). Zero disables the check.
+	SilenceWindow time.Duration
+	// ScrapeQuota is how many checks each user's items get per calendar
+	// month in UTC (SCRAPE_QUOTA_MONTHLY); items of users who used them up
+	// are skipped until the next month. Zero means unlimited.
+	ScrapeQuota int
+	// Email sends price drop emails to verified addresses. The caller sets
+	// it; nil turns email off.
+	Email *email.Notifier
+	// Realtime broadcasts each price drop notification on its owner's
+	// Supabase Realtime channel once it is stored. The caller sets it; nil
+	// turns broadcasting off.
+	Realtime *realtime.Broadcaster
+	// Notifiers are told about every price drop delivered to all channels
+	// (see settings.NotifyAll), as well as the in-app notification, webhook
+	// and email, after the user's quiet hours.
+	Notifiers []Notifier
+	// Cookies decrypts the cookies stored with items (COOKIE_ENCRYPTION_KEY).
+	// Without it, items are scraped without their cookies.
+	Cookies *secretbox.Box
+	// Proxy routes scrapes of items without a proxy of their own
+	// (SCRAPER_PROXY_URL). Nil leaves HTTP_PROXY and friends in charge.
+	Proxy *url.URL
+	// Profile is the Playwright scrape profile for items without one of
+	// their own (SCRAPE_PROFILE). Empty means ProfileStealth.
+	Profile ScrapeProfile
+	// BlockResources are the resource types the Playwright fallback does not
+	// load (SCRAPE_BLOCK_RESOURCES). Nil loads everything.
+	BlockResources []string
+	// PlaywrightDisabled turns the headless browser fallback off
+	// (PLAYWRIGHT_DISABLED), e.g. on hosts without browser binaries.
+	PlaywrightDisabled bool
+	// JSONLDFallbackDisabled stops prices from being recovered from the
+	// page's JSON-LD when a selector breaks (JSONLD_FALLBACK_DISABLED).
+	JSONLDFallbackDisabled bool
+	// PrivateAddressesAllowed lets items on loopback, private and link-local
+	// addresses be scraped (SCRAPE_ALLOW_PRIVATE_ADDRESSES), e.g. a shop on
+	// the same network as a self-hosted instance.
+	PrivateAddressesAllowed bool
+	// ItemTimeout bounds each item's scrape, browser fallback included
+	// (SCRAPE_ITEM_TIMEOUT), and ItemHTTPTimeout its plain HTTP part
+	// (SCRAPE_HTTP_TIMEOUT). A run stops starting items once less than
+	// ItemTimeout is left before its deadline.
+	ItemTimeout     time.Duration
+	ItemHTTPTimeout time.Duration
+	// CheckInterval is how often runs happen (SCHEDULER_INTERVAL). An item
+	// that keeps failing is checked every 2, 4, 8... intervals instead, up to
+	// BackoffMax (FAILURE_BACKOFF_MAX). Zero in either disables this.
+	CheckInterval time.Duration
+	BackoffMax    time.Duration
+	// Severity grades price drop notifications (SEVERITY_NOTICE_PERCENT,
+	// SEVERITY_ALERT_PERCENT).
+	Severity SeverityThresholds
+	// DropAverage only notifies drops that take the price well below its
+	// recent average (DROP_AVERAGE_WINDOW, DROP_AVERAGE_PERCENT). A zero
+	// Window compares with the last price alone.
+	DropAverage DropAverage
+	// Blobs keeps the screenshots of pages the Playwright fallback found no
+	// price on (BLOB_STORE_DIR, or S3_BUCKET and friends). Nil drops them.
+	Blobs blobstore.Store
 }
 
-func New(db *sql.DB) *Scheduler {
-	return &Scheduler{
-		db:      db,
-		scraper: NewScraper(),
+// DefaultConfig returns the configuration used when nothing is overridden.
+func DefaultConfig() Config {
+	return Config{
+		Concurrency:       defaultConcurrency,
+		AdoptBaseline:     true,
+		OutlierFactor:     defaultOutlierFactor,
+		ErrorBudget:       defaultErrorBudget,
+		ErrorBudgetWindow: defaultErrorBudgetWindow,
+		DiscontinueAfter:  defaultDiscontinueAfter,
+		SilenceWindow:     defaultSilenceWindow,
+		BlockResources:    DefaultBlockedResources,
+		ItemTimeout:       DefaultItemTimeout,
+		ItemHTTPTimeout:   DefaultItemHTTPTimeout,
+		BackoffMax:        defaultBackoffMax,
+		Severity:          DefaultSeverityThresholds,
+		DropAverage:       DropAverage{Percent: DefaultDropAveragePercent},
 	}
 }
 
-// CheckAllPrices runs a single pass of price checks for all tracked items.
-// It blocks until all items have been processed or the context is cancelled.
+func New(db *sql.DB, cfg Config) *Scheduler {
+	scraper := NewScraper()
+	scraper.proxy = cfg.Proxy
+	scraper.profile = cfg.Profile
+	scraper.blocked = cfg.BlockResources
+	scraper.itemTimeout = cfg.ItemTimeout
+	scraper.httpTimeout = cfg.ItemHTTPTimeout
+	scraper.noBrowser = cfg.PlaywrightDisabled
+	scraper.noJSONLD = cfg.JSONLDFallbackDisabled
+	scraper.AllowPrivateAddresses(cfg.PrivateAddressesAllowed)
+	s := &Scheduler{
+		db:                      db,
+		scraper:                 scraper,
+		maxItemAge:              cfg.MaxItemAge,
+		now:                     time.Now,
+		batchSize:               defaultBatchSize,
+		concurrency:             cfg.Concurrency,
+		adoptBaseline:           cfg.AdoptBaseline,
+		compareAcrossCurrencies: cfg.CompareAcrossCurrencies,
+		outlierFactor:           cfg.OutlierFactor,
+		errorBudget:             cfg.ErrorBudget,
+		errorBudgetWindow:       cfg.ErrorBudgetWindow,
+		discontinueAfter:        cfg.DiscontinueAfter,
+		silenceWindow:           cfg.SilenceWindow,
+		scrapeQuota:             cfg.ScrapeQuota,
+		checkInterval:           cfg.CheckInterval,
+		backoffMax:              cfg.BackoffMax,
+		severity:                cfg.Severity,
+		dropAverage:             cfg.DropAverage,
+		worker:                  workerName(),
+		webhookClient:           &http.Client{},
+		email:                   cfg.Email,
+		realtime:                cfg.Realtime,
+		userSettings:            settings.NewLoader(db, settingsCacheTTL),
+		cookies:                 cfg.Cookies,
+		blobs:                   cfg.Blobs,
+	}
+	s.notifier = s.defaultNotifier(cfg.Notifiers)
+	return s
+}
+
+// CheckAllPrices runs a single pass of price checks for all tracked items,
+// after retrying webhook deliveries that failed on earlier passes, sending
+// those held back by quiet hours that have since ended and sending the
+// digests that are due. The items due are queued in scrape_jobs, and the
+// queue, checks users asked for first, is worked off until it is empty or
+// the context is cancelled. Unless it was cancelled, it then checks items'
+// error budgets and that drops are still being notified, and records a
+// heartbeat. Narrower runs (a user or an item) do neither; they queue only
+// their own items, as manual checks, and work off those jobs.
 func (s *Scheduler) CheckAllPrices(ctx context.Context) {
-	// Start Playwright if needed
+	s.retryWebhooks(ctx)
+	s.flushQueuedEmails(ctx)
+	s.sendDigests(ctx)
+	s.pauseStaleItems(ctx)
+	s.pruneJobs(ctx)
+	s.pruneScreenshots(ctx)
+	s.enqueueDue(ctx, scheduledCond(1), s.now().Add(-discontinuedRecheckInterval))
+	if _, err := s.runJobs(ctx, "all tracked items", nil); err != nil {
+		slog.Error("Failed to claim jobs", "error", err)
+	}
+	if ctx.Err() == nil {
+		s.checkErrorBudgets(ctx)
+		s.checkNotificationSilence(ctx)
+		s.recordHeartbeat(ctx)
+	}
+}
+
+// ScrapeDiff runs Scraper.ScrapeDiff with the scheduler's scraper, and so its
+// proxy, profile and blocked resources.
+func (s *Scheduler) ScrapeDiff(ctx context.Context, req ScrapeRequest) (ScrapeResult, ScrapeResult, error, error) {
+	return s.scraper.ScrapeDiff(ctx, req)
+}
+
+// CheckPricesForUser runs a single pass of price checks for one user's items
+// that are due.
+func (s *Scheduler) CheckPricesForUser(ctx context.Context, userID string) {
+	s.pauseStaleItems(ctx)
+	scope := "user " + userID
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM tracked_items WHERE user_id = $1 AND `+scheduledCond(2), userID, s.now().Add(-discontinuedRecheckInterval))
+	if err != nil {
+		slog.Error("Failed to find due items", "scope", scope, "error", err)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			slog.Error("Failed to find due items", "scope", scope, "error", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		slog.Error("Failed to find due items", "scope", scope, "error", err)
+		return
+	}
+	s.runQueued(ctx, scope, JobManual, ids)
+}
+
+// CheckItem runs a price check for a single tracked item, even if it is paused
+// or discontinued.
+func (s *Scheduler) CheckItem(ctx context.Context, itemID string) {
+	s.runQueued(ctx, "item "+itemID, JobManual, []string{itemID})
+}
+
+// pauseStaleItems pauses (but never deletes) items the user has not interacted
+// with for longer than maxItemAge, recording the reason on the item.
+func (s *Scheduler) pauseStaleItems(ctx context.Context) {
+	if s.maxItemAge <= 0 {
+		return
+	}
+
+	now := s.now()
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE tracked_items
+		SET paused_at = $1, pause_reason = 'max_age', updated_at = $1
+		WHERE paused_at IS NULL AND COALESCE(last_interacted_at, created_at) < $2
+	`, now, now.Add(-s.maxItemAge))
+	if err != nil {
+		slog.Error("Failed to pause stale items", "error", err)
+		return
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 {
+		slog.Info("Paused stale items", "count", n, "max_age", s.maxItemAge)
+	}
+}
+
+// priceRun is what one run shares across every batch of jobs it claims: one
+// start of the Playwright scraper, the seed its order is shuffled with (see
+// orderKey), and the memo through which each page is fetched once.
+type priceRun struct {
+	scope   string
+	seed    string
+	memo    *scrapeMemo
+	items   atomic.Int64
+	skipped atomic.Int64
+}
+
+// startRun starts the scraper and draws the seed for a run over scope. The
+// run must be ended with finishRun.
+func (s *Scheduler) startRun(ctx context.Context, scope string) *priceRun {
 	if err := s.scraper.Start(); err != nil {
 		slog.Warn("Failed to start Playwright scraper, will use HTTP only", "error", err)
 	}
-	defer s.scraper.Stop()
+	run := &priceRun{scope: scope, seed: runSeed(ctx), memo: newScrapeMemo()}
+	slog.Info("Starting price check", "scope", scope, "order_seed", run.seed)
+	return run
+}
+
+// finishRun stops the scraper started for run and logs its totals.
+func (s *Scheduler) finishRun(run *priceRun) {
+	s.scraper.Stop()
+	slog.Info("Completed price check", "scope", run.scope, "order_seed", run.seed, "items", run.items.Load(), "unique_pages", run.memo.size(), "skipped_out_of_time", run.skipped.Load())
+}
+
+// checkPrices processes every tracked item matching cond as part of run.
+// Items are fetched in keyset-paged batches, in the run's order, so that no
+// connection is held open for the whole run and memory stays bounded; each
+// batch is grouped by page and fed to a fixed pool of workers.
+//
+// Each item gets its own scrape budget. An item is only started while ctx's
+// deadline leaves room for a whole budget; the ones a run has no time left
+// for are reported as skipped (out_of_time) instead of failing, and are not
+// logged as scrapes.
+func (s *Scheduler) checkPrices(ctx context.Context, run *priceRun, cond string, args ...any) {
+	ctx, logs := withScrapeLogBatch(ctx)
+
+	groups := make(chan []Item)
+	var wg sync.WaitGroup
+	for i := 0; i < s.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range groups {
+				// Waiting for this worker may have used up the run's time.
+				if s.outOfTime(ctx) {
+					run.skipped.Add(int64(s.skipGroup(ctx, group)))
+					continue
+				}
+				s.processGroup(ctx, group, run.memo)
+			}
+		}()
+	}
+
+	lastID := ""
+	// Once the deadline passes, the remaining items are still paged through
+	// so that each is reported as skipped.
+	fetchCtx := ctx
+	for {
+		batch, err := s.fetchBatch(fetchCtx, cond, args, run.seed, lastID)
+		if err != nil {
+			slog.Error("Failed to fetch tracked items", "error", err)
+			break
+		}
+		run.items.Add(int64(len(batch)))
+
+		for _, group := range groupItems(batch) {
+			if s.outOfTime(ctx) {
+				run.skipped.Add(int64(s.skipGroup(ctx, group)))
+				continue
+			}
+			select {
+			case groups <- group:
+			case <-ctx.Done():
+				run.skipped.Add(int64(s.skipGroup(ctx, group)))
+			}
+		}
+
+		if len(batch) < s.batchSize || errors.Is(ctx.Err(), context.Canceled) {
+			break
+		}
+		if ctx.Err() != nil {
+			fetchCtx = context.WithoutCancel(ctx)
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+
+	close(groups)
+	wg.Wait()
+	// The run's scrapes happened even if it was cut short, so the rest of the
+	// log is written regardless.
+	s.flushScrapeLogs(context.WithoutCancel(ctx), logs.take())
+}
+
+// outOfTime reports whether ctx is done or its deadline leaves less than an
+// item's scrape budget.
+func (s *Scheduler) outOfTime(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && time.Until(deadline) < s.scraper.itemTimeout
+}
 
-	slog.Info("Starting price check for all tracked items...")
+// skipGroup reports the group's items as skipped for lack of time and
+// returns how many there were.
+func (s *Scheduler) skipGroup(ctx context.Context, group []Item) int {
+	for _, item := range group {
+		reportItemResult(ctx, scrapeLogEntry{
+			ItemID:        item.ID,
+			UserID:        item.UserID,
+			Domain:        DomainOf(item.PageURL),
+			Status:        "skipped",
+			FailureReason: "out_of_time",
+		})
+	}
+	return len(group)
+}
 
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, user_id, price_text, product_name, page_url, css_selector, xpath 
+// fetchBatch returns up to batchSize items matching cond that come after
+// afterID in the run order seed gives (see orderKey); an empty afterID starts
+// from the beginning. The rows are closed before it returns.
+func (s *Scheduler) fetchBatch(ctx context.Context, cond string, args []any, seed, afterID string) ([]Item, error) {
+	key := fmt.Sprintf(orderKeyExpr, len(args)+1)
+	where := fmt.Sprintf("%s > $%d", key, len(args)+2)
+	if cond != "" {
+		where = cond + " AND " + where
+	}
+	query := fmt.Sprintf(`
+		SELECT id, user_id, price_text, product_name, page_url, css_selector, xpath, frame_selector, frame_url, variant_selector, variant_value, adapter_order, min_expected, max_expected, parse_strategy, accept_language, country_code, cookies_encrypted, headers, proxy_url, scrape_profile, COALESCE(final_url, ''), not_found_count, max_scrape_seconds, failure_streak, selector_match, shipping_selector, total_price, notification_channel, last_notified_price, availability_selector, COALESCE(availability, ''),
+		%s
 		FROM tracked_items
-	`)
+		WHERE %s
+		ORDER BY %s
+		LIMIT %d`, variantsColumn, where, key, s.batchSize)
+
+	after := ""
+	if afterID != "" {
+		after = orderKey(seed, afterID)
+	}
+	rows, err := s.db.QueryContext(ctx, query, append(append([]any{}, args...), seed, after)...)
 	if err != nil {
-		slog.Error("Failed to fetch tracked items", "error", err)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
-	var wg sync.WaitGroup
-
+	batch := make([]Item, 0, s.batchSize)
 	for rows.Next() {
-		var id, userID, priceText, productName, pageURL, cssSelector, xpath string
-		if err := rows.Scan(&id, &userID, &priceText, &productName, &pageURL, &cssSelector, &xpath); err != nil {
+		var item Item
+		var cookies, headers, variants []byte
+		var proxyURL string
+		var maxSeconds sql.NullInt64
+		if err := rows.Scan(&item.ID, &item.UserID, &item.PriceText, &item.ProductName, &item.PageURL, &item.CSSSelector, &item.XPath, &item.Frame.Selector, &item.Frame.URL, &item.Selection.Selector, &item.Selection.Value, &item.AdapterOrder, &item.Bounds.min, &item.Bounds.max, &item.ParseStrategy, &item.AcceptLanguage, &item.CountryCode, &cookies, &headers, &proxyURL, &item.Profile, &item.FinalURL, &item.NotFoundCount, &maxSeconds, &item.FailureStreak, &item.SelectorMatch, &item.ShippingSelector, &item.TotalPrice, &item.NotificationChannel, &item.LastNotifiedPrice, &item.AvailabilitySelector, &item.Availability, &variants); err != nil {
 			slog.Error("Failed to scan item", "error", err)
 			continue
 		}
-
-		wg.Add(1)
-		go func(id, userID, priceText, productName, pageURL, cssSelector, xpath string) {
-			defer wg.Done()
-			s.processItem(ctx, id, userID, priceText, productName, pageURL, cssSelector, xpath)
-		}(id, userID, priceText, productName, pageURL, cssSelector, xpath)
+		item.MaxDuration = time.Duration(maxSeconds.Int64) * time.Second
+		if item.Cookies, err = s.openCookies(cookies); err != nil {
+			slog.Error("Failed to decrypt item cookies, scraping without them", "id", item.ID, "error", err)
+		}
+		if item.Headers, err = parseHeaders(headers); err != nil {
+			slog.Error("Failed to decode item headers, scraping without them", "id", item.ID, "error", err)
+		}
+		if item.Proxy, err = ParseProxyURL(proxyURL); err != nil {
+			slog.Error("Invalid item proxy, scraping without it", "id", item.ID, "error", err)
+		}
+		if item.Variants, err = parseVariants(variants); err != nil {
+			slog.Error("Failed to decode item variants", "id", item.ID, "error", err)
+		}
+		batch = append(batch, item)
 	}
-
-	wg.Wait()
-	slog.Info("Completed price check for all tracked items")
+	return batch, rows.Err()
 }
 
 // Stop cleans up resources (call this on application shutdown)
@@ -68,97 +642,335 @@ func (s *Scheduler) Stop() {
 	s.scraper.Stop()
 }
 
-func (s *Scheduler) processItem(ctx context.Context, id, userID, oldPriceText, productName, pageURL, cssSelector, xpathSelector string) {
-	newPriceText, err := s.scraper.ScrapePrice(pageURL, cssSelector, xpathSelector)
-	if err != nil {
-		slog.Error("Failed to scrape price", "id", id, "url", pageURL, "error", err)
-		if updateErr := s.updateTrackedItemStatus(id, "failed"); updateErr != nil {
-			slog.Error("Failed to update scrape status", "id", id, "error", updateErr)
+// priceBounds holds the optional per-item range a scraped price is expected to
+// fall within. A selector that accidentally matches a phone number or an order
+// count produces absurd prices, so values outside the range are not trusted.
+type priceBounds struct {
+	min sql.NullFloat64
+	max sql.NullFloat64
+}
+
+// contains reports whether price lies within the bounds. Unset bounds are open.
+func (b priceBounds) contains(price float64) bool {
+	if b.min.Valid && price < b.min.Float64 {
+		return false
+	}
+	if b.max.Valid && price > b.max.Float64 {
+		return false
+	}
+	return true
+}
+
+// scrapeMemo remembers the scrape result for each page signature during a
+// run, so that items sharing a page in different batches still cause only one
+// fetch.
+type scrapeMemo struct {
+	mu      sync.Mutex
+	results map[string]*memoizedScrape
+}
+
+type memoizedScrape struct {
+	once   sync.Once
+	result ScrapeResult
+	err    error
+}
+
+func newScrapeMemo() *scrapeMemo {
+	return &scrapeMemo{results: make(map[string]*memoizedScrape)}
+}
+
+func (m *scrapeMemo) get(signature string) *memoizedScrape {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.results[signature]
+	if !ok {
+		entry = &memoizedScrape{}
+		m.results[signature] = entry
+	}
+	return entry
+}
+
+func (m *scrapeMemo) size() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.results)
+}
+
+// processGroup scrapes the items on one page, once per distinct selector, and
+// applies each result to its items. History, comparisons and notifications
+// remain per item; a failed scrape is recorded as a failure for each of them.
+// An item whose price disagrees with the page's consensus is flagged instead,
+// and one whose owner used up their scrape quota is not checked at all.
+func (s *Scheduler) processGroup(ctx context.Context, group []Item, memo *scrapeMemo) {
+	group = s.withinScrapeQuota(ctx, group)
+	scrapes := make([]*memoizedScrape, len(group))
+	for i, item := range group {
+		entry := memo.get(item.scrapeSignature())
+		entry.once.Do(func() {
+			ctx, cancel := item.scrapeContext(ctx)
+			defer cancel()
+			entry.result, entry.err = s.scraper.ScrapeDetailed(ctx, item.scrapeRequest())
+		})
+		scrapes[i] = entry
+	}
+	outliers := priceOutliers(group, scrapes, s.outlierFactor)
+	for i, item := range group {
+		s.recordFinalURL(ctx, item, scrapes[i].result)
+		s.reviveItem(ctx, item, scrapes[i].result, scrapes[i].err)
+		if o, ok := outliers[i]; ok {
+			s.flagOutlier(ctx, item, scrapes[i].result, o)
+		} else {
+			s.applyScrapeResult(ctx, item, scrapes[i].result, scrapes[i].err)
 		}
-		return
+		s.processVariants(ctx, item, memo)
 	}
+}
 
-	// Compare prices
-	oldPrice, err := parsePrice(oldPriceText)
+func (s *Scheduler) processItem(ctx context.Context, item Item) {
+	s.processGroup(ctx, []Item{item}, newScrapeMemo())
+}
+
+func (s *Scheduler) applyScrapeResult(ctx context.Context, item Item, result ScrapeResult, err error) {
+	id, userID, oldPriceText, productName := item.ID, item.UserID, item.PriceText, item.ProductName
+	entry := newScrapeLogEntry(item, result)
 	if err != nil {
-		slog.Warn("Failed to parse old price", "price", oldPriceText, "error", err)
-		// We scraped successfully but parsing failed. Techincally a success for the scraper part, but maybe we should flag it?
-		// For now, let's mark scraper as success, as the network/selector part worked.
-		if updateErr := s.updateTrackedItemStatus(id, "success"); updateErr != nil {
-			slog.Error("Failed to update scrape status", "id", id, "error", updateErr)
+		slog.Error("Failed to scrape price", "id", id, "url", item.PageURL, "error", err)
+		if PageGone(err) && s.discontinueAfter > 0 {
+			s.recordPageGone(ctx, item, entry, err)
+			return
 		}
+		// A sold out product is not a broken scrape. Its price is kept until
+		// it is back.
+		if errors.Is(err, ErrOutOfStock) {
+			s.resetBackoff(ctx, item)
+			s.setScrapeStatus(ctx, entry, "out_of_stock", err)
+			s.recordAvailability(ctx, item, OutOfStock)
+			return
+		}
+		s.backOff(ctx, item, &entry)
+		s.setScrapeStatus(ctx, entry, "failed", err)
 		return
 	}
+	s.resetBackoff(ctx, item)
+	if item.AvailabilitySelector != "" || item.Availability == OutOfStock {
+		s.recordAvailability(ctx, item, InStock)
+	}
+	newPriceText := result.Text
 
-	newPrice, err := parsePrice(newPriceText)
+	// A price recovered from JSON-LD is used like any other, but the item is
+	// marked so the user knows to re-pick the price element.
+	okStatus := "success"
+	if result.SelectorBroken() {
+		okStatus = "selector_broken"
+	}
+
+	newPrice, err := ParsePriceWith(newPriceText, item.ParseStrategy)
 	if err != nil {
 		slog.Warn("Failed to parse new price", "price", newPriceText, "error", err)
-		if updateErr := s.updateTrackedItemStatus(id, "success"); updateErr != nil {
-			slog.Error("Failed to update scrape status", "id", id, "error", updateErr)
-		}
+		s.setScrapeStatus(ctx, entry, okStatus, nil)
 		return
 	}
+	newPriceNormalized := NormalizePrice(newPriceText, item.ParseStrategy, item.CountryCode)
 
-	// Update status to success
-	if updateErr := s.updateTrackedItemStatus(id, "success"); updateErr != nil {
-		slog.Error("Failed to update scrape status", "id", id, "error", updateErr)
+	if !item.Bounds.contains(newPrice) {
+		slog.Warn("Scraped price outside expected bounds", "id", id, "price", newPrice)
+		s.setScrapeStatus(ctx, entry, "suspicious", nil)
+		return
 	}
 
-	if newPrice < oldPrice {
-		slog.Info("Price drop detected!", "product", productName, "old", oldPrice, "new", newPrice)
+	// With a shipping cost, the price the user pays is the total.
+	shipping, hasShipping := item.shippingCost(result)
+	total := newPrice + shipping
+	if hasShipping {
+		if err := s.recordShipping(ctx, id, shipping, total); err != nil {
+			slog.Error("Failed to record shipping cost", "id", id, "error", err)
+		}
+	} else if item.ShippingSelector != "" {
+		slog.Warn("Failed to read shipping cost, comparing the price alone", "id", id, "shipping", result.Shipping)
+	}
 
-		if err := s.updateTrackedItemPrice(id, newPriceText); err != nil {
+	// Compare prices
+	oldPrice, err := ParsePriceWith(oldPriceText, item.ParseStrategy)
+	if err != nil {
+		// The scrape itself worked, so the status is still a success. Without a
+		// usable baseline the item could never be compared again, so the new
+		// price becomes the baseline unless that has been turned off.
+		s.setScrapeStatus(ctx, entry, okStatus, nil)
+		if !s.adoptBaseline {
+			slog.Warn("Failed to parse old price, skipping item", "id", id, "price", oldPriceText, "error", err)
+			return
+		}
+		slog.Info("Failed to parse old price, adopting new price as baseline", "id", id, "old", oldPriceText, "new", newPriceText)
+		if err := s.updateTrackedItemPrice(id, newPriceText, newPriceNormalized, newPrice); err != nil {
 			slog.Error("Failed to update tracked item price", "id", id, "error", err)
 		}
+		return
+	}
 
-		if err := s.sendNotification(userID, productName, oldPriceText, newPriceText, id); err != nil {
+	// Update status to success. The fix-me notification is only sent when the
+	// item first moves into selector_broken, not on every run.
+	if changed := s.setScrapeStatus(ctx, entry, okStatus, nil); changed && okStatus == "selector_broken" {
+		if err := s.sendSelectorBrokenNotification(userID, productName, id); err != nil {
 			slog.Error("Failed to send notification", "error", err)
 		}
-	} else if newPrice > oldPrice {
-		slog.Info("Price increase detected!", "product", productName, "old", oldPrice, "new", newPrice)
+	}
+
+	// A price in another currency cannot be compared with the old one: the
+	// store switched the user to another region's prices, which is no drop
+	// however the numbers compare. It becomes the new baseline instead.
+	if from, to, changed := currencyChange(oldPriceText, newPriceText, item.CountryCode); changed && !s.compareAcrossCurrencies {
+		slog.Warn("Price currency changed, not comparing", "product", productName, "from", from, "to", to, "old", oldPriceText, "new", newPriceText)
+
+		if err := s.updateTrackedItemPrice(id, newPriceText, newPriceNormalized, newPrice); err != nil {
+			slog.Error("Failed to update tracked item price", "id", id, "error", err)
+		}
+		s.clearNotifiedPrice(ctx, item)
+		if err := s.sendCurrencyChangedNotification(ctx, item, oldPriceText, newPriceText, from, to); err != nil {
+			slog.Error("Failed to send notification", "error", err)
+		}
+		return
+	}
+
+	// Once a total has been recorded, totals are compared, so a cheaper
+	// price with dearer shipping is no drop.
+	compareOld, compareNew := oldPrice, newPrice
+	oldText, newText := oldPriceText, newPriceText
+	if hasShipping && item.TotalPrice.Valid {
+		compareOld, compareNew = item.TotalPrice.Float64, total
+		oldText, newText = totalText(compareOld, oldPriceText, item.CountryCode), totalText(compareNew, newPriceText, item.CountryCode)
+	}
+	// A drop must go below the last price notified too, so that one is not
+	// announced again after the price bounced back, and by at least the
+	// user's drop threshold. The price must also be well below its recent
+	// average, when that is configured. The history is read before the new
+	// price joins it, and holds prices without shipping.
+	baseline := item.dropBaseline(compareOld)
+	dropped := compareNew < baseline && clearsThreshold(baseline, compareNew, s.dropThreshold(ctx, userID)) && s.belowRecentAverage(ctx, id, newPrice)
 
-		if err := s.updateTrackedItemPrice(id, newPriceText); err != nil {
+	if newPrice != oldPrice {
+		if err := s.updateTrackedItemPrice(id, newPriceText, newPriceNormalized, newPrice); err != nil {
 			slog.Error("Failed to update tracked item price", "id", id, "error", err)
 		}
+	} else if err := s.recordObservedPrice(ctx, id, newPrice); err != nil {
+		slog.Error("Failed to record observed price", "id", id, "error", err)
+	}
+
+	if dropped {
+		slog.Info("Price drop detected!", "product", productName, "old", compareOld, "new", compareNew)
+
+		n := Notification{UserID: userID, ItemID: id, ProductName: productName, OldPrice: oldText, NewPrice: newText, OccurredAt: s.now(), Severity: s.severity.dropSeverity(compareOld, compareNew), DropPercent: dropPercent(compareOld, compareNew), Channel: item.NotificationChannel}
+		if err := s.notifier.Notify(ctx, n); err != nil {
+			slog.Error("Failed to send notification", "error", err)
+		} else {
+			s.recordNotifiedPrice(ctx, id, compareNew)
+		}
+	} else if compareNew < compareOld {
+		slog.Info("Price drop not notified", "product", productName, "old", compareOld, "new", compareNew)
+	} else if compareNew > compareOld {
+		slog.Info("Price increase detected!", "product", productName, "old", compareOld, "new", compareNew)
 	} else {
-		slog.Info("No price drop", "product", productName, "old", oldPrice, "new", newPrice)
+		slog.Info("No price drop", "product", productName, "old", compareOld, "new", compareNew)
 	}
 }
 
-func (s *Scheduler) sendNotification(userID, productName, oldPrice, newPrice, productID string) error {
-	title := "Price Drop Alert!"
-	message := fmt.Sprintf("Good news! The price for '%s' dropped from %s to %s.", productName, oldPrice, newPrice)
+func (s *Scheduler) sendSelectorBrokenNotification(userID, productName, productID string) error {
+	title := "Price Selector Needs Updating"
+	message := fmt.Sprintf("We couldn't find the price element you picked for '%s', so we're using the price the store publishes instead. Re-pick the price to keep tracking it precisely.", productName)
 
 	_, err := s.db.Exec(`
-		INSERT INTO notifications (user_id, title, message, type, product_id, old_price, new_price, is_read)
-		VALUES ($1, $2, $3, 'price_drop', $4, $5, $6, false)
-	`, userID, title, message, productID, oldPrice, newPrice)
+		INSERT INTO notifications (user_id, title, message, type, product_id, is_read)
+		VALUES ($1, $2, $3, 'selector_broken', $4, false)
+	`, userID, title, message, productID)
 
 	return err
 }
 
-func (s *Scheduler) updateTrackedItemPrice(itemID, newPrice string) error {
+// updateTrackedItemPrice stores a changed price, as scraped, normalized (see
+// NormalizePrice) and as the numeric last_price the item list filters and
+// sorts on, and restarts the clock on how long the price has held. Items
+// whose captured price could not be parsed when saved adopt this one as
+// their captured price. The new price is appended to the item's price
+// history in the same statement.
+func (s *Scheduler) updateTrackedItemPrice(itemID, newPriceText, newPriceNormalized string, newPrice float64) error {
 	_, err := s.db.Exec(`
-		UPDATE tracked_items 
-		SET price_text = $1 
-		WHERE id = $2
-	`, newPrice, itemID)
+		WITH updated AS (
+			UPDATE tracked_items
+			SET price_text = $1, price_normalized = NULLIF($2, ''), last_price = $3, captured_price = COALESCE(captured_price, $3),
+				price_first_seen_at = NOW(), price_last_changed_at = NOW(), updated_at = NOW()
+			WHERE id = $4
+			RETURNING id, last_price
+		)
+		INSERT INTO item_price_history (item_id, price)
+		SELECT id, last_price FROM updated
+	`, newPriceText, newPriceNormalized, newPrice, itemID)
 
 	return err
 }
 
-func (s *Scheduler) updateTrackedItemStatus(itemID, status string) error {
-	_, err := s.db.Exec(`
-		UPDATE tracked_items 
-		SET last_scrape_status = $1 
-		WHERE id = $2
-	`, status, itemID)
+// recordObservedPrice sets last_price when an unchanged price is observed. It
+// only writes when last_price differs, which in practice is the item's first
+// successful scrape; that first price also starts the item's price history.
+func (s *Scheduler) recordObservedPrice(ctx context.Context, itemID string, price float64) error {
+	_, err := s.db.ExecContext(ctx, `
+		WITH updated AS (
+			UPDATE tracked_items
+			SET last_price = $1, captured_price = COALESCE(captured_price, $1), updated_at = NOW()
+			WHERE id = $2 AND last_price IS DISTINCT FROM $1
+			RETURNING id, last_price
+		)
+		INSERT INTO item_price_history (item_id, price)
+		SELECT id, last_price FROM updated
+	`, price, itemID)
 	return err
 }
 
-func parsePrice(priceStr string) (float64, error) {
-	re := regexp.MustCompile(`[^\d\.]`)
-	cleaned := re.ReplaceAllString(priceStr, "")
+// setScrapeStatus stores the outcome of a scrape on the item, appends it to
+// the scrape log and reports it to WithItemResults. Failures are logged
+// rather than returned since the caller has nothing better to do with them.
+// It reports whether the item's status changed.
+func (s *Scheduler) setScrapeStatus(ctx context.Context, entry scrapeLogEntry, status string, scrapeErr error) bool {
+	itemStatus := status
+	if status == "outlier" {
+		// The selector most likely picks the wrong element, so the item is
+		// flagged like any other broken selector; only the log tells why.
+		itemStatus = "selector_broken"
+	}
+	changed, err := s.updateTrackedItemStatus(entry.ItemID, itemStatus)
+	if err != nil {
+		slog.Error("Failed to update scrape status", "id", entry.ItemID, "error", err)
+	}
+
+	entry.Status = status
+	switch {
+	case scrapeErr != nil:
+		entry.FailureReason = classifyScrapeError(scrapeErr)
+		entry.Error = scrapeErr.Error()
+		s.storeScreenshot(ctx, entry, scrapeErr)
+	case status == "suspicious":
+		entry.FailureReason = "out_of_bounds"
+	case status == "selector_broken":
+		entry.FailureReason = "selector_not_found"
+	case status == "outlier":
+		entry.FailureReason = "price_outlier"
+	}
+	if err := s.recordScrapeLog(ctx, entry); err != nil {
+		slog.Error("Failed to record scrape log", "id", entry.ItemID, "error", err)
+	}
+	reportItemResult(ctx, entry)
+	return changed
+}
 
-	return strconv.ParseFloat(cleaned, 64)
+// updateTrackedItemStatus sets the item's last scrape status and reports
+// whether it differed from the previous one.
+func (s *Scheduler) updateTrackedItemStatus(itemID, status string) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE tracked_items 
+		SET last_scrape_status = $1, updated_at = NOW()
+		WHERE id = $2 AND last_scrape_status IS DISTINCT FROM $1
+	`, status, itemID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
 }
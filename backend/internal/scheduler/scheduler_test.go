@@ -1,9 +1,19 @@
 package scheduler
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
 )
 
 func TestScrapePrice_CSS(t *testing.T) {
@@ -14,7 +24,7 @@ func TestScrapePrice_CSS(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	scraper := NewScraper()
+	scraper := localScraper()
 	price, err := scraper.ScrapePrice(ts.URL, ".price", "")
 	if err != nil {
 		t.Fatalf("ScrapePrice failed: %v", err)
@@ -33,7 +43,7 @@ func TestScrapePrice_XPath(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	scraper := NewScraper()
+	scraper := localScraper()
 	price, err := scraper.ScrapePrice(ts.URL, "", "//div[@id='p']")
 	if err != nil {
 		t.Fatalf("ScrapePrice failed: %v", err)
@@ -66,3 +76,811 @@ func TestParsePrice(t *testing.T) {
 		}
 	}
 }
+
+func TestPriceBounds_Contains(t *testing.T) {
+	bounded := priceBounds{
+		min: sql.NullFloat64{Float64: 10, Valid: true},
+		max: sql.NullFloat64{Float64: 500, Valid: true},
+	}
+
+	tests := []struct {
+		name   string
+		bounds priceBounds
+		price  float64
+		want   bool
+	}{
+		{"inside", bounded, 19.99, true},
+		{"at min", bounded, 10, true},
+		{"at max", bounded, 500, true},
+		{"below min", bounded, 9.99, false},
+		{"above max (phone number)", bounded, 8005551234, false},
+		{"unbounded", priceBounds{}, 8005551234, true},
+		{"min only", priceBounds{min: sql.NullFloat64{Float64: 10, Valid: true}}, 1, false},
+	}
+
+	for _, test := range tests {
+		if got := test.bounds.contains(test.price); got != test.want {
+			t.Errorf("%s: contains(%v) = %v, expected %v", test.name, test.price, got, test.want)
+		}
+	}
+}
+
+func TestProcessItem_OutsideBoundsIsSuspicious(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">1-800-555-1234</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("suspicious", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WithArgs("item-1", "user-1", "127.0.0.1", "suspicious", "out_of_bounds", "", sqlmock.AnyArg(), false, nil, "en-US", "", ts.URL, "", "selector", int64(0)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	s := New(db, localConfig())
+	s.processItem(context.Background(), Item{
+		ID:          "item-1",
+		UserID:      "user-1",
+		PriceText:   "$19.99",
+		ProductName: "Widget",
+		PageURL:     ts.URL,
+		CSSSelector: ".price",
+		Bounds:      priceBounds{max: sql.NullFloat64{Float64: 1000, Valid: true}},
+	})
+
+	// No price update or notification insert is expected.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestProcessItem_InsideBoundsNotifies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$15.00</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectDefaultSettings(mock, "user-1")
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("$15.00", "15.00 USD", 15.0, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO notifications").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectNoWebhook(mock, "user-1")
+	expectNotifiedPrice(mock, "item-1", 15.0)
+
+	s := New(db, localConfig())
+	s.processItem(context.Background(), Item{
+		ID:          "item-1",
+		UserID:      "user-1",
+		PriceText:   "$19.99",
+		ProductName: "Widget",
+		PageURL:     ts.URL,
+		CSSSelector: ".price",
+		Bounds: priceBounds{
+			min: sql.NullFloat64{Float64: 5, Valid: true},
+			max: sql.NullFloat64{Float64: 1000, Valid: true},
+		},
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestProcessItem_PriceTimestamps(t *testing.T) {
+	tests := []struct {
+		name    string
+		scraped string
+		expect  func(mock sqlmock.Sqlmock)
+	}{
+		{"changed", "$24.99", func(mock sqlmock.Sqlmock) {
+			mock.ExpectExec(`SET price_text = \$1, .*price_first_seen_at = NOW\(\), price_last_changed_at = NOW\(\)`).
+				WithArgs("$24.99", "24.99 USD", 24.99, "item-1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}},
+		{"unchanged", "$19.99", func(mock sqlmock.Sqlmock) {
+			// The SET list must not touch the price timestamps.
+			mock.ExpectExec(`SET last_price = \$1, captured_price = COALESCE\(captured_price, \$1\), updated_at = NOW\(\)\s+WHERE`).
+				WithArgs(19.99, "item-1").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte(`<html><body><div class="price">` + test.scraped + `</div></body></html>`))
+			}))
+			defer ts.Close()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectExec("UPDATE tracked_items").
+				WithArgs("success", "item-1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("INSERT INTO scrape_log").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			test.expect(mock)
+
+			s := New(db, localConfig())
+			s.processItem(context.Background(), Item{
+				ID:          "item-1",
+				UserID:      "user-1",
+				PriceText:   "$19.99",
+				ProductName: "Widget",
+				PageURL:     ts.URL,
+				CSSSelector: ".price",
+			})
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessItem_BrokenSelectorRecoversFromJSONLD(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><script type="application/ld+json">
+			{"@context":"https://schema.org","@type":"Product","name":"Widget","offers":{"@type":"Offer","price":"15.00","priceCurrency":"USD"}}
+		</script></head><body><div class="new-price-markup">$15.00</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	item := Item{
+		ID:          "item-1",
+		UserID:      "user-1",
+		PriceText:   "$19.99",
+		ProductName: "Widget",
+		PageURL:     ts.URL,
+		CSSSelector: ".price",
+	}
+
+	t.Run("first run notifies", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("selector_broken", "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO scrape_log").
+			WithArgs("item-1", "user-1", "127.0.0.1", "selector_broken", "selector_not_found", "", sqlmock.AnyArg(), false, nil, "en-US", "", ts.URL, "", "jsonld", int64(0)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO notifications .* 'selector_broken'").
+			WithArgs("user-1", sqlmock.AnyArg(), sqlmock.AnyArg(), "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectDefaultSettings(mock, "user-1")
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("USD 15.00", "15.00 USD", 15.0, "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO notifications .* 'price_drop'").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectNoWebhook(mock, "user-1")
+		expectNotifiedPrice(mock, "item-1", 15.0)
+
+		New(db, noBrowserConfig()).processItem(context.Background(), item)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("already flagged does not notify again", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		item := item
+		item.PriceText = "USD 15.00"
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("selector_broken", "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO scrape_log").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET last_price").
+			WithArgs(15.0, "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		New(db, noBrowserConfig()).processItem(context.Background(), item)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("fallback disabled fails", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("failed", "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO scrape_log").
+			WithArgs("item-1", "user-1", "127.0.0.1", "failed", "selector_not_found", sqlmock.AnyArg(), sqlmock.AnyArg(), false, nil, "en-US", "", ts.URL, "", "", int64(0)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		cfg := noBrowserConfig()
+		cfg.JSONLDFallbackDisabled = true
+		New(db, cfg).processItem(context.Background(), item)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestProcessItem_UnparseableOldPrice(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$15.00</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	item := Item{
+		ID:          "item-1",
+		UserID:      "user-1",
+		PriceText:   "See price in cart",
+		ProductName: "Widget",
+		PageURL:     ts.URL,
+		CSSSelector: ".price",
+	}
+
+	t.Run("adopts new baseline", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("success", "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO scrape_log").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE tracked_items\\s+SET price_text(?s:.*)INSERT INTO item_price_history").
+			WithArgs("$15.00", "15.00 USD", 15.0, "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		New(db, localConfig()).processItem(context.Background(), item)
+
+		// No notification: there is nothing to compare against yet.
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("skip leaves the item alone", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("success", "item-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO scrape_log").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		cfg := localConfig()
+		cfg.AdoptBaseline = false
+		New(db, cfg).processItem(context.Background(), item)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestProcessItem_Variants(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>
+			<div class="price">$30.00</div>
+			<div class="price-large">$40.00</div>
+		</body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	small, large := "$30.00", "$45.00"
+	item := Item{
+		ID:          "item-1",
+		UserID:      "user-1",
+		PriceText:   "$30.00",
+		ProductName: "T-Shirt",
+		PageURL:     ts.URL,
+		CSSSelector: ".price",
+		Variants: []Variant{
+			{ID: 1, Label: "Small", CSSSelector: ".price", PriceText: &small},
+			{ID: 2, Label: "Large", CSSSelector: ".price-large", PriceText: &large},
+		},
+	}
+
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("SET last_price").
+		WithArgs(30.0, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// Small is unchanged; Large dropped from $45.00 to $40.00.
+	mock.ExpectExec("UPDATE item_variants\\s+SET last_scrape_status").
+		WithArgs("success", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE item_variants\\s+SET price_text").
+		WithArgs("$40.00", int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO variant_price_history").
+		WithArgs(int64(2), "$40.00").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-1", sqlmock.AnyArg(), "Good news! The price for 'T-Shirt (Large)' dropped from $45.00 to $40.00.", "item-1", "$45.00", "$40.00", "notice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectDefaultSettings(mock, "user-1")
+	expectNoWebhook(mock, "user-1")
+
+	New(db, localConfig()).processItem(context.Background(), item)
+
+	// The Small variant shares the item's selector, so it reuses its scrape.
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Expected 2 fetches, got %d", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestParseVariants(t *testing.T) {
+	variants, err := parseVariants(nil)
+	if err != nil || variants != nil {
+		t.Errorf("Expected no variants for NULL, got %v, %v", variants, err)
+	}
+
+	variants, err = parseVariants([]byte(`[{"id":3,"label":"Blue","css":".blue","xpath":"","price":null}]`))
+	if err != nil {
+		t.Fatalf("Failed to parse variants: %v", err)
+	}
+	if len(variants) != 1 || variants[0].ID != 3 || variants[0].Label != "Blue" || variants[0].CSSSelector != ".blue" || variants[0].PriceText != nil {
+		t.Errorf("Unexpected variants: %+v", variants)
+	}
+}
+
+func TestDomainOf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://www.amazon.com/dp/B0BD7Z94ZQ", "amazon.com"},
+		{"https://WWW.BestBuy.com/site/123.p", "bestbuy.com"},
+		{"http://127.0.0.1:8080/item", "127.0.0.1"},
+		{"https://shop.example.co.uk/p?id=1", "shop.example.co.uk"},
+		{"not a url", "unknown"},
+	}
+
+	for _, test := range tests {
+		if got := DomainOf(test.input); got != test.expected {
+			t.Errorf("DomainOf(%q) = %q, expected %q", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestClassifyScrapeError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{nil, ""},
+		{errors.New("bad status code: 403"), "blocked"},
+		{errors.New("bad status code: 429"), "blocked"},
+		{errors.New("bad status code: 500"), "bad_status"},
+		{&StatusError{Code: 404}, "not_found"},
+		{&StatusError{Code: 410}, "not_found"},
+		{&StatusError{Code: 403}, "blocked"},
+		{errors.New("element not found with css selector (Playwright): .price"), "selector_not_found"},
+		{fmt.Errorf("fetch: %w", context.DeadlineExceeded), "timeout"},
+		{fmt.Errorf("%w: https://other.example/p", ErrOffDomainRedirect), "redirect_off_domain"},
+		{fmt.Errorf("%w: element not found with css selector (Playwright): .price", ErrScraperStopping), "scraper_stopping"},
+		{errors.New("something odd"), "other"},
+	}
+
+	for _, test := range tests {
+		if got := classifyScrapeError(test.err); got != test.expected {
+			t.Errorf("classifyScrapeError(%v) = %q, expected %q", test.err, got, test.expected)
+		}
+	}
+}
+
+func TestCheckPrices_ScopedRunsAreQueued(t *testing.T) {
+	tests := []struct {
+		name string
+		// due is the query for the items due, if the run has one.
+		due    string
+		dueIDs []string
+		run    func(s *Scheduler, ctx context.Context)
+		queued string
+	}{
+		// Discontinued items are left for their daily recheck.
+		{"user", `SELECT id FROM tracked_items WHERE user_id = \$1 AND paused_at IS NULL AND \(next_check_at IS NULL OR next_check_at <= NOW\(\)\) AND \(discontinued_at IS NULL OR NOT EXISTS \(.*created_at >= \$2\s+\)\)`, []string{"item-1", "item-2"},
+			func(s *Scheduler, ctx context.Context) { s.CheckPricesForUser(ctx, "user-1") }, `{"item-1","item-2"}`},
+		{"item", "", nil, func(s *Scheduler, ctx context.Context) { s.CheckItem(ctx, "item-1") }, `{"item-1"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+			s := New(db, noBrowserConfig())
+			s.now = func() time.Time { return now }
+			s.worker = "worker-1"
+
+			if test.due != "" {
+				rows := sqlmock.NewRows([]string{"id"})
+				for _, id := range test.dueIDs {
+					rows.AddRow(id)
+				}
+				mock.ExpectQuery(test.due).WithArgs("user-1", now.Add(-discontinuedRecheckInterval)).WillReturnRows(rows)
+			}
+			// The items are queued as manual checks, ahead of scheduled ones,
+			// and only their jobs are claimed.
+			mock.ExpectQuery("INSERT INTO scrape_jobs").
+				WithArgs(test.queued, JobManual, JobManual.priority(), now).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(5)))
+			mock.ExpectQuery(claimQuery+`.*AND id = ANY\(\$5\)`).
+				WithArgs("worker-1", now, now.Add(-jobClaimTTL), sqlmock.AnyArg(), "{5}").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}))
+
+			test.run(s, context.Background())
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckPricesForUser_NothingDue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// Nothing is queued when none of the user's items is due.
+	mock.ExpectQuery("SELECT id FROM tracked_items WHERE user_id").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	New(db, noBrowserConfig()).CheckPricesForUser(context.Background(), "user-1")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCheckAllPrices_DeduplicatesSharedURLs(t *testing.T) {
+
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$19.99</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+	expectNoPendingWebhooks(mock)
+	expectQueuedRun(mock, "item-1", "item-2", "item-3")
+
+	columns := []string{"id", "user_id", "price_text", "product_name", "page_url", "css_selector", "xpath", "frame_selector", "frame_url", "variant_selector", "variant_value", "adapter_order", "min_expected", "max_expected", "parse_strategy", "accept_language", "country_code", "cookies_encrypted", "headers", "proxy_url", "scrape_profile", "final_url", "not_found_count", "max_scrape_seconds", "failure_streak", "selector_match", "shipping_selector", "total_price", "notification_channel", "last_notified_price", "availability_selector", "availability", "variants"}
+	mock.ExpectQuery("FROM tracked_items").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("item-1", "user-1", "$19.99", "Switch", ts.URL+"/switch", ".price", "", "", "", "", "", "first", nil, nil, "auto", "en-US", "", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil).
+		AddRow("item-2", "user-2", "$19.99", "Switch", ts.URL+"/switch?utm_source=newsletter", ".price", "", "", "", "", "", "first", nil, nil, "auto", "en-US", "", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil).
+		AddRow("item-3", "user-3", "$19.99", "Switch", ts.URL+"/switch#reviews", ".price", "", "", "", "", "", "first", nil, nil, "auto", "en-US", "", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil))
+	for _, id := range []string{"item-1", "item-2", "item-3"} {
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("success", id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("SET last_price").
+			WithArgs(19.99, id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	expectScrapeLogBatch(mock, 3).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("INSERT INTO scheduler_heartbeat").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	New(db, noBrowserConfig()).CheckAllPrices(context.Background())
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected exactly 1 fetch for 3 items sharing a URL, got %d", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCheckPrices_SkipsItemsOutOfTime(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	columns := []string{"id", "user_id", "price_text", "product_name", "page_url", "css_selector", "xpath", "frame_selector", "frame_url", "variant_selector", "variant_value", "adapter_order", "min_expected", "max_expected", "parse_strategy", "accept_language", "country_code", "cookies_encrypted", "headers", "proxy_url", "scrape_profile", "final_url", "not_found_count", "max_scrape_seconds", "failure_streak", "selector_match", "shipping_selector", "total_price", "notification_channel", "last_notified_price", "availability_selector", "availability", "variants"}
+	rows := sqlmock.NewRows(columns)
+	for _, id := range []string{"item-1", "item-2", "item-3"} {
+		rows.AddRow(id, "user-1", "$19.99", "Switch", ts.URL+"/"+id, ".price", "", "", "", "", "", "first", nil, nil, "auto", "en-US", "", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil)
+	}
+	mock.ExpectQuery("FROM tracked_items").WillReturnRows(rows)
+	// Only the item that was started times out and is logged.
+	mock.ExpectExec("UPDATE tracked_items").WithArgs("failed", "item-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectScrapeLogBatch(mock, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	cfg := noBrowserConfig()
+	cfg.Concurrency = 1
+	cfg.ItemTimeout = 200 * time.Millisecond
+	cfg.ItemHTTPTimeout = 200 * time.Millisecond
+	s := New(db, cfg)
+
+	var mu sync.Mutex
+	results := map[string]ItemResult{}
+	ctx := WithItemResults(context.Background(), func(result ItemResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.ItemID] = result
+	})
+	// Enough time to start one item, but not a second once it times out.
+	ctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	run := s.startRun(ctx, "test")
+	s.checkPrices(ctx, run, "")
+	s.finishRun(run)
+
+	if r := results["item-1"]; r.Status != "failed" || r.FailureReason != "timeout" {
+		t.Errorf("Expected item-1 to time out, got %+v", r)
+	}
+	for _, id := range []string{"item-2", "item-3"} {
+		if r := results[id]; r.Status != "skipped" || r.FailureReason != "out_of_time" {
+			t.Errorf("Expected %s to be skipped for lack of time, got %+v", id, r)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestScrapeDetailed_ItemTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	s := httpScraper(true)
+	s.itemTimeout = 100 * time.Millisecond
+	start := time.Now()
+	_, err := s.ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price", AcceptLanguage: DefaultAcceptLanguage})
+	if classifyScrapeError(err) != "timeout" {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the item timeout to cut the scrape short, took %v", elapsed)
+	}
+}
+
+func TestProcessItem_MaxDuration(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		w.Write([]byte(`<html><body><div class="price">$19.99</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExec("UPDATE tracked_items").WithArgs("failed", "item-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	var results []ItemResult
+	ctx := WithItemResults(context.Background(), func(result ItemResult) { results = append(results, result) })
+	start := time.Now()
+	New(db, noBrowserConfig()).processItem(ctx, Item{ID: "item-1", UserID: "user-1", PriceText: "$19.99", PageURL: ts.URL, CSSSelector: ".price", MaxDuration: 100 * time.Millisecond})
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the item's limit to cut the scrape short, took %v", elapsed)
+	}
+	if len(results) != 1 || results[0].Status != "failed" || results[0].FailureReason != "timeout" {
+		t.Errorf("Expected the item to fail with a timeout, got %+v", results)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestGroupItems(t *testing.T) {
+	items := []Item{
+		{ID: "a", PageURL: "https://shop.example.com/p", CSSSelector: ".price"},
+		{ID: "b", PageURL: "https://shop.example.com/p", CSSSelector: ".sale-price"},
+		{ID: "c", PageURL: "https://SHOP.example.com/p/", CSSSelector: ".price"},
+		{ID: "d", PageURL: "https://shop.example.com/q", CSSSelector: ".price"},
+	}
+
+	groups := groupItems(items)
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(groups))
+	}
+	if len(groups[0]) != 3 || groups[0][0].ID != "a" || groups[0][1].ID != "b" || groups[0][2].ID != "c" {
+		t.Errorf("Expected a, b and c to share a page group, got %+v", groups[0])
+	}
+	if groups[0][0].scrapeSignature() != groups[0][2].scrapeSignature() || groups[0][0].scrapeSignature() == groups[0][1].scrapeSignature() {
+		t.Error("Expected only a and c to share a scrape")
+	}
+}
+
+func TestPauseStaleItems_FixedClock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New(db, localConfig())
+	s.now = func() time.Time { return now }
+	s.maxItemAge = 90 * 24 * time.Hour
+
+	mock.ExpectExec(`UPDATE tracked_items\s+SET paused_at = \$1, pause_reason = 'max_age'`).
+		WithArgs(now, time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s.pauseStaleItems(context.Background())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestPauseStaleItems_DisabledByDefault(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	New(db, localConfig()).pauseStaleItems(context.Background())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expected no queries, got: %v", err)
+	}
+}
+
+func TestCheckPrices_KeysetBatches(t *testing.T) {
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$19.99</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	columns := []string{"id", "user_id", "price_text", "product_name", "page_url", "css_selector", "xpath", "frame_selector", "frame_url", "variant_selector", "variant_value", "adapter_order", "min_expected", "max_expected", "parse_strategy", "accept_language", "country_code", "cookies_encrypted", "headers", "proxy_url", "scrape_profile", "final_url", "not_found_count", "max_scrape_seconds", "failure_streak", "selector_match", "shipping_selector", "total_price", "notification_channel", "last_notified_price", "availability_selector", "availability", "variants"}
+	row := func(id string) []driver.Value {
+		return []driver.Value{id, "user-1", "$19.99", "Item " + id, ts.URL + "/" + id, ".price", "", "", "", "", "", "first", nil, nil, "auto", "en-US", "", nil, nil, "", "", "", 0, nil, 0, "", "", nil, "", nil, "", "", nil}
+	}
+
+	// Five items in pages of two: the last page is short, which ends the run.
+	// Each page starts after the previous one's last item in the run's order.
+	mock.ExpectQuery(`ORDER BY md5\(\$2 \|\| id\) \|\| id\s+LIMIT 2`).WithArgs(sqlmock.AnyArg(), "run-1", "").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(row("item-1")...).AddRow(row("item-2")...))
+	mock.ExpectQuery(`ORDER BY md5\(\$2 \|\| id\) \|\| id\s+LIMIT 2`).WithArgs(sqlmock.AnyArg(), "run-1", orderKey("run-1", "item-2")).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(row("item-3")...).AddRow(row("item-4")...))
+	mock.ExpectQuery(`ORDER BY md5\(\$2 \|\| id\) \|\| id\s+LIMIT 2`).WithArgs(sqlmock.AnyArg(), "run-1", orderKey("run-1", "item-4")).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(row("item-5")...))
+	for i := 1; i <= 5; i++ {
+		id := fmt.Sprintf("item-%d", i)
+		mock.ExpectExec("UPDATE tracked_items").
+			WithArgs("success", id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("SET last_price").
+			WithArgs(19.99, id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	expectScrapeLogBatch(mock, 5).WillReturnResult(sqlmock.NewResult(0, 5))
+
+	s := New(db, noBrowserConfig())
+	s.batchSize = 2
+	s.concurrency = 3
+	ctx := WithOrderSeed(context.Background(), "run-1")
+	run := s.startRun(ctx, "all tracked items")
+	s.checkPrices(ctx, run, scheduledCond(1), s.now().Add(-discontinuedRecheckInterval))
+	s.finishRun(run)
+
+	if len(hits) != 5 {
+		t.Errorf("Expected 5 distinct pages fetched, got %d", len(hits))
+	}
+	for path, n := range hits {
+		if n != 1 {
+			t.Errorf("Expected %s to be fetched exactly once, got %d", path, n)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
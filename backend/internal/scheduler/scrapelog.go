@@ -0,0 +1,250 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// scrapeLogBatchSize bounds the rows written by one batched INSERT. Each row
+// takes sixteen parameters and Postgres allows 65535 per statement.
+const scrapeLogBatchSize = 500
+
+// scrapeLogEntry is a single row in the scrape_log table. One entry is written
+// for every item processed by the scheduler, successful or not.
+type scrapeLogEntry struct {
+	ItemID         string
+	UserID         string
+	Domain         string
+	Status         string
+	FailureReason  string
+	Error          string
+	DurationMs     int64
+	UsedPlaywright bool
+	// HeaderNames lists the custom headers sent with the request. Their
+	// values are never logged.
+	HeaderNames []string
+	// Locale and CountryCode are the language and country the page was
+	// requested for.
+	Locale      string
+	CountryCode string
+	// FinalURL is where the page ended up after redirects.
+	FinalURL string
+	// Variant is the label of the variant selected before the price was read.
+	Variant string
+	// PriceSource is where the price was read (see ScrapeResult.Source).
+	PriceSource string
+	// BackoffSeconds is how long the failure put the item's next check off
+	// (see backOff).
+	BackoffSeconds int64
+	// CreatedAt is set when the entry is batched, so that a row written at the
+	// end of a run still carries the time of its scrape.
+	CreatedAt time.Time
+}
+
+// newScrapeLogEntry starts the log entry for scraping item; the caller fills
+// in the outcome.
+func newScrapeLogEntry(item Item, result ScrapeResult) scrapeLogEntry {
+	return scrapeLogEntry{
+		ItemID:         item.ID,
+		UserID:         item.UserID,
+		Domain:         DomainOf(item.PageURL),
+		DurationMs:     result.Duration.Milliseconds(),
+		UsedPlaywright: result.UsedPlaywright(),
+		HeaderNames:    headerNames(item.Headers),
+		Locale:         localeOf(item),
+		CountryCode:    item.CountryCode,
+		FinalURL:       result.FinalURL,
+		Variant:        result.Variant,
+		PriceSource:    result.Source,
+	}
+}
+
+// localeOf is the locale item's page is requested in.
+func localeOf(item Item) string {
+	if item.AcceptLanguage == "" {
+		return DefaultAcceptLanguage
+	}
+	return item.AcceptLanguage
+}
+
+// scrapeLogBatch collects the scrape log entries of one run so that they are
+// written with a few multi-row INSERTs instead of one per item.
+type scrapeLogBatch struct {
+	mu      sync.Mutex
+	entries []scrapeLogEntry
+}
+
+type scrapeLogBatchKey struct{}
+
+// withScrapeLogBatch returns a context under which recordScrapeLog batches
+// entries instead of writing them. The caller must flush what is left with
+// flushScrapeLogs(ctx, batch.take()).
+func withScrapeLogBatch(ctx context.Context) (context.Context, *scrapeLogBatch) {
+	b := &scrapeLogBatch{}
+	return context.WithValue(ctx, scrapeLogBatchKey{}, b), b
+}
+
+// add appends entry and, once scrapeLogBatchSize entries are pending, hands
+// them back for flushing.
+func (b *scrapeLogBatch) add(entry scrapeLogEntry) []scrapeLogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = append(b.entries, entry)
+	if len(b.entries) < scrapeLogBatchSize {
+		return nil
+	}
+	return b.takeLocked()
+}
+
+// take removes and returns the pending entries.
+func (b *scrapeLogBatch) take() []scrapeLogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.takeLocked()
+}
+
+func (b *scrapeLogBatch) takeLocked() []scrapeLogEntry {
+	entries := b.entries
+	b.entries = nil
+	return entries
+}
+
+// recordScrapeLog writes entry to the scrape log, or adds it to the run's
+// batch when ctx carries one.
+func (s *Scheduler) recordScrapeLog(ctx context.Context, entry scrapeLogEntry) error {
+	if b, ok := ctx.Value(scrapeLogBatchKey{}).(*scrapeLogBatch); ok {
+		entry.CreatedAt = s.now()
+		s.flushScrapeLogs(ctx, b.add(entry))
+		return nil
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO scrape_log (item_id, user_id, domain, status, failure_reason, error, duration_ms, used_playwright, header_names, locale, country_code, final_url, variant, price_source, backoff_seconds)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $10, NULLIF($11, ''), NULLIF($12, ''), NULLIF($13, ''), NULLIF($14, ''), NULLIF($15, 0))
+	`, entry.ItemID, entry.UserID, entry.Domain, entry.Status, entry.FailureReason, entry.Error, entry.DurationMs, entry.UsedPlaywright, pq.Array(entry.HeaderNames), entry.Locale, entry.CountryCode, entry.FinalURL, entry.Variant, entry.PriceSource, entry.BackoffSeconds)
+	return err
+}
+
+// flushScrapeLogs writes entries with a single INSERT. If that fails, for
+// example because one row is rejected, every row is retried on its own so
+// that only the bad rows are lost.
+func (s *Scheduler) flushScrapeLogs(ctx context.Context, entries []scrapeLogEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	err := s.insertScrapeLogs(ctx, entries)
+	if err == nil {
+		return
+	}
+	if len(entries) > 1 {
+		slog.Warn("Batched scrape log insert failed, retrying row by row", "rows", len(entries), "error", err)
+		for _, entry := range entries {
+			if err := s.insertScrapeLogs(ctx, []scrapeLogEntry{entry}); err != nil {
+				slog.Error("Failed to record scrape log", "id", entry.ItemID, "error", err)
+			}
+		}
+		return
+	}
+	slog.Error("Failed to record scrape log", "id", entries[0].ItemID, "error", err)
+}
+
+func (s *Scheduler) insertScrapeLogs(ctx context.Context, entries []scrapeLogEntry) error {
+	var values strings.Builder
+	args := make([]any, 0, len(entries)*16)
+	for i, e := range entries {
+		if i > 0 {
+			values.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&values, "($%d, $%d, $%d, $%d, NULLIF($%d, ''), NULLIF($%d, ''), $%d, $%d, $%d, $%d, NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, 0), $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13, n+14, n+15, n+16)
+		args = append(args, e.ItemID, e.UserID, e.Domain, e.Status, e.FailureReason, e.Error, e.DurationMs, e.UsedPlaywright, pq.Array(e.HeaderNames), e.Locale, e.CountryCode, e.FinalURL, e.Variant, e.PriceSource, e.BackoffSeconds, e.CreatedAt)
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO scrape_log (item_id, user_id, domain, status, failure_reason, error, duration_ms, used_playwright, header_names, locale, country_code, final_url, variant, price_source, backoff_seconds, created_at)
+		VALUES `+values.String(), args...)
+	return err
+}
+
+// DomainOf returns the host of pageURL, lowercased and without a leading
+// "www.", so that scrape results can be grouped per store.
+func DomainOf(pageURL string) string {
+	u, err := url.Parse(strings.TrimSpace(pageURL))
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	return strings.TrimPrefix(host, "www.")
+}
+
+// classifyScrapeError maps a scrape error to a coarse failure reason that can
+// be aggregated across items (raw error strings embed selectors and URLs).
+func classifyScrapeError(err error) string {
+	if err == nil {
+		return ""
+	}
+	// Whatever the browser was doing when the scraper was stopped under it
+	// says nothing about the page.
+	if errors.Is(err, ErrScraperStopping) {
+		return "scraper_stopping"
+	}
+	if errors.Is(err, ErrNoPrice) {
+		return "no_price"
+	}
+	if errors.Is(err, ErrSelectorNotFound) {
+		return "selector_not_found"
+	}
+	if errors.Is(err, ErrOffDomainRedirect) {
+		return "redirect_off_domain"
+	}
+	if PageGone(err) {
+		return "not_found"
+	}
+	if errors.Is(err, ErrVariantNotSelected) {
+		return "variant_not_selected"
+	}
+	if errors.Is(err, ErrSelectionNeedsBrowser) {
+		return "needs_browser"
+	}
+	if errors.Is(err, ErrBotChallenge) {
+		return "bot_challenge"
+	}
+	if errors.Is(err, ErrOutOfStock) {
+		return "out_of_stock"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "bad status code: 403"), strings.Contains(msg, "bad status code: 429"):
+		return "blocked"
+	case strings.Contains(msg, "bad status code"):
+		return "bad_status"
+	case strings.Contains(msg, "element not found"):
+		return "selector_not_found"
+	case strings.Contains(msg, "Timeout"), strings.Contains(msg, "timeout"):
+		return "timeout"
+	case strings.Contains(msg, "playwright"):
+		return "browser_error"
+	default:
+		return "other"
+	}
+}
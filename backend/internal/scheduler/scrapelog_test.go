@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectScrapeLogBatch expects one INSERT writing exactly rows scrape log
+// entries: the last row's parameters end at $16*rows.
+func expectScrapeLogBatch(mock sqlmock.Sqlmock, rows int) *sqlmock.ExpectedExec {
+	return mock.ExpectExec(fmt.Sprintf(`INSERT INTO scrape_log .*VALUES .*\(\$%d, .*\$%d\)$`, 16*(rows-1)+1, 16*rows))
+}
+
+func testScrapeLogEntries(n int) []scrapeLogEntry {
+	entries := make([]scrapeLogEntry, n)
+	for i := range entries {
+		entries[i] = scrapeLogEntry{ItemID: fmt.Sprintf("item-%d", i), UserID: "user-1", Domain: "example.com", Status: "success"}
+	}
+	return entries
+}
+
+func TestRecordScrapeLog_BatchedVsPerRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := New(db, DefaultConfig())
+	entries := testScrapeLogEntries(3)
+
+	// Without a batch every entry is its own INSERT...
+	for range entries {
+		mock.ExpectExec(regexp.QuoteMeta("VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $10, NULLIF($11, ''), NULLIF($12, ''), NULLIF($13, ''), NULLIF($14, ''), NULLIF($15, 0))")).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	for _, e := range entries {
+		s.recordScrapeLog(context.Background(), e)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Expected 3 per-row inserts: %v", err)
+	}
+
+	// ...with one they are all written by a single statement.
+	ctx, batch := withScrapeLogBatch(context.Background())
+	for _, e := range entries {
+		s.recordScrapeLog(ctx, e)
+	}
+	expectScrapeLogBatch(mock, 3).WillReturnResult(sqlmock.NewResult(3, 3))
+	s.flushScrapeLogs(ctx, batch.take())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expected 1 batched insert: %v", err)
+	}
+}
+
+func TestRecordScrapeLog_FlushesFullBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := New(db, DefaultConfig())
+
+	expectScrapeLogBatch(mock, scrapeLogBatchSize).WillReturnResult(sqlmock.NewResult(0, scrapeLogBatchSize))
+
+	ctx, batch := withScrapeLogBatch(context.Background())
+	for _, e := range testScrapeLogEntries(scrapeLogBatchSize + 1) {
+		s.recordScrapeLog(ctx, e)
+	}
+
+	if rest := batch.take(); len(rest) != 1 {
+		t.Errorf("Expected 1 entry left after a full batch was flushed, got %d", len(rest))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestFlushScrapeLogs_FallsBackToPerRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := New(db, DefaultConfig())
+	entries := testScrapeLogEntries(3)
+
+	// One bad row fails the whole statement; retried alone, only it is lost.
+	expectScrapeLogBatch(mock, 3).WillReturnError(errors.New("value too long"))
+	for _, e := range entries {
+		exp := expectScrapeLogBatch(mock, 1).WithArgs(e.ItemID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg())
+		if e.ItemID == "item-1" {
+			exp.WillReturnError(errors.New("value too long"))
+		} else {
+			exp.WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+	}
+
+	s.flushScrapeLogs(context.Background(), entries)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+const benchmarkScrapeLogRows = 200
+
+// BenchmarkScrapeLog_PerRow measures one INSERT per scrape.
+func BenchmarkScrapeLog_PerRow(b *testing.B) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	s := New(db, DefaultConfig())
+	entries := testScrapeLogEntries(benchmarkScrapeLogRows)
+
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		for range entries {
+			mock.ExpectExec("INSERT INTO scrape_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+		b.StartTimer()
+
+		for _, e := range entries {
+			s.recordScrapeLog(context.Background(), e)
+		}
+	}
+}
+
+// BenchmarkScrapeLog_Batched measures the same rows written in one INSERT.
+func BenchmarkScrapeLog_Batched(b *testing.B) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	s := New(db, DefaultConfig())
+	entries := testScrapeLogEntries(benchmarkScrapeLogRows)
+
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		mock.ExpectExec("INSERT INTO scrape_log").WillReturnResult(sqlmock.NewResult(1, benchmarkScrapeLogRows))
+		b.StartTimer()
+
+		ctx, batch := withScrapeLogBatch(context.Background())
+		for _, e := range entries {
+			s.recordScrapeLog(ctx, e)
+		}
+		s.flushScrapeLogs(ctx, batch.take())
+	}
+}
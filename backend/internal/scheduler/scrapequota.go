@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// usageMonth returns the first day of t's month in UTC, which keys the
+// month's scrape_usage rows.
+func usageMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// withinScrapeQuota returns the items of group whose owners have checks left
+// this month, counting one check against each. The others are reported as
+// over_quota, which finishes their jobs instead of queueing them again, and
+// their owners are told once a month. Without a quota it returns group.
+func (s *Scheduler) withinScrapeQuota(ctx context.Context, group []Item) []Item {
+	if s.scrapeQuota <= 0 {
+		return group
+	}
+	month := usageMonth(s.now())
+	kept := make([]Item, 0, len(group))
+	for _, item := range group {
+		ok, err := s.reserveScrape(ctx, item.UserID, month)
+		if err != nil {
+			// Counting is best effort: the item is checked anyway.
+			slog.Error("Failed to count scrape against quota", "user_id", item.UserID, "error", err)
+			ok = true
+		}
+		if ok {
+			kept = append(kept, item)
+			continue
+		}
+		slog.Info("Skipping item over its owner's scrape quota", "id", item.ID, "user_id", item.UserID, "quota", s.scrapeQuota)
+		reportItemResult(ctx, scrapeLogEntry{
+			ItemID: item.ID,
+			UserID: item.UserID,
+			Domain: DomainOf(item.PageURL),
+			Status: "over_quota",
+		})
+		if err := s.sendScrapeQuotaNotification(ctx, item.UserID, month); err != nil {
+			slog.Error("Failed to send notification", "error", err)
+		}
+	}
+	return kept
+}
+
+// reserveScrape counts a check against userID's quota for month, and
+// reports false, counting nothing, when the quota is used up.
+func (s *Scheduler) reserveScrape(ctx context.Context, userID string, month time.Time) (bool, error) {
+	var scrapes int
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO scrape_usage (user_id, month, scrapes)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (user_id, month) DO UPDATE SET scrapes = scrape_usage.scrapes + 1
+		WHERE scrape_usage.scrapes < $3
+		RETURNING scrapes
+	`, userID, month, s.scrapeQuota).Scan(&scrapes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// sendScrapeQuotaNotification tells userID that their checks for month are
+// used up, unless they were told already.
+func (s *Scheduler) sendScrapeQuotaNotification(ctx context.Context, userID string, month time.Time) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE scrape_usage SET notified_at = $3 WHERE user_id = $1 AND month = $2 AND notified_at IS NULL
+	`, userID, month, s.now())
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	title := "Monthly Price Checks Used Up"
+	message := fmt.Sprintf("Your items have had all %d price checks for this month, so they won't be checked again until %s. Price drops in the meantime will be missed.", s.scrapeQuota, month.AddDate(0, 1, 0).Format("January 2"))
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO notifications (user_id, title, message, type, is_read)
+		VALUES ($1, $2, $3, 'scrape_quota_exceeded', false)
+	`, userID, title, message)
+
+	return err
+}
@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUsageMonth(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	tests := []struct {
+		in   time.Time
+		want time.Time
+	}{
+		{time.Date(2025, 6, 17, 13, 0, 0, 0, time.UTC), time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC), time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)},
+		// Still May in UTC.
+		{time.Date(2025, 6, 1, 8, 0, 0, 0, tokyo), time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if got := usageMonth(test.in); !got.Equal(test.want) {
+			t.Errorf("usageMonth(%v) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+// expectScrapeReserved expects a check of userID's item to be counted, with
+// the user at scrapes checks this month afterwards, or over quota when
+// scrapes is 0.
+func expectScrapeReserved(mock sqlmock.Sqlmock, userID string, month time.Time, quota, scrapes int) {
+	rows := sqlmock.NewRows([]string{"scrapes"})
+	if scrapes > 0 {
+		rows.AddRow(scrapes)
+	}
+	mock.ExpectQuery("INSERT INTO scrape_usage").WithArgs(userID, month, quota).WillReturnRows(rows)
+}
+
+func TestProcessGroup_SkipsUsersOverScrapeQuota(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$2,500.00</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 17, 13, 0, 0, 0, time.UTC)
+	month := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// user-1 has checks left; user-2 used theirs up and is told once.
+	expectScrapeReserved(mock, "user-1", month, 100, 42)
+	expectScrapeReserved(mock, "user-2", month, 100, 0)
+	mock.ExpectExec(`UPDATE scrape_usage SET notified_at = \$3`).
+		WithArgs("user-2", month, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-2", "Monthly Price Checks Used Up", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectScrapeReserved(mock, "user-2", month, 100, 0)
+	mock.ExpectExec(`UPDATE scrape_usage SET notified_at = \$3`).
+		WithArgs("user-2", month, now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// Only user-1's item is checked (and is out of bounds).
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("suspicious", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	cfg := localConfig()
+	cfg.ScrapeQuota = 100
+	s := New(db, cfg)
+	s.now = func() time.Time { return now }
+
+	results := map[string]string{}
+	ctx := WithItemResults(context.Background(), func(result ItemResult) {
+		results[result.ItemID] = result.Status
+	})
+	bounds := priceBounds{max: sql.NullFloat64{Float64: 1000, Valid: true}}
+	s.processGroup(ctx, []Item{
+		{ID: "item-1", UserID: "user-1", PriceText: "$19.99", ProductName: "Widget", PageURL: ts.URL, CSSSelector: ".price", Bounds: bounds},
+		{ID: "item-2", UserID: "user-2", PriceText: "$19.99", ProductName: "Widget", PageURL: ts.URL, CSSSelector: ".price", Bounds: bounds},
+		{ID: "item-3", UserID: "user-2", PriceText: "$24.99", ProductName: "Gadget", PageURL: ts.URL, CSSSelector: ".price", Bounds: bounds},
+	}, newScrapeMemo())
+
+	if hits != 1 {
+		t.Errorf("Expected the page fetched once, got %d", hits)
+	}
+	if results["item-1"] != "suspicious" || results["item-2"] != "over_quota" || results["item-3"] != "over_quota" {
+		t.Errorf("Expected only item-1 checked, got %v", results)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+// capturedArg matches any argument and keeps it.
+type capturedArg struct{ value driver.Value }
+
+func (c *capturedArg) Match(v driver.Value) bool {
+	c.value = v
+	return true
+}
+
+func TestScrapeQuotaNotification_SaysWhenChecksResume(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	message := &capturedArg{}
+	mock.ExpectExec("UPDATE scrape_usage").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-1", "Monthly Price Checks Used Up", message).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	cfg := localConfig()
+	cfg.ScrapeQuota = 500
+	s := New(db, cfg)
+	if err := s.sendScrapeQuotaNotification(context.Background(), "user-1", time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("sendScrapeQuotaNotification failed: %v", err)
+	}
+	if got, _ := message.value.(string); !strings.Contains(got, "all 500 price checks") || !strings.Contains(got, "until January 1") {
+		t.Errorf("Expected the quota and when it resets, got %q", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestProcessGroup_ScrapeQuotaErrorsFailOpen(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$2,500.00</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("INSERT INTO scrape_usage").WillReturnError(sql.ErrConnDone)
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("suspicious", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	cfg := localConfig()
+	cfg.ScrapeQuota = 100
+	s := New(db, cfg)
+	s.processItem(context.Background(), Item{ID: "item-1", UserID: "user-1", PriceText: "$19.99", PageURL: ts.URL, CSSSelector: ".price", Bounds: priceBounds{max: sql.NullFloat64{Float64: 1000, Valid: true}}})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -1,10 +1,13 @@
 package scheduler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"math/rand"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -12,8 +15,79 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/antchfx/htmlquery"
 	"github.com/playwright-community/playwright-go"
+
+	"price-track-backend/internal/adapters"
+	"price-track-backend/internal/version"
 )
 
+// browserUserAgent is a mainstream desktop Chrome user agent. Our product
+// token is appended so site operators can still identify PriceTrack traffic.
+const browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+func userAgent() string {
+	return browserUserAgent + " " + version.Product()
+}
+
+// DefaultItemTimeout bounds one item's scrape, browser fallback included,
+// and DefaultItemHTTPTimeout its plain HTTP part, unless SCRAPE_ITEM_TIMEOUT
+// and SCRAPE_HTTP_TIMEOUT say otherwise.
+const (
+	DefaultItemTimeout     = 120 * time.Second
+	DefaultItemHTTPTimeout = 60 * time.Second
+)
+
+// DefaultAcceptLanguage is the locale pages are requested in unless an item
+// pins another one.
+const DefaultAcceptLanguage = "en-US"
+
+// languageTags lists the languages a browser set to a BCP 47 tag prefers,
+// the bare language following region-specific tags ("de-CH" -> de-CH, de).
+func languageTags(tag string) []string {
+	if tag == "" {
+		tag = DefaultAcceptLanguage
+	}
+	if base, _, ok := strings.Cut(tag, "-"); ok {
+		return []string{tag, base}
+	}
+	return []string{tag}
+}
+
+// acceptLanguageHeader builds the Accept-Language header for a BCP 47 tag,
+// falling back to the bare language for region-specific tags
+// ("de-CH" -> "de-CH,de;q=0.9").
+func acceptLanguageHeader(tag string) string {
+	tags := languageTags(tag)
+	if len(tags) > 1 {
+		return tags[0] + "," + tags[1] + ";q=0.9"
+	}
+	return tags[0]
+}
+
+// screenshotError carries the PNG of the page at the moment a Playwright
+// scrape failed. The scheduler keeps it in its blob store (see
+// storeScreenshot).
+type screenshotError struct {
+	err        error
+	screenshot []byte
+}
+
+func (e *screenshotError) Error() string { return e.err.Error() }
+func (e *screenshotError) Unwrap() error { return e.err }
+
+// FailureScreenshot returns the PNG attached to a failed Playwright scrape.
+func FailureScreenshot(err error) ([]byte, bool) {
+	var shot *screenshotError
+	if errors.As(err, &shot) {
+		return shot.screenshot, true
+	}
+	return nil, false
+}
+
+// withFailureScreenshot attaches png to err.
+func withFailureScreenshot(png []byte, err error) error {
+	return &screenshotError{err: err, screenshot: png}
+}
+
 // Scraper provides methods for scraping prices from web pages.
 // It uses HTTP requests first (fast), and falls back to Playwright (headless browser)
 // for JavaScript-heavy sites.
@@ -22,15 +96,68 @@ type Scraper struct {
 	browser playwright.Browser
 	mu      sync.Mutex
 	started bool
+
+	// shutdown is cancelled by Stop, which cancels the browser work in
+	// flight. While stopping, new browser work is refused; Stop waits up to
+	// stopGrace for the inFlight pages to close, closing drained once they
+	// have, before it closes the browser.
+	shutdown       context.Context
+	cancelShutdown context.CancelFunc
+	stopping       bool
+	inFlight       int
+	drained        chan struct{}
+	stopGrace      time.Duration
+
+	// proxy is SCRAPER_PROXY_URL, used unless an item has its own.
+	proxy *url.URL
+	// profile is SCRAPE_PROFILE, used unless an item has its own.
+	profile ScrapeProfile
+	// blocked are the resource types the browser does not load
+	// (SCRAPE_BLOCK_RESOURCES).
+	blocked []string
+	// itemTimeout bounds a whole ScrapeDetailed, browser fallback included
+	// (SCRAPE_ITEM_TIMEOUT), and httpTimeout its plain HTTP part
+	// (SCRAPE_HTTP_TIMEOUT).
+	itemTimeout time.Duration
+	httpTimeout time.Duration
+	// transport is shared by the HTTP scrapes; it picks each request's
+	// proxy with proxyFor.
+	transport *http.Transport
+	// jars keeps the cookies sites set between scrapes.
+	jars *siteJars
+	// noBrowser turns the headless browser fallback off
+	// (PLAYWRIGHT_DISABLED), e.g. on hosts without browser binaries, and
+	// noJSONLD recovering prices from JSON-LD when a selector breaks
+	// (JSONLD_FALLBACK_DISABLED).
+	noBrowser bool
+	noJSONLD  bool
+	// allowPrivate lets pages on loopback, private and link-local
+	// addresses be scraped (SCRAPE_ALLOW_PRIVATE_ADDRESSES).
+	allowPrivate bool
 }
 
 // NewScraper creates a new Scraper instance.
 func NewScraper() *Scraper {
-	return &Scraper{}
+	s := &Scraper{
+		jars:        newSiteJars(siteJarTTL),
+		blocked:     DefaultBlockedResources,
+		itemTimeout: DefaultItemTimeout,
+		httpTimeout: DefaultItemHTTPTimeout,
+		stopGrace:   stopGracePeriod,
+	}
+	s.shutdown, s.cancelShutdown = context.WithCancel(context.Background())
+	s.transport = http.DefaultTransport.(*http.Transport).Clone()
+	s.transport.Proxy = s.proxyFor
+	s.transport.DialContext = s.dialContext
+	return s
 }
 
 // Start initializes the Playwright browser. Call this once at application startup.
 func (s *Scraper) Start() error {
+	if s.noBrowser {
+		return fmt.Errorf("playwright is disabled via PLAYWRIGHT_DISABLED")
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -63,10 +190,46 @@ func (s *Scraper) Start() error {
 	return nil
 }
 
-// Stop closes the Playwright browser and cleans up resources.
+// ErrScraperStopping is returned for browser work asked of a scraper while
+// it is being stopped.
+var ErrScraperStopping = errors.New("scraper is stopping")
+
+// stopGracePeriod is how long Stop waits for cancelled browser work to close
+// its pages before closing the browser underneath it.
+const stopGracePeriod = 10 * time.Second
+
+// Stop closes the Playwright browser and cleans up resources. Browser work
+// in flight is cancelled and given the grace period to close its pages
+// first; work asked for in the meantime fails with ErrScraperStopping. Once
+// Stop returns, the scraper can be started again.
 func (s *Scraper) Stop() {
+	s.mu.Lock()
+	if s.stopping {
+		s.mu.Unlock()
+		return
+	}
+	s.stopping = true
+	s.cancelShutdown()
+	var drained chan struct{}
+	if s.inFlight > 0 {
+		drained = make(chan struct{})
+		s.drained = drained
+	}
+	s.mu.Unlock()
+
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-time.After(s.stopGrace):
+			slog.Warn("Browser work still running after the grace period, closing the browser anyway", "grace", s.stopGrace)
+		}
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.stopping = false
+	s.drained = nil
+	s.shutdown, s.cancelShutdown = context.WithCancel(context.Background())
 
 	if !s.started {
 		return
@@ -82,57 +245,464 @@ func (s *Scraper) Stop() {
 	slog.Info("Playwright browser stopped")
 }
 
+// ErrNoPrice is returned when a selector matched but its text does not contain
+// a positive price (empty, whitespace, "0.00", "Out of stock", ...).
+var ErrNoPrice = errors.New("scraped text does not contain a positive price")
+
+// validatePriceText enforces the scrape success contract: the extracted text
+// must parse to a positive number.
+func validatePriceText(text string) error {
+	price, err := parsePrice(text)
+	if err != nil || price <= 0 {
+		return fmt.Errorf("%w: %q", ErrNoPrice, text)
+	}
+	return nil
+}
+
+// ErrSelectorNotFound is returned when the page loaded but the item's
+// selector no longer matches anything on it.
+var ErrSelectorNotFound = errors.New("element not found")
+
+// selectorNotFoundError carries the price recovered from the page's JSON-LD,
+// if any, alongside ErrSelectorNotFound.
+type selectorNotFoundError struct {
+	msg         string
+	jsonLDPrice string
+}
+
+func (e *selectorNotFoundError) Error() string { return e.msg }
+func (e *selectorNotFoundError) Unwrap() error { return ErrSelectorNotFound }
+
+// StatusError is returned when a page answers with a status other than 200.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string { return fmt.Sprintf("bad status code: %d", e.Code) }
+
+// PageGone reports whether err means the page no longer exists: it answered
+// 404 Not Found or 410 Gone.
+func PageGone(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && (statusErr.Code == http.StatusNotFound || statusErr.Code == http.StatusGone)
+}
+
+// ScrapeResult describes the outcome of a single scrape attempt.
+type ScrapeResult struct {
+	Text     string
+	Method   string // "http", "playwright" or "jsonld"
+	Duration time.Duration
+	// FinalURL is where the page ended up after redirects, empty if it was
+	// never loaded.
+	FinalURL string
+	// Variant is the label of the variant that was selected before the price
+	// was read, empty when the page's default was.
+	Variant string
+	// Source is where the price was read: SourceSelector, SourceJSONLD or
+	// "adapter:" and the site adapter's name. Empty if no price was.
+	Source string
+	// Shipping is the text the item's shipping selector selected on the page
+	// the price was read from, empty without one or if it matched nothing.
+	Shipping string
+	// Availability is the text the item's availability selector selected on
+	// the page before the price was read, empty without one or if it
+	// matched nothing.
+	Availability string
+}
+
+// SelectorBroken reports whether the price was recovered from JSON-LD because
+// the item's own selector no longer matches.
+func (r ScrapeResult) SelectorBroken() bool {
+	return r.Method == "jsonld"
+}
+
+// UsedPlaywright reports whether the headless browser fallback was needed.
+func (r ScrapeResult) UsedPlaywright() bool {
+	return r.Method == "playwright"
+}
+
+// ScrapeRequest is a page to scrape and how to find its price. See
+// ScrapeDetailed for what each field does; the zero value of each but URL
+// and a selector is the scraper's default.
+type ScrapeRequest struct {
+	URL string
+	// CSSSelector or, without one, XPath selects the price. Match picks
+	// among a CSS selector's matches.
+	CSSSelector string
+	XPath       string
+	Match       SelectorMatch
+	// Frame is the iframe the price is in, if any.
+	Frame Frame
+	// Selection is the variant to make active before the price is read.
+	Selection VariantSelection
+	// Order says whether the site adapter's price comes before or after the
+	// selector's.
+	Order AdapterOrder
+	// AcceptLanguage is the BCP 47 tag the page is requested in and
+	// CountryCode the country the browser is placed in.
+	AcceptLanguage string
+	CountryCode    string
+	// Cookies and Headers are sent with the page's requests.
+	Cookies []Cookie
+	Headers map[string]string
+	// Proxy overrides SCRAPER_PROXY_URL and Profile SCRAPE_PROFILE.
+	Proxy   *url.URL
+	Profile ScrapeProfile
+}
+
 func (s *Scraper) ScrapePrice(url, cssSelector, xpathSelector string) (string, error) {
-	price, err := s.scrapePriceHTTP(url, cssSelector, xpathSelector)
+	result, err := s.ScrapeDetailed(context.Background(), ScrapeRequest{URL: url, CSSSelector: cssSelector, XPath: xpathSelector, AcceptLanguage: DefaultAcceptLanguage})
+	return result.Text, err
+}
+
+// ScrapeDetailed behaves like ScrapePrice but also reports which path produced
+// the result and how long the whole attempt took. A scrape only succeeds if
+// the extracted text parses to a positive price.
+//
+// If the selector matches nothing over HTTP and the Playwright fallback does
+// not find it either, the price published in the page's JSON-LD is used
+// instead and the result is flagged with SelectorBroken.
+//
+// req.AcceptLanguage is the BCP 47 tag the page is requested in; empty means
+// DefaultAcceptLanguage. req.CountryCode places the Playwright browser in
+// that country's timezone and location (see regions). req.Cookies and
+// req.Headers are sent on both paths; the headers override the scraper's own.
+// A non-zero req.Frame looks for the price inside that iframe: over HTTP its
+// document is fetched from the iframe's src. A non-nil req.Proxy takes
+// precedence over SCRAPER_PROXY_URL on both paths, and a non-empty
+// req.Profile over SCRAPE_PROFILE on the Playwright path.
+//
+// Over HTTP, a page without the price that redirects with a meta refresh or
+// a script is followed (see fetchPrice); the browser follows those itself.
+// A page that leads to a second site fails with ErrOffDomainRedirect and is
+// not retried in the browser. One that answers 404 or 410 fails with a
+// StatusError for which PageGone reports true, unless the browser loads it.
+//
+// A non-zero req.Selection is made in the browser before the price is read,
+// and reported in the result's Variant. Such a scrape uses neither plain HTTP
+// nor JSON-LD, which only see the page's default variant, and fails with
+// ErrSelectionNeedsBrowser when Playwright is disabled.
+//
+// On a site with an adapter (see internal/adapters), its canonical URL for
+// the product is fetched and, unless there is a frame, its price is used
+// before or after the item's selectors as req.Order says. Its bot challenge
+// fails with ErrBotChallenge and a product it finds unavailable with
+// ErrOutOfStock, which is not retried in the browser. The result's Source
+// tells which price was used.
+//
+// With a shipping selector in ctx (see withShippingSelector), the text it
+// selects on the same page is reported in the result's Shipping. With an
+// availability selector (see withAvailabilitySelector), the text it selects
+// is checked first and reported in the result's Availability; when it says
+// the product is sold out, no price is read and the scrape fails with
+// ErrOutOfStock, which is not retried in the browser either.
+//
+// The whole attempt is bounded by the scraper's item timeout
+// (SCRAPE_ITEM_TIMEOUT) and its plain HTTP part by the HTTP timeout
+// (SCRAPE_HTTP_TIMEOUT), as well as by ctx.
+func (s *Scraper) ScrapeDetailed(ctx context.Context, req ScrapeRequest) (ScrapeResult, error) {
+	start := time.Now()
+	result := ScrapeResult{Method: "http"}
+	req.URL = canonicalURL(req.URL)
+	req.Profile = s.profileFor(req.Profile)
+	url := req.URL
+	ctx, cancel := context.WithTimeout(ctx, s.itemTimeout)
+	defer cancel()
+	ctx, shipping := startShippingRead(ctx)
+	ctx, availability := startAvailabilityRead(ctx)
+
+	if !req.Selection.IsZero() {
+		result.Method = "playwright"
+		err := ErrSelectionNeedsBrowser
+		if !s.noBrowser {
+			result.Text, result.Source, result.FinalURL, result.Variant, err = s.scrapePricePlaywright(ctx, req)
+		}
+		if err == nil {
+			err = validatePriceText(result.Text)
+		}
+		if err != nil {
+			result.Source = ""
+		}
+		result.Duration = time.Since(start)
+		result.Shipping = shipping.String()
+		result.Availability = availability.String()
+		return result, err
+	}
+
+	httpCtx, cancelHTTP := context.WithTimeout(ctx, s.httpTimeout)
+	price, source, httpFinalURL, httpErr := s.scrapePriceHTTP(withProxy(httpCtx, req.Proxy), url, req.CSSSelector, req.XPath, req.Match, req.Frame, req.Order, req.AcceptLanguage, req.Cookies, req.Headers)
+	cancelHTTP()
+	result.FinalURL = httpFinalURL
+	err := httpErr
 	if err == nil {
-		return price, nil
+		err = validatePriceText(price)
+	}
+	if err == nil {
+		result.Text, result.Source = price, source
+		result.Duration = time.Since(start)
+		result.Shipping = shipping.String()
+		result.Availability = availability.String()
+		return result, nil
+	}
+
+	// A page that left for another site or for a private address is not
+	// chased any further, nor is a product its site says is unavailable.
+	if !s.noBrowser && !errors.Is(httpErr, ErrOffDomainRedirect) && !errors.Is(httpErr, ErrPrivateAddress) && !errors.Is(httpErr, ErrOutOfStock) && ctx.Err() == nil {
+		// If HTTP failed (timeout, 403, 429, or selector not found), try Playwright.
+		slog.Info("HTTP scrape failed, trying Playwright", "url", url, "error", err)
+		result.Method = "playwright"
+		var finalURL string
+		result.Text, result.Source, finalURL, _, err = s.scrapePricePlaywright(ctx, req)
+		if finalURL != "" {
+			result.FinalURL = finalURL
+		}
+		if err == nil {
+			err = validatePriceText(result.Text)
+		}
+		// Unless the browser got to the page, the page is as gone as HTTP
+		// found it.
+		if err != nil && finalURL == "" && PageGone(httpErr) {
+			err = httpErr
+		}
+	}
+
+	var notFound *selectorNotFoundError
+	if err != nil && !s.noJSONLD && errors.As(httpErr, &notFound) && notFound.jsonLDPrice != "" {
+		slog.Warn("Selector not found, using JSON-LD price", "url", url, "error", httpErr)
+		result.Method = "jsonld"
+		result.Text, result.Source = notFound.jsonLDPrice, SourceJSONLD
+		result.FinalURL = httpFinalURL
+		err = nil
+	}
+
+	// Whatever the browser was doing when the budget ran out, the item
+	// timed out.
+	if err != nil && ctx.Err() != nil && !errors.Is(err, ctx.Err()) {
+		err = fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	if err != nil {
+		result.Source = ""
+	}
+	result.Duration = time.Since(start)
+	result.Shipping = shipping.String()
+	result.Availability = availability.String()
+	return result, err
+}
+
+// ScrapeHTTP is a quick, HTTP-only scrape used to preview a selector before
+// an item is saved. It never falls back to Playwright or JSON-LD, so a nil
+// error means the selector itself, or the site's adapter, currently yields a
+// positive price. The browser's req.Selection, req.CountryCode and
+// req.Profile do not apply.
+func (s *Scraper) ScrapeHTTP(ctx context.Context, req ScrapeRequest) (string, error) {
+	price, _, _, err := s.scrapePriceHTTP(withProxy(ctx, req.Proxy), canonicalURL(req.URL), req.CSSSelector, req.XPath, req.Match, req.Frame, req.Order, req.AcceptLanguage, req.Cookies, req.Headers)
+	if err != nil {
+		return "", err
+	}
+	if err := validatePriceText(price); err != nil {
+		return "", err
 	}
+	return price, nil
+}
+
+// ScrapeDiff scrapes req's page the two ways ScrapeDetailed can, over plain
+// HTTP and in the headless browser, and returns both results, so that a user
+// can see whether the page's scripts change the price. Neither falls back to
+// the other or to JSON-LD, and req.Selection is not made, as plain HTTP
+// cannot. With Playwright disabled, browserErr says so.
+func (s *Scraper) ScrapeDiff(ctx context.Context, req ScrapeRequest) (httpResult, browserResult ScrapeResult, httpErr, browserErr error) {
+	req.URL = canonicalURL(req.URL)
+	req.Selection = VariantSelection{}
+	req.Profile = s.profileFor(req.Profile)
 
-	// If HTTP failed (timeout, 403, 429, or selector not found), try Playwright.
-	slog.Info("HTTP scrape failed, trying Playwright", "url", url, "error", err)
-	return s.scrapePricePlaywright(url, cssSelector)
+	start := time.Now()
+	httpResult.Method = "http"
+	httpResult.Text, httpResult.Source, httpResult.FinalURL, httpErr = s.scrapePriceHTTP(withProxy(ctx, req.Proxy), req.URL, req.CSSSelector, req.XPath, req.Match, req.Frame, req.Order, req.AcceptLanguage, req.Cookies, req.Headers)
+	if httpErr == nil {
+		httpErr = validatePriceText(httpResult.Text)
+	}
+	httpResult.Duration = time.Since(start)
 
+	start = time.Now()
+	browserResult.Method = "playwright"
+	browserResult.Text, browserResult.Source, browserResult.FinalURL, _, browserErr = s.scrapePricePlaywright(ctx, req)
+	if browserErr == nil {
+		browserErr = validatePriceText(browserResult.Text)
+	}
+	browserResult.Duration = time.Since(start)
+	return httpResult, browserResult, httpErr, browserErr
 }
 
-func (s *Scraper) scrapePriceHTTP(url, cssSelector, xpathSelector string) (string, error) {
+// selectorNotFound builds the error for a selector that matched nothing,
+// attaching the JSON-LD price from doc, if any.
+func selectorNotFound(doc *goquery.Document, format string, args ...any) error {
+	err := &selectorNotFoundError{msg: fmt.Sprintf(format, args...)}
+	if doc != nil {
+		err.jsonLDPrice, _ = extractJSONLDPrice(doc)
+	}
+	return err
+}
+
+// scrapePriceHTTP fetches the price over plain HTTP. Sites that hand out a
+// session or region cookie before showing prices get a second request
+// carrying the cookies the first one collected. Along with the price and its
+// source, it returns the URL the page ended up at, if one was fetched.
+func (s *Scraper) scrapePriceHTTP(ctx context.Context, url, cssSelector, xpathSelector string, match SelectorMatch, frame Frame, order AdapterOrder, acceptLanguage string, cookies []Cookie, headers map[string]string) (price, source, finalURL string, err error) {
+	jar := s.siteJar(url, cookies, headers)
+	if jar == nil {
+		if jar, err = cookieJar(url, cookies); err != nil {
+			return "", "", "", err
+		}
+	}
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout:   30 * time.Second,
+		Jar:       jar,
+		Transport: s.transport,
+	}
+
+	before := jarCookieCount(jar, url)
+	price, source, finalURL, err = fetchPrice(ctx, client, url, cssSelector, xpathSelector, match, frame, order, acceptLanguage, headers)
+	if (err != nil || validatePriceText(price) != nil) && !errors.Is(err, ErrOffDomainRedirect) && jarCookieCount(jar, url) > before {
+		slog.Info("No price on first visit, retrying with the cookies the site set", "url", url, "error", err)
+		price, source, finalURL, err = fetchPrice(ctx, client, url, cssSelector, xpathSelector, match, frame, order, acceptLanguage, headers)
 	}
+	return price, source, finalURL, err
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// fetchPrice fetches pageURL with client and extracts the selected text and
+// its source. When the page does not have it but sends the browser on with a
+// meta refresh or a script, up to maxPageRedirects of those are followed. It
+// also returns the URL of the page the text was looked for on, after all
+// redirects.
+func fetchPrice(ctx context.Context, client *http.Client, pageURL, cssSelector, xpathSelector string, match SelectorMatch, frame Frame, order AdapterOrder, acceptLanguage string, headers map[string]string) (string, string, string, error) {
+	start, err := url.Parse(pageURL)
 	if err != nil {
-		return "", err
+		return "", "", "", err
+	}
+	chain := newRedirectChain(start)
+	doc, final, err := fetchDocument(ctx, client, pageURL, acceptLanguage, headers)
+	if final == nil {
+		return "", "", "", err
+	}
+	if chainErr := chain.visit(final); chainErr != nil {
+		return "", "", final.String(), chainErr
+	}
+	if err != nil {
+		return "", "", final.String(), err
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 
-	resp, err := client.Do(req)
+	for hops := 0; ; hops++ {
+		if err := checkAvailabilityHTTP(ctx, doc, final); err != nil {
+			return "", "", final.String(), err
+		}
+		price, source, err := extractPrice(ctx, client, doc, final, cssSelector, xpathSelector, match, frame, order, acceptLanguage, headers)
+		readShippingHTTP(ctx, doc)
+		if !errors.Is(err, ErrSelectorNotFound) || hops == maxPageRedirects {
+			return price, source, final.String(), err
+		}
+		target, redirectErr := pageRedirect(doc, final)
+		if redirectErr != nil {
+			return "", "", final.String(), redirectErr
+		}
+		if target == nil {
+			return price, source, final.String(), err
+		}
+		if chainErr := chain.visit(target); chainErr != nil {
+			return "", "", final.String(), chainErr
+		}
+		slog.Info("Following page redirect", "from", final.Redacted(), "to", target.Redacted())
+
+		next, nextFinal, err := fetchDocument(ctx, client, target.String(), acceptLanguage, headers)
+		if nextFinal == nil {
+			return "", "", target.String(), err
+		}
+		if chainErr := chain.visit(nextFinal); chainErr != nil {
+			return "", "", nextFinal.String(), chainErr
+		}
+		if err != nil {
+			return "", "", nextFinal.String(), err
+		}
+		doc, final = next, nextFinal
+	}
+}
+
+// fetchDocument GETs pageURL and parses it. It returns the URL the response
+// came from after HTTP redirects, nil if there was no response.
+func fetchDocument(ctx context.Context, client *http.Client, pageURL, acceptLanguage string, headers map[string]string) (*goquery.Document, *url.URL, error) {
+	resp, err := fetchPage(ctx, client, pageURL, acceptLanguage, headers)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("bad status code: %d", resp.StatusCode)
+	page, err := readPage(resp)
+	if err != nil {
+		return nil, resp.Request.URL, err
 	}
+	doc, err := goquery.NewDocumentFromReader(page)
+	return doc, resp.Request.URL, err
+}
 
-	if cssSelector != "" {
-		doc, err := goquery.NewDocumentFromReader(resp.Body)
+// extractPrice finds the selected text in doc, a page fetched from base,
+// fetching the item's iframe document first when it has one, and returns it
+// with its source. On a site with an adapter, the adapter's price comes
+// before or after the item's own selector as order says.
+func extractPrice(ctx context.Context, client *http.Client, doc *goquery.Document, base *url.URL, cssSelector, xpathSelector string, match SelectorMatch, frame Frame, order AdapterOrder, acceptLanguage string, headers map[string]string) (string, string, error) {
+	if !frame.IsZero() {
+		src, err := frameSource(doc, base, frame)
 		if err != nil {
-			return "", err
+			return "", "", err
+		}
+		if doc, _, err = fetchDocument(ctx, client, src, acceptLanguage, headers); err != nil {
+			return "", "", err
 		}
-		selection := doc.Find(cssSelector).First()
+		price, err := selectorPrice(doc, cssSelector, xpathSelector, match)
+		return price, SourceSelector, err
+	}
+
+	a := adapters.For(base)
+	if a != nil {
+		if err := checkAdapterPage(a, doc, base); err != nil {
+			return "", "", err
+		}
+		// The site's adapter knows better than a selector picked on one
+		// page, unless the item says otherwise.
+		if order != AdapterLast {
+			if price := adapterPrice(a, doc); price != "" {
+				return price, adapterSource(a), nil
+			}
+		}
+	}
+
+	price, err := selectorPrice(doc, cssSelector, xpathSelector, match)
+	if a != nil && order == AdapterLast && (err != nil || validatePriceText(price) != nil) {
+		if adapted := adapterPrice(a, doc); adapted != "" {
+			return adapted, adapterSource(a), nil
+		}
+	}
+	return price, SourceSelector, err
+}
+
+// selectorPrice finds the text the item's selector selects in doc, picking
+// among a CSS selector's matches as match says.
+func selectorPrice(doc *goquery.Document, cssSelector, xpathSelector string, match SelectorMatch) (string, error) {
+	if cssSelector != "" {
+		selection := doc.Find(cssSelector)
 		if selection.Length() == 0 {
-			return "", fmt.Errorf("element not found with css selector: %s", cssSelector)
+			return "", selectorNotFound(doc, "element not found with css selector: %s", cssSelector)
 		}
-		return strings.TrimSpace(selection.Text()), nil
-	} else if xpathSelector != "" {
-		doc, err := htmlquery.Parse(resp.Body)
-		if err != nil {
-			return "", err
+		if !match.all() {
+			return strings.TrimSpace(selection.First().Text()), nil
 		}
-		node := htmlquery.FindOne(doc, xpathSelector)
+		texts := selection.Map(func(_ int, el *goquery.Selection) string { return strings.TrimSpace(el.Text()) })
+		text, ok := match.pick(texts)
+		if !ok {
+			return "", selectorNotFound(doc, "element not found with css selector: %s (%s of %d matches)", cssSelector, match, len(texts))
+		}
+		return text, nil
+	} else if xpathSelector != "" {
+		node := htmlquery.FindOne(doc.Nodes[0], xpathSelector)
 		if node == nil {
-			return "", fmt.Errorf("element not found with xpath: %s", xpathSelector)
+			return "", selectorNotFound(doc, "element not found with xpath: %s", xpathSelector)
 		}
 		return strings.TrimSpace(htmlquery.InnerText(node)), nil
 	}
@@ -140,37 +710,80 @@ func (s *Scraper) scrapePriceHTTP(url, cssSelector, xpathSelector string) (strin
 	return "", fmt.Errorf("no selector provided")
 }
 
-func (s *Scraper) scrapePricePlaywright(url, cssSelector string) (string, error) {
-	s.mu.Lock()
-	if !s.started {
-		s.mu.Unlock()
-		if err := s.Start(); err != nil {
-			return "", fmt.Errorf("failed to start playwright: %w", err)
-		}
-		s.mu.Lock()
+// fetchPage GETs url with the scraper's headers overridden by headers. The
+// caller must close the body of the returned response, which is always 200.
+func fetchPage(ctx context.Context, client *http.Client, url, acceptLanguage string, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
 	}
-	browser := s.browser
-	s.mu.Unlock()
+	req.Header.Set("User-Agent", userAgent())
+	req.Header.Set("Accept-Language", acceptLanguageHeader(acceptLanguage))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{Code: resp.StatusCode}
+	}
+	return resp, nil
+}
 
-	if cssSelector == "" {
-		return "", fmt.Errorf("CSS selector required for Playwright scraping")
+// scrapePricePlaywright loads req's page in the headless browser, performs
+// the site adapter's steps, makes req.Selection the active variant and reads
+// the price.
+// Along with it, it returns the price's source, the URL the page ended up at
+// and the variant that was active. Work cut short by Stop fails with
+// ErrScraperStopping.
+func (s *Scraper) scrapePricePlaywright(ctx context.Context, req ScrapeRequest) (string, string, string, string, error) {
+	if req.CSSSelector == "" {
+		return "", "", "", "", fmt.Errorf("CSS selector required for Playwright scraping")
+	}
+	browser, ctx, done, err := s.beginBrowserWork(ctx)
+	if err != nil {
+		return "", "", "", "", err
 	}
+	defer done()
 
-	context, err := browser.NewContext(playwright.BrowserNewContextOptions{
-		UserAgent: playwright.String("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"),
+	text, source, finalURL, variant, err := s.scrapeInBrowser(ctx, browser, req)
+	if err != nil && errors.Is(context.Cause(ctx), ErrScraperStopping) && !errors.Is(err, ErrScraperStopping) {
+		err = fmt.Errorf("%w: %v", ErrScraperStopping, err)
+	}
+	return text, source, finalURL, variant, err
+}
+
+// scrapeInBrowser does the work of scrapePricePlaywright in browser. Once
+// ctx is done, the page is closed under it.
+func (s *Scraper) scrapeInBrowser(ctx context.Context, browser playwright.Browser, req ScrapeRequest) (string, string, string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", "", "", err
+	}
+	if req.AcceptLanguage == "" {
+		req.AcceptLanguage = DefaultAcceptLanguage
+	}
+
+	browserContext, err := browser.NewContext(playwright.BrowserNewContextOptions{
+		UserAgent: playwright.String(userAgent()),
 		Viewport: &playwright.Size{
 			Width:  1920,
 			Height: 1080,
 		},
-		Locale:            playwright.String("en-US"),
-		TimezoneId:        playwright.String("America/Los_Angeles"),
+		Locale:            playwright.String(req.AcceptLanguage),
+		Proxy:             s.playwrightProxy(req.Proxy),
+		TimezoneId:        playwright.String(timezoneFor(req.CountryCode)),
+		Geolocation:       geolocationFor(req.CountryCode),
 		HasTouch:          playwright.Bool(false),
 		JavaScriptEnabled: playwright.Bool(true),
 
 		Permissions: []string{"geolocation"},
-		ExtraHttpHeaders: map[string]string{
+		ExtraHttpHeaders: mergeHeaders(map[string]string{
 			"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8",
-			"Accept-Language":           "en-US,en;q=0.9",
+			"Accept-Language":           acceptLanguageHeader(req.AcceptLanguage),
 			"Accept-Encoding":           "gzip, deflate, br",
 			"DNT":                       "1",
 			"Connection":                "keep-alive",
@@ -180,21 +793,243 @@ func (s *Scraper) scrapePricePlaywright(url, cssSelector string) (string, error)
 			"Sec-Fetch-Site":            "none",
 			"Sec-Fetch-User":            "?1",
 			"Cache-Control":             "max-age=0",
-		},
+		}, req.Headers),
 	})
 	if err != nil {
-		return "", fmt.Errorf("could not create context: %w", err)
+		return "", "", "", "", fmt.Errorf("could not create context: %w", err)
+	}
+	defer browserContext.Close()
+	// Closing the context when ctx is done makes any call still waiting on
+	// the page return, so the item's budget holds in the browser too.
+	stop := context.AfterFunc(ctx, func() { browserContext.Close() })
+	defer stop()
+	refused := s.routeRequests(ctx, browserContext)
+
+	if len(req.Cookies) > 0 {
+		if err := browserContext.AddCookies(playwrightCookies(req.URL, req.Cookies)); err != nil {
+			return "", "", "", "", fmt.Errorf("could not add cookies: %w", err)
+		}
+	}
+	if jar := s.siteJar(req.URL, req.Cookies, req.Headers); jar != nil {
+		if shared := jarToPlaywright(jar, req.URL); len(shared) > 0 {
+			if err := browserContext.AddCookies(shared); err != nil {
+				slog.Warn("Could not add site cookies", "url", req.URL, "error", err)
+			}
+		}
+		defer func() {
+			if collected, err := browserContext.Cookies(req.URL); err == nil {
+				playwrightToJar(jar, req.URL, collected)
+			}
+		}()
 	}
-	defer context.Close()
 
-	page, err := context.NewPage()
+	page, err := browserContext.NewPage()
 	if err != nil {
-		return "", fmt.Errorf("could not create page: %w", err)
+		return "", "", "", "", fmt.Errorf("could not create page: %w", err)
 	}
 	defer page.Close()
 
-	err = page.AddInitScript(playwright.Script{
-		Content: playwright.String(`
+	if req.Profile.stealth() {
+		addStealthScript(page, req.AcceptLanguage)
+	}
+
+	resp, err := page.Goto(req.URL, playwright.PageGotoOptions{
+		WaitUntil: req.Profile.waitUntil(),
+		Timeout:   playwright.Float(30000),
+	})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", "", "", "", ctxErr
+		}
+		if err := refused(); err != nil {
+			return "", "", "", "", err
+		}
+		return "", "", "", "", fmt.Errorf("could not navigate to page: %w", err)
+	}
+	if resp != nil && (resp.Status() == http.StatusNotFound || resp.Status() == http.StatusGone) {
+		return "", "", "", "", &StatusError{Code: resp.Status()}
+	}
+
+	// Stop and the item's budget cut the wait short, as they do the
+	// browser's own.
+	select {
+	case <-time.After(req.Profile.delay()):
+	case <-ctx.Done():
+		return "", "", page.URL(), "", ctx.Err()
+	}
+	// The page may have been redirected, or sent the browser on, since.
+	if err := refused(); err != nil {
+		return "", "", page.URL(), "", err
+	}
+	if err := CheckPublicURL(ctx, page.URL()); !s.allowPrivate && errors.Is(err, ErrPrivateAddress) {
+		return "", "", page.URL(), "", err
+	}
+
+	a, pageURL := playwrightAdapter(page)
+	if !req.Frame.IsZero() {
+		a = nil
+	}
+	if a != nil {
+		runAdapterSteps(page, a)
+	}
+
+	var variant string
+	if !req.Selection.IsZero() {
+		if variant, err = selectVariant(page, req.Selection); err != nil {
+			return "", "", page.URL(), "", err
+		}
+	}
+	if err := checkAvailabilityBrowser(ctx, page); err != nil {
+		return "", "", page.URL(), variant, err
+	}
+
+	// adapterFallback reads the price with the site's adapter as the page
+	// now shows it.
+	adapterFallback := func() string {
+		doc, err := playwrightDocument(page)
+		if err != nil {
+			return ""
+		}
+		return adapterPrice(a, doc)
+	}
+	if a != nil {
+		if doc, err := playwrightDocument(page); err == nil {
+			if err := checkAdapterPage(a, doc, pageURL); err != nil {
+				return "", "", page.URL(), variant, err
+			}
+			if req.Order != AdapterLast {
+				if text := adapterPrice(a, doc); text != "" {
+					readShippingBrowser(ctx, page)
+					return text, adapterSource(a), page.URL(), variant, nil
+				}
+			}
+		}
+	}
+
+	matches := priceLocator(page, req.CSSSelector, req.Frame)
+	price := matches.First()
+	err = price.WaitFor(playwright.LocatorWaitForOptions{
+		State:   playwright.WaitForSelectorStateVisible,
+		Timeout: playwright.Float(15000),
+	})
+	if err != nil && a != nil && req.Order == AdapterLast {
+		if text := adapterFallback(); text != "" {
+			readShippingBrowser(ctx, page)
+			return text, adapterSource(a), page.URL(), variant, nil
+		}
+	}
+	if err != nil {
+		// A sold out page may only have dropped the price once it
+		// rendered.
+		if err := checkAvailabilityBrowser(ctx, page); err != nil {
+			return "", "", page.URL(), variant, err
+		}
+		notFound := fmt.Errorf("element not found with css selector (Playwright): %s", req.CSSSelector)
+		if !req.Frame.IsZero() {
+			notFound = fmt.Errorf("element not found with css selector (Playwright): %s in %s", req.CSSSelector, req.Frame)
+		}
+		png, screenshotErr := page.Screenshot()
+		if screenshotErr != nil {
+			slog.Warn("Could not take debug screenshot", "error", screenshotErr)
+			return "", "", page.URL(), variant, notFound
+		}
+		return "", "", page.URL(), variant, withFailureScreenshot(png, notFound)
+	}
+
+	text, err := matchedText(price, matches, req.Match)
+	if err != nil {
+		return "", "", page.URL(), variant, err
+	}
+	if a != nil && req.Order == AdapterLast && validatePriceText(text) != nil {
+		if adapted := adapterFallback(); adapted != "" {
+			readShippingBrowser(ctx, page)
+			return adapted, adapterSource(a), page.URL(), variant, nil
+		}
+	}
+
+	// page.URL is where any meta refresh or script redirect, or the variant
+	// selection, ended up.
+	readShippingBrowser(ctx, page)
+	return text, SourceSelector, page.URL(), variant, nil
+}
+
+// beginBrowserWork starts the browser if needed and registers work on it
+// for Stop to wait for. The returned context is ctx, also cancelled with
+// cause ErrScraperStopping when Stop is called; done must be called once the work has closed its browser
+// context. While the scraper is stopping, it fails with ErrScraperStopping.
+func (s *Scraper) beginBrowserWork(ctx context.Context) (playwright.Browser, context.Context, func(), error) {
+	s.mu.Lock()
+	if !s.started && !s.stopping {
+		s.mu.Unlock()
+		if err := s.Start(); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to start playwright: %w", err)
+		}
+		s.mu.Lock()
+	}
+	if s.stopping || !s.started {
+		s.mu.Unlock()
+		return nil, nil, nil, ErrScraperStopping
+	}
+	s.inFlight++
+	browser, shutdown := s.browser, s.shutdown
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(shutdown, func() { cancel(ErrScraperStopping) })
+	done := func() {
+		stop()
+		cancel(nil)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.inFlight--
+		if s.inFlight == 0 && s.drained != nil {
+			close(s.drained)
+			s.drained = nil
+		}
+	}
+	return browser, ctx, done, nil
+}
+
+// matchedText reads the text of the match the item's strategy picks among
+// matches, whose first match is first.
+func matchedText(first, matches playwright.Locator, match SelectorMatch) (string, error) {
+	if !match.all() {
+		text, err := first.TextContent()
+		if err != nil {
+			return "", fmt.Errorf("could not get text content: %w", err)
+		}
+		return strings.TrimSpace(text), nil
+	}
+	texts, err := matches.AllTextContents()
+	if err != nil {
+		return "", fmt.Errorf("could not get text content: %w", err)
+	}
+	for i := range texts {
+		texts[i] = strings.TrimSpace(texts[i])
+	}
+	text, ok := match.pick(texts)
+	if !ok {
+		return "", fmt.Errorf("element not found with css selector (Playwright): %s of %d matches", match, len(texts))
+	}
+	return text, nil
+}
+
+// addStealthScript hides the usual signs of an automated browser from the
+// page's scripts. navigator.languages follows acceptLanguage, as the
+// context's locale and Accept-Language header do.
+func addStealthScript(page playwright.Page, acceptLanguage string) {
+	err := page.AddInitScript(playwright.Script{
+		Content: playwright.String(stealthScript(acceptLanguage)),
+	})
+	if err != nil {
+		slog.Warn("Could not add stealth script", "error", err)
+	}
+}
+
+// stealthScript is the init script of addStealthScript.
+func stealthScript(acceptLanguage string) string {
+	languages, _ := json.Marshal(languageTags(acceptLanguage))
+	return `
 			// Override webdriver detection
 			Object.defineProperty(navigator, 'webdriver', {
 				get: () => undefined
@@ -219,7 +1054,7 @@ func (s *Scraper) scrapePricePlaywright(url, cssSelector string) (string, error)
 			
 			// Override languages
 			Object.defineProperty(navigator, 'languages', {
-				get: () => ['en-US', 'en']
+				get: () => ` + string(languages) + `
 			});
 			
 			// Override permissions API
@@ -229,41 +1064,5 @@ func (s *Scraper) scrapePricePlaywright(url, cssSelector string) (string, error)
 					Promise.resolve({ state: Notification.permission }) :
 					originalQuery(parameters)
 			);
-		`),
-	})
-	if err != nil {
-		slog.Warn("Could not add stealth script", "error", err)
-	}
-
-	_, err = page.Goto(url, playwright.PageGotoOptions{
-		WaitUntil: playwright.WaitUntilStateDomcontentloaded,
-		Timeout:   playwright.Float(30000),
-	})
-	if err != nil {
-		return "", fmt.Errorf("could not navigate to page: %w", err)
-	}
-
-	time.Sleep(time.Duration(1000+rand.Intn(2000)) * time.Millisecond)
-
-	err = page.Locator(cssSelector).First().WaitFor(playwright.LocatorWaitForOptions{
-		State:   playwright.WaitForSelectorStateVisible,
-		Timeout: playwright.Float(15000),
-	})
-	if err != nil {
-		if _, screenshotErr := page.Screenshot(playwright.PageScreenshotOptions{
-			Path: playwright.String("/tmp/debug_screenshot.png"),
-		}); screenshotErr != nil {
-			slog.Warn("Could not save debug screenshot", "error", screenshotErr)
-		} else {
-			slog.Info("Debug screenshot saved to /tmp/debug_screenshot.png")
-		}
-		return "", fmt.Errorf("element not found with css selector (Playwright): %s", cssSelector)
-	}
-
-	text, err := page.Locator(cssSelector).First().TextContent()
-	if err != nil {
-		return "", fmt.Errorf("could not get text content: %w", err)
-	}
-
-	return strings.TrimSpace(text), nil
+		`
 }
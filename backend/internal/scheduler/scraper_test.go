@@ -1,9 +1,14 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestScrapePrice_HTTP_CSS(t *testing.T) {
@@ -14,7 +19,7 @@ func TestScrapePrice_HTTP_CSS(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	scraper := NewScraper()
+	scraper := localScraper()
 	price, err := scraper.ScrapePrice(ts.URL, ".price", "")
 	if err != nil {
 		t.Fatalf("ScrapePrice failed: %v", err)
@@ -33,7 +38,7 @@ func TestScrapePrice_HTTP_XPath(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	scraper := NewScraper()
+	scraper := localScraper()
 	price, err := scraper.ScrapePrice(ts.URL, "", "//div[@id='p']")
 	if err != nil {
 		t.Fatalf("ScrapePrice failed: %v", err)
@@ -52,7 +57,7 @@ func TestScrapePrice_Live_Amazon(t *testing.T) {
 		t.Skip("Skipping live test in short mode")
 	}
 
-	scraper := NewScraper()
+	scraper := localScraper()
 	defer scraper.Stop()
 
 	if err := scraper.Start(); err != nil {
@@ -77,7 +82,7 @@ func TestScrapePrice_Live_Uniqlo(t *testing.T) {
 		t.Skip("Skipping live test in short mode")
 	}
 
-	scraper := NewScraper()
+	scraper := localScraper()
 	defer scraper.Stop()
 
 	if err := scraper.Start(); err != nil {
@@ -95,3 +100,271 @@ func TestScrapePrice_Live_Uniqlo(t *testing.T) {
 	}
 	t.Logf("Uniqlo price: %s", price)
 }
+
+func TestScrapePrice_HTTP_UserAgent(t *testing.T) {
+	var gotUA string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">$19.99</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	scraper := localScraper()
+	if _, err := scraper.ScrapePrice(ts.URL, ".price", ""); err != nil {
+		t.Fatalf("ScrapePrice failed: %v", err)
+	}
+
+	if !strings.Contains(gotUA, "PriceTrack/") {
+		t.Errorf("Expected User-Agent to identify PriceTrack, got %q", gotUA)
+	}
+}
+
+func TestScrapeDetailed_AcceptLanguage(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"de-CH", "de-CH,de;q=0.9"},
+		{"fr", "fr"},
+		{"", "en-US,en;q=0.9"},
+	}
+
+	for _, test := range tests {
+		var got string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Get("Accept-Language")
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><div class="price">CHF 19.90</div></body></html>`))
+		}))
+
+		if _, err := localScraper().ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price", AcceptLanguage: test.tag}); err != nil {
+			t.Errorf("%q: ScrapeDetailed failed: %v", test.tag, err)
+		}
+		ts.Close()
+
+		if got != test.want {
+			t.Errorf("%q: expected Accept-Language %q, got %q", test.tag, test.want, got)
+		}
+	}
+}
+
+func TestStealthScript_Languages(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"de-CH", `["de-CH","de"]`},
+		{"fr", `["fr"]`},
+		{"", `["en-US","en"]`},
+	}
+
+	for _, test := range tests {
+		if script := stealthScript(test.tag); !strings.Contains(script, "get: () => "+test.want) {
+			t.Errorf("%q: expected navigator.languages %s, got script:\n%s", test.tag, test.want, script)
+		}
+	}
+}
+
+func TestScrapePrice_RejectsNonPrices(t *testing.T) {
+
+	tests := []struct {
+		name string
+		html string
+	}{
+		{"empty", `<div class="price"></div>`},
+		{"whitespace", `<div class="price">   \n\t </div>`},
+		{"zero", `<div class="price">$0.00</div>`},
+		{"no digits", `<div class="price">Currently unavailable</div>`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte("<html><body>" + test.html + "</body></html>"))
+			}))
+			defer ts.Close()
+
+			_, err := httpScraper(true).ScrapePrice(ts.URL, ".price", "")
+			if !errors.Is(err, ErrNoPrice) {
+				t.Errorf("Expected ErrNoPrice, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWithFailureScreenshot(t *testing.T) {
+	cause := errors.New("element not found")
+	err := withFailureScreenshot([]byte("png-bytes"), cause)
+	if !errors.Is(err, cause) || err.Error() != cause.Error() {
+		t.Fatalf("Expected error to wrap %v, got %v", cause, err)
+	}
+
+	shot, ok := FailureScreenshot(fmt.Errorf("scrape failed: %w", err))
+	if !ok || string(shot) != "png-bytes" {
+		t.Errorf("Expected the screenshot attached to the error, got %q", shot)
+	}
+	if _, ok := FailureScreenshot(cause); ok {
+		t.Error("Expected no screenshot on a plain error")
+	}
+}
+
+func TestScrapeDiff_PlaywrightDisabled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><div class="price">$12.50</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	httpResult, browserResult, httpErr, browserErr := httpScraper(true).ScrapeDiff(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price"})
+	if httpErr != nil || httpResult.Text != "$12.50" || httpResult.Source != SourceSelector || httpResult.Method != "http" {
+		t.Errorf("Expected the HTTP price, got %+v (error: %v)", httpResult, httpErr)
+	}
+	if browserErr == nil || browserResult.Text != "" || browserResult.Method != "playwright" {
+		t.Errorf("Expected the browser to be unavailable, got %+v (error: %v)", browserResult, browserErr)
+	}
+}
+
+// localScraper is NewScraper allowed to scrape the loopback addresses test
+// servers listen on.
+func localScraper() *Scraper {
+	s := NewScraper()
+	s.allowPrivate = true
+	return s
+}
+
+// localConfig is DefaultConfig allowed to scrape the loopback addresses test
+// servers listen on.
+func localConfig() Config {
+	cfg := DefaultConfig()
+	cfg.PrivateAddressesAllowed = true
+	return cfg
+}
+
+// httpScraper returns a scraper that never falls back to the browser, nor to
+// JSON-LD prices unless jsonLD is set.
+func httpScraper(jsonLD bool) *Scraper {
+	s := localScraper()
+	s.noBrowser = true
+	s.noJSONLD = !jsonLD
+	return s
+}
+
+// noBrowserConfig is localConfig without the browser fallback.
+func noBrowserConfig() Config {
+	cfg := localConfig()
+	cfg.PlaywrightDisabled = true
+	return cfg
+}
+
+// fakeStarted marks s as started without launching a browser, so that browser
+// work can be registered against it.
+func fakeStarted(s *Scraper) {
+	s.mu.Lock()
+	s.started = true
+	s.mu.Unlock()
+}
+
+func TestScraperStop_WaitsForBrowserWork(t *testing.T) {
+	s := localScraper()
+	fakeStarted(s)
+	_, ctx, done, err := s.beginBrowserWork(context.Background())
+	if err != nil {
+		t.Fatalf("beginBrowserWork failed: %v", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+
+	<-ctx.Done()
+	if cause := context.Cause(ctx); !errors.Is(cause, ErrScraperStopping) {
+		t.Errorf("Expected the work to be cancelled for the stop, got %v", cause)
+	}
+	if _, _, _, err := s.beginBrowserWork(context.Background()); !errors.Is(err, ErrScraperStopping) {
+		t.Errorf("Expected new work to be refused while stopping, got %v", err)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("Expected Stop to wait for the work in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	done()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Stop to return once the work was done")
+	}
+	if s.started {
+		t.Error("Expected the scraper to be stopped")
+	}
+}
+
+func TestScraperStop_GracePeriod(t *testing.T) {
+	s := localScraper()
+	s.stopGrace = 10 * time.Millisecond
+	fakeStarted(s)
+	_, _, done, err := s.beginBrowserWork(context.Background())
+	if err != nil {
+		t.Fatalf("beginBrowserWork failed: %v", err)
+	}
+
+	start := time.Now()
+	s.Stop()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Stop to give up after the grace period, took %v", elapsed)
+	}
+	// The straggler finishing later is harmless, and the scraper can be used
+	// again.
+	done()
+	fakeStarted(s)
+	_, _, done, err = s.beginBrowserWork(context.Background())
+	if err != nil {
+		t.Fatalf("Expected a restarted scraper to take work, got %v", err)
+	}
+	done()
+}
+
+func TestScraperStop_WhileScraping(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Playwright test in short mode")
+	}
+	scraper := localScraper()
+	if err := scraper.Start(); err != nil {
+		t.Skipf("Skipping Playwright test: %v", err)
+	}
+	defer scraper.Stop()
+
+	requested := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+		w.Write([]byte(`<html><body><span class="price">$19.99</span></body></html>`))
+	}))
+	defer ts.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, _, _, _, err := scraper.scrapePricePlaywright(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price", Profile: ProfileFast})
+		errs <- err
+	}()
+	<-requested
+
+	start := time.Now()
+	scraper.Stop()
+	if elapsed := time.Since(start); elapsed >= stopGracePeriod {
+		t.Errorf("Expected the scrape to wind down before the grace period, Stop took %v", elapsed)
+	}
+	if err := <-errs; !errors.Is(err, ErrScraperStopping) {
+		t.Errorf("Expected ErrScraperStopping, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// screenshotRetention is how long failure screenshots are kept.
+	screenshotRetention = 14 * 24 * time.Hour
+	// screenshotPruneBatch bounds the screenshots one run deletes, so that a
+	// backlog is worked off over several runs.
+	screenshotPruneBatch = 500
+)
+
+// screenshotKey is the blob store key of the screenshot taken of itemID's
+// page at t.
+func screenshotKey(itemID string, t time.Time) string {
+	return fmt.Sprintf("screenshots/%s/%d.png", itemID, t.UnixNano())
+}
+
+// storeScreenshot keeps the screenshot scrapeErr carries, if any, in the blob
+// store and records it in scrape_screenshots. Failures are logged, as the
+// scrape has failed already.
+func (s *Scheduler) storeScreenshot(ctx context.Context, entry scrapeLogEntry, scrapeErr error) {
+	png, ok := FailureScreenshot(scrapeErr)
+	if !ok || s.blobs == nil {
+		return
+	}
+	key := screenshotKey(entry.ItemID, s.now())
+	if err := s.blobs.Put(ctx, key, png, "image/png"); err != nil {
+		slog.Warn("Could not store failure screenshot", "id", entry.ItemID, "error", err)
+		return
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO scrape_screenshots (item_id, user_id, key, error)
+		VALUES ($1, $2, $3, $4)
+	`, entry.ItemID, entry.UserID, key, entry.Error)
+	if err != nil {
+		slog.Error("Failed to record failure screenshot", "id", entry.ItemID, "error", err)
+		s.blobs.Delete(ctx, key)
+		return
+	}
+	slog.Info("Failure screenshot stored", "id", entry.ItemID, "key", key)
+}
+
+// pruneScreenshots deletes screenshots older than screenshotRetention from
+// the blob store, then their rows. A screenshot that cannot be deleted keeps
+// its row, so the next run tries again.
+func (s *Scheduler) pruneScreenshots(ctx context.Context) {
+	if s.blobs == nil {
+		return
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, key FROM scrape_screenshots
+		WHERE created_at < $1
+		ORDER BY created_at
+		LIMIT $2
+	`, s.now().Add(-screenshotRetention), screenshotPruneBatch)
+	if err != nil {
+		slog.Error("Failed to query old screenshots", "error", err)
+		return
+	}
+	type screenshot struct {
+		id  int64
+		key string
+	}
+	var old []screenshot
+	for rows.Next() {
+		var shot screenshot
+		if err := rows.Scan(&shot.id, &shot.key); err != nil {
+			slog.Error("Failed to scan screenshot", "error", err)
+			continue
+		}
+		old = append(old, shot)
+	}
+	rows.Close()
+
+	var deleted []int64
+	for _, shot := range old {
+		if err := s.blobs.Delete(ctx, shot.key); err != nil {
+			slog.Warn("Could not delete screenshot", "key", shot.key, "error", err)
+			continue
+		}
+		deleted = append(deleted, shot.id)
+	}
+	if len(deleted) == 0 {
+		return
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM scrape_screenshots WHERE id = ANY($1)`, pq.Array(deleted)); err != nil {
+		slog.Error("Failed to delete screenshot rows", "error", err)
+		return
+	}
+	slog.Info("Pruned screenshots", "count", len(deleted))
+}
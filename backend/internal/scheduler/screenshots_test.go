@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+
+	"price-track-backend/internal/blobstore"
+)
+
+func TestStoreScreenshot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	blobs := blobstore.Dir(t.TempDir())
+	cfg := DefaultConfig()
+	cfg.Blobs = blobs
+	s := New(db, cfg)
+	s.now = func() time.Time { return now }
+
+	key := screenshotKey("item-1", now)
+	mock.ExpectExec("INSERT INTO scrape_screenshots").
+		WithArgs("item-1", "user-1", key, "element not found").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	entry := scrapeLogEntry{ItemID: "item-1", UserID: "user-1", Error: "element not found"}
+	s.storeScreenshot(context.Background(), entry, withFailureScreenshot([]byte("png-bytes"), errors.New("element not found")))
+	if data, err := blobs.Get(context.Background(), key); err != nil || string(data) != "png-bytes" {
+		t.Errorf("Expected the screenshot under %s, got %q (error: %v)", key, data, err)
+	}
+
+	// Errors without a screenshot store nothing.
+	s.storeScreenshot(context.Background(), entry, errors.New("bad status code: 503"))
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestPruneScreenshots(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	blobs := blobstore.Dir(t.TempDir())
+	ctx := context.Background()
+	blobs.Put(ctx, "screenshots/item-1/1.png", []byte("old"), "image/png")
+	blobs.Put(ctx, "screenshots/item-1/2.png", []byte("new"), "image/png")
+	cfg := DefaultConfig()
+	cfg.Blobs = blobs
+	s := New(db, cfg)
+	s.now = func() time.Time { return now }
+
+	// The second key's blob is gone already; its row is deleted all the same.
+	mock.ExpectQuery("SELECT id, key FROM scrape_screenshots").
+		WithArgs(now.Add(-screenshotRetention), screenshotPruneBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key"}).AddRow(1, "screenshots/item-1/1.png").AddRow(2, "screenshots/item-9/1.png"))
+	mock.ExpectExec("DELETE FROM scrape_screenshots").
+		WithArgs(pq.Array([]int64{1, 2})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	s.pruneScreenshots(ctx)
+	if _, err := blobs.Get(ctx, "screenshots/item-1/1.png"); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Errorf("Expected the old screenshot deleted, got %v", err)
+	}
+	if _, err := blobs.Get(ctx, "screenshots/item-1/2.png"); err != nil {
+		t.Errorf("Expected the newer screenshot kept, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
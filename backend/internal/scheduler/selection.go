@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// VariantSelection is the size, color or other option an item tracks on a
+// page that shows the price of whichever one is selected. Selector is a CSS
+// selector for the control: a <select> has the option whose value or label is
+// Value chosen, anything else is clicked, limited to the element whose text
+// is Value when Value is set (say, the "11" among all size buttons). The zero
+// VariantSelection leaves the page's default variant.
+type VariantSelection struct {
+	Selector string
+	Value    string
+}
+
+// IsZero reports whether the page's default variant is tracked.
+func (v VariantSelection) IsZero() bool {
+	return v.Selector == ""
+}
+
+func (v VariantSelection) String() string {
+	if v.Value == "" {
+		return v.Selector
+	}
+	return fmt.Sprintf("%s = %q", v.Selector, v.Value)
+}
+
+// ErrSelectionNeedsBrowser is returned when an item with a VariantSelection
+// is scraped with Playwright disabled: a plain GET only ever sees the default
+// variant, so its price would be wrong.
+var ErrSelectionNeedsBrowser = errors.New("variant selection needs the headless browser")
+
+// ErrVariantNotSelected is returned when the page loaded but the variant
+// could not be selected on it, as when the size is gone from the list.
+var ErrVariantNotSelected = errors.New("variant not selected")
+
+// selectionSettleTimeout bounds the wait for the page to finish reacting to
+// a variant selection, which may load the variant's price or a new page.
+const selectionSettleTimeout = 5 * time.Second
+
+// selectVariant makes selection the active variant on page before the price
+// is read, and returns what is now selected: the chosen option's label or the
+// clicked element's text.
+func selectVariant(page playwright.Page, selection VariantSelection) (string, error) {
+	control := page.Locator(selection.Selector).First()
+	err := control.WaitFor(playwright.LocatorWaitForOptions{
+		State:   playwright.WaitForSelectorStateAttached,
+		Timeout: playwright.Float(15000),
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: control not found: %s", ErrVariantNotSelected, selection)
+	}
+
+	tag, err := control.Evaluate(`el => el.tagName`, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not inspect variant control: %w", err)
+	}
+	var active string
+	if tag == "SELECT" {
+		values := []string{selection.Value}
+		if _, err := control.SelectOption(playwright.SelectOptionValues{ValuesOrLabels: &values}); err != nil {
+			return "", fmt.Errorf("%w: could not select %s: %v", ErrVariantNotSelected, selection, err)
+		}
+		label, err := control.Evaluate(`el => el.selectedOptions.length ? el.selectedOptions[0].text : ""`, nil)
+		if err != nil {
+			return "", fmt.Errorf("could not read selected variant: %w", err)
+		}
+		active, _ = label.(string)
+	} else {
+		if selection.Value != "" {
+			// The one button with exactly that text, so "1" is not "11".
+			control = page.Locator(selection.Selector).Filter(playwright.LocatorFilterOptions{
+				HasText: regexp.MustCompile(`^\s*` + regexp.QuoteMeta(selection.Value) + `\s*$`),
+			}).First()
+		}
+		if err := control.Click(playwright.LocatorClickOptions{Timeout: playwright.Float(15000)}); err != nil {
+			return "", fmt.Errorf("%w: could not click %s: %v", ErrVariantNotSelected, selection, err)
+		}
+		if active, err = control.TextContent(); err != nil {
+			active = selection.Value
+		}
+	}
+
+	// Not every page goes idle, so a slow one is read as it is.
+	page.WaitForLoadState(playwright.PageWaitForLoadStateOptions{
+		State:   playwright.LoadStateNetworkidle,
+		Timeout: playwright.Float(float64(selectionSettleTimeout.Milliseconds())),
+	})
+	return strings.TrimSpace(active), nil
+}
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// variantShop serves a product page whose price follows the selected size,
+// picked with buttons or a dropdown, as shops do with a script.
+func variantShop() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>
+			<div class="sizes">
+				<button class="size" data-price="$89.00">9</button>
+				<button class="size" data-price="$95.00">11</button>
+				<button class="size" data-price="$99.00">1</button>
+			</div>
+			<select id="size"><option value="9" data-price="$89.00">US 9</option><option value="11" data-price="$95.00">US 11</option></select>
+			<span class="price">$89.00</span>
+			<script>
+				const price = document.querySelector(".price");
+				for (const b of document.querySelectorAll("button.size")) {
+					b.addEventListener("click", () => { price.textContent = b.dataset.price; });
+				}
+				const select = document.querySelector("#size");
+				select.addEventListener("change", () => { price.textContent = select.selectedOptions[0].dataset.price; });
+			</script>
+		</body></html>`))
+	}))
+}
+
+func TestScrapeDetailed_SelectsVariant(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Playwright test in short mode")
+	}
+	scraper := localScraper()
+	if err := scraper.Start(); err != nil {
+		t.Skipf("Skipping Playwright test: %v", err)
+	}
+	defer scraper.Stop()
+	ts := variantShop()
+	defer ts.Close()
+
+	tests := []struct {
+		selection VariantSelection
+		price     string
+		variant   string
+	}{
+		{VariantSelection{}, "$89.00", ""},
+		{VariantSelection{Selector: "button.size", Value: "11"}, "$95.00", "11"},
+		{VariantSelection{Selector: "button.size", Value: "1"}, "$99.00", "1"},
+		{VariantSelection{Selector: "#size", Value: "11"}, "$95.00", "US 11"},
+		{VariantSelection{Selector: "#size", Value: "US 11"}, "$95.00", "US 11"},
+	}
+	for _, test := range tests {
+		result, err := scraper.ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price", Selection: test.selection, Profile: ProfileFast})
+		if err != nil {
+			t.Errorf("%s: ScrapeDetailed failed: %v", test.selection, err)
+			continue
+		}
+		if result.Text != test.price || result.Variant != test.variant {
+			t.Errorf("%s: Expected %s for variant %q, got %s for %q", test.selection, test.price, test.variant, result.Text, result.Variant)
+		}
+	}
+
+	_, err := scraper.ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price", Selection: VariantSelection{Selector: "button.size", Value: "12"}, Profile: ProfileFast})
+	if !errors.Is(err, ErrVariantNotSelected) {
+		t.Errorf("Expected a missing size to fail, got %v", err)
+	}
+}
+
+func TestScrapeDetailed_SelectionNeedsBrowser(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`<html><body><span class="price">$89.00</span></body></html>`))
+	}))
+	defer ts.Close()
+
+	result, err := httpScraper(true).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price", Selection: VariantSelection{Selector: "button.size", Value: "11"}})
+	if !errors.Is(err, ErrSelectionNeedsBrowser) || result.Text != "" {
+		t.Errorf("Expected the default variant's price not to be used, got %q (error: %v)", result.Text, err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("Expected no plain HTTP request, got %d", n)
+	}
+	if reason := classifyScrapeError(err); reason != "needs_browser" {
+		t.Errorf("Expected needs_browser, got %q", reason)
+	}
+}
+
+func TestGroupItems_SeparatesVariants(t *testing.T) {
+	items := []Item{
+		{ID: "size-9", PageURL: "https://shop.example/shoe", CSSSelector: ".price", Selection: VariantSelection{Selector: "button.size", Value: "9"}},
+		{ID: "size-11", PageURL: "https://shop.example/shoe", CSSSelector: ".price", Selection: VariantSelection{Selector: "button.size", Value: "11"}},
+		{ID: "default", PageURL: "https://shop.example/shoe", CSSSelector: ".price"},
+	}
+	if groups := groupItems(items); len(groups) != 3 {
+		t.Errorf("Expected each variant to be its own page, got %d groups", len(groups))
+	}
+}
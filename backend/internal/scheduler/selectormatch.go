@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SelectorMatch is which of the elements an item's CSS selector matches the
+// price is read from, for list pages where it matches several. Empty means
+// SelectorFirst. XPath selectors always use their first match.
+type SelectorMatch string
+
+const (
+	// SelectorFirst reads the first match in document order. It is the
+	// default.
+	SelectorFirst SelectorMatch = "first"
+	// SelectorMin and SelectorMax read the match with the lowest and the
+	// highest price. Matches without a price ("Sold out") are skipped.
+	SelectorMin SelectorMatch = "min"
+	SelectorMax SelectorMatch = "max"
+)
+
+// selectorIndexPrefix starts "index:N", which reads the Nth match, counting
+// from 1.
+const selectorIndexPrefix = "index:"
+
+// ValidSelectorMatch reports whether s names a known strategy.
+func ValidSelectorMatch(s string) bool {
+	switch SelectorMatch(s) {
+	case SelectorFirst, SelectorMin, SelectorMax:
+		return true
+	}
+	_, ok := SelectorMatch(s).index()
+	return ok
+}
+
+// index returns N for "index:N".
+func (m SelectorMatch) index() (int, bool) {
+	rest, ok := strings.CutPrefix(string(m), selectorIndexPrefix)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(rest)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
+
+// all reports whether every match has to be read rather than the first.
+func (m SelectorMatch) all() bool {
+	return m != "" && m != SelectorFirst
+}
+
+// pick returns the text m selects among texts, the trimmed texts of every
+// element the selector matched, in document order. When no match has a price,
+// min and max fall back to the first, which then fails as it would have
+// anyway. ok is false when there is no Nth match for index:N.
+func (m SelectorMatch) pick(texts []string) (text string, ok bool) {
+	if len(texts) == 0 {
+		return "", false
+	}
+	if n, isIndex := m.index(); isIndex {
+		if n > len(texts) {
+			return "", false
+		}
+		return texts[n-1], true
+	}
+	if m != SelectorMin && m != SelectorMax {
+		return texts[0], true
+	}
+
+	text = texts[0]
+	var best float64
+	for _, candidate := range texts {
+		price, err := parsePrice(candidate)
+		if err != nil || price <= 0 {
+			continue
+		}
+		if best == 0 || (m == SelectorMin && price < best) || (m == SelectorMax && price > best) {
+			text, best = candidate, price
+		}
+	}
+	return text, true
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// listPage shows three offers for one product, the middle one sold out.
+const listPage = `<html><body>
+	<div class="offer"><span class="price">$24.99</span></div>
+	<div class="offer"><span class="price">Sold out</span></div>
+	<div class="offer"><span class="price">$19.50</span></div>
+	<div class="offer"><span class="price">$31.00</span></div>
+</body></html>`
+
+func TestValidSelectorMatch(t *testing.T) {
+	for _, s := range []string{"first", "min", "max", "index:1", "index:12"} {
+		if !ValidSelectorMatch(s) {
+			t.Errorf("Expected %q to be valid", s)
+		}
+	}
+	for _, s := range []string{"", "last", "index:", "index:0", "index:-1", "index:two", "Index:1"} {
+		if ValidSelectorMatch(s) {
+			t.Errorf("Expected %q to be invalid", s)
+		}
+	}
+}
+
+func TestSelectorPrice_Match(t *testing.T) {
+	doc := parsePage(t, listPage)
+	tests := []struct {
+		match    SelectorMatch
+		expected string
+	}{
+		{"", "$24.99"},
+		{SelectorFirst, "$24.99"},
+		{SelectorMin, "$19.50"},
+		{SelectorMax, "$31.00"},
+		{"index:2", "Sold out"},
+		{"index:4", "$31.00"},
+	}
+	for _, test := range tests {
+		price, err := selectorPrice(doc, ".price", "", test.match)
+		if err != nil || price != test.expected {
+			t.Errorf("%q: Expected %q, got %q (%v)", test.match, test.expected, price, err)
+		}
+	}
+
+	if _, err := selectorPrice(doc, ".price", "", "index:5"); !errors.Is(err, ErrSelectorNotFound) {
+		t.Errorf("Expected a missing fifth match not to be found, got %v", err)
+	}
+}
+
+func TestSelectorPrice_MatchWithoutPrices(t *testing.T) {
+	doc := parsePage(t, `<span class="price">Sold out</span><span class="price">Coming soon</span>`)
+
+	price, err := selectorPrice(doc, ".price", "", SelectorMin)
+	if err != nil || price != "Sold out" {
+		t.Errorf("Expected the first match when none has a price, got %q (%v)", price, err)
+	}
+}
+
+func TestScrapeDetailed_SelectorMatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(listPage))
+	}))
+	defer ts.Close()
+
+	result, err := httpScraper(true).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price", Match: SelectorMin})
+	if err != nil || result.Text != "$19.50" || result.Source != SourceSelector {
+		t.Errorf("Expected the lowest of the prices, got %+v (%v)", result, err)
+	}
+
+	// The second offer has no price, so reading it fails like any other
+	// selector matching text without one.
+	if _, err := httpScraper(true).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL, CSSSelector: ".price", Match: "index:2"}); !errors.Is(err, ErrNoPrice) {
+		t.Errorf("Expected ErrNoPrice, got %v", err)
+	}
+}
+
+func TestScrapeSignature_SelectorMatch(t *testing.T) {
+	first := Item{PageURL: "https://shop.example.com/list", CSSSelector: ".price"}
+	cheapest := first
+	cheapest.SelectorMatch = SelectorMin
+	if first.scrapeSignature() == cheapest.scrapeSignature() {
+		t.Error("Expected items reading different matches not to share a scrape")
+	}
+}
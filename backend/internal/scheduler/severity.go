@@ -0,0 +1,56 @@
+package scheduler
+
+// Severity ranks a notification so that clients can tell a big drop from a
+// small one and filter on it. Notifications other than price drops are
+// SeverityInfo.
+type Severity string
+
+const (
+	SeverityInfo   Severity = "info"
+	SeverityNotice Severity = "notice"
+	SeverityAlert  Severity = "alert"
+)
+
+// ValidSeverity reports whether s names a known severity.
+func ValidSeverity(s string) bool {
+	switch Severity(s) {
+	case SeverityInfo, SeverityNotice, SeverityAlert:
+		return true
+	}
+	return false
+}
+
+// SeverityThresholds are the drops, in percent of the previous price, from
+// which a price drop notification is a notice and an alert.
+type SeverityThresholds struct {
+	NoticePercent float64
+	AlertPercent  float64
+}
+
+// DefaultSeverityThresholds make a drop of 10% a notice and one of 25% an
+// alert.
+var DefaultSeverityThresholds = SeverityThresholds{NoticePercent: 10, AlertPercent: 25}
+
+// dropPercent returns how much cheaper newPrice is than oldPrice, in
+// percent, or 0 if it is not.
+func dropPercent(oldPrice, newPrice float64) float64 {
+	if oldPrice <= 0 || newPrice >= oldPrice {
+		return 0
+	}
+	return (oldPrice - newPrice) / oldPrice * 100
+}
+
+// dropSeverity returns the severity of a drop from oldPrice to newPrice.
+func (t SeverityThresholds) dropSeverity(oldPrice, newPrice float64) Severity {
+	percent := dropPercent(oldPrice, newPrice)
+	if percent == 0 {
+		return SeverityInfo
+	}
+	switch {
+	case percent >= t.AlertPercent:
+		return SeverityAlert
+	case percent >= t.NoticePercent:
+		return SeverityNotice
+	}
+	return SeverityInfo
+}
@@ -0,0 +1,40 @@
+package scheduler
+
+import "testing"
+
+func TestDropSeverity(t *testing.T) {
+	tests := []struct {
+		oldPrice, newPrice float64
+		expected           Severity
+	}{
+		{100, 99, SeverityInfo},
+		{100, 90.01, SeverityInfo},
+		{100, 90, SeverityNotice},
+		{45, 40, SeverityNotice},
+		{100, 75.01, SeverityNotice},
+		{100, 75, SeverityAlert},
+		{19.99, 9.99, SeverityAlert},
+		// Not drops.
+		{100, 100, SeverityInfo},
+		{100, 120, SeverityInfo},
+		{0, 10, SeverityInfo},
+	}
+	for _, test := range tests {
+		if got := DefaultSeverityThresholds.dropSeverity(test.oldPrice, test.newPrice); got != test.expected {
+			t.Errorf("dropSeverity(%v, %v) = %q, expected %q", test.oldPrice, test.newPrice, got, test.expected)
+		}
+	}
+}
+
+func TestDropSeverity_CustomThresholds(t *testing.T) {
+	thresholds := SeverityThresholds{NoticePercent: 5, AlertPercent: 50}
+	if got := thresholds.dropSeverity(100, 94); got != SeverityNotice {
+		t.Errorf("Expected a 6%% drop to be a notice, got %q", got)
+	}
+	if got := thresholds.dropSeverity(100, 60); got != SeverityNotice {
+		t.Errorf("Expected a 40%% drop to be a notice, got %q", got)
+	}
+	if got := thresholds.dropSeverity(100, 50); got != SeverityAlert {
+		t.Errorf("Expected a 50%% drop to be an alert, got %q", got)
+	}
+}
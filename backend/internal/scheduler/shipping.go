@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/playwright-community/playwright-go"
+)
+
+// shippingSelectorKey carries the CSS selector a scrape reads the shipping
+// cost with (see withShippingSelector).
+type shippingSelectorKey struct{}
+
+// shippingReadKey carries the *shippingRead of a scrape in progress.
+type shippingReadKey struct{}
+
+// withShippingSelector makes ScrapeDetailed also read the text cssSelector
+// selects on the page the price was read from, into the result's Shipping.
+func withShippingSelector(ctx context.Context, cssSelector string) context.Context {
+	if cssSelector == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, shippingSelectorKey{}, cssSelector)
+}
+
+// shippingRead collects the shipping text of one scrape.
+type shippingRead struct {
+	selector string
+	text     string
+}
+
+// startShippingRead returns ctx carrying a new shippingRead for the shipping
+// selector in ctx, and the read. Without a selector it returns ctx and nil.
+func startShippingRead(ctx context.Context) (context.Context, *shippingRead) {
+	selector, _ := ctx.Value(shippingSelectorKey{}).(string)
+	if selector == "" {
+		return ctx, nil
+	}
+	read := &shippingRead{selector: selector}
+	return context.WithValue(ctx, shippingReadKey{}, read), read
+}
+
+// String returns the shipping text read, empty for a nil read.
+func (r *shippingRead) String() string {
+	if r == nil {
+		return ""
+	}
+	return r.text
+}
+
+// readShippingHTTP stores the text the scrape's shipping selector selects in
+// doc, if the scrape has one.
+func readShippingHTTP(ctx context.Context, doc *goquery.Document) {
+	read, _ := ctx.Value(shippingReadKey{}).(*shippingRead)
+	if read == nil || doc == nil {
+		return
+	}
+	read.text = strings.TrimSpace(doc.Find(read.selector).First().Text())
+}
+
+// readShippingBrowser stores the text the scrape's shipping selector selects
+// on page, if the scrape has one. The price is read by then, so the page has
+// rendered and the element is not waited for.
+func readShippingBrowser(ctx context.Context, page playwright.Page) {
+	read, _ := ctx.Value(shippingReadKey{}).(*shippingRead)
+	if read == nil {
+		return
+	}
+	read.text = ""
+	element := page.Locator(read.selector).First()
+	if n, err := element.Count(); err != nil || n == 0 {
+		return
+	}
+	if text, err := element.InnerText(playwright.LocatorInnerTextOptions{Timeout: playwright.Float(2000)}); err == nil {
+		read.text = strings.TrimSpace(text)
+	}
+}
+
+// freeShippingWords mark shipping text that means it costs nothing.
+var freeShippingWords = map[string]bool{
+	"free": true, "gratis": true, "kostenlos": true, "gratuit": true, "gratuite": true, "gratuita": true, "gratuito": true,
+}
+
+// ParseShipping reads a shipping cost from text such as "+ $4.99 shipping",
+// "FREE delivery Tuesday, June 3" or "Versand: kostenlos". Text that starts
+// with a word for free, or mentions one and has no number, costs 0. ok is
+// false when text is neither a price nor free.
+func ParseShipping(text string, strategy ParseStrategy) (cost float64, ok bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > 0 && freeShippingWords[words[0]] {
+		return 0, true
+	}
+	if cost, err := ParsePriceWith(text, strategy); err == nil && cost >= 0 {
+		return cost, true
+	}
+	for _, word := range words {
+		if freeShippingWords[word] {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// shippingCost is the shipping cost result read for item. ok is false when
+// the item has no shipping selector or its text was not a cost.
+func (i Item) shippingCost(result ScrapeResult) (cost float64, ok bool) {
+	if i.ShippingSelector == "" {
+		return 0, false
+	}
+	return ParseShipping(result.Shipping, i.ParseStrategy)
+}
+
+// recordShipping stores the shipping cost and total price a check read for
+// the item, writing only when either changed.
+func (s *Scheduler) recordShipping(ctx context.Context, itemID string, shipping, total float64) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE tracked_items
+		SET shipping_price = $1, total_price = $2
+		WHERE id = $3 AND (shipping_price IS DISTINCT FROM $1 OR total_price IS DISTINCT FROM $2)
+	`, shipping, total, itemID)
+	return err
+}
+
+// totalText writes total the way NormalizePrice writes a price, in the
+// currency of priceText.
+func totalText(total float64, priceText, countryCode string) string {
+	amount := strconv.FormatFloat(total, 'f', 2, 64)
+	if currency := priceCurrency(priceText, countryCode); currency != "" {
+		return amount + " " + currency
+	}
+	return amount
+}
@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestParseShipping(t *testing.T) {
+	tests := []struct {
+		text   string
+		want   float64
+		wantOK bool
+	}{
+		{"FREE delivery Tuesday, June 3", 0, true},
+		{"Free Shipping", 0, true},
+		{"Versand: kostenlos", 0, true},
+		{"Livraison gratuite", 0, true},
+		{"+ $4.99 shipping", 4.99, true},
+		{"Shipping: 12,50 €", 12.50, true},
+		{"$0.00", 0, true},
+		{"Calculated at checkout", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		got, ok := ParseShipping(test.text, ParseAuto)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("ParseShipping(%q) = %v, %v, expected %v, %v", test.text, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+// shippingPage serves a page with price and shipping texts.
+func shippingPage(t *testing.T, price, shipping string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">` + price + `</div><div class="ship">` + shipping + `</div></body></html>`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestProcessItem_FreeShippingComparesTotal(t *testing.T) {
+	ts := shippingPage(t, "$19.99", "FREE delivery")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// The price held, but $5.00 shipping became free: the total dropped.
+	mock.ExpectExec(`SET shipping_price = \$1, total_price = \$2`).
+		WithArgs(0.0, 19.99, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectDefaultSettings(mock, "user-1")
+	mock.ExpectExec("SET last_price = \\$1").
+		WithArgs(19.99, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-1", sqlmock.AnyArg(), sqlmock.AnyArg(), "item-1", "24.99 USD", "19.99 USD", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectNoWebhook(mock, "user-1")
+	expectNotifiedPrice(mock, "item-1", 19.99)
+
+	s := New(db, localConfig())
+	s.processItem(context.Background(), Item{
+		ID:               "item-1",
+		UserID:           "user-1",
+		PriceText:        "$19.99",
+		ProductName:      "Widget",
+		PageURL:          ts.URL,
+		CSSSelector:      ".price",
+		ShippingSelector: ".ship",
+		TotalPrice:       sql.NullFloat64{Float64: 24.99, Valid: true},
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestProcessItem_ShippingCostOutweighsPriceDrop(t *testing.T) {
+	ts := shippingPage(t, "$15.00", "+ $6.50 shipping")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// The price dropped from $19.99, but with shipping the item now costs
+	// more than the $21.00 it did.
+	mock.ExpectExec(`SET shipping_price = \$1, total_price = \$2`).
+		WithArgs(6.5, 21.5, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tracked_items").
+		WithArgs("success", "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO scrape_log").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`SET price_text = \$1`).
+		WithArgs("$15.00", "15.00 USD", 15.0, "item-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s := New(db, localConfig())
+	s.processItem(context.Background(), Item{
+		ID:               "item-1",
+		UserID:           "user-1",
+		PriceText:        "$19.99",
+		ProductName:      "Widget",
+		PageURL:          ts.URL,
+		CSSSelector:      ".price",
+		ShippingSelector: ".ship",
+		TotalPrice:       sql.NullFloat64{Float64: 21.00, Valid: true},
+	})
+
+	// No notification insert is expected.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
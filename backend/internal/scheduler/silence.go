@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	// defaultSilenceWindow is how long no price drop may be notified, across
+	// all users, before the operator is alerted.
+	defaultSilenceWindow = 48 * time.Hour
+	// silenceBaselineWindows is how many windows before the last one the
+	// usual notification volume is measured over.
+	silenceBaselineWindows = 7
+	// silenceMinExpected is how many drops a window must usually see for
+	// none at all to be unexpected. Quieter instances are never alerted on.
+	silenceMinExpected = 5
+)
+
+// notificationVolume counts the price drop notifications of the last window
+// and of the silenceBaselineWindows windows before it.
+type notificationVolume struct {
+	recent, baseline int
+}
+
+// expected is the usual number of drops per window.
+func (v notificationVolume) expected() float64 {
+	return float64(v.baseline) / silenceBaselineWindows
+}
+
+// unexpectedSilence reports whether no drop was notified in the last window
+// although usually at least silenceMinExpected are.
+func (v notificationVolume) unexpectedSilence() bool {
+	return v.recent == 0 && v.expected() >= silenceMinExpected
+}
+
+// checkNotificationSilence is a dead man's switch over the whole instance:
+// when no price drop was notified to anyone over the last silenceWindow,
+// though the windows before it had plenty, something is likely broken for
+// every item (all scrapes failing, notifications failing to insert), and the
+// operator is alerted. The alert is logged as an error when the silence
+// starts and again once it ends.
+func (s *Scheduler) checkNotificationSilence(ctx context.Context) {
+	if s.silenceWindow <= 0 {
+		return
+	}
+	v, err := s.notificationVolume(ctx, s.now())
+	if err != nil {
+		slog.Error("Failed to check notification volume", "error", err)
+		return
+	}
+
+	silent := v.unexpectedSilence()
+	switch was := s.silent.Swap(silent); {
+	case silent && !was:
+		slog.Error("No price drops notified to any user; scraping or notifications may be broken", "window", s.silenceWindow, "expected", v.expected())
+	case silent:
+		slog.Warn("Still no price drops notified to any user", "window", s.silenceWindow, "expected", v.expected())
+	case was:
+		slog.Info("Price drop notifications resumed", "window", s.silenceWindow, "recent", v.recent)
+	}
+}
+
+// notificationVolume counts the price drop notifications of the window
+// before now and of the baseline windows before that.
+func (s *Scheduler) notificationVolume(ctx context.Context, now time.Time) (notificationVolume, error) {
+	recentSince := now.Add(-s.silenceWindow)
+	baselineSince := recentSince.Add(-silenceBaselineWindows * s.silenceWindow)
+
+	var v notificationVolume
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE created_at >= $1),
+			COUNT(*) FILTER (WHERE created_at < $1)
+		FROM notifications
+		WHERE type = 'price_drop' AND created_at >= $2 AND created_at < $3
+	`, recentSince, baselineSince, now).Scan(&v.recent, &v.baseline)
+	return v, err
+}
+
+// NotificationsSilent reports whether the last full pass found that no price
+// drop has been notified for unexpectedly long (see SilenceWindow).
+func (s *Scheduler) NotificationsSilent() bool {
+	return s.silent.Load()
+}
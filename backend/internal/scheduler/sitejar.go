@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+	"golang.org/x/net/publicsuffix"
+)
+
+// siteJarTTL is how long the cookies a site hands out are kept before its
+// jar starts over.
+const siteJarTTL = 30 * time.Minute
+
+// siteJars holds the cookies sites set during anonymous scrapes, one jar per
+// domain, so that a session or region cookie from one visit is sent on the
+// next by either scrape path. Items with cookies or headers of their own
+// never use them: what a site tells a logged-in user stays out of the jar.
+type siteJars struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	now  func() time.Time
+	jars map[string]siteJar
+}
+
+type siteJar struct {
+	jar       *cookiejar.Jar
+	expiresAt time.Time
+}
+
+func newSiteJars(ttl time.Duration) *siteJars {
+	return &siteJars{ttl: ttl, now: time.Now, jars: make(map[string]siteJar)}
+}
+
+// get returns the jar for pageURL's domain, starting a new one when there is
+// none or it has expired. Expired jars of other domains are dropped too.
+func (j *siteJars) get(pageURL string) *cookiejar.Jar {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := j.now()
+	for domain, sj := range j.jars {
+		if !now.Before(sj.expiresAt) {
+			delete(j.jars, domain)
+		}
+	}
+	domain := DomainOf(pageURL)
+	if sj, ok := j.jars[domain]; ok {
+		return sj.jar
+	}
+	// cookiejar.New only fails on invalid options.
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	j.jars[domain] = siteJar{jar: jar, expiresAt: now.Add(j.ttl)}
+	return jar
+}
+
+// siteJar returns the shared jar for pageURL, or nil when the item brings
+// its own cookies or headers.
+func (s *Scraper) siteJar(pageURL string, cookies []Cookie, headers map[string]string) http.CookieJar {
+	if len(cookies) > 0 || len(headers) > 0 {
+		return nil
+	}
+	return s.jars.get(pageURL)
+}
+
+// jarCookieCount counts the cookies jar would send to pageURL.
+func jarCookieCount(jar http.CookieJar, pageURL string) int {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return 0
+	}
+	return len(jar.Cookies(u))
+}
+
+// jarToPlaywright converts the cookies jar would send to pageURL for
+// BrowserContext.AddCookies.
+func jarToPlaywright(jar http.CookieJar, pageURL string) []playwright.OptionalCookie {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	var out []playwright.OptionalCookie
+	for _, c := range jar.Cookies(u) {
+		out = append(out, playwright.OptionalCookie{Name: c.Name, Value: c.Value, URL: playwright.String(pageURL)})
+	}
+	return out
+}
+
+// playwrightToJar stores the cookies a browser context holds for pageURL in
+// jar, so that the next HTTP scrape sends them.
+func playwrightToJar(jar http.CookieJar, pageURL string, cookies []playwright.Cookie) {
+	u, err := url.Parse(pageURL)
+	if err != nil || len(cookies) == 0 {
+		return
+	}
+	httpCookies := make([]*http.Cookie, len(cookies))
+	for i, c := range cookies {
+		httpCookies[i] = &http.Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Secure: c.Secure, HttpOnly: c.HttpOnly}
+		// Playwright marks domain cookies with a leading dot; the others
+		// belong to the page's host only.
+		if strings.HasPrefix(c.Domain, ".") {
+			httpCookies[i].Domain = c.Domain
+		}
+		if c.Expires > 0 {
+			httpCookies[i].Expires = time.Unix(int64(c.Expires), 0)
+		}
+	}
+	jar.SetCookies(u, httpCookies)
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// regionShop redirects visitors without a region cookie to itself, setting
+// one, and only shows the price to requests carrying it.
+func regionShop(redirects *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("region"); err != nil {
+			redirects.Add(1)
+			http.SetCookie(w, &http.Cookie{Name: "region", Value: "eu", Path: "/"})
+			http.Redirect(w, r, r.URL.Path, http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><div class="price">€18.00</div></body></html>`))
+	}))
+}
+
+func TestScrapeDetailed_KeepsSiteCookies(t *testing.T) {
+	var redirects atomic.Int32
+	ts := regionShop(&redirects)
+	defer ts.Close()
+
+	scraper := httpScraper(false)
+	for i := 0; i < 2; i++ {
+		result, err := scraper.ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL + "/p/1", CSSSelector: ".price"})
+		if err != nil {
+			t.Fatalf("scrape %d: ScrapeDetailed failed: %v", i+1, err)
+		}
+		if result.Text != "€18.00" {
+			t.Errorf("scrape %d: Expected €18.00, got %q", i+1, result.Text)
+		}
+	}
+	if got := redirects.Load(); got != 1 {
+		t.Errorf("Expected the region cookie to be kept after the first redirect, got %d redirects", got)
+	}
+}
+
+func TestScrapeDetailed_RetriesWithNewCookies(t *testing.T) {
+	var requests atomic.Int32
+	// The first visit gets a consent page that sets the session cookie.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		if _, err := r.Cookie("session"); err != nil {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			w.Write([]byte(`<html><body>Accept cookies to continue</body></html>`))
+			return
+		}
+		w.Write([]byte(`<html><body><div class="price">$31.00</div></body></html>`))
+	}))
+	defer ts.Close()
+
+	result, err := httpScraper(false).ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL + "/p/1", CSSSelector: ".price"})
+	if err != nil {
+		t.Fatalf("ScrapeDetailed failed: %v", err)
+	}
+	if result.Text != "$31.00" || result.Method != "http" {
+		t.Errorf("Expected $31.00 over HTTP, got %q via %s", result.Text, result.Method)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Expected one retry with the new cookie, got %d requests", got)
+	}
+}
+
+func TestScrapeDetailed_ItemCookiesStayOutOfSiteJar(t *testing.T) {
+	var redirects atomic.Int32
+	ts := regionShop(&redirects)
+	defer ts.Close()
+
+	scraper := httpScraper(false)
+	if _, err := scraper.ScrapeDetailed(context.Background(), ScrapeRequest{URL: ts.URL + "/p/1", CSSSelector: ".price", Cookies: []Cookie{{Name: "session", Value: "s3cret"}}}); err != nil {
+		t.Fatalf("ScrapeDetailed failed: %v", err)
+	}
+	u, _ := url.Parse(ts.URL)
+	if cookies := scraper.jars.get(ts.URL).Cookies(u); len(cookies) != 0 {
+		t.Errorf("Expected an item with its own cookies not to fill the site jar, got %v", cookies)
+	}
+}
+
+func TestSiteJars_PartitionedAndExpire(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	jars := newSiteJars(time.Hour)
+	jars.now = func() time.Time { return now }
+
+	shop := jars.get("https://www.shop.example.com/p/1")
+	if jars.get("https://shop.example.com/p/2") != shop {
+		t.Error("Expected pages of one domain to share a jar")
+	}
+	if jars.get("https://other.example.com/p/1") == shop {
+		t.Error("Expected each domain to have its own jar")
+	}
+
+	u, _ := url.Parse("https://shop.example.com/")
+	shop.SetCookies(u, []*http.Cookie{{Name: "region", Value: "eu"}})
+	now = now.Add(time.Hour)
+	if cookies := jars.get("https://shop.example.com/p/1").Cookies(u); len(cookies) != 0 {
+		t.Errorf("Expected the jar to be cleared after its TTL, got %v", cookies)
+	}
+	if len(jars.jars) != 1 {
+		t.Errorf("Expected expired jars to be dropped, got %d", len(jars.jars))
+	}
+}
@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+// Variant is a separately priced option (size, color, ...) tracked under an
+// item. Variants share the item's page but have their own selector, price
+// and history.
+type Variant struct {
+	ID          int64   `json:"id"`
+	Label       string  `json:"label"`
+	CSSSelector string  `json:"css"`
+	XPath       string  `json:"xpath"`
+	PriceText   *string `json:"price"`
+}
+
+// variantsColumn aggregates an item's variants into one JSON column so that
+// they arrive with the item in the same keyset query.
+const variantsColumn = `(
+			SELECT json_agg(json_build_object('id', v.id, 'label', v.label, 'css', v.css_selector, 'xpath', v.xpath, 'price', v.price_text) ORDER BY v.id)
+			FROM item_variants v
+			WHERE v.item_id = tracked_items.id
+		) AS variants`
+
+// parseVariants decodes variantsColumn. NULL (no variants) yields nil.
+func parseVariants(data []byte) ([]Variant, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var variants []Variant
+	err := json.Unmarshal(data, &variants)
+	return variants, err
+}
+
+// processVariants scrapes each of the item's variants, sharing the run's memo
+// so a variant selector used by several items is still fetched once.
+func (s *Scheduler) processVariants(ctx context.Context, item Item, memo *scrapeMemo) {
+	for _, v := range item.Variants {
+		// The site's adapter reads one price for the page, so each variant's
+		// own selector comes first.
+		target := Item{PageURL: item.PageURL, CSSSelector: v.CSSSelector, XPath: v.XPath, Frame: item.Frame, Selection: item.Selection, AdapterOrder: AdapterLast, AcceptLanguage: item.AcceptLanguage, CountryCode: item.CountryCode, Cookies: item.Cookies, Headers: item.Headers, Proxy: item.Proxy, Profile: item.Profile, MaxDuration: item.MaxDuration}
+		entry := memo.get(target.scrapeSignature())
+		entry.once.Do(func() {
+			ctx, cancel := target.scrapeContext(ctx)
+			defer cancel()
+			entry.result, entry.err = s.scraper.ScrapeDetailed(ctx, target.scrapeRequest())
+		})
+		s.applyVariantResult(ctx, item, v, entry.result, entry.err)
+	}
+}
+
+// applyVariantResult mirrors applyScrapeResult for a single variant: the
+// first price becomes the baseline, any change is recorded in the variant's
+// history, and a drop notifies the owner with the variant label.
+func (s *Scheduler) applyVariantResult(ctx context.Context, item Item, v Variant, result ScrapeResult, err error) {
+	if err != nil {
+		slog.Error("Failed to scrape variant price", "id", item.ID, "variant", v.Label, "error", err)
+		s.setVariantStatus(ctx, v.ID, "failed")
+		return
+	}
+	newPriceText := result.Text
+
+	newPrice, err := ParsePriceWith(newPriceText, item.ParseStrategy)
+	if err != nil {
+		slog.Warn("Failed to parse variant price", "id", item.ID, "variant", v.Label, "price", newPriceText, "error", err)
+		s.setVariantStatus(ctx, v.ID, "success")
+		return
+	}
+
+	if v.PriceText == nil {
+		slog.Info("Recorded first variant price", "id", item.ID, "variant", v.Label, "price", newPriceText)
+		s.recordVariantPrice(ctx, v.ID, newPriceText)
+		return
+	}
+	oldPrice, err := ParsePriceWith(*v.PriceText, item.ParseStrategy)
+	if err != nil {
+		slog.Info("Failed to parse old variant price, adopting new price as baseline", "id", item.ID, "variant", v.Label, "old", *v.PriceText, "new", newPriceText)
+		s.recordVariantPrice(ctx, v.ID, newPriceText)
+		return
+	}
+
+	switch {
+	case newPrice < oldPrice:
+		slog.Info("Variant price drop detected!", "product", item.ProductName, "variant", v.Label, "old", oldPrice, "new", newPrice)
+		s.recordVariantPrice(ctx, v.ID, newPriceText)
+		n := Notification{UserID: item.UserID, ItemID: item.ID, ProductName: item.ProductName + " (" + v.Label + ")", OldPrice: *v.PriceText, NewPrice: newPriceText, OccurredAt: s.now(), Severity: s.severity.dropSeverity(oldPrice, newPrice), DropPercent: dropPercent(oldPrice, newPrice), VariantID: v.ID, Channel: item.NotificationChannel}
+		if err := s.notifier.Notify(ctx, n); err != nil {
+			slog.Error("Failed to send notification", "error", err)
+		}
+	case newPrice > oldPrice:
+		slog.Info("Variant price increase detected!", "product", item.ProductName, "variant", v.Label, "old", oldPrice, "new", newPrice)
+		s.recordVariantPrice(ctx, v.ID, newPriceText)
+	default:
+		s.setVariantStatus(ctx, v.ID, "success")
+	}
+}
+
+// recordVariantPrice stores a new price for the variant and appends it to the
+// variant's price history.
+func (s *Scheduler) recordVariantPrice(ctx context.Context, variantID int64, priceText string) {
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE item_variants
+		SET price_text = $1, last_scrape_status = 'success', updated_at = NOW()
+		WHERE id = $2
+	`, priceText, variantID); err != nil {
+		slog.Error("Failed to update variant price", "variant_id", variantID, "error", err)
+		return
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO variant_price_history (variant_id, price_text)
+		VALUES ($1, $2)
+	`, variantID, priceText); err != nil {
+		slog.Error("Failed to record variant price history", "variant_id", variantID, "error", err)
+	}
+}
+
+func (s *Scheduler) setVariantStatus(ctx context.Context, variantID int64, status string) {
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE item_variants
+		SET last_scrape_status = $1, updated_at = NOW()
+		WHERE id = $2 AND last_scrape_status IS DISTINCT FROM $1
+	`, status, variantID); err != nil {
+		slog.Error("Failed to update variant status", "variant_id", variantID, "error", err)
+	}
+}
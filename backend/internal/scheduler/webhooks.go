@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// webhookMaxAttempts is the number of deliveries tried (including the
+	// first) before a webhook delivery is marked failed.
+	webhookMaxAttempts = 6
+	// webhookBaseBackoff is the wait after the first failed attempt; it
+	// doubles with every further failure, up to webhookMaxBackoff.
+	webhookBaseBackoff = time.Minute
+	webhookMaxBackoff  = 6 * time.Hour
+	// webhookRetryBatch bounds the deliveries retried on one tick.
+	webhookRetryBatch = 100
+	webhookTimeout    = 10 * time.Second
+)
+
+// WebhookEvent is the JSON body POSTed to a user's webhook.
+type WebhookEvent struct {
+	Event       string    `json:"event"`
+	ItemID      string    `json:"itemId"`
+	ProductName string    `json:"productName"`
+	OldPrice    string    `json:"oldPrice"`
+	NewPrice    string    `json:"newPrice"`
+	OccurredAt  time.Time `json:"occurredAt"`
+}
+
+// webhookBackoff returns how long to wait before the next attempt once
+// attempts deliveries have failed.
+func webhookBackoff(attempts int) time.Duration {
+	d := webhookBaseBackoff
+	for i := 1; i < attempts && d < webhookMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, webhookMaxBackoff)
+}
+
+// postWebhook delivers payload to url, treating any non-2xx response as a
+// failure.
+func (s *Scheduler) postWebhook(ctx context.Context, url string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PriceTrack-Webhook/1.0")
+
+	resp, err := s.webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendWebhook delivers event to the user's webhook, if they have one. Every
+// delivery is recorded in webhook_deliveries; a failed one is left pending for
+// retryWebhooks so that an endpoint that is briefly down does not lose alerts.
+// If deliverAfter is in the future (the user's quiet hours), the delivery is
+// only recorded as pending until then.
+func (s *Scheduler) sendWebhook(ctx context.Context, userID string, event WebhookEvent, deliverAfter time.Time) {
+	var url string
+	err := s.db.QueryRowContext(ctx, `SELECT url FROM user_webhooks WHERE user_id = $1`, userID).Scan(&url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to look up webhook", "user_id", userID, "error", err)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to encode webhook event", "error", err)
+		return
+	}
+
+	now := s.now()
+	attempts, status, lastError, deliveredAt, nextAttemptAt := 1, "delivered", "", sql.NullTime{Time: now, Valid: true}, sql.NullTime{}
+	if deliverAfter.After(now) {
+		slog.Info("Deferring webhook until quiet hours end", "user_id", userID, "until", deliverAfter)
+		attempts, status, deliveredAt = 0, "pending", sql.NullTime{}
+		nextAttemptAt = sql.NullTime{Time: deliverAfter, Valid: true}
+	} else if err := s.postWebhook(ctx, url, payload); err != nil {
+		slog.Warn("Webhook delivery failed, will retry", "user_id", userID, "error", err)
+		status, lastError, deliveredAt = "pending", err.Error(), sql.NullTime{}
+		nextAttemptAt = sql.NullTime{Time: now.Add(webhookBackoff(1)), Valid: true}
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (user_id, url, payload, status, attempts, last_error, next_attempt_at, delivered_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+	`, userID, url, payload, status, attempts, lastError, nextAttemptAt, deliveredAt); err != nil {
+		slog.Error("Failed to record webhook delivery", "user_id", userID, "error", err)
+	}
+}
+
+// pendingDelivery is a webhook delivery due for another attempt.
+type pendingDelivery struct {
+	id       int64
+	url      string
+	payload  []byte
+	attempts int
+}
+
+// retryWebhooks re-attempts pending deliveries whose backoff has elapsed. A
+// delivery that fails its webhookMaxAttempts-th attempt is marked failed.
+func (s *Scheduler) retryWebhooks(ctx context.Context) {
+	now := s.now()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, url, payload, attempts
+		FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at
+		LIMIT $2
+	`, now, webhookRetryBatch)
+	if err != nil {
+		slog.Error("Failed to query pending webhook deliveries", "error", err)
+		return
+	}
+	var due []pendingDelivery
+	for rows.Next() {
+		var d pendingDelivery
+		if err := rows.Scan(&d.id, &d.url, &d.payload, &d.attempts); err != nil {
+			slog.Error("Failed to scan webhook delivery", "error", err)
+			continue
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+
+	for _, d := range due {
+		attempts := d.attempts + 1
+		err := s.postWebhook(ctx, d.url, d.payload)
+		switch {
+		case err == nil:
+			_, err = s.db.ExecContext(ctx, `
+				UPDATE webhook_deliveries
+				SET status = 'delivered', attempts = $1, last_error = NULL, next_attempt_at = NULL, delivered_at = $2, updated_at = $2
+				WHERE id = $3
+			`, attempts, now, d.id)
+		case attempts >= webhookMaxAttempts:
+			slog.Warn("Webhook delivery failed permanently", "id", d.id, "attempts", attempts, "error", err)
+			_, err = s.db.ExecContext(ctx, `
+				UPDATE webhook_deliveries
+				SET status = 'failed', attempts = $1, last_error = $2, next_attempt_at = NULL, updated_at = $3
+				WHERE id = $4
+			`, attempts, err.Error(), now, d.id)
+		default:
+			slog.Info("Webhook delivery failed, backing off", "id", d.id, "attempts", attempts, "error", err)
+			_, err = s.db.ExecContext(ctx, `
+				UPDATE webhook_deliveries
+				SET attempts = $1, last_error = $2, next_attempt_at = $3, updated_at = $4
+				WHERE id = $5
+			`, attempts, err.Error(), now.Add(webhookBackoff(attempts)), now, d.id)
+		}
+		if err != nil {
+			slog.Error("Failed to update webhook delivery", "id", d.id, "error", err)
+		}
+	}
+	if len(due) > 0 {
+		slog.Info("Retried webhook deliveries", "count", len(due))
+	}
+}
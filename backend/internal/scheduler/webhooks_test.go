@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var webhookDeliveryColumns = []string{"id", "url", "payload", "attempts"}
+
+// expectNoWebhook expects the webhook lookup for a user without one.
+func expectNoWebhook(mock sqlmock.Sqlmock, userID string) {
+	mock.ExpectQuery("SELECT url FROM user_webhooks").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"url"}))
+}
+
+// expectNoPendingWebhooks expects the retry query at the start of a full run.
+func expectNoPendingWebhooks(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("FROM webhook_deliveries").
+		WillReturnRows(sqlmock.NewRows(webhookDeliveryColumns))
+}
+
+// webhookEndpoint returns a server that answers with the given status codes in
+// turn, counting the requests it receives.
+func webhookEndpoint(t *testing.T, codes ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		var event WebhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Failed to decode webhook body: %v", err)
+		}
+		w.WriteHeader(codes[min(n, len(codes))-1])
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{5, 16 * time.Minute},
+		{20, webhookMaxBackoff},
+	}
+
+	for _, test := range tests {
+		if got := webhookBackoff(test.attempts); got != test.expected {
+			t.Errorf("webhookBackoff(%d) = %v, expected %v", test.attempts, got, test.expected)
+		}
+	}
+}
+
+func TestSendWebhook_Delivered(t *testing.T) {
+	ts, calls := webhookEndpoint(t, http.StatusOK)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New(db, localConfig())
+	s.now = func() time.Time { return now }
+
+	mock.ExpectQuery("SELECT url FROM user_webhooks").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"url"}).AddRow(ts.URL))
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WithArgs("user-1", ts.URL, sqlmock.AnyArg(), "delivered", 1, "", nil, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	s.sendWebhook(context.Background(), "user-1", WebhookEvent{Event: "price_drop", ItemID: "item-1"}, time.Time{})
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 webhook call, got %d", calls.Load())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestWebhookRetryLifecycle(t *testing.T) {
+	// The endpoint is down for the first delivery and the first retry, then
+	// recovers.
+	ts, calls := webhookEndpoint(t, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusNoContent)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New(db, localConfig())
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// The failed first attempt is persisted as pending with a backoff.
+	mock.ExpectQuery("SELECT url FROM user_webhooks").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"url"}).AddRow(ts.URL))
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WithArgs("user-1", ts.URL, sqlmock.AnyArg(), "pending", 1, "bad status code: 503", now.Add(time.Minute), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.sendWebhook(ctx, "user-1", WebhookEvent{Event: "price_drop", ItemID: "item-1"}, time.Time{})
+
+	// The next tick retries it and fails again: the backoff doubles.
+	now = now.Add(time.Hour)
+	mock.ExpectQuery("FROM webhook_deliveries").
+		WithArgs(now, webhookRetryBatch).
+		WillReturnRows(sqlmock.NewRows(webhookDeliveryColumns).AddRow(1, ts.URL, []byte(`{}`), 1))
+	mock.ExpectExec("UPDATE webhook_deliveries\\s+SET attempts").
+		WithArgs(2, "bad status code: 502", now.Add(2*time.Minute), now, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.retryWebhooks(ctx)
+
+	// The tick after that delivers it.
+	now = now.Add(time.Hour)
+	mock.ExpectQuery("FROM webhook_deliveries").
+		WithArgs(now, webhookRetryBatch).
+		WillReturnRows(sqlmock.NewRows(webhookDeliveryColumns).AddRow(1, ts.URL, []byte(`{}`), 2))
+	mock.ExpectExec("SET status = 'delivered'").
+		WithArgs(3, now, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.retryWebhooks(ctx)
+
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 webhook calls, got %d", calls.Load())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestRetryWebhooks_GivesUpAfterMaxAttempts(t *testing.T) {
+	ts, _ := webhookEndpoint(t, http.StatusInternalServerError)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New(db, localConfig())
+	s.now = func() time.Time { return now }
+
+	mock.ExpectQuery("FROM webhook_deliveries").
+		WillReturnRows(sqlmock.NewRows(webhookDeliveryColumns).AddRow(7, ts.URL, []byte(`{}`), webhookMaxAttempts-1))
+	mock.ExpectExec("SET status = 'failed'").
+		WithArgs(webhookMaxAttempts, "bad status code: 500", now, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s.retryWebhooks(context.Background())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+// Package secretbox encrypts small values stored in the database, such as the
+// cookies users supply for scraping pages behind a login.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the length of a key in bytes (AES-256).
+const KeySize = 32
+
+// ErrDecrypt means a value was not sealed with this key or was altered.
+var ErrDecrypt = errors.New("secretbox: decryption failed")
+
+// Box seals and opens values with AES-256-GCM. Each sealed value carries its
+// own random nonce, so a Box is safe for concurrent use.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a Box using key, which must be KeySize bytes.
+func New(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secretbox: key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plaintext, returning the nonce followed by the ciphertext.
+func (b *Box) Seal(plaintext []byte) []byte {
+	nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(plaintext)+b.aead.Overhead())
+	rand.Read(nonce)
+	return b.aead.Seal(nonce, nonce, plaintext, nil)
+}
+
+// Open decrypts a value produced by Seal.
+func (b *Box) Open(sealed []byte) ([]byte, error) {
+	n := b.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := b.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
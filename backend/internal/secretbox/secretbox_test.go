@@ -0,0 +1,47 @@
+package secretbox
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestBox_RoundTrip(t *testing.T) {
+	box, err := New(bytes.Repeat([]byte{1}, KeySize))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	a, b := box.Seal([]byte("session=abc")), box.Seal([]byte("session=abc"))
+	if bytes.Equal(a, b) {
+		t.Error("Expected sealing the same value twice to differ")
+	}
+	if bytes.Contains(a, []byte("session=abc")) {
+		t.Error("Expected the sealed value not to contain the plaintext")
+	}
+	got, err := box.Open(a)
+	if err != nil || string(got) != "session=abc" {
+		t.Errorf("Expected session=abc, got %q, %v", got, err)
+	}
+}
+
+func TestBox_Open_Rejects(t *testing.T) {
+	box, _ := New(bytes.Repeat([]byte{1}, KeySize))
+	other, _ := New(bytes.Repeat([]byte{2}, KeySize))
+	sealed := box.Seal([]byte("session=abc"))
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+
+	for name, value := range map[string][]byte{"other key": other.Seal([]byte("session=abc")), "tampered": tampered, "short": {1, 2, 3}} {
+		if _, err := box.Open(value); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: expected ErrDecrypt, got %v", name, err)
+		}
+	}
+}
+
+func TestNew_KeySize(t *testing.T) {
+	if _, err := New(make([]byte, 16)); err == nil {
+		t.Error("Expected a 16-byte key to be rejected")
+	}
+}
@@ -0,0 +1,85 @@
+package settings
+
+import "time"
+
+// DigestHour is the local hour at which digests are sent.
+const DigestHour = 8
+
+// Location returns the user's time zone, or UTC if it cannot be loaded.
+// Everything time-of-day related is computed in it, so local times stay put
+// across daylight saving changes.
+func (s Settings) Location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// clockMinutes parses "HH:MM" into minutes after midnight.
+func clockMinutes(v string) (int, bool) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// QuietUntil reports whether t falls within the user's quiet hours and, if
+// so, when they end. A window whose end is before its start runs past
+// midnight; one whose start equals its end is empty.
+func (s Settings) QuietUntil(t time.Time) (time.Time, bool) {
+	start, ok := clockMinutes(s.QuietHoursStart)
+	if !ok {
+		return time.Time{}, false
+	}
+	end, ok := clockMinutes(s.QuietHoursEnd)
+	if !ok || start == end {
+		return time.Time{}, false
+	}
+
+	loc := s.Location()
+	local := t.In(loc)
+	now := local.Hour()*60 + local.Minute()
+
+	var endsTomorrow bool
+	switch {
+	case start < end && now >= start && now < end:
+	case start > end && now < end:
+	case start > end && now >= start:
+		endsTomorrow = true
+	default:
+		return time.Time{}, false
+	}
+
+	day := local.Day()
+	if endsTomorrow {
+		day++
+	}
+	return time.Date(local.Year(), local.Month(), day, end/60, end%60, 0, 0, loc), true
+}
+
+// lastDigestAt returns the most recent scheduled digest time at or before
+// now: DigestHour local time, on Mondays for weekly digests.
+func (s Settings) lastDigestAt(now time.Time) time.Time {
+	loc := s.Location()
+	local := now.In(loc)
+	y, m, d := local.Date()
+	if local.Hour() < DigestHour {
+		d--
+	}
+	if s.DigestFrequency == "weekly" {
+		weekday := time.Date(y, m, d, 12, 0, 0, 0, loc).Weekday()
+		d -= (int(weekday) - int(time.Monday) + 7) % 7
+	}
+	return time.Date(y, m, d, DigestHour, 0, 0, 0, loc)
+}
+
+// DigestDue reports whether a digest should be sent at now, given when the
+// last one went out (the zero time if never).
+func (s Settings) DigestDue(now, lastSent time.Time) bool {
+	if s.DigestFrequency != "daily" && s.DigestFrequency != "weekly" {
+		return false
+	}
+	return lastSent.Before(s.lastDigestAt(now))
+}
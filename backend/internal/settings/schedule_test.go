@@ -0,0 +1,107 @@
+package settings
+
+import (
+	"testing"
+	"time"
+)
+
+func utc(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// New York moves from EST (UTC-5) to EDT (UTC-4) at 2025-03-09 02:00 local
+// and back at 2025-11-02 02:00 local. Each case would come out the other way
+// if the offset from before the change were applied after it.
+func TestQuietUntil_DST(t *testing.T) {
+	s := Defaults()
+	s.Timezone = "America/New_York"
+	s.QuietHoursStart, s.QuietHoursEnd = "22:00", "07:00"
+
+	tests := []struct {
+		name      string
+		now       string
+		wantQuiet bool
+		wantUntil string
+	}{
+		{"spring forward, 06:30 EDT", "2025-03-09T10:30:00Z", true, "2025-03-09T11:00:00Z"},
+		{"spring forward, 07:30 EDT", "2025-03-09T11:30:00Z", false, ""},
+		{"fall back, 06:30 EST", "2025-11-02T11:30:00Z", true, "2025-11-02T12:00:00Z"},
+		{"fall back, 07:30 EST", "2025-11-02T12:30:00Z", false, ""},
+		{"evening before spring forward", "2025-03-09T03:30:00Z", true, "2025-03-09T11:00:00Z"},
+		{"evening before fall back", "2025-11-02T02:30:00Z", true, "2025-11-02T12:00:00Z"},
+	}
+
+	for _, test := range tests {
+		until, quiet := s.QuietUntil(utc(test.now))
+		if quiet != test.wantQuiet {
+			t.Errorf("%s: quiet = %v, expected %v", test.name, quiet, test.wantQuiet)
+			continue
+		}
+		if quiet && !until.Equal(utc(test.wantUntil)) {
+			t.Errorf("%s: quiet until %v, expected %s", test.name, until.UTC(), test.wantUntil)
+		}
+	}
+}
+
+func TestQuietUntil_Windows(t *testing.T) {
+	s := Defaults()
+	now := utc("2025-06-01T12:30:00Z")
+
+	if _, quiet := s.QuietUntil(now); quiet {
+		t.Error("Expected no quiet hours by default")
+	}
+
+	s.QuietHoursStart, s.QuietHoursEnd = "12:00", "13:00"
+	if until, quiet := s.QuietUntil(now); !quiet || !until.Equal(utc("2025-06-01T13:00:00Z")) {
+		t.Errorf("Expected quiet until 13:00 in a same-day window, got %v %v", until, quiet)
+	}
+
+	s.QuietHoursStart, s.QuietHoursEnd = "12:00", "12:00"
+	if _, quiet := s.QuietUntil(now); quiet {
+		t.Error("Expected an empty window to never be quiet")
+	}
+}
+
+func TestDigestDue_DST(t *testing.T) {
+	daily := Defaults()
+	daily.Timezone = "America/New_York"
+	daily.DigestFrequency = "daily"
+
+	weekly := daily
+	weekly.DigestFrequency = "weekly"
+
+	tests := []struct {
+		name     string
+		settings Settings
+		now      string
+		lastSent string
+		want     bool
+	}{
+		// 08:00 EDT is 12:00 UTC; under EST it would be 13:00 UTC.
+		{"spring forward, 08:30 EDT", daily, "2025-03-09T12:30:00Z", "2025-03-08T13:00:00Z", true},
+		{"spring forward, 07:30 EDT", daily, "2025-03-09T11:30:00Z", "2025-03-08T13:00:00Z", false},
+		// 08:00 EST is 13:00 UTC; under EDT it would be 12:00 UTC.
+		{"fall back, 07:30 EST", daily, "2025-11-02T12:30:00Z", "2025-11-01T12:00:00Z", false},
+		{"fall back, 08:00 EST", daily, "2025-11-02T13:00:00Z", "2025-11-01T12:00:00Z", true},
+		{"already sent today", daily, "2025-11-02T20:00:00Z", "2025-11-02T13:00:00Z", false},
+		{"never sent", daily, "2025-11-02T20:00:00Z", "", true},
+		// 2025-03-10 is the Monday after the change.
+		{"weekly, Monday 08:30 EDT", weekly, "2025-03-10T12:30:00Z", "2025-03-03T13:00:00Z", true},
+		{"weekly, Sunday", weekly, "2025-03-09T20:00:00Z", "2025-03-03T13:00:00Z", false},
+		{"off", Defaults(), "2025-03-10T12:30:00Z", "", false},
+	}
+
+	for _, test := range tests {
+		var lastSent time.Time
+		if test.lastSent != "" {
+			lastSent = utc(test.lastSent)
+		}
+		if got := test.settings.DigestDue(utc(test.now), lastSent); got != test.want {
+			t.Errorf("%s: DigestDue = %v, expected %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Package settings stores per-user preferences. Users only store the values
+// they changed; everything else falls back to Defaults.
+package settings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/text/currency"
+
+	"price-track-backend/internal/cache"
+)
+
+// Settings are a user's effective preferences.
+type Settings struct {
+	// Currency is the ISO 4217 code prices are shown in.
+	Currency string `json:"currency"`
+	// Timezone is an IANA time zone name such as "Europe/Berlin".
+	Timezone string `json:"timezone"`
+	// QuietHoursStart and QuietHoursEnd ("HH:MM" in Timezone) bound the
+	// window in which no notifications are sent. Both are empty when quiet
+	// hours are off.
+	QuietHoursStart string `json:"quietHoursStart"`
+	QuietHoursEnd   string `json:"quietHoursEnd"`
+	// DigestFrequency is "off", "daily" or "weekly".
+	DigestFrequency string `json:"digestFrequency"`
+	// DropThresholdPercent is the smallest drop, in percent of the price it
+	// is judged against, that is notified.
+	DropThresholdPercent float64 `json:"dropThresholdPercent"`
+	// EmailPriceDrops is whether price drops are emailed to the verified
+	// notification address.
+	EmailPriceDrops bool `json:"emailPriceDrops"`
+	// NotificationChannel is where price drops are delivered besides the
+	// in-app notification, unless an item sets its own.
+	NotificationChannel NotificationChannel `json:"notificationChannel"`
+}
+
+// NotificationChannel picks which deliveries a price drop gets besides the
+// in-app notification, which is always created.
+type NotificationChannel string
+
+const (
+	// NotifyAll delivers through the webhook, email and every other
+	// configured channel. It is the default.
+	NotifyAll NotificationChannel = "all"
+	// NotifyInApp only creates the in-app notification.
+	NotifyInApp NotificationChannel = "in_app"
+	// NotifyEmail also emails the drop.
+	NotifyEmail NotificationChannel = "email"
+	// NotifyWebhook also posts the drop to the user's webhook.
+	NotifyWebhook NotificationChannel = "webhook"
+)
+
+// ValidNotificationChannel reports whether s names a known channel.
+func ValidNotificationChannel(s string) bool {
+	switch NotificationChannel(s) {
+	case NotifyAll, NotifyInApp, NotifyEmail, NotifyWebhook:
+		return true
+	}
+	return false
+}
+
+// Defaults returns the settings of a user who never changed anything.
+func Defaults() Settings {
+	return Settings{
+		Currency:            "USD",
+		Timezone:            "UTC",
+		DigestFrequency:     "off",
+		EmailPriceDrops:     true,
+		NotificationChannel: NotifyAll,
+	}
+}
+
+// Update is a partial settings document. Nil fields are left unchanged.
+type Update struct {
+	Currency             *string  `json:"currency"`
+	Timezone             *string  `json:"timezone"`
+	QuietHoursStart      *string  `json:"quietHoursStart"`
+	QuietHoursEnd        *string  `json:"quietHoursEnd"`
+	DigestFrequency      *string  `json:"digestFrequency"`
+	DropThresholdPercent *float64 `json:"dropThresholdPercent"`
+	EmailPriceDrops      *bool    `json:"emailPriceDrops"`
+	NotificationChannel  *string  `json:"notificationChannel"`
+}
+
+var digestFrequencies = map[string]bool{"off": true, "daily": true, "weekly": true}
+
+// Validate checks every field that is set. Currency codes are normalized to
+// upper case in place.
+func (u *Update) Validate() error {
+	if u.Currency != nil {
+		unit, err := currency.ParseISO(*u.Currency)
+		if err != nil {
+			return fmt.Errorf("currency must be an ISO 4217 code")
+		}
+		code := unit.String()
+		u.Currency = &code
+	}
+	if u.Timezone != nil {
+		// LoadLocation accepts "" and "Local", which name the server's zone.
+		if *u.Timezone == "" || *u.Timezone == "Local" {
+			return fmt.Errorf("timezone must be an IANA time zone name")
+		}
+		if _, err := time.LoadLocation(*u.Timezone); err != nil {
+			return fmt.Errorf("timezone must be an IANA time zone name")
+		}
+	}
+	if (u.QuietHoursStart == nil) != (u.QuietHoursEnd == nil) {
+		return fmt.Errorf("quietHoursStart and quietHoursEnd must be set together")
+	}
+	if u.QuietHoursStart != nil {
+		if (*u.QuietHoursStart == "") != (*u.QuietHoursEnd == "") {
+			return fmt.Errorf("quietHoursStart and quietHoursEnd must both be empty to turn quiet hours off")
+		}
+		for _, v := range []string{*u.QuietHoursStart, *u.QuietHoursEnd} {
+			if _, err := time.Parse("15:04", v); v != "" && err != nil {
+				return fmt.Errorf("quiet hours must be HH:MM")
+			}
+		}
+	}
+	if u.DigestFrequency != nil && !digestFrequencies[*u.DigestFrequency] {
+		return fmt.Errorf("digestFrequency must be one of off, daily or weekly")
+	}
+	if u.DropThresholdPercent != nil && (*u.DropThresholdPercent < 0 || *u.DropThresholdPercent > 100) {
+		return fmt.Errorf("dropThresholdPercent must be between 0 and 100")
+	}
+	if u.NotificationChannel != nil && !ValidNotificationChannel(*u.NotificationChannel) {
+		return fmt.Errorf("notificationChannel must be one of all, in_app, email or webhook")
+	}
+	return nil
+}
+
+// Load reads userID's stored overrides and merges them over Defaults.
+func Load(ctx context.Context, db *sql.DB, userID string) (Settings, error) {
+	var (
+		cur, tz, quietStart, quietEnd, digest, channel sql.NullString
+		threshold                                      sql.NullFloat64
+		emailDrops                                     sql.NullBool
+	)
+	err := db.QueryRowContext(ctx, `
+		SELECT currency, timezone, quiet_hours_start, quiet_hours_end, digest_frequency, drop_threshold_percent, email_price_drops, notification_channel
+		FROM user_settings
+		WHERE user_id = $1
+	`, userID).Scan(&cur, &tz, &quietStart, &quietEnd, &digest, &threshold, &emailDrops, &channel)
+	s := Defaults()
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+
+	if cur.Valid {
+		s.Currency = cur.String
+	}
+	if tz.Valid {
+		s.Timezone = tz.String
+	}
+	if quietStart.Valid && quietEnd.Valid {
+		s.QuietHoursStart, s.QuietHoursEnd = quietStart.String, quietEnd.String
+	}
+	if digest.Valid {
+		s.DigestFrequency = digest.String
+	}
+	if threshold.Valid {
+		s.DropThresholdPercent = threshold.Float64
+	}
+	if emailDrops.Valid {
+		s.EmailPriceDrops = emailDrops.Bool
+	}
+	if channel.Valid {
+		s.NotificationChannel = NotificationChannel(channel.String)
+	}
+	return s, nil
+}
+
+// Save applies a validated update, creating the user's row on first write.
+func Save(ctx context.Context, db *sql.DB, userID string, u Update) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO user_settings (user_id, currency, timezone, quiet_hours_start, quiet_hours_end, digest_frequency, drop_threshold_percent, email_price_drops, notification_channel)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id) DO UPDATE SET
+			currency = COALESCE(EXCLUDED.currency, user_settings.currency),
+			timezone = COALESCE(EXCLUDED.timezone, user_settings.timezone),
+			quiet_hours_start = COALESCE(EXCLUDED.quiet_hours_start, user_settings.quiet_hours_start),
+			quiet_hours_end = COALESCE(EXCLUDED.quiet_hours_end, user_settings.quiet_hours_end),
+			digest_frequency = COALESCE(EXCLUDED.digest_frequency, user_settings.digest_frequency),
+			drop_threshold_percent = COALESCE(EXCLUDED.drop_threshold_percent, user_settings.drop_threshold_percent),
+			email_price_drops = COALESCE(EXCLUDED.email_price_drops, user_settings.email_price_drops),
+			notification_channel = COALESCE(EXCLUDED.notification_channel, user_settings.notification_channel),
+			updated_at = NOW()
+	`, userID, u.Currency, u.Timezone, u.QuietHoursStart, u.QuietHoursEnd, u.DigestFrequency, u.DropThresholdPercent, u.EmailPriceDrops, u.NotificationChannel)
+	return err
+}
+
+// Loader caches effective settings for the subsystems that read them on hot
+// paths. Entries expire after the TTL, so a write made by another process is
+// picked up eventually; writes through the same Loader are seen immediately.
+type Loader struct {
+	db    *sql.DB
+	cache *cache.Cache[Settings]
+}
+
+// NewLoader returns a Loader reading from db. A non-positive ttl disables
+// caching.
+func NewLoader(db *sql.DB, ttl time.Duration) *Loader {
+	return &Loader{db: db, cache: cache.New[Settings](ttl)}
+}
+
+// Share keeps the Loader's cache in store, so that a write through the Loader
+// of any process sharing it is seen by all of them immediately. It returns l.
+func (l *Loader) Share(store cache.Store) *Loader {
+	l.cache.Share(store, "settings:")
+	return l
+}
+
+// Get returns userID's effective settings.
+func (l *Loader) Get(ctx context.Context, userID string) (Settings, error) {
+	return l.cache.GetOrLoad(cacheKey(userID), func() (Settings, error) {
+		return Load(ctx, l.db, userID)
+	})
+}
+
+// Save stores u and drops the cached settings for userID.
+func (l *Loader) Save(ctx context.Context, userID string, u Update) error {
+	err := Save(ctx, l.db, userID, u)
+	l.Invalidate(userID)
+	return err
+}
+
+// Invalidate drops the cached settings for userID after they were changed
+// without going through Save.
+func (l *Loader) Invalidate(userID string) {
+	l.cache.InvalidatePrefix(cacheKey(userID))
+}
+
+func cacheKey(userID string) string {
+	return userID + "|"
+}
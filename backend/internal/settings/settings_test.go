@@ -0,0 +1,50 @@
+package settings
+
+import "testing"
+
+func TestUpdateValidate(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name  string
+		input Update
+		valid bool
+	}{
+		{"empty", Update{}, true},
+		{"currency", Update{Currency: str("eur")}, true},
+		{"unknown currency", Update{Currency: str("ABC")}, false},
+		{"currency symbol", Update{Currency: str("$")}, false},
+		{"timezone", Update{Timezone: str("America/New_York")}, true},
+		{"unknown timezone", Update{Timezone: str("Mars/Olympus")}, false},
+		{"local timezone", Update{Timezone: str("Local")}, false},
+		{"quiet hours", Update{QuietHoursStart: str("22:00"), QuietHoursEnd: str("07:30")}, true},
+		{"quiet hours off", Update{QuietHoursStart: str(""), QuietHoursEnd: str("")}, true},
+		{"quiet hours start only", Update{QuietHoursStart: str("22:00")}, false},
+		{"quiet hours half off", Update{QuietHoursStart: str("22:00"), QuietHoursEnd: str("")}, false},
+		{"quiet hours bad time", Update{QuietHoursStart: str("25:00"), QuietHoursEnd: str("07:00")}, false},
+		{"digest", Update{DigestFrequency: str("weekly")}, true},
+		{"unknown digest", Update{DigestFrequency: str("hourly")}, false},
+		{"threshold", Update{DropThresholdPercent: num(12.5)}, true},
+		{"negative threshold", Update{DropThresholdPercent: num(-1)}, false},
+		{"threshold over 100", Update{DropThresholdPercent: num(101)}, false},
+	}
+
+	for _, test := range tests {
+		err := test.input.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("%s: Validate() = %v, expected valid=%v", test.name, err, test.valid)
+		}
+	}
+}
+
+func TestUpdateValidate_NormalizesCurrency(t *testing.T) {
+	code := "eur"
+	u := Update{Currency: &code}
+	if err := u.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if *u.Currency != "EUR" {
+		t.Errorf("Expected EUR, got %s", *u.Currency)
+	}
+}
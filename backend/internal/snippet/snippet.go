@@ -0,0 +1,43 @@
+// Package snippet compresses the outer HTML snippets captured with each
+// tracked item. Snippets are kept out of tracked_items (see the
+// item_snippets table) because they dominate row size and are rarely shown.
+package snippet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// Compress gzips an HTML snippet for storage in item_snippets.snippet_gz.
+func Compress(html string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(zw, html); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress reverses Compress. Empty input yields an empty snippet.
+func Decompress(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+	html, err := io.ReadAll(zr)
+	if err != nil {
+		return "", err
+	}
+	return string(html), nil
+}
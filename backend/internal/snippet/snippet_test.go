@@ -0,0 +1,39 @@
+package snippet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	html := strings.Repeat(`<div class="price"><span>$19.99</span></div>`, 50)
+
+	data, err := Compress(html)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	if len(data) >= len(html) {
+		t.Errorf("Expected compressed size below %d, got %d", len(html), len(data))
+	}
+
+	got, err := Decompress(data)
+	if err != nil {
+		t.Fatalf("Decompress failed: %v", err)
+	}
+	if got != html {
+		t.Error("Round-tripped snippet does not match the original")
+	}
+}
+
+func TestDecompress_Empty(t *testing.T) {
+	got, err := Decompress(nil)
+	if err != nil || got != "" {
+		t.Errorf("Decompress(nil) = %q, %v; want empty", got, err)
+	}
+}
+
+func TestDecompress_Corrupt(t *testing.T) {
+	if _, err := Decompress([]byte("not gzip")); err == nil {
+		t.Error("Expected an error for corrupt input")
+	}
+}
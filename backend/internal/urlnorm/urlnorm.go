@@ -0,0 +1,73 @@
+// Package urlnorm normalizes product page URLs so that the same listing
+// reached through different links (tracking parameters, fragments, casing)
+// compares equal.
+package urlnorm
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams are query parameters that never affect page content.
+var trackingParams = map[string]bool{
+	"gclid":   true,
+	"fbclid":  true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"ref":     true,
+	"ref_":    true,
+	"_ga":     true,
+	"igshid":  true,
+}
+
+// Normalize returns a canonical form of rawURL: lowercase scheme and host,
+// no default port, no fragment, no tracking parameters, sorted query and no
+// trailing slash on the path. Unparseable input is returned trimmed but
+// otherwise unchanged.
+func Normalize(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.User = nil
+
+	if u.Path != "/" {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = ""
+	}
+
+	query := u.Query()
+	for key := range query {
+		if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = encodeSorted(query)
+
+	return u.String()
+}
+
+// encodeSorted is url.Values.Encode with values sorted too, so parameter
+// order never affects the result.
+func encodeSorted(v url.Values) string {
+	for _, values := range v {
+		sort.Strings(values)
+	}
+	return v.Encode()
+}
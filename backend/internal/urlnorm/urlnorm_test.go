@@ -0,0 +1,24 @@
+package urlnorm
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://www.Amazon.com/dp/B0BD7Z94ZQ", "https://www.amazon.com/dp/B0BD7Z94ZQ"},
+		{"https://www.amazon.com/dp/B0BD7Z94ZQ/?utm_source=x&utm_medium=y", "https://www.amazon.com/dp/B0BD7Z94ZQ"},
+		{"https://shop.example.com:443/p?b=2&a=1#reviews", "https://shop.example.com/p?a=1&b=2"},
+		{"https://shop.example.com/p?gclid=abc&color=red&fbclid=def", "https://shop.example.com/p?color=red"},
+		{"HTTP://Example.com:8080/item/", "http://example.com:8080/item"},
+		{"  https://example.com/  ", "https://example.com/"},
+		{"not a url", "not a url"},
+	}
+
+	for _, test := range tests {
+		if got := Normalize(test.input); got != test.expected {
+			t.Errorf("Normalize(%q) = %q, expected %q", test.input, got, test.expected)
+		}
+	}
+}
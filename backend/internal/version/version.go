@@ -0,0 +1,75 @@
+// Package version exposes build metadata for the API server and scraper.
+//
+// The variables below are populated at build time, e.g.
+//
+//	go build -ldflags "-X price-track-backend/internal/version.Version=1.4.2 \
+//	  -X price-track-backend/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X price-track-backend/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// When they are not set, Get falls back to the information embedded by the Go
+// toolchain (module version and VCS stamping).
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	Version   = ""
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build info, preferring values injected via -ldflags.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+// Product returns the product token used in outgoing User-Agent strings,
+// e.g. "PriceTrack/1.4.2", so site operators can identify our traffic.
+func Product() string {
+	return "PriceTrack/" + Get().Version
+}
@@ -0,0 +1,36 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGet_PrefersLdflags(t *testing.T) {
+	prevVersion, prevCommit, prevDate := Version, Commit, BuildDate
+	defer func() { Version, Commit, BuildDate = prevVersion, prevCommit, prevDate }()
+
+	Version, Commit, BuildDate = "1.4.2", "abc123", "2025-01-02T03:04:05Z"
+
+	info := Get()
+	if info.Version != "1.4.2" || info.Commit != "abc123" || info.BuildDate != "2025-01-02T03:04:05Z" {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("Expected Go version %s, got %s", runtime.Version(), info.GoVersion)
+	}
+	if got := Product(); got != "PriceTrack/1.4.2" {
+		t.Errorf("Expected PriceTrack/1.4.2, got %s", got)
+	}
+}
+
+func TestGet_Fallbacks(t *testing.T) {
+	prevVersion, prevCommit, prevDate := Version, Commit, BuildDate
+	defer func() { Version, Commit, BuildDate = prevVersion, prevCommit, prevDate }()
+
+	Version, Commit, BuildDate = "", "", ""
+
+	info := Get()
+	if info.Version == "" || info.Commit == "" || info.BuildDate == "" {
+		t.Errorf("Expected non-empty fallbacks, got %+v", info)
+	}
+}
@@ -0,0 +1,1285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+	"golang.org/x/text/language"
+
+	"price-track-backend/internal/scheduler"
+	"price-track-backend/internal/settings"
+	"price-track-backend/internal/snippet"
+	"price-track-backend/internal/urlnorm"
+)
+
+type TrackedItem struct {
+	ID               string   `json:"id"`
+	PriceText        string   `json:"priceText"`
+	PriceNormalized  string   `json:"priceNormalized,omitempty"` // see scheduler.NormalizePrice
+	ProductName      string   `json:"productName"`
+	ImageURL         string   `json:"imageUrl"`
+	ImageURLs        []string `json:"imageUrls"`
+	Tags             []string `json:"tags"`
+	CSSSelector      string   `json:"cssSelector"`
+	XPath            string   `json:"xPath"`
+	FrameSelector    string   `json:"frameSelector,omitempty"`
+	FrameURL         string   `json:"frameUrl,omitempty"`
+	VariantSelector  string   `json:"variantSelector,omitempty"`
+	VariantValue     string   `json:"variantValue,omitempty"`
+	ProxyURL         string   `json:"proxyUrl,omitempty"` // password masked
+	ScrapeProfile    string   `json:"scrapeProfile,omitempty"`
+	MaxScrapeSeconds *int     `json:"maxScrapeSeconds,omitempty"` // see SCRAPE_ITEM_TIMEOUT
+	PageURL          string   `json:"pageUrl"`
+	FinalURL         string   `json:"finalUrl,omitempty"` // where redirects led
+	OuterHTMLSnippet string   `json:"outerHtmlSnippet,omitempty"`
+	CapturedAtISO    string   `json:"capturedAtIso"`
+	SavedAtISO       string   `json:"savedAtIso"`
+	LastScrapeStatus string   `json:"lastScrapeStatus"`
+	MinExpected      *float64 `json:"minExpected,omitempty"`
+	MaxExpected      *float64 `json:"maxExpected,omitempty"`
+	PausedAt         *string  `json:"pausedAt,omitempty"`
+	PauseReason      *string  `json:"pauseReason,omitempty"`
+	ParseStrategy    string   `json:"parseStrategy"`
+	AdapterOrder     string   `json:"adapterOrder"`  // see scheduler.AdapterOrder
+	SelectorMatch    string   `json:"selectorMatch"` // see scheduler.SelectorMatch
+	AcceptLanguage   string   `json:"acceptLanguage"`
+	CountryCode      string   `json:"countryCode,omitempty"`
+	ObservedPrice    *float64 `json:"observedPrice,omitempty"`
+	// ShippingSelector selects the item's shipping cost, which the last
+	// check read as ShippingPrice. With one, drops are judged on TotalPrice,
+	// the price plus shipping.
+	ShippingSelector string   `json:"shippingSelector,omitempty"`
+	ShippingPrice    *float64 `json:"shippingPrice,omitempty"`
+	TotalPrice       *float64 `json:"totalPrice,omitempty"`
+	// NotificationChannel overrides the owner's notificationChannel
+	// setting for this item's price drops; empty keeps it.
+	NotificationChannel string `json:"notificationChannel,omitempty"`
+	// AvailabilitySelector selects the item's stock text, which is checked
+	// before its price; no price is read while it says the product is sold
+	// out. Availability is what the last check found ("in_stock" or
+	// "out_of_stock"), and is read only.
+	AvailabilitySelector string `json:"availabilitySelector,omitempty"`
+	Availability         string `json:"availability,omitempty"`
+	// Headers are sent with every scrape of the item. Only its owner ever
+	// reads them back.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	PriceFirstSeenAt   *string `json:"priceFirstSeenAt,omitempty"`
+	PriceLastChangedAt *string `json:"priceLastChangedAt,omitempty"`
+}
+
+// newItemRequest is the body of POST /items. Cookies are accepted here but
+// kept out of TrackedItem, so that no response ever echoes them.
+type newItemRequest struct {
+	TrackedItem
+	Cookies []scheduler.Cookie `json:"cookies"`
+}
+
+// itemPatch is the body of PATCH /items/{id}. Fields left out are unchanged.
+type itemPatch struct {
+	Paused         *bool   `json:"paused"`
+	ParseStrategy  *string `json:"parseStrategy"`
+	AdapterOrder   *string `json:"adapterOrder"`
+	SelectorMatch  *string `json:"selectorMatch"`
+	AcceptLanguage *string `json:"acceptLanguage"`
+	// Cookies replaces the item's cookies; an empty list removes them.
+	Cookies *[]scheduler.Cookie `json:"cookies"`
+	// Headers replaces the item's headers; an empty object removes them.
+	Headers *map[string]string `json:"headers"`
+	// FrameSelector and FrameURL replace the item's iframe together; empty
+	// strings put the price back on the page itself.
+	FrameSelector *string `json:"frameSelector"`
+	FrameURL      *string `json:"frameUrl"`
+	// VariantSelector and VariantValue replace the item's variant
+	// together; empty strings go back to the page's default variant.
+	VariantSelector *string `json:"variantSelector"`
+	VariantValue    *string `json:"variantValue"`
+	// ProxyURL replaces the item's proxy; an empty string removes it.
+	ProxyURL *string `json:"proxyUrl"`
+	// ScrapeProfile replaces the item's profile; an empty string goes
+	// back to the default.
+	ScrapeProfile *string `json:"scrapeProfile"`
+	// MaxScrapeSeconds replaces the item's scrape time limit; 0 goes back
+	// to SCRAPE_ITEM_TIMEOUT.
+	MaxScrapeSeconds *int `json:"maxScrapeSeconds"`
+	// CountryCode replaces the item's country; an empty string removes it.
+	CountryCode *string `json:"countryCode"`
+	// Tags replaces the item's tags; an empty list removes them.
+	Tags *[]string `json:"tags"`
+	// ShippingSelector replaces the item's shipping selector; an empty
+	// string compares the price alone again.
+	ShippingSelector *string `json:"shippingSelector"`
+	// NotificationChannel replaces the item's notification channel; an
+	// empty string goes back to the owner's default.
+	NotificationChannel *string `json:"notificationChannel"`
+	// AvailabilitySelector replaces the item's availability selector; an
+	// empty string stops checking its stock.
+	AvailabilitySelector *string `json:"availabilitySelector"`
+}
+
+// itemColumns is the default column list (see defaultItemFields). The outer
+// HTML snippet lives in item_snippets and is only selected on request.
+var itemColumns = defaultItemFields.columns()
+
+// snippetColumns selects the snippet. Rows not yet moved by
+// cmd/backfill-snippets still carry it in tracked_items.
+const snippetColumns = `snippet_gz, outer_html_snippet`
+
+// snippetJoin makes snippetColumns available to a query over tracked_items.
+const snippetJoin = ` LEFT JOIN item_snippets ON item_snippets.item_id = tracked_items.id`
+
+// normalizeImages keeps ImageURL and ImageURLs consistent: ImageURL is the
+// primary image and is always the first entry of ImageURLs. Blank and
+// duplicate URLs are dropped.
+func normalizeImages(i *TrackedItem) {
+	if i.ImageURL == "" && len(i.ImageURLs) > 0 {
+		i.ImageURL = i.ImageURLs[0]
+	}
+
+	urls := make([]string, 0, len(i.ImageURLs)+1)
+	seen := make(map[string]bool)
+	for _, u := range append([]string{i.ImageURL}, i.ImageURLs...) {
+		u = strings.TrimSpace(u)
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+	i.ImageURLs = urls
+}
+
+// streamItems writes rows to w as a JSON array, encoding each item as soon as
+// it is scanned so that memory stays flat regardless of the number of items.
+// Once the opening bracket is written the status code can no longer change,
+// so a mid-stream failure, including a row that cannot be scanned, ends the
+// array cleanly and is returned to the caller, which signals it to the client
+// (see respondList). It returns the number of items written.
+func streamItems(w io.Writer, rows *sql.Rows, fields itemFieldSet) (count int, err error) {
+	w.Write([]byte("["))
+	defer w.Write([]byte("]"))
+
+	for rows.Next() {
+		item, err := fields.scan(rows)
+		if err != nil {
+			return count, fmt.Errorf("scan item: %w", err)
+		}
+		if count > 0 {
+			w.Write([]byte(","))
+		}
+		if err := fields.encode(w, item); err != nil {
+			// The client has most likely gone away; nothing more can be sent.
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// maxPageSize caps the limit parameter on list endpoints.
+const maxPageSize = 500
+
+// parsePagination reads the optional limit and offset query parameters, or a
+// cursor (from an envelope's nextCursor) in place of offset. A zero limit
+// means "no limit", which keeps the legacy unpaginated behavior.
+func parsePagination(q url.Values) (limit, offset int, err error) {
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be an integer between 1 and %d", maxPageSize)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+		if limit == 0 {
+			return 0, 0, fmt.Errorf("offset requires limit")
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		if q.Get("offset") != "" {
+			return 0, 0, fmt.Errorf("cursor and offset cannot be combined")
+		}
+		if offset, err = decodeCursor(v); err != nil {
+			return 0, 0, err
+		}
+		if limit == 0 {
+			return 0, 0, fmt.Errorf("cursor requires limit")
+		}
+	}
+	return limit, offset, nil
+}
+
+// itemsETag derives a weak ETag for the caller's item list from the number of
+// matching items and the latest updated_at, using a single indexed aggregate query. The
+// request's query parameters (pagination, filters) and response shape are part
+// of the input so different pages never share a tag. The item count is
+// returned as well, for the envelope's total.
+func itemsETag(ctx context.Context, r *http.Request, q itemQuery) (string, int64, error) {
+	where, args := q.where()
+	var count int64
+	var maxUpdated sql.NullTime
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*), MAX(updated_at)
+		FROM tracked_items
+		WHERE `+where, args...).Scan(&count, &maxUpdated)
+	if err != nil {
+		return "", 0, err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%d|%d|%s|%t", q.userID, count, maxUpdated.Time.UnixNano(), r.URL.Query().Encode(), wantsEnvelope(r))
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:12]) + `"`, count, nil
+}
+
+// etagMatches implements the If-None-Match comparison (weak comparison, list
+// of tags or "*").
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// includesSnippet reports whether the query asked for outer HTML snippets
+// with ?include=snippet (a comma-separated list).
+func includesSnippet(q url.Values) bool {
+	for _, v := range strings.Split(q.Get("include"), ",") {
+		if strings.TrimSpace(v) == "snippet" {
+			return true
+		}
+	}
+	return false
+}
+
+// itemQuery describes a GET /items request: whose items, which of them, which
+// page and which fields.
+type itemQuery struct {
+	userID        string
+	limit, offset int
+	fields        itemFieldSet
+
+	// pageURL, when set, restricts the list to items whose normalized page
+	// URL equals it (?pageUrl=).
+	pageURL string
+	// changed, when set, restricts the list to one priceChangeConds bucket
+	// (?changed=).
+	changed string
+	// status, when set, restricts the list to one itemStatusConds bucket
+	// (?status=).
+	status string
+	// tags, when set, restricts the list to items carrying all of them
+	// (?tag=, repeatable).
+	tags []string
+	// orderBy is the itemSorts clause chosen with ?sort=.
+	orderBy string
+}
+
+// itemSorts maps ?sort= to an ORDER BY clause. "drop" ranks items by the
+// fraction the observed price fell below the captured one; rises rank below
+// drops and items without both prices come last. Every order ends with id so
+// that items created together (as by an import) keep their place across
+// pages.
+var itemSorts = map[string]string{
+	"":       "created_at DESC, id DESC",
+	"newest": "created_at DESC, id DESC",
+	"drop":   "(captured_price - last_price) / NULLIF(captured_price, 0) DESC NULLS LAST, created_at DESC, id DESC",
+}
+
+// priceChangeConds maps ?changed= to a condition comparing the latest price
+// the scheduler observed with the captured one. Comparisons with NULL are
+// never true, so items without an observation only match "unknown".
+var priceChangeConds = map[string]string{
+	"dropped":   "last_price < captured_price",
+	"risen":     "last_price > captured_price",
+	"unchanged": "last_price = captured_price",
+	"unknown":   "(last_price IS NULL OR captured_price IS NULL)",
+}
+
+// itemStatusConds maps ?status= to a condition on how the item is being
+// tracked. The states are distinct: a paused item is not active whatever its
+// last scrape found, and a discontinued one (its page is gone) is neither
+// active nor broken (its selector no longer matches). An out of stock item
+// (its site's adapter says it is not for sale) is still active.
+var itemStatusConds = map[string]string{
+	"active":       "paused_at IS NULL AND last_scrape_status IS DISTINCT FROM 'discontinued'",
+	"paused":       "paused_at IS NOT NULL",
+	"broken":       "last_scrape_status = 'selector_broken'",
+	"discontinued": "last_scrape_status = 'discontinued'",
+	"out_of_stock": "last_scrape_status = 'out_of_stock'",
+}
+
+// itemStatusNames lists the ?status= values, for error messages.
+func itemStatusNames() string {
+	names := slices.Sorted(maps.Keys(itemStatusConds))
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// parseItemQuery reads the pagination, field and filter parameters of a GET
+// /items request.
+func parseItemQuery(query url.Values, userID string) (itemQuery, error) {
+	q := itemQuery{userID: userID}
+	var err error
+	if q.limit, q.offset, err = parsePagination(query); err != nil {
+		return q, err
+	}
+	if q.fields, err = parseFields(query, false); err != nil {
+		return q, err
+	}
+	if values, ok := query["pageUrl"]; ok {
+		if values[0] == "" {
+			return q, fmt.Errorf("pageUrl must not be empty")
+		}
+		q.pageURL = urlnorm.Normalize(values[0])
+	}
+	if v := query.Get("changed"); v != "" {
+		if _, ok := priceChangeConds[v]; !ok {
+			return q, fmt.Errorf("changed must be one of dropped, risen, unchanged or unknown")
+		}
+		// The observed price is what the filter is about, so it is returned.
+		q.changed = v
+		q.fields = q.fields.with(observedPriceField)
+	}
+	if v := query.Get("status"); v != "" {
+		if _, ok := itemStatusConds[v]; !ok {
+			return q, fmt.Errorf("status must be one of %s", itemStatusNames())
+		}
+		q.status = v
+	}
+	if values, ok := query["tag"]; ok {
+		tags, err := normalizeTags(values)
+		if err != nil {
+			return q, err
+		}
+		if len(tags) == 0 {
+			return q, fmt.Errorf("tag must not be empty")
+		}
+		q.tags = tags
+	}
+	sort := query.Get("sort")
+	orderBy, ok := itemSorts[sort]
+	if !ok {
+		return q, fmt.Errorf("sort must be one of newest or drop")
+	}
+	q.orderBy = orderBy
+	if sort == "drop" {
+		q.fields = q.fields.with(observedPriceField)
+	}
+	return q, nil
+}
+
+// where returns the WHERE clause selecting the query's items and its args.
+func (q itemQuery) where() (string, []any) {
+	where, args := "user_id = $1", []any{q.userID}
+	if q.pageURL != "" {
+		args = append(args, q.pageURL)
+		where += fmt.Sprintf(" AND normalized_url = $%d", len(args))
+	}
+	if q.changed != "" {
+		where += " AND " + priceChangeConds[q.changed]
+	}
+	if q.status != "" {
+		where += " AND " + itemStatusConds[q.status]
+	}
+	if len(q.tags) > 0 {
+		args = append(args, pq.Array(q.tags))
+		where += fmt.Sprintf(" AND tags @> $%d", len(args))
+	}
+	return where, args
+}
+
+// queryItems selects a page of the matching items in the requested order,
+// newest first by default. A zero limit returns every item.
+func queryItems(ctx context.Context, q itemQuery) (*sql.Rows, error) {
+	where, args := q.where()
+	query := `
+		SELECT ` + q.fields.columns() + `
+		FROM ` + q.fields.from() + `
+		WHERE ` + where + `
+		ORDER BY ` + q.orderBy + `
+	`
+	if q.limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		args = append(args, q.limit, q.offset)
+	}
+	return db.QueryContext(ctx, query, args...)
+}
+
+// listItemsCached serves GET /items from the response cache. The cached body
+// is built by the same streaming encoder, just into a buffer. A revalidation
+// is answered from its own cached ETag, so a match never loads the items.
+func listItemsCached(ctx context.Context, w http.ResponseWriter, r *http.Request, q itemQuery) {
+	envelope := wantsEnvelope(r)
+	parts := []string{r.URL.Query().Encode(), strconv.FormatBool(envelope)}
+	w.Header().Set("Vary", "Accept")
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		tag, err := responseCache.GetOrLoad(userCacheKey(q.userID, append([]string{"items-etag"}, parts...)...), func() (cachedResponse, error) {
+			etag, _, err := itemsETag(ctx, r, q)
+			return cachedResponse{ETag: etag}, err
+		})
+		if err != nil {
+			slog.Error("Failed to compute items ETag", "error", err)
+			queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if etagMatches(ifNoneMatch, tag.ETag) {
+			w.Header().Set("ETag", tag.ETag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	resp, err := responseCache.GetOrLoad(userCacheKey(q.userID, append([]string{"items"}, parts...)...), func() (cachedResponse, error) {
+		etag, total, err := itemsETag(ctx, r, q)
+		if err != nil {
+			return cachedResponse{}, err
+		}
+		rows, err := queryItems(ctx, q)
+		if err != nil {
+			return cachedResponse{}, err
+		}
+		defer rows.Close()
+
+		var buf bytes.Buffer
+		err = writeList(&buf, envelope, newListMeta(total, q.limit, q.offset), func(w io.Writer) error {
+			_, err := streamItems(w, rows, q.fields)
+			return err
+		})
+		if err != nil {
+			return cachedResponse{}, err
+		}
+		return cachedResponse{ETag: etag, Body: buf.Bytes()}, nil
+	})
+	if err != nil {
+		slog.Error("Failed to load items", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", resp.ETag)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp.Body)
+}
+
+// itemsHandler serves /items.
+var itemsHandler = methods{
+	"GET":    listItemsHandler,
+	"POST":   createItemHandler,
+	"DELETE": deleteAllItemsHandler,
+}.ServeHTTP
+
+func listItemsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	q, err := parseItemQuery(r.URL.Query(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if responseCache.Enabled() {
+		listItemsCached(ctx, w, r, q)
+		return
+	}
+
+	etag, total, err := itemsETag(ctx, r, q)
+	if err != nil {
+		slog.Error("Failed to compute items ETag", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rows, err := queryItems(ctx, q)
+	if err != nil {
+		slog.Error("Failed to query items", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var count int
+	err = respondList(w, r, newListMeta(total, q.limit, q.offset), func(w io.Writer) error {
+		count, err = streamItems(w, rows, q.fields)
+		return err
+	})
+	if err != nil {
+		slog.Error("Item stream terminated early", "error", err, "written", count)
+	}
+	slog.Info("Returning items", "count", count, "user_id", userID)
+}
+
+func createItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	var body newItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		slog.Error("Failed to decode item", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	item := body.TrackedItem
+
+	if err := validateExpectedBounds(item.MinExpected, item.MaxExpected); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateParseStrategy(&item.ParseStrategy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateAdapterOrder(&item.AdapterOrder); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateSelectorMatch(&item.SelectorMatch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateAcceptLanguage(&item.AcceptLanguage); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateFrame(&item.FrameSelector, &item.FrameURL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateShippingSelector(&item.ShippingSelector); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateAvailabilitySelector(&item.AvailabilitySelector); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateVariantSelection(&item.VariantSelector, &item.VariantValue, item.CSSSelector); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if code, err := validateItemProxy(userID, item.ProxyURL); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if err := validateScrapeProfile(item.ScrapeProfile); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateMaxScrapeSeconds(item.MaxScrapeSeconds); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateCountryCode(&item.CountryCode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateNotificationChannel(item.NotificationChannel); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tags, err := normalizeTags(item.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	item.Tags = tags
+	cookies, err := sealItemCookies(item.PageURL, body.Cookies)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateItemHeaders(item.PageURL, item.Headers); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	normalizeImages(&item)
+
+	capturedAt, err := time.Parse(time.RFC3339, item.CapturedAtISO)
+	if err != nil {
+		slog.Error("Failed to parse capturedAtIso", "error", err)
+		http.Error(w, "Invalid capturedAtIso", http.StatusBadRequest)
+		return
+	}
+	savedAt, err := time.Parse(time.RFC3339, item.SavedAtISO)
+	if err != nil {
+		slog.Error("Failed to parse savedAtIso", "error", err)
+		http.Error(w, "Invalid savedAtIso", http.StatusBadRequest)
+		return
+	}
+
+	if !checkItemsQuota(ctx, w, userID, 1) {
+		return
+	}
+	if err := insertItem(ctx, item, cookies, capturedAt, savedAt, userID); err != nil {
+		slog.Error("Failed to insert item", "error", err)
+		queryError(ctx, w, err, "Failed to save item", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Received and saved item", "id", item.ID, "productName", item.ProductName, "user_id", userID)
+	invalidateUserCache(userID)
+	item.ProxyURL = scheduler.RedactProxyURL(item.ProxyURL)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+func deleteAllItemsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, "DELETE FROM tracked_items WHERE user_id = $1", userID)
+	if err != nil {
+		slog.Error("Failed to delete all items", "error", err)
+		queryError(ctx, w, err, "Failed to delete items", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Cleared all items", "user_id", userID)
+	invalidateUserCache(userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// insertItem stores a new item with its sealed cookies and, in the same
+// transaction, its compressed snippet in item_snippets.
+func insertItem(ctx context.Context, item TrackedItem, cookies sql.Null[[]byte], capturedAt, savedAt time.Time, userID string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertItemTx(ctx, tx, item, cookies, capturedAt, savedAt, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertItemTx is insertItem within tx, for callers that store more with the
+// item.
+func insertItemTx(ctx context.Context, tx *sql.Tx, item TrackedItem, cookies sql.Null[[]byte], capturedAt, savedAt time.Time, userID string) error {
+	headers, err := encodeItemHeaders(item.Headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO tracked_items (id, price_text, product_name, image_url, css_selector, xpath, page_url, outer_html_snippet, captured_at, saved_at, user_id, min_expected, max_expected, image_urls, normalized_url, parse_strategy, captured_price, price_first_seen_at, accept_language, cookies_encrypted, headers, frame_selector, frame_url, proxy_url, scrape_profile, country_code, tags, variant_selector, variant_value, price_normalized, adapter_order, max_scrape_seconds, selector_match, shipping_selector, notification_channel, availability_selector)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, '', $8, $9, $10, $11, $12, $13, $14, $15, $16, $8, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, NULLIF($28, ''), $29, $30, $31, $32)
+	`, item.ID, item.PriceText, item.ProductName, item.ImageURL, item.CSSSelector, item.XPath, item.PageURL, capturedAt, savedAt, userID, item.MinExpected, item.MaxExpected, pq.Array(item.ImageURLs), urlnorm.Normalize(item.PageURL), item.ParseStrategy, capturedPrice(item), item.AcceptLanguage, cookies, headers, item.FrameSelector, item.FrameURL, item.ProxyURL, item.ScrapeProfile, item.CountryCode, pq.Array(item.Tags), item.VariantSelector, item.VariantValue, scheduler.NormalizePrice(item.PriceText, scheduler.ParseStrategy(item.ParseStrategy), item.CountryCode), item.AdapterOrder, item.MaxScrapeSeconds, item.SelectorMatch, item.ShippingSelector, item.NotificationChannel, item.AvailabilitySelector)
+	if err != nil {
+		return err
+	}
+
+	if item.OuterHTMLSnippet != "" {
+		data, err := snippet.Compress(item.OuterHTMLSnippet)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO item_snippets (item_id, snippet_gz) VALUES ($1, $2)`, item.ID, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// capturedPrice parses the price an item was saved with, or returns nil when
+// it cannot be read as a positive number.
+func capturedPrice(item TrackedItem) *float64 {
+	price, err := scheduler.ParsePriceWith(item.PriceText, scheduler.ParseStrategy(item.ParseStrategy))
+	if err != nil || price <= 0 {
+		return nil
+	}
+	return &price
+}
+
+// validateParseStrategy checks an item's parse strategy, defaulting it to auto
+// when unset.
+func validateParseStrategy(strategy *string) error {
+	if *strategy == "" {
+		*strategy = string(scheduler.ParseAuto)
+	}
+	if !scheduler.ValidParseStrategy(*strategy) {
+		return fmt.Errorf("parseStrategy must be one of auto, us, eu, plain or lakh")
+	}
+	return nil
+}
+
+// validateAdapterOrder checks an item's adapter order, defaulting it to first
+// when unset.
+func validateAdapterOrder(order *string) error {
+	if *order == "" {
+		*order = string(scheduler.AdapterFirst)
+	}
+	if !scheduler.ValidAdapterOrder(*order) {
+		return fmt.Errorf("adapterOrder must be first or last")
+	}
+	return nil
+}
+
+// validateSelectorMatch checks which of its CSS selector's matches an item
+// reads, defaulting it to first when unset.
+func validateSelectorMatch(match *string) error {
+	if *match == "" {
+		*match = string(scheduler.SelectorFirst)
+	}
+	if !scheduler.ValidSelectorMatch(*match) {
+		return fmt.Errorf("selectorMatch must be first, min, max or index:N with N at least 1")
+	}
+	return nil
+}
+
+// maxScrapeSecondsLimit caps maxScrapeSeconds. SCRAPE_ITEM_TIMEOUT caps it
+// further.
+const maxScrapeSecondsLimit = 600
+
+// validateMaxScrapeSeconds checks an item's own scrape time limit. Nil leaves
+// it to SCRAPE_ITEM_TIMEOUT.
+func validateMaxScrapeSeconds(seconds *int) error {
+	if seconds != nil && (*seconds < 1 || *seconds > maxScrapeSecondsLimit) {
+		return fmt.Errorf("maxScrapeSeconds must be between 1 and %d", maxScrapeSecondsLimit)
+	}
+	return nil
+}
+
+// validateScrapeProfile checks an item's scrape profile. Empty leaves it to
+// SCRAPE_PROFILE.
+func validateScrapeProfile(profile string) error {
+	if profile != "" && !scheduler.ValidScrapeProfile(profile) {
+		return fmt.Errorf("scrapeProfile must be fast or stealth")
+	}
+	return nil
+}
+
+// maxFrameLength caps frameSelector and frameUrl.
+const maxFrameLength = 2048
+
+// validateFrame checks the iframe an item's price is in: at most one of a
+// selector for the iframe element and part of its src URL.
+func validateFrame(selector, frameURL *string) error {
+	*selector = strings.TrimSpace(*selector)
+	*frameURL = strings.TrimSpace(*frameURL)
+	if *selector != "" && *frameURL != "" {
+		return fmt.Errorf("set frameSelector or frameUrl, not both")
+	}
+	if len(*selector) > maxFrameLength || len(*frameURL) > maxFrameLength {
+		return fmt.Errorf("frameSelector and frameUrl must be at most %d characters", maxFrameLength)
+	}
+	return nil
+}
+
+// validateShippingSelector checks the CSS selector an item reads its shipping
+// cost with.
+func validateShippingSelector(selector *string) error {
+	*selector = strings.TrimSpace(*selector)
+	if len(*selector) > maxFrameLength {
+		return fmt.Errorf("shippingSelector must be at most %d characters", maxFrameLength)
+	}
+	return nil
+}
+
+// validateAvailabilitySelector checks the CSS selector an item reads its
+// stock text with.
+func validateAvailabilitySelector(selector *string) error {
+	*selector = strings.TrimSpace(*selector)
+	if len(*selector) > maxFrameLength {
+		return fmt.Errorf("availabilitySelector must be at most %d characters", maxFrameLength)
+	}
+	return nil
+}
+
+// validateNotificationChannel checks the channel an item's price drops are
+// delivered to. Empty keeps the owner's default.
+func validateNotificationChannel(channel string) error {
+	if channel != "" && !settings.ValidNotificationChannel(channel) {
+		return fmt.Errorf("notificationChannel must be one of all, in_app, email or webhook")
+	}
+	return nil
+}
+
+// validateVariantSelection checks the variant an item tracks: a CSS selector
+// for the size or color control and, optionally, the option to pick with it.
+// Only the headless browser can select a variant, and it reads the price with
+// the item's CSS selector.
+func validateVariantSelection(selector, value *string, cssSelector string) error {
+	*selector = strings.TrimSpace(*selector)
+	*value = strings.TrimSpace(*value)
+	if len(*selector) > maxFrameLength || len(*value) > maxFrameLength {
+		return fmt.Errorf("variantSelector and variantValue must be at most %d characters", maxFrameLength)
+	}
+	if *selector == "" {
+		if *value != "" {
+			return fmt.Errorf("variantValue needs a variantSelector")
+		}
+		return nil
+	}
+	if cssSelector == "" {
+		return fmt.Errorf("variantSelector needs a cssSelector")
+	}
+	return nil
+}
+
+// validateItemProxy checks the proxy an item is scraped through. Only admins
+// may set one, since it routes the server's traffic through any host.
+func validateItemProxy(userID, raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	if !isAdmin(userID) {
+		return http.StatusForbidden, fmt.Errorf("only admins can set proxyUrl")
+	}
+	if _, err := scheduler.ParseProxyURL(raw); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return 0, nil
+}
+
+// validateAcceptLanguage checks an item's BCP 47 locale tag, canonicalizing
+// it ("de-ch" -> "de-CH") and defaulting it to en-US when unset.
+func validateAcceptLanguage(tag *string) error {
+	if *tag == "" {
+		*tag = scheduler.DefaultAcceptLanguage
+	}
+	parsed, err := language.Parse(*tag)
+	if err != nil {
+		return fmt.Errorf("acceptLanguage must be a language tag such as en-US")
+	}
+	*tag = parsed.String()
+	return nil
+}
+
+// Limits on an item's tags.
+const (
+	maxItemTags      = 20
+	maxItemTagLength = 32
+)
+
+// normalizeTags trims and lower-cases tags, dropping blanks and duplicates.
+// The result is never nil.
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxItemTagLength {
+			return nil, fmt.Errorf("tags must be at most %d characters", maxItemTagLength)
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	if len(out) > maxItemTags {
+		return nil, fmt.Errorf("an item can have at most %d tags", maxItemTags)
+	}
+	return out, nil
+}
+
+// validateCountryCode checks the country an item is scraped from,
+// upper-casing it. Empty means none.
+func validateCountryCode(code *string) error {
+	*code = strings.ToUpper(strings.TrimSpace(*code))
+	if !scheduler.ValidCountryCode(*code) {
+		return fmt.Errorf("countryCode must be a supported two-letter country code such as DE")
+	}
+	return nil
+}
+
+// validateExpectedBounds checks the optional sanity bounds supplied for an item.
+func validateExpectedBounds(min, max *float64) error {
+	if min != nil && *min < 0 {
+		return fmt.Errorf("minExpected must not be negative")
+	}
+	if max != nil && *max <= 0 {
+		return fmt.Errorf("maxExpected must be positive")
+	}
+	if min != nil && max != nil && *min > *max {
+		return fmt.Errorf("minExpected must not exceed maxExpected")
+	}
+	return nil
+}
+
+// itemHandler serves /items/{id}.
+var itemHandler = methods{
+	"GET":    getItemHandler,
+	"DELETE": deleteItemHandler,
+	"PATCH":  updateItemHandler,
+}.ServeHTTP
+
+func getItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+
+	fields, err := parseFields(r.URL.Query(), true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	item, err := loadItem(ctx, userID, id, fields)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to load item", "id", id, "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fields.encode(w, item)
+}
+
+// loadItem reads the given fields of the user's item id. It returns
+// sql.ErrNoRows if the user has no such item.
+func loadItem(ctx context.Context, userID, id string, fields itemFieldSet) (TrackedItem, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+fields.columns()+`
+		FROM `+fields.from()+`
+		WHERE id = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		return TrackedItem{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return TrackedItem{}, err
+		}
+		return TrackedItem{}, sql.ErrNoRows
+	}
+	return fields.scan(rows)
+}
+
+func deleteItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+
+	result, err := db.ExecContext(ctx, "DELETE FROM tracked_items WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		slog.Error("Failed to delete item", "id", id, "error", err)
+		queryError(ctx, w, err, "Failed to delete item", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		slog.Warn("Item not found", "id", id)
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	invalidateUserCache(userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func updateItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+
+	var req itemPatch
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Paused == nil && req.ParseStrategy == nil && req.AdapterOrder == nil && req.SelectorMatch == nil && req.AcceptLanguage == nil && req.Cookies == nil && req.Headers == nil && req.FrameSelector == nil && req.FrameURL == nil && req.VariantSelector == nil && req.VariantValue == nil && req.ProxyURL == nil && req.ScrapeProfile == nil && req.MaxScrapeSeconds == nil && req.CountryCode == nil && req.Tags == nil && req.ShippingSelector == nil && req.NotificationChannel == nil && req.AvailabilitySelector == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	var sets []string
+	args := []any{id, userID}
+	if req.Paused != nil {
+		if *req.Paused {
+			sets = append(sets, "paused_at = COALESCE(paused_at, NOW()), pause_reason = 'user'")
+		} else {
+			sets = append(sets, "paused_at = NULL, pause_reason = NULL")
+		}
+	}
+	if req.ParseStrategy != nil {
+		if err := validateParseStrategy(req.ParseStrategy); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, *req.ParseStrategy)
+		sets = append(sets, fmt.Sprintf("parse_strategy = $%d", len(args)))
+	}
+	if req.AdapterOrder != nil {
+		if err := validateAdapterOrder(req.AdapterOrder); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, *req.AdapterOrder)
+		sets = append(sets, fmt.Sprintf("adapter_order = $%d", len(args)))
+	}
+	if req.SelectorMatch != nil {
+		if err := validateSelectorMatch(req.SelectorMatch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, *req.SelectorMatch)
+		sets = append(sets, fmt.Sprintf("selector_match = $%d", len(args)))
+	}
+	if req.AcceptLanguage != nil {
+		if err := validateAcceptLanguage(req.AcceptLanguage); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, *req.AcceptLanguage)
+		sets = append(sets, fmt.Sprintf("accept_language = $%d", len(args)))
+	}
+	if req.ScrapeProfile != nil {
+		if err := validateScrapeProfile(*req.ScrapeProfile); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, *req.ScrapeProfile)
+		sets = append(sets, fmt.Sprintf("scrape_profile = $%d", len(args)))
+	}
+	if req.MaxScrapeSeconds != nil {
+		var seconds *int
+		if *req.MaxScrapeSeconds != 0 {
+			seconds = req.MaxScrapeSeconds
+		}
+		if err := validateMaxScrapeSeconds(seconds); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, seconds)
+		sets = append(sets, fmt.Sprintf("max_scrape_seconds = $%d", len(args)))
+	}
+	if req.CountryCode != nil {
+		if err := validateCountryCode(req.CountryCode); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, *req.CountryCode)
+		sets = append(sets, fmt.Sprintf("country_code = $%d", len(args)))
+	}
+	if req.Tags != nil {
+		tags, err := normalizeTags(*req.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, pq.Array(tags))
+		sets = append(sets, fmt.Sprintf("tags = $%d", len(args)))
+	}
+	if req.ShippingSelector != nil {
+		if err := validateShippingSelector(req.ShippingSelector); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// The cost read with the old selector no longer applies, nor does
+		// the total last notified.
+		args = append(args, *req.ShippingSelector)
+		sets = append(sets, fmt.Sprintf("shipping_selector = $%d, shipping_price = NULL, total_price = NULL, last_notified_price = NULL", len(args)))
+	}
+	if req.NotificationChannel != nil {
+		if err := validateNotificationChannel(*req.NotificationChannel); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, *req.NotificationChannel)
+		sets = append(sets, fmt.Sprintf("notification_channel = $%d", len(args)))
+	}
+	if req.AvailabilitySelector != nil {
+		if err := validateAvailabilitySelector(req.AvailabilitySelector); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// What the old selector found no longer applies.
+		args = append(args, *req.AvailabilitySelector)
+		sets = append(sets, fmt.Sprintf("availability_selector = $%d, availability = NULL, availability_changed_at = NULL", len(args)))
+	}
+	if req.FrameSelector != nil || req.FrameURL != nil {
+		var selector, frameURL string
+		if req.FrameSelector != nil {
+			selector = *req.FrameSelector
+		}
+		if req.FrameURL != nil {
+			frameURL = *req.FrameURL
+		}
+		if err := validateFrame(&selector, &frameURL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, selector, frameURL)
+		sets = append(sets, fmt.Sprintf("frame_selector = $%d, frame_url = $%d", len(args)-1, len(args)))
+	}
+	if req.VariantSelector != nil || req.VariantValue != nil {
+		var selector, value string
+		if req.VariantSelector != nil {
+			selector = *req.VariantSelector
+		}
+		if req.VariantValue != nil {
+			value = *req.VariantValue
+		}
+		var cssSelector string
+		if strings.TrimSpace(selector) != "" {
+			err := db.QueryRowContext(ctx, `SELECT css_selector FROM tracked_items WHERE id = $1 AND user_id = $2`, id, userID).Scan(&cssSelector)
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Item not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				slog.Error("Failed to load item", "id", id, "error", err)
+				queryError(ctx, w, err, "Failed to update item", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := validateVariantSelection(&selector, &value, cssSelector); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, selector, value)
+		sets = append(sets, fmt.Sprintf("variant_selector = $%d, variant_value = $%d", len(args)-1, len(args)))
+	}
+	if req.ProxyURL != nil {
+		if code, err := validateItemProxy(userID, *req.ProxyURL); err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+		args = append(args, *req.ProxyURL)
+		sets = append(sets, fmt.Sprintf("proxy_url = $%d", len(args)))
+	}
+	if req.Cookies != nil || req.Headers != nil {
+		var pageURL string
+		err := db.QueryRowContext(ctx, `SELECT page_url FROM tracked_items WHERE id = $1 AND user_id = $2`, id, userID).Scan(&pageURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Item not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to load item", "id", id, "error", err)
+			queryError(ctx, w, err, "Failed to update item", http.StatusInternalServerError)
+			return
+		}
+		if req.Cookies != nil {
+			cookies, err := sealItemCookies(pageURL, *req.Cookies)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			args = append(args, cookies)
+			sets = append(sets, fmt.Sprintf("cookies_encrypted = $%d", len(args)))
+		}
+		if req.Headers != nil {
+			if err := validateItemHeaders(pageURL, *req.Headers); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			headers, err := encodeItemHeaders(*req.Headers)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			args = append(args, headers)
+			sets = append(sets, fmt.Sprintf("headers = $%d", len(args)))
+		}
+	}
+	// Any edit counts as an interaction, which keeps the item from being
+	// auto-paused for age (see MAX_ITEM_AGE in the scheduler), and checks it
+	// again on the next run however often it failed before.
+	sets = append(sets, "last_interacted_at = NOW(), updated_at = NOW(), failure_streak = 0, next_check_at = NULL")
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE tracked_items
+		SET `+strings.Join(sets, ", ")+`
+		WHERE id = $1 AND user_id = $2
+	`, args...)
+	if err != nil {
+		slog.Error("Failed to update item", "id", id, "error", err)
+		queryError(ctx, w, err, "Failed to update item", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		slog.Warn("Item not found", "id", id)
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("Updated item", "id", id, "paused", req.Paused, "parse_strategy", req.ParseStrategy, "adapter_order", req.AdapterOrder, "selector_match", req.SelectorMatch, "accept_language", req.AcceptLanguage, "country_code", req.CountryCode, "cookies_changed", req.Cookies != nil, "headers_changed", req.Headers != nil, "frame_selector", req.FrameSelector, "frame_url", req.FrameURL, "variant_selector", req.VariantSelector, "variant_value", req.VariantValue, "proxy_changed", req.ProxyURL != nil, "scrape_profile", req.ScrapeProfile, "max_scrape_seconds", req.MaxScrapeSeconds, "tags", req.Tags, "shipping_selector", req.ShippingSelector, "notification_channel", req.NotificationChannel, "availability_selector", req.AvailabilitySelector, "user_id", userID)
+	invalidateUserCache(userID)
+	w.WriteHeader(http.StatusNoContent)
+}
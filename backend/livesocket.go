@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var (
+	// liveSocketAuthTimeout is how long a connection opened without a token
+	// may take to send its auth message.
+	liveSocketAuthTimeout = 10 * time.Second
+	// liveSocketIdleTimeout is how long a connection may go without sending
+	// a message before it is closed. Clients keep it open with ping
+	// messages.
+	liveSocketIdleTimeout = 90 * time.Second
+	// liveSocketPingInterval is how often the server sends a ping frame, so
+	// that proxies do not close quiet connections.
+	liveSocketPingInterval = 30 * time.Second
+	// liveSocketWriteTimeout is how long one message may take to write. A
+	// client that does not read fast enough to stay within it is dropped.
+	liveSocketWriteTimeout = 10 * time.Second
+)
+
+const (
+	// liveSocketMaxMessage caps the size of client messages.
+	liveSocketMaxMessage = 64 << 10
+	// liveSocketQueue is how many replies may wait to be written before the
+	// client is considered too slow and dropped.
+	liveSocketQueue = 16
+)
+
+// Event types pushed on /ws.
+const (
+	liveEventPriceUpdate  = "price_update"
+	liveEventNotification = "notification"
+)
+
+// liveEvent is a message the server sends on /ws.
+type liveEvent struct {
+	Type    string `json:"type"`
+	Payload any    `json:"payload,omitempty"`
+}
+
+// liveRequest is a message a client sends on /ws: "auth" with a token (a
+// Supabase JWT) or apiKey, "subscribe" and "unsubscribe" with itemIds and,
+// for subscribe, events, and "ping".
+type liveRequest struct {
+	Type    string   `json:"type"`
+	Token   string   `json:"token,omitempty"`
+	APIKey  string   `json:"apiKey,omitempty"`
+	ItemIDs []string `json:"itemIds,omitempty"`
+	Events  []string `json:"events,omitempty"`
+}
+
+// authorization returns the Authorization header value equivalent to the
+// credentials in r.
+func (r liveRequest) authorization() string {
+	switch {
+	case r.APIKey != "":
+		return "ApiKey " + r.APIKey
+	case r.Token != "":
+		return "Bearer " + r.Token
+	}
+	return ""
+}
+
+// PriceUpdate is the payload of a price_update event: a price the scheduler
+// recorded for one of the user's items.
+type PriceUpdate struct {
+	ItemID     string    `json:"itemId"`
+	Price      float64   `json:"price"`
+	PriceText  string    `json:"priceText"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+// liveFilter is what one connection subscribed to. Nil fields mean
+// everything: all of the user's items and all event types. Once the
+// connection subscribes to items, itemIDs lists them, and unsubscribing from
+// the last one leaves it empty: no items at all.
+type liveFilter struct {
+	mu      sync.Mutex
+	itemIDs map[string]bool
+	events  []string
+}
+
+// subscribe adds itemIDs to the items the connection hears about and, if any
+// are given, replaces the event types.
+func (f *liveFilter) subscribe(itemIDs, events []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(itemIDs) > 0 && f.itemIDs == nil {
+		f.itemIDs = map[string]bool{}
+	}
+	for _, id := range itemIDs {
+		f.itemIDs[id] = true
+	}
+	if len(events) > 0 {
+		f.events = slices.Clone(events)
+	}
+}
+
+// unsubscribe removes itemIDs from the items the connection hears about, or
+// returns it to all items when none are given.
+func (f *liveFilter) unsubscribe(itemIDs []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(itemIDs) == 0 {
+		f.itemIDs = nil
+		return
+	}
+	for _, id := range itemIDs {
+		delete(f.itemIDs, id)
+	}
+}
+
+// allows reports whether an event of type event about itemID passes.
+func (f *liveFilter) allows(event, itemID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.events) > 0 && !slices.Contains(f.events, event) {
+		return false
+	}
+	return f.itemIDs == nil || f.itemIDs[itemID]
+}
+
+// state returns the filter as the payload of a "subscribed" event, where
+// null stands for everything.
+func (f *liveFilter) state() map[string][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []string
+	if f.itemIDs != nil {
+		ids = make([]string, 0, len(f.itemIDs))
+		for id := range f.itemIDs {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+	}
+	return map[string][]string{"itemIds": ids, "events": slices.Clone(f.events)}
+}
+
+// liveSocketHandler serves /ws.
+var liveSocketHandler = methods{"GET": openLiveSocketHandler}.ServeHTTP
+
+// openLiveSocketHandler upgrades to a WebSocket that pushes the user's new
+// notifications and recorded prices as they happen. Browsers cannot set
+// headers on a WebSocket, so the credentials come in the token or apiKey
+// query parameter or, to keep them out of access logs, in an auth message
+// sent first.
+func openLiveSocketHandler(w http.ResponseWriter, r *http.Request) {
+	userID := ""
+	if auth := (liveRequest{Token: r.URL.Query().Get("token"), APIKey: r.URL.Query().Get("apiKey")}).authorization(); auth != "" {
+		ctx, cancel := queryContext(r)
+		id, err := authenticate(ctx, auth)
+		cancel()
+		var rejected authError
+		if errors.As(err, &rejected) {
+			http.Error(w, string(rejected), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to look up API key", "error", err)
+			queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		userID = id
+	}
+
+	websocket.Server{
+		// Connections carry their own credentials rather than cookies, so
+		// any origin may open one.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			conn.MaxPayloadBytes = liveSocketMaxMessage
+			serveLiveSocket(r.Context(), conn, userID)
+		},
+	}.ServeHTTP(w, r)
+}
+
+// pingCodec sends a ping frame.
+var pingCodec = websocket.Codec{Marshal: func(any) ([]byte, byte, error) {
+	return nil, websocket.PingFrame, nil
+}}
+
+// liveSocket is one open /ws connection.
+type liveSocket struct {
+	conn   *websocket.Conn
+	userID string
+	filter liveFilter
+	// replies are answers to client messages, written by the writing
+	// goroutine.
+	replies chan liveEvent
+}
+
+// serveLiveSocket authenticates conn if userID is empty, then pushes events
+// until the client goes away, stops sending, or cannot keep up.
+func serveLiveSocket(ctx context.Context, conn *websocket.Conn, userID string) {
+	if userID == "" {
+		var err error
+		if userID, err = authenticateLiveSocket(ctx, conn); err != nil {
+			slog.Warn("Rejected live socket", "error", err)
+			conn.SetWriteDeadline(time.Now().Add(liveSocketWriteTimeout))
+			websocket.JSON.Send(conn, liveEvent{Type: "error", Payload: map[string]string{"message": err.Error()}})
+			return
+		}
+	}
+
+	s := &liveSocket{conn: conn, userID: userID, replies: make(chan liveEvent, liveSocketQueue)}
+
+	// Subscribe first so nothing recorded from here on is missed.
+	notificationsWake, unsubscribeNotifications := notificationStreams.subscribe(notificationInsertsChannel, userID)
+	defer unsubscribeNotifications()
+	pricesWake, unsubscribePrices := notificationStreams.subscribe(priceUpdatesChannel, userID)
+	defer unsubscribePrices()
+
+	notifications := notificationStream{userID: userID, sent: map[string]bool{}}
+	prices := priceStream{userID: userID}
+	queryCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+	err := db.QueryRowContext(queryCtx, `SELECT NOW(), COALESCE((SELECT MAX(id) FROM item_price_history), 0)`).Scan(&notifications.since, &prices.after)
+	cancel()
+	if err != nil {
+		slog.Error("Failed to open live socket", "error", err)
+		return
+	}
+
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		defer cancel()
+		s.read()
+	}()
+
+	if !s.send(liveEvent{Type: "ready"}) {
+		return
+	}
+
+	poll := time.NewTicker(notificationStreamPollInterval)
+	defer poll.Stop()
+	ping := time.NewTicker(liveSocketPingInterval)
+	defer ping.Stop()
+
+	slog.Info("Opened live socket", "user_id", userID, "listening", notificationStreams.isListening())
+	defer slog.Info("Closed live socket", "user_id", userID)
+	for {
+		sendNotifications, sendPrices := false, false
+		select {
+		case <-ctx.Done():
+			return
+		case reply := <-s.replies:
+			if !s.send(reply) {
+				return
+			}
+			continue
+		case <-ping.C:
+			s.conn.SetWriteDeadline(time.Now().Add(liveSocketWriteTimeout))
+			if err := pingCodec.Send(s.conn, nil); err != nil {
+				return
+			}
+			continue
+		case <-poll.C:
+			if notificationStreams.isListening() {
+				continue
+			}
+			sendNotifications, sendPrices = true, true
+		case <-notificationsWake:
+			sendNotifications = true
+		case <-pricesWake:
+			sendPrices = true
+		}
+
+		// A failed write ends the connection; a failed query is retried on
+		// the next wake-up.
+		var writeErr error
+		if sendNotifications {
+			err := notifications.send(ctx, func(n Notification) error {
+				itemID := ""
+				if n.ProductID != nil {
+					itemID = *n.ProductID
+				}
+				if s.filter.allows(liveEventNotification, itemID) && !s.send(liveEvent{Type: liveEventNotification, Payload: n}) {
+					writeErr = errLiveSocketWrite
+					return writeErr
+				}
+				return nil
+			})
+			if err != nil && writeErr == nil {
+				slog.Error("Failed to send notifications", "user_id", userID, "error", err)
+			}
+		}
+		if sendPrices && writeErr == nil {
+			err := prices.send(ctx, func(u PriceUpdate) error {
+				if s.filter.allows(liveEventPriceUpdate, u.ItemID) && !s.send(liveEvent{Type: liveEventPriceUpdate, Payload: u}) {
+					writeErr = errLiveSocketWrite
+					return writeErr
+				}
+				return nil
+			})
+			if err != nil && writeErr == nil {
+				slog.Error("Failed to send price updates", "user_id", userID, "error", err)
+			}
+		}
+		if writeErr != nil {
+			return
+		}
+	}
+}
+
+// errLiveSocketWrite stops a stream's send when the socket failed.
+var errLiveSocketWrite = errors.New("live socket write failed")
+
+// authenticateLiveSocket reads the auth message a connection opened without
+// credentials must start with and returns its user.
+func authenticateLiveSocket(ctx context.Context, conn *websocket.Conn) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(liveSocketAuthTimeout))
+	var req liveRequest
+	if err := websocket.JSON.Receive(conn, &req); err != nil {
+		return "", err
+	}
+	if req.Type != "auth" {
+		return "", authError("Expected an auth message")
+	}
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	return authenticate(ctx, req.authorization())
+}
+
+// send writes event, and reports whether it could within
+// liveSocketWriteTimeout.
+func (s *liveSocket) send(event liveEvent) bool {
+	s.conn.SetWriteDeadline(time.Now().Add(liveSocketWriteTimeout))
+	if err := websocket.JSON.Send(s.conn, event); err != nil {
+		slog.Warn("Dropping live socket", "user_id", s.userID, "error", err)
+		return false
+	}
+	return true
+}
+
+// read handles client messages until the client goes away or stays silent
+// for liveSocketIdleTimeout. It drops the connection when replies pile up
+// faster than they can be written.
+func (s *liveSocket) read() {
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(liveSocketIdleTimeout))
+		var req liveRequest
+		if err := websocket.JSON.Receive(s.conn, &req); err != nil {
+			return
+		}
+		var reply liveEvent
+		switch req.Type {
+		case "ping":
+			reply = liveEvent{Type: "pong"}
+		case "subscribe":
+			s.filter.subscribe(req.ItemIDs, req.Events)
+			reply = liveEvent{Type: "subscribed", Payload: s.filter.state()}
+		case "unsubscribe":
+			s.filter.unsubscribe(req.ItemIDs)
+			reply = liveEvent{Type: "subscribed", Payload: s.filter.state()}
+		default:
+			reply = liveEvent{Type: "error", Payload: map[string]string{"message": "Unknown message type " + req.Type}}
+		}
+		select {
+		case s.replies <- reply:
+		default:
+			slog.Warn("Dropping live socket that does not keep up", "user_id", s.userID)
+			return
+		}
+	}
+}
+
+// priceStream tracks what one open connection has sent: after is the ID of
+// the newest price history row.
+type priceStream struct {
+	userID string
+	after  int64
+}
+
+// send passes the prices recorded for the user's items since the last call
+// to emit.
+func (s *priceStream) send(ctx context.Context, emit func(PriceUpdate) error) error {
+	for {
+		page, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+		for _, p := range page {
+			if err := emit(p.PriceUpdate); err != nil {
+				return err
+			}
+			s.after = p.id
+		}
+		if len(page) < notificationStreamBatch {
+			return nil
+		}
+	}
+}
+
+// streamedPrice is a price update with the ID of its history row, which
+// orders the stream.
+type streamedPrice struct {
+	PriceUpdate
+	id int64
+}
+
+// load reads the next batch of price updates. The rows are closed before
+// send writes any of them, so that a slow client does not hold a database
+// connection.
+func (s *priceStream) load(ctx context.Context) ([]streamedPrice, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT h.id, h.item_id, h.price, t.price_text, h.recorded_at
+		FROM item_price_history h
+		JOIN tracked_items t ON t.id = h.item_id
+		WHERE t.user_id = $1 AND h.id > $2
+		ORDER BY h.id
+		LIMIT $3
+	`, s.userID, s.after, notificationStreamBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var page []streamedPrice
+	for rows.Next() {
+		var p streamedPrice
+		if err := rows.Scan(&p.id, &p.ItemID, &p.Price, &p.PriceText, &p.RecordedAt); err != nil {
+			return nil, err
+		}
+		page = append(page, p)
+	}
+	return page, rows.Err()
+}
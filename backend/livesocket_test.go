@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"golang.org/x/net/websocket"
+)
+
+// liveSocketToken returns a JWT for userID signed with a test secret, which
+// it installs.
+func liveSocketToken(t *testing.T, userID string) string {
+	t.Helper()
+	prev := jwtSecret
+	jwtSecret = "live-socket-secret"
+	t.Cleanup(func() { jwtSecret = prev })
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": userID}).SignedString([]byte(jwtSecret))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+// liveSocketServer serves /ws on a test server. Its cleanup waits for open
+// connections to finish, so that they are done with the mock database.
+func liveSocketServer(t *testing.T, listener net.Listener) *httptest.Server {
+	t.Helper()
+	var handlers sync.WaitGroup
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.Add(1)
+		defer handlers.Done()
+		liveSocketHandler(w, r)
+	}))
+	if listener != nil {
+		ts.Listener.Close()
+		ts.Listener = listener
+	}
+	ts.Start()
+	t.Cleanup(handlers.Wait)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// dialLiveSocket opens a WebSocket to ts with query as the query string.
+func dialLiveSocket(t *testing.T, ts *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?"+query, "", "http://localhost/")
+	if err != nil {
+		t.Fatalf("Failed to open live socket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// receivedEvent is a liveEvent as a client decodes it.
+type receivedEvent struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// readLiveEvent reads the next event from conn.
+func readLiveEvent(t *testing.T, conn *websocket.Conn) receivedEvent {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event receivedEvent
+	if err := websocket.JSON.Receive(conn, &event); err != nil {
+		t.Fatalf("Failed to read event: %v", err)
+	}
+	return event
+}
+
+// expectLiveSocketOpened expects the query a connection starts from.
+func expectLiveSocketOpened(mock sqlmock.Sqlmock, opened time.Time, lastHistoryID int64) {
+	mock.ExpectQuery(`SELECT NOW\(\), COALESCE\(\(SELECT MAX\(id\) FROM item_price_history\), 0\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"now", "max"}).AddRow(opened, lastHistoryID))
+}
+
+var priceUpdateColumns = []string{"id", "item_id", "price", "price_text", "recorded_at"}
+
+func TestLiveSocket_QueryTokenPushesNotification(t *testing.T) {
+	mock := setupMockDB(t)
+	hub := newNotificationHub()
+	hub.setListening(true)
+	setNotificationStreams(t, hub)
+
+	opened := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+	expectLiveSocketOpened(mock, opened, 0)
+	mock.ExpectQuery("FROM notifications").
+		WithArgs("user-1", opened, notificationStreamBatch).
+		WillReturnRows(sqlmock.NewRows(notificationStreamColumns).AddRow(notificationStreamRow("n-1", opened.Add(time.Second))...))
+
+	ts := liveSocketServer(t, nil)
+	conn := dialLiveSocket(t, ts, "token="+liveSocketToken(t, "user-1"))
+	if event := readLiveEvent(t, conn); event.Type != "ready" {
+		t.Fatalf("Expected ready, got %+v", event)
+	}
+
+	hub.dispatch(&pq.Notification{Channel: notificationInsertsChannel, Extra: "user-2"})
+	hub.dispatch(&pq.Notification{Channel: notificationInsertsChannel, Extra: "user-1"})
+
+	event := readLiveEvent(t, conn)
+	var n Notification
+	if err := json.Unmarshal(event.Payload, &n); err != nil || event.Type != liveEventNotification || n.ID != "n-1" {
+		t.Errorf("Expected notification n-1, got %s %s", event.Type, event.Payload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestLiveSocket_AuthMessageAndSubscriptionFilter(t *testing.T) {
+	mock := setupMockDB(t)
+	hub := newNotificationHub()
+	hub.setListening(true)
+	setNotificationStreams(t, hub)
+
+	opened := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+	expectLiveSocketOpened(mock, opened, 40)
+	mock.ExpectQuery(`FROM item_price_history h\s+JOIN tracked_items t ON t.id = h.item_id\s+WHERE t.user_id = \$1 AND h.id > \$2`).
+		WithArgs("user-1", int64(40), notificationStreamBatch).
+		WillReturnRows(sqlmock.NewRows(priceUpdateColumns).
+			AddRow(int64(41), "item-1", 10.5, "$10.50", opened.Add(time.Second)).
+			AddRow(int64(42), "item-2", 19.99, "$19.99", opened.Add(time.Second)))
+	// The next query starts after the last update seen, even one filtered out.
+	mock.ExpectQuery("FROM item_price_history").
+		WithArgs("user-1", int64(42), notificationStreamBatch).
+		WillReturnRows(sqlmock.NewRows(priceUpdateColumns).
+			AddRow(int64(43), "item-2", 18.00, "$18.00", opened.Add(2*time.Second)))
+
+	ts := liveSocketServer(t, nil)
+	conn := dialLiveSocket(t, ts, "")
+	if err := websocket.JSON.Send(conn, liveRequest{Type: "auth", Token: liveSocketToken(t, "user-1")}); err != nil {
+		t.Fatalf("Failed to send auth: %v", err)
+	}
+	if event := readLiveEvent(t, conn); event.Type != "ready" {
+		t.Fatalf("Expected ready, got %+v", event)
+	}
+
+	if err := websocket.JSON.Send(conn, liveRequest{Type: "subscribe", ItemIDs: []string{"item-2"}, Events: []string{liveEventPriceUpdate}}); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if event := readLiveEvent(t, conn); event.Type != "subscribed" || string(event.Payload) != `{"events":["price_update"],"itemIds":["item-2"]}` {
+		t.Fatalf("Expected the subscription confirmed, got %s %s", event.Type, event.Payload)
+	}
+
+	for _, want := range []float64{19.99, 18.00} {
+		hub.dispatch(&pq.Notification{Channel: priceUpdatesChannel, Extra: "user-1"})
+		event := readLiveEvent(t, conn)
+		var u PriceUpdate
+		if err := json.Unmarshal(event.Payload, &u); err != nil || event.Type != liveEventPriceUpdate || u.ItemID != "item-2" || u.Price != want {
+			t.Errorf("Expected item-2 at %v, got %s %s", want, event.Type, event.Payload)
+		}
+	}
+
+	if err := websocket.JSON.Send(conn, liveRequest{Type: "ping"}); err != nil {
+		t.Fatalf("Failed to send ping: %v", err)
+	}
+	if event := readLiveEvent(t, conn); event.Type != "pong" {
+		t.Errorf("Expected pong, got %+v", event)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestLiveSocket_UnsubscribingLastItemStopsEvents(t *testing.T) {
+	mock := setupMockDB(t)
+	hub := newNotificationHub()
+	hub.setListening(true)
+	setNotificationStreams(t, hub)
+
+	opened := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+	expectLiveSocketOpened(mock, opened, 40)
+	mock.ExpectQuery("FROM item_price_history").
+		WithArgs("user-1", int64(40), notificationStreamBatch).
+		WillReturnRows(sqlmock.NewRows(priceUpdateColumns).
+			AddRow(int64(41), "item-1", 10.5, "$10.50", opened.Add(time.Second)).
+			AddRow(int64(42), "item-2", 19.99, "$19.99", opened.Add(time.Second)))
+
+	ts := liveSocketServer(t, nil)
+	conn := dialLiveSocket(t, ts, "token="+liveSocketToken(t, "user-1"))
+	if event := readLiveEvent(t, conn); event.Type != "ready" {
+		t.Fatalf("Expected ready, got %+v", event)
+	}
+
+	for _, step := range []struct {
+		request liveRequest
+		state   string
+	}{
+		{liveRequest{Type: "subscribe", ItemIDs: []string{"item-1"}}, `{"events":null,"itemIds":["item-1"]}`},
+		{liveRequest{Type: "unsubscribe", ItemIDs: []string{"item-1"}}, `{"events":null,"itemIds":[]}`},
+	} {
+		if err := websocket.JSON.Send(conn, step.request); err != nil {
+			t.Fatalf("Failed to send %s: %v", step.request.Type, err)
+		}
+		if event := readLiveEvent(t, conn); event.Type != "subscribed" || string(event.Payload) != step.state {
+			t.Fatalf("Expected %s after %s, got %s %s", step.state, step.request.Type, event.Type, event.Payload)
+		}
+	}
+
+	// Once the updates were loaded, anything sent for them comes before the
+	// pong.
+	hub.dispatch(&pq.Notification{Channel: priceUpdatesChannel, Extra: "user-1"})
+	deadline := time.Now().Add(5 * time.Second)
+	for mock.ExpectationsWereMet() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the price updates to be loaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := websocket.JSON.Send(conn, liveRequest{Type: "ping"}); err != nil {
+		t.Fatalf("Failed to send ping: %v", err)
+	}
+	if event := readLiveEvent(t, conn); event.Type != "pong" {
+		t.Errorf("Expected no events after unsubscribing from every item, got %s %s", event.Type, event.Payload)
+	}
+}
+
+func TestLiveSocket_RejectsInvalidCredentials(t *testing.T) {
+	setupMockDB(t)
+	liveSocketToken(t, "user-1")
+	ts := liveSocketServer(t, nil)
+
+	t.Run("query parameter", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/ws?token=not-a-jwt")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+		}
+	})
+
+	t.Run("auth message", func(t *testing.T) {
+		conn := dialLiveSocket(t, ts, "")
+		if err := websocket.JSON.Send(conn, liveRequest{Type: "auth", Token: "not-a-jwt"}); err != nil {
+			t.Fatalf("Failed to send auth: %v", err)
+		}
+		if event := readLiveEvent(t, conn); event.Type != "error" || !strings.Contains(string(event.Payload), "Invalid token") {
+			t.Errorf("Expected an invalid token error, got %s %s", event.Type, event.Payload)
+		}
+		var event receivedEvent
+		if err := websocket.JSON.Receive(conn, &event); err == nil {
+			t.Errorf("Expected the connection closed, got %+v", event)
+		}
+	})
+}
+
+// smallBufferListener shrinks the send buffer of accepted connections, so
+// that a client that stops reading blocks the server quickly.
+type smallBufferListener struct {
+	net.Listener
+}
+
+func (l smallBufferListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetWriteBuffer(4096)
+	}
+	return conn, err
+}
+
+func TestLiveSocket_DropsSlowClient(t *testing.T) {
+	mock := setupMockDB(t)
+	setNotificationStreams(t, newNotificationHub())
+	prev := liveSocketWriteTimeout
+	liveSocketWriteTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { liveSocketWriteTimeout = prev })
+	expectLiveSocketOpened(mock, time.Now(), 0)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ts := liveSocketServer(t, smallBufferListener{listener})
+
+	raw, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	raw.(*net.TCPConn).SetReadBuffer(4096)
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?token="+liveSocketToken(t, "user-1"), "http://localhost/")
+	if err != nil {
+		t.Fatalf("Failed to configure client: %v", err)
+	}
+	conn, err := websocket.NewClient(config, raw)
+	if err != nil {
+		t.Fatalf("Failed to open live socket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	// Ask for pongs without ever reading them.
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < 100000; i++ {
+		if err := websocket.JSON.Send(conn, liveRequest{Type: "ping"}); err != nil {
+			break
+		}
+	}
+
+	// Whatever was written before the drop can still be read, then the
+	// connection ends instead of idling until the deadline.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var event receivedEvent
+		err := websocket.JSON.Receive(conn, &event)
+		if err == nil {
+			continue
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			t.Fatal("Expected the slow client to be dropped, but the connection stayed open")
+		}
+		break
+	}
+}
+
+// queryWhileWriting is an emit that, like a slow client, only returns once
+// another query could run, which it cannot while the stream holds the only
+// connection.
+func queryWhileWriting(t *testing.T) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var one int
+	return db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
+func TestPriceStream_ReleasesConnectionBeforeWriting(t *testing.T) {
+	mock := setupMockDB(t)
+	db.SetMaxOpenConns(1)
+
+	recorded := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM item_price_history").
+		WithArgs("user-1", int64(0), notificationStreamBatch).
+		WillReturnRows(sqlmock.NewRows(priceUpdateColumns).AddRow(int64(1), "item-1", 10.5, "$10.50", recorded))
+	mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
+
+	stream := priceStream{userID: "user-1"}
+	err := stream.send(context.Background(), func(PriceUpdate) error { return queryWhileWriting(t) })
+	if err != nil {
+		t.Fatalf("Expected the rows to be closed before writing, got %v", err)
+	}
+	if stream.after != 1 {
+		t.Errorf("Expected the stream to move past row 1, got %d", stream.after)
+	}
+}
+
+func TestNotificationStream_ReleasesConnectionBeforeWriting(t *testing.T) {
+	mock := setupMockDB(t)
+	db.SetMaxOpenConns(1)
+
+	opened := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM notifications").
+		WithArgs("user-1", opened, notificationStreamBatch).
+		WillReturnRows(sqlmock.NewRows(notificationStreamColumns).AddRow(notificationStreamRow("n-1", opened.Add(time.Second))...))
+	mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
+
+	stream := notificationStream{userID: "user-1", since: opened, sent: map[string]bool{}}
+	err := stream.send(context.Background(), func(Notification) error { return queryWhileWriting(t) })
+	if err != nil {
+		t.Fatalf("Expected the rows to be closed before writing, got %v", err)
+	}
+	if !stream.sent["n-1"] {
+		t.Errorf("Expected n-1 to be recorded as sent")
+	}
+}
@@ -4,38 +4,39 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+
+	"price-track-backend/internal/adapters"
+	"price-track-backend/internal/cache"
+	"price-track-backend/internal/config"
+	"price-track-backend/internal/email"
+	"price-track-backend/internal/realtime"
+	"price-track-backend/internal/redisstore"
+	"price-track-backend/internal/scheduler"
+	"price-track-backend/internal/settings"
+	"price-track-backend/internal/version"
 )
 
-type TrackedItem struct {
-	ID               string `json:"id"`
-	PriceText        string `json:"priceText"`
-	ProductName      string `json:"productName"`
-	ImageURL         string `json:"imageUrl"`
-	CSSSelector      string `json:"cssSelector"`
-	XPath            string `json:"xPath"`
-	PageURL          string `json:"pageUrl"`
-	OuterHTMLSnippet string `json:"outerHtmlSnippet"`
-	CapturedAtISO    string `json:"capturedAtIso"`
-	SavedAtISO       string `json:"savedAtIso"`
-	LastScrapeStatus string `json:"lastScrapeStatus"`
-}
-
 type Notification struct {
 	ID        string  `json:"id"`
 	UserID    string  `json:"userId"`
 	Title     string  `json:"title"`
 	Message   string  `json:"message"`
 	Type      string  `json:"type"`
+	Severity  string  `json:"severity"` // see scheduler.Severity
 	ProductID *string `json:"productId,omitempty"`
 	OldPrice  *string `json:"oldPrice,omitempty"`
 	NewPrice  *string `json:"newPrice,omitempty"`
@@ -59,7 +60,7 @@ func Chain(f http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
 func CORSMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, PATCH")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, HEAD, OPTIONS, PUT, DELETE, PATCH")
 		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
 
 		if r.Method == "OPTIONS" {
@@ -83,287 +84,305 @@ type contextKey string
 
 const userIDKey contextKey = "userID"
 
+// jwtSecret verifies Supabase session tokens. It is set from the
+// configuration in main.
+var jwtSecret string
+
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+		lookupCtx, cancel := queryContext(r)
+		userID, err := authenticate(lookupCtx, r.Header.Get("Authorization"))
+		cancel()
+		var rejected authError
+		if errors.As(err, &rejected) {
+			http.Error(w, string(rejected), http.StatusUnauthorized)
 			return
 		}
-
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid Authorization header format", http.StatusUnauthorized)
+		if err != nil {
+			slog.Error("Failed to look up API key", "error", err)
+			queryError(lookupCtx, w, err, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-		tokenString := parts[1]
+		ctx := context.WithValue(r.Context(), userIDKey, userID)
+		next(w, r.WithContext(ctx))
+	}
+}
 
-		secret := os.Getenv("SUPABASE_JWT_SECRET")
-		if secret == "" {
-			slog.Error("SUPABASE_JWT_SECRET is not set")
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
+// authError is a credential authenticate rejected. Its text is the 401
+// response body.
+type authError string
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(secret), nil
-		})
+func (e authError) Error() string { return string(e) }
 
-		if err != nil || !token.Valid {
-			slog.Warn("Invalid token", "error", err)
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
+// authenticate returns the user an Authorization header value belongs to:
+// "Bearer <Supabase JWT>" or "ApiKey <key>". Credentials it rejects are an
+// authError; other errors come from looking up API keys.
+func authenticate(ctx context.Context, authHeader string) (string, error) {
+	if authHeader == "" {
+		return "", authError("Missing Authorization header")
+	}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(w, "Invalid token claims", http.StatusUnauthorized)
-			return
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != "ApiKey") {
+		return "", authError("Invalid Authorization header format")
+	}
+
+	if parts[0] == "ApiKey" {
+		userID, err := authenticateAPIKey(ctx, parts[1])
+		if err == sql.ErrNoRows {
+			slog.Warn("Invalid API key")
+			return "", authError("Invalid API key")
 		}
+		return userID, err
+	}
+	tokenString := parts[1]
 
-		sub, ok := claims["sub"].(string)
-		if !ok || sub == "" {
-			http.Error(w, "Token missing sub claim", http.StatusUnauthorized)
-			return
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
+		return []byte(jwtSecret), nil
+	})
 
-		ctx := context.WithValue(r.Context(), userIDKey, sub)
-		next(w, r.WithContext(ctx))
+	if err != nil || !token.Valid {
+		slog.Warn("Invalid token", "error", err)
+		return "", authError("Invalid token")
 	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", authError("Invalid token claims")
+	}
+
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return "", authError("Token missing sub claim")
+	}
+	return sub, nil
 }
 
-func itemsHandler(w http.ResponseWriter, r *http.Request) {
+// notificationsHandler serves /notifications.
+var notificationsHandler = methods{"GET": listNotificationsHandler}.ServeHTTP
+
+func listNotificationsHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(userIDKey).(string)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	switch r.Method {
-	case "GET":
-		rows, err := db.Query(`
-			SELECT id, price_text, product_name, image_url, css_selector, xpath, page_url, outer_html_snippet, captured_at, saved_at, last_scrape_status
-			FROM tracked_items 
-			WHERE user_id = $1
-			ORDER BY created_at DESC
-		`, userID)
-		if err != nil {
-			slog.Error("Failed to query items", "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		items := []TrackedItem{}
-		for rows.Next() {
-			var i TrackedItem
-			var capturedAt, savedAt time.Time
-			var lastScrapeStatus sql.NullString
-			if err := rows.Scan(
-				&i.ID, &i.PriceText, &i.ProductName, &i.ImageURL, &i.CSSSelector, &i.XPath, &i.PageURL, &i.OuterHTMLSnippet, &capturedAt, &savedAt, &lastScrapeStatus,
-			); err != nil {
-				slog.Error("Failed to scan item", "error", err)
-				continue
-			}
-			i.CapturedAtISO = capturedAt.Format(time.RFC3339)
-			i.SavedAtISO = savedAt.Format(time.RFC3339)
-			if lastScrapeStatus.Valid {
-				i.LastScrapeStatus = lastScrapeStatus.String
-			} else {
-				i.LastScrapeStatus = "pending"
-			}
-			items = append(items, i)
-		}
-
-		slog.Info("Returning items", "count", len(items), "user_id", userID)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(items)
-
-	case "POST":
-		var item TrackedItem
-		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-			slog.Error("Failed to decode item", "error", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		capturedAt, err := time.Parse(time.RFC3339, item.CapturedAtISO)
-		if err != nil {
-			slog.Error("Failed to parse capturedAtIso", "error", err)
-			http.Error(w, "Invalid capturedAtIso", http.StatusBadRequest)
-			return
-		}
-		savedAt, err := time.Parse(time.RFC3339, item.SavedAtISO)
-		if err != nil {
-			slog.Error("Failed to parse savedAtIso", "error", err)
-			http.Error(w, "Invalid savedAtIso", http.StatusBadRequest)
-			return
-		}
-
-		_, err = db.Exec(`
-			INSERT INTO tracked_items (id, price_text, product_name, image_url, css_selector, xpath, page_url, outer_html_snippet, captured_at, saved_at, user_id)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		`, item.ID, item.PriceText, item.ProductName, item.ImageURL, item.CSSSelector, item.XPath, item.PageURL, item.OuterHTMLSnippet, capturedAt, savedAt, userID)
-
-		if err != nil {
-			slog.Error("Failed to insert item", "error", err)
-			http.Error(w, "Failed to save item", http.StatusInternalServerError)
-			return
-		}
+	limit, offset, err := parsePagination(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		slog.Info("Received and saved item", "id", item.ID, "productName", item.ProductName, "user_id", userID)
+	ctx, cancel := queryContext(r)
+	defer cancel()
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(item)
+	severities, err := parseSeverities(r.URL.Query().Get("severity"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	where, args := unreadNotificationsWhere(userID, severities)
+	notifications, err := queryNotifications(ctx, where, args, limit, offset)
+	if err != nil {
+		slog.Error("Failed to query notifications", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
-	case "DELETE":
-		_, err := db.Exec("DELETE FROM tracked_items WHERE user_id = $1", userID)
-		if err != nil {
-			slog.Error("Failed to delete all items", "error", err)
-			http.Error(w, "Failed to delete items", http.StatusInternalServerError)
+	// Only the envelope reports a total, so the legacy shape skips the count.
+	total := int64(len(notifications))
+	if limit > 0 && wantsEnvelope(r) {
+		if err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM notifications WHERE `+where, args...).Scan(&total); err != nil {
+			slog.Error("Failed to count notifications", "error", err)
+			queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
+	}
 
-		slog.Info("Cleared all items", "user_id", userID)
-		w.WriteHeader(http.StatusNoContent)
+	slog.Info("Returning notifications", "count", len(notifications), "user_id", userID)
+	respondList(w, r, newListMeta(total, limit, offset), jsonList(notifications))
+}
 
-	default:
-		slog.Warn("Method not allowed", "method", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// unreadNotificationsWhere returns the WHERE clause selecting the user's
+// unread notifications of the given severities (all if nil), and its args.
+func unreadNotificationsWhere(userID string, severities []string) (string, []any) {
+	where := "user_id = $1 AND is_read = false"
+	args := []any{userID}
+	if severities != nil {
+		args = append(args, pq.Array(severities))
+		where += fmt.Sprintf(" AND severity = ANY($%d)", len(args))
 	}
+	return where, args
 }
 
-func itemHandler(w http.ResponseWriter, r *http.Request) {
-	userID, ok := r.Context().Value(userIDKey).(string)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+// queryNotifications reads a page of the notifications where selects, newest
+// first. A zero limit reads them all. Rows that cannot be scanned are logged
+// and skipped.
+func queryNotifications(ctx context.Context, where string, args []any, limit, offset int) ([]Notification, error) {
+	query := `
+		SELECT ` + notificationColumns + `
+		FROM notifications
+		WHERE ` + where + `
+		ORDER BY created_at DESC, id DESC
+	`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		args = append(args[:len(args):len(args)], limit, offset)
 	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	id := r.PathValue("id")
-
-	if r.Method == "DELETE" {
-		result, err := db.Exec("DELETE FROM tracked_items WHERE id = $1 AND user_id = $2", id, userID)
+	notifications := []Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
 		if err != nil {
-			slog.Error("Failed to delete item", "id", id, "error", err)
-			http.Error(w, "Failed to delete item", http.StatusInternalServerError)
-			return
+			slog.Error("Failed to scan notification", "error", err)
+			continue
 		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
 
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			slog.Warn("Item not found", "id", id)
-			http.Error(w, "Item not found", http.StatusNotFound)
-			return
+// parseSeverities reads the severity filter of GET /notifications, a comma
+// separated list. Nil means no filter.
+func parseSeverities(v string) ([]string, error) {
+	if v == "" {
+		return nil, nil
+	}
+	severities := strings.Split(v, ",")
+	for _, severity := range severities {
+		if !scheduler.ValidSeverity(severity) {
+			return nil, fmt.Errorf("severity must be info, notice or alert, or a comma separated list of them")
 		}
+	}
+	return severities, nil
+}
 
-		w.WriteHeader(http.StatusNoContent)
-		return
+// notificationColumns are the columns scanNotification reads.
+const notificationColumns = `id, user_id, title, message, type, product_id, old_price, new_price, is_read, created_at, read_at, severity`
+
+// scanNotification reads one row selected with notificationColumns, followed
+// by any extra columns into extra.
+func scanNotification(rows *sql.Rows, extra ...any) (Notification, error) {
+	var n Notification
+	var productID, oldPrice, newPrice sql.NullString
+	var isRead sql.NullBool
+	var createdAt sql.NullTime
+	var readAt sql.NullTime
+
+	dest := append([]any{&n.ID, &n.UserID, &n.Title, &n.Message, &n.Type, &productID, &oldPrice, &newPrice, &isRead, &createdAt, &readAt, &n.Severity}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return n, err
 	}
 
-	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if productID.Valid {
+		n.ProductID = &productID.String
+	}
+	if oldPrice.Valid {
+		n.OldPrice = &oldPrice.String
+	}
+	if newPrice.Valid {
+		n.NewPrice = &newPrice.String
+	}
+	n.IsRead = isRead.Valid && isRead.Bool
+	if createdAt.Valid {
+		n.CreatedAt = createdAt.Time.Format(time.RFC3339)
+	}
+	if readAt.Valid {
+		formatted := readAt.Time.Format(time.RFC3339)
+		n.ReadAt = &formatted
+	}
+	return n, nil
 }
 
-func notificationsHandler(w http.ResponseWriter, r *http.Request) {
+// notificationsCountHandler serves /notifications/count.
+var notificationsCountHandler = methods{"GET": countNotificationsHandler}.ServeHTTP
+
+func countNotificationsHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(userIDKey).(string)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	ctx, cancel := queryContext(r)
+	defer cancel()
 
-	rows, err := db.Query(`
-		SELECT id, user_id, title, message, type, product_id, old_price, new_price, is_read, created_at, read_at
-		FROM notifications
-		WHERE user_id = $1 AND is_read = false
-		ORDER BY created_at DESC
-	`, userID)
+	resp, err := responseCache.GetOrLoad(userCacheKey(userID, "notifications-count"), func() (cachedResponse, error) {
+		var unread int
+		err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM notifications
+			WHERE user_id = $1 AND is_read = false
+		`, userID).Scan(&unread)
+		if err != nil {
+			return cachedResponse{}, err
+		}
+		body, err := json.Marshal(map[string]int{"unread": unread})
+		return cachedResponse{Body: body}, err
+	})
 	if err != nil {
-		slog.Error("Failed to query notifications", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		slog.Error("Failed to count notifications", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	notifications := []Notification{}
-	for rows.Next() {
-		var n Notification
-		var productID, oldPrice, newPrice sql.NullString
-		var isRead sql.NullBool
-		var createdAt sql.NullTime
-		var readAt sql.NullTime
-
-		if err := rows.Scan(&n.ID, &n.UserID, &n.Title, &n.Message, &n.Type, &productID, &oldPrice, &newPrice, &isRead, &createdAt, &readAt); err != nil {
-			slog.Error("Failed to scan notification", "error", err)
-			continue
-		}
-
-		if productID.Valid {
-			n.ProductID = &productID.String
-		}
-		if oldPrice.Valid {
-			n.OldPrice = &oldPrice.String
-		}
-		if newPrice.Valid {
-			n.NewPrice = &newPrice.String
-		}
-		n.IsRead = isRead.Valid && isRead.Bool
-		if createdAt.Valid {
-			n.CreatedAt = createdAt.Time.Format(time.RFC3339)
-		}
-		if readAt.Valid {
-			formatted := readAt.Time.Format(time.RFC3339)
-			n.ReadAt = &formatted
-		}
-
-		notifications = append(notifications, n)
-	}
-
-	slog.Info("Returning notifications", "count", len(notifications), "user_id", userID)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(notifications)
+	w.Write(resp.Body)
 }
 
-func markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+// markNotificationReadHandler serves /notifications/{id}/read.
+var markNotificationReadHandler = methods{"PATCH": markNotificationHandler}.ServeHTTP
+
+func markNotificationHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(userIDKey).(string)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	if r.Method != "PATCH" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+	if err := markNotificationRead(ctx, userID, id); err != nil {
+		slog.Error("Failed to mark notification read", "id", id, "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	id := r.PathValue("id")
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	result, err := db.Exec(`
+// markNotificationRead marks the user's notification id read. One that is
+// already read, or not the user's, is left alone.
+func markNotificationRead(ctx context.Context, userID, id string) error {
+	result, err := db.ExecContext(ctx, `
 		UPDATE notifications 
 		SET read_at = NOW(), is_read = true 
 		WHERE id = $1 AND user_id = $2 AND is_read = false
 	`, id, userID)
 	if err != nil {
-		slog.Error("Failed to mark notification read", "id", id, "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		return err
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
 		// Either not found or already read - either way, return success
 		slog.Info("Notification already read or not found", "id", id)
+	} else {
+		invalidateUserCache(userID)
 	}
-
-	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
 
 func main() {
@@ -375,39 +394,142 @@ func main() {
 		slog.Warn("No .env file found, relying on system environment variables")
 	}
 
-	connStr := os.Getenv("DATABASE_URL")
-	if connStr == "" {
-		slog.Error("DATABASE_URL environment variable is not set")
+	info := version.Get()
+	slog.Info("Starting PriceTrack API", "version", info.Version, "commit", info.Commit, "build_date", info.BuildDate, "go_version", info.GoVersion)
+
+	cfg, err := config.LoadAPI(os.Getenv)
+	if err != nil {
+		slog.Error("Refusing to start", "error", err)
 		os.Exit(1)
 	}
 
-	var err error
-	db, err = sql.Open("postgres", connStr)
+	jwtSecret = cfg.JWTSecret
+	adminUserIDs = cfg.AdminUserIDs
+	trustedProxies = cfg.TrustedProxies
+	responseCache = cache.New[cachedResponse](cfg.CacheTTL)
+	queryTimeout = cfg.QueryTimeout
+	itemsQuotaDefault = cfg.ItemsQuotaDefault
+	schedulerInterval = cfg.SchedulerInterval
+	emailSender = email.NewSender(cfg.SMTP)
+	emailTokenSecret = []byte(cfg.EmailTokenSecret)
+	publicURL = cfg.PublicURL
+	cookieBox = cfg.Cookies
+	blobs = cfg.Blobs
+	trendingDisabled = cfg.TrendingDisabled
+	apiDocsEnabled = cfg.APIDocs
+	features = enabledFeatures(cfg)
+	trendingCache = cache.New[cachedResponse](trendingCacheTTL)
+
+	db, err = sql.Open("postgres", cfg.DatabaseURL)
 	if err != nil {
 		slog.Error("Failed to open database connection", "error", err)
 		os.Exit(1)
 	}
+	settingsLoader = settings.NewLoader(db, settingsCacheTTL)
+	cfg.Scheduler.Email = email.NewNotifier(db, emailSender, emailTokenSecret, publicURL, cfg.EmailTemplates)
+	cfg.Scheduler.Realtime = realtime.NewBroadcaster(cfg.Realtime)
+	adapters.Configure(cfg.SiteSelectors)
+	previewScraper.AllowPrivateAddresses(cfg.Scheduler.PrivateAddressesAllowed)
+	scrapePrivateAddressesAllowed = cfg.Scheduler.PrivateAddressesAllowed
+	sch := scheduler.New(db, cfg.Scheduler)
+	brokenRevalidator = sch
+	priceChecker = sch
+	scrapeDiffer = sch
+	jobQueue = sch
 
 	if err := db.Ping(); err != nil {
 		slog.Error("Failed to ping database", "error", err)
 		os.Exit(1)
 	}
 	slog.Info("Connected to database")
+	checkIndexes(context.Background(), db, cfg.AutoMigrate)
+
+	notificationStreams = newNotificationHub()
+	go notificationStreams.listen(context.Background(), cfg.DatabaseURL)
+
+	if cfg.RedisURL != "" {
+		redis, err := redisstore.Open(context.Background(), cfg.RedisURL)
+		if err != nil {
+			slog.Error("Failed to connect to Redis", "error", err)
+			os.Exit(1)
+		}
+		responseCache.Share(redis, "responses:")
+		trendingCache.Share(redis, "trending:")
+		settingsLoader.Share(redis)
+		sharedRateLimits = redis
+		notificationStreams.relayThrough(redis.Relay(context.Background()))
+		slog.Info("Sharing caches, rate limits and stream wake-ups through Redis")
+	}
 
 	// Scheduler is now run as a separate job (cmd/scraper)
 	// sch := scheduler.New(db)
 	// go sch.Start()
 
-	// Update chain to include AuthMiddleware
-	http.HandleFunc("/items", Chain(itemsHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
-	http.HandleFunc("/items/{id}", Chain(itemHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
-	http.HandleFunc("/notifications", Chain(notificationsHandler, AuthMiddleware, CORSMiddleware))
-	http.HandleFunc("/notifications/{id}/read", Chain(markNotificationReadHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
-
-	port := ":8081"
-	slog.Info("Server starting", "port", port)
-	if err := http.ListenAndServe(port, nil); err != nil {
+	registerRoutes(http.HandleFunc)
+	registerConnectRoutes(http.HandleFunc)
+
+	if len(cfg.TLS.AutocertDomains) > 0 {
+		if err := checkDomainsResolve(context.Background(), cfg.TLS.AutocertDomains, net.DefaultResolver.LookupHost); err != nil {
+			slog.Error("Cannot get certificates from Let's Encrypt", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// SIGTERM (or Ctrl-C) stops accepting connections and lets the requests
+	// in flight finish.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	if err := serve(ctx, cfg, http.DefaultServeMux); err != nil {
 		slog.Error("Server failed", "error", err)
 		os.Exit(1)
 	}
+	slog.Info("Server stopped")
+}
+
+// registerRoutes registers every route of the API with handle, the routes
+// /openapi.json documents.
+func registerRoutes(handle func(pattern string, handler func(http.ResponseWriter, *http.Request))) {
+	// Update chain to include AuthMiddleware
+	handle("/version", Chain(versionHandler, CORSMiddleware))
+	handle("/openapi.json", Chain(openAPIHandler, CORSMiddleware))
+	handle("/docs", Chain(docsHandler, LoggingMiddleware))
+	handle("/health/scheduler", Chain(schedulerHealthHandler, CORSMiddleware))
+	handle("/items", Chain(itemsHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/items/import", Chain(importItemsHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/capture", Chain(captureHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/items/revalidate", Chain(itemsRevalidateHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/items/{id}", Chain(itemHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/items/{id}/scrape-logs", Chain(itemScrapeLogsHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/items/{id}/screenshots", Chain(itemScreenshotsHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/items/{id}/screenshots/{screenshotId}", Chain(itemScreenshotHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/items/{id}/check", Chain(itemCheckHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/items/{id}/check-hook", Chain(itemCheckHookHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/hooks/check/{itemId}", Chain(checkHookHandler, LoggingMiddleware))
+	handle("/items/{id}/share", Chain(itemShareHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/shared/{token}", Chain(sharedItemHandler, sharedRateLimit.Middleware, LoggingMiddleware, CORSMiddleware))
+	handle("/scrape/diff", Chain(scrapeDiffHandler, scrapeDiffRateLimit.UserMiddleware, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/price/parse", Chain(priceParseHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/items/{id}/variants", Chain(itemVariantsHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/items/{id}/variants/{variantId}", Chain(itemVariantHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/notifications", Chain(notificationsHandler, AuthMiddleware, CORSMiddleware))
+	handle("/notifications/stream", Chain(notificationsStreamHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	// WebSockets carry their credentials in the URL or first message.
+	handle("/ws", Chain(liveSocketHandler, LoggingMiddleware))
+	handle("/notifications/count", Chain(notificationsCountHandler, AuthMiddleware, CORSMiddleware))
+	handle("/notifications/{id}/read", Chain(markNotificationReadHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/webhook", Chain(webhookHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/webhook/deliveries", Chain(webhookDeliveriesHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/settings", Chain(settingsHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/settings/email", Chain(settingsEmailHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/settings/email/verification", Chain(settingsEmailVerificationHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/settings/email/verify", Chain(settingsEmailVerifyHandler, LoggingMiddleware, CORSMiddleware))
+	handle("/settings/unsubscribe/rotate", Chain(settingsUnsubscribeRotateHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/unsubscribe", Chain(unsubscribeHandler, LoggingMiddleware, CORSMiddleware))
+	handle("/trending", Chain(trendingHandler, LoggingMiddleware, CORSMiddleware))
+	handle("/stats", Chain(statsHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/api-keys", Chain(apiKeysHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/api-keys/{id}", Chain(apiKeyHandler, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/admin/domains", Chain(adminDomainsHandler, AdminMiddleware, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/admin/scrape-now", Chain(adminScrapeNowHandler, AdminMiddleware, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
+	handle("/admin/users/{id}/quota", Chain(adminUserQuotaHandler, AdminMiddleware, AuthMiddleware, LoggingMiddleware, CORSMiddleware))
 }
@@ -3,12 +3,15 @@ package main
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	_ "github.com/lib/pq"
 )
 
@@ -125,3 +128,303 @@ func TestNotificationsHandler_Integration(t *testing.T) {
 		t.Errorf("Expected title 'Test Notification', got '%s'", notifications[0].Title)
 	}
 }
+
+func TestItemHandler_PatchPause(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectExec(`SET paused_at = COALESCE\(paused_at, NOW\(\)\), pause_reason = 'user'`).
+		WithArgs("item-1", "test-user-id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"paused":true}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemHandler_PatchResumeNotFound(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectExec(`SET paused_at = NULL, pause_reason = NULL`).
+		WithArgs("missing", "test-user-id").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest("PATCH", "/items/missing", strings.NewReader(`{"paused":false}`))
+	req.SetPathValue("id", "missing")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestItemHandler_PatchParseStrategy(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectExec(`SET parse_strategy = \$3, last_interacted_at = NOW\(\)`).
+		WithArgs("item-1", "test-user-id", "eu").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"parseStrategy":"eu"}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemHandler_PatchInvalidParseStrategy(t *testing.T) {
+	setupMockDB(t)
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"parseStrategy":"roman"}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestItemHandler_PatchAdapterOrder(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectExec(`SET adapter_order = \$3, last_interacted_at = NOW\(\)`).
+		WithArgs("item-1", "test-user-id", "last").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"adapterOrder":"last"}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+
+	req = httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"adapterOrder":"never"}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w = httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown order, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestItemHandler_PatchSelectorMatch(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectExec(`SET selector_match = \$3, last_interacted_at = NOW\(\)`).
+		WithArgs("item-1", "test-user-id", "index:2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"selectorMatch":"index:2"}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+
+	for _, match := range []string{"last", "index:0", "index:two"} {
+		req = httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"selectorMatch":"`+match+`"}`))
+		req.SetPathValue("id", "item-1")
+		req = req.WithContext(setupTestContext("test-user-id"))
+		w = httptest.NewRecorder()
+
+		itemHandler(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: Expected status %d, got %d", match, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestItemHandler_PatchAcceptLanguage(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectExec(`SET accept_language = \$3, last_interacted_at = NOW\(\)`).
+		WithArgs("item-1", "test-user-id", "de-CH").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"acceptLanguage":"de-ch"}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemHandler_PatchInvalidAcceptLanguage(t *testing.T) {
+	setupMockDB(t)
+
+	req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(`{"acceptLanguage":"not a locale"}`))
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestNotificationsCountHandler(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\)\\s+FROM notifications").
+		WithArgs("test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	req := httptest.NewRequest("GET", "/notifications/count", nil)
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	notificationsCountHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if strings.TrimSpace(w.Body.String()) != `{"unread":3}` {
+		t.Errorf("Unexpected body %q", w.Body.String())
+	}
+}
+
+func TestNotificationsHandler_Envelope(t *testing.T) {
+	columns := []string{"id", "user_id", "title", "message", "type", "product_id", "old_price", "new_price", "is_read", "created_at", "read_at", "severity"}
+	row := func(id int) []driver.Value {
+		return []driver.Value{id, "user-1", "Price Drop Alert!", "Good news!", "price_drop", "item-1", "$20.00", "$15.00", false, time.Now(), nil, "alert"}
+	}
+
+	t.Run("bare array by default", func(t *testing.T) {
+		mock := setupMockDB(t)
+		mock.ExpectQuery("FROM notifications").
+			WithArgs("user-1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(row(1)...))
+
+		req := httptest.NewRequest("GET", "/notifications", nil)
+		req = req.WithContext(setupTestContext("user-1"))
+		w := httptest.NewRecorder()
+		notificationsHandler(w, req)
+
+		var notifications []Notification
+		if err := json.Unmarshal(w.Body.Bytes(), &notifications); err != nil || len(notifications) != 1 {
+			t.Errorf("Expected a bare array of 1 notification, got %s (error: %v)", w.Body.String(), err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("envelope with cursor", func(t *testing.T) {
+		mock := setupMockDB(t)
+		mock.ExpectQuery(`FROM notifications .* LIMIT \$2 OFFSET \$3`).
+			WithArgs("user-1", 1, 0).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(row(1)...))
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM notifications`).
+			WithArgs("user-1").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+		req := httptest.NewRequest("GET", "/notifications?envelope=true&limit=1", nil)
+		req = req.WithContext(setupTestContext("user-1"))
+		w := httptest.NewRecorder()
+		notificationsHandler(w, req)
+
+		var body struct {
+			Data []Notification `json:"data"`
+			Meta listMeta       `json:"meta"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Response is not an envelope: %v\n%s", err, w.Body.String())
+		}
+		expected := listMeta{Total: 2, Limit: 1, NextCursor: encodeCursor(1)}
+		if len(body.Data) != 1 || body.Meta != expected {
+			t.Errorf("Unexpected envelope: %+v", body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestNotificationsHandler_SeverityFilter(t *testing.T) {
+	columns := []string{"id", "user_id", "title", "message", "type", "product_id", "old_price", "new_price", "is_read", "created_at", "read_at", "severity"}
+	mock := setupMockDB(t)
+	mock.ExpectQuery(`WHERE user_id = \$1 AND is_read = false AND severity = ANY\(\$2\) .* LIMIT \$3 OFFSET \$4`).
+		WithArgs("user-1", `{"notice","alert"}`, 10, 0).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "user-1", "Price Drop Alert!", "Good news!", "price_drop", "item-1", "$20.00", "$15.00", false, time.Now(), nil, "alert"))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM notifications WHERE user_id = \$1 AND is_read = false AND severity = ANY\(\$2\)`).
+		WithArgs("user-1", `{"notice","alert"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	req := httptest.NewRequest("GET", "/notifications?envelope=true&limit=10&severity=notice,alert", nil)
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+	notificationsHandler(w, req)
+
+	var body struct {
+		Data []Notification `json:"data"`
+		Meta listMeta       `json:"meta"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Response is not an envelope: %v\n%s", err, w.Body.String())
+	}
+	if len(body.Data) != 1 || body.Data[0].Severity != "alert" || body.Meta.Total != 1 {
+		t.Errorf("Expected the one alert, got %+v", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+
+	req = httptest.NewRequest("GET", "/notifications?severity=urgent", nil)
+	req = req.WithContext(setupTestContext("user-1"))
+	w = httptest.NewRecorder()
+	notificationsHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown severity, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// methods routes a request to the handler registered for its HTTP method, so
+// each route declares the verbs it supports in one place. HEAD is served by
+// the GET handler with the body discarded, and any other method gets a 405
+// listing the supported ones in the Allow header.
+type methods map[string]http.HandlerFunc
+
+func (m methods) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, ok := m[r.Method]; ok {
+		h(w, r)
+		return
+	}
+	if get, ok := m["GET"]; ok && r.Method == "HEAD" {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		hw := &headResponseWriter{ResponseWriter: w, status: http.StatusOK, cancel: cancel}
+		get(hw, r.WithContext(ctx))
+		hw.flush()
+		return
+	}
+
+	w.Header().Set("Allow", m.allow())
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// allow returns the Allow header value for the route.
+func (m methods) allow() string {
+	verbs := make([]string, 0, len(m)+1)
+	for verb := range m {
+		verbs = append(verbs, verb)
+	}
+	if _, ok := m["GET"]; ok {
+		if _, ok := m["HEAD"]; !ok {
+			verbs = append(verbs, "HEAD")
+		}
+	}
+	sort.Strings(verbs)
+	return strings.Join(verbs, ", ")
+}
+
+// headResponseWriter runs a GET handler for a HEAD request. The body is
+// counted instead of sent, and the status line is held back until the handler
+// returns so that Content-Length can reflect the body GET would have sent.
+// A streaming handler, one that flushes, gets its headers sent at the first
+// Flush instead; as that is all a HEAD response has, its request context is
+// then cancelled so that it returns.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	length      int
+	wroteHeader bool
+	sent        bool
+	cancel      context.CancelFunc
+}
+
+func (w *headResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	w.length += len(b)
+	return len(b), nil
+}
+
+// Flush sends the headers of a streaming response, without Content-Length,
+// and ends the handler's request.
+func (w *headResponseWriter) Flush() {
+	if w.sent {
+		return
+	}
+	w.sent = true
+	w.ResponseWriter.WriteHeader(w.status)
+	http.NewResponseController(w.ResponseWriter).Flush()
+	w.cancel()
+}
+
+func (w *headResponseWriter) flush() {
+	if w.sent {
+		return
+	}
+	h := w.Header()
+	if h.Get("Content-Length") == "" && w.status != http.StatusNotModified && w.status != http.StatusNoContent {
+		h.Set("Content-Length", strconv.Itoa(w.length))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMethods_AllowHeaderPerRoute(t *testing.T) {
+	routes := []struct {
+		name    string
+		handler http.HandlerFunc
+		allow   string
+	}{
+		{"/items", itemsHandler, "DELETE, GET, HEAD, POST"},
+		{"/items/import", importItemsHandler, "POST"},
+		{"/items/{id}", itemHandler, "DELETE, GET, HEAD, PATCH"},
+		{"/notifications", notificationsHandler, "GET, HEAD"},
+		{"/notifications/count", notificationsCountHandler, "GET, HEAD"},
+		{"/notifications/{id}/read", markNotificationReadHandler, "PATCH"},
+		{"/stats", statsHandler, "GET, HEAD"},
+		{"/api-keys", apiKeysHandler, "GET, HEAD, POST"},
+		{"/api-keys/{id}", apiKeyHandler, "DELETE"},
+		{"/admin/domains", adminDomainsHandler, "GET, HEAD"},
+		{"/version", versionHandler, "GET, HEAD"},
+	}
+
+	for _, route := range routes {
+		t.Run(route.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/", nil)
+			req = req.WithContext(setupTestContext("test-user-id"))
+			w := httptest.NewRecorder()
+
+			route.handler(w, req)
+
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+			}
+			if got := w.Header().Get("Allow"); got != route.allow {
+				t.Errorf("Expected Allow %q, got %q", route.allow, got)
+			}
+		})
+	}
+}
+
+func TestMethods_HeadOnPostOnlyRoute(t *testing.T) {
+	req := httptest.NewRequest("HEAD", "/items/import", nil)
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	importItemsHandler(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "POST" {
+		t.Errorf("Expected Allow %q, got %q", "POST", got)
+	}
+}
+
+func TestItemsHandler_HeadMatchesGet(t *testing.T) {
+	mock := setupMockDB(t)
+
+	for i := 0; i < 2; i++ {
+		expectItemsETag(mock, "test-user-id", 2)
+		mock.ExpectQuery("FROM tracked_items").
+			WithArgs("test-user-id").
+			WillReturnRows(sqlmock.NewRows(itemColumnNames).AddRow(itemRow("a")...).AddRow(itemRow("b")...))
+	}
+
+	get := getItems(t, "/items", "")
+
+	req := httptest.NewRequest("HEAD", "/items", nil)
+	req = req.WithContext(setupTestContext("test-user-id"))
+	head := httptest.NewRecorder()
+	itemsHandler(head, req)
+
+	if head.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, head.Code)
+	}
+	if head.Body.Len() != 0 {
+		t.Errorf("Expected no body, got %d bytes", head.Body.Len())
+	}
+	if got, want := head.Header().Get("Content-Length"), strconv.Itoa(get.Body.Len()); got != want {
+		t.Errorf("Expected Content-Length %s, got %s", want, got)
+	}
+	for _, h := range []string{"ETag", "Content-Type"} {
+		if head.Header().Get(h) != get.Header().Get(h) {
+			t.Errorf("Expected %s %q, got %q", h, get.Header().Get(h), head.Header().Get(h))
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemsHandler_HeadETagShortcut(t *testing.T) {
+	mock := setupMockDB(t)
+
+	expectItemsETag(mock, "test-user-id", 2)
+	mock.ExpectQuery("FROM tracked_items").
+		WithArgs("test-user-id").
+		WillReturnRows(sqlmock.NewRows(itemColumnNames))
+	etag := getItems(t, "/items", "").Header().Get("ETag")
+
+	// Only the aggregate query may run for a conditional HEAD.
+	expectItemsETag(mock, "test-user-id", 2)
+	req := httptest.NewRequest("HEAD", "/items", nil)
+	req.Header.Set("If-None-Match", etag)
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemsHandler(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+	if w.Header().Get("Content-Length") != "" {
+		t.Errorf("Expected no Content-Length on 304, got %q", w.Header().Get("Content-Length"))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS min_expected NUMERIC;
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS max_expected NUMERIC;
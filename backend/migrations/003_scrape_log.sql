@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS scrape_log (
+  id BIGSERIAL PRIMARY KEY,
+  item_id TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  domain TEXT NOT NULL,
+  status TEXT NOT NULL,
+  failure_reason TEXT,
+  error TEXT,
+  duration_ms INTEGER NOT NULL DEFAULT 0,
+  used_playwright BOOLEAN NOT NULL DEFAULT false,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scrape_log_domain_created_at ON scrape_log (domain, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_scrape_log_item_created_at ON scrape_log (item_id, created_at DESC);
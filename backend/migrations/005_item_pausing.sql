@@ -0,0 +1,3 @@
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS paused_at TIMESTAMPTZ;
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS pause_reason TEXT;
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS last_interacted_at TIMESTAMPTZ;
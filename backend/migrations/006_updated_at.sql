@@ -0,0 +1,3 @@
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_tracked_items_user_updated_at ON tracked_items (user_id, updated_at DESC);
@@ -0,0 +1,3 @@
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS image_urls TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE tracked_items SET image_urls = ARRAY[image_url] WHERE image_url <> '' AND image_urls = '{}';
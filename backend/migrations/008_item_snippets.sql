@@ -0,0 +1,9 @@
+-- Outer HTML snippets move to a side table, gzip-compressed, so that list
+-- queries no longer read them. Existing rows are moved by cmd/backfill-snippets,
+-- after which tracked_items.outer_html_snippet is left empty.
+CREATE TABLE IF NOT EXISTS item_snippets (
+  item_id TEXT PRIMARY KEY REFERENCES tracked_items (id) ON DELETE CASCADE,
+  snippet_gz BYTEA NOT NULL
+);
+
+ALTER TABLE tracked_items ALTER COLUMN outer_html_snippet SET DEFAULT '';
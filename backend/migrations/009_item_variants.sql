@@ -0,0 +1,24 @@
+-- Variants (sizes, colors, ...) tracked under one item. Each has its own
+-- selector on the item's page, its own current price and its own history.
+CREATE TABLE IF NOT EXISTS item_variants (
+  id BIGSERIAL PRIMARY KEY,
+  item_id TEXT NOT NULL REFERENCES tracked_items (id) ON DELETE CASCADE,
+  label TEXT NOT NULL,
+  css_selector TEXT NOT NULL DEFAULT '',
+  xpath TEXT NOT NULL DEFAULT '',
+  price_text TEXT,
+  last_scrape_status TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (item_id, label)
+);
+
+CREATE TABLE IF NOT EXISTS variant_price_history (
+  id BIGSERIAL PRIMARY KEY,
+  variant_id BIGINT NOT NULL REFERENCES item_variants (id) ON DELETE CASCADE,
+  price_text TEXT NOT NULL,
+  recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_variant_price_history_variant
+  ON variant_price_history (variant_id, recorded_at DESC);
@@ -0,0 +1,7 @@
+-- The page URL in canonical form (internal/urlnorm), so that "am I tracking
+-- this page?" lookups are an index probe regardless of tracking parameters.
+-- New items fill it on insert; existing rows are filled by cmd/backfill-urls.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS normalized_url TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_tracked_items_user_normalized_url
+  ON tracked_items (user_id, normalized_url);
@@ -0,0 +1,6 @@
+-- How the scheduler reads separators in an item's price text: 'auto' guesses,
+-- the others force US (1,234.56), EU (1.234,56), plain (commas ignored) or
+-- lakh (1,23,456.78) interpretation.
+ALTER TABLE tracked_items
+  ADD COLUMN IF NOT EXISTS parse_strategy TEXT NOT NULL DEFAULT 'auto'
+  CHECK (parse_strategy IN ('auto', 'us', 'eu', 'plain', 'lakh'));
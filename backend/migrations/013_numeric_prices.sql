@@ -0,0 +1,13 @@
+-- Numeric prices for filtering and sorting in SQL. captured_price is the price
+-- when the item was saved; last_price is the latest price the scheduler
+-- observed, NULL until the first successful scrape.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS captured_price NUMERIC;
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS last_price NUMERIC;
+
+-- Best effort for existing items: price_text may already hold a scraped price
+-- rather than the captured one, and only plain "1234.56" forms are parsed
+-- here. The scheduler fills in captured_price for anything left NULL.
+UPDATE tracked_items
+SET captured_price = regexp_replace(price_text, '[^0-9.]', '', 'g')::NUMERIC
+WHERE captured_price IS NULL
+  AND regexp_replace(price_text, '[^0-9.]', '', 'g') ~ '^[0-9]+(\.[0-9]+)?$';
@@ -0,0 +1,7 @@
+-- When the current price first appeared and when the price last changed.
+-- price_last_changed_at stays NULL until the scheduler sees a different price.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS price_first_seen_at TIMESTAMPTZ;
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS price_last_changed_at TIMESTAMPTZ;
+
+-- Existing items have held their price at least since it was captured.
+UPDATE tracked_items SET price_first_seen_at = captured_at WHERE price_first_seen_at IS NULL;
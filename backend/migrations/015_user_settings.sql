@@ -0,0 +1,13 @@
+-- Per-user preferences. Every column is an override: NULL means the default
+-- from internal/settings applies, so new settings need no backfill.
+CREATE TABLE IF NOT EXISTS user_settings (
+  user_id TEXT PRIMARY KEY,
+  currency TEXT,
+  timezone TEXT,
+  quiet_hours_start TEXT,
+  quiet_hours_end TEXT,
+  digest_frequency TEXT CHECK (digest_frequency IN ('off', 'daily', 'weekly')),
+  drop_threshold_percent NUMERIC CHECK (drop_threshold_percent BETWEEN 0 AND 100),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+-- Per-user override of ITEMS_QUOTA_DEFAULT, set by admins. NULL means the
+-- default applies and 0 means unlimited.
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS items_quota INTEGER CHECK (items_quota >= 0);
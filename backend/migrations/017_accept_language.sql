@@ -0,0 +1,3 @@
+-- Locale (BCP 47 tag) each item's page is requested in, sent as the
+-- Accept-Language header and used as the browser locale.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS accept_language TEXT NOT NULL DEFAULT 'en-US';
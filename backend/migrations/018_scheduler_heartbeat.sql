@@ -0,0 +1,6 @@
+-- Written by the scraper job after each full pass, read by GET
+-- /health/scheduler. The table only ever holds one row.
+CREATE TABLE IF NOT EXISTS scheduler_heartbeat (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_run_at TIMESTAMPTZ NOT NULL
+);
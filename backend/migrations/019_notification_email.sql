@@ -0,0 +1,5 @@
+-- Where email notifications go, which need not be the login email. Nothing is
+-- sent until notification_email_verified_at is set; changing the address
+-- clears it.
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS notification_email TEXT;
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS notification_email_verified_at TIMESTAMPTZ;
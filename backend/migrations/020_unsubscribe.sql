@@ -0,0 +1,5 @@
+-- Per-channel email opt-outs and the nonce every unsubscribe link is signed
+-- with. Rotating the nonce revokes all links sent so far. A NULL
+-- email_price_drops means the default (on) applies.
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS email_price_drops BOOLEAN;
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS unsubscribe_nonce TEXT;
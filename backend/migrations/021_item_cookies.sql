@@ -0,0 +1,3 @@
+-- Cookies some shops need before they show a price (consent, region or a
+-- session), encrypted with COOKIE_ENCRYPTION_KEY. NULL means none.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS cookies_encrypted BYTEA;
@@ -0,0 +1,17 @@
+-- Public, read-only share links. share_token is NULL while an item is not
+-- shared; clearing it revokes the link.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS share_token TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tracked_items_share_token
+  ON tracked_items (share_token) WHERE share_token IS NOT NULL;
+
+-- Every price the scheduler observes for an item: the first one and each
+-- change after it. Shared views chart it aggregated by day.
+CREATE TABLE IF NOT EXISTS item_price_history (
+  id BIGSERIAL PRIMARY KEY,
+  item_id TEXT NOT NULL REFERENCES tracked_items (id) ON DELETE CASCADE,
+  price NUMERIC NOT NULL,
+  recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_price_history_item
+  ON item_price_history (item_id, recorded_at);
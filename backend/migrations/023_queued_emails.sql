@@ -0,0 +1,13 @@
+-- Emails held back by the recipient's quiet hours. The scheduler sends and
+-- deletes them once deliver_after has passed.
+CREATE TABLE IF NOT EXISTS queued_emails (
+  id BIGSERIAL PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  channel TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  body TEXT NOT NULL,
+  deliver_after TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_queued_emails_deliver_after ON queued_emails (deliver_after);
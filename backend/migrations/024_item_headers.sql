@@ -0,0 +1,6 @@
+-- Extra request headers some shops need before they show a price, as a JSON
+-- object of name to value. NULL means none.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS headers JSONB;
+
+-- The names (never the values) of the headers sent with each scrape.
+ALTER TABLE scrape_log ADD COLUMN IF NOT EXISTS header_names TEXT[];
@@ -0,0 +1,4 @@
+-- The iframe an item's price is rendered in, picked by a CSS selector for the
+-- iframe element or by part of its src URL. Empty means the page itself.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS frame_selector TEXT NOT NULL DEFAULT '';
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS frame_url TEXT NOT NULL DEFAULT '';
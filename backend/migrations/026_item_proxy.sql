@@ -0,0 +1,3 @@
+-- A proxy (http, https or socks5 URL, possibly with credentials) that scrapes
+-- of the item go through instead of SCRAPER_PROXY_URL. Empty means none.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS proxy_url TEXT NOT NULL DEFAULT '';
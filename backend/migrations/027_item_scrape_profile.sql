@@ -0,0 +1,3 @@
+-- How the headless browser fallback scrapes the item: 'stealth' or 'fast'.
+-- Empty means SCRAPE_PROFILE.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS scrape_profile TEXT NOT NULL DEFAULT '';
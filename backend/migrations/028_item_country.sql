@@ -0,0 +1,7 @@
+-- Country (ISO 3166-1 alpha-2) the headless browser pretends to be in for an
+-- item, setting its timezone and geolocation. Empty means none.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS country_code TEXT NOT NULL DEFAULT '';
+
+-- Locale and country each scrape was made for. NULL on older rows.
+ALTER TABLE scrape_log ADD COLUMN IF NOT EXISTS locale TEXT;
+ALTER TABLE scrape_log ADD COLUMN IF NOT EXISTS country_code TEXT;
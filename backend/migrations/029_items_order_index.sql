@@ -0,0 +1,2 @@
+-- Item lists order by created_at with id as the tie-breaker.
+CREATE INDEX IF NOT EXISTS idx_tracked_items_user_created_at_id ON tracked_items (user_id, created_at DESC, id DESC);
@@ -0,0 +1,4 @@
+-- Labels users group their items by, filtered with GET /items?tag=.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_tracked_items_tags ON tracked_items USING GIN (tags);
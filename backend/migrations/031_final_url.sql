@@ -0,0 +1,7 @@
+-- Where an item's page ended up after redirects (HTTP, meta refresh or
+-- script) on its last scrape. NULL unless it was redirected elsewhere.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS final_url TEXT;
+
+-- Where each scrape's page ended up. NULL on older rows and when the page
+-- never loaded.
+ALTER TABLE scrape_log ADD COLUMN IF NOT EXISTS final_url TEXT;
@@ -0,0 +1,6 @@
+-- Consecutive checks that found an item's page gone (404 or 410), reset once
+-- it loads again.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS not_found_count INTEGER NOT NULL DEFAULT 0;
+-- When the item was marked discontinued for having been gone too long. NULL
+-- while it is tracked normally.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS discontinued_at TIMESTAMPTZ;
@@ -0,0 +1,9 @@
+-- The variant (size, color, ...) to select before reading an item's price,
+-- on pages that show the price of whichever is selected. Empty tracks the
+-- page's default.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS variant_selector TEXT NOT NULL DEFAULT '';
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS variant_value TEXT NOT NULL DEFAULT '';
+
+-- The variant that was active when each scrape read the price. NULL on older
+-- rows and for items tracking the default.
+ALTER TABLE scrape_log ADD COLUMN IF NOT EXISTS variant TEXT;
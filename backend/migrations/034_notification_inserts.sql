@@ -0,0 +1,14 @@
+-- Announces each new notification on the notification_inserts channel, with
+-- its user ID as the payload, so the API can push it to that user's open
+-- /notifications/stream connections without polling.
+CREATE OR REPLACE FUNCTION notify_notification_insert() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('notification_inserts', NEW.user_id::text);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS notifications_notify_insert ON notifications;
+CREATE TRIGGER notifications_notify_insert
+  AFTER INSERT ON notifications
+  FOR EACH ROW EXECUTE FUNCTION notify_notification_insert();
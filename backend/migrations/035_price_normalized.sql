@@ -0,0 +1,5 @@
+-- The scraped price as scheduler.NormalizePrice writes it ("1234.50 EUR"),
+-- next to price_text, which keeps the store's own formatting. NULL when the
+-- price could not be parsed. New items and scrapes that change the price
+-- fill it; existing rows are filled by cmd/backfill-prices.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS price_normalized TEXT;
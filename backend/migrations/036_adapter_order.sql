@@ -0,0 +1,9 @@
+-- Whether a site adapter's price (see internal/adapters) is preferred to the
+-- item's own selector ('first') or only used when the selector finds none
+-- ('last').
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS adapter_order TEXT NOT NULL DEFAULT 'first'
+    CHECK (adapter_order IN ('first', 'last'));
+
+-- Where the price of a scrape came from: 'selector', 'jsonld' or
+-- 'adapter:<name>'. NULL for scrapes that found no price.
+ALTER TABLE scrape_log ADD COLUMN IF NOT EXISTS price_source TEXT;
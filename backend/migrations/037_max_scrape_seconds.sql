@@ -0,0 +1,5 @@
+-- An item's own limit on how long one scrape of it may take, HTTP and
+-- browser fallback together. NULL leaves it to SCRAPE_ITEM_TIMEOUT, which
+-- also caps it.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS max_scrape_seconds INTEGER
+  CHECK (max_scrape_seconds > 0);
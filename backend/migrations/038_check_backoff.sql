@@ -0,0 +1,9 @@
+-- Items whose scrapes keep failing are checked less and less often.
+-- failure_streak counts the failed checks in a row and next_check_at is when
+-- the item is due again; NULL means every run.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS failure_streak INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS next_check_at TIMESTAMPTZ;
+
+-- How long a failed scrape put the item's next check off, in seconds. NULL
+-- when it did not.
+ALTER TABLE scrape_log ADD COLUMN IF NOT EXISTS backoff_seconds INTEGER;
@@ -0,0 +1,5 @@
+-- Which of the elements an item's CSS selector matches its price is read
+-- from: 'first', 'min', 'max' or 'index:N', for list pages where the selector
+-- matches several.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS selector_match TEXT NOT NULL DEFAULT 'first'
+    CHECK (selector_match IN ('first', 'min', 'max') OR selector_match ~ '^index:[1-9][0-9]*$');
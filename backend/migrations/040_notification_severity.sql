@@ -0,0 +1,5 @@
+-- How big a price drop a notification reports: 'info', 'notice' or 'alert',
+-- from the drop thresholds the scheduler is configured with. Notifications
+-- other than price drops are 'info'.
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS severity TEXT NOT NULL DEFAULT 'info'
+    CHECK (severity IN ('info', 'notice', 'alert'));
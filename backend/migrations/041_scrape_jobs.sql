@@ -0,0 +1,27 @@
+-- Price checks waiting to run. The scheduler queues the items due on each run
+-- and the API queues the ones users ask about; scraper workers claim jobs
+-- highest priority first and record how the check went.
+--
+-- state is 'queued' until a worker claims the job, then 'running' until it
+-- is 'done' (result_status holds the item's scrape status) or 'failed' (the
+-- item was never checked). A job left 'running' by a worker that died is
+-- claimed again once its claim expires.
+CREATE TABLE IF NOT EXISTS scrape_jobs (
+  id BIGSERIAL PRIMARY KEY,
+  item_id TEXT NOT NULL REFERENCES tracked_items (id) ON DELETE CASCADE,
+  reason TEXT NOT NULL CHECK (reason IN ('scheduled', 'manual', 'revalidate')),
+  priority INTEGER NOT NULL DEFAULT 0,
+  run_after TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  state TEXT NOT NULL DEFAULT 'queued' CHECK (state IN ('queued', 'running', 'done', 'failed')),
+  claimed_by TEXT,
+  claimed_at TIMESTAMPTZ,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  result_status TEXT,
+  finished_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- An item has at most one job waiting or running; queueing it again raises
+-- that job's priority instead.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scrape_jobs_item_open ON scrape_jobs (item_id) WHERE state IN ('queued', 'running');
+CREATE INDEX IF NOT EXISTS idx_scrape_jobs_claim ON scrape_jobs (priority DESC, run_after, id) WHERE state = 'queued';
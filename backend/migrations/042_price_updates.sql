@@ -0,0 +1,14 @@
+-- Announces each price recorded in an item's history on the price_updates
+-- channel, with the item's user ID as the payload, so the API can push it to
+-- that user's open /ws connections without polling.
+CREATE OR REPLACE FUNCTION notify_price_update() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('price_updates', user_id::text) FROM tracked_items WHERE id = NEW.item_id;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS item_price_history_notify_insert ON item_price_history;
+CREATE TRIGGER item_price_history_notify_insert
+  AFTER INSERT ON item_price_history
+  FOR EACH ROW EXECUTE FUNCTION notify_price_update();
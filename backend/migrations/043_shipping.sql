@@ -0,0 +1,6 @@
+-- Shipping cost. An item with a shipping_selector has its shipping cost read
+-- with its price; shipping_price is the cost the last check read and
+-- total_price the price plus shipping, which drops are judged on.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS shipping_selector TEXT NOT NULL DEFAULT '';
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS shipping_price NUMERIC;
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS total_price NUMERIC;
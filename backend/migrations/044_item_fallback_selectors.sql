@@ -0,0 +1,10 @@
+-- Selectors to fall back on. An item saved through POST /capture keeps the
+-- extension's other candidate selectors for its price element, in the order
+-- the server ranked them, next to the one it tracks with.
+CREATE TABLE IF NOT EXISTS item_fallback_selectors (
+  item_id TEXT NOT NULL REFERENCES tracked_items (id) ON DELETE CASCADE,
+  position INTEGER NOT NULL,
+  css_selector TEXT NOT NULL DEFAULT '',
+  xpath TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (item_id, position)
+);
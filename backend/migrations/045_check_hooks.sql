@@ -0,0 +1,4 @@
+-- Check hooks. An item with a check_hook_nonce can be re-checked through
+-- POST /hooks/check/{itemId} with a token signed over its ID and the nonce;
+-- clearing the nonce revokes the token.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS check_hook_nonce TEXT;
@@ -0,0 +1,11 @@
+-- How many price checks each user's items had per calendar month (UTC), for
+-- the monthly scrape quota (SCRAPE_QUOTA_MONTHLY). month is the first day of
+-- the month, so every month starts from zero. notified_at records when the
+-- user was told that the month's quota was used up.
+CREATE TABLE IF NOT EXISTS scrape_usage (
+  user_id TEXT NOT NULL,
+  month DATE NOT NULL,
+  scrapes INTEGER NOT NULL DEFAULT 0,
+  notified_at TIMESTAMPTZ,
+  PRIMARY KEY (user_id, month)
+);
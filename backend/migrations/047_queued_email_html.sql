@@ -0,0 +1,3 @@
+-- The HTML version of a queued email, put in the layout template when it is
+-- sent. NULL for emails queued as plain text only.
+ALTER TABLE queued_emails ADD COLUMN IF NOT EXISTS html TEXT;
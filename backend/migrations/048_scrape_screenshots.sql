@@ -0,0 +1,16 @@
+-- Screenshots of pages whose price could not be found by the Playwright
+-- fallback. The PNG itself is kept in the blob store (BLOB_STORE_DIR or
+-- S3_BUCKET) under key. There is deliberately no foreign key to
+-- tracked_items: the rows of deleted items are kept until the retention
+-- deletes them together with their blobs.
+CREATE TABLE IF NOT EXISTS scrape_screenshots (
+  id BIGSERIAL PRIMARY KEY,
+  item_id TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  key TEXT NOT NULL,
+  error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scrape_screenshots_item_created_at ON scrape_screenshots (item_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_scrape_screenshots_created_at ON scrape_screenshots (created_at);
@@ -0,0 +1,7 @@
+-- Where price drops are delivered besides the in-app notification. A user's
+-- notification_channel is their default (NULL means all); an item's, when
+-- not empty, overrides it for that item.
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS notification_channel TEXT
+  CHECK (notification_channel IN ('all', 'in_app', 'email', 'webhook'));
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS notification_channel TEXT NOT NULL DEFAULT ''
+  CHECK (notification_channel IN ('', 'all', 'in_app', 'email', 'webhook'));
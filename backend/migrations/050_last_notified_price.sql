@@ -0,0 +1,5 @@
+-- The price (with shipping, when the item has a shipping selector) of the
+-- last price drop its owner was notified of. A drop is only notified when it
+-- goes below both the previous price and this one, so a price that bounces
+-- back up and down again is not announced twice. NULL until the first drop.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS last_notified_price NUMERIC;
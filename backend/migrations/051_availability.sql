@@ -0,0 +1,8 @@
+-- Stock. An item with an availability_selector has the text it selects
+-- checked before its price is read, and no price is read while it says the
+-- product is sold out. availability is what the last check found, also from
+-- the site adapters, and availability_changed_at when that last changed;
+-- both NULL until a check found either.
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS availability_selector TEXT NOT NULL DEFAULT '';
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS availability TEXT CHECK (availability IN ('in_stock', 'out_of_stock'));
+ALTER TABLE tracked_items ADD COLUMN IF NOT EXISTS availability_changed_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+-- When the user's last daily or weekly digest went out; NULL until the
+-- first. The next digest lists the price drops since then.
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS last_digest_at TIMESTAMPTZ;
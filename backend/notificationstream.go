@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// notificationInsertsChannel is the Postgres channel each new notification is
+// announced on, with its user ID as the payload (see migration 034).
+const notificationInsertsChannel = "notification_inserts"
+
+// priceUpdatesChannel is the Postgres channel each price recorded in an
+// item's history is announced on, with the item's user ID as the payload (see
+// migration 042).
+const priceUpdatesChannel = "price_updates"
+
+// hubChannels are the channels notificationHub listens on.
+var hubChannels = []string{notificationInsertsChannel, priceUpdatesChannel}
+
+// notificationStreamPollInterval is how often an open stream looks for new
+// notifications itself while LISTEN is unavailable.
+var notificationStreamPollInterval = 15 * time.Second
+
+// notificationStreamKeepAlive is how often an idle stream sends a comment, so
+// that proxies do not close it (nginx gives up after 60 seconds of silence).
+const notificationStreamKeepAlive = 30 * time.Second
+
+// notificationStreamBatch caps the notifications loaded per query.
+const notificationStreamBatch = 100
+
+// notificationHub wakes the open streams of users whose new notifications or
+// prices Postgres announced. A nil hub never wakes anyone, and its streams
+// poll.
+type notificationHub struct {
+	mu        sync.Mutex
+	listening bool
+	streams   map[hubKey]map[chan struct{}]struct{}
+	// relay, when set, passes the announcements this replica hears on to
+	// the others, for those that cannot LISTEN themselves.
+	relay hubRelay
+}
+
+// hubRelay carries announcements between API replicas, one topic per user
+// and channel (see redisstore.Relay).
+type hubRelay interface {
+	Publish(ctx context.Context, topic string) error
+	Subscribe(ctx context.Context, topic string) error
+	Unsubscribe(ctx context.Context, topic string) error
+	Messages() <-chan string
+}
+
+// hubKey identifies the streams of one user on one channel.
+type hubKey struct {
+	channel, userID string
+}
+
+// topic is the relay topic of the key's announcements.
+func (k hubKey) topic() string {
+	return k.channel + ":" + k.userID
+}
+
+// notificationStreams is set in main.
+var notificationStreams *notificationHub
+
+func newNotificationHub() *notificationHub {
+	return &notificationHub{streams: map[hubKey]map[chan struct{}]struct{}{}}
+}
+
+// subscribe returns a channel that receives a value whenever userID may have
+// something new announced on channel, and a function that stops it.
+func (h *notificationHub) subscribe(channel, userID string) (<-chan struct{}, func()) {
+	if h == nil {
+		return nil, func() {}
+	}
+	key := hubKey{channel, userID}
+	wake := make(chan struct{}, 1)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.streams[key] == nil {
+		h.streams[key] = map[chan struct{}]struct{}{}
+		h.relaySubscription(key, true)
+	}
+	h.streams[key][wake] = struct{}{}
+	return wake, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.streams[key], wake)
+		if len(h.streams[key]) == 0 {
+			delete(h.streams, key)
+			h.relaySubscription(key, false)
+		}
+	}
+}
+
+// relaySubscription subscribes to or unsubscribes from key's relayed
+// announcements, when the hub has a relay. It is called with h.mu held, so
+// that subscriptions follow the order streams open and close in.
+func (h *notificationHub) relaySubscription(key hubKey, on bool) {
+	if h.relay == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var err error
+	if on {
+		err = h.relay.Subscribe(ctx, key.topic())
+	} else {
+		err = h.relay.Unsubscribe(ctx, key.topic())
+	}
+	if err != nil {
+		slog.Warn("Failed to update relay subscription", "topic", key.topic(), "subscribe", on, "error", err)
+	}
+}
+
+// relayThrough makes the hub publish the announcements it hears to relay,
+// and wake streams on the ones relay brings from other replicas while it
+// cannot LISTEN itself. Streams still poll while it cannot: relayed
+// announcements only come if some replica can.
+func (h *notificationHub) relayThrough(relay hubRelay) {
+	h.mu.Lock()
+	h.relay = relay
+	h.mu.Unlock()
+	go func() {
+		for topic := range relay.Messages() {
+			if h.isListening() {
+				// This replica heard it from Postgres already.
+				continue
+			}
+			channel, userID, _ := strings.Cut(topic, ":")
+			h.wake(channel, userID)
+		}
+	}()
+}
+
+// wake signals userID's streams on channel, or every stream when channel is
+// empty. A stream that has not caught up with its last signal is not
+// signalled again.
+func (h *notificationHub) wake(channel, userID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key, streams := range h.streams {
+		if channel != "" && key != (hubKey{channel, userID}) {
+			continue
+		}
+		for wake := range streams {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// dispatch handles one message from the listener.
+func (h *notificationHub) dispatch(n *pq.Notification) {
+	if n == nil {
+		// The listener reconnected, so announcements may have been missed.
+		h.wake("", "")
+		return
+	}
+	h.wake(n.Channel, n.Extra)
+	h.mu.Lock()
+	relay := h.relay
+	h.mu.Unlock()
+	if relay != nil {
+		if err := relay.Publish(context.Background(), hubKey{n.Channel, n.Extra}.topic()); err != nil {
+			slog.Warn("Failed to relay announcement", "channel", n.Channel, "error", err)
+		}
+	}
+}
+
+// isListening reports whether announcements are being received, which
+// makes polling unnecessary.
+func (h *notificationHub) isListening() bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.listening
+}
+
+func (h *notificationHub) setListening(listening bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listening = listening
+}
+
+// listen receives notification and price announcements from the database at
+// connStr until ctx is done or LISTEN fails. Streams poll while it is not
+// connected.
+func (h *notificationHub) listen(ctx context.Context, connStr string) {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventReconnected:
+			slog.Info("Notification listener reconnected")
+			h.setListening(true)
+		case pq.ListenerEventDisconnected:
+			slog.Warn("Notification listener disconnected, streams poll until it reconnects", "error", err)
+			h.setListening(false)
+		}
+	})
+	for _, channel := range hubChannels {
+		if err := listener.Listen(channel); err != nil {
+			slog.Warn("Cannot LISTEN for notifications, streams poll instead", "channel", channel, "error", err)
+			listener.Close()
+			return
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	h.setListening(true)
+	slog.Info("Listening for notifications", "channels", hubChannels)
+	for n := range listener.Notify {
+		h.dispatch(n)
+	}
+	h.setListening(false)
+}
+
+// notificationsStreamHandler serves /notifications/stream.
+var notificationsStreamHandler = methods{"GET": streamNotificationsHandler}.ServeHTTP
+
+// streamNotificationsHandler pushes the user's notifications as server-sent
+// events as they are created. Each is a "notification" event whose id is the
+// notification's ID and whose data is the notification as GET /notifications
+// returns it. Only notifications created after the stream opened are sent.
+func streamNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Subscribe first so nothing created from here on is missed.
+	wake, unsubscribe := notificationStreams.subscribe(notificationInsertsChannel, userID)
+	defer unsubscribe()
+
+	stream := notificationStream{userID: userID, sent: map[string]bool{}}
+	ctx, cancel := queryContext(r)
+	err := db.QueryRowContext(ctx, `SELECT NOW()`).Scan(&stream.since)
+	cancel()
+	if err != nil {
+		slog.Error("Failed to open notification stream", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keeps nginx from buffering the events.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	rc.Flush()
+
+	poll := time.NewTicker(notificationStreamPollInterval)
+	defer poll.Stop()
+	keepAlive := time.NewTicker(notificationStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	slog.Info("Opened notification stream", "user_id", userID, "listening", notificationStreams.isListening())
+	for {
+		select {
+		case <-r.Context().Done():
+			slog.Info("Closed notification stream", "user_id", userID)
+			return
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			rc.Flush()
+			continue
+		case <-poll.C:
+			if notificationStreams.isListening() {
+				continue
+			}
+		case <-wake:
+		}
+		err := stream.send(r.Context(), func(n Notification) error {
+			data, err := json.Marshal(n)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "id: %s\nevent: notification\ndata: %s\n\n", n.ID, data)
+			return err
+		})
+		if err != nil {
+			// The next wake-up tries again.
+			slog.Error("Failed to send notifications", "user_id", userID, "error", err)
+		}
+		rc.Flush()
+	}
+}
+
+// notificationStream tracks what one open stream has sent: since is the
+// creation time of the newest notification, and sent the IDs of those created
+// exactly then, which the next query returns again.
+type notificationStream struct {
+	userID string
+	since  time.Time
+	sent   map[string]bool
+}
+
+// send passes the notifications created since the last call to emit.
+func (s *notificationStream) send(ctx context.Context, emit func(Notification) error) error {
+	for {
+		page, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+		written := 0
+		for _, p := range page {
+			if s.sent[p.ID] {
+				continue
+			}
+			if err := emit(p.Notification); err != nil {
+				return err
+			}
+			written++
+			if p.createdAt.After(s.since) {
+				s.since = p.createdAt
+				clear(s.sent)
+			}
+			s.sent[p.ID] = true
+		}
+		if len(page) < notificationStreamBatch || written == 0 {
+			return nil
+		}
+	}
+}
+
+// streamedNotification is a notification with its creation time, which
+// orders the stream.
+type streamedNotification struct {
+	Notification
+	createdAt time.Time
+}
+
+// load reads the next batch of notifications. The rows are closed before
+// send writes any of them, so that a slow client does not hold a database
+// connection.
+func (s *notificationStream) load(ctx context.Context) ([]streamedNotification, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+notificationColumns+`, created_at
+		FROM notifications
+		WHERE user_id = $1 AND created_at >= $2
+		ORDER BY created_at, id
+		LIMIT $3
+	`, s.userID, s.since, notificationStreamBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var page []streamedNotification
+	for rows.Next() {
+		var p streamedNotification
+		if p.Notification, err = scanNotification(rows, &p.createdAt); err != nil {
+			return nil, err
+		}
+		page = append(page, p)
+	}
+	return page, rows.Err()
+}
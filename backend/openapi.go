@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"price-track-backend/internal/scheduler"
+	"price-track-backend/internal/settings"
+)
+
+// apiDocsEnabled serves the Swagger UI at /docs (API_DOCS). /openapi.json is
+// always served. It is set from the configuration in main.
+var apiDocsEnabled bool
+
+// apiParam is a query parameter of an operation.
+type apiParam struct {
+	name, description string
+	// schema is the parameter's JSON schema type; empty means "string".
+	schema string
+	// repeated parameters may be given several times.
+	repeated bool
+}
+
+var (
+	limitParam    = apiParam{name: "limit", schema: "integer", description: fmt.Sprintf("Page size, 1 to %d. Without it the whole list is returned.", maxPageSize)}
+	offsetParam   = apiParam{name: "offset", schema: "integer", description: "Entries to skip; requires limit and cannot be combined with cursor."}
+	cursorParam   = apiParam{name: "cursor", description: "Opaque cursor from a previous page's meta.nextCursor; requires limit."}
+	envelopeParam = apiParam{name: "envelope", schema: "boolean", description: "Wrap the list as {\"data\": [...], \"meta\": {...}}, as does Accept: " + envelopeMediaType + "."}
+)
+
+// apiOperation documents one method of one route in /openapi.json. request
+// and response are values of the Go types the handler decodes and encodes;
+// their schemas are generated from the types' JSON encoding.
+type apiOperation struct {
+	method, path, summary string
+	// public operations need no Authorization header; admin ones need an
+	// admin's.
+	public, admin bool
+	// paginated list operations take limit, offset, cursor and envelope.
+	paginated bool
+	// quota operations answer 403 with QuotaExceeded when the caller's
+	// items would not fit.
+	quota   bool
+	params  []apiParam
+	request any
+	// response is nil for operations without a JSON body.
+	response any
+	// status is the success status; zero means 200.
+	status int
+	// errors are the error statuses beyond those every operation may answer
+	// with (401 and 403 where authenticated, 500 and 504).
+	errors []int
+}
+
+// apiOperations are the operations /openapi.json documents.
+var apiOperations = []apiOperation{
+	{method: "GET", path: "/version", summary: "Build information and enabled features", public: true, response: VersionResponse{}},
+	{method: "GET", path: "/openapi.json", summary: "This OpenAPI document", public: true},
+	{method: "GET", path: "/docs", summary: "Swagger UI for /openapi.json, when API_DOCS is set", public: true, errors: []int{404}},
+	{method: "GET", path: "/health/scheduler", summary: "Whether scheduled price checks keep running", public: true, response: SchedulerHealth{}, errors: []int{503}},
+	{method: "GET", path: "/items", summary: "List the caller's tracked items", paginated: true, response: []TrackedItem{}, errors: []int{400},
+		params: []apiParam{
+			{name: "fields", description: "Comma-separated item fields to return; the others are left out."},
+			{name: "include", description: "snippet adds outerHtmlSnippet."},
+			{name: "pageUrl", description: "Only items tracking this page."},
+			{name: "changed", description: "dropped, risen, unchanged or unknown."},
+			{name: "status", description: "active, paused, broken, discontinued or out_of_stock."},
+			{name: "tag", description: "Only items with this tag; repeat to require several.", repeated: true},
+			{name: "sort", description: "newest (default) or drop."},
+		}},
+	{method: "POST", path: "/items", summary: "Track an item", request: newItemRequest{}, response: TrackedItem{}, status: http.StatusCreated, quota: true, errors: []int{400}},
+	{method: "DELETE", path: "/items", summary: "Stop tracking all of the caller's items", status: http.StatusNoContent},
+	{method: "POST", path: "/items/import", summary: "Import items from JSON or CSV", request: []TrackedItem{}, response: ImportResponse{}, quota: true, errors: []int{400}},
+	{method: "POST", path: "/capture", summary: "Track the item a browser extension capture describes", request: CapturePayload{}, response: CaptureResponse{}, status: http.StatusCreated, quota: true, errors: []int{400}},
+	{method: "POST", path: "/items/revalidate", summary: "Re-scrape the caller's items with broken selectors", response: scheduler.RevalidateSummary{},
+		params: []apiParam{{name: "all", schema: "boolean", description: "Every user's items; admins only."}}},
+	{method: "GET", path: "/items/{id}", summary: "Get one item", response: TrackedItem{}, errors: []int{404}},
+	{method: "PATCH", path: "/items/{id}", summary: "Change an item's settings", request: itemPatch{}, errors: []int{400, 404}, status: http.StatusNoContent},
+	{method: "DELETE", path: "/items/{id}", summary: "Stop tracking an item", errors: []int{404}, status: http.StatusNoContent},
+	{method: "POST", path: "/items/{id}/check", summary: "Queue a check of an item ahead of scheduled ones", response: ItemCheck{}, status: http.StatusAccepted, errors: []int{404}},
+	{method: "POST", path: "/items/{id}/check-hook", summary: "Create or return an item's check hook", response: CheckHook{}, errors: []int{404, 503}},
+	{method: "DELETE", path: "/items/{id}/check-hook", summary: "Revoke an item's check hook", status: http.StatusNoContent, errors: []int{404}},
+	{method: "POST", path: "/hooks/check/{itemId}", summary: "Queue a check of an item with its check hook token", public: true, response: ItemCheck{}, status: http.StatusAccepted, errors: []int{401, 409, 429},
+		params: []apiParam{{name: "token", description: "The item's check hook token; or send it as a bearer token."}}},
+	{method: "GET", path: "/items/{id}/scrape-logs", summary: "An item's scrape attempts, newest first", paginated: true, response: []ScrapeLog{}, errors: []int{400}},
+	{method: "GET", path: "/items/{id}/screenshots", summary: "Screenshots of an item's page from checks that found no price, newest first", paginated: true, response: []Screenshot{}, errors: []int{400}},
+	{method: "GET", path: "/items/{id}/screenshots/{screenshotId}", summary: "Redirect to a screenshot's PNG, or the PNG itself when the blob store has no links", status: http.StatusFound, errors: []int{404}},
+	{method: "POST", path: "/items/{id}/share", summary: "Create or return an item's share link", response: ItemShare{}, errors: []int{404}},
+	{method: "DELETE", path: "/items/{id}/share", summary: "Revoke an item's share link", status: http.StatusNoContent, errors: []int{404}},
+	{method: "GET", path: "/shared/{token}", summary: "Public view of a shared item", public: true, response: SharedItem{}, errors: []int{404, 429}},
+	{method: "GET", path: "/items/{id}/variants", summary: "An item's variants", response: []ItemVariant{}, errors: []int{404}},
+	{method: "POST", path: "/items/{id}/variants", summary: "Track another variant of an item", request: ItemVariant{}, response: ItemVariant{}, status: http.StatusCreated, errors: []int{400, 404, 409}},
+	{method: "DELETE", path: "/items/{id}/variants/{variantId}", summary: "Stop tracking a variant of an item", status: http.StatusNoContent, errors: []int{404}},
+	{method: "POST", path: "/scrape/diff", summary: "Scrape a page over HTTP and in the browser side by side", request: ScrapeDiffRequest{}, response: ScrapeDiff{}, errors: []int{400, 429}},
+	{method: "POST", path: "/price/parse", summary: "Show how a price text is read", request: PriceParseRequest{}, response: PriceBreakdown{}, errors: []int{400, 422}},
+	{method: "GET", path: "/notifications", summary: "The caller's unread notifications, newest first", paginated: true, response: []Notification{}, errors: []int{400},
+		params: []apiParam{{name: "severity", description: "Comma-separated severities to keep: info, notice, alert."}}},
+	{method: "GET", path: "/notifications/stream", summary: "Server-sent notification events for notifications created from now on"},
+	{method: "GET", path: "/ws", summary: "Upgrade to a WebSocket pushing price_update and notification events", public: true, status: http.StatusSwitchingProtocols, errors: []int{401},
+		params: []apiParam{
+			{name: "token", description: "A Supabase JWT; or send {\"type\": \"auth\", \"token\": ...} as the first message."},
+			{name: "apiKey", description: "An API key, instead of token."},
+		}},
+	{method: "GET", path: "/notifications/count", summary: "How many notifications are unread", response: struct {
+		Unread int `json:"unread"`
+	}{}},
+	{method: "PATCH", path: "/notifications/{id}/read", summary: "Mark a notification read", status: http.StatusNoContent},
+	{method: "GET", path: "/webhook", summary: "The caller's webhook", response: Webhook{}, errors: []int{404}},
+	{method: "PUT", path: "/webhook", summary: "Set the caller's webhook", request: Webhook{}, response: Webhook{}, errors: []int{400}},
+	{method: "DELETE", path: "/webhook", summary: "Remove the caller's webhook", status: http.StatusNoContent},
+	{method: "GET", path: "/webhook/deliveries", summary: "Recent webhook deliveries", paginated: true, response: []WebhookDelivery{}, errors: []int{400}},
+	{method: "GET", path: "/settings", summary: "The caller's settings and items quota", response: settingsResponse{}},
+	{method: "PUT", path: "/settings", summary: "Change the caller's settings", request: settings.Update{}, response: settingsResponse{}, errors: []int{400}},
+	{method: "GET", path: "/settings/email", summary: "The caller's notification email", response: NotificationEmail{}, errors: []int{404}},
+	{method: "PUT", path: "/settings/email", summary: "Set the caller's notification email and send it a verification link", request: NotificationEmail{}, response: NotificationEmail{}, errors: []int{400, 502, 503}},
+	{method: "POST", path: "/settings/email/verification", summary: "Send the verification link again", status: http.StatusAccepted, errors: []int{404, 409, 502, 503}},
+	{method: "GET", path: "/settings/email/verify", summary: "Verify a notification email from its verification link", public: true, errors: []int{400, 409, 410},
+		params: []apiParam{{name: "token", description: "The token from the verification link."}}},
+	{method: "POST", path: "/settings/unsubscribe/rotate", summary: "Invalidate every unsubscribe link sent so far", status: http.StatusNoContent},
+	{method: "GET", path: "/unsubscribe", summary: "Turn off the channel an unsubscribe link names; answers with an HTML page", public: true, errors: []int{400, 410},
+		params: []apiParam{{name: "token", description: "The token from the unsubscribe link."}}},
+	{method: "POST", path: "/unsubscribe", summary: "One-click unsubscribe (RFC 8058), as GET", public: true, errors: []int{400, 410},
+		params: []apiParam{{name: "token", description: "The token from the unsubscribe link."}}},
+	{method: "GET", path: "/stats", summary: "The caller's tracking statistics", response: Stats{}},
+	{method: "GET", path: "/api-keys", summary: "The caller's API keys", response: []APIKey{}},
+	{method: "POST", path: "/api-keys", summary: "Create an API key; the key is only returned here", request: struct {
+		Name string `json:"name"`
+	}{}, response: APIKey{}, status: http.StatusCreated, errors: []int{400}},
+	{method: "DELETE", path: "/api-keys/{id}", summary: "Revoke an API key", status: http.StatusNoContent, errors: []int{404}},
+	{method: "GET", path: "/trending", summary: "The biggest price drops of the last day", public: true, response: []TrendingDrop{}, errors: []int{404}},
+	{method: "GET", path: "/admin/domains", summary: "Scrape health per shop domain", admin: true, response: []DomainHealth{}},
+	{method: "POST", path: "/admin/scrape-now", summary: "Check every item now, streaming each item's result and then a summary as lines of JSON", admin: true, errors: []int{409}},
+	{method: "PUT", path: "/admin/users/{id}/quota", summary: "Override a user's items quota; null reverts to the default, 0 lifts the limit", admin: true, request: struct {
+		ItemsQuota *int `json:"itemsQuota"`
+	}{}, response: ItemsQuota{}, errors: []int{400}},
+}
+
+// errorDescriptions describe the error statuses. Errors are plain text.
+var errorDescriptions = map[int]string{
+	400: "Invalid parameters or body; the body says which.",
+	401: "Missing or invalid Authorization header.",
+	403: "Not allowed for this user.",
+	404: "Not found, or not the caller's.",
+	409: "Conflicts with the current state.",
+	410: "The link has expired or was replaced by a newer one.",
+	422: "The text is not a price; the body says why.",
+	429: "Rate limited; retry later.",
+	500: "Internal error.",
+	502: "The email could not be sent.",
+	503: "Service unavailable.",
+	504: "The database query timed out.",
+}
+
+// openAPISpec builds the OpenAPI 3 document from apiOperations.
+func openAPISpec() map[string]any {
+	g := &schemaGenerator{components: map[string]any{}}
+	paths := map[string]map[string]any{}
+	for _, op := range apiOperations {
+		if paths[op.path] == nil {
+			paths[op.path] = map[string]any{}
+		}
+		paths[op.path][strings.ToLower(op.method)] = g.operation(op)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "PriceTrack API",
+			"version": "1",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKeyAuth": map[string]any{"type": "apiKey", "in": "header", "name": "Authorization", "description": "ApiKey <key>, with a key from POST /api-keys."},
+			},
+		},
+	}
+}
+
+// operation returns the OpenAPI operation object for op.
+func (g *schemaGenerator) operation(op apiOperation) map[string]any {
+	var params []any
+	for _, name := range pathParams(op.path) {
+		params = append(params, map[string]any{"name": name, "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+	}
+	query := op.params
+	if op.paginated {
+		query = append(append([]apiParam{}, query...), limitParam, offsetParam, cursorParam, envelopeParam)
+	}
+	for _, p := range query {
+		schema := map[string]any{"type": "string"}
+		if p.schema != "" {
+			schema["type"] = p.schema
+		}
+		if p.repeated {
+			schema = map[string]any{"type": "array", "items": schema}
+		}
+		params = append(params, map[string]any{"name": p.name, "in": "query", "description": p.description, "schema": schema})
+	}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	if op.response != nil {
+		schema := g.schema(reflect.TypeOf(op.response), true)
+		if op.paginated {
+			schema = map[string]any{"oneOf": []any{schema, map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"data":  schema,
+					"meta":  g.schema(reflect.TypeOf(listMeta{}), true),
+					"error": map[string]any{"type": "string", "description": "Set when data was cut short by a server error."},
+				},
+				"required": []string{"data", "meta"},
+			}}}
+		}
+		success["content"] = map[string]any{"application/json": map[string]any{"schema": schema}}
+	}
+	responses := map[string]any{fmt.Sprint(status): success}
+
+	codes := append([]int{500, 504}, op.errors...)
+	if !op.public {
+		codes = append(codes, 401)
+	}
+	if op.admin || op.path == "/items/revalidate" {
+		codes = append(codes, 403)
+	}
+	for _, code := range codes {
+		responses[fmt.Sprint(code)] = map[string]any{
+			"description": errorDescriptions[code],
+			"content":     map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}},
+		}
+	}
+	if op.quota {
+		responses["403"] = map[string]any{
+			"description": "The items would not fit the caller's quota, or a field is reserved for admins.",
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(QuotaExceeded{}), true)},
+				"text/plain":       map[string]any{"schema": map[string]any{"type": "string"}},
+			},
+		}
+	}
+
+	operation := map[string]any{"summary": op.summary, "responses": responses}
+	if params != nil {
+		operation["parameters"] = params
+	}
+	if op.request != nil {
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(op.request), false)}},
+		}
+	}
+	if op.public {
+		operation["security"] = []any{}
+	} else {
+		operation["security"] = []any{map[string]any{"bearerAuth": []any{}}, map[string]any{"apiKeyAuth": []any{}}}
+	}
+	return operation
+}
+
+// pathParams returns the names of the {wildcards} in path.
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, segment[1:len(segment)-1])
+		}
+	}
+	return names
+}
+
+// schemaGenerator derives JSON schemas from Go types the way encoding/json
+// encodes them. Named struct types in responses become shared components;
+// request bodies are described inline and list no required fields, since
+// handlers fill in defaults for what is left out.
+type schemaGenerator struct {
+	components map[string]any
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema returns the schema of t. response selects the response form.
+func (g *schemaGenerator) schema(t reflect.Type, response bool) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem(), response)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem(), response)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem(), response)}
+	case reflect.Struct:
+		if !response || t.Name() == "" {
+			return g.object(t, response)
+		}
+		if _, ok := g.components[t.Name()]; !ok {
+			g.components[t.Name()] = map[string]any{} // placeholder while recursing
+			g.components[t.Name()] = g.object(t, response)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+// object returns the schema of struct type t.
+func (g *schemaGenerator) object(t reflect.Type, response bool) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	g.fields(t, response, properties, &required)
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields adds t's encoded fields to properties, flattening embedded structs
+// as encoding/json does. In responses, fields without omitempty are always
+// present and so required; those that may encode as null are nullable.
+func (g *schemaGenerator) fields(t reflect.Type, response bool, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, response, properties, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema := g.schema(f.Type, response)
+		switch f.Type.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+			if f.Type != rawMessageType {
+				if _, isRef := schema["$ref"]; isRef {
+					schema = map[string]any{"allOf": []any{schema}}
+				}
+				schema["nullable"] = true
+			}
+		}
+		properties[name] = schema
+		if response && !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIBody []byte
+)
+
+// openAPIHandler serves /openapi.json.
+var openAPIHandler = methods{"GET": getOpenAPIHandler}.ServeHTTP
+
+func getOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		openAPIBody, _ = json.MarshalIndent(openAPISpec(), "", "  ")
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIBody)
+}
+
+// docsPage loads Swagger UI from a CDN and points it at /openapi.json.
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>PriceTrack API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// docsHandler serves /docs, a Swagger UI for /openapi.json, when API_DOCS
+// is set, and 404 otherwise.
+var docsHandler = methods{"GET": getDocsHandler}.ServeHTTP
+
+func getDocsHandler(w http.ResponseWriter, r *http.Request) {
+	if !apiDocsEnabled {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(docsPage))
+}
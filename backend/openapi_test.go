@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// servedSpec fetches /openapi.json and decodes it.
+func servedSpec(t *testing.T) map[string]any {
+	t.Helper()
+	w := httptest.NewRecorder()
+	openAPIHandler(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var spec map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("/openapi.json is not JSON: %v", err)
+	}
+	return spec
+}
+
+// specResponse returns the schema the spec declares for method and path
+// answering status with contentType.
+func specResponse(t *testing.T, spec map[string]any, method, path string, status int, contentType string) map[string]any {
+	t.Helper()
+	op, ok := lookup(spec, "paths", path, strings.ToLower(method)).(map[string]any)
+	if !ok {
+		t.Fatalf("%s %s is not documented", method, path)
+	}
+	schema, ok := lookup(op, "responses", fmt.Sprint(status), "content", contentType, "schema").(map[string]any)
+	if !ok {
+		t.Fatalf("%s %s documents no %s response %d", method, path, contentType, status)
+	}
+	return schema
+}
+
+// lookup follows keys through nested JSON objects.
+func lookup(v any, keys ...string) any {
+	for _, key := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// validate checks value against schema, resolving $refs in spec, and returns
+// what does not match. Objects with properties may not carry others, so a
+// field a handler encodes but the spec leaves out is caught too.
+func validate(spec, schema map[string]any, value any, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, _ := lookup(spec, strings.Split(strings.TrimPrefix(ref, "#/"), "/")...).(map[string]any)
+		if resolved == nil {
+			return []string{at + ": unresolved " + ref}
+		}
+		return validate(spec, resolved, value, at)
+	}
+	if value == nil && (schema["nullable"] == true || len(schema) == 0) {
+		return nil
+	}
+	if all, ok := schema["allOf"].([]any); ok {
+		var problems []string
+		for _, s := range all {
+			problems = append(problems, validate(spec, s.(map[string]any), value, at)...)
+		}
+		return problems
+	}
+	if one, ok := schema["oneOf"].([]any); ok {
+		matches := 0
+		for _, s := range one {
+			if len(validate(spec, s.(map[string]any), value, at)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return []string{fmt.Sprintf("%s: matches %d of the oneOf schemas", at, matches)}
+		}
+		return nil
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %T", at, value)}
+		}
+		var problems []string
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, present := obj[name.(string)]; !present {
+					problems = append(problems, fmt.Sprintf("%s: missing required %s", at, name))
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		additional, _ := schema["additionalProperties"].(map[string]any)
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			switch {
+			case properties[key] != nil:
+				problems = append(problems, validate(spec, properties[key].(map[string]any), obj[key], at+"."+key)...)
+			case additional != nil:
+				problems = append(problems, validate(spec, additional, obj[key], at+"."+key)...)
+			default:
+				problems = append(problems, fmt.Sprintf("%s: undocumented property %s", at, key))
+			}
+		}
+		return problems
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array, got %T", at, value)}
+		}
+		var problems []string
+		for i, elem := range arr {
+			problems = append(problems, validate(spec, schema["items"].(map[string]any), elem, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return problems
+	case "string":
+		if _, ok := value.(string); !ok {
+			return []string{fmt.Sprintf("%s: expected a string, got %T", at, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected a boolean, got %T", at, value)}
+		}
+	case "number", "integer":
+		n, ok := value.(float64)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected a number, got %T", at, value)}
+		}
+		if schema["type"] == "integer" && n != float64(int64(n)) {
+			return []string{fmt.Sprintf("%s: expected an integer, got %v", at, n)}
+		}
+	}
+	return nil
+}
+
+// expectValid checks the body w recorded against the schema the spec
+// declares for method, path and the recorded status.
+func expectValid(t *testing.T, spec map[string]any, method, path string, w *httptest.ResponseRecorder) {
+	t.Helper()
+	schema := specResponse(t, spec, method, path, w.Code, "application/json")
+	var body any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Response is not JSON: %v\n%s", err, w.Body.String())
+	}
+	for _, problem := range validate(spec, schema, body, "$") {
+		t.Errorf("%s %s: %s", method, path, problem)
+	}
+}
+
+func TestOpenAPI_ItemsMatchSchema(t *testing.T) {
+	spec := servedSpec(t)
+	mock := setupMockDB(t)
+
+	expectItemsETag(mock, "test-user-id", 2)
+	mock.ExpectQuery("FROM tracked_items").
+		WithArgs("test-user-id").
+		WillReturnRows(sqlmock.NewRows(itemColumnNames).
+			AddRow(itemRow("a")...).
+			AddRow(itemRow("b")...))
+	expectValid(t, spec, "GET", "/items", getItems(t, "/items", ""))
+
+	expectItemsETag(mock, "test-user-id", 5)
+	mock.ExpectQuery(`FROM tracked_items .* LIMIT \$2 OFFSET \$3`).
+		WithArgs("test-user-id", 2, 0).
+		WillReturnRows(sqlmock.NewRows(itemColumnNames).
+			AddRow(itemRow("a")...).
+			AddRow(itemRow("b")...))
+	expectValid(t, spec, "GET", "/items", getItems(t, "/items?envelope=true&limit=2", ""))
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestOpenAPI_NotificationsMatchSchema(t *testing.T) {
+	spec := servedSpec(t)
+	mock := setupMockDB(t)
+
+	columns := []string{"id", "user_id", "title", "message", "type", "product_id", "old_price", "new_price", "is_read", "created_at", "read_at", "severity"}
+	mock.ExpectQuery(`FROM notifications .* LIMIT \$2 OFFSET \$3`).
+		WithArgs("user-1", 1, 0).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "user-1", "Price Drop Alert!", "Good news!", "price_drop", "item-1", "$20.00", "$15.00", false, etagUpdatedAt, nil, "alert"))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM notifications`).
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	req := httptest.NewRequest("GET", "/notifications?envelope=true&limit=1", nil)
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+	notificationsHandler(w, req)
+	expectValid(t, spec, "GET", "/notifications", w)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestOpenAPI_VersionMatchesSchema(t *testing.T) {
+	spec := servedSpec(t)
+	w := httptest.NewRecorder()
+	versionHandler(w, httptest.NewRequest("GET", "/version", nil))
+	expectValid(t, spec, "GET", "/version", w)
+}
+
+func TestOpenAPI_ErrorsArePlainText(t *testing.T) {
+	spec := servedSpec(t)
+	w := httptest.NewRecorder()
+	notificationsHandler(w, httptest.NewRequest("GET", "/notifications", nil))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Expected a text/plain error, got %q", w.Header().Get("Content-Type"))
+	}
+	schema := specResponse(t, spec, "GET", "/notifications", w.Code, "text/plain")
+	for _, problem := range validate(spec, schema, w.Body.String(), "$") {
+		t.Error(problem)
+	}
+}
+
+func TestOpenAPI_QuotaBodyMatchesSchema(t *testing.T) {
+	spec := servedSpec(t)
+	w := httptest.NewRecorder()
+	quotaExceededError(w, ItemsQuota{Limit: 10, Usage: 10})
+	expectValid(t, spec, "POST", "/items", w)
+}
+
+func TestOpenAPI_DocumentsAuthAndPagination(t *testing.T) {
+	spec := servedSpec(t)
+
+	for _, scheme := range []string{"bearerAuth", "apiKeyAuth"} {
+		if lookup(spec, "components", "securitySchemes", scheme) == nil {
+			t.Errorf("Expected security scheme %s", scheme)
+		}
+	}
+	if security, _ := lookup(spec, "paths", "/items", "get", "security").([]any); len(security) != 2 {
+		t.Errorf("Expected GET /items to accept a JWT or an API key, got %v", security)
+	}
+	if security, _ := lookup(spec, "paths", "/version", "get", "security").([]any); security == nil || len(security) != 0 {
+		t.Errorf("Expected GET /version to need no auth, got %v", security)
+	}
+
+	params, _ := lookup(spec, "paths", "/items", "get", "parameters").([]any)
+	names := map[string]bool{}
+	for _, p := range params {
+		names[p.(map[string]any)["name"].(string)] = true
+	}
+	for _, name := range []string{"limit", "offset", "cursor", "envelope"} {
+		if !names[name] {
+			t.Errorf("Expected GET /items to document %s", name)
+		}
+	}
+	for _, status := range []string{"400", "401", "500", "504"} {
+		if lookup(spec, "paths", "/items", "get", "responses", status) == nil {
+			t.Errorf("Expected GET /items to document status %s", status)
+		}
+	}
+}
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	token := liveSocketToken(t, "admin-1")
+	setAdminUserIDs(t, "admin-1")
+
+	documented := map[string]map[string]bool{}
+	for _, op := range apiOperations {
+		if documented[op.path] == nil {
+			documented[op.path] = map[string]bool{}
+		}
+		documented[op.path][op.method] = true
+	}
+
+	served := map[string]map[string]bool{}
+	registerRoutes(func(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+		// A method no route supports gets past the middleware to methods,
+		// whose 405 lists the ones the route does.
+		req := httptest.NewRequest("TRACE", pattern, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("Expected TRACE %s to be answered by methods with status %d, got %d", pattern, http.StatusMethodNotAllowed, w.Code)
+			return
+		}
+		served[pattern] = map[string]bool{}
+		for _, method := range strings.Split(w.Header().Get("Allow"), ", ") {
+			if method == "HEAD" {
+				continue
+			}
+			served[pattern][method] = true
+			if !documented[pattern][method] {
+				t.Errorf("%s %s is served but not documented", method, pattern)
+			}
+		}
+	})
+	for path, methods := range documented {
+		for method := range methods {
+			if !served[path][method] {
+				t.Errorf("%s %s is documented but not served", method, path)
+			}
+		}
+	}
+}
+
+func TestDocsHandler_Disabled(t *testing.T) {
+	w := httptest.NewRecorder()
+	docsHandler(w, httptest.NewRequest("GET", "/docs", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d without API_DOCS, got %d", http.StatusNotFound, w.Code)
+	}
+
+	apiDocsEnabled = true
+	t.Cleanup(func() { apiDocsEnabled = false })
+	w = httptest.NewRecorder()
+	docsHandler(w, httptest.NewRequest("GET", "/docs", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/openapi.json") {
+		t.Errorf("Expected the Swagger UI, got %d: %s", w.Code, w.Body.String())
+	}
+}
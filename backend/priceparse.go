@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"price-track-backend/internal/scheduler"
+)
+
+// PriceParseRequest is the body of POST /price/parse.
+type PriceParseRequest struct {
+	Text     string `json:"text"`
+	Strategy string `json:"strategy,omitempty"`
+	// CountryCode resolves symbols several currencies share, as an item's
+	// does.
+	CountryCode string `json:"countryCode,omitempty"`
+}
+
+// PriceBreakdown is how a price text is read, returned by POST /price/parse.
+// Currency is the ISO 4217 code and Symbol the code or symbol in the text it
+// was read from; both are empty when the text does not say.
+type PriceBreakdown struct {
+	Numeric  float64 `json:"numeric"`
+	Currency string  `json:"currency"`
+	Symbol   string  `json:"symbol"`
+}
+
+// priceParseHandler serves /price/parse.
+var priceParseHandler = methods{"POST": postPriceParseHandler}.ServeHTTP
+
+// postPriceParseHandler reads a price text the way the scheduler would, so
+// that the extension can show how a picked price will be understood before
+// saving it. Text that is no price is 422 Unprocessable Entity.
+func postPriceParseHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.Context().Value(userIDKey).(string); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req PriceParseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Text == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+	if req.Strategy == "" {
+		req.Strategy = string(scheduler.ParseAuto)
+	}
+	if !scheduler.ValidParseStrategy(req.Strategy) {
+		http.Error(w, "strategy must be one of auto, us, eu, plain or lakh", http.StatusBadRequest)
+		return
+	}
+	if err := validateCountryCode(&req.CountryCode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	numeric, err := scheduler.ParsePriceWith(req.Text, scheduler.ParseStrategy(req.Strategy))
+	switch {
+	case errors.Is(err, scheduler.ErrMalformedPrice) && strings.ContainsAny(req.Text, "0123456789"):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil || numeric <= 0:
+		http.Error(w, "no price in text", http.StatusUnprocessableEntity)
+		return
+	}
+	currency, symbol := scheduler.PriceCurrency(req.Text, req.CountryCode)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PriceBreakdown{Numeric: numeric, Currency: currency, Symbol: symbol})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postPriceParse(body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/price/parse", strings.NewReader(body))
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+	priceParseHandler(w, req)
+	return w
+}
+
+func TestPriceParseHandler(t *testing.T) {
+	tests := []struct {
+		body string
+		want PriceBreakdown
+	}{
+		{`{"text":"$1,234.56"}`, PriceBreakdown{Numeric: 1234.56, Currency: "USD", Symbol: "$"}},
+		{`{"text":"1.234,50 €"}`, PriceBreakdown{Numeric: 1234.5, Currency: "EUR", Symbol: "€"}},
+		{`{"text":"1.234","strategy":"eu"}`, PriceBreakdown{Numeric: 1234}},
+		{`{"text":"1.234","strategy":"us"}`, PriceBreakdown{Numeric: 1.234}},
+		{`{"text":"CHF 19.90"}`, PriceBreakdown{Numeric: 19.9, Currency: "CHF", Symbol: "CHF"}},
+		{`{"text":"₹1,23,456.78","strategy":"lakh"}`, PriceBreakdown{Numeric: 123456.78, Currency: "INR", Symbol: "₹"}},
+		{`{"text":"¥1,980","countryCode":"cn"}`, PriceBreakdown{Numeric: 1980, Currency: "CNY", Symbol: "¥"}},
+		{`{"text":"C$ 24.99"}`, PriceBreakdown{Numeric: 24.99, Currency: "CAD", Symbol: "C$"}},
+	}
+	for _, test := range tests {
+		w := postPriceParse(test.body)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: Expected status 200, got %d: %s", test.body, w.Code, w.Body.String())
+			continue
+		}
+		var got PriceBreakdown
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: Failed to decode response: %v", test.body, err)
+		}
+		if got != test.want {
+			t.Errorf("%s: Expected %+v, got %+v", test.body, test.want, got)
+		}
+	}
+}
+
+func TestPriceParseHandler_Errors(t *testing.T) {
+	tests := []struct {
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{`{"text":"1.2.3","strategy":"us"}`, http.StatusUnprocessableEntity, "malformed price"},
+		{`{"text":"Sold out"}`, http.StatusUnprocessableEntity, "no price in text"},
+		{`{"text":"$0.00"}`, http.StatusUnprocessableEntity, "no price in text"},
+		{`{"text":""}`, http.StatusBadRequest, "text is required"},
+		{`{"text":"$5","strategy":"roman"}`, http.StatusBadRequest, "strategy must be"},
+		{`{"text":"$5","countryCode":"XX"}`, http.StatusBadRequest, "countryCode"},
+		{`not json`, http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		w := postPriceParse(test.body)
+		if w.Code != test.wantCode || !strings.Contains(w.Body.String(), test.wantBody) {
+			t.Errorf("%s: Expected %d with %q, got %d: %s", test.body, test.wantCode, test.wantBody, w.Code, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/price/parse", strings.NewReader(`{"text":"$5"}`))
+	w := httptest.NewRecorder()
+	priceParseHandler(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a user, got %d", w.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"price-track-backend/internal/config"
+)
+
+// queryTimeout is set from the configuration (DB_QUERY_TIMEOUT) in main.
+var queryTimeout = config.DefaultQueryTimeout
+
+// queryContext derives the context for a request's database calls. It is
+// cancelled when the client goes away or queryTimeout elapses.
+func queryContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), queryTimeout)
+}
+
+// isQueryTimeout reports whether err was caused by ctx running out of time.
+// Drivers do not always wrap the context error, so ctx itself is checked too.
+func isQueryTimeout(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// queryError responds to a failed database call: 504 if it ran out of time,
+// otherwise message with the given status code.
+func queryError(ctx context.Context, w http.ResponseWriter, err error, message string, code int) {
+	if isQueryTimeout(ctx, err) {
+		http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+		return
+	}
+	http.Error(w, message, code)
+}
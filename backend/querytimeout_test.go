@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// withQueryTimeout shortens the per-request query timeout for one test.
+func withQueryTimeout(t *testing.T, d time.Duration) {
+	t.Helper()
+	prev := queryTimeout
+	queryTimeout = d
+	t.Cleanup(func() { queryTimeout = prev })
+}
+
+func TestNotificationsHandler_QueryTimeoutReturns504(t *testing.T) {
+	mock := setupMockDB(t)
+	withQueryTimeout(t, 20*time.Millisecond)
+
+	mock.ExpectQuery("FROM notifications").
+		WithArgs("test-user-id").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	req := httptest.NewRequest("GET", "/notifications", nil)
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	notificationsHandler(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status %d, got %d", http.StatusGatewayTimeout, w.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the handler to give up after the timeout, took %v", elapsed)
+	}
+}
+
+func TestItemsHandler_QueryTimeoutReturns504(t *testing.T) {
+	mock := setupMockDB(t)
+	withQueryTimeout(t, 20*time.Millisecond)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\), MAX\(updated_at\)`).
+		WithArgs("test-user-id").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}))
+
+	w := getItems(t, "/items", "")
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status %d, got %d", http.StatusGatewayTimeout, w.Code)
+	}
+}
+
+func TestStatsHandler_ClientCancelIsNotTimeout(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("FROM tracked_items").
+		WithArgs("test-user-id").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"total", "active", "paused"}))
+
+	ctx, cancel := context.WithCancel(setupTestContext("test-user-id"))
+	time.AfterFunc(20*time.Millisecond, cancel)
+	req := httptest.NewRequest("GET", "/stats", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	statsHandler(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// itemsQuotaDefault is set from the configuration (ITEMS_QUOTA_DEFAULT) in
+// main. Zero means users may track any number of items.
+var itemsQuotaDefault int
+
+// ItemsQuota is how many items a user may track and how many they do. A
+// zero Limit means unlimited.
+type ItemsQuota struct {
+	Limit int `json:"limit"`
+	Usage int `json:"usage"`
+}
+
+// allows reports whether n more items fit within the quota.
+func (q ItemsQuota) allows(n int) bool {
+	return q.Limit == 0 || q.Usage+n <= q.Limit
+}
+
+// loadItemsQuota reads userID's quota (their user_settings override, else
+// itemsQuotaDefault) and current item count. The check that follows is not
+// atomic with the insert, so concurrent requests may overshoot slightly.
+func loadItemsQuota(ctx context.Context, userID string) (ItemsQuota, error) {
+	var override sql.NullInt64
+	var q ItemsQuota
+	err := db.QueryRowContext(ctx, `
+		SELECT (SELECT items_quota FROM user_settings WHERE user_id = $1),
+			(SELECT COUNT(*) FROM tracked_items WHERE user_id = $1)
+	`, userID).Scan(&override, &q.Usage)
+	if err != nil {
+		return q, err
+	}
+	q.Limit = itemsQuotaDefault
+	if override.Valid {
+		q.Limit = int(override.Int64)
+	}
+	return q, nil
+}
+
+// QuotaExceeded is the body of the 403 answered when items would not fit the
+// caller's quota.
+type QuotaExceeded struct {
+	Error string `json:"error"` // always "quota_exceeded"
+	ItemsQuota
+}
+
+// quotaExceededError responds with 403 and a machine-readable body.
+func quotaExceededError(w http.ResponseWriter, q ItemsQuota) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(QuotaExceeded{"quota_exceeded", q})
+}
+
+// checkItemsQuota loads the quota and reports whether n more items fit. When
+// they do not, or the quota cannot be loaded, it has already responded.
+func checkItemsQuota(ctx context.Context, w http.ResponseWriter, userID string, n int) bool {
+	q, err := loadItemsQuota(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to load items quota", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return false
+	}
+	if !q.allows(n) {
+		slog.Warn("Items quota exceeded", "user_id", userID, "limit", q.Limit, "usage", q.Usage, "adding", n)
+		quotaExceededError(w, q)
+		return false
+	}
+	return true
+}
+
+// adminUserQuotaHandler serves /admin/users/{id}/quota.
+var adminUserQuotaHandler = methods{"PUT": putUserQuotaHandler}.ServeHTTP
+
+// putUserQuotaHandler sets a user's items quota override. A null itemsQuota
+// reverts the user to ITEMS_QUOTA_DEFAULT; 0 lifts the limit.
+func putUserQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	targetID := r.PathValue("id")
+
+	var body struct {
+		ItemsQuota *int `json:"itemsQuota"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.ItemsQuota != nil && *body.ItemsQuota < 0 {
+		http.Error(w, "itemsQuota must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO user_settings (user_id, items_quota)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET items_quota = EXCLUDED.items_quota, updated_at = NOW()
+	`, targetID, body.ItemsQuota)
+	if err != nil {
+		slog.Error("Failed to save items quota", "error", err)
+		queryError(ctx, w, err, "Failed to save quota", http.StatusInternalServerError)
+		return
+	}
+
+	q, err := loadItemsQuota(ctx, targetID)
+	if err != nil {
+		slog.Error("Failed to load items quota", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Set items quota", "user_id", targetID, "quota", body.ItemsQuota)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(q)
+}
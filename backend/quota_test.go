@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectItemsQuota expects the quota lookup. override is the user_settings
+// items_quota value (nil for none).
+func expectItemsQuota(mock sqlmock.Sqlmock, userID string, override any, usage int) {
+	mock.ExpectQuery(`SELECT \(SELECT items_quota FROM user_settings`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"items_quota", "count"}).AddRow(override, usage))
+}
+
+// setItemsQuotaDefault sets ITEMS_QUOTA_DEFAULT for the duration of a test.
+func setItemsQuotaDefault(t *testing.T, n int) {
+	prev := itemsQuotaDefault
+	itemsQuotaDefault = n
+	t.Cleanup(func() { itemsQuotaDefault = prev })
+}
+
+func postItem(t *testing.T, id string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := `{"id":"` + id + `","priceText":"$19.99","productName":"Widget","cssSelector":".price","pageUrl":"https://example.com/p/1",` +
+		`"capturedAtIso":"2025-01-01T00:00:00Z","savedAtIso":"2025-01-01T00:00:00Z"}`
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+	itemsHandler(w, req)
+	return w
+}
+
+func TestCreateItem_QuotaBoundary(t *testing.T) {
+	mock := setupMockDB(t)
+	setItemsQuotaDefault(t, 5)
+
+	// One below the quota: the item is the fifth and still fits.
+	expectItemsQuota(mock, "test-user-id", nil, 4)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tracked_items").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	if w := postItem(t, "item-5"); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	// Exactly at the quota: rejected without an insert.
+	expectItemsQuota(mock, "test-user-id", nil, 5)
+	w := postItem(t, "item-6")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusForbidden, w.Code, w.Body.String())
+	}
+	var body struct {
+		Error string `json:"error"`
+		Limit int    `json:"limit"`
+		Usage int    `json:"usage"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Error != "quota_exceeded" || body.Limit != 5 || body.Usage != 5 {
+		t.Errorf("Expected quota_exceeded with limit 5 and usage 5, got %s", w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCreateItem_UserOverrideLiftsQuota(t *testing.T) {
+	mock := setupMockDB(t)
+	setItemsQuotaDefault(t, 5)
+
+	expectItemsQuota(mock, "test-user-id", 0, 500)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tracked_items").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if w := postItem(t, "item-501"); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestImportItemsHandler_QuotaCrossedPartway(t *testing.T) {
+	mock := setupMockDB(t)
+	setItemsQuotaDefault(t, 5)
+
+	// Three valid rows with room for two: nothing is imported.
+	expectItemsQuota(mock, "test-user-id", nil, 3)
+	body := `[{"pageUrl":"https://example.com/a","cssSelector":".p"},` +
+		`{"pageUrl":"https://example.com/b","cssSelector":".p"},` +
+		`{"pageUrl":"https://example.com/c","cssSelector":".p"}]`
+	w, _ := postImport(t, "/items/import", "application/json", body)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"quota_exceeded"`) {
+		t.Fatalf("Expected quota_exceeded, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Invalid rows do not count against the quota.
+	expectItemsQuota(mock, "test-user-id", nil, 3)
+	for range 2 {
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO tracked_items").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+	body = `[{"pageUrl":"https://example.com/a","cssSelector":".p"},` +
+		`{"pageUrl":"not a url","cssSelector":".p"},` +
+		`{"pageUrl":"https://example.com/c","cssSelector":".p"}]`
+	w, resp := postImport(t, "/items/import", "application/json", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if resp.Imported != 2 {
+		t.Errorf("Expected 2 imported items, got %d", resp.Imported)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestAdminUserQuotaHandler_Put(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectExec("INSERT INTO user_settings \\(user_id, items_quota\\)").
+		WithArgs("user-2", 1000).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectItemsQuota(mock, "user-2", 1000, 120)
+
+	req := httptest.NewRequest("PUT", "/admin/users/user-2/quota", strings.NewReader(`{"itemsQuota":1000}`))
+	req.SetPathValue("id", "user-2")
+	req = req.WithContext(setupTestContext("admin-1"))
+	w := httptest.NewRecorder()
+
+	adminUserQuotaHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var q ItemsQuota
+	if err := json.Unmarshal(w.Body.Bytes(), &q); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if q != (ItemsQuota{Limit: 1000, Usage: 120}) {
+		t.Errorf("Expected limit 1000 and usage 120, got %+v", q)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestAdminUserQuotaHandler_PutNegative(t *testing.T) {
+	setupMockDB(t)
+
+	req := httptest.NewRequest("PUT", "/admin/users/user-2/quota", strings.NewReader(`{"itemsQuota":-1}`))
+	req.SetPathValue("id", "user-2")
+	w := httptest.NewRecorder()
+
+	adminUserQuotaHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitStore counts requests for every API replica (see
+// redisstore.Client.Allow).
+type rateLimitStore interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error)
+}
+
+// trustedProxies are the reverse proxies whose forwarding headers name the
+// client (TRUSTED_PROXIES). main sets them from the config.
+var trustedProxies []netip.Prefix
+
+// trustedProxy reports whether addr, an IP address, is one of
+// trustedProxies.
+func trustedProxy(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, p := range trustedProxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP address r came from. That is the connection's
+// remote address, unless it is a trusted proxy's: then it is the last
+// address in X-Forwarded-For that no trusted proxy added, or X-Real-IP
+// without one. Forwarding headers from anyone else are ignored, since any
+// client can set them.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !trustedProxy(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		if !trustedProxy(hop) {
+			return hop
+		}
+	}
+	if real := strings.TrimSpace(r.Header.Get("X-Real-IP")); real != "" {
+		if _, err := netip.ParseAddr(real); err == nil {
+			return real
+		}
+	}
+	return ip
+}
+
+// sharedRateLimits is set in main when REDIS_URL is. Nil leaves each replica
+// counting on its own.
+var sharedRateLimits rateLimitStore
+
+// ipRateLimiter allows each client IP a fixed number of requests per window.
+// It counts in sharedRateLimits when there is one, and otherwise in process,
+// where every API replica counts separately.
+type ipRateLimiter struct {
+	// name keeps the limiter's keys in sharedRateLimits apart from other
+	// limiters'.
+	name   string
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+func newIPRateLimiter(name string, limit int, window time.Duration) *ipRateLimiter {
+	return &ipRateLimiter{name: name, limit: limit, window: window, now: time.Now, counts: make(map[string]int)}
+}
+
+// allow counts a request from ip and reports whether it is within the limit.
+// When it is not, it also returns how long until the window resets. If the
+// shared store fails, the request is counted in process instead.
+func (l *ipRateLimiter) allow(ip string) (bool, time.Duration) {
+	if sharedRateLimits != nil {
+		ok, retry, err := sharedRateLimits.Allow(context.Background(), l.name+":"+ip, l.limit, l.window)
+		if err == nil {
+			return ok, retry
+		}
+		slog.Warn("Failed to count request in shared rate limit, counting locally", "limiter", l.name, "error", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.start) >= l.window {
+		l.start = now
+		clear(l.counts)
+	}
+	if l.counts[ip] >= l.limit {
+		return false, l.start.Add(l.window).Sub(now)
+	}
+	l.counts[ip]++
+	return true, 0
+}
+
+// Middleware rejects requests over the limit with 429 and Retry-After,
+// counting them by clientIP.
+func (l *ipRateLimiter) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, retry := l.allow(clientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Round(time.Second)/time.Second)))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// UserMiddleware is Middleware keyed by the authenticated user instead of
+// the client IP, for routes behind AuthMiddleware: behind a reverse proxy
+// every request has the proxy's address.
+func (l *ipRateLimiter) UserMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(userIDKey).(string)
+		if ok, retry := l.allow("user:" + userID); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Round(time.Second)/time.Second)))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
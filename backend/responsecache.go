@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+
+	"price-track-backend/internal/cache"
+)
+
+// cachedResponse is a fully rendered response body plus its validator.
+type cachedResponse struct {
+	ETag string
+	Body []byte
+}
+
+// responseCache holds rendered responses for hot read endpoints. It starts
+// disabled, which is how tests run; main enables it from the configuration.
+var responseCache = cache.New[cachedResponse](0)
+
+// userCacheKey builds a cache key scoped to a user, so that every entry for
+// that user can be dropped with invalidateUserCache.
+func userCacheKey(userID string, parts ...string) string {
+	return userID + "|" + strings.Join(parts, "|")
+}
+
+// invalidateUserCache drops all cached responses for userID. Call it after
+// any write that changes what the user would read.
+func invalidateUserCache(userID string) {
+	responseCache.InvalidatePrefix(userID + "|")
+}
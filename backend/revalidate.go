@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"price-track-backend/internal/scheduler"
+)
+
+// revalidateTimeout bounds POST /items/revalidate, which scrapes every
+// flagged item before it responds.
+const revalidateTimeout = 5 * time.Minute
+
+// brokenRevalidator re-scrapes items flagged selector_broken. main sets it to
+// a scheduler once the database is open.
+var brokenRevalidator interface {
+	RevalidateBroken(ctx context.Context, userID string) (scheduler.RevalidateSummary, error)
+}
+
+// itemsRevalidateHandler serves /items/revalidate.
+var itemsRevalidateHandler = methods{"POST": revalidateItemsHandler}.ServeHTTP
+
+// revalidateItemsHandler re-scrapes the caller's items with broken selectors,
+// or everyone's when an admin passes ?all=true, and returns how many are
+// fixed.
+func revalidateItemsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	scope := userID
+	if r.URL.Query().Get("all") == "true" {
+		if !isAdmin(userID) {
+			slog.Warn("Non-admin attempted to revalidate all items", "user_id", userID)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		scope = ""
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), revalidateTimeout)
+	defer cancel()
+
+	summary, err := brokenRevalidator.RevalidateBroken(ctx, scope)
+	if err != nil {
+		slog.Error("Failed to revalidate broken selectors", "error", err)
+		queryError(ctx, w, err, "Failed to revalidate items", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Revalidated broken selectors", "user_id", userID, "all", scope == "", "checked", summary.Checked, "fixed", summary.Fixed)
+	if summary.Checked > 0 {
+		if scope == "" {
+			responseCache.InvalidatePrefix("")
+		} else {
+			invalidateUserCache(userID)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"price-track-backend/internal/scheduler"
+)
+
+// fakeRevalidator records the scope it was asked to revalidate.
+type fakeRevalidator struct {
+	scopes  []string
+	summary scheduler.RevalidateSummary
+}
+
+func (f *fakeRevalidator) RevalidateBroken(_ context.Context, userID string) (scheduler.RevalidateSummary, error) {
+	f.scopes = append(f.scopes, userID)
+	return f.summary, nil
+}
+
+func setRevalidator(t *testing.T, summary scheduler.RevalidateSummary) *fakeRevalidator {
+	t.Helper()
+	f := &fakeRevalidator{summary: summary}
+	prev := brokenRevalidator
+	brokenRevalidator = f
+	t.Cleanup(func() { brokenRevalidator = prev })
+	return f
+}
+
+func postRevalidate(target, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", target, nil)
+	req = req.WithContext(setupTestContext(userID))
+	w := httptest.NewRecorder()
+	itemsRevalidateHandler(w, req)
+	return w
+}
+
+func TestItemsRevalidateHandler_UserScope(t *testing.T) {
+	f := setRevalidator(t, scheduler.RevalidateSummary{Checked: 3, Fixed: 2, StillBroken: 1})
+
+	w := postRevalidate("/items/revalidate", "test-user-id")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if len(f.scopes) != 1 || f.scopes[0] != "test-user-id" {
+		t.Errorf("Expected the caller's items to be revalidated, got scopes %q", f.scopes)
+	}
+	var summary scheduler.RevalidateSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if summary != f.summary {
+		t.Errorf("Expected %+v, got %+v", f.summary, summary)
+	}
+}
+
+func TestItemsRevalidateHandler_AllRequiresAdmin(t *testing.T) {
+	f := setRevalidator(t, scheduler.RevalidateSummary{})
+	setAdminUserIDs(t, "admin-1")
+
+	if w := postRevalidate("/items/revalidate?all=true", "test-user-id"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+	if w := postRevalidate("/items/revalidate?all=true", "admin-1"); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if len(f.scopes) != 1 || f.scopes[0] != "" {
+		t.Errorf("Expected one instance-wide revalidation, got scopes %q", f.scopes)
+	}
+}
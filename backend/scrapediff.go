@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"price-track-backend/internal/scheduler"
+)
+
+// scrapeDiffTimeout bounds POST /scrape/diff, which loads the page twice.
+const scrapeDiffTimeout = 90 * time.Second
+
+// scrapeDiffRateLimit limits POST /scrape/diff per user; every request
+// starts a headless browser page.
+var scrapeDiffRateLimit = newIPRateLimiter("scrape-diff", 10, time.Minute)
+
+// scrapePrivateAddressesAllowed lets POST /scrape/diff compare pages on
+// private addresses (SCRAPE_ALLOW_PRIVATE_ADDRESSES).
+var scrapePrivateAddressesAllowed bool
+
+// scrapeDiffer scrapes a page both ways for POST /scrape/diff. main sets it
+// to a scheduler once the database is open.
+var scrapeDiffer interface {
+	ScrapeDiff(ctx context.Context, req scheduler.ScrapeRequest) (scheduler.ScrapeResult, scheduler.ScrapeResult, error, error)
+}
+
+// ScrapeDiffRequest is the body of POST /scrape/diff.
+type ScrapeDiffRequest struct {
+	URL            string `json:"url"`
+	CSSSelector    string `json:"cssSelector"`
+	XPath          string `json:"xPath"`
+	FrameSelector  string `json:"frameSelector,omitempty"`
+	FrameURL       string `json:"frameUrl,omitempty"`
+	AdapterOrder   string `json:"adapterOrder,omitempty"`
+	SelectorMatch  string `json:"selectorMatch,omitempty"`
+	AcceptLanguage string `json:"acceptLanguage,omitempty"`
+	CountryCode    string `json:"countryCode,omitempty"`
+	ScrapeProfile  string `json:"scrapeProfile,omitempty"`
+}
+
+// ScrapeDiffResult is what one way of scraping found.
+type ScrapeDiffResult struct {
+	Price       string `json:"price,omitempty"`
+	PriceSource string `json:"priceSource,omitempty"`
+	FinalURL    string `json:"finalUrl,omitempty"`
+	DurationMs  int64  `json:"durationMs"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ScrapeDiff is returned by POST /scrape/diff. Same is true when both ways
+// found the same price.
+type ScrapeDiff struct {
+	HTTP    ScrapeDiffResult `json:"http"`
+	Browser ScrapeDiffResult `json:"browser"`
+	Same    bool             `json:"same"`
+}
+
+// scrapeDiffHandler serves /scrape/diff.
+var scrapeDiffHandler = methods{"POST": postScrapeDiffHandler}.ServeHTTP
+
+// postScrapeDiffHandler scrapes a page over plain HTTP and in the headless
+// browser and returns both prices side by side, for debugging a selector on
+// a page whose scripts change what it shows.
+func postScrapeDiffHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req ScrapeDiffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateScrapeDiffRequest(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), scrapeDiffTimeout)
+	defer cancel()
+	// The scraper refuses private addresses on every hop as well; this
+	// turns the page's own into a clear error. A host that does not
+	// resolve is left for the scrape to report.
+	if !scrapePrivateAddressesAllowed {
+		if err := scheduler.CheckPublicURL(ctx, req.URL); errors.Is(err, scheduler.ErrPrivateAddress) {
+			http.Error(w, "url must be on a public address", http.StatusBadRequest)
+			return
+		}
+	}
+
+	httpResult, browserResult, httpErr, browserErr := scrapeDiffer.ScrapeDiff(ctx, scheduler.ScrapeRequest{
+		URL:            req.URL,
+		CSSSelector:    req.CSSSelector,
+		XPath:          req.XPath,
+		Match:          scheduler.SelectorMatch(req.SelectorMatch),
+		Frame:          scheduler.Frame{Selector: req.FrameSelector, URL: req.FrameURL},
+		Order:          scheduler.AdapterOrder(req.AdapterOrder),
+		AcceptLanguage: req.AcceptLanguage,
+		CountryCode:    req.CountryCode,
+		Profile:        scheduler.ScrapeProfile(req.ScrapeProfile),
+	})
+	diff := ScrapeDiff{
+		HTTP:    scrapeDiffResult(httpResult, httpErr),
+		Browser: scrapeDiffResult(browserResult, browserErr),
+	}
+	if httpErr == nil && browserErr == nil {
+		a, errA := scheduler.ParsePriceWith(httpResult.Text, scheduler.ParseAuto)
+		b, errB := scheduler.ParsePriceWith(browserResult.Text, scheduler.ParseAuto)
+		diff.Same = errA == nil && errB == nil && a == b
+	}
+
+	slog.Info("Compared scrapes", "user_id", userID, "url", req.URL, "http_error", httpErr, "browser_error", browserErr, "same", diff.Same)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+func scrapeDiffResult(result scheduler.ScrapeResult, err error) ScrapeDiffResult {
+	out := ScrapeDiffResult{DurationMs: result.Duration.Milliseconds(), FinalURL: result.FinalURL}
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	out.Price, out.PriceSource = result.Text, result.Source
+	return out
+}
+
+// validateScrapeDiffRequest checks the page and selector to compare, with the
+// same rules as an item's.
+func validateScrapeDiffRequest(req *ScrapeDiffRequest) error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http(s) URL")
+	}
+	if req.CSSSelector == "" && req.XPath == "" {
+		return fmt.Errorf("cssSelector or xPath is required")
+	}
+	if err := validateFrame(&req.FrameSelector, &req.FrameURL); err != nil {
+		return err
+	}
+	if err := validateAdapterOrder(&req.AdapterOrder); err != nil {
+		return err
+	}
+	if err := validateSelectorMatch(&req.SelectorMatch); err != nil {
+		return err
+	}
+	if err := validateAcceptLanguage(&req.AcceptLanguage); err != nil {
+		return err
+	}
+	if err := validateCountryCode(&req.CountryCode); err != nil {
+		return err
+	}
+	return validateScrapeProfile(req.ScrapeProfile)
+}
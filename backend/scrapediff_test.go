@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"price-track-backend/internal/scheduler"
+)
+
+// fakeDiffer returns fixed results and records the URL it was asked for.
+type fakeDiffer struct {
+	urls           []string
+	http, browser  scheduler.ScrapeResult
+	httpErr, brErr error
+}
+
+func (f *fakeDiffer) ScrapeDiff(_ context.Context, req scheduler.ScrapeRequest) (scheduler.ScrapeResult, scheduler.ScrapeResult, error, error) {
+	f.urls = append(f.urls, req.URL)
+	return f.http, f.browser, f.httpErr, f.brErr
+}
+
+func setDiffer(t *testing.T, f *fakeDiffer) {
+	t.Helper()
+	prev := scrapeDiffer
+	scrapeDiffer = f
+	t.Cleanup(func() { scrapeDiffer = prev })
+}
+
+func postScrapeDiff(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/scrape/diff", strings.NewReader(body))
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestScrapeDiffHandler_BothResults(t *testing.T) {
+	f := &fakeDiffer{
+		http:    scheduler.ScrapeResult{Text: "$24.99", Source: "selector", FinalURL: "https://shop.example.com/p/1", Duration: 120 * time.Millisecond},
+		browser: scheduler.ScrapeResult{Text: "$19.99", Source: "selector", FinalURL: "https://shop.example.com/p/1", Duration: 2 * time.Second},
+	}
+	setDiffer(t, f)
+
+	w := postScrapeDiff(scrapeDiffHandler, `{"url":"https://shop.example.com/p/1","cssSelector":".price"}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var diff ScrapeDiff
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if diff.HTTP.Price != "$24.99" || diff.HTTP.DurationMs != 120 || diff.Browser.Price != "$19.99" || diff.Browser.DurationMs != 2000 {
+		t.Errorf("Expected both prices side by side, got %+v", diff)
+	}
+	if diff.Same {
+		t.Error("Expected different prices not to be the same")
+	}
+	if len(f.urls) != 1 || f.urls[0] != "https://shop.example.com/p/1" {
+		t.Errorf("Expected one scrape of the page, got %q", f.urls)
+	}
+}
+
+func TestScrapeDiffHandler_OneFails(t *testing.T) {
+	setDiffer(t, &fakeDiffer{
+		http:    scheduler.ScrapeResult{Duration: time.Second},
+		httpErr: errors.New("element not found with css selector: .price"),
+		browser: scheduler.ScrapeResult{Text: "€1.234,50", Source: "adapter:amazon"},
+	})
+
+	w := postScrapeDiff(scrapeDiffHandler, `{"url":"https://www.amazon.de/dp/B07XJ8C8F5","cssSelector":".price"}`)
+
+	var diff ScrapeDiff
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if diff.HTTP.Error == "" || diff.HTTP.Price != "" || diff.Browser.Price != "€1.234,50" || diff.Browser.PriceSource != "adapter:amazon" || diff.Same {
+		t.Errorf("Expected the HTTP error next to the browser's price, got %+v", diff)
+	}
+}
+
+func TestScrapeDiffHandler_Invalid(t *testing.T) {
+	setDiffer(t, &fakeDiffer{})
+	for _, body := range []string{
+		`{"url":"ftp://shop.example.com/p/1","cssSelector":".price"}`,
+		`{"url":"https://shop.example.com/p/1"}`,
+		`{"url":"https://shop.example.com/p/1","cssSelector":".price","adapterOrder":"never"}`,
+	} {
+		if w := postScrapeDiff(scrapeDiffHandler, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: Expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestScrapeDiffHandler_RateLimitedPerUser(t *testing.T) {
+	setDiffer(t, &fakeDiffer{})
+	limited := Chain(scrapeDiffHandler, newIPRateLimiter("scrape-diff", 1, time.Minute).UserMiddleware)
+	body := `{"url":"https://shop.example.com/p/1","cssSelector":".price"}`
+	// Both users come through the same reverse proxy.
+	post := func(userID string) int {
+		req := httptest.NewRequest("POST", "/scrape/diff", strings.NewReader(body))
+		req = req.WithContext(setupTestContext(userID))
+		req.RemoteAddr = "172.18.0.5:40000"
+		w := httptest.NewRecorder()
+		limited(w, req)
+		return w.Code
+	}
+
+	if code := post("user-a"); code != http.StatusOK {
+		t.Fatalf("Expected the first request through, got %d", code)
+	}
+	if code := post("user-a"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, code)
+	}
+	if code := post("user-b"); code != http.StatusOK {
+		t.Errorf("Expected another user behind the same proxy through, got %d", code)
+	}
+}
+
+func TestScrapeDiffHandler_PrivateAddress(t *testing.T) {
+	f := &fakeDiffer{}
+	setDiffer(t, f)
+	for _, target := range []string{"http://169.254.169.254/latest/meta-data/", "http://127.0.0.1:8081/admin", "http://10.0.0.7/", "http://[::1]/"} {
+		w := postScrapeDiff(scrapeDiffHandler, `{"url":"`+target+`","cssSelector":".price"}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: Expected status %d, got %d", target, http.StatusBadRequest, w.Code)
+		}
+	}
+	if len(f.urls) != 0 {
+		t.Errorf("Expected no private page to be scraped, got %q", f.urls)
+	}
+}
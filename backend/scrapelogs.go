@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// defaultScrapeLogPageSize applies when /items/{id}/scrape-logs is requested
+// without a limit; an item accumulates an entry on every scheduler run.
+const defaultScrapeLogPageSize = 50
+
+// ScrapeLog is one scheduler attempt to scrape an item. Outlier marks an
+// attempt whose price disagreed with the other items on the same page and
+// was not recorded. HeaderNames lists the item's custom headers sent with
+// the request; their values are never logged. Locale and CountryCode are
+// what the page was requested for, FinalURL where it ended up after
+// redirects, and Variant the size or color selected on it, when recorded.
+// PriceSource is where the price was read: "selector", "jsonld" or
+// "adapter:" and the site adapter's name. BackoffSeconds is how long the
+// next check was put off after repeated failures.
+type ScrapeLog struct {
+	ID             int64    `json:"id"`
+	Status         string   `json:"status"`
+	Outlier        bool     `json:"outlier"`
+	FailureReason  *string  `json:"failureReason"`
+	Error          *string  `json:"error"`
+	DurationMs     int64    `json:"durationMs"`
+	UsedPlaywright bool     `json:"usedPlaywright"`
+	HeaderNames    []string `json:"headerNames,omitempty"`
+	Locale         string   `json:"locale,omitempty"`
+	CountryCode    string   `json:"countryCode,omitempty"`
+	FinalURL       string   `json:"finalUrl,omitempty"`
+	Variant        string   `json:"variant,omitempty"`
+	PriceSource    string   `json:"priceSource,omitempty"`
+	BackoffSeconds int64    `json:"backoffSeconds,omitempty"`
+	CreatedAt      string   `json:"createdAt"`
+}
+
+// itemScrapeLogsHandler serves /items/{id}/scrape-logs.
+var itemScrapeLogsHandler = methods{"GET": listScrapeLogsHandler}.ServeHTTP
+
+// listScrapeLogsHandler returns the item's scrape attempts, newest first.
+func listScrapeLogsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, offset, err := parsePagination(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = defaultScrapeLogPageSize
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, status, failure_reason, error, duration_ms, used_playwright, header_names, locale, country_code, final_url, variant, price_source, backoff_seconds, created_at
+		FROM scrape_log
+		WHERE item_id = $1 AND user_id = $2
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, id, userID, limit, offset)
+	if err != nil {
+		slog.Error("Failed to query scrape logs", "id", id, "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	logs := []ScrapeLog{}
+	for rows.Next() {
+		var l ScrapeLog
+		var createdAt time.Time
+		var failureReason, errText, locale, countryCode, finalURL, variant, priceSource sql.NullString
+		var backoff sql.NullInt64
+		var headerNames pq.StringArray
+		if err := rows.Scan(&l.ID, &l.Status, &failureReason, &errText, &l.DurationMs, &l.UsedPlaywright, &headerNames, &locale, &countryCode, &finalURL, &variant, &priceSource, &backoff, &createdAt); err != nil {
+			slog.Error("Failed to scan scrape log", "error", err)
+			continue
+		}
+		l.Outlier = l.Status == "outlier"
+		l.HeaderNames = headerNames
+		l.Locale, l.CountryCode = locale.String, countryCode.String
+		l.FinalURL, l.Variant = finalURL.String, variant.String
+		l.PriceSource, l.BackoffSeconds = priceSource.String, backoff.Int64
+		if failureReason.Valid {
+			l.FailureReason = &failureReason.String
+		}
+		if errText.Valid {
+			l.Error = &errText.String
+		}
+		l.CreatedAt = createdAt.Format(time.RFC3339)
+		logs = append(logs, l)
+	}
+
+	var total int64
+	if wantsEnvelope(r) {
+		if err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM scrape_log WHERE item_id = $1 AND user_id = $2
+		`, id, userID).Scan(&total); err != nil {
+			slog.Error("Failed to count scrape logs", "id", id, "error", err)
+			queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	respondList(w, r, newListMeta(total, limit, offset), jsonList(logs))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var scrapeLogColumns = []string{"id", "status", "failure_reason", "error", "duration_ms", "used_playwright", "header_names", "locale", "country_code", "final_url", "variant", "price_source", "backoff_seconds", "created_at"}
+
+// getScrapeLogs performs a GET /items/item-1/scrape-logs request.
+func getScrapeLogs(target, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	req.Header.Set("Accept", accept)
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+	itemScrapeLogsHandler(w, req)
+	return w
+}
+
+func TestItemScrapeLogsHandler_BareArray(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("FROM scrape_log").
+		WithArgs("item-1", "user-1", defaultScrapeLogPageSize, 0).
+		WillReturnRows(sqlmock.NewRows(scrapeLogColumns).
+			AddRow(3, "outlier", "price_outlier", nil, 380, false, "{X-Api-Key}", "de-DE", "DE", "https://example.de/p/1", "US 11", "adapter:amazon", nil, time.Now()).
+			AddRow(2, "failed", "timeout", "context deadline exceeded", 10000, true, nil, "en-US", nil, nil, nil, nil, 7200, time.Now()).
+			AddRow(1, "success", nil, nil, 420, false, nil, nil, nil, nil, nil, "selector", nil, time.Now()))
+
+	w := getScrapeLogs("/items/item-1/scrape-logs", "")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var logs []ScrapeLog
+	if err := json.Unmarshal(w.Body.Bytes(), &logs); err != nil {
+		t.Fatalf("Response is not a JSON array: %v\n%s", err, w.Body.String())
+	}
+	if len(logs) != 3 || *logs[1].FailureReason != "timeout" || logs[2].FailureReason != nil {
+		t.Errorf("Unexpected logs: %+v", logs)
+	}
+	if !logs[0].Outlier || *logs[0].FailureReason != "price_outlier" || logs[1].Outlier || logs[2].Outlier {
+		t.Errorf("Expected only the first attempt to be flagged as an outlier, got %+v", logs)
+	}
+	if len(logs[0].HeaderNames) != 1 || logs[0].HeaderNames[0] != "X-Api-Key" || logs[1].HeaderNames != nil {
+		t.Errorf("Expected only the first attempt to list header names, got %+v", logs)
+	}
+	if logs[0].Locale != "de-DE" || logs[0].CountryCode != "DE" || logs[1].Locale != "en-US" || logs[1].CountryCode != "" || logs[2].Locale != "" {
+		t.Errorf("Expected the recorded locales and countries, got %+v", logs)
+	}
+	if logs[0].FinalURL != "https://example.de/p/1" || logs[1].FinalURL != "" {
+		t.Errorf("Expected only the first attempt to have a final URL, got %+v", logs)
+	}
+	if logs[0].Variant != "US 11" || logs[1].Variant != "" {
+		t.Errorf("Expected only the first attempt to have a variant, got %+v", logs)
+	}
+	if logs[0].PriceSource != "adapter:amazon" || logs[1].PriceSource != "" || logs[2].PriceSource != "selector" {
+		t.Errorf("Expected the recorded price sources, got %+v", logs)
+	}
+	if logs[0].BackoffSeconds != 0 || logs[1].BackoffSeconds != 7200 {
+		t.Errorf("Expected only the failed attempt to have backed off, got %+v", logs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemScrapeLogsHandler_Envelope(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("FROM scrape_log").
+		WithArgs("item-1", "user-1", 1, 0).
+		WillReturnRows(sqlmock.NewRows(scrapeLogColumns).
+			AddRow(2, "success", nil, nil, 300, false, nil, nil, nil, nil, nil, "selector", nil, time.Now()))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM scrape_log`).
+		WithArgs("item-1", "user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	w := getScrapeLogs("/items/item-1/scrape-logs?limit=1", envelopeMediaType)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var body struct {
+		Data []ScrapeLog `json:"data"`
+		Meta listMeta    `json:"meta"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Response is not an envelope: %v\n%s", err, w.Body.String())
+	}
+	if len(body.Data) != 1 || body.Meta.Total != 3 || body.Meta.Limit != 1 || body.Meta.NextCursor != encodeCursor(1) {
+		t.Errorf("Unexpected envelope: %+v", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
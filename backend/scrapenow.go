@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"price-track-backend/internal/scheduler"
+)
+
+// scrapeNowTimeout bounds POST /admin/scrape-now, which streams a whole price
+// check run. It matches the scraper job's own limit.
+const scrapeNowTimeout = time.Hour
+
+// priceChecker runs full price checks for POST /admin/scrape-now. main sets it
+// to a scheduler once the database is open.
+var priceChecker interface {
+	CheckAllPrices(ctx context.Context)
+}
+
+// scrapeNowRunning is held for the duration of a POST /admin/scrape-now run,
+// so that operators cannot start several at once.
+var scrapeNowRunning sync.Mutex
+
+// scrapeNowSummary is the last line of a POST /admin/scrape-now response.
+type scrapeNowSummary struct {
+	Done       bool           `json:"done"`
+	Cancelled  bool           `json:"cancelled,omitempty"`
+	Checked    int            `json:"checked"`
+	ByStatus   map[string]int `json:"byStatus"`
+	DurationMs int64          `json:"durationMs"`
+}
+
+// adminScrapeNowHandler serves /admin/scrape-now.
+var adminScrapeNowHandler = methods{"POST": scrapeNowHandler}.ServeHTTP
+
+// scrapeNowHandler runs a full price check out of band, as the scheduled job
+// would, and streams the outcome of each item as a line of JSON while the run
+// progresses. A summary line with "done": true ends the response. Only one
+// run is allowed at a time; a second request gets 409 Conflict.
+func scrapeNowHandler(w http.ResponseWriter, r *http.Request) {
+	if !scrapeNowRunning.TryLock() {
+		http.Error(w, "A price check triggered from /admin/scrape-now is already running", http.StatusConflict)
+		return
+	}
+	defer scrapeNowRunning.Unlock()
+
+	userID, _ := r.Context().Value(userIDKey).(string)
+	slog.Info("Starting out-of-band price check", "user_id", userID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), scrapeNowTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+
+	var mu sync.Mutex
+	summary := scrapeNowSummary{Done: true, ByStatus: map[string]int{}}
+	report := func(result scheduler.ItemResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		summary.Checked++
+		summary.ByStatus[result.Status]++
+		if err := enc.Encode(result); err == nil {
+			rc.Flush()
+		}
+	}
+
+	start := time.Now()
+	priceChecker.CheckAllPrices(scheduler.WithItemResults(ctx, report))
+	// The run may have changed anyone's prices and statuses.
+	responseCache.InvalidatePrefix("")
+
+	mu.Lock()
+	defer mu.Unlock()
+	summary.Cancelled = ctx.Err() != nil
+	summary.DurationMs = time.Since(start).Milliseconds()
+	enc.Encode(summary)
+	slog.Info("Completed out-of-band price check", "user_id", userID, "checked", summary.Checked, "cancelled", summary.Cancelled)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// blockingChecker holds CheckAllPrices until release is closed.
+type blockingChecker struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingChecker) CheckAllPrices(ctx context.Context) {
+	close(c.started)
+	<-c.release
+}
+
+func setPriceChecker(t *testing.T, checker interface{ CheckAllPrices(context.Context) }) {
+	t.Helper()
+	prev := priceChecker
+	priceChecker = checker
+	t.Cleanup(func() { priceChecker = prev })
+}
+
+func postScrapeNow() *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/admin/scrape-now", nil)
+	req = req.WithContext(setupTestContext("admin-1"))
+	w := httptest.NewRecorder()
+	adminScrapeNowHandler(w, req)
+	return w
+}
+
+func TestScrapeNowHandler_RejectsConcurrentRuns(t *testing.T) {
+	checker := &blockingChecker{started: make(chan struct{}), release: make(chan struct{})}
+	setPriceChecker(t, checker)
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- postScrapeNow() }()
+	<-checker.started
+
+	if w := postScrapeNow(); w.Code != http.StatusConflict {
+		t.Errorf("Expected a second run to get status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	close(checker.release)
+	w := <-first
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the first run to get status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var summary scrapeNowSummary
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), &summary); err != nil {
+			t.Fatalf("Failed to decode line %q: %v", scanner.Text(), err)
+		}
+	}
+	if !summary.Done || summary.Checked != 0 {
+		t.Errorf("Expected a summary line for an empty run, got %+v", summary)
+	}
+
+	// Once the first run is over, another may start.
+	setPriceChecker(t, &blockingChecker{started: make(chan struct{}), release: checker.release})
+	if w := postScrapeNow(); w.Code != http.StatusOK {
+		t.Errorf("Expected a later run to get status %d, got %d", http.StatusOK, w.Code)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"price-track-backend/internal/blobstore"
+)
+
+const (
+	// defaultScreenshotPageSize applies when /items/{id}/screenshots is
+	// requested without a limit.
+	defaultScreenshotPageSize = 50
+	// screenshotURLTTL is how long the link a screenshot redirects to works.
+	screenshotURLTTL = 15 * time.Minute
+)
+
+// blobs keeps failure screenshots (BLOB_STORE_DIR or S3_BUCKET). It is set
+// from the configuration in main; nil when screenshots are not kept.
+var blobs blobstore.Store
+
+// Screenshot is the page as the browser saw it when a check found no price
+// on it. Error is why the check failed.
+type Screenshot struct {
+	ID        int64   `json:"id"`
+	Error     *string `json:"error"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// itemScreenshotsHandler serves /items/{id}/screenshots.
+var itemScreenshotsHandler = methods{"GET": listScreenshotsHandler}.ServeHTTP
+
+// itemScreenshotHandler serves /items/{id}/screenshots/{screenshotId}.
+var itemScreenshotHandler = methods{"GET": getScreenshotHandler}.ServeHTTP
+
+// listScreenshotsHandler returns the item's failure screenshots, newest
+// first.
+func listScreenshotsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, offset, err := parsePagination(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = defaultScreenshotPageSize
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, error, created_at
+		FROM scrape_screenshots
+		WHERE item_id = $1 AND user_id = $2
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, id, userID, limit, offset)
+	if err != nil {
+		slog.Error("Failed to query screenshots", "id", id, "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	shots := []Screenshot{}
+	for rows.Next() {
+		var s Screenshot
+		var errText sql.NullString
+		var createdAt time.Time
+		if err := rows.Scan(&s.ID, &errText, &createdAt); err != nil {
+			slog.Error("Failed to scan screenshot", "error", err)
+			continue
+		}
+		if errText.Valid {
+			s.Error = &errText.String
+		}
+		s.CreatedAt = createdAt.Format(time.RFC3339)
+		shots = append(shots, s)
+	}
+
+	var total int64
+	if wantsEnvelope(r) {
+		if err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM scrape_screenshots WHERE item_id = $1 AND user_id = $2
+		`, id, userID).Scan(&total); err != nil {
+			slog.Error("Failed to count screenshots", "id", id, "error", err)
+			queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	respondList(w, r, newListMeta(total, limit, offset), jsonList(shots))
+}
+
+// getScreenshotHandler redirects to a short-lived link to the screenshot's
+// PNG, or serves the PNG itself when the blob store has no links (a
+// directory).
+func getScreenshotHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	screenshotID, err := strconv.ParseInt(r.PathValue("screenshotId"), 10, 64)
+	if err != nil || blobs == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	var key string
+	err = db.QueryRowContext(ctx, `
+		SELECT key FROM scrape_screenshots
+		WHERE id = $1 AND item_id = $2 AND user_id = $3
+	`, screenshotID, r.PathValue("id"), userID).Scan(&key)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to query screenshot", "id", screenshotID, "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	link, err := blobs.URL(r.Context(), key, screenshotURLTTL)
+	if err == nil {
+		http.Redirect(w, r, link, http.StatusFound)
+		return
+	}
+	if !errors.Is(err, blobstore.ErrNoURL) {
+		slog.Error("Failed to sign screenshot URL", "key", key, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	png, err := blobs.Get(r.Context(), key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to read screenshot", "key", key, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Write(png)
+}
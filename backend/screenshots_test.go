@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/blobstore"
+)
+
+// useBlobs makes store the API's blob store for the test.
+func useBlobs(t *testing.T, store blobstore.Store) {
+	t.Helper()
+	previous := blobs
+	blobs = store
+	t.Cleanup(func() { blobs = previous })
+}
+
+// getScreenshot performs a GET /items/item-1/screenshots/{screenshotId}
+// request.
+func getScreenshot(screenshotID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/items/item-1/screenshots/"+screenshotID, nil)
+	req.SetPathValue("id", "item-1")
+	req.SetPathValue("screenshotId", screenshotID)
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+	itemScreenshotHandler(w, req)
+	return w
+}
+
+func TestItemScreenshotsHandler(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("FROM scrape_screenshots").
+		WithArgs("item-1", "user-1", defaultScreenshotPageSize, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "error", "created_at"}).
+			AddRow(2, "element not found with css selector (Playwright): .price", time.Now()).
+			AddRow(1, nil, time.Now()))
+
+	req := httptest.NewRequest("GET", "/items/item-1/screenshots", nil)
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+	itemScreenshotsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var shots []Screenshot
+	if err := json.Unmarshal(w.Body.Bytes(), &shots); err != nil {
+		t.Fatalf("Response is not a JSON array: %v\n%s", err, w.Body.String())
+	}
+	if len(shots) != 2 || shots[0].ID != 2 || !strings.Contains(*shots[0].Error, ".price") || shots[1].Error != nil {
+		t.Errorf("Unexpected screenshots: %+v", shots)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemScreenshotHandler_Dir(t *testing.T) {
+	mock := setupMockDB(t)
+	store := blobstore.Dir(t.TempDir())
+	useBlobs(t, store)
+	store.Put(context.Background(), "screenshots/item-1/1.png", []byte("png-bytes"), "image/png")
+
+	mock.ExpectQuery("SELECT key FROM scrape_screenshots").
+		WithArgs(int64(7), "item-1", "user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("screenshots/item-1/1.png"))
+
+	w := getScreenshot("7")
+	if w.Code != http.StatusOK || w.Body.String() != "png-bytes" || w.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("Expected the PNG from the directory, got %d %q (%s)", w.Code, w.Body.String(), w.Header().Get("Content-Type"))
+	}
+
+	// Another user's screenshot, or one that is not a number, is not found.
+	mock.ExpectQuery("SELECT key FROM scrape_screenshots").
+		WithArgs(int64(8), "item-1", "user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"key"}))
+	if w := getScreenshot("8"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := getScreenshot("latest"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemScreenshotHandler_RedirectsToS3(t *testing.T) {
+	mock := setupMockDB(t)
+	store, err := blobstore.NewS3(blobstore.S3Config{Endpoint: "http://minio:9000", Bucket: "shots", AccessKeyID: "id", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatalf("NewS3 failed: %v", err)
+	}
+	useBlobs(t, store)
+
+	mock.ExpectQuery("SELECT key FROM scrape_screenshots").
+		WithArgs(int64(7), "item-1", "user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("screenshots/item-1/1.png"))
+
+	w := getScreenshot("7")
+	location := w.Header().Get("Location")
+	if w.Code != http.StatusFound || !strings.HasPrefix(location, "http://minio:9000/shots/screenshots/item-1/1.png?") || !strings.Contains(location, "X-Amz-Expires=900") {
+		t.Errorf("Expected a redirect to a presigned URL, got %d to %q", w.Code, location)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
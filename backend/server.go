@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"price-track-backend/internal/config"
+)
+
+const (
+	// shutdownTimeout is how long requests in flight get to finish once the
+	// server is told to stop. Event streams are cut off after it.
+	shutdownTimeout = 10 * time.Second
+	// challengeAddr is where Let's Encrypt asks for HTTP-01 challenges.
+	challengeAddr = ":80"
+	// domainLookupTimeout bounds the startup check of AUTOCERT_DOMAINS.
+	domainLookupTimeout = 5 * time.Second
+)
+
+// serve runs the API on cfg.ListenAddr until ctx is done, then gives the
+// requests in flight shutdownTimeout to finish. With TLS_CERT_FILE the API
+// is served over HTTPS. With AUTOCERT_DOMAINS its certificates come from
+// Let's Encrypt, and a second listener on :80 answers the HTTP-01
+// challenges and redirects everything else to HTTPS; both are shut down
+// together. It returns early with the error of a listener that fails.
+func serve(ctx context.Context, cfg config.Config, handler http.Handler) error {
+	api := &http.Server{Addr: cfg.ListenAddr, Handler: handler}
+	servers := []*http.Server{api}
+	listen := api.ListenAndServe
+
+	switch {
+	case cfg.TLS.CertFile != "":
+		listen = func() error { return api.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile) }
+	case len(cfg.TLS.AutocertDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLS.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.TLS.AutocertCacheDir),
+		}
+		api.TLSConfig = manager.TLSConfig()
+		listen = func() error { return api.ListenAndServeTLS("", "") }
+		servers = append(servers, &http.Server{Addr: challengeAddr, Handler: manager.HTTPHandler(httpsRedirect(cfg.ListenAddr))})
+	}
+
+	errc := make(chan error, len(servers))
+	go func() { errc <- listen() }()
+	for _, srv := range servers[1:] {
+		go func() { errc <- srv.ListenAndServe() }()
+	}
+	for _, srv := range servers {
+		slog.Info("Server starting", "addr", srv.Addr, "tls", srv == api && cfg.TLS.Enabled())
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		slog.Info("Server shutting down")
+	case err = <-errc:
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil {
+			slog.Warn("Requests still in flight at shutdown were cut off", "addr", srv.Addr, "error", shutdownErr)
+		}
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// httpsRedirect sends plain HTTP requests to the same URL over HTTPS on
+// apiAddr's port, keeping their method with 308 Permanent Redirect.
+func httpsRedirect(apiAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(apiAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// checkDomainsResolve looks up every autocert domain, so that a typo stops
+// the server at startup instead of failing each certificate request.
+func checkDomainsResolve(ctx context.Context, domains []string, lookup func(context.Context, string) ([]string, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, domainLookupTimeout)
+	defer cancel()
+	var errs []error
+	for _, domain := range domains {
+		if addrs, err := lookup(ctx, domain); err != nil || len(addrs) == 0 {
+			errs = append(errs, fmt.Errorf("AUTOCERT_DOMAINS: %s does not resolve (%v)", domain, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"price-track-backend/internal/config"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	for _, tc := range []struct{ apiAddr, host, want string }{
+		{":443", "api.example.com", "https://api.example.com/items?limit=5"},
+		{":443", "api.example.com:80", "https://api.example.com/items?limit=5"},
+		{":8443", "api.example.com", "https://api.example.com:8443/items?limit=5"},
+	} {
+		req := httptest.NewRequest("POST", "http://"+tc.host+"/items?limit=5", nil)
+		w := httptest.NewRecorder()
+		httpsRedirect(tc.apiAddr).ServeHTTP(w, req)
+		if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != tc.want {
+			t.Errorf("Expected 308 to %s, got %d to %s", tc.want, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestCheckDomainsResolve(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		if host == "api.example.com" {
+			return []string{"203.0.113.7"}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if err := checkDomainsResolve(context.Background(), []string{"api.example.com"}, lookup); err != nil {
+		t.Errorf("Expected the domain to resolve, got %v", err)
+	}
+	err := checkDomainsResolve(context.Background(), []string{"api.example.com", "api.exmaple.com"}, lookup)
+	if err == nil || !strings.Contains(err.Error(), "api.exmaple.com does not resolve") || strings.Contains(err.Error(), "api.example.com ") {
+		t.Errorf("Expected only the misspelled domain to be reported, got %v", err)
+	}
+}
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestServe_TLSCertFiles(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}, NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	cfg := config.Config{
+		ListenAddr: freeAddr(t),
+		TLS:        config.TLSConfig{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")},
+	}
+	os.WriteFile(cfg.TLS.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+	os.WriteFile(cfg.TLS.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Proto+" "+r.URL.Path)
+		}))
+	}()
+
+	roots := x509.NewCertPool()
+	cert, _ := x509.ParseCertificate(der)
+	roots.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	var resp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if resp, err = client.Get("https://" + cfg.ListenAddr + "/version"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("Expected the API over HTTPS, got %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "HTTP/2.0 /version" && string(body) != "HTTP/1.1 /version" {
+		t.Errorf("Unexpected response %q", body)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(shutdownTimeout):
+		t.Fatal("Expected serve to return once its context is done")
+	}
+	if _, err := net.Dial("tcp", cfg.ListenAddr); err == nil {
+		t.Error("Expected the listener to be closed")
+	}
+}
+
+func TestServe_ListenerFails(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	err = serve(context.Background(), config.Config{ListenAddr: l.Addr().String()}, http.NotFoundHandler())
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		t.Errorf("Expected the address in use to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"price-track-backend/internal/settings"
+)
+
+// settingsCacheTTL bounds how long settings written by another process may
+// be served stale.
+const settingsCacheTTL = time.Minute
+
+// settingsLoader reads user settings. main replaces it once the database is
+// open; until then (and in tests) it does not cache.
+var settingsLoader = settings.NewLoader(nil, 0)
+
+// settingsResponse is the body of GET and PUT /settings: the effective
+// settings plus the read-only items quota.
+type settingsResponse struct {
+	settings.Settings
+	ItemsQuota ItemsQuota `json:"itemsQuota"`
+}
+
+// writeSettings responds with userID's settings and quota.
+func writeSettings(ctx context.Context, w http.ResponseWriter, userID string) {
+	s, err := settingsLoader.Get(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to load settings", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	q, err := loadItemsQuota(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to load items quota", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settingsResponse{s, q})
+}
+
+// settingsHandler serves /settings.
+var settingsHandler = methods{
+	"GET": getSettingsHandler,
+	"PUT": putSettingsHandler,
+}.ServeHTTP
+
+func getSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	writeSettings(ctx, w, userID)
+}
+
+func putSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	var update settings.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := update.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := settingsLoader.Save(ctx, userID, update); err != nil {
+		slog.Error("Failed to save settings", "error", err)
+		queryError(ctx, w, err, "Failed to save settings", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Saved settings", "user_id", userID)
+	writeSettings(ctx, w, userID)
+}
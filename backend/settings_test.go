@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/settings"
+)
+
+var settingsColumns = []string{"currency", "timezone", "quiet_hours_start", "quiet_hours_end", "digest_frequency", "drop_threshold_percent", "email_price_drops", "notification_channel"}
+
+func doSettingsRequest(t *testing.T, method, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "/settings", strings.NewReader(body))
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+	settingsHandler(w, req)
+	return w
+}
+
+func decodeSettings(t *testing.T, w *httptest.ResponseRecorder) settingsResponse {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var s settingsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return s
+}
+
+func TestSettingsHandler_GetDefaults(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("FROM user_settings").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows(settingsColumns))
+	expectItemsQuota(mock, "user-1", nil, 3)
+
+	setItemsQuotaDefault(t, 100)
+	s := decodeSettings(t, doSettingsRequest(t, "GET", ""))
+
+	if s.Settings != settings.Defaults() {
+		t.Errorf("Expected defaults, got %+v", s.Settings)
+	}
+	if s.ItemsQuota != (ItemsQuota{Limit: 100, Usage: 3}) {
+		t.Errorf("Expected the default quota and usage, got %+v", s.ItemsQuota)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestSettingsHandler_GetMergesOverrides(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("FROM user_settings").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow("EUR", nil, "22:00", "07:00", nil, nil, nil, nil))
+	expectItemsQuota(mock, "user-1", nil, 0)
+
+	s := decodeSettings(t, doSettingsRequest(t, "GET", ""))
+
+	want := settings.Defaults()
+	want.Currency, want.QuietHoursStart, want.QuietHoursEnd = "EUR", "22:00", "07:00"
+	if s.Settings != want {
+		t.Errorf("Expected %+v, got %+v", want, s.Settings)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestSettingsHandler_PutPartial(t *testing.T) {
+	mock := setupMockDB(t)
+
+	// Only the fields in the body are written; the rest are passed as NULL so
+	// the upsert keeps the stored values.
+	mock.ExpectExec("INSERT INTO user_settings").
+		WithArgs("user-1", nil, "Europe/Berlin", nil, nil, nil, 10.0, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM user_settings").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow("GBP", "Europe/Berlin", nil, nil, nil, 10.0, nil, nil))
+	expectItemsQuota(mock, "user-1", nil, 0)
+
+	s := decodeSettings(t, doSettingsRequest(t, "PUT", `{"timezone":"Europe/Berlin","dropThresholdPercent":10}`))
+
+	if s.Currency != "GBP" || s.Timezone != "Europe/Berlin" || s.DropThresholdPercent != 10 || s.DigestFrequency != "off" {
+		t.Errorf("Expected stored currency kept and new values applied, got %+v", s)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestSettingsHandler_PutInvalid(t *testing.T) {
+	mock := setupMockDB(t)
+
+	for _, body := range []string{
+		`{"currency":"DOLLARS"}`,
+		`{"timezone":"Nowhere/Special"}`,
+		`{"quietHoursStart":"22:00"}`,
+		`{"digestFrequency":"hourly"}`,
+		`{"dropThresholdPercent":150}`,
+		`{"notificationChannel":"sms"}`,
+		`not json`,
+	} {
+		w := doSettingsRequest(t, "PUT", body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sharedCacheMaxAge is how long clients and proxies may reuse a shared
+	// view. It also bounds how long a revoked link can keep being served
+	// from a cache outside the API.
+	sharedCacheMaxAge = time.Minute
+	// sharedHistoryDays is how far back a shared view's history goes.
+	sharedHistoryDays = 365
+)
+
+// sharedRateLimit limits reads of /shared/{token} per client IP; the
+// endpoint is unauthenticated.
+var sharedRateLimit = newIPRateLimiter("shared", 60, time.Minute)
+
+// ItemShare is the response to creating a share link.
+type ItemShare struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// SharedItem is the public view of a shared item. It deliberately carries
+// nothing that identifies the owner or how the item is scraped.
+type SharedItem struct {
+	ProductName  string             `json:"productName"`
+	ImageURL     string             `json:"imageUrl"`
+	PriceText    string             `json:"priceText"`
+	CurrentPrice *float64           `json:"currentPrice"`
+	History      []SharedPricePoint `json:"history"`
+}
+
+// SharedPricePoint aggregates the prices observed on one UTC day.
+type SharedPricePoint struct {
+	Date  string  `json:"date"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Close float64 `json:"close"`
+}
+
+// generateShareToken returns a new random, URL-safe share token.
+func generateShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// sharedCacheKey is the response cache key of a shared view. It is not
+// scoped to a user, so revoking drops it explicitly.
+func sharedCacheKey(token string) string {
+	return "shared|" + token
+}
+
+// itemShareHandler serves /items/{id}/share.
+var itemShareHandler = methods{
+	"POST":   createItemShareHandler,
+	"DELETE": deleteItemShareHandler,
+}.ServeHTTP
+
+// createItemShareHandler returns the item's share link, creating one if the
+// item is not shared yet.
+func createItemShareHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		slog.Error("Failed to generate share token", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+	err = db.QueryRowContext(ctx, `
+		UPDATE tracked_items
+		SET share_token = COALESCE(share_token, $3)
+		WHERE id = $1 AND user_id = $2
+		RETURNING share_token
+	`, id, userID, token).Scan(&token)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to share item", "id", id, "error", err)
+		queryError(ctx, w, err, "Failed to share item", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Shared item", "id", id, "user_id", userID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ItemShare{
+		Token: token,
+		URL:   strings.TrimSuffix(publicURL, "/") + "/shared/" + token,
+	})
+}
+
+// deleteItemShareHandler revokes the item's share link. Revoking an item that
+// is not shared succeeds.
+func deleteItemShareHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+	var old sql.NullString
+	err := db.QueryRowContext(ctx, `
+		UPDATE tracked_items t
+		SET share_token = NULL
+		FROM (SELECT id, share_token FROM tracked_items WHERE id = $1 AND user_id = $2 FOR UPDATE) old
+		WHERE t.id = old.id
+		RETURNING old.share_token
+	`, id, userID).Scan(&old)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to revoke share link", "id", id, "error", err)
+		queryError(ctx, w, err, "Failed to revoke share link", http.StatusInternalServerError)
+		return
+	}
+	if old.Valid {
+		responseCache.InvalidatePrefix(sharedCacheKey(old.String))
+	}
+
+	slog.Info("Revoked share link", "id", id, "user_id", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sharedItemHandler serves /shared/{token} without authentication.
+var sharedItemHandler = methods{"GET": getSharedItemHandler}.ServeHTTP
+
+func getSharedItemHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	token := r.PathValue("token")
+	resp, err := responseCache.GetOrLoad(sharedCacheKey(token), func() (cachedResponse, error) {
+		item, err := loadSharedItem(ctx, token)
+		if err != nil {
+			return cachedResponse{}, err
+		}
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(item); err != nil {
+			return cachedResponse{}, err
+		}
+		sum := sha256.Sum256(buf.Bytes())
+		return cachedResponse{ETag: `"` + hex.EncodeToString(sum[:12]) + `"`, Body: buf.Bytes()}, nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to load shared item", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(sharedCacheMaxAge/time.Second)))
+	w.Header().Set("ETag", resp.ETag)
+	if etagMatches(r.Header.Get("If-None-Match"), resp.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp.Body)
+}
+
+// loadSharedItem reads the public view of the item shared under token. It
+// returns sql.ErrNoRows for unknown or revoked tokens.
+func loadSharedItem(ctx context.Context, token string) (SharedItem, error) {
+	var item SharedItem
+	var id string
+	var current sql.NullFloat64
+	err := db.QueryRowContext(ctx, `
+		SELECT id, product_name, image_url, price_text, last_price
+		FROM tracked_items
+		WHERE share_token = $1
+	`, token).Scan(&id, &item.ProductName, &item.ImageURL, &item.PriceText, &current)
+	if err != nil {
+		return item, err
+	}
+	if current.Valid {
+		item.CurrentPrice = &current.Float64
+	}
+
+	item.History, err = dailyPriceHistory(ctx, id)
+	return item, err
+}
+
+// dailyPriceHistory aggregates the prices item id was seen at over the last
+// sharedHistoryDays, one point per UTC day, oldest first.
+func dailyPriceHistory(ctx context.Context, id string) ([]SharedPricePoint, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT to_char(date_trunc('day', recorded_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD'),
+			MIN(price), MAX(price), (array_agg(price ORDER BY recorded_at DESC))[1]
+		FROM item_price_history
+		WHERE item_id = $1 AND recorded_at >= NOW() - make_interval(days => $2)
+		GROUP BY 1
+		ORDER BY 1
+	`, id, sharedHistoryDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []SharedPricePoint{}
+	for rows.Next() {
+		var p SharedPricePoint
+		if err := rows.Scan(&p.Date, &p.Min, &p.Max, &p.Close); err != nil {
+			return nil, err
+		}
+		history = append(history, p)
+	}
+	return history, rows.Err()
+}
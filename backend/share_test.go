@@ -0,0 +1,249 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/cache"
+)
+
+// expectSharedItem expects the queries behind GET /shared/{token}.
+func expectSharedItem(mock sqlmock.Sqlmock, token string) {
+	mock.ExpectQuery("FROM tracked_items\\s+WHERE share_token = \\$1").
+		WithArgs(token).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_name", "image_url", "price_text", "last_price"}).
+			AddRow("item-1", "Widget", "https://example.com/a.png", "$15.00", 15.0))
+	mock.ExpectQuery("FROM item_price_history").
+		WithArgs("item-1", sharedHistoryDays).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "min", "max", "close"}).
+			AddRow("2025-01-01", 19.99, 19.99, 19.99).
+			AddRow("2025-01-02", 15.0, 17.5, 15.0))
+}
+
+func getShared(t *testing.T, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/shared/"+token, nil)
+	req.SetPathValue("token", token)
+	w := httptest.NewRecorder()
+	sharedItemHandler(w, req)
+	return w
+}
+
+func TestItemShareHandler_Post(t *testing.T) {
+	mock := setupMockDB(t)
+	prev := publicURL
+	publicURL = "https://api.example.com/"
+	t.Cleanup(func() { publicURL = prev })
+
+	mock.ExpectQuery("SET share_token = COALESCE\\(share_token, \\$3\\)").
+		WithArgs("item-1", "test-user-id", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"share_token"}).AddRow("tok"))
+
+	req := httptest.NewRequest("POST", "/items/item-1/share", nil)
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemShareHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var share ItemShare
+	if err := json.Unmarshal(w.Body.Bytes(), &share); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if share.Token != "tok" || share.URL != "https://api.example.com/shared/tok" {
+		t.Errorf("Expected the existing token and its URL, got %+v", share)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemShareHandler_PostNotFound(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("SET share_token").
+		WithArgs("other", "test-user-id", sqlmock.AnyArg()).
+		WillReturnError(sql.ErrNoRows)
+
+	req := httptest.NewRequest("POST", "/items/other/share", nil)
+	req.SetPathValue("id", "other")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemShareHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestSharedItemHandler_OmitsPrivateFields(t *testing.T) {
+	mock := setupMockDB(t)
+	expectSharedItem(mock, "tok")
+
+	w := getShared(t, "tok")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Header().Get("Cache-Control"), "public") || w.Header().Get("ETag") == "" {
+		t.Errorf("Expected a cacheable response, got headers %v", w.Header())
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	allowed := map[string]bool{"productName": true, "imageUrl": true, "priceText": true, "currentPrice": true, "history": true}
+	for key := range body {
+		if !allowed[key] {
+			t.Errorf("Unexpected field %q in shared view", key)
+		}
+	}
+	for _, private := range []string{"cssSelector", "xpath", "outerHtmlSnippet", "userId", "test-user-id"} {
+		if strings.Contains(w.Body.String(), private) {
+			t.Errorf("Expected shared view not to contain %q, got %s", private, w.Body.String())
+		}
+	}
+
+	var item SharedItem
+	if err := json.Unmarshal(w.Body.Bytes(), &item); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(item.History) != 2 || item.History[1] != (SharedPricePoint{Date: "2025-01-02", Min: 15, Max: 17.5, Close: 15}) {
+		t.Errorf("Unexpected history: %+v", item.History)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestSharedItemHandler_Revoked(t *testing.T) {
+	mock := setupMockDB(t)
+	prev := responseCache
+	responseCache = cache.New[cachedResponse](time.Minute)
+	t.Cleanup(func() { responseCache = prev })
+
+	expectSharedItem(mock, "tok")
+	if w := getShared(t, "tok"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	// Served from the cache: no queries expected.
+	if w := getShared(t, "tok"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d from the cache, got %d", http.StatusOK, w.Code)
+	}
+
+	mock.ExpectQuery("SET share_token = NULL").
+		WithArgs("item-1", "test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"share_token"}).AddRow("tok"))
+	req := httptest.NewRequest("DELETE", "/items/item-1/share", nil)
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+	itemShareHandler(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	mock.ExpectQuery("WHERE share_token = \\$1").
+		WithArgs("tok").
+		WillReturnError(sql.ErrNoRows)
+	if w := getShared(t, "tok"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d after revoking, got %d", http.StatusNotFound, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestIPRateLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newIPRateLimiter("shared", 2, time.Minute)
+	l.now = func() time.Time { return now }
+	h := l.Middleware(func(w http.ResponseWriter, r *http.Request) {})
+
+	request := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/shared/tok", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request("192.0.2.1:1000"); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status %d, got %d", i, http.StatusOK, w.Code)
+		}
+	}
+	w := request("192.0.2.1:2000")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", got)
+	}
+	if w := request("192.0.2.2:1000"); w.Code != http.StatusOK {
+		t.Errorf("Expected another IP to be allowed, got %d", w.Code)
+	}
+
+	now = now.Add(time.Minute)
+	if w := request("192.0.2.1:1000"); w.Code != http.StatusOK {
+		t.Errorf("Expected the limit to reset after the window, got %d", w.Code)
+	}
+}
+
+func TestIPRateLimiter_TrustedProxy(t *testing.T) {
+	prev := trustedProxies
+	trustedProxies = []netip.Prefix{netip.MustParsePrefix("172.16.0.0/12")}
+	t.Cleanup(func() { trustedProxies = prev })
+
+	l := newIPRateLimiter("shared", 1, time.Minute)
+	h := l.Middleware(func(w http.ResponseWriter, r *http.Request) {})
+	request := func(remoteAddr string, header map[string]string) int {
+		req := httptest.NewRequest("GET", "/shared/tok", nil)
+		req.RemoteAddr = remoteAddr
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w.Code
+	}
+
+	// Behind nginx, each client gets a bucket of its own.
+	nginx := "172.18.0.5:40000"
+	if code := request(nginx, map[string]string{"X-Real-IP": "198.51.100.1", "X-Forwarded-For": "198.51.100.1"}); code != http.StatusOK {
+		t.Fatalf("Expected the first client through, got %d", code)
+	}
+	if code := request(nginx, map[string]string{"X-Real-IP": "198.51.100.2", "X-Forwarded-For": "198.51.100.2"}); code != http.StatusOK {
+		t.Errorf("Expected a second client behind the proxy through, got %d", code)
+	}
+	if code := request(nginx, map[string]string{"X-Real-IP": "198.51.100.1", "X-Forwarded-For": "198.51.100.1"}); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the first client to be limited, got %d", code)
+	}
+	// An address the client made up is to the left of the one nginx added.
+	if code := request(nginx, map[string]string{"X-Forwarded-For": "203.0.113.9, 198.51.100.1"}); code != http.StatusTooManyRequests {
+		t.Errorf("Expected a spoofed X-Forwarded-For entry to be ignored, got %d", code)
+	}
+	// Headers from a client that is not a trusted proxy are ignored.
+	if code := request("198.51.100.3:1000", map[string]string{"X-Real-IP": "198.51.100.9"}); code != http.StatusOK {
+		t.Fatalf("Expected a direct client through, got %d", code)
+	}
+	if code := request("198.51.100.3:1000", map[string]string{"X-Real-IP": "198.51.100.10"}); code != http.StatusTooManyRequests {
+		t.Errorf("Expected a direct client's X-Real-IP to be ignored, got %d", code)
+	}
+}
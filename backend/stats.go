@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// Stats is the at-a-glance dashboard summary for a user.
+type Stats struct {
+	ItemsTracked     int     `json:"itemsTracked"`
+	ActiveItems      int     `json:"activeItems"`
+	PausedItems      int     `json:"pausedItems"`
+	DropsLast7Days   int     `json:"dropsLast7Days"`
+	DropsLast30Days  int     `json:"dropsLast30Days"`
+	EstimatedSavings float64 `json:"estimatedSavings"`
+}
+
+// numericPriceSQL extracts a numeric value from a price text column in SQL,
+// mirroring the scheduler's parsePrice for the common "$1,234.56" shape.
+func numericPriceSQL(column string) string {
+	return `NULLIF(regexp_replace(` + column + `, '[^0-9.]', '', 'g'), '')::numeric`
+}
+
+// statsHandler serves /stats.
+var statsHandler = methods{"GET": getStatsHandler}.ServeHTTP
+
+func getStatsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	var stats Stats
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE paused_at IS NULL),
+			COUNT(*) FILTER (WHERE paused_at IS NOT NULL)
+		FROM tracked_items
+		WHERE user_id = $1
+	`, userID).Scan(&stats.ItemsTracked, &stats.ActiveItems, &stats.PausedItems)
+	if err != nil {
+		slog.Error("Failed to query item stats", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Each drop notification records the step from the previous price to the
+	// new one, so summing the steps estimates the total saved across drops.
+	err = db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE created_at >= NOW() - INTERVAL '7 days'),
+			COUNT(*) FILTER (WHERE created_at >= NOW() - INTERVAL '30 days'),
+			COALESCE(SUM(GREATEST(`+numericPriceSQL("old_price")+` - `+numericPriceSQL("new_price")+`, 0)), 0)
+		FROM notifications
+		WHERE user_id = $1 AND type = 'price_drop'
+	`, userID).Scan(&stats.DropsLast7Days, &stats.DropsLast30Days, &stats.EstimatedSavings)
+	if err != nil {
+		slog.Error("Failed to query drop stats", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
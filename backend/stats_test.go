@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestStatsHandler(t *testing.T) {
+	mock := setupMockDB(t)
+
+	// Seeded data: 5 items (1 paused) and drops of which 2 were this week.
+	mock.ExpectQuery("FROM tracked_items").
+		WithArgs("test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"total", "active", "paused"}).AddRow(5, 4, 1))
+	mock.ExpectQuery("FROM notifications\\s+WHERE user_id = \\$1 AND type = 'price_drop'").
+		WithArgs("test-user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"drops7", "drops30", "savings"}).AddRow(2, 3, 42.5))
+
+	req := httptest.NewRequest("GET", "/stats", nil)
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	statsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var stats Stats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	expected := Stats{ItemsTracked: 5, ActiveItems: 4, PausedItems: 1, DropsLast7Days: 2, DropsLast30Days: 3, EstimatedSavings: 42.5}
+	if stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestStatsHandler_Unauthorized(t *testing.T) {
+	req := httptest.NewRequest("GET", "/stats", nil)
+	w := httptest.NewRecorder()
+
+	statsHandler(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestItemsHandler_GetByTag(t *testing.T) {
+	mock := setupMockDB(t)
+
+	row := itemRow("a")
+	row[len(row)-3] = "{gifts,electronics}"
+	mock.ExpectQuery(`SELECT COUNT\(\*\), MAX\(updated_at\)\s+FROM tracked_items\s+WHERE user_id = \$1 AND tags @> \$2`).
+		WithArgs("test-user-id", `{"gifts","sale"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(1, etagUpdatedAt))
+	mock.ExpectQuery(`WHERE user_id = \$1 AND tags @> \$2\s+ORDER BY created_at DESC, id DESC`).
+		WithArgs("test-user-id", `{"gifts","sale"}`).
+		WillReturnRows(sqlmock.NewRows(itemColumnNames).AddRow(row...))
+
+	w := getItems(t, "/items?tag=Gifts&tag=sale&tag=gifts", "")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var items []TrackedItem
+	if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(items) != 1 || strings.Join(items[0].Tags, ",") != "gifts,electronics" {
+		t.Errorf("Expected item a with its tags, got %+v", items)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemsHandler_GetReturnsEmptyTags(t *testing.T) {
+	mock := setupMockDB(t)
+
+	expectItemsETag(mock, "test-user-id", 1)
+	mock.ExpectQuery("FROM tracked_items").
+		WithArgs("test-user-id").
+		WillReturnRows(sqlmock.NewRows(itemColumnNames).AddRow(itemRow("a")...))
+
+	w := getItems(t, "/items", "")
+
+	if !strings.Contains(w.Body.String(), `"tags":[]`) {
+		t.Errorf("Expected an empty tags array, got %s", w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemsHandler_GetRejectsEmptyTag(t *testing.T) {
+	setupMockDB(t)
+
+	if w := getItems(t, "/items?tag=%20", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestItemsHandler_PostStoresTags(t *testing.T) {
+	mock := setupMockDB(t)
+
+	expectItemsQuota(mock, "test-user-id", nil, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tracked_items").
+		WithArgs("item-1", "$19.99", "Widget", "", ".price", "", "https://example.com/p/1", sqlmock.AnyArg(), sqlmock.AnyArg(), "test-user-id", nil, nil, "{}", "https://example.com/p/1", "auto", 19.99, "en-US", nil, nil, "", "", "", "", "", `{"gifts","electronics"}`, "", "", "19.99 USD", "first", nil, "first", "", "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	body := `{"id":"item-1","priceText":"$19.99","productName":"Widget","cssSelector":".price","pageUrl":"https://example.com/p/1",` +
+		`"capturedAtIso":"2025-01-01T00:00:00Z","savedAtIso":"2025-01-01T00:00:00Z","tags":[" Gifts ","electronics","gifts",""]}`
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+	req = req.WithContext(setupTestContext("test-user-id"))
+	w := httptest.NewRecorder()
+
+	itemsHandler(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestItemHandler_PatchTags(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectExec(`SET tags = \$3, last_interacted_at = NOW\(\)`).
+		WithArgs("item-1", "test-user-id", "{}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	tooMany := `["` + strings.Join(strings.Split("abcdefghijklmnopqrstu", ""), `","`) + `"]`
+	for body, want := range map[string]int{
+		`{"tags":[]}`: http.StatusNoContent,
+		`{"tags":["` + strings.Repeat("x", 33) + `"]}`: http.StatusBadRequest,
+		`{"tags":` + tooMany + `}`:                     http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest("PATCH", "/items/item-1", strings.NewReader(body))
+		req.SetPathValue("id", "item-1")
+		req = req.WithContext(setupTestContext("test-user-id"))
+		w := httptest.NewRecorder()
+
+		itemHandler(w, req)
+
+		if w.Code != want {
+			t.Errorf("%s: Expected status %d, got %d: %s", body, want, w.Code, w.Body.String())
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"price-track-backend/internal/cache"
+)
+
+const (
+	// trendingCacheTTL is how long /trending is served from memory. Drops
+	// are only detected once per scheduler run, so minutes of staleness cost
+	// nothing.
+	trendingCacheTTL = 5 * time.Minute
+	// defaultTrendingLimit and maxTrendingLimit bound ?limit= on /trending.
+	defaultTrendingLimit = 10
+	maxTrendingLimit     = 50
+)
+
+// trendingDisabled turns /trending off (TRENDING_DISABLED). It is set from
+// the configuration in main.
+var trendingDisabled bool
+
+// trendingCache holds rendered /trending responses. Like responseCache it
+// starts disabled; main enables it.
+var trendingCache = cache.New[cachedResponse](0)
+
+// TrendingDrop is one of the biggest recent price drops on the instance. It
+// names the product and its shop's domain, never the page URL, who tracks it
+// or how it is scraped.
+type TrendingDrop struct {
+	ProductName string  `json:"productName"`
+	Domain      string  `json:"domain"`
+	OldPrice    string  `json:"oldPrice"`
+	NewPrice    string  `json:"newPrice"`
+	DropPercent float64 `json:"dropPercent"`
+}
+
+// trendingHandler serves /trending without authentication.
+var trendingHandler = methods{"GET": getTrendingHandler}.ServeHTTP
+
+// getTrendingHandler returns the biggest price drops detected across all
+// users in the last day, one per product page, largest first.
+func getTrendingHandler(w http.ResponseWriter, r *http.Request) {
+	if trendingDisabled {
+		http.NotFound(w, r)
+		return
+	}
+
+	limit := defaultTrendingLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxTrendingLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxTrendingLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	resp, err := trendingCache.GetOrLoad("trending|"+strconv.Itoa(limit), func() (cachedResponse, error) {
+		drops, err := loadTrendingDrops(ctx, limit)
+		if err != nil {
+			return cachedResponse{}, err
+		}
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(drops); err != nil {
+			return cachedResponse{}, err
+		}
+		return cachedResponse{Body: buf.Bytes()}, nil
+	})
+	if err != nil {
+		slog.Error("Failed to load trending drops", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(trendingCacheTTL/time.Second)))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp.Body)
+}
+
+// loadTrendingDrops aggregates the last day's price drop notifications by
+// normalized page URL, so a product tracked by several users appears once
+// with its biggest drop.
+func loadTrendingDrops(ctx context.Context, limit int) ([]TrendingDrop, error) {
+	oldPrice, newPrice := numericPriceSQL("n.old_price"), numericPriceSQL("n.new_price")
+	rows, err := db.QueryContext(ctx, `
+		SELECT normalized_url, product_name, old_price, new_price, drop_percent
+		FROM (
+			SELECT DISTINCT ON (t.normalized_url) t.normalized_url, t.product_name, n.old_price, n.new_price,
+				(`+oldPrice+` - `+newPrice+`) / `+oldPrice+` * 100 AS drop_percent
+			FROM notifications n
+			JOIN tracked_items t ON t.id = n.product_id
+			WHERE n.type = 'price_drop'
+				AND n.created_at >= NOW() - INTERVAL '1 day'
+				AND t.normalized_url IS NOT NULL
+				AND `+oldPrice+` > 0
+				AND `+newPrice+` < `+oldPrice+`
+			ORDER BY t.normalized_url, drop_percent DESC
+		) drops
+		ORDER BY drop_percent DESC, normalized_url
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drops := []TrendingDrop{}
+	for rows.Next() {
+		var d TrendingDrop
+		var pageURL string
+		if err := rows.Scan(&pageURL, &d.ProductName, &d.OldPrice, &d.NewPrice, &d.DropPercent); err != nil {
+			return nil, err
+		}
+		if u, err := url.Parse(pageURL); err == nil {
+			d.Domain = u.Hostname()
+		}
+		d.DropPercent = math.Round(d.DropPercent*10) / 10
+		drops = append(drops, d)
+	}
+	return drops, rows.Err()
+}
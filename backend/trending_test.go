@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/cache"
+)
+
+var trendingColumns = []string{"normalized_url", "product_name", "old_price", "new_price", "drop_percent"}
+
+func getTrending(t *testing.T, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	trendingHandler(w, httptest.NewRequest("GET", target, nil))
+	return w
+}
+
+func TestTrendingHandler(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery(`SELECT DISTINCT ON \(t.normalized_url\)`).
+		WithArgs(defaultTrendingLimit).
+		WillReturnRows(sqlmock.NewRows(trendingColumns).
+			AddRow("https://shop.example.com/p/1", "Widget", "$40.00", "$30.00", 25.0).
+			AddRow("https://other.example.org/gadget", "Gadget", "$9.99", "$8.99", 10.01001001))
+
+	w := getTrending(t, "/trending")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Header().Get("Cache-Control"), "max-age=300") {
+		t.Errorf("Expected a cacheable response, got Cache-Control %q", w.Header().Get("Cache-Control"))
+	}
+	var drops []TrendingDrop
+	if err := json.Unmarshal(w.Body.Bytes(), &drops); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := []TrendingDrop{
+		{ProductName: "Widget", Domain: "shop.example.com", OldPrice: "$40.00", NewPrice: "$30.00", DropPercent: 25},
+		{ProductName: "Gadget", Domain: "other.example.org", OldPrice: "$9.99", NewPrice: "$8.99", DropPercent: 10},
+	}
+	if len(drops) != len(want) || drops[0] != want[0] || drops[1] != want[1] {
+		t.Errorf("Expected %+v, got %+v", want, drops)
+	}
+	if strings.Contains(w.Body.String(), "/p/1") {
+		t.Errorf("Expected page URLs to be stripped, got %s", w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestTrendingHandler_Cached(t *testing.T) {
+	mock := setupMockDB(t)
+	prev := trendingCache
+	trendingCache = cache.New[cachedResponse](time.Minute)
+	t.Cleanup(func() { trendingCache = prev })
+
+	mock.ExpectQuery("FROM notifications").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(trendingColumns).AddRow("https://shop.example.com/p/1", "Widget", "$40.00", "$30.00", 25.0))
+
+	first := getTrending(t, "/trending?limit=5")
+	second := getTrending(t, "/trending?limit=5")
+
+	if second.Code != http.StatusOK || second.Body.String() != first.Body.String() {
+		t.Errorf("Expected the cached response, got %d: %s", second.Code, second.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestTrendingHandler_Disabled(t *testing.T) {
+	setupMockDB(t)
+	trendingDisabled = true
+	t.Cleanup(func() { trendingDisabled = false })
+
+	if w := getTrending(t, "/trending"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestTrendingHandler_InvalidLimit(t *testing.T) {
+	setupMockDB(t)
+
+	for _, limit := range []string{"0", "51", "ten"} {
+		if w := getTrending(t, "/trending?limit="+limit); w.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s: expected status %d, got %d", limit, http.StatusBadRequest, w.Code)
+		}
+	}
+}
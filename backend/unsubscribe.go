@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"price-track-backend/internal/email"
+)
+
+// unsubscribeHandler serves /unsubscribe. It is opened from an email footer
+// (GET) or by the mail client's one-click List-Unsubscribe (POST), so the
+// token stands in for authentication.
+var unsubscribeHandler = methods{
+	"GET":  unsubscribe,
+	"POST": unsubscribe,
+}.ServeHTTP
+
+// settingsUnsubscribeRotateHandler serves /settings/unsubscribe/rotate.
+var settingsUnsubscribeRotateHandler = methods{"POST": postSettingsUnsubscribeRotateHandler}.ServeHTTP
+
+// unsubscribe turns off the channel named in the token and responds with a
+// short confirmation page. Following the same link again changes nothing and
+// confirms again.
+func unsubscribe(w http.ResponseWriter, r *http.Request) {
+	claims, err := email.VerifyUnsubscribe(emailTokenSecret, r.URL.Query().Get("token"))
+	if err != nil {
+		http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	err = email.Unsubscribe(ctx, db, claims)
+	if errors.Is(err, email.ErrTokenRevoked) {
+		http.Error(w, "This unsubscribe link is no longer valid; use the one in a more recent email", http.StatusGone)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to unsubscribe", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	settingsLoader.Invalidate(claims.UserID)
+
+	slog.Info("Unsubscribed", "user_id", claims.UserID, "channel", claims.Channel)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!doctype html><title>Unsubscribed</title><p>You will no longer receive %s from PriceTrack. You can turn them back on in the extension's settings.</p>\n", claims.Channel.Description())
+}
+
+// postSettingsUnsubscribeRotateHandler revokes every unsubscribe link sent to
+// the user so far, for example after forwarding an email by mistake.
+func postSettingsUnsubscribeRotateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	if err := email.RotateUnsubscribeNonce(ctx, db, userID); err != nil {
+		slog.Error("Failed to rotate unsubscribe nonce", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Rotated unsubscribe nonce", "user_id", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"price-track-backend/internal/email"
+)
+
+func doUnsubscribe(method, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/unsubscribe?token="+url.QueryEscape(token), nil)
+	w := httptest.NewRecorder()
+	unsubscribeHandler(w, req)
+	return w
+}
+
+func TestUnsubscribe_RepeatedIsIdempotent(t *testing.T) {
+	mock := setupMockDB(t)
+	setEmailSender(t)
+	token := email.SignUnsubscribe(emailTokenSecret, email.UnsubscribeClaims{UserID: "user-1", Channel: email.ChannelPriceDrops, Nonce: "nonce-1"})
+
+	for range 2 {
+		mock.ExpectExec("SET email_price_drops = FALSE").
+			WithArgs("user-1", "nonce-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	// The mail client's one-click POST, then the user following the footer link.
+	for _, method := range []string{"POST", "GET"} {
+		w := doUnsubscribe(method, token)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status %d, got %d", method, http.StatusOK, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "price drop emails") {
+			t.Errorf("%s: expected a confirmation naming the channel, got %q", method, w.Body.String())
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestUnsubscribe_ForgedToken(t *testing.T) {
+	mock := setupMockDB(t)
+	setEmailSender(t)
+	forged := email.SignUnsubscribe([]byte("other-secret"), email.UnsubscribeClaims{UserID: "user-1", Channel: email.ChannelPriceDrops, Nonce: "nonce-1"})
+
+	for _, token := range []string{forged, ""} {
+		if w := doUnsubscribe("GET", token); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expected no queries, got: %v", err)
+	}
+}
+
+func TestUnsubscribe_RotatedNonce(t *testing.T) {
+	mock := setupMockDB(t)
+	setEmailSender(t)
+	token := email.SignUnsubscribe(emailTokenSecret, email.UnsubscribeClaims{UserID: "user-1", Channel: email.ChannelDigest, Nonce: "old-nonce"})
+
+	mock.ExpectExec("INSERT INTO user_settings").
+		WithArgs("user-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SET digest_frequency = 'off'").
+		WithArgs("user-1", "old-nonce").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest("POST", "/settings/unsubscribe/rotate", nil).WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+	settingsUnsubscribeRotateHandler(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	if w := doUnsubscribe("GET", token); w.Code != http.StatusGone {
+		t.Errorf("Expected status %d for a revoked link, got %d", http.StatusGone, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
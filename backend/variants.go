@@ -0,0 +1,187 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ItemVariant is one separately priced option (size, color, ...) of a tracked
+// item. The scheduler scrapes each variant's selector on the item's page.
+type ItemVariant struct {
+	ID               int64   `json:"id"`
+	Label            string  `json:"label"`
+	CSSSelector      string  `json:"cssSelector"`
+	XPath            string  `json:"xPath"`
+	PriceText        *string `json:"priceText"`
+	LastScrapeStatus *string `json:"lastScrapeStatus"`
+	CreatedAt        string  `json:"createdAt"`
+}
+
+// itemVariantsHandler serves /items/{id}/variants.
+var itemVariantsHandler = methods{
+	"GET":  listVariantsHandler,
+	"POST": createVariantHandler,
+}.ServeHTTP
+
+func listVariantsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	// Joining from tracked_items yields one row with NULL variant columns for
+	// an owned item without variants, and none at all for a foreign item.
+	id := r.PathValue("id")
+	rows, err := db.QueryContext(ctx, `
+		SELECT v.id, v.label, v.css_selector, v.xpath, v.price_text, v.last_scrape_status, v.created_at
+		FROM tracked_items
+		LEFT JOIN item_variants v ON v.item_id = tracked_items.id
+		WHERE tracked_items.id = $1 AND tracked_items.user_id = $2
+		ORDER BY v.id
+	`, id, userID)
+	if err != nil {
+		slog.Error("Failed to query variants", "id", id, "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	found := false
+	variants := []ItemVariant{}
+	for rows.Next() {
+		found = true
+		var variantID sql.NullInt64
+		var label, css, xpath sql.NullString
+		var createdAt sql.NullTime
+		var v ItemVariant
+		if err := rows.Scan(&variantID, &label, &css, &xpath, &v.PriceText, &v.LastScrapeStatus, &createdAt); err != nil {
+			slog.Error("Failed to scan variant", "error", err)
+			continue
+		}
+		if !variantID.Valid {
+			continue
+		}
+		v.ID, v.Label, v.CSSSelector, v.XPath = variantID.Int64, label.String, css.String, xpath.String
+		v.CreatedAt = createdAt.Time.Format(time.RFC3339)
+		variants = append(variants, v)
+	}
+	if !found {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variants)
+}
+
+func createVariantHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	var v ItemVariant
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	v.Label = strings.TrimSpace(v.Label)
+	if v.Label == "" || len(v.Label) > 100 {
+		http.Error(w, "label is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	if v.CSSSelector == "" && v.XPath == "" {
+		http.Error(w, "cssSelector or xPath is required", http.StatusBadRequest)
+		return
+	}
+
+	// Selecting from tracked_items both checks ownership and yields no row
+	// (sql.ErrNoRows) when the item is missing or belongs to someone else.
+	id := r.PathValue("id")
+	var createdAt time.Time
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO item_variants (item_id, label, css_selector, xpath)
+		SELECT id, $3, $4, $5
+		FROM tracked_items
+		WHERE id = $1 AND user_id = $2
+		RETURNING id, created_at
+	`, id, userID, v.Label, v.CSSSelector, v.XPath).Scan(&v.ID, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		http.Error(w, "A variant with this label already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to insert variant", "id", id, "error", err)
+		queryError(ctx, w, err, "Failed to create variant", http.StatusInternalServerError)
+		return
+	}
+	v.PriceText, v.LastScrapeStatus = nil, nil
+	v.CreatedAt = createdAt.Format(time.RFC3339)
+
+	slog.Info("Created item variant", "id", id, "variant_id", v.ID, "user_id", userID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(v)
+}
+
+// itemVariantHandler serves /items/{id}/variants/{variantId}.
+var itemVariantHandler = methods{"DELETE": deleteVariantHandler}.ServeHTTP
+
+func deleteVariantHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	id := r.PathValue("id")
+	variantID, err := strconv.ParseInt(r.PathValue("variantId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Variant not found", http.StatusNotFound)
+		return
+	}
+	result, err := db.ExecContext(ctx, `
+		DELETE FROM item_variants v
+		USING tracked_items
+		WHERE v.id = $1 AND v.item_id = $2
+		  AND tracked_items.id = v.item_id AND tracked_items.user_id = $3
+	`, variantID, id, userID)
+	if err != nil {
+		slog.Error("Failed to delete variant", "id", id, "variant_id", variantID, "error", err)
+		queryError(ctx, w, err, "Failed to delete variant", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "Variant not found", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("Deleted item variant", "id", id, "variant_id", variantID, "user_id", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestItemVariantsHandler_List(t *testing.T) {
+	mock := setupMockDB(t)
+
+	columns := []string{"id", "label", "css_selector", "xpath", "price_text", "last_scrape_status", "created_at"}
+	mock.ExpectQuery("LEFT JOIN item_variants").
+		WithArgs("item-1", "user-1").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "Small", ".price-s", "", "$10.00", "success", time.Now()).
+			AddRow(2, "Large", ".price-l", "", nil, nil, time.Now()))
+
+	req := httptest.NewRequest("GET", "/items/item-1/variants", nil)
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+
+	itemVariantsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var variants []ItemVariant
+	if err := json.NewDecoder(w.Body).Decode(&variants); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(variants) != 2 || variants[0].Label != "Small" || *variants[0].PriceText != "$10.00" || variants[1].PriceText != nil {
+		t.Errorf("Unexpected variants: %+v", variants)
+	}
+}
+
+func TestItemVariantsHandler_ListWithoutVariants(t *testing.T) {
+	mock := setupMockDB(t)
+
+	columns := []string{"id", "label", "css_selector", "xpath", "price_text", "last_scrape_status", "created_at"}
+	mock.ExpectQuery("LEFT JOIN item_variants").
+		WithArgs("item-1", "user-1").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(nil, nil, nil, nil, nil, nil, nil))
+
+	req := httptest.NewRequest("GET", "/items/item-1/variants", nil)
+	req.SetPathValue("id", "item-1")
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+
+	itemVariantsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != "[]" {
+		t.Errorf("Expected empty list, got %s", body)
+	}
+}
+
+func TestItemVariantsHandler_ListUnknownItem(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("LEFT JOIN item_variants").
+		WithArgs("other", "user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "label", "css_selector", "xpath", "price_text", "last_scrape_status", "created_at"}))
+
+	req := httptest.NewRequest("GET", "/items/other/variants", nil)
+	req.SetPathValue("id", "other")
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+
+	itemVariantsHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestItemVariantsHandler_Create(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		dbErr    error
+		expected int
+	}{
+		{"created", `{"label":" Large ","cssSelector":".price-l"}`, nil, http.StatusCreated},
+		{"missing label", `{"cssSelector":".price-l"}`, nil, http.StatusBadRequest},
+		{"missing selector", `{"label":"Large"}`, nil, http.StatusBadRequest},
+		{"unknown item", `{"label":"Large","cssSelector":".price-l"}`, sql.ErrNoRows, http.StatusNotFound},
+		{"duplicate label", `{"label":"Large","cssSelector":".price-l"}`, &pq.Error{Code: "23505"}, http.StatusConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := setupMockDB(t)
+			if test.expected != http.StatusBadRequest {
+				query := mock.ExpectQuery("INSERT INTO item_variants").
+					WithArgs("item-1", "user-1", "Large", ".price-l", "")
+				if test.dbErr != nil {
+					query.WillReturnError(test.dbErr)
+				} else {
+					query.WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, time.Now()))
+				}
+			}
+
+			req := httptest.NewRequest("POST", "/items/item-1/variants", strings.NewReader(test.body))
+			req.SetPathValue("id", "item-1")
+			req = req.WithContext(setupTestContext("user-1"))
+			w := httptest.NewRecorder()
+
+			itemVariantsHandler(w, req)
+
+			if w.Code != test.expected {
+				t.Errorf("Expected status %d, got %d: %s", test.expected, w.Code, w.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestItemVariantHandler_Delete(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectExec("DELETE FROM item_variants").
+		WithArgs(int64(7), "item-1", "user-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("DELETE", "/items/item-1/variants/7", nil)
+	req.SetPathValue("id", "item-1")
+	req.SetPathValue("variantId", "7")
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+
+	itemVariantHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"price-track-backend/internal/config"
+	"price-track-backend/internal/version"
+)
+
+// VersionResponse is returned by GET /version. It must only ever contain
+// build metadata and on/off feature flags, never configuration values.
+type VersionResponse struct {
+	version.Info
+	Features map[string]bool `json:"features"`
+}
+
+// features are the optional features reported by /version. They are set
+// from the configuration in main.
+var features map[string]bool
+
+// enabledFeatures reports which optional features cfg turns on.
+func enabledFeatures(cfg config.Config) map[string]bool {
+	return map[string]bool{
+		"playwright":     !cfg.Scheduler.PlaywrightDisabled,
+		"jsonLDFallback": !cfg.Scheduler.JSONLDFallbackDisabled,
+		"adminEndpoints": len(cfg.AdminUserIDs) > 0,
+		"trending":       !cfg.TrendingDisabled,
+		"docs":           cfg.APIDocs,
+		"tls":            cfg.TLS.Enabled(),
+		"redis":          cfg.RedisURL != "",
+		"responseCache":  cfg.CacheTTL > 0,
+		"email":          cfg.SMTP.Addr != "",
+		"realtime":       cfg.Realtime.URL != "",
+		"itemCookies":    cfg.Cookies != nil,
+		"screenshots":    cfg.Blobs != nil,
+	}
+}
+
+// versionHandler serves /version.
+var versionHandler = methods{"GET": getVersionHandler}.ServeHTTP
+
+func getVersionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(VersionResponse{
+		Info:     version.Get(),
+		Features: features,
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"price-track-backend/internal/config"
+)
+
+func TestVersionHandler(t *testing.T) {
+	prevSecret := jwtSecret
+	jwtSecret = "super-secret-value"
+	t.Cleanup(func() { jwtSecret = prevSecret })
+	setAdminUserIDs(t, "admin-user-id")
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	w := httptest.NewRecorder()
+
+	versionHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	body := w.Body.String()
+	if strings.Contains(body, "super-secret-value") || strings.Contains(body, "admin-user-id") {
+		t.Errorf("Response leaks environment values: %s", body)
+	}
+
+	var resp map[string]any
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	for _, key := range []string{"version", "commit", "buildDate", "goVersion", "features"} {
+		if _, ok := resp[key]; !ok {
+			t.Errorf("Expected key %q in response", key)
+		}
+	}
+}
+
+func TestEnabledFeatures(t *testing.T) {
+	cfg := config.Config{
+		AdminUserIDs: []string{"admin-user-id"},
+		APIDocs:      true,
+		RedisURL:     "redis://localhost:6379",
+		TLS:          config.TLSConfig{AutocertDomains: []string{"api.example.com"}},
+	}
+	cfg.Scheduler.PlaywrightDisabled = true
+
+	got := enabledFeatures(cfg)
+	want := map[string]bool{
+		"playwright":     false,
+		"jsonLDFallback": true,
+		"adminEndpoints": true,
+		"trending":       true,
+		"docs":           true,
+		"tls":            true,
+		"redis":          true,
+		"responseCache":  false,
+		"email":          false,
+		"realtime":       false,
+		"itemCookies":    false,
+		"screenshots":    false,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("Expected features %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultDeliveryPageSize applies when /webhook/deliveries is requested
+// without a limit.
+const defaultDeliveryPageSize = 50
+
+// Webhook is the user's registered webhook endpoint.
+type Webhook struct {
+	URL       string `json:"url"`
+	UpdatedAt string `json:"updatedAt,omitempty"`
+}
+
+// WebhookDelivery reports the state of one webhook delivery. Status is
+// pending (will be retried at NextAttemptAt), delivered or failed.
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	URL           string          `json:"url"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     *string         `json:"lastError"`
+	NextAttemptAt *string         `json:"nextAttemptAt"`
+	DeliveredAt   *string         `json:"deliveredAt"`
+	CreatedAt     string          `json:"createdAt"`
+}
+
+// webhookHandler serves /webhook.
+var webhookHandler = methods{
+	"GET":    getWebhookHandler,
+	"PUT":    putWebhookHandler,
+	"DELETE": deleteWebhookHandler,
+}.ServeHTTP
+
+func getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	var hook Webhook
+	var updatedAt time.Time
+	err := db.QueryRowContext(ctx, `
+		SELECT url, updated_at FROM user_webhooks WHERE user_id = $1
+	`, userID).Scan(&hook.URL, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "No webhook configured", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to query webhook", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	hook.UpdatedAt = updatedAt.Format(time.RFC3339)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hook)
+}
+
+func putWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	var hook Webhook
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, "url must be an http(s) URL", http.StatusBadRequest)
+		return
+	}
+
+	var updatedAt time.Time
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO user_webhooks (user_id, url)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET url = EXCLUDED.url, updated_at = NOW()
+		RETURNING updated_at
+	`, userID, hook.URL).Scan(&updatedAt)
+	if err != nil {
+		slog.Error("Failed to save webhook", "error", err)
+		queryError(ctx, w, err, "Failed to save webhook", http.StatusInternalServerError)
+		return
+	}
+	hook.UpdatedAt = updatedAt.Format(time.RFC3339)
+
+	slog.Info("Saved webhook", "user_id", userID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hook)
+}
+
+func deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, `DELETE FROM user_webhooks WHERE user_id = $1`, userID)
+	if err != nil {
+		slog.Error("Failed to delete webhook", "error", err)
+		queryError(ctx, w, err, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "No webhook configured", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("Deleted webhook", "user_id", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// webhookDeliveriesHandler serves /webhook/deliveries.
+var webhookDeliveriesHandler = methods{"GET": listWebhookDeliveriesHandler}.ServeHTTP
+
+// listWebhookDeliveriesHandler returns the user's webhook deliveries, newest
+// first, optionally filtered with ?status=.
+func listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, offset, err := parsePagination(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = defaultDeliveryPageSize
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", "pending", "delivered", "failed":
+	default:
+		http.Error(w, "status must be pending, delivered or failed", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, url, payload, status, attempts, last_error, next_attempt_at, delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE user_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, userID, status, limit, offset)
+	if err != nil {
+		slog.Error("Failed to query webhook deliveries", "error", err)
+		queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload []byte
+		var lastError sql.NullString
+		var nextAttemptAt, deliveredAt sql.NullTime
+		var createdAt time.Time
+		if err := rows.Scan(&d.ID, &d.URL, &payload, &d.Status, &d.Attempts, &lastError, &nextAttemptAt, &deliveredAt, &createdAt); err != nil {
+			slog.Error("Failed to scan webhook delivery", "error", err)
+			continue
+		}
+		d.Payload = payload
+		if lastError.Valid {
+			d.LastError = &lastError.String
+		}
+		if nextAttemptAt.Valid {
+			formatted := nextAttemptAt.Time.Format(time.RFC3339)
+			d.NextAttemptAt = &formatted
+		}
+		if deliveredAt.Valid {
+			formatted := deliveredAt.Time.Format(time.RFC3339)
+			d.DeliveredAt = &formatted
+		}
+		d.CreatedAt = createdAt.Format(time.RFC3339)
+		deliveries = append(deliveries, d)
+	}
+
+	var total int64
+	if wantsEnvelope(r) {
+		if err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM webhook_deliveries WHERE user_id = $1 AND ($2 = '' OR status = $2)
+		`, userID, status).Scan(&total); err != nil {
+			slog.Error("Failed to count webhook deliveries", "error", err)
+			queryError(ctx, w, err, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	respondList(w, r, newListMeta(total, limit, offset), jsonList(deliveries))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestWebhookHandler_Put(t *testing.T) {
+	mock := setupMockDB(t)
+
+	mock.ExpectQuery("INSERT INTO user_webhooks").
+		WithArgs("user-1", "https://hooks.example.com/drops").
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(time.Now()))
+
+	req := httptest.NewRequest("PUT", "/webhook", strings.NewReader(`{"url":"https://hooks.example.com/drops"}`))
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+
+	webhookHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestWebhookHandler_PutInvalidURL(t *testing.T) {
+	setupMockDB(t)
+
+	for _, body := range []string{`{"url":""}`, `{"url":"ftp://example.com"}`, `{"url":"/relative"}`} {
+		req := httptest.NewRequest("PUT", "/webhook", strings.NewReader(body))
+		req = req.WithContext(setupTestContext("user-1"))
+		w := httptest.NewRecorder()
+
+		webhookHandler(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestWebhookDeliveriesHandler_List(t *testing.T) {
+	mock := setupMockDB(t)
+
+	now := time.Now()
+	columns := []string{"id", "url", "payload", "status", "attempts", "last_error", "next_attempt_at", "delivered_at", "created_at"}
+	mock.ExpectQuery("FROM webhook_deliveries").
+		WithArgs("user-1", "pending", defaultDeliveryPageSize, 0).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(2, "https://hooks.example.com", []byte(`{"event":"price_drop"}`), "pending", 2, "bad status code: 502", now.Add(time.Minute), nil, now))
+
+	req := httptest.NewRequest("GET", "/webhook/deliveries?status=pending", nil)
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+
+	webhookDeliveriesHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var deliveries []WebhookDelivery
+	if err := json.Unmarshal(w.Body.Bytes(), &deliveries); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Attempts != 2 || deliveries[0].NextAttemptAt == nil || deliveries[0].DeliveredAt != nil {
+		t.Errorf("Unexpected deliveries: %+v", deliveries)
+	}
+	if string(deliveries[0].Payload) != `{"event":"price_drop"}` {
+		t.Errorf("Expected payload to be passed through, got %s", deliveries[0].Payload)
+	}
+}
+
+func TestWebhookDeliveriesHandler_InvalidStatus(t *testing.T) {
+	setupMockDB(t)
+
+	req := httptest.NewRequest("GET", "/webhook/deliveries?status=lost", nil)
+	req = req.WithContext(setupTestContext("user-1"))
+	w := httptest.NewRecorder()
+
+	webhookDeliveriesHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
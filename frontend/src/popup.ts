@@ -357,7 +357,13 @@ async function renderTrackedItems() {
     const scrapeFailedIndicator =
       item.lastScrapeStatus === "failed"
         ? '<span class="scrape-failed-text" style="color:red; font-size: 0.9em; margin-top: 4px; display: block;"><span style="font-size: 1.2em; font-weight: bold; margin-right: 4px;">*</span>Scrape failed, check manually</span>'
-        : "";
+        : item.lastScrapeStatus === "selector_broken"
+          ? '<span class="scrape-failed-text" style="color:darkorange; font-size: 0.9em; margin-top: 4px; display: block;"><span style="font-size: 1.2em; font-weight: bold; margin-right: 4px;">*</span>Price element moved, re-pick the price</span>'
+          : item.lastScrapeStatus === "discontinued"
+            ? '<span class="scrape-failed-text" style="color:gray; font-size: 0.9em; margin-top: 4px; display: block;"><span style="font-size: 1.2em; font-weight: bold; margin-right: 4px;">*</span>Product page is gone, no longer checked</span>'
+            : item.lastScrapeStatus === "out_of_stock"
+              ? '<span class="scrape-failed-text" style="color:gray; font-size: 0.9em; margin-top: 4px; display: block;"><span style="font-size: 1.2em; font-weight: bold; margin-right: 4px;">*</span>Out of stock</span>'
+              : "";
 
     // Use oldPrice and newPrice from notification if available
     let priceHtml = `<span class="item-price">${escapeHtml(item.priceText)}</span>`;
@@ -13,6 +13,21 @@ export type TrackedItem = PricePickPayload & {
   savedAtIso: string;
   id: string;
   lastScrapeStatus?: string;
+  imageUrls?: string[];
+  parseStrategy?: "auto" | "us" | "eu" | "plain" | "lakh";
+  adapterOrder?: "first" | "last";
+  selectorMatch?: "first" | "min" | "max" | `index:${number}`;
+  maxScrapeSeconds?: number;
+  acceptLanguage?: string;
+  observedPrice?: number;
+  shippingSelector?: string;
+  shippingPrice?: number;
+  totalPrice?: number;
+  notificationChannel?: "all" | "in_app" | "email" | "webhook";
+  availabilitySelector?: string;
+  availability?: "in_stock" | "out_of_stock";
+  priceFirstSeenAt?: string;
+  priceLastChangedAt?: string;
 };
 
 export type RuntimeMessage =
@@ -28,6 +43,7 @@ export interface Notification {
   title: string;
   message: string;
   type: "price_drop" | string;
+  severity: "info" | "notice" | "alert";
   productId?: string;
   oldPrice?: string;
   newPrice?: string;
@@ -3,7 +3,7 @@ set -e
 
 echo "1. Building binaries..."
 cd backend
-go build -o api ./main.go
+go build -o api .
 go build -o scraper ./cmd/scraper/main.go
 
 echo "2. Starting API (background)..."